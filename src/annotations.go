@@ -0,0 +1,15 @@
+package main
+
+// Annotations label a result with what was measured and why, so results
+// can be grouped and attributed later.
+type Annotations struct {
+	Tags    map[string]string `json:"tags,omitempty"` // from --tag
+	Note    string            `json:"note,omitempty"`
+	Git     *GitInfo          `json:"git,omitempty"`     // from --git
+	SysInfo *SystemInfo       `json:"sysinfo,omitempty"` // from --sysinfo
+}
+
+// annotations returns the labels from --tag, --note, --git, and --sysinfo.
+func (c *runCmd) annotations() Annotations {
+	return Annotations{Tags: c.Tag, Note: c.Note, Git: c.git, SysInfo: c.sysinfo}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+)
+
+var (
+	errAttestNeedsSign = errors.New("--attest needs --sign")
+	errNotAttestation  = errors.New("not an in-toto attestation")
+)
+
+const (
+	inTotoPayloadType      = "application/vnd.in-toto+json"
+	inTotoStatementType    = "https://in-toto.io/Statement/v1"
+	benchmarkPredicateType = "https://github.com/howmanysmall/ztime/attestation/benchmark/v1"
+)
+
+// Envelope is a DSSE envelope, which signs a payload together with its
+// type.
+type Envelope struct {
+	PayloadType string              `json:"payloadType"`
+	Payload     string              `json:"payload"` // base64
+	Signatures  []EnvelopeSignature `json:"signatures"`
+}
+
+// EnvelopeSignature is a signature in an Envelope. The key ID is the
+// base64 Ed25519 public key, as in a Signature.
+type EnvelopeSignature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"` // base64
+}
+
+// Statement is an in-toto statement: a claim, the predicate, about the
+// subjects.
+type Statement struct {
+	Type          string             `json:"_type"`
+	Subject       []Subject          `json:"subject"`
+	PredicateType string             `json:"predicateType"`
+	Predicate     BenchmarkPredicate `json:"predicate"`
+}
+
+// Subject names an artifact a Statement is about by its digests.
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// BenchmarkPredicate claims that the commit in the subject, built on the
+// machine, measured as the result.
+type BenchmarkPredicate struct {
+	Builder Builder         `json:"builder"`
+	Git     *GitInfo        `json:"git"`
+	Machine *SystemInfo     `json:"machine"`
+	Result  json.RawMessage `json:"result"`
+}
+
+// Builder identifies what produced an attestation, in the style of SLSA
+// provenance.
+type Builder struct {
+	ID      string `json:"id"`      // hostname of the machine
+	Version string `json:"version"` // of ztime
+}
+
+// attestResult wraps v in a statement about the git commit it measured,
+// named repo, and signs it in a DSSE envelope.
+func attestResult(v any, key ed25519.PrivateKey, repo string, gitInfo *GitInfo, sys *SystemInfo) ([]byte, error) {
+	result, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("encoding result: %w", err)
+	}
+
+	statement := Statement{
+		Type:          inTotoStatementType,
+		Subject:       []Subject{{Name: repo, Digest: map[string]string{"gitCommit": gitInfo.Commit}}},
+		PredicateType: benchmarkPredicateType,
+		Predicate: BenchmarkPredicate{
+			Builder: Builder{ID: sys.Hostname, Version: serverVersion},
+			Git:     gitInfo,
+			Machine: sys,
+			Result:  result,
+		},
+	}
+
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		return nil, fmt.Errorf("encoding statement: %w", err)
+	}
+
+	envelope := Envelope{
+		PayloadType: inTotoPayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures: []EnvelopeSignature{{
+			KeyID: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)), //nolint:forcetypeassert // Always an ed25519.PublicKey.
+			Sig:   base64.StdEncoding.EncodeToString(ed25519.Sign(key, preAuthEncoding(inTotoPayloadType, payload))),
+		}},
+	}
+
+	data, err := json.MarshalIndent(envelope, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encoding attestation: %w", err)
+	}
+
+	return data, nil
+}
+
+// verifyAttestation checks the signatures of a DSSE envelope and returns
+// the key that signed it and its statement. If want is non-nil, one of the
+// signatures must be by that key.
+func verifyAttestation(data []byte, want ed25519.PublicKey) (ed25519.PublicKey, Statement, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, Statement{}, fmt.Errorf("parsing attestation: %w", err)
+	}
+
+	if envelope.PayloadType != inTotoPayloadType {
+		return nil, Statement{}, fmt.Errorf("%w: payload type %q", errNotAttestation, envelope.PayloadType)
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		return nil, Statement{}, fmt.Errorf("%w: payload", errMalformedField)
+	}
+
+	signed := preAuthEncoding(envelope.PayloadType, payload)
+
+	var signer ed25519.PublicKey
+
+	for _, s := range envelope.Signatures {
+		pub, err := base64.StdEncoding.DecodeString(s.KeyID)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return nil, Statement{}, fmt.Errorf("%w: keyid", errMalformedField)
+		}
+
+		if want != nil && !want.Equal(ed25519.PublicKey(pub)) {
+			continue
+		}
+
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err != nil {
+			return nil, Statement{}, fmt.Errorf("%w: sig", errMalformedField)
+		}
+
+		if !ed25519.Verify(pub, signed, sig) {
+			return nil, Statement{}, errBadSignature
+		}
+
+		signer = pub
+
+		break
+	}
+
+	switch {
+	case signer == nil && want != nil:
+		return nil, Statement{}, errKeyMismatch
+	case signer == nil:
+		return nil, Statement{}, fmt.Errorf("%w: no signatures", errMalformedField)
+	}
+
+	var statement Statement
+	if err := json.Unmarshal(payload, &statement); err != nil {
+		return nil, Statement{}, fmt.Errorf("parsing statement: %w", err)
+	}
+
+	if statement.Type != inTotoStatementType || statement.PredicateType != benchmarkPredicateType || len(statement.Subject) == 0 {
+		return nil, Statement{}, fmt.Errorf("%w: statement %q with predicate %q", errNotAttestation, statement.Type, statement.PredicateType)
+	}
+
+	return signer, statement, nil
+}
+
+// attestedResult returns the result in an attestation without checking its
+// signature.
+func attestedResult(data []byte) (json.RawMessage, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("parsing attestation: %w", err)
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: payload", errMalformedField)
+	}
+
+	var statement Statement
+	if err := json.Unmarshal(payload, &statement); err != nil {
+		return nil, fmt.Errorf("parsing statement: %w", err)
+	}
+
+	return statement.Predicate.Result, nil
+}
+
+// preAuthEncoding is the DSSE encoding of a payload and its type that is
+// signed, so that neither can be swapped without breaking the signature.
+func preAuthEncoding(payloadType string, payload []byte) []byte {
+	var b bytes.Buffer
+
+	b.WriteString("DSSEv1 " + strconv.Itoa(len(payloadType)) + " " + payloadType + " " + strconv.Itoa(len(payload)) + " ")
+	b.Write(payload)
+
+	return b.Bytes()
+}
+
+// gitRepoName names the repository containing dir for the subject of an
+// attestation: its origin URL, or else the name of its top-level directory.
+func gitRepoName(dir string) string {
+	if url, err := git(dir, "config", "--get", "remote.origin.url"); err == nil && url != "" {
+		return url
+	}
+
+	if top, err := git(dir, "rev-parse", "--show-toplevel"); err == nil {
+		return filepath.Base(top)
+	}
+
+	return filepath.Base(dir)
+}
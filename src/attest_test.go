@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAttestAndVerify(t *testing.T) {
+	t.Parallel()
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	gitInfo := &GitInfo{Commit: "0123abcd", Branch: "main"}
+	data, err := attestResult(Metrics{Command: "make"}, priv, "github.com/x/y", gitInfo, &SystemInfo{Hostname: "ci-1"})
+	if err != nil {
+		t.Fatalf("attestResult() error = %v", err)
+	}
+
+	signer, statement, err := verifyAttestation(data, pub)
+	if err != nil {
+		t.Fatalf("verifyAttestation() error = %v", err)
+	}
+
+	if !signer.Equal(pub) {
+		t.Errorf("verifyAttestation() signer = %x, want %x", signer, pub)
+	}
+
+	subject := statement.Subject[0]
+	if subject.Name != "github.com/x/y" || subject.Digest["gitCommit"] != "0123abcd" || statement.Predicate.Builder.ID != "ci-1" {
+		t.Errorf("statement = %+v", statement)
+	}
+
+	if _, _, err := verifyAttestation(data, otherPub); !errors.Is(err, errKeyMismatch) {
+		t.Errorf("verifyAttestation() with other key error = %v, want %v", err, errKeyMismatch)
+	}
+
+	// Editing the statement breaks the signature over it.
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		t.Fatal(err)
+	}
+
+	payload, _ := base64.StdEncoding.DecodeString(envelope.Payload)
+	envelope.Payload = base64.StdEncoding.EncodeToString([]byte(strings.Replace(string(payload), `"make"`, `"true"`, 1)))
+
+	tampered, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := verifyAttestation(tampered, nil); !errors.Is(err, errBadSignature) {
+		t.Errorf("verifyAttestation() on tampered payload error = %v, want %v", err, errBadSignature)
+	}
+
+	// ztime merge reads the result out of an attestation.
+	path := filepath.Join(t.TempDir(), "attested.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if runs, err := readResultRuns(path); err != nil || len(runs) != 1 || runs[0].host != "attested" {
+		t.Errorf("readResultRuns() = %+v, %v", runs, err)
+	}
+}
+
+func TestPreAuthEncoding(t *testing.T) {
+	t.Parallel()
+
+	// The example in the DSSE protocol specification.
+	got := string(preAuthEncoding("http://example.com/HelloWorld", []byte("hello world")))
+	expected := "DSSEv1 29 http://example.com/HelloWorld 11 hello world"
+
+	if got != expected {
+		t.Errorf("preAuthEncoding() = %q, want %q", got, expected)
+	}
+}
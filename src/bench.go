@@ -0,0 +1,393 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// BenchResult summarizes a benchmark: the command run a fixed number of
+// times (--runs), as often as fits in a time window (--duration), or until
+// its elapsed times are stable (--min-runs/--max-runs).
+type BenchResult struct {
+	SchemaVersion int `json:"schema_version,omitempty" unit:"none"` // set on top-level results
+
+	Command         string        `json:"command"`
+	Runs            int           `json:"runs" unit:"count"`
+	WallTime        time.Duration `json:"wall_time"` // wall-clock time from the first run to the last, including hooks, cooldown, and repeated runs
+	RunsPerMinute   float64       `json:"runs_per_minute" unit:"runs/minute"`
+	StopReason      string        `json:"stop_reason"`                    // runs, duration, stable, max-runs, failed, interrupted, or cancelled
+	Outliers        []int         `json:"outliers,omitempty" unit:"none"` // run numbers, counting from 1
+	OutliersDropped bool          `json:"outliers_dropped,omitempty"`
+	Invalidated     []Metrics     `json:"invalidated,omitempty"` // runs discarded and repeated after a suspend or clock step
+	Elapsed         DurationStats `json:"elapsed"`
+	UserTime        DurationStats `json:"user_time"`
+	SystemTime      DurationStats `json:"system_time"`
+	Results         []Metrics     `json:"results"`
+
+	Annotations
+}
+
+// Bounds for --min-runs/--max-runs when only one of them is given.
+const (
+	defaultMinRuns = 3
+	defaultMaxRuns = 100
+)
+
+func (c *runCmd) benchmarking() bool {
+	return c.Runs > 1 || c.Duration > 0 || c.adaptive() || c.Compare != ""
+}
+
+// adaptive reports whether the benchmark stops once elapsed times are stable.
+// A comparison without a run count or window is adaptive too.
+func (c *runCmd) adaptive() bool {
+	return c.MinRuns > 0 || c.MaxRuns > 0 || (c.Compare != "" && c.Runs == 0 && c.Duration == 0)
+}
+
+// bench runs the benchmark or comparison, reports it, and returns the error
+// of the run that stopped it early, if any.
+func (c *runCmd) bench() error {
+	if c.Compare != "" {
+		result, err := c.runComparison()
+		c.export("compare", result)
+
+		if !c.Quiet {
+			c.reportComparison(result)
+
+			for _, r := range result.Benchmarks {
+				c.printBenchNotes(r, err)
+			}
+		}
+
+		for _, r := range result.Benchmarks {
+			if err == nil {
+				err = c.checkThresholds(r.Results...)
+			}
+		}
+
+		return err
+	}
+
+	results, err := c.runBenches(benchState{name: strings.Join(c.Command, " "), command: c.Command})
+	result := results[0]
+	c.export("bench", result)
+
+	if !c.Quiet {
+		c.reportBench(result)
+		c.printBenchNotes(result, err)
+	}
+
+	if err == nil {
+		err = c.checkThresholds(result.Results...)
+	}
+
+	return err
+}
+
+// printBenchNotes prints warnings about a benchmark after its report.
+func (c *runCmd) printBenchNotes(r BenchResult, err error) {
+	if !c.structured() {
+		var orphans []Orphan
+		for _, m := range r.Results {
+			orphans = append(orphans, m.Orphans...)
+		}
+
+		for _, note := range []string{invalidatedNote(r), outlierNote(r), orphanNote(orphans), diagnosticsNote(c.DiagnoseOnSlow, r.Results...)} {
+			if note != "" {
+				fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+			}
+		}
+	}
+
+	if err != nil && r.StopReason == "failed" {
+		fmt.Fprintf(os.Stderr, "ztime: benchmark of %s stopped after run %d failed\n", r.Command, r.Runs)
+	}
+}
+
+// benchState tracks one command's benchmark while it is in progress.
+type benchState struct {
+	name        string
+	command     []string
+	spent       time.Duration // elapsed time of this command's runs, without hooks
+	wall        time.Duration // wall-clock time of this command's runs, with hooks and ztime's own work
+	results     []Metrics
+	invalidated []Metrics
+	reason      string // why the benchmark ended; "" while it is running
+}
+
+// runBenches benchmarks each command in turn, or alternates between them
+// run by run with --interleave. Every command gets its own run count and
+// --duration window. The first run that fails or is interrupted ends all
+// benchmarks, and its error is returned with the results gathered so far.
+func (c *runCmd) runBenches(states ...benchState) ([]BenchResult, error) {
+	var err error
+
+	for turn := 0; err == nil; turn++ {
+		b := c.nextBench(states, turn)
+		if b == nil {
+			break
+		}
+
+		err = c.step(b)
+		if b.reason == "failed" || b.reason == "interrupted" {
+			break
+		}
+	}
+
+	results := make([]BenchResult, 0, len(states))
+
+	for _, b := range states {
+		r := newBenchResult(b.results, b.wall, c.DropOutliers)
+		r.Command = b.name
+		r.StopReason = cmp.Or(b.reason, "cancelled")
+		r.Invalidated = b.invalidated
+		r.Annotations = c.annotations()
+		results = append(results, r)
+	}
+
+	return results, err
+}
+
+// nextBench picks the benchmark to advance on the given turn: the first
+// unfinished one, or with --interleave each unfinished one in rotation. It
+// returns nil once all are finished.
+func (c *runCmd) nextBench(states []benchState, turn int) *benchState {
+	var running []*benchState
+
+	for i := range states {
+		if states[i].reason == "" {
+			running = append(running, &states[i])
+		}
+	}
+
+	switch {
+	case len(running) == 0:
+		return nil
+	case c.Interleave:
+		return running[turn%len(running)]
+	default:
+		return running[0]
+	}
+}
+
+// step runs the command once more unless its benchmark is over. Runs cut
+// short by a suspend or clock step are set aside and repeated, up to
+// maxRepeats times; after that they are kept, so that a clock that keeps
+// stepping cannot hold the benchmark up forever.
+func (c *runCmd) step(b *benchState) error {
+	if b.reason != "" {
+		return nil
+	}
+
+	if b.reason = c.stopReason(b.results, b.spent); b.reason != "" {
+		return nil
+	}
+
+	started := time.Now()
+	m, err := c.measure(b.command, c.runOptions(runOptions{iteration: len(b.results) + 1, totalRuns: c.Runs}))
+	b.wall += time.Since(started)
+	b.spent += m.ElapsedTime
+	m.Command = b.name
+
+	if err == nil && m.ClockJump != 0 && len(b.invalidated) < c.maxRepeats() {
+		b.invalidated = append(b.invalidated, m)
+
+		return nil
+	}
+
+	b.results = append(b.results, m)
+
+	switch {
+	case err != nil:
+		b.reason = "failed"
+	case interrupted(m.SignalLog):
+		b.reason = "interrupted"
+	}
+
+	return err
+}
+
+// maxRepeats is how many runs of a benchmark may be set aside after a
+// suspend or clock step.
+func (c *runCmd) maxRepeats() int {
+	return max(3, c.Runs)
+}
+
+// stopReason returns why the benchmark should end after the given results,
+// taking spent time in total, or "" to start another run. At least one run
+// always happens, even when the window is shorter than a single run.
+func (c *runCmd) stopReason(results []Metrics, spent time.Duration) string {
+	n := len(results)
+
+	switch {
+	case n == 0:
+		return ""
+	case c.Runs > 0 && n >= c.Runs:
+		return "runs"
+	case c.Duration > 0 && spent >= c.Duration:
+		return "duration"
+	case !c.adaptive():
+		return ""
+	case n >= max(cmp.Or(c.MaxRuns, defaultMaxRuns), c.MinRuns):
+		return "max-runs"
+	case n >= cmp.Or(c.MinRuns, defaultMinRuns) && elapsedStats(results).CV <= c.TargetCV:
+		return "stable"
+	default:
+		return ""
+	}
+}
+
+func elapsedStats(results []Metrics) DurationStats {
+	return summarize(elapsedTimes(results))
+}
+
+func elapsedTimes(results []Metrics) []time.Duration {
+	elapsed := make([]time.Duration, 0, len(results))
+	for _, m := range results {
+		elapsed = append(elapsed, m.ElapsedTime)
+	}
+
+	return elapsed
+}
+
+// newBenchResult summarizes results, which took wall to run. Runs whose
+// elapsed time is an outlier are listed in the result and, with
+// dropOutliers, left out of the statistics; Results always holds every run.
+func newBenchResult(results []Metrics, wall time.Duration, dropOutliers bool) BenchResult {
+	r := BenchResult{
+		Runs:     len(results),
+		WallTime: wall,
+		Results:  results,
+	}
+
+	kept := results
+
+	if indices := outliers(elapsedTimes(results)); len(indices) > 0 {
+		for _, i := range indices {
+			r.Outliers = append(r.Outliers, i+1)
+		}
+
+		if dropOutliers {
+			kept = make([]Metrics, 0, len(results)-len(indices))
+			for i, m := range results {
+				if !slices.Contains(indices, i) {
+					kept = append(kept, m)
+				}
+			}
+
+			r.OutliersDropped = true
+		}
+	}
+
+	user := make([]time.Duration, 0, len(kept))
+	system := make([]time.Duration, 0, len(kept))
+
+	for _, m := range kept {
+		user = append(user, m.UserTime)
+		system = append(system, m.SystemTime)
+	}
+
+	r.Elapsed = elapsedStats(kept)
+	r.UserTime = summarize(user)
+	r.SystemTime = summarize(system)
+
+	if len(results) > 0 {
+		r.Command = results[0].Command
+	}
+
+	if wall > 0 {
+		r.RunsPerMinute = float64(r.Runs) / wall.Minutes()
+	}
+
+	return r
+}
+
+// reportBench prints a benchmark result in the selected output format.
+// Templates receive the BenchResult instead of a single run's Metrics.
+func (c *runCmd) reportBench(r BenchResult) {
+	switch {
+	case c.JSONL:
+		c.events.emit(event{Type: eventBenchFinished, Result: r})
+	case c.JSON:
+		c.printJSON(r)
+	case c.tmpl != nil:
+		printTemplate(c.tmpl, r)
+	default:
+		printBench(r, formatOptions{micro: c.PrecisionNS})
+
+		if c.Plot {
+			printPlot(r)
+		}
+	}
+}
+
+func printBench(r BenchResult, opts formatOptions) {
+	bold := lipgloss.NewStyle().Bold(true)
+
+	secs := func(d time.Duration) string { return opts.seconds(d, 3) }
+
+	fmt.Fprintf(os.Stderr, "%s  %s runs in %s (%s)\n",
+		palette.command.Render(r.Command),
+		bold.Render(fmt.Sprint(r.Runs)),
+		secs(r.WallTime),
+		palette.good.Render(fmt.Sprintf("%.1f runs/min", r.RunsPerMinute)),
+	)
+	fmt.Fprintf(os.Stderr, "  elapsed  mean %s ± %s (cv %.1f%%)  min %s  max %s\n",
+		palette.total.Render(secs(r.Elapsed.Mean)), secs(r.Elapsed.StdDev), r.Elapsed.CV*100, secs(r.Elapsed.Min), secs(r.Elapsed.Max))
+	fmt.Fprintf(os.Stderr, "           p50 %s  p90 %s  p95 %s  p99 %s\n",
+		secs(r.Elapsed.P50), secs(r.Elapsed.P90), secs(r.Elapsed.P95), secs(r.Elapsed.P99))
+	fmt.Fprintf(os.Stderr, "  cpu      %s user %s system (mean)\n",
+		palette.user.Render(opts.seconds(r.UserTime.Mean, 2)),
+		palette.system.Render(opts.seconds(r.SystemTime.Mean, 2)),
+	)
+}
+
+// invalidatedNote reports runs that were repeated because the system
+// suspended or the clock stepped, or returns "" if there were none.
+func invalidatedNote(r BenchResult) string {
+	kept := 0
+
+	for _, m := range r.Results {
+		if m.ClockJump != 0 {
+			kept++
+		}
+	}
+
+	switch {
+	case kept > 0:
+		return fmt.Sprintf("repeated %d run(s) interrupted by a system suspend or clock change, then kept %d more; their elapsed times include the jump", len(r.Invalidated), kept)
+	case len(r.Invalidated) > 0:
+		return fmt.Sprintf("repeated %d run(s) interrupted by a system suspend or clock change", len(r.Invalidated))
+	default:
+		return ""
+	}
+}
+
+// outlierNote warns about outlying runs, or returns "" if there were none.
+func outlierNote(r BenchResult) string {
+	if len(r.Outliers) == 0 {
+		return ""
+	}
+
+	note := fmt.Sprintf("%d of %d runs were outliers (%s); ", len(r.Outliers), r.Runs, joinInts(r.Outliers))
+	if r.OutliersDropped {
+		return note + "they were left out of the statistics"
+	}
+
+	return note + "results may be skewed by system interference (see --drop-outliers)"
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = "#" + strconv.Itoa(v)
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Runtimes supported by --runtime-stats.
+const (
+	runtimeGo     = "go"
+	runtimeJVM    = "jvm"
+	runtimePython = "python"
+)
+
+// RuntimeStats holds what the runtime bridges learned from the command's
+// language runtime.
+type RuntimeStats struct {
+	GCCycles   int           `json:"gc_cycles" unit:"count"`
+	GCTime     time.Duration `json:"gc_time"`                        // Go: wall-clock time of GC cycles; JVM: pause time
+	Imports    int           `json:"imports,omitempty" unit:"count"` // Python modules imported
+	ImportTime time.Duration `json:"import_time,omitempty"`          // Python: time spent in module bodies
+}
+
+var (
+	// gc 4 @0.019s 3%: 0.016+1.2+0.021 ms clock, ...
+	goGCTrace = regexp.MustCompile(`^gc \d+ @[\d.]+s \d+%: ([\d.]+)\+([\d.]+)\+([\d.]+) ms clock`)
+	// import time:       412 |        964 |   encodings
+	pythonImportTime = regexp.MustCompile(`^import time:\s+(\d+) \|`)
+	// [0.031s][info][gc] GC(0) Pause Young (Normal) (G1 Evacuation Pause) 23M->4M(256M) 3.112ms
+	jvmGCPause = regexp.MustCompile(`GC\(\d+\) Pause .* ([\d.]+)ms$`)
+)
+
+// runtimeBridge turns on the tracing of the selected runtimes through their
+// environment variables and collects the results. Go and Python trace to
+// stderr; those lines are taken out of the command's stderr. The JVM logs
+// to a file.
+type runtimeBridge struct {
+	tap    *lineTap
+	jvmLog string
+
+	mu    sync.Mutex
+	stats RuntimeStats
+}
+
+func attachRuntimeBridge(cmd *exec.Cmd, runtimes []string) (*runtimeBridge, error) {
+	b := &runtimeBridge{}
+
+	for _, rt := range runtimes {
+		switch rt {
+		case runtimeGo:
+			cmd.Env = append(cmd.Env, "GODEBUG="+joinEnv(os.Getenv("GODEBUG"), ",", "gctrace=1"))
+		case runtimePython:
+			cmd.Env = append(cmd.Env, "PYTHONPROFILEIMPORTTIME=1")
+		case runtimeJVM:
+			log, err := os.CreateTemp("", "ztime-gc-*.log")
+			if err != nil {
+				return nil, fmt.Errorf("creating JVM GC log: %w", err)
+			}
+
+			_ = log.Close()
+			b.jvmLog = log.Name()
+			cmd.Env = append(cmd.Env, "JAVA_TOOL_OPTIONS="+joinEnv(os.Getenv("JAVA_TOOL_OPTIONS"), " ", "-Xlog:gc:file="+b.jvmLog))
+		}
+	}
+
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+
+	b.tap = &lineTap{dst: cmd.Stderr, consume: b.stderrLine}
+	cmd.Stderr = b.tap
+
+	return b, nil
+}
+
+// joinEnv appends value to an existing environment variable value.
+func joinEnv(existing, sep, value string) string {
+	if existing == "" {
+		return value
+	}
+
+	return existing + sep + value
+}
+
+// stderrLine records a Go GC trace or Python import time line, reporting
+// whether it was one.
+func (b *runtimeBridge) stderrLine(line string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if m := goGCTrace.FindStringSubmatch(line); m != nil {
+		b.stats.GCCycles++
+
+		for _, phase := range m[1:] {
+			b.stats.GCTime += parseMillis(phase)
+		}
+
+		return true
+	}
+
+	if m := pythonImportTime.FindStringSubmatch(line); m != nil {
+		us, _ := strconv.ParseInt(m[1], 10, 64)
+		b.stats.Imports++
+		b.stats.ImportTime += time.Duration(us) * time.Microsecond
+
+		return true
+	}
+
+	return line == "import time: self [us] | cumulative | imported package"
+}
+
+func (b *runtimeBridge) finish(m *Metrics, _ time.Time) {
+	b.tap.flush()
+
+	if b.jvmLog != "" {
+		b.readJVMLog()
+		_ = os.Remove(b.jvmLog)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := b.stats
+	m.Runtime = &stats
+}
+
+func (b *runtimeBridge) readJVMLog() {
+	f, err := os.Open(b.jvmLog)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if m := jvmGCPause.FindStringSubmatch(scanner.Text()); m != nil {
+			b.stats.GCCycles++
+			b.stats.GCTime += parseMillis(m[1])
+		}
+	}
+}
+
+func parseMillis(s string) time.Duration {
+	ms, _ := strconv.ParseFloat(s, 64)
+
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// lineTap passes output through to dst line by line, except for lines that
+// consume claims.
+type lineTap struct {
+	dst     io.Writer
+	consume func(line string) bool
+
+	mu      sync.Mutex
+	partial []byte
+}
+
+func (t *lineTap) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.partial = append(t.partial, p...)
+
+	for {
+		i := bytes.IndexByte(t.partial, '\n')
+		if i < 0 {
+			break
+		}
+
+		line := t.partial[:i+1]
+		t.partial = t.partial[i+1:]
+
+		if !t.consume(string(bytes.TrimRight(line, "\r\n"))) {
+			if _, err := t.dst.Write(line); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return len(p), nil
+}
+
+// flush writes out a final line that did not end in a newline.
+func (t *lineTap) flush() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.partial) > 0 && !t.consume(string(t.partial)) {
+		_, _ = t.dst.Write(t.partial)
+	}
+
+	t.partial = nil
+}
+
+// runtimeNote summarizes the runtime statistics, or returns "" if none
+// were collected.
+func runtimeNote(rs *RuntimeStats) string {
+	if rs == nil {
+		return ""
+	}
+
+	var parts []string
+
+	if rs.GCCycles > 0 {
+		parts = append(parts, fmt.Sprintf("%d GC cycles, %s in GC", rs.GCCycles, humanDuration(rs.GCTime)))
+	}
+
+	if rs.Imports > 0 {
+		parts = append(parts, fmt.Sprintf("%d imports, %s importing", rs.Imports, humanDuration(rs.ImportTime)))
+	}
+
+	if len(parts) == 0 {
+		return "the runtime reported no GC cycles or imports"
+	}
+
+	return strings.Join(parts, "; ")
+}
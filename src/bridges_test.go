@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestRuntimeBridgeStderr(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	b := &runtimeBridge{}
+	b.tap = &lineTap{dst: &out, consume: b.stderrLine}
+
+	input := "warming up\n" +
+		"gc 1 @0.004s 2%: 0.010+1.5+0.002 ms clock, 0.08+0.1/0.4/0.9+0.01 ms cpu, 4->4->0 MB, 4 MB goal, 8 P\n" +
+		"import time: self [us] | cumulative | imported package\n" +
+		"import time:       412 |        964 |   encodings\n" +
+		"gc 2 @0.010s 3%: 0.5+2.0+0.5 ms clock, 1+0/1/2+1 ms cpu, 4->5->1 MB, 5 MB goal, 8 P\n" +
+		"import time:      1588 |       1588 | json\n" +
+		"done"
+
+	// Split writes in the middle of lines, as pipes do.
+	for _, chunk := range []string{input[:30], input[30:150], input[150:]} {
+		if _, err := b.tap.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var m Metrics
+
+	b.finish(&m, time.Time{})
+
+	if got := out.String(); got != "warming up\ndone" {
+		t.Errorf("passed through %q, want the non-trace lines", got)
+	}
+
+	want := RuntimeStats{GCCycles: 2, GCTime: 4512 * time.Microsecond, Imports: 2, ImportTime: 2 * time.Millisecond}
+	if m.Runtime == nil || *m.Runtime != want {
+		t.Errorf("Runtime = %+v, want %+v", m.Runtime, want)
+	}
+}
+
+func TestJVMGCPause(t *testing.T) {
+	t.Parallel()
+
+	line := "[0.031s][info][gc] GC(0) Pause Young (Normal) (G1 Evacuation Pause) 23M->4M(256M) 3.112ms"
+
+	m := jvmGCPause.FindStringSubmatch(line)
+	if m == nil || parseMillis(m[1]) != 3112*time.Microsecond {
+		t.Errorf("jvmGCPause on %q = %v", line, m)
+	}
+
+	if jvmGCPause.MatchString("[0.002s][info][gc] Using G1") {
+		t.Error("jvmGCPause matched a non-pause line")
+	}
+}
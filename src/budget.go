@@ -0,0 +1,219 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+	"gopkg.in/yaml.v3"
+)
+
+var errBudget = errors.New("invalid budget")
+
+// budgetEntry is one metric's limits in a --budget file. A run that
+// exceeds the warn limit is reported; one that exceeds the fail limit also
+// fails the budget. Either limit may be unset.
+type budgetEntry struct {
+	metric string
+	warn   *threshold
+	fail   *threshold
+}
+
+// loadBudget reads a budget file: a TOML file with a table per metric,
+// or a YAML file with a mapping per metric, checked in file order, whose
+// warn and fail keys are limits in the metric's unit, as for --fail-if:
+//
+//	[elapsed]
+//	warn = "20s"
+//	fail = "30s"
+//
+//	[max_rss]
+//	fail = "2G"
+//
+// Files ending in .yaml or .yml are read as YAML.
+func loadBudget(path string) ([]budgetEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	parse := parseConfig
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		parse = parseYAMLTables
+	}
+
+	tables, err := parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", errBudget, path, err)
+	}
+
+	if len(tables[0].values) > 0 {
+		return nil, fmt.Errorf("%w: %s: keys must be in a table per metric", errBudget, path)
+	}
+
+	budget := make([]budgetEntry, 0, len(tables)-1)
+
+	for _, t := range tables[1:] {
+		e := budgetEntry{metric: t.name}
+
+		for key, value := range t.values {
+			limit, err := budgetLimit(t.name, value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: [%s] %s: %w", errBudget, t.name, key, err)
+			}
+
+			switch key {
+			case "warn":
+				e.warn = limit
+			case "fail":
+				e.fail = limit
+			default:
+				return nil, fmt.Errorf("%w: [%s]: unknown key %s (want warn or fail)", errBudget, t.name, key)
+			}
+		}
+
+		budget = append(budget, e)
+	}
+
+	if len(budget) == 0 {
+		return nil, fmt.Errorf("%w: %s sets no limits", errBudget, path)
+	}
+
+	return budget, nil
+}
+
+// parseYAMLTables reads a YAML mapping as config tables: the keys whose
+// values are mappings are tables, in file order, and the others belong to
+// the top-level table "".
+func parseYAMLTables(r io.Reader) ([]configTable, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	tables := []configTable{{values: make(map[string]any)}}
+	if len(doc.Content) == 0 {
+		return tables, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%w: line %d: expected a mapping", errConfigSyntax, root.Line)
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, node := root.Content[i], root.Content[i+1]
+
+		var value any
+		if err := node.Decode(&value); err != nil {
+			return nil, err
+		}
+
+		if values, ok := value.(map[string]any); ok && node.Kind == yaml.MappingNode {
+			tables = append(tables, configTable{name: key.Value, values: values})
+		} else {
+			tables[0].values[key.Value] = value
+		}
+	}
+
+	return tables, nil
+}
+
+// budgetLimit parses a budget value as the threshold "metric > value".
+func budgetLimit(metric string, value any) (*threshold, error) {
+	var t threshold
+	if err := t.UnmarshalText(fmt.Appendf(nil, "%s > %v", metric, value)); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// checkBudget compares the mean of runs with the --budget limits, prints a
+// pass/warn/fail table, and returns the fail limits that were exceeded.
+// Warnings alone do not fail the budget.
+func (c *runCmd) checkBudget(runs []Metrics) []string {
+	if len(c.budget) == 0 {
+		return nil
+	}
+
+	var (
+		failed []string
+		rows   [][]string
+	)
+
+	for _, e := range c.budget {
+		status := "pass"
+		limits := [2]string{"-", "-"}
+
+		var value float64
+
+		for i, t := range []*threshold{e.warn, e.fail} {
+			if t == nil {
+				continue
+			}
+
+			var exceeded bool
+
+			value, exceeded = t.exceeded(runs)
+			limits[i] = formatMetric(e.metric, t.limit)
+
+			switch {
+			case exceeded && t == e.fail:
+				status = "fail"
+
+				failed = append(failed, fmt.Sprintf("%s (was %s)", t.text, formatMetric(e.metric, value)))
+			case exceeded:
+				status = "warn"
+			}
+		}
+
+		rows = append(rows, []string{e.metric, formatMetric(e.metric, value), limits[0], limits[1], status})
+	}
+
+	if !c.Quiet && !c.structured() {
+		printBudget(runs[0].Command, rows)
+	}
+
+	return failed
+}
+
+// printBudget prints the budget table of a command: each metric with its
+// value, warn and fail limits, and status.
+func printBudget(command string, rows [][]string) {
+	bold := lipgloss.NewStyle().Bold(true)
+	faint := lipgloss.NewStyle().Faint(true)
+	status := map[string]lipgloss.Style{
+		"pass": palette.good,
+		"warn": palette.warn,
+		"fail": palette.bad.Bold(true),
+	}
+
+	rows = append([][]string{{"metric", "value", "warn", "fail", "status"}}, rows...)
+
+	var widths [4]int
+
+	for _, row := range rows {
+		for i := range widths {
+			widths[i] = max(widths[i], utf8.RuneCountInString(row[i]))
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "%s  %s\n", bold.Render("budget"), faint.Render(command))
+
+	for i, row := range rows {
+		line := fmt.Sprintf("  %-*s  %*s  %*s  %*s  ", widths[0], row[0], widths[1], row[1], widths[2], row[2], widths[3], row[3])
+
+		if i == 0 {
+			fmt.Fprintln(os.Stderr, faint.Render(line+row[4]))
+		} else {
+			fmt.Fprintln(os.Stderr, line+status[row[4]].Render(row[4]))
+		}
+	}
+}
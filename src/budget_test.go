@@ -0,0 +1,100 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLoadBudget(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		file    string
+		input   string
+		metrics []string
+		wantErr error
+	}{
+		{name: "warn and fail", input: "[elapsed]\nwarn = \"20s\"\nfail = \"30s\"\n\n[max_rss]\nfail = \"2G\"\n", metrics: []string{"elapsed", "max_rss"}},
+		{name: "count", input: "[page_faults]\nwarn = 10\n", metrics: []string{"page_faults"}},
+		{name: "empty", input: "# nothing\n", wantErr: errBudget},
+		{name: "top-level key", input: "fail = \"30s\"\n", wantErr: errBudget},
+		{name: "unknown key", input: "[elapsed]\nlimit = \"30s\"\n", wantErr: errBudget},
+		{name: "unknown metric", input: "[latency]\nfail = \"30s\"\n", wantErr: errInvalidThreshold},
+		{name: "missing unit", input: "[elapsed]\nfail = 30\n", wantErr: errInvalidThreshold},
+		{name: "yaml", file: "budgets.yaml", input: "elapsed:\n  warn: 20s\n  fail: 30s\nmax_rss:\n  fail: 2G\n", metrics: []string{"elapsed", "max_rss"}},
+		{name: "yaml count", file: "budgets.yml", input: "page_faults: {warn: 10}\n", metrics: []string{"page_faults"}},
+		{name: "yaml empty", file: "budgets.yaml", input: "# nothing\n", wantErr: errBudget},
+		{name: "yaml top-level key", file: "budgets.yaml", input: "fail: 30s\n", wantErr: errBudget},
+		{name: "yaml not a mapping", file: "budgets.yaml", input: "- elapsed\n", wantErr: errBudget},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), cmp.Or(tt.file, "budget.toml"))
+			if err := os.WriteFile(path, []byte(tt.input), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			budget, err := loadBudget(path)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("loadBudget() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var metrics []string
+			for _, e := range budget {
+				metrics = append(metrics, e.metric)
+			}
+
+			if !slices.Equal(metrics, tt.metrics) {
+				t.Errorf("loadBudget() metrics = %v, want %v", metrics, tt.metrics)
+			}
+		})
+	}
+}
+
+func TestCheckBudget(t *testing.T) {
+	t.Parallel()
+
+	limit := func(condition string) *threshold {
+		var th threshold
+		if err := th.UnmarshalText([]byte(condition)); err != nil {
+			t.Fatal(err)
+		}
+
+		return &th
+	}
+
+	c := runCmd{Quiet: true, budget: []budgetEntry{
+		{metric: "elapsed", warn: limit("elapsed > 1s"), fail: limit("elapsed > 5s")},
+		{metric: "max_rss", fail: limit("max_rss > 1M")},
+	}}
+
+	tests := []struct {
+		name     string
+		run      Metrics
+		exceeded bool
+	}{
+		{name: "Pass", run: Metrics{ElapsedTime: 500 * time.Millisecond, MaxRSS: 512}},
+		{name: "Warn Only", run: Metrics{ElapsedTime: 2 * time.Second, MaxRSS: 512}},
+		{name: "Fail", run: Metrics{ElapsedTime: 6 * time.Second, MaxRSS: 512}, exceeded: true},
+		{name: "Fail Without Warn Limit", run: Metrics{ElapsedTime: time.Second, MaxRSS: 2048}, exceeded: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := c.checkThresholds(tt.run); errors.Is(err, errThresholdExceeded) != tt.exceeded {
+				t.Errorf("checkThresholds() error = %v, want exceeded %v", err, tt.exceeded)
+			}
+		})
+	}
+}
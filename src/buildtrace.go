@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errBuildTraceCommand = errors.New("--build-trace needs a ninja or make command")
+
+// makeTraceLine matches what make --trace prints before running the recipe
+// of a target, such as "Makefile:12: update target 'main.o' due to: main.c".
+var makeTraceLine = regexp.MustCompile(`: (?:update target '(.+)' due to:|target '(.+)' does not exist)`)
+
+// BuildTrace is the slowest steps of a ninja or make build, from
+// --build-trace.
+type BuildTrace struct {
+	Tool     string        `json:"tool"` // ninja or make
+	Steps    int           `json:"steps" unit:"count"`
+	StepTime time.Duration `json:"step_time"`         // sum of the steps' durations
+	Slowest  []BuildStep   `json:"slowest,omitempty"` // slowest first
+	Error    string        `json:"error,omitempty"`   // why the steps could not be read
+}
+
+// BuildStep is one target the build made.
+type BuildStep struct {
+	Target   string        `json:"target"`
+	Start    time.Duration `json:"start"` // since the build started
+	Duration time.Duration `json:"duration"`
+}
+
+// buildWatch collects the steps of a build. For ninja it reads what the
+// build appended to .ninja_log; for make it adds --trace to the command and
+// times the trace lines as they are written.
+type buildWatch struct {
+	tool string
+	top  int
+
+	// ninja
+	log    string
+	offset int64
+
+	// make
+	mu      sync.Mutex
+	targets []makeTarget
+}
+
+// makeTarget is a trace line of make and when it was written.
+type makeTarget struct {
+	name string
+	at   time.Time
+}
+
+// watchBuild prepares to trace the build cmd runs, listing the top slowest
+// steps.
+func watchBuild(cmd *exec.Cmd, top int) (*buildWatch, error) {
+	switch tool := filepath.Base(cmd.Args[0]); tool {
+	case "ninja":
+		w := &buildWatch{tool: tool, top: top, log: filepath.Join(ninjaDir(cmd), ".ninja_log")}
+		if info, err := os.Stat(w.log); err == nil {
+			w.offset = info.Size()
+		}
+
+		return w, nil
+	case "make", "gmake":
+		w := &buildWatch{tool: "make", top: top}
+		if !slices.Contains(cmd.Args[1:], "--trace") {
+			cmd.Args = append(cmd.Args, "--trace")
+		}
+
+		if cmd.Stdout == nil {
+			cmd.Stdout = os.Stdout
+		}
+
+		cmd.Stdout = &makeTraceWriter{watch: w, dst: cmd.Stdout}
+
+		return w, nil
+	default:
+		return nil, fmt.Errorf("%w, not %s", errBuildTraceCommand, tool)
+	}
+}
+
+// ninjaDir is the build directory of a ninja command: its -C argument,
+// relative to where it runs.
+func ninjaDir(cmd *exec.Cmd) string {
+	dir := ""
+
+	for i, arg := range cmd.Args[1:] {
+		switch {
+		case arg == "-C" && i+2 < len(cmd.Args):
+			dir = cmd.Args[i+2]
+		case strings.HasPrefix(arg, "-C") && len(arg) > 2:
+			dir = arg[2:]
+		}
+	}
+
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+
+	return filepath.Join(cmd.Dir, dir)
+}
+
+func (w *buildWatch) finish(m *Metrics, start time.Time) {
+	var (
+		steps []BuildStep
+		err   error
+	)
+
+	if w.tool == "ninja" {
+		steps, err = w.ninjaSteps()
+	} else {
+		steps = w.makeSteps(start, start.Add(m.ElapsedTime))
+	}
+
+	t := &BuildTrace{Tool: w.tool, Steps: len(steps)}
+	if err != nil {
+		t.Error = err.Error()
+		m.BuildTrace = t
+
+		return
+	}
+
+	for _, s := range steps {
+		t.StepTime += s.Duration
+	}
+
+	slices.SortStableFunc(steps, func(a, b BuildStep) int { return cmp.Compare(b.Duration, a.Duration) })
+	t.Slowest = steps[:min(w.top, len(steps))]
+	m.BuildTrace = t
+}
+
+// ninjaSteps reads the entries the build appended to .ninja_log.
+func (w *buildWatch) ninjaSteps() ([]BuildStep, error) {
+	f, err := os.Open(w.log)
+	if err != nil {
+		return nil, fmt.Errorf("reading ninja log: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("reading ninja log: %w", err)
+	}
+
+	// Ninja rewrites the log without the entries it no longer needs when
+	// it has too many, after which this build's cannot be told apart.
+	if info.Size() < w.offset {
+		return nil, fmt.Errorf("ninja recompacted %s during the build; run it again", w.log)
+	}
+
+	if _, err := f.Seek(w.offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("reading ninja log: %w", err)
+	}
+
+	return parseNinjaLog(f), nil
+}
+
+// parseNinjaLog reads the entries of a .ninja_log, whose lines are "START
+// END MTIME OUTPUT HASH" separated by tabs, with times in milliseconds
+// since the build started. The outputs of one command share an entry each,
+// so entries with the same times and command hash are one step.
+func parseNinjaLog(r io.Reader) []BuildStep {
+	type edge struct {
+		start, end int64
+		hash       string
+	}
+
+	var steps []BuildStep
+
+	seen := map[edge]int{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 5 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		start, err1 := strconv.ParseInt(fields[0], 10, 64)
+		end, err2 := strconv.ParseInt(fields[1], 10, 64)
+
+		if err1 != nil || err2 != nil || end < start {
+			continue
+		}
+
+		e := edge{start: start, end: end, hash: fields[4]}
+		if i, ok := seen[e]; ok {
+			steps[i].Target += " " + fields[3]
+
+			continue
+		}
+
+		seen[e] = len(steps)
+		steps = append(steps, BuildStep{
+			Target:   fields[3],
+			Start:    time.Duration(start) * time.Millisecond,
+			Duration: time.Duration(end-start) * time.Millisecond,
+		})
+	}
+
+	return steps
+}
+
+// makeSteps times each target from its trace line to the next one, or to
+// the end of the build for the last. This is each target's own time only
+// when make runs one recipe at a time.
+func (w *buildWatch) makeSteps(start, end time.Time) []BuildStep {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	steps := make([]BuildStep, 0, len(w.targets))
+
+	for i, t := range w.targets {
+		next := end
+		if i+1 < len(w.targets) {
+			next = w.targets[i+1].at
+		}
+
+		steps = append(steps, BuildStep{Target: t.name, Start: t.at.Sub(start), Duration: max(next.Sub(t.at), 0)})
+	}
+
+	return steps
+}
+
+// makeTraceWriter passes make's output through and notes when each trace
+// line was written.
+type makeTraceWriter struct {
+	watch   *buildWatch
+	dst     io.Writer
+	partial []byte
+}
+
+func (w *makeTraceWriter) Write(p []byte) (int, error) {
+	now := time.Now()
+
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+
+		if match := makeTraceLine.FindSubmatch(w.partial[:i]); match != nil {
+			name := cmp.Or(string(match[1]), string(match[2]))
+
+			w.watch.mu.Lock()
+			w.watch.targets = append(w.watch.targets, makeTarget{name: name, at: now})
+			w.watch.mu.Unlock()
+		}
+
+		w.partial = w.partial[i+1:]
+	}
+
+	return w.dst.Write(p)
+}
+
+// buildTraceNote lists the slowest build steps over several lines, or
+// returns "" without --build-trace.
+func buildTraceNote(m Metrics) string {
+	t := m.BuildTrace
+
+	switch {
+	case t == nil:
+		return ""
+	case t.Error != "":
+		return "no build trace: " + t.Error
+	}
+
+	var note strings.Builder
+
+	fmt.Fprintf(&note, "build: %d %s steps, %s of step time", t.Steps, t.Tool, humanDuration(t.StepTime))
+
+	if m.ElapsedTime > 0 && t.Tool == "ninja" {
+		fmt.Fprintf(&note, " (%.1f in parallel on average)", float64(t.StepTime)/float64(m.ElapsedTime))
+	}
+
+	for _, s := range t.Slowest {
+		fmt.Fprintf(&note, "\n  %10s  at %-8s  %s", humanDuration(s.Duration), humanDuration(s.Start), s.Target)
+	}
+
+	return note.String()
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseNinjaLog(t *testing.T) {
+	t.Parallel()
+
+	log := "# ninja log v5\n" +
+		"0\t1200\t1700000000000000000\tobj/a.o\t1f2e\n" +
+		"5\t300\t1700000000000000000\tobj/b.o\t3c4d\n" +
+		"1200\t1900\t1700000000000000000\tgen/x.h\t5e6f\n" +
+		"1200\t1900\t1700000000000000000\tgen/x.cc\t5e6f\n" +
+		"garbage\n"
+
+	got := parseNinjaLog(strings.NewReader(log))
+	want := []BuildStep{
+		{Target: "obj/a.o", Start: 0, Duration: 1200 * time.Millisecond},
+		{Target: "obj/b.o", Start: 5 * time.Millisecond, Duration: 295 * time.Millisecond},
+		{Target: "gen/x.h gen/x.cc", Start: 1200 * time.Millisecond, Duration: 700 * time.Millisecond},
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("parseNinjaLog() = %+v, want %+v", got, want)
+	}
+}
+
+func TestWatchBuildNinja(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	log := filepath.Join(dir, ".ninja_log")
+
+	if err := os.WriteFile(log, []byte("# ninja log v5\n0\t10\t0\told.o\taaaa\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.CommandContext(context.Background(), "ninja", "-C", dir)
+
+	w, err := watchBuild(cmd, 5)
+	if err != nil {
+		t.Fatalf("watchBuild() error = %v", err)
+	}
+
+	f, err := os.OpenFile(log, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = f.WriteString("0\t250\t0\tnew.o\tbbbb\n")
+	_ = f.Close()
+
+	var m Metrics
+
+	w.finish(&m, time.Now())
+
+	if m.BuildTrace == nil || m.BuildTrace.Steps != 1 || m.BuildTrace.Slowest[0].Target != "new.o" {
+		t.Errorf("BuildTrace = %+v, want only the step the build appended", m.BuildTrace)
+	}
+}
+
+func TestNinjaDir(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		args []string
+		dir  string
+		want string
+	}{
+		{[]string{"ninja"}, "", ".ninja_log"},
+		{[]string{"ninja", "-C", "out/Release", "all"}, "", "out/Release/.ninja_log"},
+		{[]string{"ninja", "-Cbuild"}, "/src", "/src/build/.ninja_log"},
+		{[]string{"ninja", "-C", "/abs"}, "/src", "/abs/.ninja_log"},
+	}
+
+	for _, tt := range tests {
+		cmd := &exec.Cmd{Args: tt.args, Dir: tt.dir}
+		if got := filepath.Join(ninjaDir(cmd), ".ninja_log"); got != tt.want {
+			t.Errorf("ninjaDir(%v in %q) = %q, want %q", tt.args, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestWatchBuildMake(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	cmd := exec.CommandContext(context.Background(), "make", "-j1")
+	cmd.Stdout = &out
+
+	w, err := watchBuild(cmd, 1)
+	if err != nil {
+		t.Fatalf("watchBuild() error = %v", err)
+	}
+
+	if want := []string{"make", "-j1", "--trace"}; !slices.Equal(cmd.Args, want) {
+		t.Errorf("Args = %v, want %v", cmd.Args, want)
+	}
+
+	start := time.Now()
+
+	_, _ = cmd.Stdout.Write([]byte("Makefile:2: target 'a' does not exist\nsleep 0.3; to"))
+	time.Sleep(20 * time.Millisecond)
+	_, _ = cmd.Stdout.Write([]byte("uch a\nMakefile:4: update target 'b' due to: a\n"))
+
+	if !strings.HasSuffix(out.String(), "touch a\nMakefile:4: update target 'b' due to: a\n") {
+		t.Errorf("output = %q, want it passed through", out.String())
+	}
+
+	m := Metrics{ElapsedTime: time.Since(start) + 100*time.Millisecond}
+	w.finish(&m, start)
+
+	got := m.BuildTrace
+	if got == nil || got.Tool != "make" || got.Steps != 2 || len(got.Slowest) != 1 || got.Slowest[0].Target != "b" {
+		t.Errorf("BuildTrace = %+v, want b slowest of 2 steps", got)
+	}
+
+	if _, err := watchBuild(exec.CommandContext(context.Background(), "cargo", "build"), 1); !errors.Is(err, errBuildTraceCommand) {
+		t.Errorf("watchBuild(cargo) error = %v, want %v", err, errBuildTraceCommand)
+	}
+}
+
+func TestBuildTraceNote(t *testing.T) {
+	t.Parallel()
+
+	if got := buildTraceNote(Metrics{}); got != "" {
+		t.Errorf("buildTraceNote() without --build-trace = %q, want empty", got)
+	}
+
+	m := Metrics{ElapsedTime: time.Second, BuildTrace: &BuildTrace{
+		Tool: "ninja", Steps: 40, StepTime: 4 * time.Second,
+		Slowest: []BuildStep{{Target: "obj/a.o", Start: 100 * time.Millisecond, Duration: 900 * time.Millisecond}},
+	}}
+
+	want := "build: 40 ninja steps, 4.00s of step time (4.0 in parallel on average)\n" +
+		"    900.00ms  at 100.00ms  obj/a.o"
+	if got := buildTraceNote(m); got != want {
+		t.Errorf("buildTraceNote() = %q, want %q", got, want)
+	}
+}
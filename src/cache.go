@@ -0,0 +1,268 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cachedRun runs the command, or with --cache reports the result of an
+// earlier successful run with the same cache key instead of running it
+// again. Only runs that exit 0 are stored.
+func (c *runCmd) cachedRun(opts runOptions) (Metrics, error) {
+	if !c.Cache {
+		return c.runAttempts(opts)
+	}
+
+	path, err := c.cachePath()
+	if err != nil {
+		return Metrics{Command: strings.Join(c.Command, " "), ExitCode: -1}, err
+	}
+
+	if m, ok := loadCached(path); ok {
+		m.Annotations = c.annotations()
+		opts.events.emit(event{Type: eventRunFinished, Iteration: 1, Result: m})
+
+		return m, nil
+	}
+
+	m, err := c.runAttempts(opts)
+	if err == nil && m.ExitCode == 0 {
+		if err := storeCached(path, m); err != nil {
+			fmt.Fprintf(os.Stderr, "ztime: caching result: %v\n", err)
+		}
+	}
+
+	return m, err
+}
+
+// cachePath returns the file that holds the cached result for this
+// invocation, under the user's cache directory.
+func (c *runCmd) cachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	inputs := c.CacheInput
+	if c.Stdin != "" {
+		inputs = append(slices.Clone(inputs), c.Stdin)
+	}
+
+	key, err := cacheKey(c.Command, cacheEnviron(os.Environ(), c.CacheEnv), inputs, c.cacheOptions())
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "ztime", "results", key+".json"), nil
+}
+
+// cacheOptions lists ztime's own options that change how the command runs,
+// and so its result. Presets and profiles only set flags, so they count
+// through the flags they set.
+func (c *runCmd) cacheOptions() []string {
+	options := []string{
+		"pty=" + strconv.FormatBool(c.PTY),
+		"stdin=" + c.Stdin,
+		"stdin-null=" + strconv.FormatBool(c.StdinNull),
+		"timeout=" + c.Timeout.String(),
+		"limit-mem=" + strconv.FormatInt(int64(c.LimitMem), 10),
+		"kill-children=" + strconv.FormatBool(c.KillChildren),
+		"prepare=" + c.Prepare,
+		"cleanup=" + c.Cleanup,
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(c.Env)) {
+		options = append(options, "env="+key+"="+c.Env[key])
+	}
+
+	// A run in a container depends on the image, the runtime, and its
+	// options; runs outside one keep the keys they had.
+	if c.Container != "" {
+		options = append(options, "container="+c.Container, "container-runtime="+c.ContainerRuntime)
+		for _, arg := range c.ContainerArg {
+			options = append(options, "container-arg="+arg)
+		}
+	}
+
+	return options
+}
+
+// cacheEnviron returns the variables of environ that are part of the cache
+// key: those named in allow if it is set, and otherwise all but the ones
+// that change between shells or sessions without affecting the command.
+func cacheEnviron(environ, allow []string) []string {
+	return slices.DeleteFunc(slices.Clone(environ), func(kv string) bool {
+		name, _, _ := strings.Cut(kv, "=")
+		if len(allow) > 0 {
+			return !slices.Contains(allow, name)
+		}
+
+		return volatileEnv(name)
+	})
+}
+
+// cacheKey hashes what decides a command's result: its arguments, working
+// directory, platform, environment, ztime's options that affect the run,
+// and the contents of its declared inputs.
+func cacheKey(args, environ, inputs, options []string) (string, error) {
+	h := sha256.New()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	writeField(h, "argv", args...)
+	writeField(h, "wd", wd)
+	writeField(h, "platform", runtime.GOOS, runtime.GOARCH)
+	writeField(h, "options", options...)
+
+	env := slices.Clone(environ)
+	slices.Sort(env)
+	writeField(h, "env", env...)
+
+	for _, input := range inputs {
+		if err := hashInput(h, input); err != nil {
+			return "", fmt.Errorf("hashing --cache-input: %w", err)
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// volatileEnv reports whether an environment variable changes between
+// otherwise identical invocations, such as from one shell, terminal, or
+// login session to the next, without affecting the command.
+func volatileEnv(name string) bool {
+	switch name {
+	case "_", "OLDPWD", "PWD", "SHLVL",
+		"TERM_SESSION_ID", "ITERM_SESSION_ID", "WT_SESSION", "WINDOWID", "KITTY_WINDOW_ID", "ALACRITTY_WINDOW_ID", "WEZTERM_PANE",
+		"TMUX", "TMUX_PANE", "STY",
+		"SSH_AUTH_SOCK", "SSH_AGENT_PID", "SSH_CLIENT", "SSH_CONNECTION", "SSH_TTY", "GPG_TTY",
+		"XDG_SESSION_ID", "DBUS_SESSION_BUS_ADDRESS":
+		return true
+	default:
+		return false
+	}
+}
+
+// writeField writes a labeled list of values to h, NUL-separated so that
+// no two different lists hash the same.
+func writeField(h hash.Hash, label string, values ...string) {
+	fmt.Fprintf(h, "%s\x00%d\x00", label, len(values))
+
+	for _, v := range values {
+		fmt.Fprintf(h, "%d\x00%s\x00", len(v), v)
+	}
+}
+
+// hashInput adds a file, or every file under a directory, to h: each path
+// with its contents, or with its target for symbolic links.
+func hashInput(h hash.Hash, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return nil
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+
+			writeField(h, "link", path, target)
+
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		sum := sha256.New()
+		if _, err := io.Copy(sum, f); err != nil {
+			return err
+		}
+
+		writeField(h, "file", path, hex.EncodeToString(sum.Sum(nil)))
+
+		return nil
+	})
+}
+
+// loadCached reads a cached result and marks it as such. A missing or
+// unreadable entry is a miss.
+func loadCached(path string) (Metrics, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Metrics{}, false
+	}
+
+	var m Metrics
+	if err := json.Unmarshal(data, &m); err != nil {
+		return Metrics{}, false
+	}
+
+	m.Cached = true
+
+	return m, true
+}
+
+// storeCached writes m to path, replacing any earlier entry atomically so
+// that concurrent invocations never read half a result.
+func storeCached(path string, m Metrics) error {
+	data, err := json.Marshal(versioned(m))
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// cachedNote says that the result was reused rather than measured, or
+// returns "" for a fresh run.
+func cachedNote(m Metrics) string {
+	if !m.Cached {
+		return ""
+	}
+
+	return fmt.Sprintf("cached result from %s; the command was not run", m.EndTime.Local().Format(time.DateTime))
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+
+	"golang.org/x/sys/unix"
+)
+
+// dropCaches flushes dirty pages and purges the unified buffer cache with
+// purge(8), which requires root.
+func dropCaches() error {
+	unix.Sync()
+
+	if out, err := exec.CommandContext(context.Background(), "purge").CombinedOutput(); err != nil {
+		return fmt.Errorf("dropping caches (requires root): %w: %s", err, out)
+	}
+
+	return nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// dropCaches flushes dirty pages and drops the page cache, dentries, and
+// inodes. Writing to drop_caches requires root.
+func dropCaches() error {
+	unix.Sync()
+
+	if err := os.WriteFile("/proc/sys/vm/drop_caches", []byte("3"), 0); err != nil {
+		return fmt.Errorf("dropping caches (requires root): %w", err)
+	}
+
+	return nil
+}
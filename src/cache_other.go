@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+var errDropCachesUnsupported = errors.New("--drop-caches is not supported")
+
+func dropCaches() error {
+	return fmt.Errorf("%w on %s", errDropCachesUnsupported, runtime.GOOS)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCacheKey(t *testing.T) {
+	t.Parallel()
+
+	input := filepath.Join(t.TempDir(), "input.txt")
+	if err := os.WriteFile(input, []byte("a"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	key := func(args, environ []string) string {
+		t.Helper()
+
+		k, err := cacheKey(args, cacheEnviron(environ, nil), []string{input}, nil)
+		if err != nil {
+			t.Fatalf("cacheKey() error = %v", err)
+		}
+
+		return k
+	}
+
+	base := key([]string{"make", "all"}, []string{"CC=gcc", "SHLVL=1"})
+
+	tests := []struct {
+		name    string
+		args    []string
+		environ []string
+		same    bool
+	}{
+		{"identical", []string{"make", "all"}, []string{"CC=gcc", "SHLVL=1"}, true},
+		{"environment order", []string{"make", "all"}, []string{"SHLVL=1", "CC=gcc"}, true},
+		{"volatile variable", []string{"make", "all"}, []string{"CC=gcc", "SHLVL=3", "OLDPWD=/"}, true},
+		{"session variable", []string{"make", "all"}, []string{"CC=gcc", "SHLVL=1", "SSH_AUTH_SOCK=/tmp/agent.1", "TERM_SESSION_ID=w0t0p0"}, true},
+		{"arguments", []string{"make", "test"}, []string{"CC=gcc", "SHLVL=1"}, false},
+		{"argument boundaries", []string{"make a", "ll"}, []string{"CC=gcc", "SHLVL=1"}, false},
+		{"environment", []string{"make", "all"}, []string{"CC=clang", "SHLVL=1"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := key(tt.args, tt.environ); (got == base) != tt.same {
+			t.Errorf("%s: key equal to base = %v, want %v", tt.name, got == base, tt.same)
+		}
+	}
+
+	if err := os.WriteFile(input, []byte("b"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if key([]string{"make", "all"}, []string{"CC=gcc", "SHLVL=1"}) == base {
+		t.Error("key did not change with the contents of a --cache-input file")
+	}
+
+	if _, err := cacheKey([]string{"make"}, nil, []string{filepath.Join(t.TempDir(), "missing")}, nil); err == nil {
+		t.Error("cacheKey() with a missing input succeeded, want an error")
+	}
+}
+
+func TestCacheEnviron(t *testing.T) {
+	t.Parallel()
+
+	environ := []string{"CC=gcc", "HOME=/home/me", "SSH_AUTH_SOCK=/tmp/agent.1"}
+
+	if got := cacheEnviron(environ, nil); !slices.Equal(got, []string{"CC=gcc", "HOME=/home/me"}) {
+		t.Errorf("cacheEnviron() = %q, want the session variable left out", got)
+	}
+
+	if got := cacheEnviron(environ, []string{"CC", "SSH_AUTH_SOCK"}); !slices.Equal(got, []string{"CC=gcc", "SSH_AUTH_SOCK=/tmp/agent.1"}) {
+		t.Errorf("cacheEnviron(allow) = %q, want only the allowed variables", got)
+	}
+}
+
+func TestCacheOptions(t *testing.T) {
+	t.Parallel()
+
+	key := func(c runCmd) string {
+		t.Helper()
+
+		k, err := cacheKey([]string{"make"}, nil, nil, c.cacheOptions())
+		if err != nil {
+			t.Fatalf("cacheKey() error = %v", err)
+		}
+
+		return k
+	}
+
+	base := key(runCmd{})
+
+	for name, c := range map[string]runCmd{
+		"pty":       {PTY: true},
+		"stdin":     {StdinNull: true},
+		"timeout":   {Timeout: time.Minute},
+		"limit-mem": {LimitMem: 1 << 30},
+		"env":       {Env: map[string]string{"CC": "clang"}},
+		"prepare":   {Prepare: "make clean"},
+		"container": {Container: "alpine"},
+	} {
+		if key(c) == base {
+			t.Errorf("%s: key did not change with the option", name)
+		}
+	}
+
+	container := runCmd{Container: "alpine", ContainerRuntime: "docker"}
+	for name, c := range map[string]runCmd{
+		"container":         {Container: "debian", ContainerRuntime: "docker"},
+		"container-runtime": {Container: "alpine", ContainerRuntime: "podman"},
+		"container-arg":     {Container: "alpine", ContainerRuntime: "docker", ContainerArg: []string{"--volume=.:/src"}},
+	} {
+		if key(c) == key(container) {
+			t.Errorf("%s: key did not change with the option", name)
+		}
+	}
+
+	if key(runCmd{Quiet: true, JSON: true}) != base {
+		t.Error("key changed with an output option")
+	}
+}
+
+func TestStoreCached(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "results", "key.json")
+
+	if _, ok := loadCached(path); ok {
+		t.Fatal("loadCached() hit before anything was stored")
+	}
+
+	m := Metrics{Command: "make all", ElapsedTime: 3 * time.Second, Argv: []string{"make", "all"}}
+	if err := storeCached(path, m); err != nil {
+		t.Fatalf("storeCached() error = %v", err)
+	}
+
+	got, ok := loadCached(path)
+	if !ok {
+		t.Fatal("loadCached() missed after storeCached()")
+	}
+
+	if !got.Cached || got.Command != m.Command || got.ElapsedTime != m.ElapsedTime {
+		t.Errorf("loadCached() = %+v, want %+v marked cached", got, m)
+	}
+
+	if cachedNote(got) == "" || cachedNote(m) != "" {
+		t.Error("cachedNote() should describe cached results only")
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// calibrationRuns is how many times the no-op command is timed to measure
+// ztime's overhead, after one untimed warmup run.
+const calibrationRuns = 20
+
+// noopArg, as ztime's only argument, makes it exit at once without parsing
+// its command line or reading any configuration. Re-running ztime this way
+// is the program that does nothing which --calibrate times, the same on
+// every platform.
+const noopArg = "__noop"
+
+// isNoop reports whether args, as in os.Args, ask for the no-op entry point.
+func isNoop(args []string) bool {
+	return len(args) == 2 && args[1] == noopArg
+}
+
+// noopCommand is the command line that runs ztime's no-op entry point.
+func noopCommand() ([]string, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("locating ztime to calibrate with: %w", err)
+	}
+
+	return []string{self, noopArg}, nil
+}
+
+// Calibration is the result of --calibrate.
+type Calibration struct {
+	Command  string        `json:"command"`
+	Runs     int           `json:"runs" unit:"count"`
+	Overhead DurationStats `json:"overhead"`
+}
+
+// calibrate times a program that does nothing, which is ztime's own cost
+// of starting, timing, and reaping a command, and returns the median.
+func (c *runCmd) calibrate() (time.Duration, error) {
+	r, err := c.measureOverhead()
+	if err != nil {
+		return 0, err
+	}
+
+	c.debug.printf("ztime overhead is %s (median of %d runs of %s)", r.Overhead.P50, r.Runs, r.Command)
+
+	return r.Overhead.P50, nil
+}
+
+func (c *runCmd) measureOverhead() (Calibration, error) {
+	args, err := noopCommand()
+	if err != nil {
+		return Calibration{}, err
+	}
+
+	opts := runOptions{debug: c.debug, precisionNS: c.PrecisionNS}
+	samples := make([]time.Duration, 0, calibrationRuns+1)
+
+	for range calibrationRuns + 1 {
+		m, err := runCommand(args, opts)
+		if err != nil {
+			return Calibration{}, fmt.Errorf("calibrating with %s: %w", strings.Join(args, " "), err)
+		}
+
+		samples = append(samples, m.ElapsedTime)
+	}
+
+	return Calibration{
+		Command:  strings.Join(args, " "),
+		Runs:     calibrationRuns,
+		Overhead: summarize(samples[1:]),
+	}, nil
+}
+
+// printCalibration prints the overhead for --calibrate.
+func (c *runCmd) printCalibration() error {
+	r, err := c.measureOverhead()
+	if err != nil {
+		return err
+	}
+
+	if c.JSON {
+		c.printJSON(r)
+
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "ztime overhead: %s median, %s min, %s max (%d runs of %s)\n",
+		humanDuration(r.Overhead.P50), humanDuration(r.Overhead.Min), humanDuration(r.Overhead.Max), r.Runs, r.Command)
+
+	return nil
+}
+
+// subtractOverhead leaves the measured overhead out of the elapsed time of
+// m, never taking it below zero, and records how much was left out.
+func subtractOverhead(m *Metrics, overhead time.Duration) {
+	if overhead <= 0 {
+		return
+	}
+
+	m.Overhead = min(overhead, m.ElapsedTime)
+	m.ElapsedTime -= m.Overhead
+	m.CPUPercent = calculateCPUPercent(m.UserTime, m.SystemTime, m.ElapsedTime)
+	m.Unaccounted = max(m.ElapsedTime-m.UserTime-m.SystemTime, 0)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain lets the test binary stand in for ztime when a test re-runs it
+// as the no-op entry point.
+func TestMain(m *testing.M) {
+	if isNoop(os.Args) {
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestIsNoop(t *testing.T) {
+	t.Parallel()
+
+	for _, args := range [][]string{{"ztime"}, {"ztime", "run", noopArg}, {"ztime", noopArg, "x"}} {
+		if isNoop(args) {
+			t.Errorf("isNoop(%q) = true", args)
+		}
+	}
+
+	if !isNoop([]string{"ztime", noopArg}) {
+		t.Error("isNoop(ztime __noop) = false")
+	}
+}
+
+func TestSubtractOverhead(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		elapsed  time.Duration
+		overhead time.Duration
+		want     time.Duration
+	}{
+		{"none", 10 * time.Millisecond, 0, 10 * time.Millisecond},
+		{"subtracted", 10 * time.Millisecond, time.Millisecond, 9 * time.Millisecond},
+		{"clamped", time.Millisecond, 2 * time.Millisecond, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m := Metrics{ElapsedTime: tt.elapsed, UserTime: time.Millisecond / 2, Unaccounted: tt.elapsed - time.Millisecond/2}
+			subtractOverhead(&m, tt.overhead)
+
+			if m.ElapsedTime != tt.want || m.Overhead != tt.elapsed-tt.want {
+				t.Errorf("ElapsedTime = %v, Overhead = %v; want %v, %v", m.ElapsedTime, m.Overhead, tt.want, tt.elapsed-tt.want)
+			}
+
+			if m.Unaccounted != max(tt.want-m.UserTime, 0) {
+				t.Errorf("Unaccounted = %v, want it recomputed", m.Unaccounted)
+			}
+		})
+	}
+}
+
+func TestMeasureOverhead(t *testing.T) {
+	t.Parallel()
+
+	r, err := (&runCmd{}).measureOverhead()
+	if err != nil {
+		t.Fatalf("measureOverhead() error = %v", err)
+	}
+
+	if r.Runs != calibrationRuns || !strings.HasSuffix(r.Command, " "+noopArg) {
+		t.Errorf("measureOverhead() = %d runs of %q, want %d of the no-op entry point", r.Runs, r.Command, calibrationRuns)
+	}
+
+	if r.Overhead.Min <= 0 || r.Overhead.P50 < r.Overhead.Min || r.Overhead.Max > time.Second {
+		t.Errorf("Overhead = %+v, want a small positive median", r.Overhead)
+	}
+}
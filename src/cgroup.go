@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CgroupThrottle is the CPU throttling of ztime's cgroup during a run, which
+// the command shares. It is only recorded when the cgroup has a CPU limit.
+type CgroupThrottle struct {
+	Cgroup           string        `json:"cgroup"`                         // cpu.stat file read
+	Periods          int64         `json:"periods" unit:"count"`           // enforcement periods during the run
+	ThrottledPeriods int64         `json:"throttled_periods" unit:"count"` // periods in which the cgroup hit its limit
+	ThrottledTime    time.Duration `json:"throttled_time"`                 // time the cgroup's tasks were held back
+}
+
+// cpuStat holds the throttling counters of a cgroup's cpu.stat.
+type cpuStat struct {
+	periods       int64
+	throttled     int64
+	throttledTime time.Duration
+}
+
+// parseCPUStat reads the throttling counters of a cgroup v1 or v2 cpu.stat
+// file. v1 reports the throttled time in nanoseconds, v2 in microseconds.
+func parseCPUStat(data []byte) (cpuStat, bool) {
+	var (
+		s     cpuStat
+		found bool
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), " ")
+
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		switch key {
+		case "nr_periods":
+			s.periods, found = n, true
+		case "nr_throttled":
+			s.throttled = n
+		case "throttled_usec":
+			s.throttledTime = time.Duration(n) * time.Microsecond
+		case "throttled_time":
+			s.throttledTime = time.Duration(n)
+		}
+	}
+
+	return s, found
+}
+
+// cgroupPaths returns where a file of the cgroup described by
+// /proc/self/cgroup may be, for cgroup v1, v2, and hybrid hierarchies. The
+// file is v1File in the v1 hierarchy of controller and v2File in the
+// unified one.
+func cgroupPaths(data []byte, controller, v1File, v2File string) []string {
+	var paths []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		controllers, dir := parts[1], parts[2]
+
+		switch {
+		case controllers == "":
+			paths = append(paths, path.Join("/sys/fs/cgroup", dir, v2File), path.Join("/sys/fs/cgroup/unified", dir, v2File))
+		case strings.Contains(","+controllers+",", ","+controller+","):
+			paths = append(paths, path.Join("/sys/fs/cgroup", controllers, dir, v1File))
+			if controllers != controller {
+				paths = append(paths, path.Join("/sys/fs/cgroup", controller, dir, v1File))
+			}
+		}
+	}
+
+	return paths
+}
+
+// throttleNote warns that the run was CPU throttled, which makes its timing
+// unrepresentative, or returns "" if it was not.
+func throttleNote(t *CgroupThrottle) string {
+	if t == nil || t.ThrottledPeriods == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("warning: the cgroup's CPU limit throttled the run in %d of %d periods (%.3fs); the timing is not representative",
+		t.ThrottledPeriods, t.Periods, t.ThrottledTime.Seconds())
+}
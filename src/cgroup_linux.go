@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// cgroupWatch compares the throttling counters of ztime's cgroup before and
+// after a run.
+type cgroupWatch struct {
+	path   string
+	before cpuStat
+}
+
+// watchCgroupThrottling finds the cpu.stat of ztime's cgroup and records
+// its counters. It returns nil when there is none to read.
+func watchCgroupThrottling() *cgroupWatch {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return nil
+	}
+
+	for _, path := range cgroupPaths(data, "cpu", "cpu.stat", "cpu.stat") {
+		//nolint:gosec // Intended behavior: the path comes from /proc/self/cgroup.
+		stat, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		if before, ok := parseCPUStat(stat); ok {
+			return &cgroupWatch{path: path, before: before}
+		}
+	}
+
+	return nil
+}
+
+func (w *cgroupWatch) finish(m *Metrics, _ time.Time) {
+	//nolint:gosec // Intended behavior: the path comes from /proc/self/cgroup.
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return
+	}
+
+	after, ok := parseCPUStat(data)
+	if !ok || after.periods == w.before.periods {
+		return
+	}
+
+	m.CgroupThrottle = &CgroupThrottle{
+		Cgroup:           w.path,
+		Periods:          after.periods - w.before.periods,
+		ThrottledPeriods: after.throttled - w.before.throttled,
+		ThrottledTime:    after.throttledTime - w.before.throttledTime,
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import "time"
+
+// cgroupWatch does nothing outside Linux, which has no cgroups.
+type cgroupWatch struct{}
+
+func watchCgroupThrottling() *cgroupWatch {
+	return nil
+}
+
+func (*cgroupWatch) finish(*Metrics, time.Time) {}
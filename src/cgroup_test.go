@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCPUStat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		data   string
+		want   cpuStat
+		wantOK bool
+	}{
+		{"v2", "usage_usec 9000\nuser_usec 6000\nsystem_usec 3000\nnr_periods 40\nnr_throttled 12\nthrottled_usec 300000\n", cpuStat{40, 12, 300 * time.Millisecond}, true},
+		{"v1", "nr_periods 40\nnr_throttled 12\nthrottled_time 300000000\n", cpuStat{40, 12, 300 * time.Millisecond}, true},
+		{"no cpu controller", "usage_usec 9000\nuser_usec 6000\n", cpuStat{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := parseCPUStat([]byte(tt.data))
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("parseCPUStat() = %+v, %v; want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestCgroupPaths(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("4:memory:/ci/job\n2:cpu,cpuacct:/ci/job\n0::/ci/job\n")
+	want := []string{
+		"/sys/fs/cgroup/cpu,cpuacct/ci/job/cpu.stat",
+		"/sys/fs/cgroup/cpu/ci/job/cpu.stat",
+		"/sys/fs/cgroup/ci/job/cpu.stat",
+		"/sys/fs/cgroup/unified/ci/job/cpu.stat",
+	}
+
+	if got := cgroupPaths(data, "cpu", "cpu.stat", "cpu.stat"); !reflect.DeepEqual(got, want) {
+		t.Errorf("cgroupPaths(cpu) = %v, want %v", got, want)
+	}
+
+	want = []string{
+		"/sys/fs/cgroup/memory/ci/job/memory.oom_control",
+		"/sys/fs/cgroup/ci/job/memory.events",
+		"/sys/fs/cgroup/unified/ci/job/memory.events",
+	}
+
+	if got := cgroupPaths(data, "memory", "memory.oom_control", "memory.events"); !reflect.DeepEqual(got, want) {
+		t.Errorf("cgroupPaths(memory) = %v, want %v", got, want)
+	}
+}
+
+func TestThrottleNote(t *testing.T) {
+	t.Parallel()
+
+	if note := throttleNote(&CgroupThrottle{Periods: 40}); note != "" {
+		t.Errorf("throttleNote() without throttling = %q, want none", note)
+	}
+
+	want := "warning: the cgroup's CPU limit throttled the run in 12 of 40 periods (0.300s); the timing is not representative"
+	if note := throttleNote(&CgroupThrottle{Periods: 40, ThrottledPeriods: 12, ThrottledTime: 300 * time.Millisecond}); note != want {
+		t.Errorf("throttleNote() = %q, want %q", note, want)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// changeSettle is how long --on-change waits for a burst of changes, such
+// as an editor saving or a checkout, to end before acting on it.
+const changeSettle = 100 * time.Millisecond
+
+// changeWatcher notices changes under the --on-change paths with fsnotify.
+// fsnotify watches single directories, so every directory under the paths
+// is watched, and new ones as they appear. A path that is a file is watched
+// through its directory, which keeps working when an editor replaces the
+// file. Anything matching an exclude glob is ignored.
+type changeWatcher struct {
+	watcher  *fsnotify.Watcher
+	dirs     map[string]bool // watched with everything in them
+	files    map[string]bool // watched on their own
+	excludes []string
+}
+
+// watchChanges starts watching paths. A missing path or a malformed glob is
+// an error.
+func watchChanges(paths, excludes []string) (*changeWatcher, error) {
+	for _, pattern := range excludes {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("--on-change-exclude %q: %w", pattern, err)
+		}
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("watching for changes: %w", err)
+	}
+
+	c := &changeWatcher{watcher: watcher, dirs: make(map[string]bool), files: make(map[string]bool), excludes: excludes}
+
+	for _, path := range paths {
+		if err := c.add(filepath.Clean(path)); err != nil {
+			_ = watcher.Close()
+
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// add watches root: the file, or the directory and every directory under
+// it that is not excluded.
+func (c *changeWatcher) add(root string) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		c.files[root] = true
+
+		return c.watch(filepath.Dir(root))
+	}
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			// Entries that vanish or cannot be read are not watched.
+			if path == root {
+				return err
+			}
+
+			return nil
+		case !d.IsDir():
+			return nil
+		case path != root && c.excluded(path):
+			return filepath.SkipDir
+		}
+
+		c.dirs[path] = true
+
+		return c.watch(path)
+	})
+}
+
+func (c *changeWatcher) watch(dir string) error {
+	if err := c.watcher.Add(dir); err != nil {
+		return fmt.Errorf("watching %s: %w", dir, err)
+	}
+
+	return nil
+}
+
+// excluded reports whether path, or its name, matches an exclude glob.
+func (c *changeWatcher) excluded(path string) bool {
+	for _, pattern := range c.excludes {
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// changed reports whether an event is a change to a watched path. A new
+// directory in a watched one is watched from then on.
+func (c *changeWatcher) changed(event fsnotify.Event) bool {
+	if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+		return false
+	}
+
+	if !c.files[event.Name] && (!c.dirs[filepath.Dir(event.Name)] || c.excluded(event.Name)) {
+		return false
+	}
+
+	if event.Has(fsnotify.Create) && c.dirs[filepath.Dir(event.Name)] {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			_ = c.add(event.Name)
+		}
+	}
+
+	return true
+}
+
+// wait blocks until a watched path is created, written, removed, or renamed
+// and the changes have settled, returning false if ctx is cancelled first.
+// Events lost because the kernel's queue overflowed count as a change.
+func (c *changeWatcher) wait(ctx context.Context) bool {
+	var settled <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case event, ok := <-c.watcher.Events:
+			if !ok {
+				return false
+			}
+
+			if c.changed(event) {
+				settled = time.After(changeSettle)
+			}
+		case err, ok := <-c.watcher.Errors:
+			if !ok {
+				return false
+			}
+
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				settled = time.After(changeSettle)
+			}
+		case <-settled:
+			return true
+		}
+	}
+}
+
+// discard drops the changes seen so far, once they have settled or ctx is
+// cancelled. After a run, these are the command's own writes under the
+// watched paths, such as its build outputs, which would otherwise start the
+// next run at once.
+func (c *changeWatcher) discard(ctx context.Context) {
+	settled := time.After(changeSettle)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-c.watcher.Events:
+			if !ok {
+				return
+			}
+
+			if c.changed(event) {
+				settled = time.After(changeSettle)
+			}
+		case <-c.watcher.Errors:
+		case <-settled:
+			return
+		}
+	}
+}
+
+func (c *changeWatcher) close() {
+	_ = c.watcher.Close()
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Tests wait this long for a change that should come, and noChangeWait for
+// one that should not.
+const (
+	changeWait   = 10 * time.Second
+	noChangeWait = 500 * time.Millisecond
+)
+
+// waitsForChange reports whether w sees a change after change runs, if it
+// is not nil, within timeout.
+func waitsForChange(t *testing.T, w *changeWatcher, change func(), timeout time.Duration) bool {
+	t.Helper()
+
+	if change != nil {
+		go func() {
+			time.Sleep(changeSettle)
+			change()
+		}()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return w.wait(ctx)
+}
+
+func writeFile(t *testing.T, path string) func() {
+	t.Helper()
+
+	return func() {
+		if err := os.WriteFile(path, []byte("x"), 0o600); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestChangeWatcher(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	w, err := watchChanges([]string{dir}, nil)
+	if err != nil {
+		t.Fatalf("watchChanges() error = %v", err)
+	}
+	defer w.close()
+
+	if waitsForChange(t, w, nil, noChangeWait) {
+		t.Error("wait() = true without a change")
+	}
+
+	if !waitsForChange(t, w, writeFile(t, filepath.Join(dir, "new.txt")), changeWait) {
+		t.Fatal("wait() = false, want true after a file was created")
+	}
+
+	// A directory created after the watch started is watched too.
+	sub := filepath.Join(dir, "sub")
+	if !waitsForChange(t, w, func() { _ = os.Mkdir(sub, 0o700) }, changeWait) {
+		t.Fatal("wait() = false, want true after a directory was created")
+	}
+
+	if !waitsForChange(t, w, writeFile(t, filepath.Join(sub, "new.txt")), changeWait) {
+		t.Error("wait() = false, want true after a file was created in a new directory")
+	}
+}
+
+func TestChangeWatcherExclude(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+
+	if err := os.Mkdir(out, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := watchChanges([]string{dir}, []string{"out", "*.o"})
+	if err != nil {
+		t.Fatalf("watchChanges() error = %v", err)
+	}
+	defer w.close()
+
+	for _, path := range []string{filepath.Join(out, "app"), filepath.Join(dir, "main.o")} {
+		if waitsForChange(t, w, writeFile(t, path), noChangeWait) {
+			t.Errorf("wait() = true after %s, which is excluded, changed", path)
+		}
+	}
+
+	if !waitsForChange(t, w, writeFile(t, filepath.Join(dir, "main.c")), changeWait) {
+		t.Error("wait() = false, want true after a file that is not excluded changed")
+	}
+}
+
+func TestChangeWatcherFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.toml")
+	writeFile(t, file)()
+
+	w, err := watchChanges([]string{file}, nil)
+	if err != nil {
+		t.Fatalf("watchChanges() error = %v", err)
+	}
+	defer w.close()
+
+	if waitsForChange(t, w, writeFile(t, filepath.Join(dir, "other.toml")), noChangeWait) {
+		t.Error("wait() = true after another file in the directory changed")
+	}
+
+	// Editors often save by replacing the file.
+	replace := func() {
+		tmp := filepath.Join(dir, "config.toml.tmp")
+		writeFile(t, tmp)()
+		_ = os.Rename(tmp, file)
+	}
+
+	for range 2 {
+		if !waitsForChange(t, w, replace, changeWait) {
+			t.Fatal("wait() = false, want true after the file was replaced")
+		}
+	}
+}
+
+func TestChangeWatcherDiscard(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	w, err := watchChanges([]string{dir}, nil)
+	if err != nil {
+		t.Fatalf("watchChanges() error = %v", err)
+	}
+	defer w.close()
+
+	// Written during a run, as a build writes its outputs.
+	writeFile(t, filepath.Join(dir, "output"))()
+	w.discard(context.Background())
+
+	if waitsForChange(t, w, nil, noChangeWait) {
+		t.Error("wait() = true for a change that was discarded")
+	}
+}
+
+func TestWatchChangesErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	if _, err := watchChanges([]string{filepath.Join(dir, "missing")}, nil); err == nil {
+		t.Error("watchChanges() of a missing path succeeded")
+	}
+
+	if _, err := watchChanges([]string{dir}, []string{"["}); err == nil {
+		t.Error("watchChanges() with a malformed glob succeeded")
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// regressionFloor is the smallest slowdown, relative to the mean, that
+// counts as a regression. Without it, a history with little or no spread
+// would flag any run that is a little slower.
+const regressionFloor = 0.05
+
+var errRegression = errors.New("performance regression")
+
+// checkCmd times a command and compares it with its recorded history.
+type checkCmd struct {
+	Sigma      float64 `default:"2" help:"Flag a regression when the run is more than this many standard deviations, and more than 5%, slower than the mean of the history."`
+	MinHistory int     `default:"5" help:"Recorded runs needed before checking; until then runs are only recorded." placeholder:"N"`
+	Window     int     `default:"50" help:"Compare with at most this many of the latest recorded runs." placeholder:"N"`
+	JSON       bool    `help:"Output the check result in JSON format."`
+
+	Command []string `arg:"" help:"Command to execute." passthrough:""`
+}
+
+// CheckResult is the outcome of comparing a run with its history.
+type CheckResult struct {
+	SchemaVersion int `json:"schema_version,omitempty" unit:"none"`
+
+	Result     Metrics       `json:"result"`
+	History    int           `json:"history" unit:"count"`                            // recorded runs compared with
+	Mean       time.Duration `json:"mean,omitempty"`                                  // of the history's elapsed times
+	StdDev     time.Duration `json:"stddev,omitempty"`                                // of the history's elapsed times
+	Deviations float64       `json:"deviations,omitempty" unit:"standard deviations"` // standard deviations the run is above the mean
+	Regression bool          `json:"regression"`
+}
+
+// Run times the command once, compares its elapsed time with the recorded
+// runs of the same command in the same directory, and records it. It
+// returns errRegression if the run is significantly slower than usual.
+func (c *checkCmd) Run() error {
+	c.Command = dropSeparator(c.Command)
+
+	r := &runCmd{Command: c.Command}
+	if err := r.prepare(); err != nil {
+		return err
+	}
+
+	path, err := historyPath(c.Command)
+	if err != nil {
+		return err
+	}
+
+	history, err := loadHistory(path)
+	if err != nil {
+		return err
+	}
+
+	m, err := r.measure(c.Command, r.runOptions(runOptions{totalRuns: 1}))
+	if err != nil {
+		if !c.JSON {
+			printSummary(m, "", formatOptions{})
+		}
+
+		// A failed run says nothing about performance and is not recorded.
+		return err
+	}
+
+	// A spread needs at least two runs.
+	needed := max(c.MinHistory, 2)
+	result := compareHistory(m, history[max(len(history)-c.Window, 0):], needed, c.Sigma)
+
+	if c.JSON {
+		data, err := r.encodeJSON(result)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(os.Stderr, string(data))
+	} else {
+		printSummary(m, "", formatOptions{})
+		c.printCheck(result, needed)
+	}
+
+	if err := appendHistory(path, m); err != nil {
+		return fmt.Errorf("recording run: %w", err)
+	}
+
+	if result.Regression {
+		return fmt.Errorf("%w: %s slower than usual", errRegression, result.slowdown(" standard deviations"))
+	}
+
+	return nil
+}
+
+// compareHistory compares the elapsed time of m with the distribution of
+// history, flagging a regression when it is more than sigma standard
+// deviations and regressionFloor above the mean. With fewer than needed
+// runs in the history, nothing is compared.
+func compareHistory(m Metrics, history []Metrics, needed int, sigma float64) CheckResult {
+	result := CheckResult{Result: m, History: len(history)}
+	if len(history) < needed {
+		return result
+	}
+
+	stats := elapsedStats(history)
+	result.Mean, result.StdDev = stats.Mean, stats.StdDev
+
+	slower := m.ElapsedTime - stats.Mean
+	if stats.StdDev > 0 {
+		result.Deviations = float64(slower) / float64(stats.StdDev)
+	}
+
+	result.Regression = float64(slower) > sigma*float64(stats.StdDev) && float64(slower) > regressionFloor*float64(stats.Mean)
+
+	return result
+}
+
+// slowdown describes how far the run is above the mean: in deviations, as
+// many standard deviations, or as a percentage when the history has no
+// spread to measure it in.
+func (r CheckResult) slowdown(deviations string) string {
+	if r.StdDev == 0 {
+		return fmt.Sprintf("%.0f%%", 100*float64(r.Result.ElapsedTime-r.Mean)/float64(r.Mean))
+	}
+
+	return fmt.Sprintf("%.1f%s", r.Deviations, deviations)
+}
+
+// printCheck prints how the run compares with its history.
+func (c *checkCmd) printCheck(r CheckResult, needed int) {
+	good := palette.good
+	bad := palette.bad.Bold(true)
+
+	if r.History < needed {
+		fmt.Fprintf(os.Stderr, "ztime: recorded run %d of the %d needed before checking for regressions\n", r.History+1, needed)
+
+		return
+	}
+
+	baseline := fmt.Sprintf("the last %d runs (%s ± %s)", r.History, humanDuration(r.Mean), humanDuration(r.StdDev))
+
+	if r.Regression {
+		fmt.Fprintf(os.Stderr, "ztime: %s %s is %s above the mean of %s\n", bad.Render("regression:"), humanDuration(r.Result.ElapsedTime), r.slowdown("σ"), baseline)
+	} else if r.StdDev == 0 || r.Deviations > c.Sigma {
+		fmt.Fprintf(os.Stderr, "ztime: %s %s is at most %.0f%% above the mean of %s\n", good.Render("ok:"), humanDuration(r.Result.ElapsedTime), 100*regressionFloor, baseline)
+	} else {
+		fmt.Fprintf(os.Stderr, "ztime: %s %s is within %gσ of the mean of %s\n", good.Render("ok:"), humanDuration(r.Result.ElapsedTime), c.Sigma, baseline)
+	}
+}
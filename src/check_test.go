@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompareHistory(t *testing.T) {
+	t.Parallel()
+
+	history := func(ms ...int) []Metrics {
+		var runs []Metrics
+		for _, n := range ms {
+			runs = append(runs, Metrics{ElapsedTime: time.Duration(n) * time.Millisecond})
+		}
+
+		return runs
+	}
+
+	usual := history(90, 100, 110, 100)
+
+	// Identical runs, as a cached or very quick step records, have no spread.
+	steady := history(100, 100, 100, 100)
+
+	tests := []struct {
+		name       string
+		history    []Metrics
+		elapsed    time.Duration
+		needed     int
+		regression bool
+		slowdown   string
+	}{
+		{name: "Usual", history: usual, elapsed: 105 * time.Millisecond, needed: 2, slowdown: "0.6σ"},
+		{name: "Faster", history: usual, elapsed: 50 * time.Millisecond, needed: 2, slowdown: "-6.1σ"},
+		{name: "Much Slower", history: usual, elapsed: 150 * time.Millisecond, needed: 2, regression: true, slowdown: "6.1σ"},
+		{name: "Too Little History", history: usual, elapsed: 150 * time.Millisecond, needed: 5},
+		{name: "No Spread", history: steady, elapsed: 100 * time.Millisecond, needed: 2, slowdown: "0%"},
+		{name: "No Spread Slightly Slower", history: steady, elapsed: 101 * time.Millisecond, needed: 2, slowdown: "1%"},
+		{name: "No Spread Much Slower", history: steady, elapsed: 150 * time.Millisecond, needed: 2, regression: true, slowdown: "50%"},
+		{name: "Little Spread Slightly Slower", history: history(100, 100, 100, 101), elapsed: 103 * time.Millisecond, needed: 2, slowdown: "5.5σ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := compareHistory(Metrics{ElapsedTime: tt.elapsed}, tt.history, tt.needed, 2)
+			if r.Regression != tt.regression {
+				t.Errorf("compareHistory() regression = %v (%s), want %v", r.Regression, r.slowdown("σ"), tt.regression)
+			}
+
+			if r.History != len(tt.history) {
+				t.Errorf("compareHistory() history = %d, want %d", r.History, len(tt.history))
+			}
+
+			if tt.slowdown != "" && r.slowdown("σ") != tt.slowdown {
+				t.Errorf("slowdown() = %s, want %s", r.slowdown("σ"), tt.slowdown)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Comparison is the result of benchmarking the command against --compare.
+type Comparison struct {
+	SchemaVersion int `json:"schema_version,omitempty" unit:"none"`
+
+	Benchmarks  []BenchResult `json:"benchmarks"` // the command first, then --compare
+	Interleaved bool          `json:"interleaved"`
+	Speedup     float64       `json:"speedup" unit:"ratio"` // mean elapsed of the slower over the faster; 0 if a benchmark failed
+	Fastest     string        `json:"fastest,omitempty"`
+}
+
+// runComparison benchmarks the command and the --compare shell command
+// under the same settings.
+func (c *runCmd) runComparison() (Comparison, error) {
+	other := shellCommand(context.Background(), c.Compare).Args
+
+	results, err := c.runBenches(
+		benchState{name: strings.Join(c.Command, " "), command: c.Command},
+		benchState{name: c.Compare, command: other},
+	)
+
+	return newComparison(results, c.Interleave), err
+}
+
+func newComparison(results []BenchResult, interleaved bool) Comparison {
+	cmp := Comparison{Benchmarks: results, Interleaved: interleaved}
+
+	for _, r := range results {
+		if r.StopReason == "failed" || r.StopReason == "cancelled" {
+			return cmp
+		}
+	}
+
+	a, b := results[0].Elapsed.Mean, results[1].Elapsed.Mean
+	if a <= 0 || b <= 0 {
+		return cmp
+	}
+
+	if a <= b {
+		cmp.Fastest = results[0].Command
+		cmp.Speedup = float64(b) / float64(a)
+	} else {
+		cmp.Fastest = results[1].Command
+		cmp.Speedup = float64(a) / float64(b)
+	}
+
+	return cmp
+}
+
+// reportComparison prints a comparison in the selected output format.
+// Templates receive the Comparison.
+func (c *runCmd) reportComparison(r Comparison) {
+	switch {
+	case c.JSONL:
+		c.events.emit(event{Type: eventCompareFinished, Result: r})
+	case c.JSON:
+		c.printJSON(r)
+	case c.tmpl != nil:
+		printTemplate(c.tmpl, r)
+	default:
+		for _, b := range r.Benchmarks {
+			printBench(b, formatOptions{micro: c.PrecisionNS})
+
+			if c.Plot {
+				printPlot(b)
+			}
+		}
+
+		printSpeedup(r)
+	}
+}
+
+func printSpeedup(r Comparison) {
+	if r.Fastest == "" {
+		return
+	}
+
+	bold := lipgloss.NewStyle().Bold(true)
+
+	order := "one after the other"
+	if r.Interleaved {
+		order = "interleaved"
+	}
+
+	fmt.Fprintf(os.Stderr, "%s ran %s faster (%s)\n",
+		bold.Render(r.Fastest), palette.good.Render(fmt.Sprintf("%.2fx", r.Speedup)), order)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNextBench(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		interleave bool
+		reasons    []string
+		expected   []int // index picked on turns 0, 1, 2, ...; -1 for none
+	}{
+		{name: "Sequential", reasons: []string{"", ""}, expected: []int{0, 0, 0}},
+		{name: "Sequential Second", reasons: []string{"runs", ""}, expected: []int{1, 1}},
+		{name: "Interleaved", interleave: true, reasons: []string{"", ""}, expected: []int{0, 1, 0, 1}},
+		{name: "Interleaved One Left", interleave: true, reasons: []string{"", "runs"}, expected: []int{0, 0}},
+		{name: "All Done", interleave: true, reasons: []string{"runs", "duration"}, expected: []int{-1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := runCmd{Interleave: tt.interleave}
+
+			states := make([]benchState, len(tt.reasons))
+			for i, reason := range tt.reasons {
+				states[i].reason = reason
+			}
+
+			var got []int
+
+			for turn := range tt.expected {
+				picked := -1
+
+				for i := range states {
+					if c.nextBench(states, turn) == &states[i] {
+						picked = i
+					}
+				}
+
+				got = append(got, picked)
+			}
+
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("nextBench() picked %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewComparison(t *testing.T) {
+	t.Parallel()
+
+	results := []BenchResult{
+		{Command: "a", Elapsed: DurationStats{Mean: 3 * time.Second}},
+		{Command: "b", Elapsed: DurationStats{Mean: 2 * time.Second}},
+	}
+
+	got := newComparison(results, true)
+	if got.Fastest != "b" || got.Speedup != 1.5 || !got.Interleaved {
+		t.Errorf("newComparison() = fastest %q, speedup %v, interleaved %v", got.Fastest, got.Speedup, got.Interleaved)
+	}
+
+	results[1].StopReason = "failed"
+	if got := newComparison(results, false); got.Fastest != "" || got.Speedup != 0 {
+		t.Errorf("newComparison() with a failed benchmark = fastest %q, speedup %v", got.Fastest, got.Speedup)
+	}
+}
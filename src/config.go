@@ -0,0 +1,369 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/alecthomas/kong"
+)
+
+var (
+	errConfigSyntax       = errors.New("invalid config")
+	errConfigUnknownKey   = errors.New("unknown config key")
+	errConfigUnknownTable = errors.New("unknown config table")
+	errUnknownProfile     = errors.New("no such profile in the config file")
+	errConfigMatch        = errors.New("invalid match pattern")
+)
+
+// mapTables maps the config tables that hold the value of a map flag to the
+// flag's name.
+//
+//nolint:gochecknoglobals // The table is fixed and read-only.
+var mapTables = map[string]string{"theme": "theme", "specifiers": "specifier", "env": "env", "tag": "tag"}
+
+// configPath returns the default configuration file,
+// $XDG_CONFIG_HOME/ztime/config.toml or ~/.config/ztime/config.toml.
+func configPath() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "ztime", "config.toml")
+	}
+
+	return "~/.config/ztime/config.toml"
+}
+
+// configResolver supplies flag defaults from a config file. Keys are flag
+// names, so "jsonl-interval = \"500ms\"" sets the default of
+// --jsonl-interval. A "[profile.NAME]" table holds a bundle of defaults
+// that --profile NAME applies over the top-level ones, a "[command.NAME]"
+// table holds defaults for one command that apply over the profile's, and
+// the "[theme]", "[specifiers]", "[env]", and "[tag]" tables set those map
+// flags; flags given on the command line take precedence.
+type configResolver struct {
+	files    *configFiles
+	values   map[string]any
+	profiles map[string]map[string]any
+	commands []commandDefaults
+}
+
+// commandDefaults is a "[command.NAME]" table. It applies to commands whose
+// program has the base name NAME or, if the table has a "match" key, whose
+// command line matches that regular expression.
+type commandDefaults struct {
+	name   string
+	match  *regexp.Regexp
+	values map[string]any
+}
+
+// applies reports whether the defaults are for the command args.
+func (d commandDefaults) applies(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	if d.match != nil {
+		return d.match.MatchString(strings.Join(args, " "))
+	}
+
+	return filepath.Base(args[0]) == d.name
+}
+
+// configFiles loads config files for kong and keeps them, in load order,
+// so that the profile selected in one file applies to all of them and an
+// unknown --profile can be reported.
+type configFiles struct {
+	loaded []*configResolver
+}
+
+// load is the kong.ConfigurationLoader for config files.
+func (f *configFiles) load(r io.Reader) (kong.Resolver, error) {
+	tables, err := parseConfig(r)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &configResolver{files: f, values: flagValues(tables[0].values), profiles: make(map[string]map[string]any)}
+
+	for _, t := range tables[1:] {
+		// The [theme], [specifiers], [env], and [tag] tables are the values
+		// of those map flags.
+		if flag, ok := mapTables[t.name]; ok {
+			c.values[flag] = stringValues(t.values)
+
+			continue
+		}
+
+		if name, ok := strings.CutPrefix(t.name, "command."); ok && name != "" {
+			d, err := newCommandDefaults(name, flagValues(t.values))
+			if err != nil {
+				return nil, err
+			}
+
+			c.commands = append(c.commands, d)
+
+			continue
+		}
+
+		name, ok := strings.CutPrefix(t.name, "profile.")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%w: [%s]", errConfigUnknownTable, t.name)
+		}
+
+		c.profiles[name] = flagValues(t.values)
+	}
+
+	f.loaded = append(f.loaded, c)
+
+	return c, nil
+}
+
+// newCommandDefaults reads a "[command.NAME]" table, taking its "match"
+// key out of the flag values.
+func newCommandDefaults(name string, values map[string]any) (commandDefaults, error) {
+	d := commandDefaults{name: name, values: values}
+
+	if pattern, ok := values["match"]; ok {
+		delete(values, "match")
+
+		s, _ := pattern.(string)
+
+		re, err := regexp.Compile(s)
+		if err != nil || s == "" {
+			return d, fmt.Errorf("%w in [command.%s]: %q", errConfigMatch, name, s)
+		}
+
+		d.match = re
+	}
+
+	return d, nil
+}
+
+// checkProfile reports an error if profile is set but no config file
+// defines it.
+func (f *configFiles) checkProfile(profile string) error {
+	if profile == "" {
+		return nil
+	}
+
+	for _, c := range f.loaded {
+		if _, ok := c.profiles[profile]; ok {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", errUnknownProfile, profile)
+}
+
+// selectedProfile returns the value of --profile from the command line or,
+// failing that, from the last config file that sets it. Kong does not
+// expose values it resolved from config files until all flags are
+// resolved, so the files are consulted directly.
+func (f *configFiles) selectedProfile(ctx *kong.Context) string {
+	for _, flag := range ctx.Flags() {
+		if flag.Name == "profile" {
+			if name, _ := ctx.FlagValue(flag).(string); name != "" {
+				return name
+			}
+		}
+	}
+
+	for _, c := range slices.Backward(f.loaded) {
+		if name, ok := c.values["profile"].(string); ok {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// Validate rejects keys that do not name a flag, so typos are not silently
+// ignored.
+func (c *configResolver) Validate(app *kong.Application) error {
+	flags := make(map[string]bool)
+
+	var walk func(node *kong.Node)
+	walk = func(node *kong.Node) {
+		for _, flag := range node.Flags {
+			flags[flag.Name] = true
+		}
+
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(app.Node)
+
+	var unknown []string
+
+	for key := range c.values {
+		if !flags[key] {
+			unknown = append(unknown, key)
+		}
+	}
+
+	for name, profile := range c.profiles {
+		for key := range profile {
+			if !flags[key] {
+				unknown = append(unknown, "profile."+name+"."+key)
+			}
+		}
+	}
+
+	for _, d := range c.commands {
+		for key := range d.values {
+			if !flags[key] {
+				unknown = append(unknown, "command."+d.name+"."+key)
+			}
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+
+		return fmt.Errorf("%w: %s", errConfigUnknownKey, strings.Join(unknown, ", "))
+	}
+
+	return nil
+}
+
+// Resolve returns the configured value of flag from the first command
+// table that applies to the command, the selected profile, or the top
+// level, or nil if it is not set.
+func (c *configResolver) Resolve(ctx *kong.Context, _ *kong.Path, flag *kong.Flag) (any, error) {
+	if len(c.commands) > 0 {
+		args := commandArgs(ctx)
+
+		for _, d := range c.commands {
+			if value, ok := d.values[flag.Name]; ok && d.applies(args) {
+				return value, nil
+			}
+		}
+	}
+
+	if profile, ok := c.profiles[c.files.selectedProfile(ctx)]; ok {
+		if value, ok := profile[flag.Name]; ok {
+			return value, nil
+		}
+	}
+
+	return c.values[flag.Name], nil
+}
+
+// commandArgs returns the command being timed, as parsed so far.
+func commandArgs(ctx *kong.Context) []string {
+	for _, path := range ctx.Path {
+		if path.Positional != nil && path.Positional.Name == "command" {
+			args, _ := ctx.Value(path).Interface().([]string)
+
+			return args
+		}
+	}
+
+	return nil
+}
+
+// configTable is a "[name]" table of a config file with its key/value
+// pairs. Keys before the first table header belong to a table named "".
+type configTable struct {
+	name   string
+	values map[string]any
+}
+
+// parseConfig reads a TOML file into ztime's tables. Each table under the
+// top level is one, except that a table holding nothing but other tables,
+// such as the [profile] of [profile.fast], stands for those instead, named
+// by their dotted path. Tables nested in one that holds keys, inline or
+// not, are values of that table. Tables are returned in file order,
+// starting with the top-level table "".
+func parseConfig(r io.Reader) ([]configTable, error) {
+	var doc map[string]any
+
+	md, err := toml.NewDecoder(r).Decode(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errConfigSyntax, err)
+	}
+
+	top := configTable{values: make(map[string]any)}
+
+	var tables []configTable
+
+	for key, value := range doc {
+		if table, ok := value.(map[string]any); ok {
+			tables = appendConfigTables(tables, key, table)
+		} else {
+			top.values[key] = value
+		}
+	}
+
+	// Order the tables by where each first appears in the file.
+	order := make(map[string]int)
+
+	for i, key := range md.Keys() {
+		for n := 1; n <= len(key); n++ {
+			if name := strings.Join(key[:n], "."); order[name] == 0 {
+				order[name] = i + 1
+			}
+		}
+	}
+
+	slices.SortStableFunc(tables, func(a, b configTable) int { return cmp.Compare(order[a.name], order[b.name]) })
+
+	return append([]configTable{top}, tables...), nil
+}
+
+// appendConfigTables appends the table called name to tables, or the
+// tables nested in it if it holds nothing else.
+func appendConfigTables(tables []configTable, name string, table map[string]any) []configTable {
+	nested := len(table) > 0
+
+	for _, value := range table {
+		if _, ok := value.(map[string]any); !ok {
+			nested = false
+		}
+	}
+
+	if nested {
+		for key, value := range table {
+			tables = appendConfigTables(tables, name+"."+key, value.(map[string]any)) //nolint:forcetypeassert // Checked above.
+		}
+
+		return tables
+	}
+
+	return append(tables, configTable{name: name, values: table})
+}
+
+// flagValues returns the keys of a config table as flag names. Underscores
+// are read as dashes, so both "jsonl_interval" and "jsonl-interval" work.
+// Tables are the values of map flags, whose values are all strings.
+func flagValues(values map[string]any) map[string]any {
+	flags := make(map[string]any, len(values))
+	for key, value := range values {
+		if table, ok := value.(map[string]any); ok {
+			value = stringValues(table)
+		}
+
+		flags[strings.ReplaceAll(key, "_", "-")] = value
+	}
+
+	return flags
+}
+
+// stringValues returns table with every value as a string, so that
+// "user = 33" sets a map flag too.
+func stringValues(table map[string]any) map[string]any {
+	values := make(map[string]any, len(table))
+	for key, value := range table {
+		values[key] = fmt.Sprint(value)
+	}
+
+	return values
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kong"
+)
+
+func TestParseConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]any
+		wantErr error
+	}{
+		{
+			name:  "scalars",
+			input: "# defaults\njson = true\nruns = 1_000\ntarget_cv = 0.1\ntimefmt = \"%J\\t%E\" # tab\n\nstdin = '/tmp/in'\n",
+			want: map[string]any{
+				"json":      true,
+				"runs":      int64(1000),
+				"target_cv": 0.1,
+				"timefmt":   "%J\t%E",
+				"stdin":     "/tmp/in",
+			},
+		},
+		{
+			name:  "array",
+			input: `runtime-stats = ["go", "python"]`,
+			want:  map[string]any{"runtime-stats": []any{"go", "python"}},
+		},
+		{name: "empty array", input: "exporter = []", want: map[string]any{"exporter": []any{}}},
+		{name: "bare string", input: "timefmt = %E", wantErr: errConfigSyntax},
+		{name: "unterminated string", input: `timefmt = "%E`, wantErr: errConfigSyntax},
+		{name: "unterminated array", input: "runtime-stats = [\"go\"", wantErr: errConfigSyntax},
+		{name: "trailing text", input: "json = true false", wantErr: errConfigSyntax},
+		{name: "no value", input: "json", wantErr: errConfigSyntax},
+		{name: "duplicate", input: "runs = 1\nruns = 2", wantErr: errConfigSyntax},
+		{name: "duplicate table", input: "[a]\n[a]", wantErr: errConfigSyntax},
+		{name: "malformed table", input: "[a", wantErr: errConfigSyntax},
+		{
+			name:  "multi-line array",
+			input: "runtime-stats = [\n  \"go\",\n  \"python\", # trailing comma\n]\n",
+			want:  map[string]any{"runtime-stats": []any{"go", "python"}},
+		},
+		{
+			name:    "unknown escape",
+			input:   `timefmt = "%E\u00e9\x"` + "\n",
+			wantErr: errConfigSyntax, // \x is not a TOML escape
+		},
+		{
+			name:  "literal strings",
+			input: "timefmt = \"%E\\u00e9\"\nstdin = '''C:\\in'''\n",
+			want:  map[string]any{"timefmt": "%E\u00e9", "stdin": `C:\in`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseConfig(strings.NewReader(tt.input))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("parseConfig() error = %v, want %v", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseConfig() error = %v", err)
+			}
+
+			if len(got) != 1 || !reflect.DeepEqual(got[0].values, tt.want) {
+				t.Errorf("parseConfig() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConfigTables(t *testing.T) {
+	t.Parallel()
+
+	input := "runs = 10\n\n[build] # comment\ncommand = \"make\"\nruns = 3\n\n[\"unit tests\"]\ncommand = \"go test\"\n" +
+		"[profile.ci]\nenv.CI = \"1\"\nquiet = true\n\n[profile]\nfast.runs = 1\n"
+
+	got, err := parseConfig(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseConfig() error = %v", err)
+	}
+
+	want := []configTable{
+		{name: "", values: map[string]any{"runs": int64(10)}},
+		{name: "build", values: map[string]any{"command": "make", "runs": int64(3)}},
+		{name: "unit tests", values: map[string]any{"command": "go test"}},
+		{name: "profile.ci", values: map[string]any{"env": map[string]any{"CI": "1"}, "quiet": true}},
+		{name: "profile.fast", values: map[string]any{"runs": int64(1)}},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseConfig() = %#v, want %#v", got, want)
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	config := "timefmt = \"%E\"\njsonl_interval = \"250ms\"\nruntime-stats = [\"go\"]\nruns = 3\n"
+
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	parse := func(args ...string) (*commandLine, error) {
+		var cli commandLine
+
+		parser, err := kong.New(&cli, kong.Configuration((&configFiles{}).load, path))
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = parser.Parse(args)
+
+		return &cli, err
+	}
+
+	cli, err := parse("run", "--runs", "5", "true")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	run := cli.Run
+	if run.TimeFmt != "%E" || run.JSONLInterval != 250*time.Millisecond || !reflect.DeepEqual(run.RuntimeStats, []string{"go"}) {
+		t.Errorf("config defaults not applied: timefmt %q, jsonl-interval %s, runtime-stats %v", run.TimeFmt, run.JSONLInterval, run.RuntimeStats)
+	}
+
+	if run.Runs != 5 {
+		t.Errorf("Runs = %d, want the command-line value 5", run.Runs)
+	}
+
+	if err := os.WriteFile(path, []byte("tmefmt = \"%E\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := parse("run", "true"); !errors.Is(err, errConfigUnknownKey) {
+		t.Errorf("Parse() error = %v, want %v", err, errConfigUnknownKey)
+	}
+}
+
+func TestConfigProfiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	config := "timefmt = \"%E\"\nruns = 2\n\n[profile.bench]\nruns = 10\n\n[profile.ci]\njson = true\n"
+
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	selects := filepath.Join(dir, "selects.toml")
+	if err := os.WriteFile(selects, []byte("profile = \"bench\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantRuns int
+		wantJSON bool
+		wantErr  error
+	}{
+		{name: "no profile", args: []string{"run", "true"}, wantRuns: 2},
+		{name: "profile", args: []string{"--profile", "bench", "run", "true"}, wantRuns: 10},
+		{name: "other profile", args: []string{"--profile", "ci", "run", "true"}, wantRuns: 2, wantJSON: true},
+		{name: "flag over profile", args: []string{"--profile", "bench", "run", "--runs", "4", "true"}, wantRuns: 4},
+		{name: "selected by another file", args: []string{"--config", selects, "run", "true"}, wantRuns: 10},
+		{name: "unknown profile", args: []string{"--profile", "nightly", "run", "true"}, wantErr: errUnknownProfile},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var cli commandLine
+
+			configs := &configFiles{}
+
+			parser, err := kong.New(&cli, kong.Configuration(configs.load, path))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := parser.Parse(tt.args); err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			if err := configs.checkProfile(cli.Profile); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("checkProfile() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			if cli.Run.Runs != tt.wantRuns || cli.Run.JSON != tt.wantJSON || cli.Run.TimeFmt != "%E" {
+				t.Errorf("runs %d, json %v, timefmt %q; want %d, %v, %q", cli.Run.Runs, cli.Run.JSON, cli.Run.TimeFmt, tt.wantRuns, tt.wantJSON, "%E")
+			}
+		})
+	}
+}
+
+func TestConfigUnknownTable(t *testing.T) {
+	t.Parallel()
+
+	_, err := (&configFiles{}).load(strings.NewReader("[bench]\nruns = 10\n"))
+	if !errors.Is(err, errConfigUnknownTable) {
+		t.Errorf("load() error = %v, want %v", err, errConfigUnknownTable)
+	}
+}
+
+func TestConfigEnv(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	config := "env = { CARGO_INCREMENTAL = \"0\" }\n\n[profile.ci.env]\nCI = true\n\n[profile.ci]\nruns = 1\n"
+
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		args []string
+		want map[string]string
+	}{
+		{args: []string{"run", "true"}, want: map[string]string{"CARGO_INCREMENTAL": "0"}},
+		{args: []string{"--profile", "ci", "run", "true"}, want: map[string]string{"CI": "true"}},
+	} {
+		var cli commandLine
+
+		parser, err := kong.New(&cli, kong.Configuration((&configFiles{}).load, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := parser.Parse(tt.args); err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.args, err)
+		}
+
+		if !reflect.DeepEqual(cli.Run.Env, tt.want) {
+			t.Errorf("Parse(%q): env = %v, want %v", tt.args, cli.Run.Env, tt.want)
+		}
+	}
+}
+
+func TestConfigTheme(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("[theme]\nuser = 160\ntotal = \"bold #ff0000\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var cli commandLine
+
+	parser, err := kong.New(&cli, kong.Configuration((&configFiles{}).load, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := parser.Parse([]string{"run", "true"}); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := map[string]string{"user": "160", "total": "bold #ff0000"}
+	if !reflect.DeepEqual(cli.Theme, want) {
+		t.Errorf("Theme = %v, want %v", cli.Theme, want)
+	}
+}
+
+func TestConfigSpecifiers(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("[specifiers]\nmem = \"%M KiB\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var cli commandLine
+
+	parser, err := kong.New(&cli, kong.Configuration((&configFiles{}).load, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := parser.Parse([]string{"run", "true"}); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := map[string]string{"mem": "%M KiB"}
+	if !reflect.DeepEqual(cli.Run.Specifier, want) {
+		t.Errorf("Specifier = %v, want %v", cli.Run.Specifier, want)
+	}
+}
+
+func TestConfigCommandDefaults(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	config := "timefmt = \"%E\"\n\n[command.cargo-build]\nmatch = \"^cargo (build|test)\"\nall = true\ntimefmt = \"%J %M\"\n\n" +
+		"[command.cargo]\ntimefmt = \"%J\"\n\n[command.make]\nlong = true\n"
+
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args    []string
+		timeFmt string
+		all     bool
+		long    bool
+	}{
+		{[]string{"run", "cargo", "build"}, "%J %M", true, false},
+		{[]string{"run", "cargo", "fmt"}, "%J", false, false},
+		{[]string{"run", "/usr/bin/make", "-j8"}, "%E", false, true},
+		{[]string{"run", "--timefmt", "%U", "cargo", "test"}, "%U", true, false},
+		{[]string{"run", "true"}, "%E", false, false},
+	}
+
+	for _, tt := range tests {
+		var cli commandLine
+
+		parser, err := kong.New(&cli, kong.Configuration((&configFiles{}).load, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := parser.Parse(tt.args); err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.args, err)
+		}
+
+		if run := cli.Run; run.TimeFmt != tt.timeFmt || run.All != tt.all || run.Long != tt.long {
+			t.Errorf("Parse(%q) = timefmt %q, all %v, long %v; want %q, %v, %v", tt.args, run.TimeFmt, run.All, run.Long, tt.timeFmt, tt.all, tt.long)
+		}
+	}
+}
+
+func TestConfigCommandDefaultsInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, config := range []string{"[command.x]\nmatch = \"(\"\n", "[command.x]\nmatch = 3\n"} {
+		if _, err := (&configFiles{}).load(strings.NewReader(config)); !errors.Is(err, errConfigMatch) {
+			t.Errorf("load(%q) error = %v, want %v", config, err, errConfigMatch)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("[command.x]\ntimefmat = \"%E\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var cli commandLine
+
+	parser, err := kong.New(&cli, kong.Configuration((&configFiles{}).load, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := parser.Parse([]string{"run", "true"}); !errors.Is(err, errConfigUnknownKey) {
+		t.Errorf("Parse() error = %v, want %v", err, errConfigUnknownKey)
+	}
+}
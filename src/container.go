@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	errContainerNotFound = errors.New("container not found")
+	errContainerStats    = errors.New("container stats API")
+)
+
+// containerRetryInterval is how often the stats collector asks for a
+// container that the runtime has not created yet.
+const containerRetryInterval = 50 * time.Millisecond
+
+// ContainerStats is what the container runtime's stats API reported about
+// a --container run. The runtime samples about once a second, so the last
+// second of a run may be missing from the CPU and throttling figures.
+type ContainerStats struct {
+	Image            string        `json:"image"`
+	Runtime          string        `json:"runtime"`                                  // docker or podman
+	Samples          int           `json:"samples" unit:"count"`                     // stats samples received
+	MemoryPeak       int64         `json:"memory_peak,omitempty" unit:"bytes"`       // cgroup memory usage, including page cache
+	CPUUser          time.Duration `json:"cpu_user,omitempty"`                       // user CPU time of the container's processes
+	CPUSystem        time.Duration `json:"cpu_system,omitempty"`                     // system CPU time of the container's processes
+	Periods          int64         `json:"periods,omitempty" unit:"count"`           // CFS enforcement periods under a CPU limit
+	ThrottledPeriods int64         `json:"throttled_periods,omitempty" unit:"count"` // periods in which the container hit its CPU limit
+	ThrottledTime    time.Duration `json:"throttled_time,omitempty"`                 // total time the container was held back
+	Error            string        `json:"error,omitempty"`                          // why no stats could be read
+}
+
+// containerSample is the part of a stats API sample that ztime uses. It is
+// the same for Docker and Podman's Docker-compatible API.
+type containerSample struct {
+	CPUStats struct {
+		CPUUsage struct {
+			Kernel int64 `json:"usage_in_kernelmode"`
+			User   int64 `json:"usage_in_usermode"`
+		} `json:"cpu_usage"`
+		Throttling struct {
+			Periods          int64 `json:"periods"`
+			ThrottledPeriods int64 `json:"throttled_periods"`
+			ThrottledTime    int64 `json:"throttled_time"`
+		} `json:"throttling_data"`
+	} `json:"cpu_stats"`
+	MemoryStats struct {
+		Usage    int64 `json:"usage"`
+		MaxUsage int64 `json:"max_usage"` // cgroup v1 only
+	} `json:"memory_stats"`
+}
+
+// runContainer runs args in a new --container container with the runtime's
+// CLI, which relays stdin, output, signals, and the exit status, while
+// reading the container's stats from the runtime's API. The times and
+// resource usage in the metrics are those of the CLI; the container's own
+// are in the Container field.
+func (c *runCmd) runContainer(args []string, opts runOptions) (Metrics, error) {
+	name := "ztime-" + strings.ToLower(rand.Text())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stats := make(chan ContainerStats, 1)
+
+	go func() {
+		stats <- collectContainerStats(ctx, containerClient(c.ContainerRuntime), name)
+	}()
+
+	m, err := runCommand(containerArgs(c.ContainerRuntime, name, c.Container, c.ContainerArg, opts.stdinPath != "" || !isTerminal(os.Stdin), args), opts)
+
+	cancel()
+
+	s := <-stats
+	s.Image = c.Container
+	s.Runtime = c.ContainerRuntime
+
+	m.Command = strings.Join(args, " ")
+	m.Argv = args
+	m.Container = &s
+
+	return m, err
+}
+
+// containerArgs is the command line that runs args in a container named
+// name, removed when it exits. Stdin is attached if interactive is set.
+func containerArgs(runtime, name, image string, extra []string, interactive bool, args []string) []string {
+	words := []string{runtime, "run", "--rm", "--name", name}
+	if interactive {
+		words = append(words, "--interactive")
+	}
+
+	words = append(words, extra...)
+	words = append(words, image)
+
+	return append(words, args...)
+}
+
+// containerClient returns an HTTP client for the runtime's API socket,
+// found from DOCKER_HOST or CONTAINER_HOST or in its default place.
+func containerClient(runtime string) *http.Client {
+	socket := "/var/run/docker.sock"
+	env := "DOCKER_HOST"
+
+	if runtime == "podman" {
+		env = "CONTAINER_HOST"
+		socket = "/run/podman/podman.sock"
+
+		if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" && os.Geteuid() != 0 {
+			socket = filepath.Join(dir, "podman", "podman.sock")
+		}
+	}
+
+	if host, ok := strings.CutPrefix(os.Getenv(env), "unix://"); ok {
+		socket = host
+	}
+
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}
+}
+
+// collectContainerStats streams the stats of the container named name
+// until ctx is done, waiting for the runtime to create it first, and
+// returns the peak memory usage and the latest CPU figures.
+func collectContainerStats(ctx context.Context, client *http.Client, name string) ContainerStats {
+	var s ContainerStats
+
+	for {
+		err := streamContainerStats(ctx, client, name, &s)
+		if err == nil || ctx.Err() != nil {
+			return s
+		}
+
+		if !errors.Is(err, errContainerNotFound) {
+			s.Error = err.Error()
+
+			return s
+		}
+
+		select {
+		case <-ctx.Done():
+			return s
+		case <-time.After(containerRetryInterval):
+		}
+	}
+}
+
+// streamContainerStats reads stats samples of the container into s until
+// the stream ends or ctx is done.
+func streamContainerStats(ctx context.Context, client *http.Client, name string, s *ContainerStats) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://runtime/containers/"+url.PathEscape(name)+"/stats?stream=true", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+
+		return fmt.Errorf("%w: %w", errContainerStats, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errContainerNotFound
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("%w: %s", errContainerStats, resp.Status)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+
+	for {
+		var sample containerSample
+		if err := decoder.Decode(&sample); err != nil {
+			return nil //nolint:nilerr // The stream ends when the container exits or ctx is done.
+		}
+
+		s.add(sample)
+	}
+}
+
+// add takes a sample into the stats. Samples of a stopped container, with
+// no memory usage, are ignored.
+func (s *ContainerStats) add(sample containerSample) {
+	if sample.MemoryStats.Usage == 0 {
+		return
+	}
+
+	s.Samples++
+	s.MemoryPeak = max(s.MemoryPeak, sample.MemoryStats.Usage, sample.MemoryStats.MaxUsage)
+
+	cpu := sample.CPUStats
+	s.CPUUser = time.Duration(cpu.CPUUsage.User)
+	s.CPUSystem = time.Duration(cpu.CPUUsage.Kernel)
+	s.Periods = cpu.Throttling.Periods
+	s.ThrottledPeriods = cpu.Throttling.ThrottledPeriods
+	s.ThrottledTime = time.Duration(cpu.Throttling.ThrottledTime)
+}
+
+// containerNote summarizes the container's stats, or returns "" for a run
+// outside a container.
+func containerNote(s *ContainerStats) string {
+	switch {
+	case s == nil:
+		return ""
+	case s.Error != "":
+		return "no container stats: " + s.Error
+	case s.Samples == 0:
+		return "no container stats: the run ended before the runtime's first sample"
+	}
+
+	note := fmt.Sprintf("container %s peak, %.2fs user %.2fs system", humanBytes(s.MemoryPeak), s.CPUUser.Seconds(), s.CPUSystem.Seconds())
+	if s.ThrottledPeriods > 0 {
+		note += fmt.Sprintf(", throttled in %d of %d periods (%.3fs)", s.ThrottledPeriods, s.Periods, s.ThrottledTime.Seconds())
+	}
+
+	return note
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestContainerArgs(t *testing.T) {
+	t.Parallel()
+
+	got := containerArgs("podman", "ztime-x", "alpine", []string{"--volume=.:/src"}, true, []string{"make", "-j8"})
+	want := []string{"podman", "run", "--rm", "--name", "ztime-x", "--interactive", "--volume=.:/src", "alpine", "make", "-j8"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("containerArgs() = %v, want %v", got, want)
+	}
+}
+
+func TestCollectContainerStats(t *testing.T) {
+	// The fake runtime creates the container on the third request and then
+	// streams two samples and one of the stopped container.
+	var requests atomic.Int32
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/ztime-x/stats" || requests.Add(1) < 3 {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		for _, usage := range []int{300, 200, 0} {
+			fmt.Fprintf(w, `{"cpu_stats":{"cpu_usage":{"usage_in_usermode":%d,"usage_in_kernelmode":1000},"throttling_data":{"periods":10,"throttled_periods":%d,"throttled_time":5000}},"memory_stats":{"usage":%d}}`+"\n", usage*1000, usage/100, usage)
+		}
+	}))
+
+	socket := filepath.Join(t.TempDir(), "api.sock")
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets not available: %v", err)
+	}
+
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+
+	t.Setenv("DOCKER_HOST", "unix://"+socket)
+
+	s := collectContainerStats(context.Background(), containerClient("docker"), "ztime-x")
+
+	want := ContainerStats{Samples: 2, MemoryPeak: 300, CPUUser: 200 * time.Microsecond, CPUSystem: time.Microsecond, Periods: 10, ThrottledPeriods: 2, ThrottledTime: 5 * time.Microsecond}
+	if s != want {
+		t.Errorf("collectContainerStats() = %+v, want %+v", s, want)
+	}
+}
+
+func TestCollectContainerStatsUnreachable(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "unix://"+filepath.Join(t.TempDir(), "missing.sock"))
+
+	s := collectContainerStats(context.Background(), containerClient("docker"), "ztime-x")
+	if s.Samples != 0 || !strings.Contains(s.Error, "missing.sock") {
+		t.Errorf("collectContainerStats() = %+v, want an error naming the socket", s)
+	}
+
+	if note := containerNote(&s); !strings.HasPrefix(note, "no container stats: ") {
+		t.Errorf("containerNote() = %q, want the error", note)
+	}
+}
+
+func TestContainerNote(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		stats *ContainerStats
+		want  string
+	}{
+		{"local run", nil, ""},
+		{"no samples", &ContainerStats{}, "no container stats: the run ended before the runtime's first sample"},
+		{"unthrottled", &ContainerStats{Samples: 1, MemoryPeak: 64 << 20, CPUUser: 1500 * time.Millisecond, CPUSystem: 250 * time.Millisecond}, "container 64.0 MiB peak, 1.50s user 0.25s system"},
+		{"throttled", &ContainerStats{Samples: 1, MemoryPeak: 64 << 20, Periods: 40, ThrottledPeriods: 12, ThrottledTime: 300 * time.Millisecond}, "container 64.0 MiB peak, 0.00s user 0.00s system, throttled in 12 of 40 periods (0.300s)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := containerNote(tt.stats); got != tt.want {
+				t.Errorf("containerNote() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// debugLog writes what ztime itself does with --verbose, such as starting
+// the command, forwarding signals, and taking samples. A nil log discards
+// everything, so callers need not check for --verbose.
+type debugLog struct {
+	start time.Time
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newDebugLog(w io.Writer) *debugLog {
+	return &debugLog{start: time.Now(), w: w}
+}
+
+// printf writes a line stamped with the time since ztime started.
+func (l *debugLog) printf(format string, args ...any) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	fmt.Fprintf(l.w, "ztime: debug: +%.3fs %s\n", time.Since(l.start).Seconds(), fmt.Sprintf(format, args...))
+}
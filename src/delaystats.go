@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var errDelayStatsUnsupported = errors.New("--delay-stats is not supported")
+
+// DelayStats is what the kernel's delay accounting recorded about the
+// command's main thread, queried over taskstats with --delay-stats after it
+// exited and before it was reaped. Each delay is time the thread could not
+// run for that reason, and each count the number of times it happened.
+type DelayStats struct {
+	CPU          time.Duration `json:"cpu"` // runnable but waiting for a CPU
+	CPUCount     int64         `json:"cpu_count" unit:"count"`
+	BlockIO      time.Duration `json:"block_io"` // waiting for block I/O to complete
+	BlockIOCount int64         `json:"block_io_count" unit:"count"`
+	Swap         time.Duration `json:"swap"` // waiting for pages to be swapped in
+	SwapCount    int64         `json:"swap_count" unit:"count"`
+	Reclaim      time.Duration `json:"reclaim"` // waiting for memory to be reclaimed
+	ReclaimCount int64         `json:"reclaim_count" unit:"count"`
+	Error        string        `json:"error,omitempty"` // why the stats could not be read
+}
+
+// delayNote describes where the command's wall time went, or returns ""
+// without --delay-stats.
+func delayNote(m Metrics) string {
+	s := m.DelayStats
+
+	switch {
+	case s == nil:
+		return ""
+	case s.Error != "":
+		return "no delay stats: " + s.Error
+	}
+
+	note := fmt.Sprintf("delays: cpu %s (%d), block I/O %s (%d), swap %s (%d), reclaim %s (%d)",
+		humanDuration(s.CPU), s.CPUCount, humanDuration(s.BlockIO), s.BlockIOCount,
+		humanDuration(s.Swap), s.SwapCount, humanDuration(s.Reclaim), s.ReclaimCount)
+
+	if m.ElapsedTime > 0 {
+		total := s.CPU + s.BlockIO + s.Swap + s.Reclaim
+		note += fmt.Sprintf("; %.0f%% of elapsed", float64(total)/float64(m.ElapsedTime)*100)
+	}
+
+	return note
+}
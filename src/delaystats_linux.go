@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// delayAcctSysctl turns delay accounting on and off on kernels since 5.14,
+// where it is off by default.
+const delayAcctSysctl = "/proc/sys/kernel/task_delayacct"
+
+// delayStatsSupported checks that delay accounting is on, since only the
+// CPU delay is recorded otherwise and it cannot be turned on for a process
+// that is already running.
+func delayStatsSupported() error {
+	data, err := os.ReadFile(delayAcctSysctl)
+	if err == nil && bytes.Equal(bytes.TrimSpace(data), []byte("0")) {
+		return fmt.Errorf("%w: delay accounting is off; turn it on with sysctl kernel.task_delayacct=1", errDelayStatsUnsupported)
+	}
+
+	return nil
+}
+
+// waitDelayStats blocks until pid exits and queries its delay accounting
+// while it is a zombie. It leaves the process for the caller to reap.
+func waitDelayStats(pid int) *DelayStats {
+	if err := waitZombie(pid); err != nil {
+		return &DelayStats{Error: err.Error()}
+	}
+
+	s, err := queryTaskstats(pid)
+	if err != nil {
+		return &DelayStats{Error: err.Error()}
+	}
+
+	return &s
+}
+
+// queryTaskstats asks the taskstats generic netlink family for the
+// statistics of pid. The kernel only answers processes with CAP_NET_ADMIN.
+func queryTaskstats(pid int) (DelayStats, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, unix.NETLINK_GENERIC)
+	if err != nil {
+		return DelayStats{}, fmt.Errorf("opening taskstats: %w", err)
+	}
+	defer unix.Close(fd)
+
+	reply, err := genlRequest(fd, unix.GENL_ID_CTRL, unix.CTRL_CMD_GETFAMILY, unix.CTRL_ATTR_FAMILY_NAME, []byte("TASKSTATS\x00"))
+	if err != nil {
+		return DelayStats{}, fmt.Errorf("finding taskstats: %w", err)
+	}
+
+	id, ok := netlinkAttr(reply, unix.CTRL_ATTR_FAMILY_ID)
+	if !ok || len(id) < 2 {
+		return DelayStats{}, fmt.Errorf("finding taskstats: %w", unix.ENOENT)
+	}
+
+	reply, err = genlRequest(fd, binary.NativeEndian.Uint16(id), unix.TASKSTATS_CMD_GET,
+		unix.TASKSTATS_CMD_ATTR_PID, binary.NativeEndian.AppendUint32(nil, uint32(pid))) //nolint:gosec // PIDs are positive.
+	if err != nil {
+		return DelayStats{}, fmt.Errorf("querying taskstats: %w", err)
+	}
+
+	return parseTaskstatsReply(reply)
+}
+
+// genlRequest sends a generic netlink command with one attribute and
+// returns the attributes of the reply.
+func genlRequest(fd int, family uint16, cmd uint8, attrType uint16, attr []byte) ([]byte, error) {
+	req := make([]byte, nlmsgHeaderLen+unix.GENL_HDRLEN, nlmsgHeaderLen+unix.GENL_HDRLEN+unix.SizeofRtAttr+len(attr)+unix.NLA_ALIGNTO)
+	binary.NativeEndian.PutUint16(req[4:], family)
+	binary.NativeEndian.PutUint16(req[6:], unix.NLM_F_REQUEST)
+	req[nlmsgHeaderLen] = cmd
+	req[nlmsgHeaderLen+1] = 1 // version
+	req = appendNetlinkAttr(req, attrType, attr)
+	binary.NativeEndian.PutUint32(req[0:], uint32(len(req))) //nolint:gosec // The request is small.
+
+	if err := unix.Sendto(fd, req, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, sockDiagBufferSz)
+
+	n, _, err := unix.Recvfrom(fd, buf, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseGenlReply(buf[:n])
+}
+
+// parseGenlReply returns the attributes of a generic netlink reply, or the
+// error the kernel sent instead.
+func parseGenlReply(data []byte) ([]byte, error) {
+	if len(data) < nlmsgHeaderLen {
+		return nil, unix.EBADMSG
+	}
+
+	size := int(binary.NativeEndian.Uint32(data[0:]))
+	if size < nlmsgHeaderLen || size > len(data) {
+		return nil, unix.EBADMSG
+	}
+
+	body := data[nlmsgHeaderLen:size]
+
+	if binary.NativeEndian.Uint16(data[4:]) == unix.NLMSG_ERROR {
+		if len(body) >= 4 {
+			if errno := int32(binary.NativeEndian.Uint32(body)); errno != 0 { //nolint:gosec // Reinterprets the C int.
+				return nil, unix.Errno(-errno)
+			}
+		}
+
+		return nil, unix.EBADMSG
+	}
+
+	if len(body) < unix.GENL_HDRLEN {
+		return nil, unix.EBADMSG
+	}
+
+	return body[unix.GENL_HDRLEN:], nil
+}
+
+// appendNetlinkAttr appends an attribute, padded to the netlink alignment.
+func appendNetlinkAttr(b []byte, kind uint16, value []byte) []byte {
+	b = binary.NativeEndian.AppendUint16(b, uint16(unix.SizeofRtAttr+len(value))) //nolint:gosec // Attributes are small.
+	b = binary.NativeEndian.AppendUint16(b, kind)
+	b = append(b, value...)
+
+	return append(b, make([]byte, nlmAlign(len(value))-len(value))...)
+}
+
+// netlinkAttr returns the value of the first attribute of kind in attrs.
+func netlinkAttr(attrs []byte, kind uint16) ([]byte, bool) {
+	for len(attrs) >= unix.SizeofRtAttr {
+		size := int(binary.NativeEndian.Uint16(attrs[0:]))
+		if size < unix.SizeofRtAttr || size > len(attrs) {
+			break
+		}
+
+		// Nested attributes have the top bit of their type set.
+		if binary.NativeEndian.Uint16(attrs[2:])&^unix.NLA_F_NESTED == kind {
+			return attrs[unix.SizeofRtAttr:size], true
+		}
+
+		attrs = attrs[min(nlmAlign(size), len(attrs)):]
+	}
+
+	return nil, false
+}
+
+// parseTaskstatsReply reads the struct taskstats nested in a
+// TASKSTATS_TYPE_AGGR_PID attribute. Older kernels send it shorter.
+func parseTaskstatsReply(attrs []byte) (DelayStats, error) {
+	aggr, ok := netlinkAttr(attrs, unix.TASKSTATS_TYPE_AGGR_PID)
+	if !ok {
+		return DelayStats{}, fmt.Errorf("parsing taskstats: %w", unix.EBADMSG)
+	}
+
+	data, ok := netlinkAttr(aggr, unix.TASKSTATS_TYPE_STATS)
+	if !ok {
+		return DelayStats{}, fmt.Errorf("parsing taskstats: %w", unix.EBADMSG)
+	}
+
+	var ts unix.Taskstats
+
+	full := make([]byte, unsafe.Sizeof(ts))
+	copy(full, data)
+
+	field := func(offset uintptr) int64 {
+		return int64(binary.NativeEndian.Uint64(full[offset:])) //nolint:gosec // Counters and nanoseconds fit in int64.
+	}
+
+	return DelayStats{
+		CPU:          time.Duration(field(unsafe.Offsetof(ts.Cpu_delay_total))),
+		CPUCount:     field(unsafe.Offsetof(ts.Cpu_count)),
+		BlockIO:      time.Duration(field(unsafe.Offsetof(ts.Blkio_delay_total))),
+		BlockIOCount: field(unsafe.Offsetof(ts.Blkio_count)),
+		Swap:         time.Duration(field(unsafe.Offsetof(ts.Swapin_delay_total))),
+		SwapCount:    field(unsafe.Offsetof(ts.Swapin_count)),
+		Reclaim:      time.Duration(field(unsafe.Offsetof(ts.Freepages_delay_total))),
+		ReclaimCount: field(unsafe.Offsetof(ts.Freepages_count)),
+	}, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestParseTaskstatsReply(t *testing.T) {
+	t.Parallel()
+
+	var ts unix.Taskstats
+
+	stats := make([]byte, unsafe.Sizeof(ts))
+	binary.NativeEndian.PutUint64(stats[unsafe.Offsetof(ts.Cpu_delay_total):], 2_000_000)
+	binary.NativeEndian.PutUint64(stats[unsafe.Offsetof(ts.Cpu_count):], 7)
+	binary.NativeEndian.PutUint64(stats[unsafe.Offsetof(ts.Blkio_delay_total):], 150_000_000)
+	binary.NativeEndian.PutUint64(stats[unsafe.Offsetof(ts.Blkio_count):], 600)
+	binary.NativeEndian.PutUint64(stats[unsafe.Offsetof(ts.Freepages_delay_total):], 3_000)
+	binary.NativeEndian.PutUint64(stats[unsafe.Offsetof(ts.Freepages_count):], 1)
+
+	aggr := appendNetlinkAttr(nil, unix.TASKSTATS_TYPE_PID, binary.NativeEndian.AppendUint32(nil, 42))
+	aggr = appendNetlinkAttr(aggr, unix.TASKSTATS_TYPE_STATS, stats)
+	reply := appendNetlinkAttr(nil, unix.TASKSTATS_TYPE_AGGR_PID|unix.NLA_F_NESTED, aggr)
+
+	got, err := parseTaskstatsReply(reply)
+	if err != nil {
+		t.Fatalf("parseTaskstatsReply() error = %v", err)
+	}
+
+	want := DelayStats{
+		CPU: 2 * time.Millisecond, CPUCount: 7, BlockIO: 150 * time.Millisecond, BlockIOCount: 600,
+		Reclaim: 3 * time.Microsecond, ReclaimCount: 1,
+	}
+	if got != want {
+		t.Errorf("parseTaskstatsReply() = %+v, want %+v", got, want)
+	}
+
+	if _, err := parseTaskstatsReply(appendNetlinkAttr(nil, unix.TASKSTATS_TYPE_NULL, nil)); !errors.Is(err, unix.EBADMSG) {
+		t.Errorf("parseTaskstatsReply() without stats error = %v, want EBADMSG", err)
+	}
+}
+
+func TestParseGenlReply(t *testing.T) {
+	t.Parallel()
+
+	msg := func(kind uint16, body []byte) []byte {
+		b := binary.NativeEndian.AppendUint32(nil, uint32(nlmsgHeaderLen+len(body))) //nolint:gosec // Test messages are small.
+		b = binary.NativeEndian.AppendUint16(b, kind)
+
+		return append(append(b, make([]byte, 10)...), body...)
+	}
+
+	attrs := appendNetlinkAttr(nil, unix.CTRL_ATTR_FAMILY_ID, []byte{0x17, 0})
+
+	got, err := parseGenlReply(msg(unix.GENL_ID_CTRL, append([]byte{unix.CTRL_CMD_NEWFAMILY, 1, 0, 0}, attrs...)))
+	if err != nil {
+		t.Fatalf("parseGenlReply() error = %v", err)
+	}
+
+	if id, ok := netlinkAttr(got, unix.CTRL_ATTR_FAMILY_ID); !ok || binary.NativeEndian.Uint16(id) != 0x17 {
+		t.Errorf("family ID = %v, %v, want 0x17", id, ok)
+	}
+
+	errno := -int32(unix.EPERM)
+	if _, err := parseGenlReply(msg(unix.NLMSG_ERROR, binary.NativeEndian.AppendUint32(nil, uint32(errno)))); !errors.Is(err, unix.EPERM) {
+		t.Errorf("parseGenlReply() of an error = %v, want EPERM", err)
+	}
+}
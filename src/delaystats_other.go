@@ -0,0 +1,16 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func delayStatsSupported() error {
+	return fmt.Errorf("%w on %s", errDelayStatsUnsupported, runtime.GOOS)
+}
+
+func waitDelayStats(int) *DelayStats {
+	return nil
+}
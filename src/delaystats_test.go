@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelayNote(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		m    Metrics
+		want string
+	}{
+		{"disabled", Metrics{}, ""},
+		{"unreadable", Metrics{DelayStats: &DelayStats{Error: "operation not permitted"}}, "no delay stats: operation not permitted"},
+		{
+			"delays",
+			Metrics{ElapsedTime: time.Second, DelayStats: &DelayStats{
+				CPU: 100 * time.Millisecond, CPUCount: 40, BlockIO: 150 * time.Millisecond, BlockIOCount: 600,
+				Reclaim: 50 * time.Millisecond, ReclaimCount: 3,
+			}},
+			"delays: cpu 100.00ms (40), block I/O 150.00ms (600), swap 0µs (0), reclaim 50.00ms (3); 30% of elapsed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := delayNote(tt.m); got != tt.want {
+				t.Errorf("delayNote() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
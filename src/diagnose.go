@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// diagnosticToolTimeout bounds each external tool run while capturing a
+// diagnostic bundle, so a wedged tool cannot hold up the report.
+const diagnosticToolTimeout = 10 * time.Second
+
+var errUnknownSignal = errors.New("unknown signal")
+
+// diagnosis captures a diagnostic bundle once a run has been going for
+// longer than --diagnose-on-slow allows.
+type diagnosis struct {
+	timer *time.Timer
+	done  chan struct{}
+	dir   string
+}
+
+// diagnoseAfter arms a capture of the running process pid after threshold,
+// taking the perf sample through the sudo helper with sudo. It returns nil
+// if threshold is not positive.
+func diagnoseAfter(threshold time.Duration, pid int, signal string, sudo bool) *diagnosis {
+	if threshold <= 0 {
+		return nil
+	}
+
+	d := &diagnosis{done: make(chan struct{})}
+	d.timer = time.AfterFunc(threshold, func() {
+		defer close(d.done)
+
+		dir, err := captureDiagnostics(pid, threshold, signal, sudo)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ztime: capturing diagnostics: %v\n", err)
+		}
+
+		d.dir = dir
+	})
+
+	return d
+}
+
+// stop cancels a capture that has not started, or waits for one in progress
+// to finish. It returns the bundle directory, or "" if the run finished
+// within the threshold.
+func (d *diagnosis) stop() string {
+	if d == nil || d.timer.Stop() {
+		return ""
+	}
+
+	<-d.done
+
+	return d.dir
+}
+
+// diagnosticBundle is a directory of files describing a slow process. What
+// could not be collected is listed in errors.txt rather than failing the
+// capture.
+type diagnosticBundle struct {
+	dir  string
+	sudo bool // run privileged tools through the sudo helper
+	errs []string
+}
+
+// captureDiagnostics writes a bundle for pid to a new temporary directory
+// and returns its path. The process snapshot comes first and the signal
+// last, since a stack dump signal ends some programs.
+func captureDiagnostics(pid int, threshold time.Duration, signal string, sudo bool) (string, error) {
+	dir, err := os.MkdirTemp("", "ztime-diag-")
+	if err != nil {
+		return "", err
+	}
+
+	b := &diagnosticBundle{dir: dir, sudo: sudo}
+
+	summary := fmt.Sprintf("pid: %d\nthreshold: %s\ncaptured: %s\n", pid, threshold, time.Now().Format(time.RFC3339Nano))
+
+	captureProcess(b, pid)
+
+	if signal != "" {
+		if err := sendSignal(pid, signal); err != nil {
+			b.fail("signal", err)
+		} else {
+			summary += fmt.Sprintf("signal: sent SIG%s; any stack dump is on the command's stderr\n", strings.TrimPrefix(strings.ToUpper(signal), "SIG"))
+		}
+	}
+
+	b.write("summary.txt", []byte(summary), nil)
+
+	if len(b.errs) > 0 {
+		b.write("errors.txt", []byte(strings.Join(b.errs, "\n")+"\n"), nil)
+	}
+
+	return dir, nil
+}
+
+// write saves data as name in the bundle, or records err instead.
+func (b *diagnosticBundle) write(name string, data []byte, err error) {
+	if err == nil {
+		err = os.WriteFile(filepath.Join(b.dir, name), data, 0o600)
+	}
+
+	if err != nil {
+		b.fail(name, err)
+	}
+}
+
+// run saves the combined output of a diagnostic tool as name. A tool that
+// is not installed is recorded as missing.
+func (b *diagnosticBundle) run(name string, tool string, args ...string) {
+	if _, err := exec.LookPath(tool); err != nil {
+		b.fail(name, err)
+
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticToolTimeout)
+	defer cancel()
+
+	out, err := helperOutput(exec.CommandContext(ctx, tool, args...), true)
+	if err != nil {
+		out = fmt.Appendf(out, "\n%s: %v\n", tool, err)
+	}
+
+	b.write(name, out, nil)
+}
+
+func (b *diagnosticBundle) fail(what string, err error) {
+	b.errs = append(b.errs, fmt.Sprintf("%s: %v", what, err))
+}
+
+// sendSignal sends the named signal, such as "QUIT", to pid.
+func sendSignal(pid int, name string) error {
+	sig, ok := signalByName(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", errUnknownSignal, name)
+	}
+
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+
+	return proc.Signal(sig)
+}
+
+// diagnosticsNote points at the bundles captured for slow runs, or returns
+// "" if every run finished within the threshold.
+func diagnosticsNote(threshold time.Duration, runs ...Metrics) string {
+	var dirs []string
+
+	for _, m := range runs {
+		if m.Diagnostics != "" {
+			dirs = append(dirs, m.Diagnostics)
+		}
+	}
+
+	switch len(dirs) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf("run exceeded %s; diagnostics saved in %s", threshold, dirs[0])
+	default:
+		return fmt.Sprintf("%d runs exceeded %s; diagnostics saved in %s", len(dirs), threshold, strings.Join(dirs, ", "))
+	}
+}
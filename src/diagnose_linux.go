@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// perfSample is how long perf samples the slow process.
+const perfSample = time.Second
+
+// captureProcess snapshots pid from /proc: its status, kernel stack and
+// wait channel, each thread's state, and its open file descriptors, then
+// takes a short perf sample if perf is installed, through the sudo helper
+// if the bundle says so.
+func captureProcess(b *diagnosticBundle, pid int) {
+	proc := "/proc/" + strconv.Itoa(pid)
+
+	for _, name := range []string{"status", "wchan", "stack"} {
+		data, err := os.ReadFile(filepath.Join(proc, name))
+		b.write(name, data, err)
+	}
+
+	threads, err := threadStates(proc)
+	b.write("threads.txt", threads, err)
+
+	fds, err := openFiles(proc)
+	b.write("fds.txt", fds, err)
+
+	if b.sudo {
+		samplePerfWithSudo(b, pid)
+
+		return
+	}
+
+	b.run("perf.txt", "perf", "record", "--quiet", "-g", "-F", "99", "-p", strconv.Itoa(pid),
+		"-o", filepath.Join(b.dir, "perf.data"), "--", "sleep", strconv.Itoa(int(perfSample.Seconds())))
+}
+
+// samplePerfWithSudo takes the perf sample with the helper's perf-record
+// action, which records until ztime closes its stdin.
+func samplePerfWithSudo(b *diagnosticBundle, pid int) {
+	record, stdin, err := startSudoPerf(pid, filepath.Join(b.dir, "perf.data"))
+	if err != nil {
+		b.fail("perf.data", err)
+
+		return
+	}
+
+	time.Sleep(perfSample)
+	_ = stdin.Close()
+
+	if err := waitHelper(record); err != nil {
+		b.fail("perf.data", sudoError(record, err))
+	}
+}
+
+// threadStates lists each thread of the process with its state and the
+// kernel function it is waiting in.
+func threadStates(proc string) ([]byte, error) {
+	tids, err := os.ReadDir(filepath.Join(proc, "task"))
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+
+	for _, tid := range tids {
+		task := filepath.Join(proc, "task", tid.Name())
+
+		name, state := "?", "?"
+		if status, err := os.ReadFile(filepath.Join(task, "status")); err == nil {
+			for line := range strings.Lines(string(status)) {
+				if rest, ok := strings.CutPrefix(line, "Name:"); ok {
+					name = strings.TrimSpace(rest)
+				} else if rest, ok := strings.CutPrefix(line, "State:"); ok {
+					state = strings.TrimSpace(rest)
+				}
+			}
+		}
+
+		wchan, _ := os.ReadFile(filepath.Join(task, "wchan"))
+		fmt.Fprintf(&sb, "%s\t%s\t%s\t%s\n", tid.Name(), name, state, wchan)
+	}
+
+	return []byte(sb.String()), nil
+}
+
+// openFiles lists the process's file descriptors and what they refer to.
+func openFiles(proc string) ([]byte, error) {
+	fds, err := os.ReadDir(filepath.Join(proc, "fd"))
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+
+	for _, fd := range fds {
+		target, err := os.Readlink(filepath.Join(proc, "fd", fd.Name()))
+		if err != nil {
+			target = err.Error()
+		}
+
+		fmt.Fprintf(&sb, "%s\t%s\n", fd.Name(), target)
+	}
+
+	return []byte(sb.String()), nil
+}
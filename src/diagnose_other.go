@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import "strconv"
+
+// captureProcess snapshots pid with ps and, where installed, its open files
+// with lsof. There is no /proc to read outside Linux.
+func captureProcess(b *diagnosticBundle, pid int) {
+	p := strconv.Itoa(pid)
+
+	b.run("ps.txt", "ps", "-o", "pid,ppid,state,rss,vsz,time,wchan,command", "-p", p)
+	b.run("fds.txt", "lsof", "-n", "-P", "-p", p)
+}
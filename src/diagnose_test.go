@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiagnosticsNote(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		runs []Metrics
+		want string
+	}{
+		{"none", []Metrics{{}, {}}, ""},
+		{"one", []Metrics{{Diagnostics: "/tmp/a"}}, "run exceeded 5s; diagnostics saved in /tmp/a"},
+		{"several", []Metrics{{Diagnostics: "/tmp/a"}, {}, {Diagnostics: "/tmp/b"}}, "2 runs exceeded 5s; diagnostics saved in /tmp/a, /tmp/b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := diagnosticsNote(5*time.Second, tt.runs...); got != tt.want {
+				t.Errorf("diagnosticsNote() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiagnoseOnSlow(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep not available")
+	}
+
+	m, err := runCommand([]string{"sleep", "0.3"}, runOptions{diagnoseAfter: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("runCommand() error = %v", err)
+	}
+
+	if m.Diagnostics == "" {
+		t.Fatal("Diagnostics is empty for a run over the threshold")
+	}
+
+	t.Cleanup(func() { _ = os.RemoveAll(m.Diagnostics) })
+
+	summary, err := os.ReadFile(filepath.Join(m.Diagnostics, "summary.txt"))
+	if err != nil {
+		t.Fatalf("reading summary: %v", err)
+	}
+
+	if !strings.Contains(string(summary), "threshold: 50ms") {
+		t.Errorf("summary = %q, want the threshold", summary)
+	}
+
+	fast, err := runCommand([]string{"sleep", "0"}, runOptions{diagnoseAfter: time.Minute})
+	if err != nil {
+		t.Fatalf("runCommand() error = %v", err)
+	}
+
+	if fast.Diagnostics != "" {
+		t.Errorf("Diagnostics = %q for a run within the threshold, want none", fast.Diagnostics)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// plannedRun is one command ztime would benchmark or time, with its hooks.
+type plannedRun struct {
+	name    string // suite benchmark or --compare label; empty for a lone command
+	args    []string
+	prepare string
+	cleanup string
+	runs    string
+}
+
+// dryRun prints how the command would be run with --dry-run and runs
+// nothing, failing as a real run would when the program cannot be found.
+func (c *runCmd) dryRun() error {
+	return c.writeDryRun(os.Stderr)
+}
+
+func (c *runCmd) writeDryRun(w io.Writer) error {
+	plans, err := c.plannedRuns()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "ztime: dry run, nothing is run")
+
+	for _, p := range plans {
+		if err := c.describeRun(w, p); err != nil {
+			return err
+		}
+	}
+
+	if limits := c.plannedLimits(); len(limits) > 0 {
+		fmt.Fprintf(w, "limits:      %s\n", strings.Join(limits, ", "))
+	}
+
+	return nil
+}
+
+// plannedRuns lists the commands that would run, in order, as the suite,
+// --parallel, and --compare modes would build them.
+func (c *runCmd) plannedRuns() ([]plannedRun, error) {
+	shell := func(script string) []string {
+		return shellCommand(context.Background(), script).Args
+	}
+
+	switch {
+	case c.Suite != "":
+		suite, err := loadSuite(c.Suite)
+		if err != nil {
+			return nil, err
+		}
+
+		plans := make([]plannedRun, 0, len(suite))
+		for _, b := range suite {
+			plans = append(plans, plannedRun{
+				name:    b.name,
+				args:    shell(b.command),
+				prepare: cmp.Or(b.prepare, c.Prepare),
+				cleanup: cmp.Or(b.cleanup, c.Cleanup),
+				runs:    strconv.Itoa(b.runs),
+			})
+		}
+
+		return plans, nil
+	case c.Parallel:
+		plans := make([]plannedRun, 0, len(c.Command))
+		for _, command := range c.Command {
+			plans = append(plans, plannedRun{name: command, args: shell(command), runs: "1, all at once"})
+		}
+
+		if c.Jobs > 0 {
+			for i := range plans {
+				plans[i].runs = fmt.Sprintf("1, at most %d at once", c.Jobs)
+			}
+		}
+
+		return plans, nil
+	}
+
+	plans := []plannedRun{{args: c.Command, prepare: c.Prepare, cleanup: c.Cleanup, runs: c.plannedRunCount()}}
+	if c.Compare != "" {
+		plans[0].name = "command"
+		plans = append(plans, plannedRun{name: "compared with", args: shell(c.Compare), prepare: c.Prepare, cleanup: c.Cleanup, runs: plans[0].runs})
+	}
+
+	return plans, nil
+}
+
+// plannedRunCount describes how often the command would run.
+func (c *runCmd) plannedRunCount() string {
+	switch {
+	case c.Watch > 0:
+		return fmt.Sprintf("every %s until interrupted", c.Watch)
+	case len(c.OnChange) > 0:
+		return "on every change under " + strings.Join(c.OnChange, ", ") + " until interrupted"
+	case c.Duration > 0:
+		return "as many as fit in " + c.Duration.String()
+	case c.adaptive():
+		return fmt.Sprintf("%d to %d, until the elapsed times vary by at most %.0f%%",
+			cmp.Or(c.MinRuns, defaultMinRuns), cmp.Or(c.MaxRuns, defaultMaxRuns), c.TargetCV*100)
+	case c.Runs > 1:
+		return strconv.Itoa(c.Runs)
+	case c.Retries > 0:
+		return fmt.Sprintf("1, retried up to %d times while it fails", c.Retries)
+	default:
+		return "1"
+	}
+}
+
+// describeRun prints the program, arguments, working directory, and
+// environment changes of one planned run, wrapped for --ssh or --container
+// the way the runner would.
+func (c *runCmd) describeRun(w io.Writer, p plannedRun) error {
+	args := p.args
+
+	switch {
+	case c.SSH != "":
+		args = c.sshArgs(args)
+	case c.Container != "":
+		args = containerArgs(c.ContainerRuntime, "ztime-NAME", c.Container, c.ContainerArg, c.Stdin != "" || c.StdinNull || !isTerminal(os.Stdin), args)
+	}
+
+	//nolint:gosec // Intended behavior: the command comes from the user and is not run.
+	cmd := exec.CommandContext(context.Background(), args[0], args[1:]...)
+	if cmd.Err != nil {
+		return cmd.Err
+	}
+
+	totalRuns := 1
+	if c.benchmarking() {
+		totalRuns = c.Runs
+	}
+
+	opts := c.runOptions(runOptions{totalRuns: totalRuns})
+	cmd.Env = runEnv(opts)
+
+	// Attaching the instruments shows what they add to the environment;
+	// ssh gets none of them.
+	if c.SSH == "" {
+		instruments, err := attachInstruments(cmd, opts)
+		if err != nil {
+			return err
+		}
+
+		for _, inst := range instruments {
+			inst.finish(&Metrics{}, time.Time{})
+		}
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	if p.name != "" {
+		fmt.Fprintf(w, "%s:\n", p.name)
+	}
+
+	fmt.Fprintf(w, "  program:     %s\n", cmd.Path)
+	fmt.Fprintf(w, "  arguments:   %s\n", quoteArgs(args))
+	fmt.Fprintf(w, "  directory:   %s\n", dir)
+	fmt.Fprintf(w, "  environment: %s\n", cmp.Or(strings.Join(addedEnv(cmd.Env, os.Environ()), " "), "unchanged"))
+	fmt.Fprintf(w, "  stdin:       %s\n", c.plannedStdin())
+	fmt.Fprintf(w, "  runs:        %s\n", p.runs)
+
+	if p.prepare != "" {
+		fmt.Fprintf(w, "  prepare:     %s\n", p.prepare)
+	}
+
+	if p.cleanup != "" {
+		fmt.Fprintf(w, "  cleanup:     %s\n", p.cleanup)
+	}
+
+	return nil
+}
+
+// plannedStdin describes where the command's stdin would come from.
+func (c *runCmd) plannedStdin() string {
+	switch {
+	case c.StdinNull:
+		return os.DevNull
+	case c.Stdin != "":
+		return c.Stdin
+	case c.PTY:
+		return "a pseudo-terminal"
+	case c.StdinStats:
+		return "ztime's, relayed through a pipe"
+	default:
+		return "ztime's"
+	}
+}
+
+// plannedLimits lists the flags that could stop, slow, or fail a run.
+func (c *runCmd) plannedLimits() []string {
+	var limits []string
+
+	if c.MaxOutputRate > 0 {
+		limits = append(limits, fmt.Sprintf("output over %s/s (%s)", humanBytes(int64(c.MaxOutputRate)), c.OutputRateAction))
+	}
+
+	for _, t := range c.FailIf {
+		limits = append(limits, "fail if "+t.text)
+	}
+
+	if c.Budget != "" {
+		limits = append(limits, "budget "+c.Budget)
+	}
+
+	if c.Timeout > 0 {
+		limits = append(limits, "time out after "+c.Timeout.String())
+	}
+
+	if c.LimitMem > 0 {
+		limits = append(limits, "memory limit "+humanBytes(int64(c.LimitMem)))
+	}
+
+	if c.DiagnoseOnSlow > 0 {
+		limits = append(limits, "diagnose after "+c.DiagnoseOnSlow.String())
+	}
+
+	if c.Cooldown > 0 {
+		limits = append(limits, "cool down "+c.Cooldown.String())
+	}
+
+	if c.RequireIdle > 0 {
+		limits = append(limits, fmt.Sprintf("load at most %g", c.RequireIdle))
+	}
+
+	if c.DropCaches {
+		limits = append(limits, "drop caches")
+	}
+
+	if c.KillOrphans {
+		limits = append(limits, "kill orphans")
+	}
+
+	if c.KillChildren {
+		limits = append(limits, "kill children after "+c.KillGrace.String())
+	}
+
+	return limits
+}
+
+// addedEnv returns the entries of env that base does not have.
+func addedEnv(env, base []string) []string {
+	var added []string
+
+	for _, kv := range env {
+		if !slices.Contains(base, kv) {
+			added = append(added, kv)
+		}
+	}
+
+	return added
+}
+
+// quoteArgs joins args for a shell, quoting those that need it.
+func quoteArgs(args []string) string {
+	words := make([]string, len(args))
+	for i, arg := range args {
+		words[i] = arg
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`|&;<>()*?[]{}~#!") {
+			words[i] = shellQuote(arg)
+		}
+	}
+
+	return strings.Join(words, " ")
+}
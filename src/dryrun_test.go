@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteDryRun(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	c := runCmd{
+		Command: []string{"sh", "-c", "echo hi"},
+		Runs:    3,
+		Prepare: "make clean",
+		Compare: "true",
+		FailIf:  []threshold{{metric: "elapsed", op: ">", limit: 1, text: "elapsed > 1s"}},
+	}
+
+	var buf bytes.Buffer
+	if err := c.writeDryRun(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"arguments:   sh -c 'echo hi'",
+		"environment: ZTIME_ITERATION=1 ZTIME_TOTAL_RUNS=3 ZTIME_RUN_ID=",
+		"prepare:     make clean",
+		"compared with:",
+		"limits:      fail if elapsed > 1s",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("dry run output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteDryRunNotFound(t *testing.T) {
+	t.Parallel()
+
+	c := runCmd{Command: []string{"ztime-no-such-program"}}
+
+	var buf bytes.Buffer
+	if err := c.writeDryRun(&buf); !errors.Is(err, exec.ErrNotFound) {
+		t.Fatalf("writeDryRun() error = %v, want %v", err, exec.ErrNotFound)
+	}
+}
+
+func TestPlannedRunCount(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		c    runCmd
+		want string
+	}{
+		{"single", runCmd{}, "1"},
+		{"runs", runCmd{Runs: 5}, "5"},
+		{"duration", runCmd{Duration: 10 * time.Second}, "as many as fit in 10s"},
+		{"adaptive", runCmd{MinRuns: 5, TargetCV: 0.05}, "5 to 100, until the elapsed times vary by at most 5%"},
+		{"retries", runCmd{Retries: 2}, "1, retried up to 2 times while it fails"},
+		{"watch", runCmd{Watch: time.Second}, "every 1s until interrupted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.c.plannedRunCount(); got != tt.want {
+				t.Errorf("plannedRunCount() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteArgs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"make", "-j8"}, "make -j8"},
+		{[]string{"sh", "-c", "echo $HOME"}, "sh -c 'echo $HOME'"},
+		{[]string{"printf", ""}, "printf ''"},
+		{[]string{"echo", "it's"}, `echo 'it'\''s'`},
+	}
+
+	for _, tt := range tests {
+		if got := quoteArgs(tt.args); got != tt.want {
+			t.Errorf("quoteArgs(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestDebugLog(t *testing.T) {
+	t.Parallel()
+
+	var nilLog *debugLog
+	nilLog.printf("discarded %d", 1)
+
+	var buf bytes.Buffer
+	newDebugLog(&buf).printf("forwarded %s to pid %d", "SIGINT", 42)
+
+	if got := buf.String(); !strings.HasPrefix(got, "ztime: debug: +") || !strings.HasSuffix(got, "s forwarded SIGINT to pid 42\n") {
+		t.Errorf("printf wrote %q", got)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Event types written by --jsonl.
+const (
+	eventRunStarted       = "run_started"
+	eventSample           = "sample"
+	eventRunFinished      = "run_finished"
+	eventBenchFinished    = "bench_finished"
+	eventCompareFinished  = "compare_finished"
+	eventParallelFinished = "parallel_finished"
+	eventSuiteFinished    = "suite_finished"
+	eventError            = "error" // ztime serve: the command could not run
+)
+
+// event is one line of --jsonl output.
+type event struct {
+	Type        string        `json:"type"`
+	Time        time.Time     `json:"time"`
+	Iteration   int           `json:"iteration,omitempty" unit:"none"`                       // run events
+	Command     string        `json:"command,omitempty"`                                     // run_started
+	Argv        []string      `json:"argv,omitempty"`                                        // run_started
+	PID         int           `json:"pid,omitempty" unit:"none"`                             // sample
+	Elapsed     time.Duration `json:"elapsed,omitempty"`                                     // sample
+	RSS         int64         `json:"rss_kb,omitempty" unit:"kilobytes"`                     // sample, where the platform reports it
+	Threads     int64         `json:"threads,omitempty" unit:"count"`                        // sample (Linux)
+	FDs         int64         `json:"fds,omitempty" unit:"count"`                            // sample (Linux): open file descriptors
+	Voluntary   float64       `json:"voluntary_switches,omitempty" unit:"switches/second"`   // sample (Linux): since the last one
+	Involuntary float64       `json:"involuntary_switches,omitempty" unit:"switches/second"` // sample (Linux)
+	Result      any           `json:"result,omitempty"`                                      // Metrics, BenchResult, Comparison, ParallelResult, or SuiteResult
+	Error       string        `json:"error,omitempty"`                                       // error
+}
+
+// eventStream writes events as JSON Lines while the command runs. A nil
+// stream discards everything, so callers need not check for --jsonl.
+type eventStream struct {
+	interval time.Duration
+
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newEventStream(w io.Writer, interval time.Duration) *eventStream {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	return &eventStream{interval: interval, enc: enc}
+}
+
+func (s *eventStream) emit(e event) {
+	if s == nil {
+		return
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	e.Result = versioned(e.Result)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_ = s.enc.Encode(e)
+}
+
+// processSample is a snapshot of a running process's resources.
+type processSample struct {
+	rss         int64 // KB
+	threads     int64
+	fds         int64
+	voluntary   int64 // context switches so far, summed over the live threads
+	involuntary int64
+}
+
+// sample emits a sample event for the running process every interval until
+// the returned function is called, which returns the largest thread and
+// descriptor counts seen and the context switch rates, if any.
+func (s *eventStream) sample(pid int, start time.Time, iteration int, debug *debugLog) func() (processSample, *ContextSwitches) {
+	if s == nil || s.interval <= 0 {
+		return func() (processSample, *ContextSwitches) { return processSample{}, nil }
+	}
+
+	var (
+		peak     processSample
+		switches switchRates
+	)
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				e := event{Type: eventSample, Time: now, Iteration: iteration, PID: pid, Elapsed: now.Sub(start)}
+				if p, ok := sampleProcess(pid); ok {
+					e.RSS, e.Threads, e.FDs = p.rss, p.threads, p.fds
+					peak.threads = max(peak.threads, p.threads)
+					peak.fds = max(peak.fds, p.fds)
+					e.Voluntary, e.Involuntary = switches.add(p, e.Elapsed)
+				}
+
+				debug.printf("sample of pid %d: rss %d KB, %d threads, %d fds", pid, e.RSS, e.Threads, e.FDs)
+
+				s.emit(e)
+			}
+		}
+	}()
+
+	return func() (processSample, *ContextSwitches) {
+		close(done)
+		<-stopped
+
+		return peak, switches.result(s.interval)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestEventStream(t *testing.T) {
+	t.Parallel()
+
+	var nilStream *eventStream
+
+	nilStream.emit(event{Type: eventRunStarted})
+	nilStream.sample(1, time.Now(), 1, nil)()
+
+	var out bytes.Buffer
+
+	s := newEventStream(&out, 10*time.Millisecond)
+	s.emit(event{Type: eventRunStarted, Iteration: 1, Command: "a<b"})
+
+	stop := s.sample(-1, time.Now(), 1, nil)
+	time.Sleep(35 * time.Millisecond)
+	stop()
+
+	s.emit(event{Type: eventRunFinished, Iteration: 1, Result: Metrics{Command: "a<b"}})
+
+	if !bytes.Contains(out.Bytes(), []byte(`"command":"a<b"`)) {
+		t.Errorf("output escaped HTML characters: %s", out.String())
+	}
+
+	var types []string
+
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var e struct {
+			Type    string `json:"type"`
+			Command string `json:"command"`
+		}
+
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("line %q is not JSON: %v", scanner.Text(), err)
+		}
+
+		types = append(types, e.Type)
+	}
+
+	if len(types) < 4 || types[0] != eventRunStarted || types[1] != eventSample || types[len(types)-1] != eventRunFinished {
+		t.Errorf("event types = %v, want run_started, samples, run_finished", types)
+	}
+}
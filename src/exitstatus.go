@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// Exit statuses for failures that did not come from the command, as
+// timeout(1) and the shell use them.
+const (
+	exitTimeout       = 124 // the command ran out of time (--timeout, ztime k8s --timeout)
+	exitInternal      = 125 // ztime itself failed, such as a hook, cache, or I/O error
+	exitNotExecutable = 126 // the command was found but could not be run
+	exitNotFound      = 127 // the command was not found
+)
+
+// Values of --exit-status-from.
+const (
+	exitFromChild      = "child"
+	exitFromSelf       = "self"
+	exitFromAlwaysZero = "always-zero"
+)
+
+// exitCode maps the result of running the command to ztime's exit status,
+// reporting errors that did not come from the command itself.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	if errors.Is(err, errThresholdExceeded) || errors.Is(err, errRegression) {
+		fmt.Fprintf(os.Stderr, "ztime: %v\n", err)
+
+		return 1
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+
+		return exitErr.ExitCode()
+	}
+
+	fmt.Fprintf(os.Stderr, "ztime: %v\n", err)
+
+	if code := startFailure(err); code != 0 {
+		return code
+	}
+
+	if errors.Is(err, errTimedOut) || errors.Is(err, context.DeadlineExceeded) {
+		return exitTimeout
+	}
+
+	return exitInternal
+}
+
+// startFailure returns the shell's exit status for a command that could not
+// be started, or 0 if err is not such a failure. Looking the command up
+// fails with an exec.Error and starting it with a "fork/exec" PathError.
+func startFailure(err error) int {
+	var (
+		execErr *exec.Error
+		pathErr *fs.PathError
+	)
+
+	switch {
+	case errors.As(err, &execErr):
+		err = execErr.Err
+	case errors.As(err, &pathErr) && pathErr.Op == "fork/exec":
+		err = pathErr.Err
+	default:
+		return 0
+	}
+
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+		return exitNotFound
+	}
+
+	return exitNotExecutable
+}
+
+// exitStatus applies --exit-status-from to the exit status for err. Errors
+// are reported whichever source is chosen.
+func (c *runCmd) exitStatus(err error) int {
+	code := exitCode(err)
+
+	var exitErr *exec.ExitError
+
+	switch {
+	case c.ExitStatusFrom == exitFromAlwaysZero:
+		return 0
+	case c.ExitStatusFrom == exitFromSelf && errors.As(err, &exitErr):
+		// The command failed, but ztime measured and reported it.
+		return 0
+	default:
+		return code
+	}
+}
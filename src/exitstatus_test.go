@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"syscall"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, 0},
+		{"threshold", fmt.Errorf("%w: elapsed > 1s", errThresholdExceeded), 1},
+		{"not in PATH", &exec.Error{Name: "nope", Err: exec.ErrNotFound}, exitNotFound},
+		{"missing path", &exec.Error{Name: "./nope", Err: fs.ErrNotExist}, exitNotFound},
+		{"not executable", &exec.Error{Name: "./data", Err: fs.ErrPermission}, exitNotExecutable},
+		{"bad format", &fs.PathError{Op: "fork/exec", Path: "./data", Err: syscall.ENOEXEC}, exitNotExecutable},
+		{"stdin missing", &fs.PathError{Op: "open", Path: "input", Err: fs.ErrNotExist}, exitInternal},
+		{"timeout", fmt.Errorf("waiting for job: %w", context.DeadlineExceeded), exitTimeout},
+		{"run timeout", fmt.Errorf("%w after 1s", errTimedOut), exitTimeout},
+		{"hook", errors.New("prepare hook failed"), exitInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExitStatusFrom(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	commandErr := exec.CommandContext(context.Background(), "sh", "-c", "exit 3").Run()
+	internalErr := errors.New("cleanup hook failed")
+
+	tests := []struct {
+		from string
+		err  error
+		want int
+	}{
+		{exitFromChild, commandErr, 3},
+		{exitFromChild, internalErr, exitInternal},
+		{exitFromSelf, commandErr, 0},
+		{exitFromSelf, internalErr, exitInternal},
+		{exitFromAlwaysZero, commandErr, 0},
+		{exitFromAlwaysZero, internalErr, 0},
+	}
+
+	for _, tt := range tests {
+		c := &runCmd{ExitStatusFrom: tt.from}
+		if got := c.exitStatus(tt.err); got != tt.want {
+			t.Errorf("--exit-status-from %s: exitStatus(%v) = %d, want %d", tt.from, tt.err, got, tt.want)
+		}
+	}
+}
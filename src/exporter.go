@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// The exporter protocol is newline-delimited JSON. ztime starts the
+// exporter, writes a "hello" message to its stdin, and waits for a "ready"
+// reply on its stdout. It then writes one "result" message per report and
+// closes stdin; the exporter should flush and exit.
+const (
+	exporterProtocol         = 1
+	exporterHandshakeTimeout = 5 * time.Second
+)
+
+var errExporterHandshake = errors.New("exporter handshake failed")
+
+// exporterMessage is one line of the exporter protocol.
+type exporterMessage struct {
+	Type     string `json:"type"`               // hello, ready, or result
+	Protocol int    `json:"protocol,omitempty"` // hello, ready
+	Version  string `json:"version,omitempty"`  // hello: ztime version
+	Name     string `json:"name,omitempty"`     // ready: exporter name
+	Kind     string `json:"kind,omitempty"`     // result: run or bench
+	Result   any    `json:"result,omitempty"`   // result: Metrics or BenchResult
+}
+
+// exporter is a running exporter subprocess.
+type exporter struct {
+	command string
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	drained chan struct{}
+}
+
+func startExporter(command string) (*exporter, error) {
+	cmd := shellCommand(context.Background(), command)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("exporter %q: %w", command, err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("exporter %q: %w", command, err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("exporter %q: %w", command, err)
+	}
+
+	e := &exporter{command: command, cmd: cmd, stdin: stdin, drained: make(chan struct{})}
+
+	if err := e.handshake(bufio.NewReader(stdout)); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+
+		return nil, fmt.Errorf("exporter %q: %w", command, err)
+	}
+
+	return e, nil
+}
+
+// handshake sends hello and waits for ready. Anything the exporter prints
+// afterwards is passed through to stderr.
+func (e *exporter) handshake(stdout *bufio.Reader) error {
+	hello := exporterMessage{Type: "hello", Protocol: exporterProtocol, Version: serverVersion}
+	if err := json.NewEncoder(e.stdin).Encode(hello); err != nil {
+		return fmt.Errorf("%w: %w", errExporterHandshake, err)
+	}
+
+	ready := make(chan error, 1)
+
+	go func() {
+		defer close(e.drained)
+
+		ready <- readReady(stdout)
+
+		_, _ = io.Copy(os.Stderr, stdout)
+	}()
+
+	select {
+	case err := <-ready:
+		return err
+	case <-time.After(exporterHandshakeTimeout):
+		return fmt.Errorf("%w: no reply within %v", errExporterHandshake, exporterHandshakeTimeout)
+	}
+}
+
+func readReady(stdout *bufio.Reader) error {
+	line, err := stdout.ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return fmt.Errorf("%w: %w", errExporterHandshake, err)
+	}
+
+	var msg exporterMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return fmt.Errorf("%w: %w", errExporterHandshake, err)
+	}
+
+	if msg.Type != "ready" || msg.Protocol != exporterProtocol {
+		return fmt.Errorf("%w: expected ready for protocol %d, got %q for protocol %d",
+			errExporterHandshake, exporterProtocol, msg.Type, msg.Protocol)
+	}
+
+	return nil
+}
+
+// send writes a result message.
+func (e *exporter) send(kind string, result any) error {
+	msg := exporterMessage{Type: "result", Kind: kind, Result: versioned(result)}
+	if err := json.NewEncoder(e.stdin).Encode(msg); err != nil {
+		return fmt.Errorf("exporter %q: %w", e.command, err)
+	}
+
+	return nil
+}
+
+// close signals the end of the results and waits for the exporter to exit.
+func (e *exporter) close() error {
+	_ = e.stdin.Close()
+	<-e.drained
+
+	if err := e.cmd.Wait(); err != nil {
+		return fmt.Errorf("exporter %q: %w", e.command, err)
+	}
+
+	return nil
+}
+
+// export writes a result to --export-hyperfine and sends it to every
+// exporter, then shuts the exporters down. Failures are reported but do not
+// change ztime's exit status.
+func (c *runCmd) export(kind string, result any) {
+	c.sendResult(kind, result)
+	c.closeExporters()
+}
+
+// sendResult writes a result to --export-hyperfine and sends it to every
+// exporter, leaving them running for more, as --watch does.
+func (c *runCmd) sendResult(kind string, result any) {
+	if c.ExportHyperfine != "" {
+		if err := writeHyperfine(c.ExportHyperfine, result); err != nil {
+			fmt.Fprintf(os.Stderr, "ztime: --export-hyperfine: %v\n", err)
+		}
+	}
+
+	for _, e := range c.exporters {
+		if err := e.send(kind, result); err != nil {
+			fmt.Fprintf(os.Stderr, "ztime: %v\n", err)
+		}
+	}
+}
+
+// closeExporters shuts every exporter down once there are no more results.
+func (c *runCmd) closeExporters() {
+	for _, e := range c.exporters {
+		if err := e.close(); err != nil {
+			fmt.Fprintf(os.Stderr, "ztime: %v\n", err)
+		}
+	}
+
+	c.exporters = nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExporterProtocol(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	out := filepath.Join(t.TempDir(), "messages")
+	script := `read hello; echo '{"type":"ready","protocol":1,"name":"test"}'; printf '%s\n' "$hello" > ` +
+		shellQuote(out) + `; cat >> ` + shellQuote(out)
+
+	e, err := startExporter(script)
+	if err != nil {
+		t.Fatalf("startExporter() error = %v", err)
+	}
+
+	if err := e.send("run", Metrics{Command: "make", ExitCode: 2}); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+
+	if err := e.close(); err != nil {
+		t.Fatalf("close() error = %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("reading exporter output: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("exporter received %d messages, want 2: %q", len(lines), lines)
+	}
+
+	var hello, result struct {
+		Type     string  `json:"type"`
+		Protocol int     `json:"protocol"`
+		Kind     string  `json:"kind"`
+		Result   Metrics `json:"result"`
+	}
+
+	if err := json.Unmarshal([]byte(lines[0]), &hello); err != nil || hello.Type != "hello" || hello.Protocol != 1 {
+		t.Errorf("first message = %s, want a protocol 1 hello", lines[0])
+	}
+
+	if err := json.Unmarshal([]byte(lines[1]), &result); err != nil || result.Kind != "run" || result.Result.ExitCode != 2 {
+		t.Errorf("second message = %s, want the run result", lines[1])
+	}
+}
+
+func TestExporterHandshakeRejected(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	_, err := startExporter(`read hello; echo '{"type":"ready","protocol":99}'`)
+	if !errors.Is(err, errExporterHandshake) {
+		t.Errorf("startExporter() error = %v, want %v", err, errExporterHandshake)
+	}
+}
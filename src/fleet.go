@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	errFleetHostFailed = errors.New("suite failed on some hosts")
+	errFleetRunFailed  = errors.New("benchmark run failed")
+	errNoHosts         = errors.New("no hosts listed")
+	errNoRemoteResult  = errors.New("remote ztime reported no result")
+)
+
+// fleetCmd runs a benchmark suite on several machines over SSH and
+// compares them.
+type fleetCmd struct {
+	Hosts string `required:"" type:"existingfile" help:"File listing one SSH destination per line, such as user@host." placeholder:"FILE"`
+	Suite string `required:"" type:"existingfile" help:"Benchmark suite to run on every host." placeholder:"FILE"`
+	JSON  bool   `help:"Output the combined results in JSON format."`
+
+	Remote string `default:"ztime" help:"Command that runs ztime on the hosts." placeholder:"CMD"`
+	SSH    string `name:"ssh" default:"ssh" help:"SSH client to connect with." placeholder:"CMD"`
+}
+
+// FleetResult is the outcome of running a suite on every host of a fleet.
+type FleetResult struct {
+	SchemaVersion int `json:"schema_version,omitempty" unit:"none"`
+
+	Suite []string    `json:"suite"` // benchmark names, in suite order
+	Hosts []FleetHost `json:"hosts"`
+}
+
+// FleetHost is the suite's results on one host.
+type FleetHost struct {
+	Host       string        `json:"host"`
+	Benchmarks []BenchResult `json:"benchmarks"`
+	Error      string        `json:"error,omitempty"` // why the suite stopped early on this host
+}
+
+// Run runs the suite on all hosts at once, and each host's benchmarks one
+// after the other, then prints a per-host comparison.
+func (c *fleetCmd) Run() error {
+	hosts, err := loadHosts(c.Hosts)
+	if err != nil {
+		return err
+	}
+
+	suite, err := loadSuite(c.Suite)
+	if err != nil {
+		return err
+	}
+
+	result := FleetResult{Hosts: make([]FleetHost, len(hosts))}
+	for _, b := range suite {
+		result.Suite = append(result.Suite, b.name)
+	}
+
+	var wg sync.WaitGroup
+
+	for i, host := range hosts {
+		wg.Go(func() {
+			result.Hosts[i] = c.runHost(host, suite)
+		})
+	}
+
+	wg.Wait()
+
+	if c.JSON {
+		data, err := json.MarshalIndent(versioned(result), "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(os.Stderr, string(data))
+	} else {
+		printFleet(result)
+	}
+
+	failed := 0
+
+	for _, h := range result.Hosts {
+		if h.Error != "" {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d", errFleetHostFailed, failed, len(hosts))
+	}
+
+	return nil
+}
+
+// runHost runs the suite on host, stopping at the first benchmark that
+// fails.
+func (c *fleetCmd) runHost(host string, suite []suiteBenchmark) FleetHost {
+	h := FleetHost{Host: host}
+
+	for _, b := range suite {
+		r, err := c.runRemote(host, b)
+		if err != nil {
+			h.Error = fmt.Sprintf("%s: %v", b.name, err)
+
+			break
+		}
+
+		h.Benchmarks = append(h.Benchmarks, r)
+	}
+
+	return h
+}
+
+// runRemote benchmarks b on host with the remote ztime, reading the result
+// from its --jsonl events. The command's own output is discarded.
+func (c *fleetCmd) runRemote(host string, b suiteBenchmark) (BenchResult, error) {
+	var stderr bytes.Buffer
+
+	//nolint:gosec // Intended behavior: the hosts and remote command come from the user.
+	cmd := exec.CommandContext(context.Background(), c.SSH, "-o", "BatchMode=yes", "--", host, remoteCommand(c.Remote, b))
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &stderr
+
+	runErr := cmd.Run()
+	output := stderr.String()
+
+	r, ok := parseRemoteResult(&stderr)
+	if !ok {
+		if runErr == nil {
+			runErr = errNoRemoteResult
+		}
+
+		return BenchResult{}, fmt.Errorf("%w%s", runErr, lastLine(output))
+	}
+
+	r.Command = b.name
+
+	if r.StopReason == "failed" {
+		return r, fmt.Errorf("%w: run %d", errFleetRunFailed, r.Runs)
+	}
+
+	return r, nil
+}
+
+// remoteCommand is the shell command line that benchmarks b with ztime on
+// a remote host. ztime is not quoted, so it may use "~" or variables.
+func remoteCommand(ztime string, b suiteBenchmark) string {
+	words := []string{ztime, "run", "--jsonl", "--jsonl-interval", "0", "--runs", strconv.Itoa(b.runs)}
+
+	if b.prepare != "" {
+		words = append(words, "--prepare", shellQuote(b.prepare))
+	}
+
+	if b.cleanup != "" {
+		words = append(words, "--cleanup", shellQuote(b.cleanup))
+	}
+
+	words = append(words, "sh", "-c", shellQuote(b.command))
+
+	return strings.Join(words, " ")
+}
+
+// parseRemoteResult finds the benchmark result among the remote ztime's
+// events. A single run reports no bench_finished event, so its result is
+// built from the run_finished events instead.
+func parseRemoteResult(r io.Reader) (BenchResult, bool) {
+	var runs []Metrics
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16<<20)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !bytes.HasPrefix(line, []byte(`{"type":`)) {
+			continue
+		}
+
+		var e struct {
+			Type   string          `json:"type"`
+			Result json.RawMessage `json:"result"`
+		}
+
+		if json.Unmarshal(line, &e) != nil {
+			continue
+		}
+
+		switch e.Type {
+		case eventBenchFinished:
+			var b BenchResult
+			if json.Unmarshal(e.Result, &b) == nil {
+				return b, true
+			}
+		case eventRunFinished:
+			var m Metrics
+			if json.Unmarshal(e.Result, &m) == nil {
+				runs = append(runs, m)
+			}
+		}
+	}
+
+	if len(runs) == 0 {
+		return BenchResult{}, false
+	}
+
+	result := newBenchResult(runs, elapsedSum(runs), false)
+	result.StopReason = "runs"
+
+	if runs[len(runs)-1].ExitCode != 0 {
+		result.StopReason = "failed"
+	}
+
+	return result, true
+}
+
+// elapsedSum returns the total elapsed time of runs. It stands in for the
+// wall-clock time of benchmarks pieced together from runs, which was not
+// measured.
+func elapsedSum(runs []Metrics) time.Duration {
+	var total time.Duration
+	for _, m := range runs {
+		total += m.ElapsedTime
+	}
+
+	return total
+}
+
+// lastLine returns the last non-empty line of s prefixed with ": ", for
+// adding a remote error message to a local one.
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		return ": " + last
+	}
+
+	return ""
+}
+
+// loadHosts reads one SSH destination per line, skipping blank lines and
+// "#" comments.
+func loadHosts(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+
+	for line := range strings.Lines(string(data)) {
+		line, _, _ = strings.Cut(line, "#")
+		if host := strings.TrimSpace(line); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("%w in %s", errNoHosts, path)
+	}
+
+	return hosts, nil
+}
+
+// printFleet prints each benchmark's mean elapsed time on every host,
+// relative to the fastest host.
+func printFleet(r FleetResult) {
+	bold := lipgloss.NewStyle().Bold(true)
+	faint := lipgloss.NewStyle().Faint(true)
+
+	width := 0
+	for _, h := range r.Hosts {
+		width = max(width, len(h.Host))
+	}
+
+	for i, name := range r.Suite {
+		fmt.Fprintln(os.Stderr, bold.Render(name))
+
+		fastest := fastestHost(r.Hosts, i)
+
+		for _, h := range r.Hosts {
+			host := fmt.Sprintf("  %-*s  ", width, h.Host)
+
+			if i >= len(h.Benchmarks) || h.Benchmarks[i].Runs == 0 {
+				fmt.Fprintln(os.Stderr, host+palette.bad.Render("no result"))
+
+				continue
+			}
+
+			b := h.Benchmarks[i]
+			line := fmt.Sprintf("%smean %.3fs ± %.3fs  %s", host, b.Elapsed.Mean.Seconds(), b.Elapsed.StdDev.Seconds(), faint.Render(fmt.Sprintf("%d runs", b.Runs)))
+
+			switch {
+			case b.Elapsed.Mean == fastest:
+				line += "  " + palette.good.Render("fastest")
+			case fastest > 0:
+				line += fmt.Sprintf("  %.2fx slower", float64(b.Elapsed.Mean)/float64(fastest))
+			}
+
+			fmt.Fprintln(os.Stderr, line)
+		}
+	}
+
+	for _, h := range r.Hosts {
+		if h.Error != "" {
+			fmt.Fprintf(os.Stderr, "ztime: %s: %s\n", h.Host, h.Error)
+		}
+	}
+}
+
+// fastestHost returns the lowest mean elapsed time of benchmark i across
+// hosts, or 0 if no host has a result for it.
+func fastestHost(hosts []FleetHost, i int) time.Duration {
+	var fastest time.Duration
+
+	for _, h := range hosts {
+		if i < len(h.Benchmarks) {
+			if mean := h.Benchmarks[i].Elapsed.Mean; mean > 0 && (fastest == 0 || mean < fastest) {
+				fastest = mean
+			}
+		}
+	}
+
+	return fastest
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRemoteCommand(t *testing.T) {
+	t.Parallel()
+
+	b := suiteBenchmark{name: "build", command: "make 'all'", prepare: "make clean", runs: 5}
+	want := `~/bin/ztime run --jsonl --jsonl-interval 0 --runs 5 --prepare 'make clean' sh -c 'make '\''all'\'''`
+
+	if got := remoteCommand("~/bin/ztime", b); got != want {
+		t.Errorf("remoteCommand() = %s, want %s", got, want)
+	}
+}
+
+func TestParseRemoteResult(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		stderr     string
+		wantOK     bool
+		wantRuns   int
+		wantReason string
+	}{
+		{
+			name: "benchmark",
+			stderr: "compiling...\n" +
+				`{"type":"run_finished","time":"2026-01-01T00:00:00Z","iteration":1,"result":{"elapsed_time":1000,"exit_code":0}}` + "\n" +
+				`{"type":"bench_finished","time":"2026-01-01T00:00:00Z","result":{"command":"sh -c make","runs":2,"stop_reason":"runs"}}` + "\n",
+			wantOK:     true,
+			wantRuns:   2,
+			wantReason: "runs",
+		},
+		{
+			name:       "single run",
+			stderr:     `{"type":"run_finished","time":"2026-01-01T00:00:00Z","iteration":1,"result":{"elapsed_time":1000,"exit_code":2}}` + "\n",
+			wantOK:     true,
+			wantRuns:   1,
+			wantReason: "failed",
+		},
+		{name: "no events", stderr: "sh: ztime: not found\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := parseRemoteResult(strings.NewReader(tt.stderr))
+			if ok != tt.wantOK {
+				t.Fatalf("parseRemoteResult() ok = %v, want %v", ok, tt.wantOK)
+			}
+
+			if got.Runs != tt.wantRuns || got.StopReason != tt.wantReason {
+				t.Errorf("parseRemoteResult() = %d runs, stop reason %q; want %d, %q", got.Runs, got.StopReason, tt.wantRuns, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestFleetRunHost(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	// The fake SSH client reports a benchmark of one second for every
+	// command, and fails for the host "down".
+	ssh := filepath.Join(t.TempDir(), "ssh")
+	script := `#!/bin/sh
+while [ "$1" != "--" ]; do shift; done
+[ "$2" = down ] && { echo "connection refused" >&2; exit 255; }
+echo '{"type":"bench_finished","result":{"runs":3,"stop_reason":"runs","elapsed":{"mean":1000000000}}}' >&2
+`
+
+	if err := os.WriteFile(ssh, []byte(script), 0o700); err != nil { //nolint:gosec // The fake client must be executable.
+		t.Fatal(err)
+	}
+
+	c := fleetCmd{Remote: "ztime", SSH: ssh}
+	suite := []suiteBenchmark{{name: "build", command: "make", runs: 3}, {name: "test", command: "make test", runs: 3}}
+
+	up := c.runHost("up", suite)
+	if up.Error != "" || len(up.Benchmarks) != 2 {
+		t.Fatalf("runHost(up) = %+v, want two results", up)
+	}
+
+	if names := []string{up.Benchmarks[0].Command, up.Benchmarks[1].Command}; !reflect.DeepEqual(names, []string{"build", "test"}) {
+		t.Errorf("benchmark names = %v, want the suite names", names)
+	}
+
+	if up.Benchmarks[0].Elapsed.Mean != time.Second {
+		t.Errorf("Elapsed.Mean = %v, want 1s", up.Benchmarks[0].Elapsed.Mean)
+	}
+
+	down := c.runHost("down", suite)
+	if len(down.Benchmarks) != 0 || !strings.Contains(down.Error, "connection refused") {
+		t.Errorf("runHost(down) = %+v, want an error with the remote message", down)
+	}
+}
+
+func TestFastestHost(t *testing.T) {
+	t.Parallel()
+
+	bench := func(mean time.Duration) BenchResult { return BenchResult{Elapsed: DurationStats{Mean: mean}} }
+
+	hosts := []FleetHost{
+		{Host: "a", Benchmarks: []BenchResult{bench(2 * time.Second), bench(time.Second)}},
+		{Host: "b", Benchmarks: []BenchResult{bench(3 * time.Second)}},
+		{Host: "c"},
+	}
+
+	if got := fastestHost(hosts, 0); got != 2*time.Second {
+		t.Errorf("fastestHost(0) = %v, want 2s", got)
+	}
+
+	if got := fastestHost(hosts, 1); got != time.Second {
+		t.Errorf("fastestHost(1) = %v, want 1s", got)
+	}
+
+	if got := fastestHost(hosts, 2); got != 0 {
+		t.Errorf("fastestHost(2) = %v, want 0", got)
+	}
+}
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errSpecifierName = errors.New("invalid specifier name")
+
+// fmtSpec is a parsed TIMEFMT conversion such as %E, %*E, %-10.4U, or
+// %{name}.
+type fmtSpec struct {
+	left      bool
+	width     int
+	precision int
+	star      bool
+	verb      byte
+	name      string // of a user-defined %{name} specifier
+}
+
+// formatOptions holds settings that change how specifiers and the default
+// summary are rendered.
+type formatOptions struct {
+	human   bool              // print kilobyte values as human-readable sizes
+	long    bool              // print the default summary one metric per line
+	all     bool              // add memory, faults, context switches, and block I/O to the default summary
+	width   int               // of the terminal, for the default summary; 0 if unknown
+	custom  map[string]string // user-defined %{name} specifiers and their templates
+	dialect string            // "gnu" for GNU time's letters; zsh's otherwise
+	micro   bool              // print times to the microsecond, with --precision-ns
+	depth   int               // of custom specifiers being expanded
+}
+
+// defaultPrecision is the number of decimals used for time values when no
+// precision modifier is given.
+const defaultPrecision = 2
+
+// maxSpecifierDepth bounds how deeply user-defined specifiers may expand
+// each other, so one that refers to itself is printed as written instead of
+// looping.
+const maxSpecifierDepth = 8
+
+// specifier renders one conversion. clock marks the time values that the
+// star modifier prints in [h:]mm:ss format; the others are printed as
+// written when starred.
+type specifier struct {
+	clock  bool
+	render func(out *bytes.Buffer, spec fmtSpec, m Metrics, opts formatOptions)
+}
+
+// zshSpecifiers are the built-in conversions by verb: zsh's TIMEFMT set plus
+// ztime's own.
+//
+//nolint:gochecknoglobals // The registry is fixed and read-only.
+var zshSpecifiers = map[byte]specifier{
+	'%': textSpecifier(func(Metrics) string { return "%" }),
+	'J': textSpecifier(func(m Metrics) string { return m.Command }),
+	'P': textSpecifier(func(m Metrics) string { return strconv.Itoa(m.CPUPercent) + "%" }),
+	'p': textSpecifier(func(m Metrics) string { return strconv.Itoa(m.PID) }),
+	'x': textSpecifier(func(m Metrics) string { return strconv.Itoa(m.ExitCode) }),
+	'g': textSpecifier(func(m Metrics) string { return cmp.Or(m.TermSignal, "-") }),
+
+	'U': durationSpecifier(func(m Metrics) time.Duration { return m.UserTime }),
+	'S': durationSpecifier(func(m Metrics) time.Duration { return m.SystemTime }),
+	'E': durationSpecifier(func(m Metrics) time.Duration { return m.ElapsedTime }),
+	'i': durationSpecifier(func(m Metrics) time.Duration { return m.Unaccounted }),
+	'f': {clock: true, render: writeFirstOutput},
+
+	'L': countSpecifier(func(m Metrics) int64 { return m.OutputLines }),
+	'l': textSpecifier(func(m Metrics) string { return strconv.FormatFloat(m.OutputLinesPerSec, 'f', 1, 64) }),
+	'B': {render: writeOutputBytes},
+	'b': {render: writeOutputRate},
+
+	'M': kilobyteSpecifier(func(m Metrics) int64 { return m.MaxRSS }, false),
+	'm': kilobyteSpecifier(func(m Metrics) int64 { return m.MaxRSS }, true),
+	'X': kilobyteSpecifier(func(m Metrics) int64 { return m.SharedRSS }, false),
+	'D': kilobyteSpecifier(func(m Metrics) int64 { return m.UnsharedData + m.UnsharedStk }, false),
+	'K': kilobyteSpecifier(func(m Metrics) int64 { return m.SharedRSS + m.UnsharedData + m.UnsharedStk }, false),
+
+	'W': countSpecifier(func(m Metrics) int64 { return m.Swaps }),
+	'F': countSpecifier(func(m Metrics) int64 { return m.PageFaults }),
+	'R': countSpecifier(func(m Metrics) int64 { return m.PageReclaims }),
+	'I': countSpecifier(func(m Metrics) int64 { return m.BlockInput }),
+	'O': countSpecifier(func(m Metrics) int64 { return m.BlockOutput }),
+	'r': countSpecifier(func(m Metrics) int64 { return m.MsgsRecv }),
+	's': countSpecifier(func(m Metrics) int64 { return m.MsgsSent }),
+	'k': countSpecifier(func(m Metrics) int64 { return m.Signals }),
+	'w': countSpecifier(func(m Metrics) int64 { return m.VCtxSwitches }),
+	'c': countSpecifier(func(m Metrics) int64 { return m.ICtxSwitches }),
+}
+
+// gnuSpecifiers are the conversions of GNU time's --format, selected by
+// --format-dialect gnu. Times are plain seconds, and %E drops the fraction
+// past an hour, as GNU time prints them.
+//
+//nolint:gochecknoglobals // The registry is fixed and read-only.
+var gnuSpecifiers = map[byte]specifier{
+	'%': textSpecifier(func(Metrics) string { return "%" }),
+	'C': textSpecifier(func(m Metrics) string { return m.Command }),
+	'P': textSpecifier(func(m Metrics) string { return strconv.Itoa(m.CPUPercent) + "%" }),
+	'x': textSpecifier(func(m Metrics) string { return strconv.Itoa(m.ExitCode) }),
+	'Z': textSpecifier(func(Metrics) string { return strconv.Itoa(os.Getpagesize()) }),
+
+	'e': secondsSpecifier(func(m Metrics) time.Duration { return m.ElapsedTime }),
+	'U': secondsSpecifier(func(m Metrics) time.Duration { return m.UserTime }),
+	'S': secondsSpecifier(func(m Metrics) time.Duration { return m.SystemTime }),
+	'E': {render: writeGNUClock},
+
+	'M': kilobyteSpecifier(func(m Metrics) int64 { return m.MaxRSS }, false),
+	't': kilobyteSpecifier(func(m Metrics) int64 { return m.UnsharedData }, false),
+	'X': kilobyteSpecifier(func(m Metrics) int64 { return m.SharedRSS }, false),
+	'D': kilobyteSpecifier(func(m Metrics) int64 { return m.UnsharedData + m.UnsharedStk }, false),
+	'p': kilobyteSpecifier(func(m Metrics) int64 { return m.UnsharedStk }, false),
+	'K': kilobyteSpecifier(func(m Metrics) int64 { return m.SharedRSS + m.UnsharedData + m.UnsharedStk }, false),
+
+	'W': countSpecifier(func(m Metrics) int64 { return m.Swaps }),
+	'F': countSpecifier(func(m Metrics) int64 { return m.PageFaults }),
+	'R': countSpecifier(func(m Metrics) int64 { return m.PageReclaims }),
+	'I': countSpecifier(func(m Metrics) int64 { return m.BlockInput }),
+	'O': countSpecifier(func(m Metrics) int64 { return m.BlockOutput }),
+	'r': countSpecifier(func(m Metrics) int64 { return m.MsgsRecv }),
+	's': countSpecifier(func(m Metrics) int64 { return m.MsgsSent }),
+	'k': countSpecifier(func(m Metrics) int64 { return m.Signals }),
+	'w': countSpecifier(func(m Metrics) int64 { return m.VCtxSwitches }),
+	'c': countSpecifier(func(m Metrics) int64 { return m.ICtxSwitches }),
+}
+
+// specifiers returns the registry of the selected dialect.
+func (o formatOptions) specifiers() map[byte]specifier {
+	if o.dialect == "gnu" {
+		return gnuSpecifiers
+	}
+
+	return zshSpecifiers
+}
+
+// seconds writes d in seconds with the given decimals, or to the
+// microsecond with --precision-ns.
+func (o formatOptions) seconds(d time.Duration, decimals int) string {
+	if o.micro {
+		decimals = microPrecision
+	}
+
+	return fmt.Sprintf("%.*fs", decimals, d.Seconds())
+}
+
+// escapes are the backslash sequences a template may use.
+//
+//nolint:gochecknoglobals // The table is fixed and read-only.
+var escapes = map[byte]byte{'n': '\n', 't': '\t', '\\': '\\'}
+
+func textSpecifier(value func(Metrics) string) specifier {
+	return specifier{render: func(out *bytes.Buffer, _ fmtSpec, m Metrics, _ formatOptions) {
+		out.WriteString(value(m))
+	}}
+}
+
+func countSpecifier(value func(Metrics) int64) specifier {
+	return textSpecifier(func(m Metrics) string { return strconv.FormatInt(value(m), 10) })
+}
+
+func durationSpecifier(value func(Metrics) time.Duration) specifier {
+	return specifier{clock: true, render: func(out *bytes.Buffer, spec fmtSpec, m Metrics, _ formatOptions) {
+		writeDuration(out, value(m), spec)
+	}}
+}
+
+// secondsSpecifier renders a time as seconds without a unit, as GNU time
+// does.
+func secondsSpecifier(value func(Metrics) time.Duration) specifier {
+	return specifier{render: func(out *bytes.Buffer, spec fmtSpec, m Metrics, _ formatOptions) {
+		precision := spec.precision
+		if precision < 0 {
+			precision = defaultPrecision
+		}
+
+		fmt.Fprintf(out, "%.*f", precision, value(m).Seconds())
+	}}
+}
+
+// kilobyteSpecifier renders a kilobyte value, humanized with --human or
+// always when human is set, as for %m.
+func kilobyteSpecifier(value func(Metrics) int64, human bool) specifier {
+	return specifier{render: func(out *bytes.Buffer, _ fmtSpec, m Metrics, opts formatOptions) {
+		if human || opts.human {
+			out.WriteString(humanBytes(value(m) * 1024))
+		} else {
+			out.WriteString(strconv.FormatInt(value(m), 10))
+		}
+	}}
+}
+
+func writeFirstOutput(out *bytes.Buffer, spec fmtSpec, m Metrics, _ formatOptions) {
+	if m.FirstOutputMS == 0 {
+		out.WriteByte('-')
+	} else {
+		writeDuration(out, time.Duration(m.FirstOutputMS*float64(time.Millisecond)), spec)
+	}
+}
+
+// writeGNUClock writes the elapsed time as GNU time's %E: m:ss.ff, or
+// h:mm:ss without a fraction from an hour on.
+func writeGNUClock(out *bytes.Buffer, _ fmtSpec, m Metrics, _ formatOptions) {
+	precision := defaultPrecision
+	if m.ElapsedTime >= time.Hour {
+		precision = 0
+	}
+
+	writeClock(out, m.ElapsedTime, precision)
+}
+
+func writeOutputBytes(out *bytes.Buffer, _ fmtSpec, m Metrics, opts formatOptions) {
+	if opts.human {
+		out.WriteString(humanBytes(m.OutputBytes))
+	} else {
+		out.WriteString(strconv.FormatInt(m.OutputBytes, 10))
+	}
+}
+
+func writeOutputRate(out *bytes.Buffer, _ fmtSpec, m Metrics, opts formatOptions) {
+	if opts.human {
+		out.WriteString(humanBytes(int64(m.OutputBytesPerSec)) + "/s")
+	} else {
+		out.WriteString(strconv.FormatInt(int64(m.OutputBytesPerSec), 10))
+	}
+}
+
+func format(tmpl string, m Metrics, opts formatOptions) string {
+	var out bytes.Buffer
+
+	//nolint:intrange // We need C-style loop to skip over parsed specifiers.
+	for i := 0; i < len(tmpl); i++ {
+		char := tmpl[i]
+
+		if char == '\\' && i+1 < len(tmpl) {
+			if escaped, ok := escapes[tmpl[i+1]]; ok {
+				out.WriteByte(escaped)
+				i++
+
+				continue
+			}
+		}
+
+		if char != '%' {
+			out.WriteByte(char)
+
+			continue
+		}
+
+		spec, end, ok := parseSpec(tmpl, i+1)
+		if !ok {
+			out.WriteString(tmpl[i:])
+
+			break
+		}
+
+		var field bytes.Buffer
+		if handleSpecifier(&field, spec, m, opts) {
+			writePadded(&out, field.String(), spec)
+		} else {
+			out.WriteString(tmpl[i : end+1])
+		}
+
+		i = end
+	}
+
+	return out.String()
+}
+
+// handleSpecifier renders spec into out, reporting false when it is unknown
+// or takes no star so that it is printed as written.
+func handleSpecifier(out *bytes.Buffer, spec fmtSpec, m Metrics, opts formatOptions) bool {
+	if spec.name != "" {
+		tmpl, ok := opts.custom[spec.name]
+		if !ok || spec.star || opts.depth >= maxSpecifierDepth {
+			return false
+		}
+
+		opts.depth++
+		out.WriteString(format(tmpl, m, opts))
+
+		return true
+	}
+
+	s, ok := opts.specifiers()[spec.verb]
+	if !ok || spec.star && !s.clock {
+		return false
+	}
+
+	if spec.precision < 0 && opts.micro {
+		spec.precision = microPrecision
+	}
+
+	s.render(out, spec, m, opts)
+
+	return true
+}
+
+// usesSpecifier reports whether the template contains a conversion with
+// one of the given verbs, directly or through a user-defined specifier, so
+// measurements that cost something can be skipped.
+func usesSpecifier(tmpl, verbs string, custom map[string]string) bool {
+	return usesSpecifierAt(tmpl, verbs, custom, 0)
+}
+
+func usesSpecifierAt(tmpl, verbs string, custom map[string]string, depth int) bool {
+	//nolint:intrange // We need C-style loop to skip over parsed specifiers.
+	for i := 0; i < len(tmpl); i++ {
+		if tmpl[i] != '%' {
+			continue
+		}
+
+		spec, end, ok := parseSpec(tmpl, i+1)
+		if !ok {
+			return false
+		}
+
+		if spec.name != "" {
+			if sub, found := custom[spec.name]; found && depth < maxSpecifierDepth &&
+				usesSpecifierAt(sub, verbs, custom, depth+1) {
+				return true
+			}
+		} else if strings.IndexByte(verbs, spec.verb) >= 0 {
+			return true
+		}
+
+		i = end
+	}
+
+	return false
+}
+
+// parseSpec parses the modifiers and verb of a conversion starting right
+// after the '%'. It returns the index of the verb, or of the closing brace
+// of %{name}, or false when the template ends before a verb is found.
+func parseSpec(tmpl string, start int) (fmtSpec, int, bool) {
+	spec := fmtSpec{precision: -1}
+	i := start
+
+	// zsh writes the star first (%*E); accept it after the modifiers too.
+	if i < len(tmpl) && tmpl[i] == '*' {
+		spec.star = true
+		i++
+	}
+
+	if i < len(tmpl) && tmpl[i] == '-' {
+		spec.left = true
+		i++
+	}
+
+	spec.width, i = parseDigits(tmpl, i)
+
+	if i < len(tmpl) && tmpl[i] == '.' {
+		spec.precision, i = parseDigits(tmpl, i+1)
+	}
+
+	if i < len(tmpl) && tmpl[i] == '*' {
+		spec.star = true
+		i++
+	}
+
+	if i >= len(tmpl) {
+		return spec, i, false
+	}
+
+	spec.verb = tmpl[i]
+
+	// An unclosed brace is left as the unknown verb '{'.
+	if spec.verb == '{' {
+		if n := strings.IndexByte(tmpl[i+1:], '}'); n > 0 {
+			spec.name = tmpl[i+1 : i+1+n]
+
+			return spec, i + 1 + n, true
+		}
+	}
+
+	return spec, i, true
+}
+
+// checkSpecifierNames reports an error for a user-defined specifier name
+// that %{name} cannot refer to.
+func checkSpecifierNames(custom map[string]string) error {
+	for name := range custom {
+		if name == "" || strings.ContainsAny(name, "{}") {
+			return fmt.Errorf("--specifier: %w: %q", errSpecifierName, name)
+		}
+	}
+
+	return nil
+}
+
+func parseDigits(tmpl string, i int) (int, int) {
+	n := 0
+	for i < len(tmpl) && tmpl[i] >= '0' && tmpl[i] <= '9' {
+		n = n*10 + int(tmpl[i]-'0')
+		i++
+	}
+
+	return n, i
+}
+
+func writePadded(out *bytes.Buffer, field string, spec fmtSpec) {
+	if spec.left {
+		fmt.Fprintf(out, "%-*s", spec.width, field)
+	} else {
+		fmt.Fprintf(out, "%*s", spec.width, field)
+	}
+}
+
+func writeDuration(out *bytes.Buffer, d time.Duration, spec fmtSpec) {
+	precision := spec.precision
+	if precision < 0 {
+		precision = defaultPrecision
+	}
+
+	if spec.star {
+		writeClock(out, d, precision)
+	} else {
+		fmt.Fprintf(out, "%.*fs", precision, d.Seconds())
+	}
+}
+
+// writeClock writes d as [h:]mm:ss.ff, the layout used by %*E.
+func writeClock(out *bytes.Buffer, d time.Duration, precision int) {
+	hours := int(d.Hours())
+	mins := int(d.Minutes()) % 60
+	secs := d.Seconds() - float64(int(d.Minutes())*60)
+
+	secWidth := 2
+	if precision > 0 {
+		secWidth += precision + 1
+	}
+
+	if hours > 0 {
+		fmt.Fprintf(out, "%d:%02d:%0*.*f", hours, mins, secWidth, precision, secs)
+	} else {
+		fmt.Fprintf(out, "%d:%0*.*f", mins, secWidth, precision, secs)
+	}
+}
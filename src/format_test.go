@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFormatEscapes(t *testing.T) {
+	t.Parallel()
+
+	m := Metrics{ElapsedTime: 1500 * time.Millisecond, CPUPercent: 12}
+
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{`%E\n%P`, "1.50s\n12%"},
+		{`%E\t%P`, "1.50s\t12%"},
+		{`a\\nb`, `a\nb`},
+		{`C:\temp\x`, "C:\temp\\x"},
+		{`end\`, `end\`},
+	}
+
+	for _, tt := range tests {
+		if got := format(tt.tmpl, m, formatOptions{}); got != tt.want {
+			t.Errorf("format(%q) = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestFormatCustomSpecifiers(t *testing.T) {
+	t.Parallel()
+
+	m := Metrics{Command: "make", ElapsedTime: 2 * time.Second, MaxRSS: 2048}
+	opts := formatOptions{custom: map[string]string{
+		"mem":   "%M KiB",
+		"both":  "%E/%{mem}",
+		"loop":  "%{loop}",
+		"lines": `%J\n%E`,
+	}}
+
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{"%{mem}", "2048 KiB"},
+		{"[%-10{mem}]", "[2048 KiB  ]"},
+		{"[%10{mem}]", "[  2048 KiB]"},
+		{"%{both}", "2.00s/2048 KiB"},
+		{"%{lines}", "make\n2.00s"},
+		{"%{nope} %E", "%{nope} 2.00s"},
+		{"%*{mem}", "%*{mem}"},
+		{"%{mem", "%{mem"},
+		{"%{}", "%{}"},
+		{"%{loop}", "%{loop}"},
+	}
+
+	for _, tt := range tests {
+		if got := format(tt.tmpl, m, opts); got != tt.want {
+			t.Errorf("format(%q) = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestUsesSpecifierCustom(t *testing.T) {
+	t.Parallel()
+
+	custom := map[string]string{"first": "%f", "outer": "%{first}", "loop": "%{loop}"}
+
+	tests := []struct {
+		tmpl string
+		want bool
+	}{
+		{"%{first}", true},
+		{"%{outer} %E", true},
+		{"%{loop}", false},
+		{"%{nope}", false},
+	}
+
+	for _, tt := range tests {
+		if got := usesSpecifier(tt.tmpl, "f", custom); got != tt.want {
+			t.Errorf("usesSpecifier(%q) = %v, want %v", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestCheckSpecifierNames(t *testing.T) {
+	t.Parallel()
+
+	if err := checkSpecifierNames(map[string]string{"mem": "%M", "peak rss": "%m"}); err != nil {
+		t.Errorf("checkSpecifierNames() error = %v", err)
+	}
+
+	for _, name := range []string{"", "a}b", "{x"} {
+		if err := checkSpecifierNames(map[string]string{name: "%E"}); !errors.Is(err, errSpecifierName) {
+			t.Errorf("checkSpecifierNames(%q) error = %v, want %v", name, err, errSpecifierName)
+		}
+	}
+}
+
+func TestFormatGNUDialect(t *testing.T) {
+	t.Parallel()
+
+	m := Metrics{
+		Command:      "make -j8",
+		UserTime:     1500 * time.Millisecond,
+		SystemTime:   250 * time.Millisecond,
+		ElapsedTime:  62 * time.Second,
+		CPUPercent:   2,
+		MaxRSS:       2048,
+		UnsharedData: 100,
+		UnsharedStk:  20,
+		SharedRSS:    7,
+		ExitCode:     3,
+		Signals:      1,
+	}
+	gnu := formatOptions{dialect: "gnu"}
+
+	tests := []struct {
+		tmpl string
+		m    Metrics
+		want string
+	}{
+		{"%C: %e real %U user %S sys", m, "make -j8: 62.00 real 1.50 user 0.25 sys"},
+		{"%E %P", m, "1:02.00 2%"},
+		{"%E", Metrics{ElapsedTime: time.Hour + 2*time.Minute + 3500*time.Millisecond}, "1:02:04"},
+		{"%x %k", m, "3 1"},
+		{"%MKB %t %p %D %X %K", m, "2048KB 100 20 120 7 127"},
+		{"%.1e", m, "62.0"},
+		{`%e\t%J`, m, "62.00\t%J"},
+	}
+
+	for _, tt := range tests {
+		if got := format(tt.tmpl, tt.m, gnu); got != tt.want {
+			t.Errorf("format(%q) = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestFormatExitStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		m    Metrics
+		want string
+	}{
+		{Metrics{ExitCode: 0}, "0 -"},
+		{Metrics{ExitCode: 3}, "3 -"},
+		{Metrics{ExitCode: -1, Killed: true, TermSignal: "SIGKILL"}, "-1 SIGKILL"},
+	}
+
+	for _, tt := range tests {
+		if got := format("%x %g", tt.m, formatOptions{}); got != tt.want {
+			t.Errorf("format(%%x %%g) = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestFormatMicro(t *testing.T) {
+	t.Parallel()
+
+	m := Metrics{ElapsedTime: 1234567 * time.Nanosecond, UserTime: 1000 * time.Nanosecond}
+	micro := formatOptions{micro: true}
+
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{"%E %U", "0.001235s 0.000001s"},
+		{"%*E", "0:00.001235"},
+		{"%.3E", "0.001s"},
+	}
+
+	for _, tt := range tests {
+		if got := format(tt.tmpl, m, micro); got != tt.want {
+			t.Errorf("format(%q) = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+
+	if got, want := summaryLine(m, micro), "  0.000001s user 0.000000s system 0% cpu 0.001235s total"; got != want {
+		t.Errorf("summaryLine() = %q, want %q", got, want)
+	}
+}
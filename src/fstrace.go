@@ -0,0 +1,99 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// FSTrace counts what the command and its children did to each file, as
+// recorded with --fs-trace.
+type FSTrace struct {
+	Files      int            `json:"files" unit:"count"`
+	Opens      int64          `json:"opens" unit:"count"`
+	Reads      int64          `json:"reads" unit:"count"`
+	Writes     int64          `json:"writes" unit:"count"`
+	ReadBytes  int64          `json:"read_bytes" unit:"bytes"`  // estimated from file offsets
+	WriteBytes int64          `json:"write_bytes" unit:"bytes"` // estimated from file offsets
+	Top        []PathActivity `json:"top"`                      // most bytes first
+	Incomplete bool           `json:"incomplete,omitempty"`
+}
+
+// PathActivity is one file in an FSTrace. Reads and writes count the
+// access events the kernel reported, which it merges while they are
+// queued, so they are lower bounds on the system calls made.
+type PathActivity struct {
+	Path       string `json:"path"`
+	Opens      int64  `json:"opens" unit:"count"`
+	Reads      int64  `json:"reads" unit:"count"`
+	Writes     int64  `json:"writes" unit:"count"`
+	ReadBytes  int64  `json:"read_bytes" unit:"bytes"`
+	WriteBytes int64  `json:"write_bytes" unit:"bytes"`
+}
+
+// add combines the activity of two processes on the same file.
+func (a PathActivity) add(b PathActivity) PathActivity {
+	a.Opens += b.Opens
+	a.Reads += b.Reads
+	a.Writes += b.Writes
+	a.ReadBytes += b.ReadBytes
+	a.WriteBytes += b.WriteBytes
+
+	return a
+}
+
+func (a PathActivity) bytes() int64 {
+	return a.ReadBytes + a.WriteBytes
+}
+
+func (a PathActivity) events() int64 {
+	return a.Opens + a.Reads + a.Writes
+}
+
+func newFSTrace(paths map[string]PathActivity, top int, incomplete bool) *FSTrace {
+	t := &FSTrace{Files: len(paths), Incomplete: incomplete}
+
+	all := make([]PathActivity, 0, len(paths))
+
+	for _, a := range paths {
+		t.Opens += a.Opens
+		t.Reads += a.Reads
+		t.Writes += a.Writes
+		t.ReadBytes += a.ReadBytes
+		t.WriteBytes += a.WriteBytes
+
+		all = append(all, a)
+	}
+
+	slices.SortFunc(all, func(a, b PathActivity) int {
+		return cmp.Or(cmp.Compare(b.bytes(), a.bytes()), cmp.Compare(b.events(), a.events()), strings.Compare(a.Path, b.Path))
+	})
+
+	t.Top = all[:min(top, len(all))]
+
+	return t
+}
+
+// fsTraceNote describes the file activity over several lines, or returns ""
+// if none was recorded.
+func fsTraceNote(t *FSTrace) string {
+	if t == nil {
+		return ""
+	}
+
+	var note strings.Builder
+
+	fmt.Fprintf(&note, "fs: %d files, %d opens, %d reads (%s), %d writes (%s)",
+		t.Files, t.Opens, t.Reads, humanBytes(t.ReadBytes), t.Writes, humanBytes(t.WriteBytes))
+
+	if t.Incomplete {
+		note.WriteString(", some accesses were missed")
+	}
+
+	for _, a := range t.Top {
+		fmt.Fprintf(&note, "\n  %10s r  %10s w  %6d opens  %s", humanBytes(a.ReadBytes), humanBytes(a.WriteBytes), a.Opens, a.Path)
+	}
+
+	return note.String()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewFSTrace(t *testing.T) {
+	t.Parallel()
+
+	paths := map[string]PathActivity{
+		"/tmp/out":     {Path: "/tmp/out", Opens: 1, Writes: 40, WriteBytes: 4096},
+		"/lib/libc.so": {Path: "/lib/libc.so", Opens: 3, Reads: 3, ReadBytes: 2048},
+		"/etc/hosts":   {Path: "/etc/hosts", Opens: 2},
+		"/etc/passwd":  {Path: "/etc/passwd", Opens: 1},
+	}
+
+	ft := newFSTrace(paths, 3, false)
+
+	if ft.Files != 4 || ft.Opens != 7 || ft.Reads != 3 || ft.Writes != 40 || ft.ReadBytes != 2048 || ft.WriteBytes != 4096 {
+		t.Errorf("newFSTrace() = %+v", ft)
+	}
+
+	var top []string
+	for _, a := range ft.Top {
+		top = append(top, a.Path)
+	}
+
+	if got, want := strings.Join(top, " "), "/tmp/out /lib/libc.so /etc/hosts"; got != want {
+		t.Errorf("Top = %s, want %s", got, want)
+	}
+}
+
+func TestPathActivityAdd(t *testing.T) {
+	t.Parallel()
+
+	got := PathActivity{Path: "/f", Opens: 1, Reads: 2, ReadBytes: 10}.add(PathActivity{Opens: 1, Writes: 3, WriteBytes: 7})
+	if got != (PathActivity{Path: "/f", Opens: 2, Reads: 2, Writes: 3, ReadBytes: 10, WriteBytes: 7}) {
+		t.Errorf("add() = %+v", got)
+	}
+}
+
+func TestFSTraceNote(t *testing.T) {
+	t.Parallel()
+
+	if got := fsTraceNote(nil); got != "" {
+		t.Errorf("fsTraceNote(nil) = %q, want empty", got)
+	}
+
+	ft := &FSTrace{Files: 1, Opens: 2, Reads: 5, ReadBytes: 2048, Incomplete: true, Top: []PathActivity{{Path: "/f", Opens: 2, Reads: 5, ReadBytes: 2048}}}
+
+	const want = "fs: 1 files, 2 opens, 5 reads (2.0 KiB), 0 writes (0 B), some accesses were missed\n     2.0 KiB r         0 B w       2 opens  /f"
+	if got := fsTraceNote(ft); got != want {
+		t.Errorf("fsTraceNote() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+var errGit = errors.New("reading git metadata")
+
+// GitInfo identifies the version of the code in a git working tree.
+type GitInfo struct {
+	Commit string `json:"commit"`
+	Branch string `json:"branch,omitempty"` // empty on a detached HEAD
+	Dirty  bool   `json:"dirty"`            // uncommitted changes, including untracked files
+}
+
+// readGitInfo describes the working tree containing dir.
+func readGitInfo(dir string) (*GitInfo, error) {
+	commit, err := git(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := git(dir, "status", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+
+	// symbolic-ref fails on a detached HEAD, which has no branch.
+	branch, _ := git(dir, "symbolic-ref", "--short", "-q", "HEAD")
+
+	return &GitInfo{Commit: commit, Branch: branch, Dirty: status != ""}, nil
+}
+
+// git runs a git command in dir and returns its trimmed output.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(context.Background(), "git", args...)
+	cmd.Dir = dir
+
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("%w: %s", errGit, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+
+		return "", fmt.Errorf("%w: %w", errGit, err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
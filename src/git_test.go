@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadGitInfo(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+
+	run := func(args ...string) {
+		t.Helper()
+
+		args = append([]string{"-c", "user.name=ztime", "-c", "user.email=ztime@example.com", "-c", "commit.gpgsign=false"}, args...)
+		if _, err := git(dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := readGitInfo(dir); !errors.Is(err, errGit) {
+		t.Fatalf("readGitInfo() outside a repository: error = %v, want %v", err, errGit)
+	}
+
+	run("init", "-q", "-b", "trunk")
+
+	if err := os.WriteFile(filepath.Join(dir, "main.c"), []byte("int main;\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	run("add", "main.c")
+	run("commit", "-q", "-m", "initial")
+
+	info, err := readGitInfo(dir)
+	if err != nil {
+		t.Fatalf("readGitInfo() error = %v", err)
+	}
+
+	if len(info.Commit) != 40 || info.Branch != "trunk" || info.Dirty {
+		t.Errorf("readGitInfo() = %+v, want a clean trunk commit", info)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "new.c"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	run("checkout", "-q", "--detach")
+
+	info, err = readGitInfo(dir)
+	if err != nil {
+		t.Fatalf("readGitInfo() error = %v", err)
+	}
+
+	if info.Branch != "" || !info.Dirty {
+		t.Errorf("readGitInfo() = %+v, want a dirty detached HEAD", info)
+	}
+}
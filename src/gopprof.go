@@ -0,0 +1,176 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GoProfile is a profile fetched from the command's pprof endpoint with
+// --go-pprof.
+type GoProfile struct {
+	Profile string        `json:"profile"` // cpu, heap, allocs, goroutine, block, or mutex
+	At      time.Duration `json:"at"`      // since the run started
+	File    string        `json:"file,omitempty"`
+	Error   string        `json:"error,omitempty"` // why it could not be fetched
+}
+
+// goPprofConfig is what --go-pprof fetches and where it saves it.
+type goPprofConfig struct {
+	addr     string
+	at       []time.Duration
+	profiles []string
+	cpu      time.Duration // length of each CPU profile
+	dir      string
+}
+
+// goPprofFetcher fetches profiles from a Go program's net/http/pprof
+// endpoint at set points of a run.
+type goPprofFetcher struct {
+	config *goPprofConfig
+	prefix string // file name prefix telling the runs of a benchmark apart
+	cancel context.CancelFunc
+	timers []*time.Timer
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	profiles []GoProfile
+}
+
+// fetchGoProfiles schedules the fetches of a run that started at start. It
+// returns nil if config is nil.
+func fetchGoProfiles(config *goPprofConfig, start time.Time, iteration, totalRuns int) *goPprofFetcher {
+	if config == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &goPprofFetcher{config: config, cancel: cancel}
+
+	if totalRuns != 1 {
+		f.prefix = "run" + strconv.Itoa(max(iteration, 1)) + "-"
+	}
+
+	for _, at := range config.at {
+		f.wg.Add(1)
+		f.timers = append(f.timers, time.AfterFunc(time.Until(start.Add(at)), func() {
+			defer f.wg.Done()
+
+			for _, profile := range config.profiles {
+				f.wg.Go(func() { f.fetch(ctx, profile, at) })
+			}
+		}))
+	}
+
+	return f
+}
+
+// fetch saves one profile and records the outcome.
+func (f *goPprofFetcher) fetch(ctx context.Context, profile string, at time.Duration) {
+	p := GoProfile{Profile: profile, At: at}
+
+	data, err := download(ctx, goPprofURL(f.config.addr, profile, f.config.cpu))
+	if err == nil {
+		p.File = filepath.Join(f.config.dir, f.prefix+profile+"-"+at.String()+".pb.gz")
+
+		if err = os.MkdirAll(f.config.dir, 0o750); err == nil {
+			//nolint:gosec // Intended behavior: the profile is for the user to read.
+			err = os.WriteFile(p.File, data, 0o644)
+		}
+	}
+
+	if err != nil {
+		p.File = ""
+		p.Error = err.Error()
+	}
+
+	f.mu.Lock()
+	f.profiles = append(f.profiles, p)
+	f.mu.Unlock()
+}
+
+// stop cancels the fetches not yet due, abandons those in flight, as the
+// program they ask has exited, and stores the outcomes in m.
+func (f *goPprofFetcher) stop(m *Metrics) {
+	if f == nil {
+		return
+	}
+
+	for _, timer := range f.timers {
+		if timer.Stop() {
+			f.wg.Done()
+		}
+	}
+
+	f.cancel()
+	f.wg.Wait()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	slices.SortFunc(f.profiles, func(a, b GoProfile) int {
+		return cmp.Or(cmp.Compare(a.At, b.At), strings.Compare(a.Profile, b.Profile))
+	})
+
+	m.GoProfiles = f.profiles
+}
+
+// goPprofURL is where net/http/pprof serves a profile. addr is a host and
+// port, or a URL to serve the /debug/pprof/ handlers from another prefix.
+func goPprofURL(addr, profile string, cpu time.Duration) string {
+	base := strings.TrimSuffix(addr, "/")
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+
+	if !strings.HasSuffix(base, "/debug/pprof") {
+		base += "/debug/pprof"
+	}
+
+	if profile == "cpu" {
+		return base + "/profile?seconds=" + strconv.Itoa(max(int(cpu.Seconds()), 1))
+	}
+
+	return base + "/" + profile
+}
+
+// goPprofNote says how many profiles were saved and why others were not,
+// or returns "" without --go-pprof.
+func goPprofNote(m Metrics) string {
+	if len(m.GoProfiles) == 0 {
+		return ""
+	}
+
+	saved, dir := 0, ""
+
+	var failed []string
+
+	for _, p := range m.GoProfiles {
+		if p.File != "" {
+			saved++
+			dir = filepath.Dir(p.File)
+
+			continue
+		}
+
+		failed = append(failed, fmt.Sprintf("%s at %s: %s", p.Profile, p.At, p.Error))
+	}
+
+	note := fmt.Sprintf("go pprof: saved %d of %d profiles", saved, len(m.GoProfiles))
+	if dir != "" {
+		note += " to " + dir
+	}
+
+	if len(failed) > 0 {
+		note += " (" + strings.Join(failed, "; ") + ")"
+	}
+
+	return note
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGoPprofURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		addr, profile string
+		cpu           time.Duration
+		want          string
+	}{
+		{"localhost:6060", "heap", 0, "http://localhost:6060/debug/pprof/heap"},
+		{"localhost:6060", "cpu", 10 * time.Second, "http://localhost:6060/debug/pprof/profile?seconds=10"},
+		{"localhost:6060", "cpu", 200 * time.Millisecond, "http://localhost:6060/debug/pprof/profile?seconds=1"},
+		{"https://svc.internal/admin/", "goroutine", 0, "https://svc.internal/admin/debug/pprof/goroutine"},
+		{"http://localhost:6060/debug/pprof/", "allocs", 0, "http://localhost:6060/debug/pprof/allocs"},
+	}
+
+	for _, tt := range tests {
+		if got := goPprofURL(tt.addr, tt.profile, tt.cpu); got != tt.want {
+			t.Errorf("goPprofURL(%q, %q, %v) = %q, want %q", tt.addr, tt.profile, tt.cpu, got, tt.want)
+		}
+	}
+}
+
+func TestFetchGoProfiles(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/debug/pprof/heap":
+			_, _ = w.Write([]byte("heap profile"))
+		case "/debug/pprof/profile":
+			_, _ = w.Write([]byte("cpu profile " + r.URL.Query().Get("seconds")))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := filepath.Join(t.TempDir(), "pprof")
+	config := &goPprofConfig{
+		addr:     server.URL,
+		at:       []time.Duration{0, time.Hour},
+		profiles: []string{"heap", "cpu", "mutex"},
+		cpu:      2 * time.Second,
+		dir:      dir,
+	}
+
+	f := fetchGoProfiles(config, time.Now(), 2, 3)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		f.mu.Lock()
+		n := len(f.profiles)
+		f.mu.Unlock()
+
+		if n == 3 {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var m Metrics
+
+	f.stop(&m)
+
+	if len(m.GoProfiles) != 3 {
+		t.Fatalf("GoProfiles = %+v, want the three due at 0s", m.GoProfiles)
+	}
+
+	want := map[string]string{"cpu": "cpu profile 2", "heap": "heap profile"}
+
+	for _, p := range m.GoProfiles {
+		if p.Profile == "mutex" {
+			if p.File != "" || p.Error == "" {
+				t.Errorf("mutex profile = %+v, want an error", p)
+			}
+
+			continue
+		}
+
+		if wantFile := filepath.Join(dir, "run2-"+p.Profile+"-0s.pb.gz"); p.File != wantFile {
+			t.Errorf("%s file = %q, want %q", p.Profile, p.File, wantFile)
+		}
+
+		if data, err := os.ReadFile(p.File); err != nil || string(data) != want[p.Profile] {
+			t.Errorf("%s profile = %q, %v; want %q", p.Profile, data, err, want[p.Profile])
+		}
+	}
+
+	if got, want := goPprofNote(m), "go pprof: saved 2 of 3 profiles to "+dir; !strings.HasPrefix(got, want) {
+		t.Errorf("goPprofNote() = %q, want prefix %q", got, want)
+	}
+}
+
+func TestFetchGoProfilesDisabled(t *testing.T) {
+	t.Parallel()
+
+	var m Metrics
+
+	fetchGoProfiles(nil, time.Now(), 1, 1).stop(&m)
+
+	if m.GoProfiles != nil || goPprofNote(m) != "" {
+		t.Errorf("GoProfiles = %+v, want none", m.GoProfiles)
+	}
+}
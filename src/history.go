@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// historyDir returns the directory that holds recorded runs,
+// $XDG_DATA_HOME/ztime/history or ~/.local/share/ztime/history.
+func historyDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "ztime", "history"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".local", "share", "ztime", "history"), nil
+}
+
+// historyPath returns the file of recorded runs of args in the working
+// directory. Unlike the --cache key, the environment is left out, so that
+// runs from different shells share a history.
+func historyPath(args []string) (string, error) {
+	dir, err := historyDir()
+	if err != nil {
+		return "", err
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	writeField(h, "argv", args...)
+	writeField(h, "wd", wd)
+
+	return filepath.Join(dir, hex.EncodeToString(h.Sum(nil))+".jsonl"), nil
+}
+
+// loadHistory reads the runs recorded in path, oldest first. A missing
+// file is an empty history, and lines that do not parse are skipped.
+func loadHistory(path string) ([]Metrics, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var runs []Metrics
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16<<20)
+
+	for scanner.Scan() {
+		var m Metrics
+		if json.Unmarshal(scanner.Bytes(), &m) == nil {
+			runs = append(runs, m)
+		}
+	}
+
+	return runs, scanner.Err()
+}
+
+// appendHistory records m at the end of the history in path.
+func appendHistory(path string, m Metrics) error {
+	data, err := json.Marshal(versioned(m))
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "history", "runs.jsonl")
+
+	runs, err := loadHistory(path)
+	if err != nil || len(runs) != 0 {
+		t.Fatalf("loadHistory() of a missing file = %v, %v; want an empty history", runs, err)
+	}
+
+	for _, d := range []time.Duration{time.Second, 2 * time.Second} {
+		if err := appendHistory(path, Metrics{Command: "make", ElapsedTime: d}); err != nil {
+			t.Fatalf("appendHistory() error = %v", err)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = f.WriteString("{truncated\n")
+	_ = f.Close()
+
+	runs, err = loadHistory(path)
+	if err != nil {
+		t.Fatalf("loadHistory() error = %v", err)
+	}
+
+	if len(runs) != 2 || runs[0].ElapsedTime != time.Second || runs[1].ElapsedTime != 2*time.Second {
+		t.Errorf("loadHistory() = %+v, want the two recorded runs in order", runs)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errHookTime   = errors.New("invalid time")
+	errReportTime = errors.New("invalid threshold")
+)
+
+// defaultReportTime is the threshold of the shell hooks when
+// ZTIME_REPORTWALL is not set.
+const defaultReportTime = 5 * time.Second
+
+// hookCmd prints the shell integration for "eval".
+type hookCmd struct {
+	Shell string `arg:"" enum:"zsh,bash,fish" help:"Shell to print the hook for (${enum})."`
+}
+
+// hookReportCmd is what the shell hooks run after every command. The shell
+// measures the elapsed time, as it has no way to hand over the command's
+// resource usage, so its threshold is one of wall-clock time and is not
+// taken from REPORTTIME, which zsh compares with CPU time.
+type hookReportCmd struct {
+	Start     string        `help:"When the command started, in seconds since the epoch, as in EPOCHREALTIME." placeholder:"SECONDS"`
+	End       string        `help:"When the command ended, in seconds since the epoch." placeholder:"SECONDS"`
+	Elapsed   time.Duration `help:"How long the command took, instead of --start and --end."`
+	Exit      int           `help:"Exit status of the command."`
+	Threshold string        `help:"Report only commands whose elapsed time was at least this long, in seconds or as a duration; negative disables reports (default 5s)." placeholder:"SECONDS"`
+	Command   string        `arg:"" optional:"" help:"Command line as typed."`
+}
+
+// Run prints the hook.
+func (c *hookCmd) Run() error {
+	fmt.Print(hookScripts()[c.Shell])
+
+	return nil
+}
+
+// hookScripts returns the hook of each shell. Each records when a command
+// starts and hands the times to "ztime hook-report" before the next
+// prompt, and leaves the threshold to it. The bash hook keeps running any
+// DEBUG trap that was set before it, such as bash-preexec's.
+func hookScripts() map[string]string {
+	return map[string]string{
+		"zsh": `zmodload zsh/datetime
+_ztime_preexec() {
+  _ztime_start=$EPOCHREALTIME
+  _ztime_command=$1
+}
+_ztime_precmd() {
+  local exit=$?
+  [[ -n $_ztime_start ]] || return 0
+  command ztime hook-report --start "$_ztime_start" --end "$EPOCHREALTIME" --exit "$exit" \
+    --threshold "${ZTIME_REPORTWALL-}" -- "$_ztime_command"
+  unset _ztime_start _ztime_command
+}
+autoload -Uz add-zsh-hook
+add-zsh-hook preexec _ztime_preexec
+add-zsh-hook precmd _ztime_precmd
+`,
+		"bash": `_ztime_preexec() {
+  [[ -n ${_ztime_ready-} && -z ${COMP_LINE-} && $BASH_COMMAND != _ztime_precmd* ]] || return 0
+  _ztime_ready=
+  _ztime_start=${EPOCHREALTIME:-$SECONDS}
+  _ztime_command=$BASH_COMMAND
+}
+_ztime_precmd() {
+  local exit=$?
+  if [[ -n ${_ztime_start-} ]]; then
+    command ztime hook-report --start "$_ztime_start" --end "${EPOCHREALTIME:-$SECONDS}" --exit "$exit" \
+      --threshold "${ZTIME_REPORTWALL-}" -- "$_ztime_command"
+    unset _ztime_start _ztime_command
+  fi
+  _ztime_ready=1
+}
+_ztime_debug_trap=$(trap -p DEBUG)
+if [[ $_ztime_debug_trap != *_ztime_preexec* ]]; then
+  _ztime_debug_trap=${_ztime_debug_trap#"trap -- "}
+  eval "_ztime_debug_trap=${_ztime_debug_trap%" DEBUG"}"
+  trap 'eval "$_ztime_debug_trap"; _ztime_preexec' DEBUG
+fi
+PROMPT_COMMAND="_ztime_precmd${PROMPT_COMMAND:+; $PROMPT_COMMAND}"
+`,
+		"fish": `function _ztime_postexec --on-event fish_postexec
+    set -l exit $status
+    set -q CMD_DURATION; or return
+    set -l threshold
+    set -q ZTIME_REPORTWALL; and set threshold $ZTIME_REPORTWALL
+    command ztime hook-report --elapsed {$CMD_DURATION}ms --exit $exit --threshold "$threshold" -- $argv[1]
+end
+`,
+	}
+}
+
+// Run prints the summary of a command that took at least the threshold.
+func (c *hookReportCmd) Run() error {
+	threshold, err := parseReportTime(c.Threshold)
+	if err != nil {
+		return err
+	}
+
+	elapsed := c.Elapsed
+	if c.Start != "" || c.End != "" {
+		if elapsed, err = epochElapsed(c.Start, c.End); err != nil {
+			return err
+		}
+	}
+
+	if threshold < 0 || elapsed < threshold {
+		return nil
+	}
+
+	fmt.Fprintln(os.Stderr, hookSummary(c.Command, elapsed, c.Exit))
+
+	return nil
+}
+
+// hookSummary is the line printed for a command reported by a shell hook.
+func hookSummary(command string, elapsed time.Duration, exit int) string {
+	var clock bytes.Buffer
+
+	writeClock(&clock, elapsed, defaultPrecision)
+
+	line := fmt.Sprintf("%s  %s total", palette.command.Render(command), palette.total.Render(clock.String()))
+	if exit != 0 {
+		line += " " + palette.bad.Render(fmt.Sprintf("(exit %d)", exit))
+	}
+
+	return line
+}
+
+// parseReportTime reads a threshold in seconds, as zsh's REPORTTIME holds
+// it, or as a duration such as "500ms".
+func parseReportTime(s string) (time.Duration, error) {
+	if s == "" {
+		return defaultReportTime, nil
+	}
+
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", errReportTime, s)
+	}
+
+	return d, nil
+}
+
+// epochElapsed returns the time between two EPOCHREALTIME readings, which
+// use the locale's decimal separator.
+func epochElapsed(start, end string) (time.Duration, error) {
+	var times [2]float64
+
+	for i, s := range []string{start, end} {
+		secs, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q", errHookTime, s)
+		}
+
+		times[i] = secs
+	}
+
+	return max(time.Duration((times[1]-times[0])*float64(time.Second)), 0), nil
+}
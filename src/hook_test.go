@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseReportTime(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr error
+	}{
+		{"", defaultReportTime, nil},
+		{"10", 10 * time.Second, nil},
+		{"0.5", 500 * time.Millisecond, nil},
+		{"-1", -time.Second, nil},
+		{"750ms", 750 * time.Millisecond, nil},
+		{"soon", 0, errReportTime},
+	}
+
+	for _, tt := range tests {
+		got, err := parseReportTime(tt.in)
+		if !errors.Is(err, tt.wantErr) || got != tt.want {
+			t.Errorf("parseReportTime(%q) = %v, %v; want %v, %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestEpochElapsed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		start, end string
+		want       time.Duration
+		wantErr    error
+	}{
+		{"1700000000.250000", "1700000002.750000", 2500 * time.Millisecond, nil},
+		{"1700000000,5", "1700000001,5", time.Second, nil},
+		{"100", "103", 3 * time.Second, nil},
+		{"5", "4", 0, nil},
+		{"", "4", 0, errHookTime},
+	}
+
+	for _, tt := range tests {
+		got, err := epochElapsed(tt.start, tt.end)
+		if !errors.Is(err, tt.wantErr) || (got-tt.want).Abs() > time.Microsecond {
+			t.Errorf("epochElapsed(%q, %q) = %v, %v; want %v, %v", tt.start, tt.end, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestHookSummary(t *testing.T) {
+	t.Parallel()
+
+	if got, want := hookSummary("make", 62*time.Second, 0), "make  1:02.00 total"; got != want {
+		t.Errorf("hookSummary() = %q, want %q", got, want)
+	}
+
+	if got := hookSummary("make", time.Second, 2); !strings.HasSuffix(got, "(exit 2)") {
+		t.Errorf("hookSummary() = %q, want the exit status", got)
+	}
+}
+
+func TestHookScriptsParse(t *testing.T) {
+	t.Parallel()
+
+	for shell, script := range hookScripts() {
+		path, err := exec.LookPath(shell)
+		if err != nil {
+			continue
+		}
+
+		flag := "-n"
+		if shell == "fish" {
+			flag = "--no-execute"
+		}
+
+		cmd := exec.CommandContext(t.Context(), path, flag)
+		cmd.Stdin = strings.NewReader(script)
+
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("%s rejects its hook: %v\n%s", shell, err, out)
+		}
+	}
+}
+
+func TestBashHookKeepsDebugTrap(t *testing.T) {
+	t.Parallel()
+
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not available")
+	}
+
+	script := "trap 'traced=$((traced+1))' DEBUG\n" + hookScripts()["bash"] + `traced=0
+:
+echo "$traced"
+trap -p DEBUG
+`
+
+	out, err := exec.CommandContext(t.Context(), bash, "-c", script).CombinedOutput()
+	if err != nil {
+		t.Fatalf("bash error = %v\n%s", err, out)
+	}
+
+	count, trap, _ := strings.Cut(string(out), "\n")
+	if count == "0" || !strings.Contains(trap, "_ztime_preexec") {
+		t.Errorf("bash output = %q, want the earlier trap still counting and the hook's trap set", out)
+	}
+}
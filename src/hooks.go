@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+var errHookFailed = errors.New("hook failed")
+
+// measure runs the command once between the --prepare and --cleanup hooks,
+// after waiting out --cooldown and --require-idle and dropping caches with
+// --drop-caches. None of this is part of the timing. A failure before the
+// run counts as a run that could not start; a failing cleanup hook fails an
+// otherwise successful run. With --ssh the command runs on the remote host
+// and with --container in a container, while the hooks still run locally.
+// The result is sent to --statsd.
+func (c *runCmd) measure(args []string, opts runOptions) (Metrics, error) {
+	if err := c.beforeRun(opts); err != nil {
+		return Metrics{Command: strings.Join(args, " "), RunID: opts.runID, ExitCode: -1}, err
+	}
+
+	m, err := c.runner()(args, opts)
+	c.lastRunEnd = time.Now() // m.EndTime is the remote clock's with --ssh
+
+	m.RunID = opts.runID
+	subtractOverhead(&m, c.overhead)
+
+	if hookErr := runHook("cleanup", c.Cleanup, opts); hookErr != nil && err == nil {
+		err = hookErr
+	}
+
+	m.Orphans = c.collectOrphans()
+	m.Annotations = c.annotations()
+	opts.events.emit(event{Type: eventRunFinished, Iteration: max(opts.iteration, 1), Result: m})
+	c.sendStatsD(m)
+
+	return m, err
+}
+
+// runner returns how the command is run: locally, on the --ssh host, or in
+// a --container.
+func (c *runCmd) runner() func([]string, runOptions) (Metrics, error) {
+	switch {
+	case c.SSH != "":
+		return c.runSSH
+	case c.Container != "":
+		return c.runContainer
+	default:
+		return runCommand
+	}
+}
+
+func (c *runCmd) beforeRun(opts runOptions) error {
+	c.coolDown()
+
+	if err := c.waitIdle(); err != nil {
+		return err
+	}
+
+	if c.DropCaches {
+		opts.debug.printf("dropping the page cache")
+
+		if err := c.privileged(privilegedDropCaches); err != nil {
+			return err
+		}
+	}
+
+	return runHook("prepare", c.Prepare, opts)
+}
+
+// runHook runs a --prepare or --cleanup script with the same ZTIME_*
+// variables as the command. Its output goes to stderr so that the
+// command's stdout stays clean, and it gets no stdin.
+func runHook(name, script string, opts runOptions) error {
+	if script == "" {
+		return nil
+	}
+
+	opts.debug.printf("running %s hook: %s", name, script)
+
+	cmd := shellCommand(context.Background(), script)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = runEnv(opts)
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s %q: %v", errHookFailed, name, script, err) //nolint:errorlint // Keep exec errors from being read as the command's exit status.
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMeasureHooks(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	tests := []struct {
+		name     string
+		prepare  string
+		cleanup  string
+		hookFail bool
+		ran      bool
+	}{
+		{name: "No Hooks", ran: true},
+		{name: "Passing Hooks", prepare: "true", cleanup: "true", ran: true},
+		{name: "Failing Prepare", prepare: "exit 3", hookFail: true},
+		{name: "Failing Cleanup", cleanup: "false", hookFail: true, ran: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := runCmd{Prepare: tt.prepare, Cleanup: tt.cleanup}
+
+			m, err := c.measure([]string{"true"}, runOptions{})
+			if got := errors.Is(err, errHookFailed); got != tt.hookFail {
+				t.Errorf("measure() error = %v, want hook failure %v", err, tt.hookFail)
+			}
+
+			if ran := m.ExitCode == 0; ran != tt.ran {
+				t.Errorf("measure() exit code = %d, want command run %v", m.ExitCode, tt.ran)
+			}
+		})
+	}
+}
+
+func TestMeasureAnnotations(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("true"); err != nil {
+		t.Skip("true not available")
+	}
+
+	c := runCmd{Tag: map[string]string{"branch": "main"}, Note: "nightly", Runs: 2, Command: []string{"true"}}
+
+	results, err := c.runBenches(benchState{name: "true", command: c.Command})
+	if err != nil {
+		t.Fatalf("runBenches() error = %v", err)
+	}
+
+	r := results[0]
+	if r.Tags["branch"] != "main" || r.Note != "nightly" {
+		t.Errorf("BenchResult tags %v, note %q; want the --tag and --note values", r.Tags, r.Note)
+	}
+
+	for i, m := range r.Results {
+		if m.Tags["branch"] != "main" || m.Note != "nightly" {
+			t.Errorf("run %d tags %v, note %q; want the --tag and --note values", i+1, m.Tags, m.Note)
+		}
+	}
+}
+
+func TestBenchWallTimeIncludesHooks(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("true"); err != nil {
+		t.Skip("true not available")
+	}
+
+	c := runCmd{Runs: 2, Prepare: "sleep 0.05", Command: []string{"true"}}
+
+	results, err := c.runBenches(benchState{name: "true", command: c.Command})
+	if err != nil {
+		t.Fatalf("runBenches() error = %v", err)
+	}
+
+	if r := results[0]; r.WallTime < 100*time.Millisecond {
+		t.Errorf("WallTime = %v, want at least the two 50ms --prepare hooks", r.WallTime)
+	}
+}
+
+// fakeSudo puts a sudo on PATH that appends its arguments to the returned
+// log and exits with status.
+func fakeSudo(t *testing.T, status int) string {
+	t.Helper()
+
+	return fakeSudoScript(t, fmt.Sprintf("exit %d", status))
+}
+
+// fakeSudoScript puts a sudo on PATH that appends its arguments to the
+// returned log and then runs body.
+func fakeSudoScript(t *testing.T, body string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	log := filepath.Join(dir, "calls")
+	script := fmt.Sprintf("#!/bin/sh\necho \"sudo $*\" >> %s\n%s\n", log, body)
+
+	if err := os.WriteFile(filepath.Join(dir, "sudo"), []byte(script), 0o755); err != nil { //nolint:gosec // The fake sudo must be executable.
+		t.Fatal(err)
+	}
+
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	return log
+}
+
+func TestMeasureDropCaches(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake sudo is a shell script")
+	}
+
+	log := fakeSudo(t, 0)
+	c := runCmd{DropCaches: true, SudoCollectors: true}
+
+	for range 2 {
+		if _, err := c.measure([]string{"sh", "-c", "echo run >> " + log}, runOptions{}); err != nil {
+			t.Fatalf("measure() error = %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	self, _ := os.Executable()
+	drop := "sudo -- " + self + " privileged drop-caches"
+
+	if want := strings.Repeat(drop+"\nrun\n", 2); string(data) != want {
+		t.Errorf("calls = %q, want the caches dropped before every run: %q", data, want)
+	}
+}
+
+func TestMeasureDropCachesFails(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake sudo is a shell script")
+	}
+
+	fakeSudo(t, 1)
+	c := runCmd{DropCaches: true, SudoCollectors: true}
+
+	m, err := c.measure([]string{"true"}, runOptions{})
+	if err == nil || !strings.Contains(err.Error(), "privileged drop-caches") {
+		t.Errorf("measure() error = %v, want the failed drop", err)
+	}
+
+	if m.ExitCode != -1 {
+		t.Errorf("measure() exit code = %d, want the command not run", m.ExitCode)
+	}
+}
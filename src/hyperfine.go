@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// hyperfineExport is the JSON hyperfine writes with --export-json, which
+// ztime imports and writes with --export-hyperfine.
+type hyperfineExport struct {
+	Results []hyperfineResult `json:"results"`
+}
+
+// hyperfineResult is one benchmarked command of a hyperfine export. Times
+// are in seconds.
+type hyperfineResult struct {
+	Command    string            `json:"command"`
+	Mean       float64           `json:"mean"`
+	StdDev     *float64          `json:"stddev"` // null for a single run
+	Median     float64           `json:"median"`
+	User       float64           `json:"user"`   // mean over the runs
+	System     float64           `json:"system"` // mean over the runs
+	Min        float64           `json:"min"`
+	Max        float64           `json:"max"`
+	Times      []float64         `json:"times"`
+	Memory     []int64           `json:"memory_usage_byte,omitempty"`
+	ExitCodes  []*int            `json:"exit_codes"` // null when killed by a signal
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// writeHyperfine writes the benchmarks of a result to path in hyperfine's
+// export format, for the tools that read it, such as hyperfine's plotting
+// scripts.
+func writeHyperfine(path string, result any) error {
+	data, err := json.MarshalIndent(hyperfineExport{Results: hyperfineResults(result)}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	//nolint:gosec // Intended behavior: the export is for the user to read.
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// hyperfineResults converts a result to one hyperfine result per command:
+// a benchmark's runs, each benchmark of a comparison or suite, each command
+// of --parallel, or a single run.
+func hyperfineResults(result any) []hyperfineResult {
+	var results []hyperfineResult
+
+	switch r := result.(type) {
+	case BenchResult:
+		results = append(results, newHyperfineResult(r.Command, r.Results, r.Tags))
+	case Comparison:
+		for _, b := range r.Benchmarks {
+			results = append(results, newHyperfineResult(b.Command, b.Results, b.Tags))
+		}
+	case SuiteResult:
+		for _, b := range r.Benchmarks {
+			results = append(results, newHyperfineResult(b.Command, b.Results, b.Tags))
+		}
+	case ParallelResult:
+		for _, runs := range groupByCommand(r.Results) {
+			results = append(results, newHyperfineResult(runs[0].Command, runs, r.Tags))
+		}
+	case Metrics:
+		results = append(results, newHyperfineResult(r.Command, []Metrics{r}, r.Tags))
+	}
+
+	return results
+}
+
+// newHyperfineResult summarizes the runs of command. Tags become
+// parameters, which hyperfine's scripts use to tell the results of a
+// parameter scan apart.
+func newHyperfineResult(command string, runs []Metrics, tags map[string]string) hyperfineResult {
+	var elapsed, user, system []time.Duration
+
+	r := hyperfineResult{Command: command, Parameters: tags, Times: []float64{}, ExitCodes: []*int{}}
+
+	for _, m := range runs {
+		elapsed = append(elapsed, m.ElapsedTime)
+		user = append(user, m.UserTime)
+		system = append(system, m.SystemTime)
+		r.Times = append(r.Times, m.ElapsedTime.Seconds())
+
+		if m.MaxRSS > 0 {
+			r.Memory = append(r.Memory, m.MaxRSS*1024)
+		}
+
+		if m.Killed {
+			r.ExitCodes = append(r.ExitCodes, nil)
+		} else {
+			r.ExitCodes = append(r.ExitCodes, &m.ExitCode)
+		}
+	}
+
+	// Memory is per run, so it is left out unless every run has it.
+	if len(r.Memory) != len(runs) {
+		r.Memory = nil
+	}
+
+	stats := summarize(elapsed)
+	r.Mean, r.Median, r.Min, r.Max = stats.Mean.Seconds(), stats.P50.Seconds(), stats.Min.Seconds(), stats.Max.Seconds()
+	r.User, r.System = summarize(user).Mean.Seconds(), summarize(system).Mean.Seconds()
+
+	if len(runs) > 1 {
+		stddev := stats.StdDev.Seconds()
+		r.StdDev = &stddev
+	}
+
+	return r
+}
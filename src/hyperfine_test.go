@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHyperfineResults(t *testing.T) {
+	t.Parallel()
+
+	bench := BenchResult{
+		Command: "make",
+		Results: []Metrics{
+			{Command: "make", ElapsedTime: time.Second, UserTime: 600 * time.Millisecond, MaxRSS: 1024},
+			{Command: "make", ElapsedTime: 3 * time.Second, UserTime: 800 * time.Millisecond, MaxRSS: 2048, ExitCode: 2},
+		},
+		Annotations: Annotations{Tags: map[string]string{"jobs": "8"}},
+	}
+
+	tests := []struct {
+		name     string
+		result   any
+		commands []string
+	}{
+		{"bench", bench, []string{"make"}},
+		{"comparison", Comparison{Benchmarks: []BenchResult{bench, {Command: "ninja"}}}, []string{"make", "ninja"}},
+		{"suite", SuiteResult{Benchmarks: []BenchResult{{Command: "a"}, {Command: "b"}}}, []string{"a", "b"}},
+		{"parallel", ParallelResult{Results: []Metrics{{Command: "x"}, {Command: "y"}, {Command: "x"}}}, []string{"x", "y"}},
+		{"run", Metrics{Command: "true"}, []string{"true"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := hyperfineResults(tt.result)
+			if len(got) != len(tt.commands) {
+				t.Fatalf("hyperfineResults() returned %d results, want %d", len(got), len(tt.commands))
+			}
+
+			for i, r := range got {
+				if r.Command != tt.commands[i] {
+					t.Errorf("result %d command = %q, want %q", i, r.Command, tt.commands[i])
+				}
+			}
+		})
+	}
+
+	r := hyperfineResults(bench)[0]
+
+	if r.Mean != 2 || r.Median != 2 || r.Min != 1 || r.Max != 3 || r.User != 0.7 || r.StdDev == nil || *r.StdDev < 1.41 || *r.StdDev > 1.42 {
+		t.Errorf("bench statistics = %+v", r)
+	}
+
+	if len(r.Memory) != 2 || r.Memory[1] != 2048*1024 || *r.ExitCodes[1] != 2 || r.Parameters["jobs"] != "8" {
+		t.Errorf("bench runs: memory %v, exit codes %v, parameters %v", r.Memory, r.ExitCodes, r.Parameters)
+	}
+
+	if single := hyperfineResults(Metrics{Killed: true})[0]; single.StdDev != nil || single.ExitCodes[0] != nil || single.Memory != nil {
+		t.Errorf("single killed run: stddev %v, exit codes %v, memory %v", single.StdDev, single.ExitCodes, single.Memory)
+	}
+}
+
+func TestWriteHyperfineImports(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "bench.json")
+	bench := BenchResult{Command: "sleep 1", Results: []Metrics{
+		{Command: "sleep 1", ElapsedTime: time.Second},
+		{Command: "sleep 1", ElapsedTime: 1100 * time.Millisecond, Killed: true, ExitCode: -1},
+	}}
+
+	if err := writeHyperfine(path, bench); err != nil {
+		t.Fatalf("writeHyperfine() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := parseHyperfine(data)
+	if err != nil {
+		t.Fatalf("parseHyperfine() error = %v", err)
+	}
+
+	if len(runs) != 2 || runs[0].ElapsedTime != time.Second || runs[1].ElapsedTime != 1100*time.Millisecond || !runs[1].Killed {
+		t.Errorf("parseHyperfine(writeHyperfine()) = %+v", runs)
+	}
+}
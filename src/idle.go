@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+)
+
+// idlePollInterval is how often --idle-wait checks the load average again.
+const idlePollInterval = 5 * time.Second
+
+var (
+	errNotIdle         = errors.New("the system is not idle")
+	errLoadUnsupported = errors.New("--require-idle is not supported")
+)
+
+// coolDown sleeps until --cooldown has passed since the previous run
+// ended, so heat from one run does not throttle the next.
+func (c *runCmd) coolDown() {
+	if c.Cooldown > 0 && !c.lastRunEnd.IsZero() {
+		time.Sleep(c.Cooldown - time.Since(c.lastRunEnd))
+	}
+}
+
+// waitIdle returns once the 1-minute load average is at most
+// --require-idle, waiting up to --idle-wait for it to fall.
+func (c *runCmd) waitIdle() error {
+	if c.RequireIdle <= 0 {
+		return nil
+	}
+
+	read := c.loads
+	if read == nil {
+		read = loadAverage
+	}
+
+	deadline := time.Now().Add(c.IdleWait)
+	announced := false
+
+	for {
+		loads := read()
+		if loads == nil {
+			return fmt.Errorf("%w on %s", errLoadUnsupported, runtime.GOOS)
+		}
+
+		if loads[0] <= c.RequireIdle {
+			return nil
+		}
+
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("%w: 1-minute load average %.2f is above %.2f", errNotIdle, loads[0], c.RequireIdle)
+		}
+
+		if !announced && !c.Quiet && !c.structured() {
+			fmt.Fprintf(os.Stderr, "ztime: waiting for the load average (%.2f) to fall to %.2f\n", loads[0], c.RequireIdle)
+
+			announced = true
+		}
+
+		time.Sleep(min(idlePollInterval, time.Until(deadline)))
+	}
+}
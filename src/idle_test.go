@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitIdle(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		require float64
+		loads   *[3]float64
+		wantErr error
+	}{
+		{name: "Disabled", loads: &[3]float64{4, 3, 2}},
+		{name: "Idle Enough", require: 1, loads: &[3]float64{0.5, 1, 2}},
+		{name: "Too Busy", require: 1, loads: &[3]float64{1.5, 1, 0.5}, wantErr: errNotIdle},
+		{name: "Unsupported", require: 1, wantErr: errLoadUnsupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := runCmd{RequireIdle: tt.require, Quiet: true, loads: func() *[3]float64 { return tt.loads }}
+
+			if err := c.waitIdle(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("waitIdle() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCoolDown(t *testing.T) {
+	t.Parallel()
+
+	c := runCmd{Cooldown: 50 * time.Millisecond}
+
+	start := time.Now()
+	c.coolDown()
+
+	if elapsed := time.Since(start); elapsed >= c.Cooldown {
+		t.Errorf("coolDown() before any run took %s, want no wait", elapsed)
+	}
+
+	c.lastRunEnd = time.Now()
+	c.coolDown()
+
+	if elapsed := time.Since(c.lastRunEnd); elapsed < c.Cooldown {
+		t.Errorf("coolDown() returned after %s, want at least %s", elapsed, c.Cooldown)
+	}
+}
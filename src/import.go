@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var errImportFormat = errors.New("unrecognized results file")
+
+// importCmd records results measured with other tools in the history.
+type importCmd struct {
+	Format string    `enum:"auto,hyperfine,gnu-time" default:"auto" help:"Format of the files (auto,hyperfine,gnu-time): hyperfine --export-json output or GNU time -v reports; auto tells them apart by their contents."`
+	At     timeBound `help:"When the last imported run of each file ended, in the forms --since of ztime trend takes (default: the file's modification time)." placeholder:"WHEN"`
+	DryRun bool      `name:"dry-run" help:"Report what would be imported without recording it."`
+
+	Files []string `arg:"" type:"existingfile" help:"Results files to import."`
+}
+
+// Run parses every file and appends its runs to the history of their
+// command lines in the working directory. Runs already in the history,
+// such as from an earlier import of the same file, are skipped.
+func (c *importCmd) Run() error {
+	for _, file := range c.Files {
+		runs, err := c.parse(file)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+
+		for _, group := range groupByCommand(runs) {
+			added, err := c.record(group)
+			if err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+
+			verb := "imported"
+			if c.DryRun {
+				verb = "would import"
+			}
+
+			fmt.Fprintf(os.Stderr, "ztime: %s %d of %d runs of %s from %s\n", verb, added, len(group), group[0].Command, file)
+		}
+	}
+
+	return nil
+}
+
+// parse reads the runs in file, in the order they ran, and places them one
+// after the other, the last ending at --at or when the file was written.
+func (c *importCmd) parse(file string) ([]Metrics, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	//nolint:gosec // Intended behavior: the user names the file to import.
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	format := c.Format
+	if format == "auto" {
+		format = detectImportFormat(data)
+	}
+
+	var runs []Metrics
+
+	switch format {
+	case "hyperfine":
+		runs, err = parseHyperfine(data)
+	case "gnu-time":
+		runs, err = parseGNUTime(data)
+	default:
+		return nil, fmt.Errorf("%w: want hyperfine JSON or GNU time -v output", errImportFormat)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("%w: no runs found", errImportFormat)
+	}
+
+	end := info.ModTime()
+	if !c.At.IsZero() {
+		end = c.At.Time
+	}
+
+	placeRuns(runs, end)
+
+	return runs, nil
+}
+
+// record appends the runs of one command line that are not yet in its
+// history, returning how many there were.
+func (c *importCmd) record(runs []Metrics) (int, error) {
+	path, err := historyPath(runs[0].Argv)
+	if err != nil {
+		return 0, err
+	}
+
+	history, err := loadHistory(path)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+
+	for _, m := range runs {
+		if slices.ContainsFunc(history, func(h Metrics) bool {
+			return h.StartTime.Equal(m.StartTime) && h.ElapsedTime == m.ElapsedTime
+		}) {
+			continue
+		}
+
+		added++
+
+		if c.DryRun {
+			continue
+		}
+
+		if err := appendHistory(path, m); err != nil {
+			return added, fmt.Errorf("recording run: %w", err)
+		}
+	}
+
+	return added, nil
+}
+
+// detectImportFormat names the format of a results file, or returns "" if
+// it is neither.
+func detectImportFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")):
+		return "hyperfine"
+	case bytes.Contains(data, []byte("Command being timed:")):
+		return "gnu-time"
+	default:
+		return ""
+	}
+}
+
+// groupByCommand splits runs by command line, keeping the order each was
+// first seen in.
+func groupByCommand(runs []Metrics) [][]Metrics {
+	var groups [][]Metrics
+
+	index := map[string]int{}
+
+	for _, m := range runs {
+		i, ok := index[m.Command]
+		if !ok {
+			i = len(groups)
+			index[m.Command] = i
+			groups = append(groups, nil)
+		}
+
+		groups[i] = append(groups[i], m)
+	}
+
+	return groups
+}
+
+// placeRuns sets the start and end times of runs, which neither format
+// records, as if each started when the one before it ended and the last
+// ended at end.
+func placeRuns(runs []Metrics, end time.Time) {
+	for i := len(runs) - 1; i >= 0; i-- {
+		runs[i].EndTime = end
+		runs[i].StartTime = end.Add(-runs[i].ElapsedTime)
+		end = runs[i].StartTime
+	}
+}
+
+// importedRun returns a run of a command line as the other tools print it.
+// They run it through a shell, so it is split at spaces into arguments.
+func importedRun(command string) Metrics {
+	argv := strings.Fields(command)
+
+	return Metrics{Command: strings.Join(argv, " "), Argv: argv}
+}
+
+// parseHyperfine reads every run of every command in a hyperfine export.
+// hyperfine only keeps the mean user and system times, so each run gets
+// those. Parameters of a parameter scan become tags.
+func parseHyperfine(data []byte) ([]Metrics, error) {
+	var export hyperfineExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("%w: %w", errImportFormat, err)
+	}
+
+	var runs []Metrics
+
+	for _, r := range export.Results {
+		for i, seconds := range r.Times {
+			m := importedRun(r.Command)
+			m.ElapsedTime = secondsDuration(seconds)
+			m.UserTime = secondsDuration(r.User)
+			m.SystemTime = secondsDuration(r.System)
+			m.CPUPercent = calculateCPUPercent(m.UserTime, m.SystemTime, m.ElapsedTime)
+
+			if len(r.Parameters) > 0 {
+				m.Tags = r.Parameters
+			}
+
+			if i < len(r.ExitCodes) {
+				if code := r.ExitCodes[i]; code != nil {
+					m.ExitCode = *code
+				} else {
+					m.ExitCode, m.Killed = -1, true
+				}
+			}
+
+			if i < len(r.Memory) {
+				m.MaxRSS = r.Memory[i] / 1024
+			}
+
+			runs = append(runs, m)
+		}
+	}
+
+	return runs, nil
+}
+
+// parseGNUTime reads the reports in GNU time -v output, such as a file
+// written with -o and appended to with -a. Each report starts with its
+// "Command being timed" line, after a line saying how the command ended if
+// it failed.
+func parseGNUTime(data []byte) ([]Metrics, error) {
+	var (
+		runs   []Metrics
+		counts map[string]*int64
+		signal int // from the line before the next report
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if n, ok := strings.CutPrefix(line, "Command terminated by signal "); ok {
+			signal, _ = strconv.Atoi(n)
+
+			continue
+		}
+
+		label, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+
+		if label == "Command being timed" {
+			m := importedRun(strings.Trim(value, `"`))
+			if signal > 0 {
+				m.Killed, m.TermSignal = true, signalName(syscall.Signal(signal))
+			}
+
+			runs, signal = append(runs, m), 0
+			counts = gnuTimeCounts(&runs[len(runs)-1])
+
+			continue
+		}
+
+		if len(runs) == 0 {
+			continue
+		}
+
+		m := &runs[len(runs)-1]
+
+		var err error
+
+		switch {
+		case label == "User time (seconds)":
+			m.UserTime, err = parseSeconds(value)
+		case label == "System time (seconds)":
+			m.SystemTime, err = parseSeconds(value)
+		case strings.HasPrefix(label, "Elapsed (wall clock) time"):
+			m.ElapsedTime, err = parseClock(value)
+		case label == "Exit status":
+			m.ExitCode, err = strconv.Atoi(value)
+		case counts[label] != nil:
+			*counts[label], err = strconv.ParseInt(value, 10, 64)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", errImportFormat, label, err)
+		}
+	}
+
+	for i := range runs {
+		m := &runs[i]
+		m.CPUPercent = calculateCPUPercent(m.UserTime, m.SystemTime, m.ElapsedTime)
+
+		if m.Killed {
+			m.ExitCode = -1
+		}
+	}
+
+	return runs, scanner.Err()
+}
+
+// gnuTimeCounts maps the labels of the counters in a GNU time -v report to
+// the fields of m they go in.
+func gnuTimeCounts(m *Metrics) map[string]*int64 {
+	return map[string]*int64{
+		"Maximum resident set size (kbytes)":     &m.MaxRSS,
+		"Major (requiring I/O) page faults":      &m.PageFaults,
+		"Minor (reclaiming a frame) page faults": &m.PageReclaims,
+		"Voluntary context switches":             &m.VCtxSwitches,
+		"Involuntary context switches":           &m.ICtxSwitches,
+		"Swaps":                                  &m.Swaps,
+		"File system inputs":                     &m.BlockInput,
+		"File system outputs":                    &m.BlockOutput,
+		"Socket messages sent":                   &m.MsgsSent,
+		"Socket messages received":               &m.MsgsRecv,
+		"Signals delivered":                      &m.Signals,
+	}
+}
+
+// parseSeconds reads a number of seconds such as "1.25".
+func parseSeconds(s string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(s, 64)
+
+	return secondsDuration(seconds), err
+}
+
+// parseClock reads an elapsed time as GNU time prints it, "m:ss.ss" or
+// "h:mm:ss".
+func parseClock(s string) (time.Duration, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("%w %q", errInvalidTime, s)
+	}
+
+	d, err := parseSeconds(parts[len(parts)-1])
+	if err != nil {
+		return 0, err
+	}
+
+	unit := time.Minute
+
+	for i := len(parts) - 2; i >= 0; i-- {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return 0, fmt.Errorf("%w %q", errInvalidTime, s)
+		}
+
+		d += time.Duration(n) * unit
+		unit = time.Hour
+	}
+
+	return d, nil
+}
+
+// secondsDuration converts fractional seconds to a duration.
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
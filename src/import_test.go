@@ -0,0 +1,210 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const hyperfineJSON = `{
+  "results": [
+    {
+      "command": "sleep  0.1",
+      "mean": 0.15,
+      "stddev": 0.07,
+      "median": 0.15,
+      "user": 0.001,
+      "system": 0.002,
+      "min": 0.1,
+      "max": 0.2,
+      "times": [0.1, 0.2],
+      "memory_usage_byte": [2097152, 3145728],
+      "exit_codes": [0, null],
+      "parameters": {"n": "0.1"}
+    },
+    {
+      "command": "true",
+      "mean": 0.001,
+      "user": 0.0005,
+      "system": 0.0003,
+      "times": [0.001],
+      "exit_codes": [1]
+    }
+  ]
+}`
+
+const gnuTimeReport = `	Command being timed: "make -j8"
+	User time (seconds): 3.50
+	System time (seconds): 0.50
+	Percent of CPU this job got: 200%
+	Elapsed (wall clock) time (h:mm:ss or m:ss): 0:02.00
+	Average shared text size (kbytes): 0
+	Maximum resident set size (kbytes): 51200
+	Major (requiring I/O) page faults: 3
+	Minor (reclaiming a frame) page faults: 1200
+	Voluntary context switches: 40
+	Involuntary context switches: 7
+	Swaps: 0
+	File system inputs: 16
+	File system outputs: 128
+	Socket messages sent: 0
+	Socket messages received: 0
+	Signals delivered: 0
+	Page size (bytes): 4096
+	Exit status: 0
+Command terminated by signal 9
+	Command being timed: "sleep 100"
+	User time (seconds): 0.00
+	System time (seconds): 0.00
+	Percent of CPU this job got: 0%
+	Elapsed (wall clock) time (h:mm:ss or m:ss): 1:01:05
+	Maximum resident set size (kbytes): 1920
+	Exit status: 0
+`
+
+func TestParseHyperfine(t *testing.T) {
+	t.Parallel()
+
+	runs, err := parseHyperfine([]byte(hyperfineJSON))
+	if err != nil {
+		t.Fatalf("parseHyperfine() error = %v", err)
+	}
+
+	if len(runs) != 3 {
+		t.Fatalf("parseHyperfine() returned %d runs, want 3", len(runs))
+	}
+
+	first, killed, last := runs[0], runs[1], runs[2]
+
+	if first.Command != "sleep 0.1" || len(first.Argv) != 2 || first.ElapsedTime != 100*time.Millisecond ||
+		first.UserTime != time.Millisecond || first.SystemTime != 2*time.Millisecond || first.MaxRSS != 2048 || first.Tags["n"] != "0.1" {
+		t.Errorf("first run = %+v", first)
+	}
+
+	if !killed.Killed || killed.ExitCode != -1 || killed.MaxRSS != 3072 {
+		t.Errorf("killed run: killed %v, exit %d, max rss %d", killed.Killed, killed.ExitCode, killed.MaxRSS)
+	}
+
+	if last.Command != "true" || last.ExitCode != 1 || last.Tags != nil {
+		t.Errorf("last run: command %q, exit %d, tags %v", last.Command, last.ExitCode, last.Tags)
+	}
+}
+
+func TestParseGNUTime(t *testing.T) {
+	t.Parallel()
+
+	runs, err := parseGNUTime([]byte(gnuTimeReport))
+	if err != nil {
+		t.Fatalf("parseGNUTime() error = %v", err)
+	}
+
+	if len(runs) != 2 {
+		t.Fatalf("parseGNUTime() returned %d runs, want 2", len(runs))
+	}
+
+	m := runs[0]
+
+	expected := Metrics{
+		Command: "make -j8", UserTime: 3500 * time.Millisecond, SystemTime: 500 * time.Millisecond,
+		ElapsedTime: 2 * time.Second, CPUPercent: 200, MaxRSS: 51200, PageFaults: 3, PageReclaims: 1200,
+		VCtxSwitches: 40, ICtxSwitches: 7, BlockInput: 16, BlockOutput: 128,
+	}
+
+	if m.Command != expected.Command || m.UserTime != expected.UserTime || m.SystemTime != expected.SystemTime ||
+		m.ElapsedTime != expected.ElapsedTime || m.CPUPercent != expected.CPUPercent || m.MaxRSS != expected.MaxRSS ||
+		m.PageFaults != expected.PageFaults || m.PageReclaims != expected.PageReclaims || m.VCtxSwitches != expected.VCtxSwitches ||
+		m.ICtxSwitches != expected.ICtxSwitches || m.BlockInput != expected.BlockInput || m.BlockOutput != expected.BlockOutput {
+		t.Errorf("first report = %+v\nwant %+v", m, expected)
+	}
+
+	if killed := runs[1]; !killed.Killed || killed.TermSignal != "SIGKILL" || killed.ExitCode != -1 || killed.ElapsedTime != time.Hour+65*time.Second {
+		t.Errorf("second report: killed %v by %q, exit %d, elapsed %v", killed.Killed, killed.TermSignal, killed.ExitCode, killed.ElapsedTime)
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in       string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{in: "0:01.50", expected: 1500 * time.Millisecond},
+		{in: "12:00.00", expected: 12 * time.Minute},
+		{in: "2:03:04", expected: 2*time.Hour + 3*time.Minute + 4*time.Second},
+		{in: "1.5", wantErr: true},
+		{in: "a:00", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseClock(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.expected && !tt.wantErr {
+			t.Errorf("parseClock(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.expected, tt.wantErr)
+		}
+	}
+}
+
+func TestDetectImportFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		hyperfineJSON:          "hyperfine",
+		gnuTimeReport:          "gnu-time",
+		"real 0m1.000s\n":      "",
+		"\n  {\"results\":[]}": "hyperfine",
+	}
+
+	for data, expected := range tests {
+		if got := detectImportFormat([]byte(data)); got != expected {
+			t.Errorf("detectImportFormat(%.20q) = %q, want %q", data, got, expected)
+		}
+	}
+}
+
+func TestPlaceRuns(t *testing.T) {
+	t.Parallel()
+
+	end := time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+	runs := []Metrics{{ElapsedTime: time.Second}, {ElapsedTime: 2 * time.Second}}
+
+	placeRuns(runs, end)
+
+	if !runs[1].EndTime.Equal(end) || !runs[1].StartTime.Equal(end.Add(-2*time.Second)) ||
+		!runs[0].EndTime.Equal(runs[1].StartTime) || !runs[0].StartTime.Equal(end.Add(-3*time.Second)) {
+		t.Errorf("placeRuns() = %v-%v, %v-%v", runs[0].StartTime, runs[0].EndTime, runs[1].StartTime, runs[1].EndTime)
+	}
+}
+
+func TestImportRecordsOnce(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	file := filepath.Join(t.TempDir(), "results.json")
+	if err := os.WriteFile(file, []byte(hyperfineJSON), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &importCmd{Format: "auto", Files: []string{file}}
+
+	// Importing the same file again adds nothing.
+	for range 2 {
+		if err := c.Run(); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	}
+
+	path, err := historyPath([]string{"sleep", "0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := loadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(history) != 2 {
+		t.Errorf("history has %d runs, want 2", len(history))
+	}
+}
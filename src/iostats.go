@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var errIOStatsUnsupported = errors.New("--io-stats is not supported")
+
+// IOStats is the I/O the kernel accounted to the command and the
+// descendants it waited for, read with --io-stats from /proc/<pid>/io
+// after it exited and before it was reaped. Unlike the block counts of
+// resource usage, which count 512-byte operations, these are bytes.
+type IOStats struct {
+	ReadBytes           int64  `json:"read_bytes" unit:"bytes"`            // fetched from storage
+	WriteBytes          int64  `json:"write_bytes" unit:"bytes"`           // sent toward storage, including page cache writeback
+	CancelledWriteBytes int64  `json:"cancelled_write_bytes" unit:"bytes"` // written but truncated or deleted before reaching storage
+	LogicalRead         int64  `json:"logical_read" unit:"bytes"`          // passed to read-like system calls, from the page cache or not
+	LogicalWrite        int64  `json:"logical_write" unit:"bytes"`         // passed to write-like system calls
+	Error               string `json:"error,omitempty"`                    // why the stats could not be read
+}
+
+// parseProcIO parses /proc/<pid>/io, whose lines are "key: value".
+func parseProcIO(data []byte) (IOStats, error) {
+	fields := map[string]*int64{}
+
+	var s IOStats
+
+	fields["read_bytes"] = &s.ReadBytes
+	fields["write_bytes"] = &s.WriteBytes
+	fields["cancelled_write_bytes"] = &s.CancelledWriteBytes
+	fields["rchar"] = &s.LogicalRead
+	fields["wchar"] = &s.LogicalWrite
+
+	found := 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+
+		field, ok := fields[strings.TrimSpace(key)]
+		if !ok {
+			continue
+		}
+
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return IOStats{}, fmt.Errorf("parsing io %s: %w", key, err)
+		}
+
+		*field = n
+		found++
+	}
+
+	if found == 0 {
+		return IOStats{}, fmt.Errorf("parsing io %q: no counters", bytes.TrimSpace(data))
+	}
+
+	return s, nil
+}
+
+// ioNote describes the bytes that reached storage next to the block
+// counts, or returns "" without --io-stats.
+func ioNote(m Metrics) string {
+	s := m.IOStats
+
+	switch {
+	case s == nil:
+		return ""
+	case s.Error != "":
+		return "no I/O stats: " + s.Error
+	}
+
+	note := fmt.Sprintf("io: read %s and wrote %s to storage", humanBytes(s.ReadBytes), humanBytes(s.WriteBytes))
+	if s.CancelledWriteBytes > 0 {
+		note += fmt.Sprintf(" (%s of writes cancelled)", humanBytes(s.CancelledWriteBytes))
+	}
+
+	return note + fmt.Sprintf("; %s read and %s written through system calls; %d block inputs, %d block outputs",
+		humanBytes(s.LogicalRead), humanBytes(s.LogicalWrite), m.BlockInput, m.BlockOutput)
+}
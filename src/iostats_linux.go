@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"strconv"
+)
+
+func ioStatsSupported() error {
+	return nil
+}
+
+// waitIOStats blocks until pid exits and reads its I/O accounting while it
+// is a zombie, when it includes the descendants it reaped. It leaves the
+// process for the caller to reap.
+func waitIOStats(pid int) *IOStats {
+	if err := waitZombie(pid); err != nil {
+		return &IOStats{Error: err.Error()}
+	}
+
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/io")
+	if err != nil {
+		return &IOStats{Error: err.Error()}
+	}
+
+	s, err := parseProcIO(data)
+	if err != nil {
+		return &IOStats{Error: err.Error()}
+	}
+
+	return &s
+}
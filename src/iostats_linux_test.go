@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+)
+
+func TestWaitIOStats(t *testing.T) {
+	t.Parallel()
+
+	cmd := exec.CommandContext(context.Background(), "sh", "-c", "head -c 65536 /dev/zero | cat > /dev/null")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start sh: %v", err)
+	}
+
+	s := waitIOStats(cmd.Process.Pid)
+
+	// The process must still be there for Wait to reap.
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("Wait() after waitIOStats() error = %v", err)
+	}
+
+	// The pipeline's children were reaped by sh, so their reads count.
+	if s == nil || s.Error != "" || s.LogicalRead < 65536 {
+		t.Errorf("waitIOStats() = %+v, want the I/O of the exited process and its children", s)
+	}
+}
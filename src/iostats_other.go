@@ -0,0 +1,16 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func ioStatsSupported() error {
+	return fmt.Errorf("%w on %s", errIOStatsUnsupported, runtime.GOOS)
+}
+
+func waitIOStats(int) *IOStats {
+	return nil
+}
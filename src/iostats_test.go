@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestParseProcIO(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("rchar: 21495808\nwchar: 20971520\nsyscr: 12\nsyscw: 20\nread_bytes: 4096\nwrite_bytes: 20971520\ncancelled_write_bytes: 8192\n")
+
+	want := IOStats{ReadBytes: 4096, WriteBytes: 20971520, CancelledWriteBytes: 8192, LogicalRead: 21495808, LogicalWrite: 20971520}
+	if got, err := parseProcIO(data); err != nil || got != want {
+		t.Errorf("parseProcIO() = %+v, %v; want %+v", got, err, want)
+	}
+
+	for _, bad := range []string{"", "garbage", "read_bytes: many\n"} {
+		if _, err := parseProcIO([]byte(bad)); err == nil {
+			t.Errorf("parseProcIO(%q) accepted it", bad)
+		}
+	}
+}
+
+func TestIONote(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		m    Metrics
+		want string
+	}{
+		{"disabled", Metrics{}, ""},
+		{"unreadable", Metrics{IOStats: &IOStats{Error: "permission denied"}}, "no I/O stats: permission denied"},
+		{
+			"writes",
+			Metrics{BlockOutput: 40960, IOStats: &IOStats{WriteBytes: 20 << 20, LogicalRead: 1024, LogicalWrite: 20 << 20}},
+			"io: read 0 B and wrote 20.0 MiB to storage; 1.0 KiB read and 20.0 MiB written through system calls; 0 block inputs, 40960 block outputs",
+		},
+		{
+			"cancelled",
+			Metrics{IOStats: &IOStats{WriteBytes: 8192, CancelledWriteBytes: 4096}},
+			"io: read 0 B and wrote 8.0 KiB to storage (4.0 KiB of writes cancelled); 0 B read and 0 B written through system calls; 0 block inputs, 0 block outputs",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := ioNote(tt.m); got != tt.want {
+				t.Errorf("ioNote() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ipcDrain is how long ztime keeps serving connections that are still open
+// when the command exits, in case the last message is in flight.
+const ipcDrain = 100 * time.Millisecond
+
+var errIPCRequest = errors.New("unknown request")
+
+// ipcServer listens on a Unix socket, announced in ZTIME_IPC_SOCKET, for
+// line-based requests from the command:
+//
+//	elapsed            replies with the seconds since the command started
+//	metric NAME VALUE  records a custom metric, replacing any earlier value
+//
+// Every request gets a one-line reply, "ok" or "error: ...", except elapsed,
+// which gets the number.
+type ipcServer struct {
+	dir      string
+	listener net.Listener
+	start    time.Time
+	conns    sync.WaitGroup
+
+	mu      sync.Mutex
+	open    map[net.Conn]bool
+	closing bool
+	metrics map[string]float64
+}
+
+func listenIPC(cmd *exec.Cmd) (*ipcServer, error) {
+	// Socket paths are limited to about 100 bytes, so keep it short.
+	dir, err := os.MkdirTemp("", "ztime-")
+	if err != nil {
+		return nil, fmt.Errorf("creating IPC socket: %w", err)
+	}
+
+	path := filepath.Join(dir, "ipc.sock")
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+
+		return nil, fmt.Errorf("creating IPC socket: %w", err)
+	}
+
+	cmd.Env = append(cmd.Env, "ZTIME_IPC_SOCKET="+path)
+
+	s := &ipcServer{dir: dir, listener: listener, start: time.Now(), open: map[net.Conn]bool{}, metrics: map[string]float64{}}
+
+	s.conns.Go(s.accept)
+
+	return s, nil
+}
+
+func (s *ipcServer) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.open[conn] = true
+		if s.closing {
+			_ = conn.SetReadDeadline(time.Now().Add(ipcDrain))
+		}
+		s.mu.Unlock()
+
+		s.conns.Go(func() {
+			defer func() {
+				s.mu.Lock()
+				delete(s.open, conn)
+				s.mu.Unlock()
+
+				_ = conn.Close()
+			}()
+
+			s.serve(conn)
+		})
+	}
+}
+
+func (s *ipcServer) serve(conn net.Conn) {
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		if _, err := fmt.Fprintln(conn, s.handle(scanner.Text())); err != nil {
+			return
+		}
+	}
+}
+
+// handle answers one request.
+func (s *ipcServer) handle(line string) string {
+	fields := strings.Fields(line)
+
+	switch {
+	case len(fields) == 1 && fields[0] == "elapsed":
+		return strconv.FormatFloat(time.Since(s.start).Seconds(), 'f', microPrecision, 64)
+	case len(fields) == 3 && fields[0] == "metric":
+		value, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return fmt.Sprintf("error: invalid value %q", fields[2])
+		}
+
+		s.mu.Lock()
+		s.metrics[fields[1]] = value
+		s.mu.Unlock()
+
+		return "ok"
+	default:
+		return fmt.Sprintf("error: %v: %q", errIPCRequest, line)
+	}
+}
+
+// finish stops listening, gives open connections a moment to send what
+// they have left, and stores the custom metrics.
+func (s *ipcServer) finish(m *Metrics, _ time.Time) {
+	_ = s.listener.Close()
+
+	s.mu.Lock()
+	s.closing = true
+	for conn := range s.open {
+		_ = conn.SetReadDeadline(time.Now().Add(ipcDrain))
+	}
+	s.mu.Unlock()
+
+	s.conns.Wait()
+
+	_ = os.RemoveAll(s.dir)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.metrics) > 0 {
+		m.Custom = s.metrics
+	}
+}
+
+// customNote lists the custom metrics the command sent, or returns "" if
+// it sent none.
+func customNote(m Metrics) string {
+	if len(m.Custom) == 0 {
+		return ""
+	}
+
+	parts := make([]string, 0, len(m.Custom))
+	for _, name := range slices.Sorted(maps.Keys(m.Custom)) {
+		parts = append(parts, name+" "+strconv.FormatFloat(m.Custom[name], 'g', -1, 64))
+	}
+
+	return "custom: " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIPCHandle(t *testing.T) {
+	t.Parallel()
+
+	s := &ipcServer{start: time.Now(), metrics: map[string]float64{}}
+
+	tests := []struct{ request, want string }{
+		{"metric rows 1200", "ok"},
+		{"  metric   ratio 0.5 ", "ok"},
+		{"metric rows 1300", "ok"},
+		{"metric rows", `error: unknown request: "metric rows"`},
+		{"metric rows many", `error: invalid value "many"`},
+		{"metric rows +Inf", `error: invalid value "+Inf"`},
+		{"elapsed now", `error: unknown request: "elapsed now"`},
+	}
+
+	for _, tt := range tests {
+		if got := s.handle(tt.request); got != tt.want {
+			t.Errorf("handle(%q) = %q, want %q", tt.request, got, tt.want)
+		}
+	}
+
+	if want := map[string]float64{"rows": 1300, "ratio": 0.5}; !reflect.DeepEqual(s.metrics, want) {
+		t.Errorf("metrics = %v, want %v", s.metrics, want)
+	}
+
+	if secs, err := strconv.ParseFloat(s.handle("elapsed"), 64); err != nil || secs < 0 {
+		t.Errorf("handle(\"elapsed\") = %v, %v; want seconds", secs, err)
+	}
+}
+
+func TestIPCSocket(t *testing.T) {
+	t.Parallel()
+
+	cmd := exec.Command("true")
+
+	s, err := listenIPC(cmd)
+	if err != nil {
+		t.Skipf("Unix sockets not available: %v", err)
+	}
+
+	path, ok := strings.CutPrefix(cmd.Env[len(cmd.Env)-1], "ZTIME_IPC_SOCKET=")
+	if !ok {
+		t.Fatalf("Env = %q, want ZTIME_IPC_SOCKET last", cmd.Env)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := bufio.NewReader(conn)
+
+	fmt.Fprintln(conn, "metric hits 7")
+
+	if reply, err := reader.ReadString('\n'); err != nil || reply != "ok\n" {
+		t.Errorf("reply = %q, %v; want \"ok\"", reply, err)
+	}
+
+	// A metric sent without waiting for the reply still counts.
+	fmt.Fprintln(conn, "metric misses 2")
+
+	var m Metrics
+
+	s.finish(&m, time.Time{})
+	_ = conn.Close()
+
+	if want := map[string]float64{"hits": 7, "misses": 2}; !reflect.DeepEqual(m.Custom, want) {
+		t.Errorf("Custom = %v, want %v", m.Custom, want)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket %s left behind: %v", path, err)
+	}
+}
+
+func TestCustomNote(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		custom map[string]float64
+		want   string
+	}{
+		{nil, ""},
+		{map[string]float64{"rows": 1200, "cache_hit_ratio": 0.93}, "custom: cache_hit_ratio 0.93, rows 1200"},
+	}
+
+	for _, tt := range tests {
+		if got := customNote(Metrics{Custom: tt.custom}); got != tt.want {
+			t.Errorf("customNote(%v) = %q, want %q", tt.custom, got, tt.want)
+		}
+	}
+}
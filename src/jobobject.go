@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errLimitMemUnsupported = errors.New("--limit-mem is not supported")
+
+// JobStats is the accounting of the Job Object the command ran in on
+// Windows, which covers every process it started. On Linux, the cgroup of
+// --limit-mem only reports PeakMemory, in use rather than committed, and
+// MemoryLimit.
+type JobStats struct {
+	Processes         int64  `json:"processes" unit:"count"`              // in the job over the run
+	PeakMemory        int64  `json:"peak_memory" unit:"bytes"`            // committed by the whole job at once
+	PeakProcessMemory int64  `json:"peak_process_memory" unit:"bytes"`    // committed by one process
+	MemoryLimit       int64  `json:"memory_limit,omitempty" unit:"bytes"` // from --limit-mem
+	ReadBytes         int64  `json:"read_bytes" unit:"bytes"`             // by read operations
+	WriteBytes        int64  `json:"write_bytes" unit:"bytes"`            // by write operations
+	OtherBytes        int64  `json:"other_bytes" unit:"bytes"`            // by other I/O, such as device control
+	ReadOps           int64  `json:"read_ops" unit:"count"`
+	WriteOps          int64  `json:"write_ops" unit:"count"`
+	OtherOps          int64  `json:"other_ops" unit:"count"`
+	PageFaults        int64  `json:"page_faults" unit:"count"` // soft and hard
+	Error             string `json:"error,omitempty"`          // why the accounting could not be read
+}
+
+// jobNote describes the Job Object of a run whose memory was limited, or
+// why its accounting could not be read.
+func jobNote(j *JobStats) string {
+	switch {
+	case j == nil:
+		return ""
+	case j.Error != "":
+		return "job: " + j.Error
+	case j.MemoryLimit == 0:
+		return ""
+	case j.Processes == 0:
+		return fmt.Sprintf("job: peak memory %s of the %s limit", humanBytes(j.PeakMemory), humanBytes(j.MemoryLimit))
+	default:
+		return fmt.Sprintf("job: %d processes, peak memory %s of the %s limit", j.Processes, humanBytes(j.PeakMemory), humanBytes(j.MemoryLimit))
+	}
+}
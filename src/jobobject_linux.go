@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// cgroupRoot is where ztime expects the cgroup v2 hierarchy.
+const cgroupRoot = "/sys/fs/cgroup"
+
+// jobObject is the cgroup the command runs in with --limit-mem on Linux,
+// the counterpart of the Windows Job Object: the command and every process
+// it starts are held to its memory.max. Runs without a limit get none.
+type jobObject struct {
+	dir   string
+	fd    *os.File // the cgroup's directory, which the command starts in
+	limit int64
+	debug *debugLog
+}
+
+// newJobObject creates a cgroup limited to --limit-mem and makes cmd start
+// in it, so that it is limited before it runs. Creating it at the top of
+// the hierarchy requires root; with --sudo-collectors it goes in a cgroup
+// the helper delegates to ztime instead.
+func newJobObject(cmd *exec.Cmd, opts runOptions) (*jobObject, error) {
+	if opts.limitMem <= 0 {
+		return nil, nil
+	}
+
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("%w: cgroup v2 is not mounted at %s", errLimitMemUnsupported, cgroupRoot)
+	}
+
+	parent := cgroupRoot
+	if opts.sudoCollectors {
+		delegated, err := delegatedCgroup()
+		if err != nil {
+			return nil, err
+		}
+
+		parent = delegated
+	} else {
+		_ = os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte("+memory"), 0)
+	}
+
+	dir, err := os.MkdirTemp(parent, "ztime-run-")
+	if err != nil {
+		return nil, fmt.Errorf("creating cgroup (requires root or --sudo-collectors): %w", err)
+	}
+
+	j := &jobObject{dir: dir, limit: opts.limitMem, debug: opts.debug}
+
+	if err := os.WriteFile(filepath.Join(dir, "memory.max"), []byte(strconv.FormatInt(opts.limitMem, 10)), 0); err != nil {
+		j.remove()
+
+		return nil, fmt.Errorf("limiting cgroup memory: %w", err)
+	}
+
+	// Swapping out would let the command go past the limit. There is no
+	// memory.swap.max without swap accounting.
+	_ = os.WriteFile(filepath.Join(dir, "memory.swap.max"), []byte("0"), 0)
+
+	j.fd, err = os.Open(dir)
+	if err != nil {
+		j.remove()
+
+		return nil, fmt.Errorf("opening cgroup: %w", err)
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(j.fd.Fd())
+
+	return j, nil
+}
+
+// start closes the cgroup's directory, which p was started in.
+func (j *jobObject) start(p *os.Process) error {
+	if j == nil {
+		return nil
+	}
+
+	_ = j.fd.Close()
+
+	j.debug.printf("pid %d is in cgroup %s", p.Pid, j.dir)
+
+	return nil
+}
+
+// finish records the memory peak of the cgroup and removes it.
+func (j *jobObject) finish(m *Metrics) {
+	if j == nil {
+		return
+	}
+
+	_ = j.fd.Close()
+
+	stats := &JobStats{MemoryLimit: j.limit}
+	m.Job = stats
+
+	// memory.peak is only there from Linux 5.19.
+	if data, err := os.ReadFile(filepath.Join(j.dir, "memory.peak")); err != nil {
+		stats.Error = "reading cgroup memory: " + err.Error()
+	} else if peak, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil {
+		stats.PeakMemory = peak
+	}
+
+	j.remove()
+}
+
+// remove deletes the cgroup. One that processes the command left behind
+// still use stays for them.
+func (j *jobObject) remove() {
+	if err := os.Remove(j.dir); err != nil {
+		j.debug.printf("keeping cgroup %s: %v", j.dir, err)
+	}
+}
+
+// delegatedCgroup has the sudo helper delegate a cgroup to this ztime, once
+// per process, and returns its path.
+var delegatedCgroup = sync.OnceValues(func() (string, error) {
+	if err := runPrivileged(privilegedCreateCgroup, strconv.Itoa(os.Getpid())); err != nil {
+		return "", err
+	}
+
+	return delegatedCgroupPath(os.Getpid()), nil
+})
+
+// delegatedCgroupPath is where the helper creates the cgroup it delegates to
+// the ztime with the given PID.
+func delegatedCgroupPath(pid int) string {
+	return filepath.Join(cgroupRoot, "ztime-sudo-"+strconv.Itoa(pid))
+}
+
+// delegateCgroup is the create-cgroup action of the privileged helper. It
+// creates a cgroup with the memory controller for the run cgroups of the
+// ztime with the given PID, moves that ztime into a leaf of it, and hands
+// it to the user who ran sudo the way cgroup v2 delegates a subtree: the
+// directory with its cgroup.procs, cgroup.threads, and
+// cgroup.subtree_control. A process may only start another in a cgroup if
+// it may write to the cgroup.procs of both cgroups' common ancestor, which
+// is then this one. Cgroups of ztime processes that have exited are removed
+// first.
+func delegateCgroup(pid int) error {
+	removeStaleCgroups()
+
+	top := delegatedCgroupPath(pid)
+	leaf := filepath.Join(top, "ztime")
+
+	_ = os.WriteFile(filepath.Join(cgroupRoot, "cgroup.subtree_control"), []byte("+memory"), 0)
+
+	for _, dir := range []string{top, leaf} {
+		if err := os.Mkdir(dir, 0o755); err != nil && !errors.Is(err, os.ErrExist) { //nolint:gosec // cgroup directories are world-readable.
+			return fmt.Errorf("creating cgroup: %w", err)
+		}
+	}
+
+	// ztime leaves top first, which may only enable controllers while it
+	// has no processes of its own.
+	if err := os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0); err != nil {
+		return fmt.Errorf("moving ztime into its cgroup: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(top, "cgroup.subtree_control"), []byte("+memory"), 0); err != nil {
+		return fmt.Errorf("enabling the memory controller: %w", err)
+	}
+
+	uid, gid, ok := sudoUser()
+	if !ok {
+		return nil
+	}
+
+	for _, path := range []string{
+		top,
+		filepath.Join(top, "cgroup.procs"),
+		filepath.Join(top, "cgroup.threads"),
+		filepath.Join(top, "cgroup.subtree_control"),
+		leaf,
+		filepath.Join(leaf, "cgroup.procs"),
+		filepath.Join(leaf, "cgroup.threads"),
+	} {
+		if err := os.Chown(path, uid, gid); err != nil {
+			return fmt.Errorf("delegating cgroup: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// removeStaleCgroups removes the delegated cgroups of ztime processes that
+// are gone, together with the run cgroups they left.
+func removeStaleCgroups() {
+	tops, _ := filepath.Glob(filepath.Join(cgroupRoot, "ztime-sudo-*"))
+
+	for _, top := range tops {
+		pid, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(top), "ztime-sudo-"))
+		if err != nil || !errors.Is(unix.Kill(pid, 0), unix.ESRCH) {
+			continue
+		}
+
+		children, _ := os.ReadDir(top)
+		for _, child := range children {
+			if child.IsDir() {
+				_ = os.Remove(filepath.Join(top, child.Name()))
+			}
+		}
+
+		_ = os.Remove(top)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !windows && !linux
+
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// jobObject stands in for the Windows Job Object the command runs in;
+// other platforms measure the tree with rusage and end it with signals.
+type jobObject struct{}
+
+func newJobObject(_ *exec.Cmd, opts runOptions) (*jobObject, error) {
+	if opts.limitMem > 0 {
+		return nil, errLimitMemUnsupported
+	}
+
+	return nil, nil
+}
+
+func (*jobObject) start(*os.Process) error { return nil }
+
+func (*jobObject) finish(*Metrics) {}
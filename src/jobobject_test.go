@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestJobNote(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		job      *JobStats
+		expected string
+	}{
+		{"no job", nil, ""},
+		{"no limit", &JobStats{Processes: 3, PeakMemory: 1 << 20}, ""},
+		{"limit", &JobStats{Processes: 14, PeakMemory: 3 << 30, MemoryLimit: 4 << 30}, "job: 14 processes, peak memory 3.0 GiB of the 4.0 GiB limit"},
+		{"cgroup", &JobStats{PeakMemory: 3 << 30, MemoryLimit: 4 << 30}, "job: peak memory 3.0 GiB of the 4.0 GiB limit"},
+		{"unreadable", &JobStats{MemoryLimit: 1 << 30, Error: "reading job accounting: access denied"}, "job: reading job accounting: access denied"},
+		{"unreadable without limit", &JobStats{Error: "reading job memory: access denied"}, "job: reading job memory: access denied"},
+	}
+
+	for _, tt := range tests {
+		if got := jobNote(tt.job); got != tt.expected {
+			t.Errorf("%s: jobNote() = %q, want %q", tt.name, got, tt.expected)
+		}
+	}
+}
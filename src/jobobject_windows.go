@@ -0,0 +1,195 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	procNtResumeProcess         = windows.NewLazySystemDLL("ntdll.dll").NewProc("NtResumeProcess")
+	procK32GetProcessMemoryInfo = windows.NewLazySystemDLL("kernel32.dll").NewProc("K32GetProcessMemoryInfo")
+)
+
+// processMemoryCounters is PROCESS_MEMORY_COUNTERS, which x/sys/windows
+// does not define. Sizes are in bytes.
+type processMemoryCounters struct {
+	Cb                         uint32
+	PageFaultCount             uint32
+	PeakWorkingSetSize         uintptr
+	WorkingSetSize             uintptr
+	QuotaPeakPagedPoolUsage    uintptr
+	QuotaPagedPoolUsage        uintptr
+	QuotaPeakNonPagedPoolUsage uintptr
+	QuotaNonPagedPoolUsage     uintptr
+	PagefileUsage              uintptr
+	PeakPagefileUsage          uintptr
+}
+
+// jobAccounting is JOBOBJECT_BASIC_AND_IO_ACCOUNTING_INFORMATION, which
+// x/sys/windows does not define. Times are in 100ns units.
+type jobAccounting struct {
+	TotalUserTime             int64
+	TotalKernelTime           int64
+	ThisPeriodTotalUserTime   int64
+	ThisPeriodTotalKernelTime int64
+	TotalPageFaultCount       uint32
+	TotalProcesses            uint32
+	ActiveProcesses           uint32
+	TotalTerminatedProcesses  uint32
+	IoInfo                    windows.IO_COUNTERS
+}
+
+// jobObject is the Job Object the command runs in. Every process it starts
+// joins the job too, so the job accounts for the whole tree, holds it to
+// --limit-mem, and can end all of it at once.
+type jobObject struct {
+	handle       windows.Handle
+	process      windows.Handle // the command's own process, kept open for its peak working set
+	memoryLimit  int64
+	killChildren bool
+	debug        *debugLog
+}
+
+// newJobObject creates the job and makes cmd start suspended, so that it
+// is in the job before it can start processes of its own.
+func newJobObject(cmd *exec.Cmd, opts runOptions) (*jobObject, error) {
+	handle, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating job object: %w", err)
+	}
+
+	if opts.limitMem > 0 {
+		var info windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION
+		info.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_JOB_MEMORY
+		info.JobMemoryLimit = uintptr(opts.limitMem)
+
+		if _, err := windows.SetInformationJobObject(handle, windows.JobObjectExtendedLimitInformation,
+			uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+			_ = windows.CloseHandle(handle)
+
+			return nil, fmt.Errorf("limiting job memory: %w", err)
+		}
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.CreationFlags |= windows.CREATE_SUSPENDED
+
+	return &jobObject{handle: handle, memoryLimit: opts.limitMem, killChildren: opts.killChildren, debug: opts.debug}, nil
+}
+
+// start puts the suspended process p in the job and resumes it. If it
+// fails, p is left suspended for the caller to kill.
+func (j *jobObject) start(p *os.Process) error {
+	const access = windows.PROCESS_SET_QUOTA | windows.PROCESS_TERMINATE | windows.PROCESS_SUSPEND_RESUME | windows.PROCESS_QUERY_LIMITED_INFORMATION
+
+	process, err := windows.OpenProcess(access, false, uint32(p.Pid))
+	if err != nil {
+		return fmt.Errorf("opening process: %w", err)
+	}
+
+	if err := windows.AssignProcessToJobObject(j.handle, process); err != nil {
+		_ = windows.CloseHandle(process)
+
+		return fmt.Errorf("adding process to job object: %w", err)
+	}
+
+	if status, _, _ := procNtResumeProcess.Call(uintptr(process)); status != 0 {
+		_ = windows.CloseHandle(process)
+
+		return fmt.Errorf("resuming process: %w", windows.NTStatus(status))
+	}
+
+	j.process = process
+
+	j.debug.printf("pid %d is in its job object", p.Pid)
+
+	return nil
+}
+
+// finish replaces the CPU time and I/O of the command alone with those of
+// its whole job, sets the maximum RSS to the peak working set of the
+// command's own process, terminates what is left of the job with
+// --kill-children, and closes it. The memory the job committed is only in
+// its JobStats, as commit is not resident memory.
+func (j *jobObject) finish(m *Metrics) {
+	defer func() { _ = windows.CloseHandle(j.handle) }()
+
+	if j.process != 0 {
+		m.MaxRSS = peakWorkingSet(j.process) / 1024
+		_ = windows.CloseHandle(j.process)
+	}
+
+	stats := &JobStats{MemoryLimit: j.memoryLimit}
+	m.Job = stats
+
+	var (
+		acct   jobAccounting
+		limits windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION
+	)
+
+	if err := windows.QueryInformationJobObject(j.handle, windows.JobObjectBasicAndIoAccountingInformation,
+		uintptr(unsafe.Pointer(&acct)), uint32(unsafe.Sizeof(acct)), nil); err != nil {
+		stats.Error = fmt.Sprintf("reading job accounting: %v", err)
+
+		return
+	}
+
+	if err := windows.QueryInformationJobObject(j.handle, windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&limits)), uint32(unsafe.Sizeof(limits)), nil); err != nil {
+		stats.Error = fmt.Sprintf("reading job memory: %v", err)
+
+		return
+	}
+
+	if j.killChildren && acct.ActiveProcesses > 0 {
+		j.debug.printf("terminating the %d processes left in the job object", acct.ActiveProcesses)
+
+		_ = windows.TerminateJobObject(j.handle, 1)
+		m.ChildrenKilled = true
+	}
+
+	io := acct.IoInfo
+	stats.Processes = int64(acct.TotalProcesses)
+	stats.PeakMemory = int64(limits.PeakJobMemoryUsed)
+	stats.PeakProcessMemory = int64(limits.PeakProcessMemoryUsed)
+	stats.ReadBytes, stats.WriteBytes, stats.OtherBytes = int64(io.ReadTransferCount), int64(io.WriteTransferCount), int64(io.OtherTransferCount)
+	stats.ReadOps, stats.WriteOps, stats.OtherOps = int64(io.ReadOperationCount), int64(io.WriteOperationCount), int64(io.OtherOperationCount)
+	stats.PageFaults = int64(acct.TotalPageFaultCount)
+
+	m.UserTime = time.Duration(acct.TotalUserTime) * 100
+	m.SystemTime = time.Duration(acct.TotalKernelTime) * 100
+	m.CPUPercent = calculateCPUPercent(m.UserTime, m.SystemTime, m.ElapsedTime)
+	m.Unaccounted = max(m.ElapsedTime-m.UserTime-m.SystemTime, 0)
+	m.BlockInput, m.BlockOutput = stats.ReadOps, stats.WriteOps
+}
+
+// peakWorkingSet returns the most physical memory process ever had
+// resident, in bytes, or 0 if it cannot be read.
+func peakWorkingSet(process windows.Handle) int64 {
+	counters := processMemoryCounters{Cb: uint32(unsafe.Sizeof(processMemoryCounters{}))}
+
+	if ok, _, _ := procK32GetProcessMemoryInfo.Call(uintptr(process), uintptr(unsafe.Pointer(&counters)), uintptr(counters.Cb)); ok == 0 {
+		return 0
+	}
+
+	return int64(counters.PeakWorkingSetSize)
+}
+
+// killTimedOut ends the whole job of a command that ran past --timeout.
+// Windows has no signal to ask it to exit first.
+func killTimedOut(_ *exec.Cmd, j *jobObject, _ runOptions, _ <-chan struct{}) {
+	j.debug.printf("terminating the job object after --timeout")
+
+	_ = windows.TerminateJobObject(j.handle, 1)
+}
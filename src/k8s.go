@@ -0,0 +1,450 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	errK8sPodFailed = errors.New("pod failed before the container started")
+	errQuantity     = errors.New("invalid quantity")
+)
+
+// k8sContainer is the name of the Job's only container.
+const k8sContainer = "command"
+
+// k8sCmd runs a command as a Kubernetes Job and reports it like a local
+// run.
+type k8sCmd struct {
+	Image     string        `required:"" help:"Container image to run the command in." placeholder:"IMAGE"`
+	Namespace string        `short:"n" help:"Namespace to create the Job in; defaults to kubectl's current one." placeholder:"NS"`
+	Kubectl   string        `default:"kubectl" help:"kubectl command to reach the cluster with." placeholder:"CMD"`
+	Interval  time.Duration `default:"2s" help:"Time between polls of the pod's status and resource usage."`
+	Timeout   time.Duration `default:"1h" help:"Give up on a Job that has not finished after this long."`
+	Keep      bool          `help:"Leave the Job and its pod in the cluster afterwards."`
+	JSON      bool          `help:"Output the metrics in JSON format."`
+
+	Command []string `arg:"" help:"Command to run in the container." passthrough:""`
+}
+
+// K8sJob describes the Job behind a ztime k8s run.
+type K8sJob struct {
+	Name       string        `json:"name"`
+	Namespace  string        `json:"namespace,omitempty"`
+	Pod        string        `json:"pod,omitempty"`
+	Node       string        `json:"node,omitempty"`
+	Image      string        `json:"image"`
+	Pending    time.Duration `json:"pending,omitempty"`                  // from creating the Job until the container started: scheduling and image pull
+	Reason     string        `json:"reason,omitempty"`                   // why the container terminated, such as OOMKilled
+	MemoryPeak int64         `json:"memory_peak,omitempty" unit:"bytes"` // highest working set sampled
+	CPUTime    time.Duration `json:"cpu_time,omitempty"`                 // container CPU time; estimated from usage rates with metrics-server
+	Samples    int           `json:"samples" unit:"count"`               // resource usage samples taken
+	Source     string        `json:"source,omitempty"`                   // where the samples came from: kubelet or metrics-server
+}
+
+// k8sPod is the part of a pod object that ztime uses.
+type k8sPod struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Spec struct {
+		NodeName string `json:"nodeName"`
+	} `json:"spec"`
+	Status struct {
+		Phase             string `json:"phase"`
+		Message           string `json:"message"`
+		ContainerStatuses []struct {
+			Name  string `json:"name"`
+			State struct {
+				Running *struct {
+					StartedAt time.Time `json:"startedAt"`
+				} `json:"running"`
+				Terminated *k8sTerminated `json:"terminated"`
+			} `json:"state"`
+		} `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+// k8sTerminated is the state of a container that has exited. Kubernetes
+// records its times to the second.
+type k8sTerminated struct {
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	ExitCode   int       `json:"exitCode"`
+	Signal     int       `json:"signal"`
+	Reason     string    `json:"reason"`
+}
+
+// Run creates the Job, waits for it, copies its logs to stdout, and
+// reports the container's run. ztime exits with the container's exit
+// status.
+func (c *k8sCmd) Run() error {
+	c.Command = dropSeparator(c.Command)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	job := K8sJob{Name: "ztime-" + strings.ToLower(rand.Text())[:12], Namespace: c.Namespace, Image: c.Image}
+
+	if _, err := c.kubectl(ctx, k8sJobManifest(job.Name, c.Image, c.Command), "create", "-f", "-"); err != nil {
+		return err
+	}
+
+	m, err := c.wait(ctx, &job)
+
+	if job.Pod != "" {
+		if logs, err := c.kubectl(context.Background(), nil, "logs", "pod/"+job.Pod, "--container", k8sContainer); err == nil {
+			_, _ = os.Stdout.Write(logs)
+		}
+	}
+
+	if !c.Keep {
+		if _, err := c.kubectl(context.Background(), nil, "delete", "job", job.Name, "--wait=false", "--cascade=background"); err != nil {
+			fmt.Fprintf(os.Stderr, "ztime: %v\n", err)
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		fmt.Fprintf(os.Stderr, "ztime: %v\n", err)
+		os.Exit(exitTimeout)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	r := &runCmd{JSON: c.JSON}
+	r.report(m)
+
+	if !c.JSON {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", k8sNote(job))
+	}
+
+	if m.ExitCode != 0 {
+		os.Exit(m.ExitCode)
+	}
+
+	return nil
+}
+
+// wait polls the Job's pod until its container exits, sampling its
+// resource usage while it runs, and returns the container's run as
+// metrics.
+func (c *k8sCmd) wait(ctx context.Context, job *K8sJob) (Metrics, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	created := time.Now()
+	last := created
+
+	for {
+		pod, err := c.pod(ctx, job.Name)
+		if err != nil {
+			return Metrics{}, err
+		}
+
+		if pod != nil {
+			job.Pod, job.Node, job.Namespace = pod.Metadata.Name, pod.Spec.NodeName, pod.Metadata.Namespace
+
+			for _, s := range pod.Status.ContainerStatuses {
+				if s.Name != k8sContainer {
+					continue
+				}
+
+				if t := s.State.Terminated; t != nil {
+					job.Pending = max(t.StartedAt.Sub(created), 0)
+
+					return k8sMetrics(c.Command, *job, *t), nil
+				}
+
+				if s.State.Running != nil {
+					now := time.Now()
+					c.sample(ctx, job, now.Sub(last))
+					last = now
+				}
+			}
+
+			if pod.Status.Phase == "Failed" {
+				return Metrics{}, fmt.Errorf("%w: %s", errK8sPodFailed, pod.Status.Message)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return Metrics{}, fmt.Errorf("waiting for job %s: %w", job.Name, context.Cause(ctx))
+		case <-time.After(c.Interval):
+		}
+	}
+}
+
+// pod returns the Job's pod, or nil if it has not been created yet.
+func (c *k8sCmd) pod(ctx context.Context, job string) (*k8sPod, error) {
+	out, err := c.kubectl(ctx, nil, "get", "pods", "--selector", "job-name="+job, "--output", "json")
+	if err != nil {
+		return nil, err
+	}
+
+	var list struct {
+		Items []k8sPod `json:"items"`
+	}
+
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, fmt.Errorf("kubectl get pods: %w", err)
+	}
+
+	if len(list.Items) == 0 {
+		return nil, nil //nolint:nilnil // No pod yet is not an error.
+	}
+
+	return &list.Items[0], nil
+}
+
+// sample records the container's resource usage, from the kubelet's stats
+// summary if ztime may read it and from metrics-server otherwise, sticking
+// with the first that works. Usage that cannot be read is skipped.
+func (c *k8sCmd) sample(ctx context.Context, job *K8sJob, since time.Duration) {
+	if job.Source != "metrics-server" {
+		out, err := c.kubectl(ctx, nil, "get", "--raw", "/api/v1/nodes/"+job.Node+"/proxy/stats/summary")
+		if err == nil {
+			if cpu, memory, ok := kubeletUsage(out, *job); ok {
+				job.Source = "kubelet"
+				job.Samples++
+				job.CPUTime = cpu
+				job.MemoryPeak = max(job.MemoryPeak, memory)
+
+				return
+			}
+		}
+	}
+
+	// Cumulative kubelet figures and metrics-server rates do not mix.
+	if job.Source == "kubelet" {
+		return
+	}
+
+	out, err := c.kubectl(ctx, nil, "get", "--raw", "/apis/metrics.k8s.io/v1beta1/namespaces/"+job.Namespace+"/pods/"+job.Pod)
+	if err != nil {
+		return
+	}
+
+	if cores, memory, ok := metricsServerUsage(out); ok {
+		job.Source = "metrics-server"
+		job.Samples++
+		job.CPUTime += time.Duration(cores * float64(since))
+		job.MemoryPeak = max(job.MemoryPeak, memory)
+	}
+}
+
+// kubeletUsage finds the Job's container in a kubelet stats summary and
+// returns its cumulative CPU time and current working set.
+func kubeletUsage(data []byte, job K8sJob) (time.Duration, int64, bool) {
+	var summary struct {
+		Pods []struct {
+			PodRef struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"podRef"`
+			Containers []struct {
+				Name string `json:"name"`
+				CPU  struct {
+					UsageCoreNanoSeconds int64 `json:"usageCoreNanoSeconds"`
+				} `json:"cpu"`
+				Memory struct {
+					WorkingSetBytes int64 `json:"workingSetBytes"`
+				} `json:"memory"`
+			} `json:"containers"`
+		} `json:"pods"`
+	}
+
+	if json.Unmarshal(data, &summary) != nil {
+		return 0, 0, false
+	}
+
+	for _, pod := range summary.Pods {
+		if pod.PodRef.Name != job.Pod || pod.PodRef.Namespace != job.Namespace {
+			continue
+		}
+
+		for _, container := range pod.Containers {
+			if container.Name == k8sContainer {
+				return time.Duration(container.CPU.UsageCoreNanoSeconds), container.Memory.WorkingSetBytes, true
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// metricsServerUsage returns the CPU usage rate in cores and the working
+// set of the Job's container from a metrics-server PodMetrics object.
+func metricsServerUsage(data []byte) (float64, int64, bool) {
+	var metrics struct {
+		Containers []struct {
+			Name  string `json:"name"`
+			Usage struct {
+				CPU    string `json:"cpu"`
+				Memory string `json:"memory"`
+			} `json:"usage"`
+		} `json:"containers"`
+	}
+
+	if json.Unmarshal(data, &metrics) != nil {
+		return 0, 0, false
+	}
+
+	for _, container := range metrics.Containers {
+		if container.Name != k8sContainer {
+			continue
+		}
+
+		cores, err := parseQuantity(container.Usage.CPU)
+		if err != nil {
+			return 0, 0, false
+		}
+
+		memory, err := parseQuantity(container.Usage.Memory)
+		if err != nil {
+			return 0, 0, false
+		}
+
+		return cores, int64(memory), true
+	}
+
+	return 0, 0, false
+}
+
+// parseQuantity parses a Kubernetes resource quantity such as "250m",
+// "1200n", "64Mi", or "2".
+func parseQuantity(s string) (float64, error) {
+	suffixes := []struct {
+		suffix string
+		scale  float64
+	}{
+		{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40},
+		{"n", 1e-9}, {"u", 1e-6}, {"m", 1e-3}, {"k", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12},
+	}
+
+	scale := 1.0
+
+	for _, q := range suffixes {
+		if number, ok := strings.CutSuffix(s, q.suffix); ok {
+			s, scale = number, q.scale
+
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w %q", errQuantity, s)
+	}
+
+	return n * scale, nil
+}
+
+// k8sMetrics is the container's run as metrics. Kubernetes only reports
+// its start and end to the second, and no CPU split between user and
+// system time.
+func k8sMetrics(args []string, job K8sJob, t k8sTerminated) Metrics {
+	elapsed := t.FinishedAt.Sub(t.StartedAt)
+
+	if t.Reason != "Completed" {
+		job.Reason = t.Reason
+	}
+
+	m := Metrics{
+		Command:     strings.Join(args, " "),
+		Argv:        args,
+		ElapsedTime: elapsed,
+		StartTime:   t.StartedAt,
+		EndTime:     t.FinishedAt,
+		ExitCode:    t.ExitCode,
+		CPUPercent:  calculateCPUPercent(job.CPUTime, 0, elapsed),
+		Kubernetes:  &job,
+	}
+
+	switch {
+	case t.Signal != 0:
+		m.TermSignal = signalName(syscall.Signal(t.Signal))
+		m.Killed = true
+	case t.Reason == "OOMKilled":
+		m.TermSignal = signalName(syscall.SIGKILL)
+		m.Killed = true
+	}
+
+	m.OOMKilled = t.Reason == "OOMKilled"
+
+	return m
+}
+
+// k8sJobManifest is a Job that runs args once in a container from image,
+// without retries.
+func k8sJobManifest(name, image string, args []string) []byte {
+	labels := map[string]string{"app.kubernetes.io/managed-by": "ztime"}
+
+	manifest, _ := json.Marshal(map[string]any{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata":   map[string]any{"name": name, "labels": labels},
+		"spec": map[string]any{
+			"backoffLimit": 0,
+			"template": map[string]any{
+				"metadata": map[string]any{"labels": labels},
+				"spec": map[string]any{
+					"restartPolicy": "Never",
+					"containers":    []any{map[string]any{"name": k8sContainer, "image": image, "command": args}},
+				},
+			},
+		},
+	})
+
+	return manifest
+}
+
+// kubectl runs kubectl in the --namespace namespace with stdin, if not nil,
+// and returns its output.
+func (c *k8sCmd) kubectl(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	if c.Namespace != "" {
+		args = append([]string{"--namespace", c.Namespace}, args...)
+	}
+
+	//nolint:gosec // Intended behavior: the kubectl command comes from the user.
+	cmd := exec.CommandContext(ctx, c.Kubectl, args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("kubectl %s: %w%s", strings.Join(args, " "), err, lastLine(stderr.String()))
+	}
+
+	return out, nil
+}
+
+// k8sNote says where the Job ran, how long it waited to start, and what it
+// used.
+func k8sNote(job K8sJob) string {
+	note := fmt.Sprintf("job %s on %s, pending %.0fs", job.Name, job.Node, job.Pending.Seconds())
+
+	if job.Samples == 0 {
+		return note + "; no resource usage sampled"
+	}
+
+	return note + fmt.Sprintf("; %s peak working set, %.2fs CPU (%s)", humanBytes(job.MemoryPeak), job.CPUTime.Seconds(), job.Source)
+}
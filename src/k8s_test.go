@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseQuantity(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{"2", 2, false},
+		{"250m", 0.25, false},
+		{"1500000n", 0.0015, false},
+		{"64Mi", 64 << 20, false},
+		{"12345Ki", 12345 << 10, false},
+		{"1G", 1e9, false},
+		{"lots", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseQuantity(tt.in)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseQuantity(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKubeletUsage(t *testing.T) {
+	t.Parallel()
+
+	summary := []byte(`{"pods":[
+		{"podRef":{"name":"other","namespace":"ci"},"containers":[{"name":"command","cpu":{"usageCoreNanoSeconds":1},"memory":{"workingSetBytes":1}}]},
+		{"podRef":{"name":"job-abc","namespace":"ci"},"containers":[
+			{"name":"sidecar","cpu":{"usageCoreNanoSeconds":2},"memory":{"workingSetBytes":2}},
+			{"name":"command","cpu":{"usageCoreNanoSeconds":3000000000},"memory":{"workingSetBytes":4096}}]}]}`)
+
+	cpu, memory, ok := kubeletUsage(summary, K8sJob{Pod: "job-abc", Namespace: "ci"})
+	if !ok || cpu != 3*time.Second || memory != 4096 {
+		t.Errorf("kubeletUsage() = %v, %d, %v; want 3s, 4096, true", cpu, memory, ok)
+	}
+
+	if _, _, ok := kubeletUsage(summary, K8sJob{Pod: "job-abc", Namespace: "default"}); ok {
+		t.Error("kubeletUsage() found a pod in another namespace")
+	}
+}
+
+func TestMetricsServerUsage(t *testing.T) {
+	t.Parallel()
+
+	cores, memory, ok := metricsServerUsage([]byte(`{"containers":[{"name":"command","usage":{"cpu":"500m","memory":"2Mi"}}]}`))
+	if !ok || cores != 0.5 || memory != 2<<20 {
+		t.Errorf("metricsServerUsage() = %v, %d, %v; want 0.5, 2Mi, true", cores, memory, ok)
+	}
+}
+
+func TestK8sMetrics(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC)
+	job := K8sJob{Name: "ztime-x", CPUTime: 5 * time.Second}
+
+	m := k8sMetrics([]string{"make", "test"}, job, k8sTerminated{StartedAt: start, FinishedAt: start.Add(10 * time.Second), ExitCode: 137, Reason: "OOMKilled"})
+
+	if m.Command != "make test" || m.ElapsedTime != 10*time.Second || m.ExitCode != 137 || m.CPUPercent != 50 {
+		t.Errorf("k8sMetrics() = %+v, want a 10s run at 50%% CPU exiting 137", m)
+	}
+
+	if !m.Killed || !m.OOMKilled || m.TermSignal == "" || m.Kubernetes.Reason != "OOMKilled" {
+		t.Errorf("k8sMetrics() = killed %v by %q, reason %q; want an OOM kill", m.Killed, m.TermSignal, m.Kubernetes.Reason)
+	}
+}
+
+func TestK8sWait(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	// The fake kubectl has no pod on the first poll, a running one on the
+	// second, and a finished one after that. Only metrics-server works.
+	dir := t.TempDir()
+	kubectl := filepath.Join(dir, "kubectl")
+	script := `#!/bin/sh
+polls=` + filepath.Join(dir, "polls") + `
+case "$*" in
+*"get pods"*)
+	echo x >> "$polls"
+	case $(wc -l < "$polls") in
+	*1) echo '{"items":[]}' ;;
+	*2) echo '{"items":[{"metadata":{"name":"job-abc","namespace":"ci"},"spec":{"nodeName":"node-1"},"status":{"phase":"Running","containerStatuses":[{"name":"command","state":{"running":{"startedAt":"2026-03-02T14:00:00Z"}}}]}}]}' ;;
+	*) echo '{"items":[{"metadata":{"name":"job-abc","namespace":"ci"},"spec":{"nodeName":"node-1"},"status":{"phase":"Succeeded","containerStatuses":[{"name":"command","state":{"terminated":{"startedAt":"2026-03-02T14:00:00Z","finishedAt":"2026-03-02T14:00:07Z","exitCode":0,"reason":"Completed"}}}]}}]}' ;;
+	esac ;;
+*stats/summary*) echo "forbidden" >&2; exit 1 ;;
+*metrics.k8s.io*) echo '{"containers":[{"name":"command","usage":{"cpu":"1","memory":"3Mi"}}]}' ;;
+*) exit 1 ;;
+esac
+`
+
+	if err := os.WriteFile(kubectl, []byte(script), 0o700); err != nil { //nolint:gosec // The fake client must be executable.
+		t.Fatal(err)
+	}
+
+	c := k8sCmd{Kubectl: kubectl, Interval: time.Millisecond, Timeout: time.Minute, Command: []string{"make"}}
+	job := K8sJob{Name: "ztime-x"}
+
+	m, err := c.wait(context.Background(), &job)
+	if err != nil {
+		t.Fatalf("wait() error = %v", err)
+	}
+
+	if m.ElapsedTime != 7*time.Second || m.ExitCode != 0 {
+		t.Errorf("wait() = %v, exit %d; want 7s, exit 0", m.ElapsedTime, m.ExitCode)
+	}
+
+	if job.Pod != "job-abc" || job.Node != "node-1" || job.Source != "metrics-server" || job.Samples != 1 || job.MemoryPeak != 3<<20 || job.Reason != "" {
+		data, _ := json.Marshal(job)
+		t.Errorf("job = %s, want one metrics-server sample of pod job-abc on node-1", data)
+	}
+}
@@ -4,283 +4,1329 @@
 package main
 
 import (
-	"bytes"
+	"cmp"
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"net"
 	"os"
 	"os/exec"
-	"os/signal"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
+	"text/template"
 	"time"
 
 	"github.com/alecthomas/kong"
-	"github.com/charmbracelet/lipgloss"
 )
 
 // Metrics holds the timing and resource usage data.
 type Metrics struct {
-	Command      string        `json:"command"`
-	UserTime     time.Duration `json:"user_time"`
-	SystemTime   time.Duration `json:"system_time"`
-	ElapsedTime  time.Duration `json:"elapsed_time"`
-	CPUPercent   int           `json:"cpu_percent"`
-	MaxRSS       int64         `json:"max_rss"`       // in KB
-	SharedRSS    int64         `json:"shared_rss"`    // in KB
-	UnsharedRSS  int64         `json:"unshared_rss"`  // in KB
-	UnsharedData int64         `json:"unshared_data"` // in KB
-	UnsharedStk  int64         `json:"unshared_stk"`  // in KB
-	PageFaults   int64         `json:"page_faults"`   // Major
-	PageReclaims int64         `json:"page_reclaims"` // Minor
-	Swaps        int64         `json:"swaps"`
-	BlockInput   int64         `json:"block_input"`
-	BlockOutput  int64         `json:"block_output"`
-	MsgsSent     int64         `json:"msgs_sent"`
-	MsgsRecv     int64         `json:"msgs_recv"`
-	Signals      int64         `json:"signals"`
-	VCtxSwitches int64         `json:"v_ctx_switches"`
-	ICtxSwitches int64         `json:"i_ctx_switches"`
+	SchemaVersion int `json:"schema_version,omitempty" unit:"none"` // set on top-level results
+
+	Command         string           `json:"command"`
+	RunID           string           `json:"run_id,omitempty"` // shared by every run of one ztime invocation, and exported as ZTIME_RUN_ID
+	UserTime        time.Duration    `json:"user_time"`
+	SystemTime      time.Duration    `json:"system_time"`
+	ElapsedTime     time.Duration    `json:"elapsed_time"`
+	Unaccounted     time.Duration    `json:"unaccounted,omitempty"`  // elapsed minus user and system: waiting on I/O, locks, children, or a CPU
+	WallTime        time.Duration    `json:"wall_time,omitempty"`    // with --exclude-stopped: elapsed time including the time stopped
+	StoppedTime     time.Duration    `json:"stopped_time,omitempty"` // with --exclude-stopped: time stopped by job control, left out of elapsed
+	Stops           int              `json:"stops,omitempty" unit:"count"`
+	Overhead        time.Duration    `json:"overhead,omitempty"` // with --subtract-overhead: ztime's own cost, left out of elapsed
+	CPUPercent      int              `json:"cpu_percent" unit:"percent"`
+	MaxRSS          int64            `json:"max_rss" unit:"kilobytes"`       // in KB on every platform
+	SharedRSS       int64            `json:"shared_rss" unit:"kilobytes"`    // in KB
+	UnsharedRSS     int64            `json:"unshared_rss" unit:"kilobytes"`  // in KB
+	UnsharedData    int64            `json:"unshared_data" unit:"kilobytes"` // in KB
+	UnsharedStk     int64            `json:"unshared_stk" unit:"kilobytes"`  // in KB
+	PageFaults      int64            `json:"page_faults" unit:"count"`       // Major
+	PageReclaims    int64            `json:"page_reclaims" unit:"count"`     // Minor
+	Swaps           int64            `json:"swaps" unit:"count"`
+	BlockInput      int64            `json:"block_input" unit:"count"`
+	BlockOutput     int64            `json:"block_output" unit:"count"`
+	MsgsSent        int64            `json:"msgs_sent" unit:"count"`
+	MsgsRecv        int64            `json:"msgs_recv" unit:"count"`
+	Signals         int64            `json:"signals" unit:"count"`
+	VCtxSwitches    int64            `json:"v_ctx_switches" unit:"count"`
+	ICtxSwitches    int64            `json:"i_ctx_switches" unit:"count"`
+	SignalLog       []SignalEvent    `json:"signal_log,omitempty"`
+	Orphans         []Orphan         `json:"orphans,omitempty"`         // processes still around after the command exited (Linux)
+	ChildrenKilled  bool             `json:"children_killed,omitempty"` // --kill-children found processes left in the command's group and terminated them
+	WorkingSet      *WorkingSet      `json:"working_set,omitempty"`
+	FSTrace         *FSTrace         `json:"fs_trace,omitempty"`
+	ThreadCPU       *ThreadCPU       `json:"thread_cpu,omitempty"`                   // from --per-thread
+	TestReport      *TestReport      `json:"test_report,omitempty"`                  // from --test-report
+	BuildTrace      *BuildTrace      `json:"build_trace,omitempty"`                  // from --build-trace
+	Runtime         *RuntimeStats    `json:"runtime,omitempty"`                      // from --runtime-stats
+	Diagnostics     string           `json:"diagnostics,omitempty"`                  // bundle directory written by --diagnose-on-slow
+	WaitStates      *WaitStates      `json:"wait_states,omitempty"`                  // from --wait-states
+	SchedStats      *SchedStats      `json:"sched_stats,omitempty"`                  // from --sched-stats
+	SchedLatency    *SchedLatency    `json:"sched_latency,omitempty"`                // from --sched-latency
+	IOStats         *IOStats         `json:"io_stats,omitempty"`                     // from --io-stats
+	DelayStats      *DelayStats      `json:"delay_stats,omitempty"`                  // from --delay-stats
+	Pressure        *Pressure        `json:"pressure,omitempty"`                     // from --pressure
+	NetTrace        *NetTrace        `json:"net_trace,omitempty"`                    // from --net-trace
+	PeakThreads     int64            `json:"peak_threads,omitempty" unit:"count"`    // largest of the --jsonl samples (Linux)
+	PeakFDs         int64            `json:"peak_fds,omitempty" unit:"count"`        // largest of the --jsonl samples (Linux)
+	ContextSwitches *ContextSwitches `json:"context_switches,omitempty"`             // rates between the --jsonl samples (Linux)
+	PeakPSS         int64            `json:"peak_pss_kb,omitempty" unit:"kilobytes"` // of the process tree, with --pss
+	PeakUSS         int64            `json:"peak_uss_kb,omitempty" unit:"kilobytes"` // of the process tree, with --pss
+	PeakProcs       int              `json:"peak_processes,omitempty" unit:"count"`  // in the tree, with --pss
+	PSSSamples      int              `json:"pss_samples,omitempty" unit:"count"`
+
+	ExitCode   int           `json:"exit_code" unit:"none"` // -1 if killed or never started
+	TermSignal string        `json:"term_signal,omitempty"` // e.g. "SIGKILL"
+	Killed     bool          `json:"killed"`                // terminated by a signal
+	TimedOut   bool          `json:"timed_out,omitempty"`   // ended by --timeout
+	CoreDumped bool          `json:"core_dumped"`
+	StartTime  time.Time     `json:"start_time"`
+	EndTime    time.Time     `json:"end_time"`
+	ClockJump  time.Duration `json:"clock_jump,omitempty"`      // wall-clock time the run gained or lost to a suspend or clock step
+	PID        int           `json:"pid,omitempty" unit:"none"` // 0 if the command never started
+	PPID       int           `json:"ppid" unit:"none"`          // ztime's own PID
+	Executable string        `json:"executable,omitempty"`      // absolute path the command resolved to
+	Argv       []string      `json:"argv"`
+
+	StdinSource    string        `json:"stdin_source,omitempty"` // file given by --stdin or --stdin-null
+	StdinBytes     int64         `json:"stdin_bytes,omitempty" unit:"bytes"`
+	StdinFirstByte time.Duration `json:"stdin_first_byte,omitempty"` // since run start
+
+	OutputRatePeak     int64         `json:"output_rate_peak,omitempty" unit:"bytes/second"` // bytes/s, with --max-output-rate
+	OutputRateExceeded bool          `json:"output_rate_exceeded,omitempty"`
+	OutputDropped      int64         `json:"output_dropped,omitempty" unit:"bytes"` // bytes
+	OutputThrottled    time.Duration `json:"output_throttled,omitempty"`            // time writes were held back
+
+	FirstOutputMS     float64 `json:"first_output_ms,omitempty" unit:"milliseconds"`         // since run start
+	OutputBytes       int64   `json:"output_bytes,omitempty" unit:"bytes"`                   // stdout and stderr combined
+	OutputLines       int64   `json:"output_lines,omitempty" unit:"count"`                   // stdout and stderr combined
+	OutputBytesPerSec float64 `json:"output_bytes_per_second,omitempty" unit:"bytes/second"` // over the elapsed time
+	OutputLinesPerSec float64 `json:"output_lines_per_second,omitempty" unit:"lines/second"` // over the elapsed time
+
+	Attempts     []Metrics     `json:"attempts,omitempty"`      // every attempt when retried
+	TotalElapsed time.Duration `json:"total_elapsed,omitempty"` // across attempts, including delays
+
+	Cached bool `json:"cached,omitempty"` // reported from --cache without running the command
+
+	Phases []Phase            `json:"phases,omitempty"` // marked by the command with --phases
+	Custom map[string]float64 `json:"custom,omitempty"` // sent by the command over the --ipc socket
+
+	CPUProfile *CPUProfile `json:"cpu_profile,omitempty"` // with --profile-cpu
+	GoProfiles []GoProfile `json:"go_profiles,omitempty"` // with --go-pprof
+
+	Remote     *RemoteRun      `json:"remote,omitempty"`     // with --ssh
+	Container  *ContainerStats `json:"container,omitempty"`  // with --container
+	Kubernetes *K8sJob         `json:"kubernetes,omitempty"` // from ztime k8s
+
+	CgroupThrottle *CgroupThrottle `json:"cgroup_throttle,omitempty"` // when ztime's cgroup has a CPU limit (Linux)
+	Job            *JobStats       `json:"job,omitempty"`             // the Job Object (Windows) or --limit-mem cgroup (Linux) of the process tree
+
+	OOMKilled bool  `json:"oom_killed,omitempty"`             // killed by the kernel's out-of-memory killer
+	OOMKills  int64 `json:"oom_kills,omitempty" unit:"count"` // in ztime's cgroup during the run (Linux)
+	SwapIn    int64 `json:"swap_in,omitempty" unit:"bytes"`   // system-wide during the run (Linux)
+	SwapOut   int64 `json:"swap_out,omitempty" unit:"bytes"`  // system-wide during the run (Linux)
+
+	Annotations
+}
+
+// runOptions controls how runCommand executes and measures the command.
+type runOptions struct {
+	meterStdin       bool
+	stdinPath        string // file to use as stdin instead of ztime's own
+	pty              bool
+	workingSetTop    int // files to list with --working-set; 0 disables the watch
+	fsTraceTop       int // files to list with --fs-trace; 0 disables the trace
+	perThreadTop     int // threads to list with --per-thread; 0 disables the sampling
+	buildTraceTop    int // build steps to list with --build-trace; 0 disables the trace
+	runtimes         []string
+	events           *eventStream  // --jsonl output; nil when disabled
+	debug            *debugLog     // --verbose output; nil when disabled
+	signals          []os.Signal   // to forward to the command; nil forwards signalList()
+	killChildren     bool          // run the command in its own process group and clean it up
+	killGrace        time.Duration // between SIGTERM and SIGKILL for what is left of the group
+	timeout          time.Duration // end the command after this long; 0 disables
+	limitMem         int64         // bytes the job may commit (Windows) or use (Linux); 0 disables
+	sudoCollectors   bool          // run perf, fanotify, and cgroup setup through the sudo helper
+	maxOutputRate    int64         // bytes per second; 0 disables the guard
+	outputRateAction string
+	diagnoseAfter    time.Duration // capture diagnostics if the run is still going after this; 0 disables
+	diagnoseSignal   string
+	phases           bool           // give the command a pipe for phase marks
+	testReport       string         // JUnit XML report to read after the run; "" disables it
+	ipc              bool           // give the command a socket for queries and custom metrics
+	profileCPU       string         // file to write the command's stack samples to; "" disables profiling
+	goPprof          *goPprofConfig // profiles to fetch from the command; nil disables fetching
+	waitStates       bool           // sample the process state to split the unaccounted time
+	schedStats       bool           // read the scheduler statistics of the exited process before reaping it
+	schedLatency     bool           // sample the scheduler statistics of every thread of the process tree
+	ioStats          bool           // read the I/O accounting of the exited process before reaping it
+	delayStats       bool           // query the delay accounting of the exited process before reaping it
+	pressure         bool           // sample the pressure stall information during the run
+	netTrace         bool           // sample the sockets of the process tree
+	excludeStopped   bool           // leave the time the command is stopped out of the elapsed time
+	precisionNS      bool           // time the run with the raw monotonic clock
+	pss              bool           // sample the PSS and USS of the process tree
+	meterOutput      bool           // count the command's output and record when it starts
+	iteration        int            // 1-based position of this run; 0 means 1
+	runID            string         // exported as ZTIME_RUN_ID
+	env              []string       // KEY=VALUE pairs from --env
+	totalRuns        int            // number of planned runs; 0 if unknown
+}
+
+// serverVersion is set at release time with -ldflags "-X main.serverVersion=...".
+//
+//nolint:gochecknoglobals // Set by the linker.
+var serverVersion = "dev"
+
+// commandLine is the top-level CLI. Timing a command is the default, so
+// "ztime make" and "ztime run make" are equivalent.
+type commandLine struct {
+	Config  kong.ConfigFlag `help:"Read flag defaults from this TOML file; its values take precedence over the default config file." placeholder:"FILE"`
+	Profile string          `help:"Apply the bundle of flag defaults defined as [profile.NAME] in the config file." placeholder:"NAME"`
+	Preset  string          `help:"Apply ztime's bundle of flag defaults for benchmarking a build tool: cargo, go, or npm. Config files and the command line take precedence." placeholder:"NAME"`
+
+	Color string            `help:"When to color the output (${enum}); auto colors it on a terminal unless NO_COLOR is set or CLICOLOR_FORCE forces it." enum:"auto,always,never" default:"auto"`
+	Theme map[string]string `help:"Restyle a part of the output as ROLE=STYLE, where ROLE is user, system, cpu, total, command, plot, good, warn, or bad and STYLE is an ANSI color number or #RRGGBB with any of bold, faint, italic, and underline; a [theme] table in the config file sets them too." placeholder:"ROLE=STYLE" mapsep:","`
+
+	Run        runCmd        `cmd:"" default:"withargs" help:"Time a command (default)."`
+	Selftest   selftestCmd   `cmd:"" help:"Compare ztime's output with zsh's time reserved word."`
+	Verify     verifyCmd     `cmd:"" help:"Verify a JSON result signed with --sign or attested with --attest."`
+	Fleet      fleetCmd      `cmd:"" help:"Run a benchmark suite on several hosts over SSH and compare them."`
+	Check      checkCmd      `cmd:"" help:"Time a command and flag it if it is significantly slower than its recorded history."`
+	Serve      serveCmd      `cmd:"" help:"Run commands on request over HTTP, streaming their metrics, with history and Prometheus endpoints."`
+	Tui        tuiCmd        `cmd:"" name:"tui" help:"Browse the runs recorded by check and serve: filter them, view details, compare two, and chart trends."`
+	Import     importCmd     `cmd:"" help:"Record results measured with hyperfine or GNU time -v in the history, so check, tui, and trend see them."`
+	Merge      mergeCmd      `cmd:"" help:"Combine result files from several machines or CI shards into one result grouped by host."`
+	Stats      statsCmd      `cmd:"" help:"Summarize result files per benchmark and host, comparing the hosts."`
+	Trend      trendCmd      `cmd:"" help:"Chart the elapsed times of recorded runs matching a pattern over time, or export them as CSV."`
+	K8s        k8sCmd        `cmd:"" name:"k8s" help:"Run a command as a Kubernetes Job and report its duration and resource usage."`
+	Version    versionCmd    `cmd:"" help:"Print ztime's version; --check reports whether a newer release exists."`
+	SelfUpdate selfUpdateCmd `cmd:"" name:"self-update" help:"Replace this binary with the latest release from GitHub, after checking its signed SHA-256 checksum."`
+	Hook       hookCmd       `cmd:"" help:"Print shell functions that report every interactive command taking longer than ZTIME_REPORTWALL; use eval \"$(ztime hook zsh)\"."`
+
+	HookReport hookReportCmd `cmd:"" hidden:"" help:"Report a command timed by a shell hook."`
+
+	Privileged privilegedCmd `cmd:"" hidden:"" help:"Perform a privileged collector action (run through sudo by --sudo-collectors)."`
+}
+
+// runCmd times a single command.
+type runCmd struct {
+	JSON            bool              `help:"Output metrics in JSON format." xor:"output"`
+	CanonicalJSON   bool              `help:"Print JSON with sorted keys and no whitespace, for hashing and diffing byte-for-byte; implies --json." name:"canonical-json" xor:"canonical-template,canonical-jsonl"`
+	JSONL           bool              `name:"jsonl" help:"Stream JSON Lines events (run_started, sample, run_finished, ...) as they happen." xor:"output,canonical-jsonl,sign-jsonl"`
+	JSONLInterval   time.Duration     `name:"jsonl-interval" help:"Time between sample events with --jsonl; 0 disables them." default:"1s"`
+	Template        string            `help:"Render metrics with a Go text/template (file path or template text)." xor:"output,sign-template,canonical-template"`
+	StatsD          string            `name:"statsd" help:"Send elapsed, user, and system timers and a max_rss gauge to this StatsD or DogStatsD agent over UDP after each run; --tag labels become DogStatsD tags." placeholder:"HOST:PORT"`
+	StatsDPrefix    string            `name:"statsd-prefix" help:"Prefix of the --statsd metric names." default:"ztime."`
+	Exporter        []string          `help:"Send results to an exporter command speaking the exporter protocol (repeatable)." placeholder:"CMD" sep:"none"`
+	ExportHyperfine string            `name:"export-hyperfine" help:"Also write the results to FILE in hyperfine's --export-json format, for its plotting scripts and other tools that read it." type:"path" placeholder:"FILE"`
+	Schema          bool              `help:"Print the JSON Schema of the JSON output, with the unit of every number, and exit."`
+	Sign            string            `help:"Sign the JSON result with an Ed25519 private key (PEM); implies --json." type:"existingfile" placeholder:"KEYFILE" xor:"sign-template,sign-jsonl"`
+	Attest          bool              `help:"With --sign, write an in-toto attestation in a DSSE envelope instead, stating that the git commit in the working directory measured as the result on this machine; implies --git and --sysinfo."`
+	TimeFmt         string            `name:"timefmt" env:"TIMEFMT" help:"Format of the summary line, using zsh's TIMEFMT specifiers." placeholder:"FORMAT"`
+	FormatDialect   string            `help:"Letters of --timefmt and TIMEFMT: zsh's, or GNU time's --format letters, such as %e, %C, and %x." enum:"zsh,gnu" default:"zsh"`
+	Specifier       map[string]string `help:"Define %{NAME} for --timefmt as the format TEMPLATE (repeatable); a [specifiers] table in the config file defines them too." placeholder:"NAME=TEMPLATE" mapsep:"none"`
+	Quiet           bool              `short:"q" help:"Suppress the summary output."`
+	ReportThreshold string            `name:"report-threshold" env:"REPORTTIME" help:"Print the summary only if the command's user plus system time is at least this long, in seconds as in zsh's REPORTTIME or as a duration; negative always prints it. JSON and other structured output are not affected." placeholder:"SECONDS"`
+	All             bool              `help:"Add the maximum RSS, page faults, context switches, and block I/O to the summary, leaving out those that are zero."`
+	Long            bool              `help:"Print the summary with each metric on its own line; it switches to this by itself when the one-line summary does not fit the terminal."`
+	Human           bool              `help:"Print memory specifiers (%M, %X, %D, %K) and output sizes (%B, %b) as human-readable sizes."`
+	Verbose         bool              `short:"v" help:"Log what ztime itself does, such as starting the command, running hooks, forwarding signals, and taking samples."`
+	DryRun          bool              `help:"Print how the command would be run (program, arguments, environment changes, working directory, and limits) without running it."`
+
+	Env     map[string]string `help:"Set an environment variable for the command and its hooks as KEY=VALUE (repeatable)." placeholder:"KEY=VALUE" mapsep:"none"`
+	Tag     map[string]string `help:"Label the result with KEY=VALUE, such as branch=main, for grouping results later (repeatable)." placeholder:"KEY=VALUE" mapsep:"none"`
+	Note    string            `help:"Attach a free-form note to the result." placeholder:"TEXT"`
+	Git     bool              `help:"Record the commit, branch, and dirty status of the git repository in the working directory."`
+	SysInfo bool              `name:"sysinfo" help:"Record the machine and its state: OS, kernel, CPU, memory, load average, CPU governor, and power source."`
+
+	StdinStats bool   `help:"Relay stdin through a pipe to measure bytes fed to the command and time to first byte." xor:"pty-stats,ssh-stats"`
+	Stdin      string `help:"Give the command this file as stdin instead of the terminal; reopened for every run." type:"existingfile" placeholder:"FILE" xor:"stdin,pty-stdin"`
+	StdinNull  bool   `help:"Give the command an empty stdin (/dev/null)." xor:"stdin,pty-null"`
+	PTY        bool   `name:"pty" help:"Run the command on a pseudo-terminal so it behaves as it does interactively (Linux and macOS)." xor:"pty-stdin,pty-null,pty-stats,pty-rate,pty-runtime,ssh-pty,pty-parallel,pty-first,pty-output"`
+
+	MaxOutputRate    byteRate `help:"Watch for combined stdout/stderr output faster than this, e.g. 1MiB/s." placeholder:"SIZE/s" xor:"pty-rate,ssh-rate"`
+	OutputRateAction string   `help:"What to do when --max-output-rate is exceeded (${enum})." enum:"warn,throttle,truncate" default:"warn"`
+	FirstOutput      bool     `help:"Relay stdout and stderr through pipes to measure how long the command takes to write its first output; implied by %f in --timefmt." xor:"pty-first,ssh-first"`
+	OutputStats      bool     `help:"Relay stdout and stderr through pipes to count the lines and bytes the command writes and their rate; implied by %L, %B, %l, and %b in --timefmt." xor:"pty-output,ssh-output"`
+
+	Runs            int           `help:"Benchmark: run the command N times and report statistics." placeholder:"N" xor:"runs-retries,runs-min,runs-max,runs-cache,runs-watch,runs-change,runs-parallel,runs-suite"`
+	Duration        time.Duration `help:"Benchmark: run the command repeatedly for this long and report throughput." xor:"duration-retries,duration-cache,duration-watch,duration-change,duration-parallel,duration-suite"`
+	MinRuns         int           `help:"Benchmark until stable: run at least N times (default 3)." placeholder:"N" xor:"runs-min,min-retries,min-cache,min-watch,min-change,min-parallel,min-suite"`
+	MaxRuns         int           `help:"Benchmark until stable: run at most N times (default 100)." placeholder:"N" xor:"runs-max,max-retries,max-cache,max-watch,max-change,max-parallel,max-suite"`
+	TargetCV        float64       `help:"Benchmark until stable: stop once the coefficient of variation of elapsed time is at most this." default:"0.05" name:"target-cv"`
+	DropOutliers    bool          `help:"Leave runs with outlying elapsed times out of the benchmark statistics."`
+	Plot            bool          `help:"Draw the elapsed times of a benchmark as a sparkline in run order and a histogram."`
+	Compare         string        `help:"Benchmark the command against this shell command and report which is faster." placeholder:"CMD" xor:"compare-retries,compare-cache,compare-watch,compare-change,compare-parallel,compare-suite"`
+	Interleave      bool          `help:"With --compare, alternate runs of the two commands instead of running them one after the other."`
+	Watch           time.Duration `help:"Re-run the command this long after each run ends, until interrupted, printing each elapsed time with the average of the last 10 runs." placeholder:"INTERVAL" xor:"runs-watch,duration-watch,min-watch,max-watch,compare-watch,retries-watch,cache-watch,watch-change,watch-parallel,watch-suite"`
+	OnChange        []string      `help:"Re-run the command whenever a file under PATH changes, until interrupted (repeatable)." type:"path" placeholder:"PATH" sep:"none" xor:"runs-change,duration-change,min-change,max-change,compare-change,retries-change,cache-change,watch-change,change-parallel,change-suite"`
+	OnChangeExclude []string      `help:"Ignore changes to files and directories under the --on-change paths whose name or path matches GLOB, such as build outputs (repeatable)." placeholder:"GLOB" sep:"none"`
+
+	Parallel bool   `help:"Run each argument as a shell command, all at the same time, and print a table of their metrics." xor:"runs-parallel,duration-parallel,min-parallel,max-parallel,compare-parallel,watch-parallel,change-parallel,retries-parallel,cache-parallel,pty-parallel,parallel-suite"`
+	Jobs     int    `help:"With --parallel, run at most N commands at a time." placeholder:"N"`
+	Suite    string `help:"Benchmark the named commands of this suite file one after the other, each with its own runs and hooks, and report them together." type:"existingfile" placeholder:"FILE" xor:"runs-suite,duration-suite,min-suite,max-suite,compare-suite,watch-suite,change-suite,retries-suite,cache-suite,parallel-suite"`
+
+	FailIf []threshold `help:"Exit with status 1 if the run, or the mean of a benchmark's runs, meets CONDITION, such as 'elapsed > 30s' or 'max_rss > 2G' (repeatable)." placeholder:"CONDITION" sep:"none"`
+	Budget string      `help:"Check the run against the per-metric warn and fail limits in this TOML or YAML file, print a pass/warn/fail table, and exit with status 1 on a failure." type:"existingfile" placeholder:"FILE"`
+
+	ExitStatusFrom string `help:"Where ztime's exit status comes from (${enum}): the command's status, ztime's own success even if the command failed, or always 0." enum:"child,self,always-zero" default:"child" placeholder:"SOURCE"`
+
+	Retries    int           `help:"Re-run the command up to N times while it exits non-zero." placeholder:"N" xor:"runs-retries,duration-retries,min-retries,max-retries,compare-retries,retries-watch,retries-change,retries-parallel,retries-suite"`
+	RetryDelay time.Duration `help:"Time to wait between attempts." default:"0s"`
+
+	Cache      bool     `help:"Skip the command if it already succeeded with the same arguments, working directory, environment, options such as --env and --timeout, and --cache-input contents, and report the stored metrics." xor:"runs-cache,duration-cache,min-cache,max-cache,compare-cache,cache-watch,cache-change,ssh-cache,cache-parallel,cache-suite"`
+	CacheInput []string `help:"File or directory whose contents are part of the --cache key (repeatable)." type:"path" placeholder:"PATH" sep:"none"`
+	CacheEnv   []string `help:"Make only these environment variables part of the --cache key, instead of all but per-session ones such as SSH_AUTH_SOCK (repeatable)." placeholder:"NAME"`
+
+	Prepare string `help:"Shell command to run before each run, excluded from the timing; failure aborts." placeholder:"CMD"`
+	Cleanup string `help:"Shell command to run after each run, excluded from the timing; failure aborts." placeholder:"CMD"`
+
+	Cooldown    time.Duration `help:"Wait this long after each run before starting the next, so heat from one run does not throttle the next."`
+	RequireIdle float64       `help:"Refuse to start a run while the 1-minute load average is above LOAD (Linux and macOS)." placeholder:"LOAD"`
+	IdleWait    time.Duration `help:"With --require-idle, wait up to this long for the load to fall instead of refusing at once."`
+
+	DropCaches     bool `help:"Sync and drop the page cache before each run to measure cold starts (Linux and macOS; requires root)."`
+	SudoCollectors bool `help:"Run the privileged parts of --drop-caches, the perf sampling of --profile-cpu and --diagnose-on-slow, the fanotify watch of --working-set and --fs-trace, and the cgroup of --limit-mem through a sudo helper instead of requiring ztime itself to run as root."`
+
+	ForwardSignals   []string `help:"Forward only these signals to the command, such as INT,TERM; others have their usual effect on ztime (Unix)." placeholder:"SIGNALS" xor:"forward-signals"`
+	NoForwardSignals bool     `help:"Forward no signals to the command; they have their usual effect on ztime, and the command only gets what the terminal sends it." xor:"forward-signals"`
+
+	KillOrphans  bool          `help:"Kill processes the command leaves running after it exits (Linux, FreeBSD)."`
+	KillChildren bool          `help:"Run the command in its own process group, forward signals to the whole group, and terminate what is left of it when the command exits; on Windows, terminate what is left of its Job Object." xor:"ssh-kill"`
+	KillGrace    time.Duration `help:"With --kill-children or --timeout, time between SIGTERM and SIGKILL." default:"2s"`
+	Timeout      time.Duration `help:"End the command if it runs longer than this, with SIGTERM and then SIGKILL after --kill-grace (its whole Job Object at once on Windows), and exit with status 124." xor:"ssh-timeout"`
+	LimitMem     byteSize      `name:"limit-mem" help:"Limit the memory the command and every process it starts may commit together, such as 2GiB, with a Job Object limit (Windows) or a cgroup v2 memory.max (Linux; requires root or --sudo-collectors)." placeholder:"SIZE" xor:"ssh-limitmem"`
+	WorkingSet   int           `help:"Record the files the command opens and list the N largest (Linux; requires root)." placeholder:"N" xor:"ssh-ws,container-ws"`
+	FSTrace      int           `name:"fs-trace" help:"Count the opens, reads, and writes of every file the command and its children touch, estimate the bytes moved, and list the N busiest files (Linux; requires root)." placeholder:"N" xor:"ssh-fstrace,container-fstrace"`
+	PerThread    int           `name:"per-thread" help:"Sample the CPU time of every thread of the command and its children every 20ms, and list the N busiest with the number of distinct CPUs they ran on (Linux)." placeholder:"N" xor:"ssh-perthread,container-perthread"`
+
+	WaitStates   bool `help:"Sample the state of the command's process every 10ms to split its unaccounted time (elapsed minus CPU time) into sleeping and disk wait (Linux)." xor:"ssh-waits"`
+	SchedStats   bool `help:"Report how long the command's main thread waited for a CPU and how often it moved between CPUs, from the scheduler's statistics (Linux)." xor:"ssh-sched"`
+	SchedLatency bool `name:"sched-latency" help:"Read the scheduler statistics of every thread of the command and its children every 10ms, and report a histogram of how long they waited for a CPU before each timeslice (Linux)." xor:"ssh-schedlat,container-schedlat"`
+	NetTrace     bool `name:"net-trace" help:"Watch the sockets of the command and its children every 20ms, and report the TCP connections they made, the bytes sent to and received from each remote host, and the DNS lookups they waited for (Linux)." xor:"ssh-net,container-net"`
+	IOStats      bool `name:"io-stats" help:"Report the bytes the command and the descendants it waited for read from and wrote to storage, from the kernel's I/O accounting, next to the block operation counts (Linux)." xor:"ssh-io,container-io"`
+	DelayStats   bool `name:"delay-stats" help:"Report how long the command waited for a CPU, for block I/O, for swap, and for memory reclaim, from the kernel's delay accounting over taskstats. Needs CAP_NET_ADMIN and kernel.task_delayacct=1 (Linux)." xor:"ssh-delay,container-delay"`
+	Pressure     bool `name:"pressure" help:"Read the pressure stall information of the machine, or of ztime's cgroup, every 100ms and report how much of the run tasks stalled waiting for CPU, memory, and I/O (Linux)." xor:"ssh-pressure,container-pressure"`
+	PSS          bool `name:"pss" help:"Sample the proportional (PSS) and unique (USS) memory of the command and its descendants every 100ms and report their peaks, which count shared pages once unlike the maximum RSS (Linux)." xor:"ssh-pss"`
+
+	ExcludeStopped   bool `help:"Leave the time the command spends stopped (Ctrl-Z, SIGSTOP) out of the elapsed time, reporting the wall-clock time separately (Linux)." xor:"ssh-stopped,container-stopped"`
+	Calibrate        bool `help:"Measure ztime's own overhead of starting, timing, and reaping a command by timing a program that does nothing, print it, and exit."`
+	SubtractOverhead bool `help:"Measure ztime's overhead before running the command, as --calibrate does, and leave it out of the elapsed time." xor:"ssh-overhead,container-overhead"`
+	PrecisionNS      bool `name:"precision-ns" help:"Time the command with the raw monotonic clock, which NTP does not adjust, and print times to the microsecond; JSON times are always integer nanoseconds."`
+
+	Phases     bool   `help:"Give the command a pipe, whose descriptor is in ZTIME_MARK_FD, on which it can write ZTIME-MARK:NAME lines to mark where each phase starts, and report how long each phase took." xor:"ssh-phases,container-phases"`
+	TestReport string `name:"test-report" help:"After the command exits, read the JUnit XML report it wrote to FILE, as gotestsum --junitfile and pytest --junitxml do, and report its test counts, slowest tests, and failures." placeholder:"FILE" type:"path" xor:"ssh-testreport,container-testreport"`
+	BuildTrace int    `name:"build-trace" help:"When the command is ninja or make, list the N slowest build steps, from what the build appended to .ninja_log or from the output of make --trace, which ztime adds." placeholder:"N" xor:"ssh-buildtrace,container-buildtrace"`
+	IPC        bool   `name:"ipc" help:"Listen on a Unix socket, whose path is in ZTIME_IPC_SOCKET, on which the command can send \"elapsed\" lines to get its elapsed time and \"metric NAME VALUE\" lines to add custom metrics to the result." xor:"ssh-ipc,container-ipc"`
+
+	ProfileCPU string `name:"profile-cpu" help:"Sample the command's stacks with perf (Linux) or sample (macOS) while it runs and write them to FILE as folded stacks for flamegraph.pl or speedscope." placeholder:"FILE" type:"path" xor:"ssh-profile,container-profile"`
+
+	GoPprof        string          `name:"go-pprof" help:"Fetch profiles from the net/http/pprof endpoint of the Go program being timed at this address, such as localhost:6060, while it runs, and save them next to the result." placeholder:"ADDR" xor:"ssh-gopprof,container-gopprof"`
+	GoPprofAt      []time.Duration `name:"go-pprof-at" help:"With --go-pprof, fetch the profiles this long after the command starts (repeatable)." default:"5s" placeholder:"DURATION"`
+	GoPprofProfile []string        `name:"go-pprof-profile" help:"With --go-pprof, the profiles to fetch (${enum})." enum:"cpu,heap,allocs,goroutine,block,mutex" default:"cpu,heap" placeholder:"PROFILE"`
+	GoPprofCPU     time.Duration   `name:"go-pprof-cpu" help:"With --go-pprof, how long each CPU profile samples." default:"5s"`
+	GoPprofDir     string          `name:"go-pprof-dir" help:"With --go-pprof, the directory to save the profiles in (default ztime-pprof-RUNID)." type:"path" placeholder:"DIR"`
+
+	RuntimeStats []string `help:"Collect GC and import statistics from the command's language runtime (${enum})." enum:"go,jvm,python" placeholder:"RUNTIME" xor:"pty-runtime,ssh-runtime,container-runtime"`
+
+	DiagnoseOnSlow time.Duration `help:"If the command is still running after this long, save a diagnostic bundle (process state, open files, perf sample) and report its path." xor:"ssh-diagnose,container-diagnose"`
+	DiagnoseSignal string        `help:"With --diagnose-on-slow, also send this signal for a stack dump, e.g. QUIT for a JVM thread dump (Go programs exit on QUIT)." placeholder:"SIGNAL"`
+
+	SSH       string `name:"ssh" help:"Run the command on this SSH destination, such as user@host, under the ztime installed there, and report the remote elapsed time and the ssh overhead separately." placeholder:"DEST" xor:"ssh-pty,ssh-stats,ssh-rate,ssh-runtime,ssh-ws,ssh-diagnose,ssh-cache,ssh-container,ssh-phases,ssh-first,ssh-output,ssh-waits,ssh-sched,ssh-pss,ssh-kill,ssh-stopped,ssh-overhead,ssh-ipc,ssh-profile,ssh-gopprof,ssh-io,ssh-fstrace,ssh-net,ssh-schedlat,ssh-delay,ssh-pressure,ssh-perthread,ssh-testreport,ssh-buildtrace,ssh-timeout,ssh-limitmem"`
+	SSHZtime  string `name:"ssh-ztime" help:"Command that runs ztime on the --ssh host." default:"ztime" placeholder:"CMD"`
+	SSHClient string `name:"ssh-client" help:"SSH client to connect with." default:"ssh" placeholder:"CMD"`
+
+	Container        string   `help:"Run the command in a new container from IMAGE with docker or podman, and record the container's memory peak, CPU time, and CPU throttling from the runtime's stats API." placeholder:"IMAGE" xor:"ssh-container,container-ws,container-runtime,container-diagnose,container-phases,container-stopped,container-overhead,container-ipc,container-profile,container-gopprof,container-io,container-fstrace,container-net,container-schedlat,container-delay,container-pressure,container-perthread,container-testreport,container-buildtrace"`
+	ContainerRuntime string   `help:"Container runtime for --container (${enum})." enum:"docker,podman" default:"docker"`
+	ContainerArg     []string `help:"Pass an option to the container runtime's run command, such as --container-arg=--volume=.:/src (repeatable)." placeholder:"ARG" sep:"none"`
+
+	Command []string `arg:"" optional:"" help:"Command to execute." passthrough:""`
+
+	budget     []budgetEntry
+	git        *GitInfo
+	sysinfo    *SystemInfo
+	tmpl       *template.Template
+	signingKey ed25519.PrivateKey
+	attestRepo string // subject of --attest
+	exporters  []*exporter
+	statsd     net.Conn
+	events     *eventStream
+	debug      *debugLog
+	runID      string
+	signals    []os.Signal   // from --forward-signals; nil for the default set
+	overhead   time.Duration // measured for --subtract-overhead
+	reportTime time.Duration // from --report-threshold; negative when unset
+
+	preset         string             // from --preset, for its summary; "" with --template
+	lastRunEnd     time.Time          // for --cooldown
+	loads          func() *[3]float64 // for --require-idle; loadAverage when nil
+	orphansWatched bool
+	seenOrphans    map[int]bool
 }
 
 func main() {
-	var cli struct {
-		JSON    bool     `help:"Output metrics in JSON format."`
-		Quiet   bool     `short:"q" help:"Suppress the summary output."`
-		Command []string `arg:"" help:"Command to execute." passthrough:""`
+	if isNoop(os.Args) {
+		return
 	}
 
-	kctx := kong.Parse(&cli,
+	var cli commandLine
+
+	configs := &configFiles{}
+
+	parser := kong.Must(&cli,
 		kong.Name("ztime"),
 		kong.Description("A shell-independent command timer replacement for 'zsh time'."),
 		kong.UsageOnError(),
+		kong.Resolvers(presetResolver{files: configs}),
+		kong.Configuration(configs.load, configPath()),
 	)
 
-	if len(cli.Command) == 0 {
+	kctx, err := parser.Parse(escapeCommand(os.Args[1:], subcommands(parser.Model.Node)))
+	parser.FatalIfErrorf(err)
+	kctx.FatalIfErrorf(configs.checkProfile(cli.Profile))
+	kctx.FatalIfErrorf(checkPreset(cli.Preset))
+	cli.Run.preset = cli.Preset
+	kctx.FatalIfErrorf(setupColors(cli.Color, cli.Theme))
+
+	kctx.FatalIfErrorf(kctx.Run())
+}
+
+// subcommands returns the names and aliases of ztime's subcommands.
+func subcommands(root *kong.Node) []string {
+	var names []string
+
+	for _, child := range root.Children {
+		if child.Type == kong.CommandNode {
+			names = append(names, child.Name)
+			names = append(names, child.Aliases...)
+		}
+	}
+
+	return names
+}
+
+// escapeCommand makes "ztime [FLAGS] -- NAME ..." time the command NAME
+// even when ztime has a subcommand of that name, by selecting run
+// explicitly: Kong drops the "--" and would take NAME as the subcommand.
+// Arguments that already name a subcommand before the "--" are left as
+// they are.
+func escapeCommand(args, commands []string) []string {
+	separator := slices.Index(args, "--")
+	if separator < 0 {
+		return args
+	}
+
+	for _, arg := range args[:separator] {
+		if slices.Contains(commands, arg) {
+			return args
+		}
+	}
+
+	return append([]string{"run"}, args...)
+}
+
+// dropSeparator removes the "--" that Kong keeps at the start of
+// passthrough arguments, as in "ztime run -- cmd".
+func dropSeparator(args []string) []string {
+	if len(args) > 0 && args[0] == "--" {
+		return args[1:]
+	}
+
+	return args
+}
+
+// Run times the command and exits with its exit status.
+func (c *runCmd) Run(kctx *kong.Context) error {
+	c.Command = dropSeparator(c.Command)
+
+	if c.Schema {
+		data, err := json.MarshalIndent(outputSchema(), "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(data))
+
+		return nil
+	}
+
+	if c.Calibrate {
+		os.Exit(c.exitStatus(c.printCalibration()))
+	}
+
+	if len(c.Command) == 0 && c.Suite == "" {
 		_ = kctx.PrintUsage(false)
 
 		os.Exit(0)
 	}
 
-	metrics, err := runCommand(cli.Command)
+	if c.DryRun {
+		os.Exit(c.exitStatus(c.dryRun()))
+	}
+
+	if templateSet(kctx) {
+		c.preset = ""
+	}
+
+	if err := c.prepare(); err != nil {
+		return err
+	}
+
+	if c.Watch > 0 || len(c.OnChange) > 0 {
+		os.Exit(c.exitStatus(c.watch()))
+	}
+
+	if c.Suite != "" {
+		os.Exit(c.exitStatus(c.suite()))
+	}
+
+	if c.Parallel {
+		os.Exit(c.exitStatus(c.parallel()))
+	}
+
+	if c.benchmarking() {
+		os.Exit(c.exitStatus(c.bench()))
+	}
+
+	metrics, err := c.cachedRun(c.runOptions(runOptions{meterStdin: c.StdinStats, totalRuns: 1}))
+	c.export("run", metrics)
 
 	// 5. Output
-	if !cli.Quiet {
-		if cli.JSON {
-			data, _ := json.MarshalIndent(metrics, "", "  ")
+	if !c.Quiet && c.reportable(metrics) {
+		c.report(metrics)
+		c.printNotes(metrics)
 
-			fmt.Fprintln(os.Stderr, string(data))
-		} else {
-			printSummary(metrics)
+		if len(metrics.Attempts) > 1 && !c.structured() {
+			fmt.Fprintf(os.Stderr, "ztime: %d attempts, %.3fs total\n", len(metrics.Attempts), metrics.TotalElapsed.Seconds())
 		}
 	}
 
 	// 6. Exit Code
 	if err == nil {
-		os.Exit(0)
+		err = c.checkThresholds(metrics)
 	}
 
-	var exitErr *exec.ExitError
-	if errors.As(err, &exitErr) {
-		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
-			os.Exit(128 + int(status.Signal()))
+	os.Exit(c.exitStatus(err))
+
+	return nil
+}
+
+// prepare loads the files named by flags before the command runs, so
+// mistakes are reported without wasting a run.
+func (c *runCmd) prepare() error {
+	var err error
+
+	if c.Sign != "" {
+		if c.signingKey, err = loadSigningKey(c.Sign); err != nil {
+			return err
 		}
 
-		os.Exit(exitErr.ExitCode())
+		c.JSON = true
 	}
 
-	fmt.Fprintf(os.Stderr, "ztime: %v\n", err)
+	if c.Attest {
+		if c.signingKey == nil {
+			return errAttestNeedsSign
+		}
 
-	os.Exit(127)
+		c.Git, c.SysInfo = true, true
+		c.attestRepo = gitRepoName(".")
+	}
+
+	if c.CanonicalJSON {
+		c.JSON = true
+	}
+
+	if c.JSONL {
+		c.events = newEventStream(os.Stderr, c.JSONLInterval)
+	}
+
+	if c.Verbose {
+		c.debug = newDebugLog(os.Stderr)
+	}
+
+	if err := checkSpecifierNames(c.Specifier); err != nil {
+		return err
+	}
+
+	c.reportTime = -1
+	if c.ReportThreshold != "" {
+		if c.reportTime, err = parseReportTime(c.ReportThreshold); err != nil {
+			return fmt.Errorf("--report-threshold: %w", err)
+		}
+	}
+
+	switch {
+	case c.NoForwardSignals:
+		c.signals = []os.Signal{}
+	case len(c.ForwardSignals) > 0:
+		if c.signals, err = parseSignals(c.ForwardSignals); err != nil {
+			return err
+		}
+	}
+
+	c.orphansWatched = watchOrphans()
+
+	if c.Git {
+		if c.git, err = readGitInfo("."); err != nil {
+			return err
+		}
+	}
+
+	if c.SysInfo {
+		c.sysinfo = readSystemInfo()
+	}
+
+	if c.Budget != "" {
+		if c.budget, err = loadBudget(c.Budget); err != nil {
+			return err
+		}
+	}
+
+	if c.DiagnoseSignal != "" {
+		if _, ok := signalByName(c.DiagnoseSignal); !ok {
+			return fmt.Errorf("--diagnose-signal: %w: %s", errUnknownSignal, c.DiagnoseSignal)
+		}
+	}
+
+	if tmpl := cmp.Or(c.Template, c.presetSummary()); tmpl != "" {
+		if c.tmpl, err = loadTemplate(tmpl); err != nil {
+			return err
+		}
+	}
+
+	if c.StatsD != "" {
+		if c.statsd, err = dialStatsD(c.StatsD); err != nil {
+			return err
+		}
+	}
+
+	if c.SubtractOverhead {
+		if c.overhead, err = c.calibrate(); err != nil {
+			return err
+		}
+	}
+
+	for _, command := range c.Exporter {
+		e, err := startExporter(command)
+		if err != nil {
+			return err
+		}
+
+		c.exporters = append(c.exporters, e)
+	}
+
+	return nil
+}
+
+// printJSON prints v as indented JSON, wrapped in a signature with --sign.
+func (c *runCmd) printJSON(v any) {
+	data, err := c.encodeJSON(v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ztime: %v\n", err)
+
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, string(data))
+}
+
+// encodeJSON encodes v as indented JSON, signed with --sign or attested
+// with --attest, and in canonical form with --canonical-json.
+func (c *runCmd) encodeJSON(v any) ([]byte, error) {
+	v = versioned(v)
+	data, err := json.MarshalIndent(v, "", "  ")
+
+	switch {
+	case c.Attest:
+		data, err = attestResult(v, c.signingKey, c.attestRepo, c.git, c.sysinfo)
+	case c.signingKey != nil:
+		data, err = signResult(v, c.signingKey)
+	}
+
+	if err == nil && c.CanonicalJSON {
+		data, err = canonicalJSON(data)
+	}
+
+	return data, err
 }
 
-func runCommand(args []string) (Metrics, error) {
+// runOptions fills in the per-run settings that come from flags.
+func (c *runCmd) runOptions(opts runOptions) runOptions {
+	if opts.runID == "" {
+		if c.runID == "" {
+			c.runID = newRunID()
+		}
+
+		opts.runID = c.runID
+	}
+
+	opts.pty = c.PTY
+	for _, key := range slices.Sorted(maps.Keys(c.Env)) {
+		opts.env = append(opts.env, key+"="+c.Env[key])
+	}
+
+	opts.events = c.events
+	opts.debug = c.debug
+	opts.signals = c.signals
+	opts.killChildren = c.KillChildren
+	opts.killGrace = c.KillGrace
+	opts.timeout = c.Timeout
+	opts.limitMem = int64(c.LimitMem)
+	opts.sudoCollectors = c.SudoCollectors
+	opts.workingSetTop = c.WorkingSet
+	opts.fsTraceTop = c.FSTrace
+	opts.perThreadTop = c.PerThread
+	opts.runtimes = c.RuntimeStats
+	opts.stdinPath = c.Stdin
+	if c.StdinNull {
+		opts.stdinPath = os.DevNull
+	}
+
+	opts.phases = c.Phases
+	opts.testReport = c.TestReport
+	opts.buildTraceTop = c.BuildTrace
+	opts.ipc = c.IPC
+	opts.profileCPU = c.ProfileCPU
+
+	if c.GoPprof != "" {
+		opts.goPprof = &goPprofConfig{
+			addr:     c.GoPprof,
+			at:       c.GoPprofAt,
+			profiles: c.GoPprofProfile,
+			cpu:      c.GoPprofCPU,
+			dir:      cmp.Or(c.GoPprofDir, "ztime-pprof-"+opts.runID),
+		}
+	}
+
+	opts.waitStates = c.WaitStates
+	opts.schedStats = c.SchedStats
+	opts.schedLatency = c.SchedLatency
+	opts.ioStats = c.IOStats
+	opts.delayStats = c.DelayStats
+	opts.pressure = c.Pressure
+	opts.netTrace = c.NetTrace
+	opts.excludeStopped = c.ExcludeStopped
+	opts.precisionNS = c.PrecisionNS
+	opts.pss = c.PSS
+	// Count output whenever it goes through pipes anyway. GNU time has no
+	// output specifiers.
+	opts.meterOutput = c.FirstOutput || c.OutputStats || c.MaxOutputRate > 0 ||
+		c.FormatDialect != "gnu" && usesSpecifier(c.TimeFmt, "fLBlb", c.Specifier)
+	opts.diagnoseAfter = c.DiagnoseOnSlow
+	opts.diagnoseSignal = c.DiagnoseSignal
+
+	opts.maxOutputRate = int64(c.MaxOutputRate)
+	opts.outputRateAction = c.OutputRateAction
+
+	return opts
+}
+
+// printNotes prints warnings about the run after its summary. JSON output
+// carries the same information in its fields.
+func (c *runCmd) printNotes(m Metrics) {
+	if c.structured() {
+		return
+	}
+
+	for _, note := range memoryNotes(m) {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := cachedNote(m); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := outputRateNote(m, int64(c.MaxOutputRate)); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := runtimeNote(m.Runtime); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := workingSetNote(m.WorkingSet); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := fsTraceNote(m.FSTrace); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := perThreadNote(m.ThreadCPU); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := orphanNote(m.Orphans); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if m.ChildrenKilled {
+		fmt.Fprintln(os.Stderr, "ztime: terminated processes left in the command's process group")
+	}
+
+	if note := unaccountedNote(m); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := stoppedNote(m); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if m.Overhead > 0 {
+		fmt.Fprintf(os.Stderr, "ztime: left %s of ztime overhead out of the elapsed time\n", humanDuration(m.Overhead))
+	}
+
+	if note := pssNote(m); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := schedNote(m); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := schedLatencyNote(m); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := ioNote(m); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := delayNote(m); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := pressureNote(m); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := netNote(m); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := phasesNote(m); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := testReportNote(m); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := buildTraceNote(m); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := customNote(m); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := profileNote(m); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := goPprofNote(m); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if c.FirstOutput {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", firstOutputNote(m))
+	}
+
+	if c.OutputStats {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", outputStatsNote(m))
+	}
+
+	if note := remoteNote(m); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := containerNote(m.Container); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := jobNote(m.Job); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := throttleNote(m.CgroupThrottle); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if note := diagnosticsNote(c.DiagnoseOnSlow, m); note != "" {
+		fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+	}
+
+	if m.ClockJump != 0 {
+		fmt.Fprintf(os.Stderr, "ztime: the wall clock jumped by %s during the run (system suspend or clock change)\n", m.ClockJump.Round(time.Millisecond))
+	}
+}
+
+// reportable reports whether the summary of m is printed under
+// --report-threshold: structured output always is, and the summary when the
+// CPU time reaches the threshold.
+func (c *runCmd) reportable(m Metrics) bool {
+	return c.structured() || c.reportTime < 0 || m.UserTime+m.SystemTime >= c.reportTime
+}
+
+// structured reports whether the output is meant for programs, which get
+// every detail in fields rather than as notes.
+func (c *runCmd) structured() bool {
+	return c.JSON || c.JSONL
+}
+
+// report prints the metrics of a single run in the selected output format.
+func (c *runCmd) report(m Metrics) {
+	switch {
+	case c.JSONL:
+		// Already streamed as run_finished.
+	case c.JSON:
+		c.printJSON(m)
+	case c.tmpl != nil:
+		printTemplate(c.tmpl, m)
+	default:
+		printSummary(m, c.TimeFmt, formatOptions{human: c.Human, long: c.Long, all: c.All, width: terminalWidth(), custom: c.Specifier, dialect: c.FormatDialect, micro: c.PrecisionNS})
+	}
+}
+
+func runCommand(args []string, opts runOptions) (Metrics, error) {
 	// 1. Setup Command
 	//nolint:gosec // Intended behavior: ztime runs arbitrary commands.
 	cmd := exec.CommandContext(context.Background(), args[0], args[1:]...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	cmd.Env = runEnv(opts)
 
-	// 2. Signal Handling
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, signalList()...)
+	job, err := newJobObject(cmd, opts)
+	if err != nil {
+		return Metrics{Command: strings.Join(args, " ")}, err
+	}
 
-	go func() {
-		for sig := range sigChan {
-			if cmd.Process != nil {
-				_ = cmd.Process.Signal(sig)
-			}
-		}
-	}()
+	instruments, err := attachInstruments(cmd, opts)
+	if err != nil {
+		job.finish(&Metrics{})
+
+		return Metrics{Command: strings.Join(args, " ")}, err
+	}
+
+	// 2. Signal Handling
+	forwarder := forwardSignals(cmd, opts)
 
 	// 3. Execution & Measurement
+	iteration := max(opts.iteration, 1)
+	opts.events.emit(event{Type: eventRunStarted, Iteration: iteration, Command: strings.Join(args, " "), Argv: args})
+
+	var (
+		diagnostics string
+		waits       *waitSampler
+		pss         *pssSampler
+		net         *netSampler
+		latency     *schedLatencySampler
+		pressure    *pressureSampler
+		threads     *threadSampler
+		sched       *SchedStats
+		ioStats     *IOStats
+		delays      *DelayStats
+		stops       *stopWatch
+		profiler    *cpuProfiler
+		goProfiles  *goPprofFetcher
+		peak        processSample
+		switches    *ContextSwitches
+		timedOut    bool
+		end         time.Time
+	)
+
+	raw := startRawClock(opts.precisionNS)
 	start := time.Now()
-	err := cmd.Run()
-	end := time.Now()
 
-	signal.Stop(sigChan)
-	close(sigChan)
+	err = cmd.Start()
+	if err == nil {
+		// A process that did not make it into its job is still suspended.
+		if err = job.start(cmd.Process); err != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+		}
+	}
+
+	if err == nil {
+		opts.debug.printf("started %s as pid %d", cmd.Path, cmd.Process.Pid)
+
+		stopTimeout := watchTimeout(opts.timeout, func(exited <-chan struct{}) { killTimedOut(cmd, job, opts, exited) })
+
+		stopSampling := opts.events.sample(cmd.Process.Pid, start, iteration, opts.debug)
+		diagnosis := diagnoseAfter(opts.diagnoseAfter, cmd.Process.Pid, opts.diagnoseSignal, opts.sudoCollectors)
+		waits = sampleWaitStates(opts.waitStates, cmd.Process.Pid)
+		pss = samplePSS(opts.pss, cmd.Process.Pid)
+		net = sampleNet(opts.netTrace, cmd.Process.Pid)
+		latency = sampleSchedLatency(opts.schedLatency, cmd.Process.Pid)
+		pressure = samplePressure(opts.pressure)
+		threads = sampleThreads(opts.perThreadTop, cmd.Process.Pid)
+		stops = watchStops(opts.excludeStopped, cmd.Process.Pid)
+		profiler = profileCPU(opts.profileCPU, cmd.Process.Pid, opts.sudoCollectors)
+		goProfiles = fetchGoProfiles(opts.goPprof, start, iteration, opts.totalRuns)
+
+		if opts.schedStats {
+			sched = waitSchedStats(cmd.Process.Pid)
+		}
+
+		if opts.ioStats {
+			ioStats = waitIOStats(cmd.Process.Pid)
+		}
+
+		if opts.delayStats {
+			delays = waitDelayStats(cmd.Process.Pid)
+		}
+
+		err = cmd.Wait()
+
+		// Take the end time before stopping the samplers, which can wait
+		// for a diagnostic capture still in progress.
+		end = time.Now()
+		raw.stop()
+
+		timedOut = stopTimeout()
+
+		peak, switches = stopSampling()
+		diagnostics = diagnosis.stop()
+	} else {
+		end = time.Now()
+		raw.stop()
+	}
+
+	if cmd.ProcessState != nil {
+		opts.debug.printf("pid %d %s after %.3fs", cmd.ProcessState.Pid(), cmd.ProcessState, end.Sub(start).Seconds())
+	}
+
+	signalLog := forwarder.stop(start)
 
 	// 4. Metrics Extraction
-	return extractMetrics(cmd, start, end, args), err
-}
+	m := extractMetrics(cmd, start, end, args)
+	raw.apply(&m)
+	stops.stop(&m)
+	m.SignalLog = signalLog
+	m.Diagnostics = diagnostics
+	m.WaitStates = waits.stop(m.Unaccounted)
+	m.SchedStats = sched
+	m.IOStats = ioStats
+	m.DelayStats = delays
+	m.PeakThreads = peak.threads
+	m.PeakFDs = peak.fds
+	m.ContextSwitches = switches
+	pss.stop(&m)
+	net.stop(&m)
+	latency.stop(&m)
+	pressure.stop(&m)
+	threads.stop(&m)
+	profiler.stop(&m)
+	goProfiles.stop(&m)
+	job.finish(&m)
 
-func printSummary(m Metrics) {
-	timeFmt := os.Getenv("TIMEFMT")
-	if timeFmt != "" {
-		fmt.Fprintln(os.Stderr, format(timeFmt, m))
-		return
+	if timedOut {
+		m.TimedOut = true
+		err = fmt.Errorf("%w after %s", errTimedOut, opts.timeout)
 	}
 
-	// Default styled output using lipgloss
-	bold := lipgloss.NewStyle().Bold(true)
-	faint := lipgloss.NewStyle().Faint(true)
-	blue := lipgloss.NewStyle().Foreground(lipgloss.Color("33"))
-	green := lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	for _, inst := range instruments {
+		inst.finish(&m, start)
+	}
 
-	var summary strings.Builder
-	summary.WriteString(fmt.Sprintf("%s  %s user %s system %s cpu %s total\n",
-		faint.Render(m.Command),
-		blue.Render(fmt.Sprintf("%.2fs", m.UserTime.Seconds())),
-		blue.Render(fmt.Sprintf("%.2fs", m.SystemTime.Seconds())),
-		green.Render(fmt.Sprintf("%d%%", m.CPUPercent)),
-		bold.Render(fmt.Sprintf("%.3fs", m.ElapsedTime.Seconds())),
-	))
+	return m, err
+}
 
-	fmt.Fprint(os.Stderr, summary.String())
+// instrument collects extra measurements around a run and stores them in
+// the metrics once the command has exited.
+type instrument interface {
+	finish(m *Metrics, start time.Time)
 }
 
-func extractMetrics(cmd *exec.Cmd, start, end time.Time, args []string) Metrics {
-	elapsed := end.Sub(start)
-	m := Metrics{
-		Command:     strings.Join(args, " "),
-		ElapsedTime: elapsed,
+// attachInstruments wires the optional collectors selected by opts into cmd.
+func attachInstruments(cmd *exec.Cmd, opts runOptions) ([]instrument, error) {
+	var instruments []instrument
+
+	if opts.schedStats {
+		if err := schedStatsSupported(); err != nil {
+			return nil, err
+		}
 	}
 
-	if cmd.ProcessState != nil {
-		m.UserTime = cmd.ProcessState.UserTime()
-		m.SystemTime = cmd.ProcessState.SystemTime()
-		m.CPUPercent = calculateCPUPercent(m.UserTime, m.SystemTime, elapsed)
+	if opts.ioStats {
+		if err := ioStatsSupported(); err != nil {
+			return nil, err
+		}
+	}
 
-		populateUsage(&m, cmd.ProcessState)
+	if opts.delayStats {
+		if err := delayStatsSupported(); err != nil {
+			return nil, err
+		}
 	}
 
-	return m
-}
+	if opts.perThreadTop > 0 {
+		if err := perThreadSupported(); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.pressure {
+		if err := pressureSupported(); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.schedLatency {
+		if err := schedLatencySupported(); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.netTrace {
+		if err := netTraceSupported(); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.pss {
+		if err := pssSupported(); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.excludeStopped {
+		if err := excludeStoppedSupported(); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.profileCPU != "" {
+		if err := profileSupported(); err != nil {
+			return nil, err
+		}
+	}
+
+	// fail releases the instruments attached so far.
+	fail := func(err error) ([]instrument, error) {
+		for _, inst := range instruments {
+			inst.finish(&Metrics{}, time.Time{})
+		}
+
+		return nil, err
+	}
+
+	if opts.pty {
+		pty, err := attachPTY(cmd)
+		if err != nil {
+			return fail(err)
+		}
+
+		instruments = append(instruments, pty)
+	}
+
+	if opts.killChildren {
+		group, err := newProcessGroup(cmd, opts.killGrace, opts.debug)
+		if err != nil {
+			return fail(err)
+		}
+
+		instruments = append(instruments, group)
+	}
+
+	if cgroup := watchCgroupThrottling(); cgroup != nil {
+		instruments = append(instruments, cgroup)
+	}
+
+	if memory := watchMemory(); memory != nil {
+		instruments = append(instruments, memory)
+	}
+
+	var src io.Reader = os.Stdin
+
+	if opts.stdinPath != "" {
+		in, err := openStdin(cmd, opts.stdinPath)
+		if err != nil {
+			return fail(err)
+		}
+
+		src = in.file
+		instruments = append(instruments, in)
+	}
+
+	if opts.meterStdin {
+		stdin, err := meterStdin(cmd, src)
+		if err != nil {
+			return fail(err)
+		}
+
+		instruments = append(instruments, stdin)
+	}
+
+	if opts.workingSetTop > 0 || opts.fsTraceTop > 0 {
+		files, err := watchFiles(cmd, opts.workingSetTop, opts.fsTraceTop, opts.sudoCollectors)
+		if err != nil {
+			return fail(err)
+		}
+
+		instruments = append(instruments, files)
+	}
 
-func format(tmpl string, m Metrics) string {
-	var out bytes.Buffer
+	if opts.phases {
+		phases, err := watchPhases(cmd)
+		if err != nil {
+			return fail(err)
+		}
 
-	inPercent := false
+		instruments = append(instruments, phases)
+	}
 
-	//nolint:intrange // We need C-style loop to modify i for %*E skipping.
-	for i := 0; i < len(tmpl); i++ {
-		char := tmpl[i]
+	if opts.testReport != "" {
+		instruments = append(instruments, testReportWatch{path: opts.testReport})
+	}
 
-		if inPercent {
-			handled := handleSpecifier(&out, char, m, &i, tmpl)
+	if opts.buildTraceTop > 0 {
+		build, err := watchBuild(cmd, opts.buildTraceTop)
+		if err != nil {
+			return fail(err)
+		}
 
-			if !handled {
-				out.WriteByte('%')
-				out.WriteByte(char)
-			}
+		instruments = append(instruments, build)
+	}
 
-			inPercent = false
-		} else {
-			if char == '%' {
-				inPercent = true
-			} else {
-				out.WriteByte(char)
-			}
+	if opts.ipc {
+		ipc, err := listenIPC(cmd)
+		if err != nil {
+			return fail(err)
 		}
+
+		instruments = append(instruments, ipc)
 	}
 
-	if inPercent {
-		out.WriteByte('%')
+	if opts.maxOutputRate > 0 {
+		instruments = append(instruments, guardOutput(cmd, opts.maxOutputRate, opts.outputRateAction))
+	}
+
+	// Outside the guard, so throttled writes count when the child made them.
+	if opts.meterOutput {
+		instruments = append(instruments, meterOutput(cmd))
+	}
+
+	// The bridge wraps whatever stderr the instruments above settled on.
+	if len(opts.runtimes) > 0 {
+		bridge, err := attachRuntimeBridge(cmd, opts.runtimes)
+		if err != nil {
+			return fail(err)
+		}
+
+		instruments = append(instruments, bridge)
 	}
 
-	return out.String()
+	return instruments, nil
 }
 
-func handleSpecifier(out *bytes.Buffer, char byte, m Metrics, idx *int, tmpl string) bool {
-	switch char {
-	case '%':
-		out.WriteByte('%')
-	case 'J':
-		out.WriteString(m.Command)
-	case 'U':
-		fmt.Fprintf(out, "%.2fs", m.UserTime.Seconds())
-	case 'S':
-		fmt.Fprintf(out, "%.2fs", m.SystemTime.Seconds())
-	case 'E':
-		fmt.Fprintf(out, "%.2fs", m.ElapsedTime.Seconds())
-	case '*':
-		handleStar(out, m, idx, tmpl)
-	case 'P':
-		out.WriteString(strconv.Itoa(m.CPUPercent) + "%")
-	default:
-		return handleIntSpecifier(out, char, m)
+// runEnv returns the command's environment: ztime's own plus
+// ZTIME_ITERATION and ZTIME_TOTAL_RUNS, so prepare scripts and workloads can
+// tell runs apart, and ZTIME_RUN_ID, so their logs can be joined with the
+// result.
+func runEnv(opts runOptions) []string {
+	env := append(os.Environ(), opts.env...)
+	env = append(env, "ZTIME_ITERATION="+strconv.Itoa(max(opts.iteration, 1)))
+	if opts.totalRuns > 0 {
+		env = append(env, "ZTIME_TOTAL_RUNS="+strconv.Itoa(opts.totalRuns))
+	}
+
+	if opts.runID != "" {
+		env = append(env, "ZTIME_RUN_ID="+opts.runID)
 	}
 
-	return true
+	return env
 }
 
-func handleIntSpecifier(out *bytes.Buffer, char byte, m Metrics) bool {
-	switch char {
-	case 'M':
-		out.WriteString(strconv.FormatInt(m.MaxRSS, 10))
-	case 'W':
-		out.WriteString(strconv.FormatInt(m.Swaps, 10))
-	case 'X':
-		out.WriteString(strconv.FormatInt(m.SharedRSS, 10))
-	case 'D':
-		out.WriteString(strconv.FormatInt(m.UnsharedData+m.UnsharedStk, 10))
-	case 'K':
-		out.WriteString(strconv.FormatInt(m.SharedRSS+m.UnsharedData+m.UnsharedStk, 10))
-	case 'F':
-		out.WriteString(strconv.FormatInt(m.PageFaults, 10))
-	case 'R':
-		out.WriteString(strconv.FormatInt(m.PageReclaims, 10))
-	case 'I':
-		out.WriteString(strconv.FormatInt(m.BlockInput, 10))
-	case 'O':
-		out.WriteString(strconv.FormatInt(m.BlockOutput, 10))
-	case 'r':
-		out.WriteString(strconv.FormatInt(m.MsgsRecv, 10))
-	case 's':
-		out.WriteString(strconv.FormatInt(m.MsgsSent, 10))
-	case 'k':
-		out.WriteString(strconv.FormatInt(m.Signals, 10))
-	case 'w':
-		out.WriteString(strconv.FormatInt(m.VCtxSwitches, 10))
-	case 'c':
-		out.WriteString(strconv.FormatInt(m.ICtxSwitches, 10))
-	default:
-		return false
+// newRunID returns a random ID for a ztime invocation.
+func newRunID() string {
+	return strings.ToLower(rand.Text())
+}
+
+func printTemplate(tmpl *template.Template, data any) {
+	text, err := renderTemplate(tmpl, data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ztime: %v\n", err)
+
+		return
+	}
+
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
 	}
 
-	return true
+	fmt.Fprint(os.Stderr, text)
 }
 
-func handleStar(out *bytes.Buffer, m Metrics, idx *int, tmpl string) {
-	if *idx+1 < len(tmpl) && tmpl[*idx+1] == 'E' {
-		*idx++
-		d := m.ElapsedTime
-		hours := int(d.Hours())
-		mins := int(d.Minutes()) % 60
-		secs := d.Seconds() - float64(int(d.Minutes())*60)
+func extractMetrics(cmd *exec.Cmd, start, end time.Time, args []string) Metrics {
+	elapsed := end.Sub(start)
+	m := Metrics{
+		Command:     strings.Join(args, " "),
+		ElapsedTime: elapsed,
+		ExitCode:    -1,
+		StartTime:   start,
+		EndTime:     end,
+		ClockJump:   clockJump(start, end),
+		PPID:        os.Getpid(),
+		Argv:        slices.Clone(args),
+	}
 
-		if hours > 0 {
-			fmt.Fprintf(out, "%d:%02d:%05.2f", hours, mins, secs)
-		} else {
-			fmt.Fprintf(out, "%d:%05.2f", mins, secs)
+	if cmd.Err == nil {
+		if path, err := filepath.Abs(cmd.Path); err == nil {
+			m.Executable = path
 		}
-	} else {
-		out.WriteByte('*')
 	}
+
+	if cmd.ProcessState != nil {
+		m.PID = cmd.ProcessState.Pid()
+		m.UserTime = cmd.ProcessState.UserTime()
+		m.SystemTime = cmd.ProcessState.SystemTime()
+		m.CPUPercent = calculateCPUPercent(m.UserTime, m.SystemTime, elapsed)
+
+		populateExitStatus(&m, cmd.ProcessState)
+		populateUsage(&m, cmd.ProcessState)
+
+		m.Unaccounted = max(elapsed-m.UserTime-m.SystemTime, 0)
+	}
+
+	return m
+}
+
+func populateExitStatus(m *Metrics, state *os.ProcessState) {
+	m.ExitCode = state.ExitCode()
+
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		m.TermSignal = signalName(status.Signal())
+		m.Killed = true
+		m.CoreDumped = status.CoreDump()
+	}
+}
+
+// clockJumpThreshold is how far the wall clock may drift from the monotonic
+// clock during a run before the run counts as interrupted. NTP slewing stays
+// well below it.
+const clockJumpThreshold = 500 * time.Millisecond
+
+// clockJump compares the wall-clock and monotonic durations between start
+// and end. The monotonic clock stops while the system is suspended and is
+// unaffected by clock steps, so a large difference means the elapsed time
+// does not reflect the command alone. It returns 0 below the threshold.
+func clockJump(start, end time.Time) time.Duration {
+	jump := end.Round(0).Sub(start.Round(0)) - end.Sub(start)
+	if jump.Abs() < clockJumpThreshold {
+		return 0
+	}
+
+	return jump
 }
 
 func calculateCPUPercent(user, sys, elapsed time.Duration) int {
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
 	"testing"
 	"time"
+
+	"github.com/alecthomas/kong"
 )
 
 func TestFormat(t *testing.T) {
@@ -28,6 +34,7 @@ func TestFormat(t *testing.T) {
 		Signals:      2,
 		VCtxSwitches: 15,
 		ICtxSwitches: 25,
+		PID:          4242,
 	}
 
 	tests := []struct {
@@ -35,6 +42,11 @@ func TestFormat(t *testing.T) {
 		fmt      string
 		expected string
 	}{
+		{
+			name:     "PID",
+			fmt:      "[%p] %J",
+			expected: "[4242] sleep 1",
+		},
 		{
 			name:     "Default",
 			fmt:      "%J  %U user %S system %P cpu %*E total",
@@ -56,7 +68,7 @@ func TestFormat(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			got := format(tt.fmt, metrics)
+			got := format(tt.fmt, metrics, formatOptions{})
 			if got != tt.expected {
 				t.Errorf("format() = %q, want %q", got, tt.expected)
 			}
@@ -110,7 +122,58 @@ func TestFormatMetrics(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			got := format(tt.fmt, metrics)
+			got := format(tt.fmt, metrics, formatOptions{})
+			if got != tt.expected {
+				t.Errorf("format() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormatHumanMemory(t *testing.T) {
+	t.Parallel()
+
+	metrics := Metrics{
+		MaxRSS:       1258291, // 1.2 GiB in KB
+		SharedRSS:    512,
+		UnsharedData: 256,
+		UnsharedStk:  256,
+	}
+
+	tests := []struct {
+		name     string
+		fmt      string
+		human    bool
+		expected string
+	}{
+		{
+			name:     "Raw",
+			fmt:      "%M %X %D %K",
+			expected: "1258291 512 512 1024",
+		},
+		{
+			name:     "Human Flag",
+			fmt:      "%M %X %D %K",
+			human:    true,
+			expected: "1.2 GiB 512.0 KiB 512.0 KiB 1.0 MiB",
+		},
+		{
+			name:     "Human Specifier",
+			fmt:      "%m (%M KB)",
+			expected: "1.2 GiB (1258291 KB)",
+		},
+		{
+			name:     "Human Specifier Width",
+			fmt:      "[%10m]",
+			expected: "[   1.2 GiB]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := format(tt.fmt, metrics, formatOptions{human: tt.human})
 			if got != tt.expected {
 				t.Errorf("format() = %q, want %q", got, tt.expected)
 			}
@@ -124,7 +187,7 @@ func TestFormatElapsedHours(t *testing.T) {
 	m := Metrics{
 		ElapsedTime: 3661 * time.Second, // 1h 1m 1s
 	}
-	got := format("%*E", m)
+	got := format("%*E", m, formatOptions{})
 	// 1h = 3600, 61s left -> 1m 1s.
 	// Expected: 1:01:01.00
 	expected := "1:01:01.00"
@@ -133,6 +196,92 @@ func TestFormatElapsedHours(t *testing.T) {
 	}
 }
 
+func TestFormatModifiers(t *testing.T) {
+	t.Parallel()
+
+	metrics := Metrics{
+		Command:     "make",
+		UserTime:    1234567 * time.Microsecond,
+		SystemTime:  500 * time.Millisecond,
+		ElapsedTime: 2500 * time.Millisecond,
+		CPUPercent:  69,
+		MaxRSS:      1024,
+	}
+
+	tests := []struct {
+		name     string
+		fmt      string
+		expected string
+	}{
+		{
+			name:     "Precision",
+			fmt:      "%.4E",
+			expected: "2.5000s",
+		},
+		{
+			name:     "Zero Precision",
+			fmt:      "%0.0U",
+			expected: "1s",
+		},
+		{
+			name:     "Width And Precision",
+			fmt:      "[%10.4E]",
+			expected: "[   2.5000s]",
+		},
+		{
+			name:     "Left Aligned",
+			fmt:      "[%-8U]",
+			expected: "[1.23s   ]",
+		},
+		{
+			name:     "Width On Integers",
+			fmt:      "[%6M|%-4P]",
+			expected: "[  1024|69% ]",
+		},
+		{
+			name:     "Star Precision",
+			fmt:      "%*.3E",
+			expected: "0:02.500",
+		},
+		{
+			name:     "Star Zero Precision",
+			fmt:      "%*.0E",
+			expected: "0:02",
+		},
+		{
+			name:     "Star After Modifiers",
+			fmt:      "%9.1*E",
+			expected: "   0:02.5",
+		},
+		{
+			name:     "Star User",
+			fmt:      "%*U",
+			expected: "0:01.23",
+		},
+		{
+			name:     "Unknown Keeps Modifiers",
+			fmt:      "%-5.1Z %*M",
+			expected: "%-5.1Z %*M",
+		},
+		{
+			name:     "Trailing Modifiers",
+			fmt:      "x %10",
+			expected: "x %10",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := format(tt.fmt, metrics, formatOptions{})
+			if got != tt.expected {
+				t.Errorf("format() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
 func TestCalculateCPUPercent(t *testing.T) {
 	t.Parallel()
 
@@ -184,3 +333,182 @@ func TestCalculateCPUPercent(t *testing.T) {
 		})
 	}
 }
+
+func TestRunCommandExitStatus(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	m, err := runCommand([]string{"sh", "-c", "exit 3"}, runOptions{})
+	if exitCode(err) != 3 {
+		t.Errorf("exitCode() = %d, want 3", exitCode(err))
+	}
+
+	if m.ExitCode != 3 || m.Killed || m.TermSignal != "" {
+		t.Errorf("exit status = (%d, %t, %q), want (3, false, \"\")", m.ExitCode, m.Killed, m.TermSignal)
+	}
+
+	if !m.EndTime.After(m.StartTime) {
+		t.Errorf("EndTime %v is not after StartTime %v", m.EndTime, m.StartTime)
+	}
+
+	sh, _ := exec.LookPath("sh")
+	if m.PID <= 0 || m.PPID != os.Getpid() || !filepath.IsAbs(m.Executable) || filepath.Base(m.Executable) != filepath.Base(sh) {
+		t.Errorf("process metadata = pid %d, ppid %d, executable %q", m.PID, m.PPID, m.Executable)
+	}
+
+	if !slices.Equal(m.Argv, []string{"sh", "-c", "exit 3"}) {
+		t.Errorf("Argv = %q", m.Argv)
+	}
+}
+
+func TestRunEnv(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		opts     runOptions
+		expected []string
+	}{
+		{
+			name:     "Zero Value",
+			opts:     runOptions{},
+			expected: []string{"ZTIME_ITERATION=1"},
+		},
+		{
+			name:     "Known Total",
+			opts:     runOptions{iteration: 3, totalRuns: 10},
+			expected: []string{"ZTIME_ITERATION=3", "ZTIME_TOTAL_RUNS=10"},
+		},
+		{
+			name:     "Run ID",
+			opts:     runOptions{totalRuns: 1, runID: "abc123"},
+			expected: []string{"ZTIME_ITERATION=1", "ZTIME_TOTAL_RUNS=1", "ZTIME_RUN_ID=abc123"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			env := runEnv(tt.opts)
+			got := env[len(env)-len(tt.expected):]
+
+			for i := range tt.expected {
+				if got[i] != tt.expected[i] {
+					t.Errorf("runEnv() = %q, want suffix %q", got, tt.expected)
+
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestClockJump(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	end := start.Add(2 * time.Second)
+
+	if got := clockJump(start, end); got != 0 {
+		t.Errorf("clockJump() with matching clocks = %v, want 0", got)
+	}
+
+	// Without monotonic readings both durations come from the wall clock.
+	if got := clockJump(start.Round(0), end.Round(0)); got != 0 {
+		t.Errorf("clockJump() without monotonic readings = %v, want 0", got)
+	}
+}
+
+func TestEncodeJSONCanonical(t *testing.T) {
+	t.Parallel()
+
+	v := struct {
+		Zeta  float64           `json:"zeta"`
+		Alpha string            `json:"alpha"`
+		Tags  map[string]string `json:"tags"`
+	}{Zeta: 0.5, Alpha: "<a&b>", Tags: map[string]string{"b": "2", "a": "1"}}
+
+	c := runCmd{CanonicalJSON: true}
+
+	got, err := c.encodeJSON(v)
+	if err != nil {
+		t.Fatalf("encodeJSON() error = %v", err)
+	}
+
+	const want = `{"alpha":"<a&b>","tags":{"a":"1","b":"2"},"zeta":0.5}`
+	if string(got) != want {
+		t.Errorf("encodeJSON() = %s, want %s", got, want)
+	}
+}
+
+func TestReportable(t *testing.T) {
+	t.Parallel()
+
+	m := Metrics{UserTime: 300 * time.Millisecond, SystemTime: 200 * time.Millisecond, ElapsedTime: 10 * time.Second}
+
+	tests := []struct {
+		name string
+		c    runCmd
+		want bool
+	}{
+		{"unset", runCmd{reportTime: -1}, true},
+		{"reached", runCmd{reportTime: 500 * time.Millisecond}, true},
+		{"below", runCmd{reportTime: time.Second}, false},
+		{"json", runCmd{reportTime: time.Second, JSON: true}, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.c.reportable(m); got != tt.want {
+			t.Errorf("%s: reportable() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeCommand(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		args    []string
+		command string   // selected by Kong
+		run     []string // command run's Command, after dropSeparator
+	}{
+		{"subcommand", []string{"version"}, "version", nil},
+		{"escaped subcommand name", []string{"--", "version"}, "run <command>", []string{"version"}},
+		{"escaped with flags", []string{"-q", "--runs", "2", "--", "check", "x"}, "run <command>", []string{"check", "x"}},
+		{"explicit run", []string{"run", "--", "version"}, "run <command>", []string{"version"}},
+		{"explicit run without separator", []string{"run", "version"}, "run <command>", []string{"version"}},
+		{"other subcommand", []string{"check", "--", "make"}, "check <command>", nil},
+		{"default command", []string{"make", "-j4"}, "run <command>", []string{"make", "-j4"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var cli commandLine
+
+			parser, err := kong.New(&cli)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			kctx, err := parser.Parse(escapeCommand(tt.args, subcommands(parser.Model.Node)))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			if kctx.Command() != tt.command {
+				t.Errorf("command = %q, want %q", kctx.Command(), tt.command)
+			}
+
+			if got := dropSeparator(cli.Run.Command); tt.run != nil && !slices.Equal(got, tt.run) {
+				t.Errorf("run command = %q, want %q", got, tt.run)
+			}
+		})
+	}
+}
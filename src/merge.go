@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var errMergeInput = errors.New("not a ztime result")
+
+// mergeCmd combines result files into one result grouped by host.
+type mergeCmd struct {
+	Out string `help:"Write the merged result to FILE instead of stdout." type:"path" placeholder:"FILE"`
+
+	Files []string `arg:"" type:"existingfile" help:"Results to combine: the JSON of a run, benchmark, comparison, suite, --parallel, check, fleet, or earlier merge, signed, attested, or not, or a --jsonl stream."`
+}
+
+// statsCmd summarizes result files per benchmark and host.
+type statsCmd struct {
+	JSON bool `help:"Output the merged result in JSON format."`
+
+	Files []string `arg:"" type:"existingfile" help:"Results to summarize, in the forms ztime merge takes."`
+}
+
+// mergedRun is a run read from a result file, with the benchmark and host
+// it is grouped under.
+type mergedRun struct {
+	host  string
+	bench string
+	run   Metrics
+}
+
+// Run writes the merged result as JSON.
+func (c *mergeCmd) Run() error {
+	result, err := mergeResults(c.Files)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(versioned(result), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	data = append(data, '\n')
+
+	if c.Out == "" {
+		_, err = os.Stdout.Write(data)
+
+		return err
+	}
+
+	//nolint:gosec // Intended behavior: the merged result is for the user to read.
+	if err := os.WriteFile(c.Out, data, 0o644); err != nil {
+		return err
+	}
+
+	runs := 0
+
+	for _, h := range result.Hosts {
+		for _, b := range h.Benchmarks {
+			runs += b.Runs
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "ztime: merged %d runs of %d benchmarks on %d hosts into %s\n", runs, len(result.Suite), len(result.Hosts), c.Out)
+
+	return nil
+}
+
+// Run prints each benchmark's mean elapsed time on every host.
+func (c *statsCmd) Run() error {
+	result, err := mergeResults(c.Files)
+	if err != nil {
+		return err
+	}
+
+	if c.JSON {
+		data, err := json.MarshalIndent(versioned(result), "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(os.Stderr, string(data))
+	} else {
+		printFleet(result)
+	}
+
+	return nil
+}
+
+// mergeResults reads files and groups their runs by benchmark and host,
+// in the order each was first seen, as a fleet result. A run found in more
+// than one file, such as in a result and in an earlier merge of it, is
+// kept once.
+func mergeResults(files []string) (FleetResult, error) {
+	var result FleetResult
+
+	runs := map[string]map[string][]Metrics{} // by host, then benchmark
+	seen := map[string]bool{}
+
+	for _, file := range files {
+		found, err := readResultRuns(file)
+		if err != nil {
+			return FleetResult{}, fmt.Errorf("%s: %w", file, err)
+		}
+
+		for _, r := range found {
+			if key := runKey(r); key != "" {
+				if seen[key] {
+					continue
+				}
+
+				seen[key] = true
+			}
+
+			if runs[r.host] == nil {
+				runs[r.host] = map[string][]Metrics{}
+				result.Hosts = append(result.Hosts, FleetHost{Host: r.host})
+			}
+
+			if !slices.Contains(result.Suite, r.bench) {
+				result.Suite = append(result.Suite, r.bench)
+			}
+
+			runs[r.host][r.bench] = append(runs[r.host][r.bench], r.run)
+		}
+	}
+
+	// Benchmarks stay in suite order; a host without runs of one gets an
+	// empty result in its place.
+	for i, h := range result.Hosts {
+		for _, name := range result.Suite {
+			b := BenchResult{Command: name}
+
+			if found := runs[h.Host][name]; len(found) > 0 {
+				b = newBenchResult(found, elapsedSum(found), false)
+				b.Command, b.StopReason = name, "runs"
+			}
+
+			result.Hosts[i].Benchmarks = append(result.Hosts[i].Benchmarks, b)
+		}
+	}
+
+	return result, nil
+}
+
+// runKey identifies a run across files, or returns "" for a run without a
+// start time, which cannot be told apart from others.
+func runKey(r mergedRun) string {
+	if r.run.StartTime.IsZero() {
+		return ""
+	}
+
+	return fmt.Sprintf("%s\x00%s\x00%d\x00%d\x00%d", r.host, r.bench, r.run.StartTime.UnixNano(), r.run.ElapsedTime, r.run.PID)
+}
+
+// readResultRuns reads the runs of a result file, telling the kinds of
+// result apart by their fields.
+func readResultRuns(path string) ([]mergedRun, error) {
+	//nolint:gosec // Intended behavior: the user names the files to merge.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Runs without a host of their own are grouped under the file's name,
+	// such as that of a CI shard's artifact.
+	host := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) != nil {
+		r, ok := parseRemoteResult(bytes.NewReader(data))
+		if !ok {
+			return nil, fmt.Errorf("%w: want ztime JSON output or a --jsonl stream", errMergeInput)
+		}
+
+		return benchRuns(r, "", host), nil
+	}
+
+	if _, ok := fields["payloadType"]; ok {
+		if data, err = attestedResult(data); err != nil {
+			return nil, fmt.Errorf("%w: %w", errMergeInput, err)
+		}
+
+		fields = nil
+
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, fmt.Errorf("%w: %w", errMergeInput, err)
+		}
+	} else if _, ok := fields["signature"]; ok {
+		data = fields["result"]
+		fields = nil
+
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, fmt.Errorf("%w: %w", errMergeInput, err)
+		}
+	}
+
+	has := func(names ...string) bool {
+		for _, name := range names {
+			if _, ok := fields[name]; !ok {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	var runs []mergedRun
+
+	switch {
+	case has("hosts", "suite"):
+		var r FleetResult
+		err = json.Unmarshal(data, &r)
+
+		for _, h := range r.Hosts {
+			for _, b := range h.Benchmarks {
+				runs = append(runs, benchRuns(b, h.Host, host)...)
+			}
+		}
+	case has("benchmarks"):
+		var r SuiteResult // or a Comparison, which has the same benchmarks
+		err = json.Unmarshal(data, &r)
+
+		for _, b := range r.Benchmarks {
+			runs = append(runs, benchRuns(b, "", host)...)
+		}
+	case has("jobs", "results"):
+		var r ParallelResult
+		err = json.Unmarshal(data, &r)
+
+		for _, m := range r.Results {
+			runs = append(runs, mergedRun{host: runHost(m, r.Annotations, host), bench: m.Command, run: m})
+		}
+	case has("runs", "results"):
+		var r BenchResult
+		err = json.Unmarshal(data, &r)
+		runs = benchRuns(r, "", host)
+	case has("result", "history"):
+		var r CheckResult
+		err = json.Unmarshal(data, &r)
+		runs = []mergedRun{{host: runHost(r.Result, Annotations{}, host), bench: r.Result.Command, run: r.Result}}
+	case has("elapsed_time"):
+		var m Metrics
+		err = json.Unmarshal(data, &m)
+		runs = []mergedRun{{host: runHost(m, Annotations{}, host), bench: m.Command, run: m}}
+	default:
+		return nil, errMergeInput
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errMergeInput, err)
+	}
+
+	return runs, nil
+}
+
+// benchRuns returns the runs of b under its name, on host if it is set or
+// else on the host each run names.
+func benchRuns(b BenchResult, host, fallback string) []mergedRun {
+	runs := make([]mergedRun, 0, len(b.Results))
+
+	for _, m := range b.Results {
+		h := host
+		if h == "" {
+			h = runHost(m, b.Annotations, fallback)
+		}
+
+		runs = append(runs, mergedRun{host: h, bench: b.Command, run: m})
+	}
+
+	return runs
+}
+
+// runHost names the machine m ran on: its "host" tag, its --ssh host, or
+// its --sysinfo hostname, from the run or else the result it belongs to,
+// or fallback.
+func runHost(m Metrics, outer Annotations, fallback string) string {
+	for _, a := range []Annotations{m.Annotations, outer} {
+		if host := a.Tags["host"]; host != "" {
+			return host
+		}
+	}
+
+	if m.Remote != nil && m.Remote.Host != "" {
+		return m.Remote.Host
+	}
+
+	for _, a := range []Annotations{m.Annotations, outer} {
+		if a.SysInfo != nil && a.SysInfo.Hostname != "" {
+			return a.SysInfo.Hostname
+		}
+	}
+
+	return fallback
+}
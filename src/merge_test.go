@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func writeResult(t *testing.T, dir, name string, v any) string {
+	t.Helper()
+
+	data, err := json.Marshal(versioned(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func mergeRun(command string, elapsed time.Duration, start int) Metrics {
+	return Metrics{Command: command, ElapsedTime: elapsed, StartTime: time.Unix(int64(start), 0), PID: start}
+}
+
+func TestMergeResults(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	alpha := Annotations{Tags: map[string]string{"host": "alpha"}}
+
+	bench := BenchResult{Command: "make", Runs: 2, Results: []Metrics{mergeRun("make", time.Second, 1), mergeRun("make", 3*time.Second, 2)}, Annotations: alpha}
+	files := []string{
+		writeResult(t, dir, "a.json", bench),
+		writeResult(t, dir, "shard-2.json", mergeRun("make", 2*time.Second, 3)),
+		writeResult(t, dir, "c.json", Comparison{Benchmarks: []BenchResult{
+			{Command: "ninja", Runs: 1, Results: []Metrics{mergeRun("ninja", time.Second, 4)}, Annotations: alpha},
+		}}),
+		// The same benchmark again, as an earlier merge would hold it.
+		writeResult(t, dir, "old.json", FleetResult{Suite: []string{"make"}, Hosts: []FleetHost{{Host: "alpha", Benchmarks: []BenchResult{bench}}}}),
+	}
+
+	result, err := mergeResults(files)
+	if err != nil {
+		t.Fatalf("mergeResults() error = %v", err)
+	}
+
+	if !slices.Equal(result.Suite, []string{"make", "ninja"}) {
+		t.Errorf("suite = %v, want [make ninja]", result.Suite)
+	}
+
+	if len(result.Hosts) != 2 || result.Hosts[0].Host != "alpha" || result.Hosts[1].Host != "shard-2" {
+		t.Fatalf("hosts = %+v, want alpha and shard-2", result.Hosts)
+	}
+
+	alphaMake, alphaNinja := result.Hosts[0].Benchmarks[0], result.Hosts[0].Benchmarks[1]
+	if alphaMake.Runs != 2 || alphaMake.Elapsed.Mean != 2*time.Second || alphaNinja.Runs != 1 || alphaNinja.Command != "ninja" {
+		t.Errorf("alpha: make %d runs, mean %v; ninja %d runs", alphaMake.Runs, alphaMake.Elapsed.Mean, alphaNinja.Runs)
+	}
+
+	// A host without runs of a benchmark has an empty result in its place.
+	if shard := result.Hosts[1].Benchmarks; len(shard) != 2 || shard[0].Runs != 1 || shard[1].Runs != 0 || shard[1].Command != "ninja" {
+		t.Errorf("shard-2 benchmarks = %+v", shard)
+	}
+}
+
+func TestReadResultRuns(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	run := mergeRun("true", time.Millisecond, 1)
+
+	signed := SignedResult{Result: json.RawMessage(`{"command":"true","elapsed_time":1000000,"argv":["true"]}`)}
+	stream := filepath.Join(dir, "stream.jsonl")
+
+	if err := os.WriteFile(stream, []byte(`{"type":"run_started"}`+"\n"+`{"type":"run_finished","result":{"command":"true","elapsed_time":5}}`+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		runs    int
+		wantErr error
+	}{
+		{"run", writeResult(t, dir, "run.json", run), 1, nil},
+		{"check", writeResult(t, dir, "check.json", CheckResult{Result: run, History: 3}), 1, nil},
+		{"parallel", writeResult(t, dir, "parallel.json", ParallelResult{Jobs: 2, Results: []Metrics{run, run}}), 2, nil},
+		{"suite", writeResult(t, dir, "suite.json", SuiteResult{Benchmarks: []BenchResult{{Results: []Metrics{run}}}}), 1, nil},
+		{"signed", writeResult(t, dir, "signed.json", signed), 1, nil},
+		{"jsonl", stream, 1, nil},
+		{"unknown", writeResult(t, dir, "other.json", map[string]int{"x": 1}), 0, errMergeInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			runs, err := readResultRuns(tt.path)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("readResultRuns() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if len(runs) != tt.runs {
+				t.Errorf("readResultRuns() returned %d runs, want %d", len(runs), tt.runs)
+			}
+		})
+	}
+}
+
+func TestRunHost(t *testing.T) {
+	t.Parallel()
+
+	tagged := Annotations{Tags: map[string]string{"host": "tagged"}}
+	sysinfo := Annotations{SysInfo: &SystemInfo{Hostname: "box"}}
+
+	tests := []struct {
+		name     string
+		m        Metrics
+		outer    Annotations
+		expected string
+	}{
+		{"run tag", Metrics{Annotations: tagged, Remote: &RemoteRun{Host: "ssh"}}, sysinfo, "tagged"},
+		{"result tag", Metrics{Remote: &RemoteRun{Host: "ssh"}}, tagged, "tagged"},
+		{"ssh host", Metrics{Annotations: sysinfo, Remote: &RemoteRun{Host: "ssh"}}, Annotations{}, "ssh"},
+		{"sysinfo", Metrics{}, sysinfo, "box"},
+		{"file name", Metrics{}, Annotations{}, "file"},
+	}
+
+	for _, tt := range tests {
+		if got := runHost(tt.m, tt.outer, "file"); got != tt.expected {
+			t.Errorf("%s: runHost() = %q, want %q", tt.name, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// netTraceInterval is how often --net-trace looks at the command's
+	// sockets.
+	netTraceInterval = 20 * time.Millisecond
+	// netTraceHosts is how many remote hosts the summary lists.
+	netTraceHosts = 5
+	// dnsPort is where sockets carrying DNS lookups are connected.
+	dnsPort = 53
+)
+
+var errNetTraceUnsupported = errors.New("--net-trace is not supported")
+
+// NetTrace is the network activity of the command and its children seen
+// with --net-trace. The sockets are sampled every SampleInterval, so
+// connections that open and close between two samples are missed and the
+// bytes of each are those counted at its last sample: the counts are lower
+// bounds.
+type NetTrace struct {
+	Samples        int           `json:"samples" unit:"count"`
+	SampleInterval time.Duration `json:"sample_interval"`
+	Connections    int           `json:"connections" unit:"count" bound:"lower"` // TCP connections seen
+	BytesSent      int64         `json:"bytes_sent" unit:"bytes" bound:"lower"`  // acknowledged by the remote end
+	BytesReceived  int64         `json:"bytes_received" unit:"bytes" bound:"lower"`
+	DNSLookups     int           `json:"dns_lookups" unit:"count" bound:"lower"` // UDP sockets to port 53 seen
+	DNSTime        time.Duration `json:"dns_time"`                               // estimated from how long they were seen open
+	Hosts          []RemoteHost  `json:"hosts,omitempty"`                        // most bytes first
+}
+
+// RemoteHost is the TCP traffic with one remote address. Its counts are
+// lower bounds, as those of its NetTrace are.
+type RemoteHost struct {
+	Address       string        `json:"address"`
+	Connections   int           `json:"connections" unit:"count" bound:"lower"`
+	BytesSent     int64         `json:"bytes_sent" unit:"bytes" bound:"lower"`
+	BytesReceived int64         `json:"bytes_received" unit:"bytes" bound:"lower"`
+	MinRTT        time.Duration `json:"min_rtt"` // lowest round-trip time the kernel measured
+}
+
+// socketSample is one socket of the command as seen in one sample.
+type socketSample struct {
+	cookie   uint64 // identifies the socket for its lifetime
+	udp      bool
+	remote   netip.AddrPort
+	sent     int64
+	received int64
+	rtt      time.Duration
+}
+
+// netSampler looks at the sockets of a process tree until stopped.
+type netSampler struct {
+	done    chan struct{}
+	stopped chan struct{}
+	samples int
+	tcp     map[uint64]socketSample // latest sample of each connection
+	dns     map[uint64]int          // samples each DNS socket was seen in
+}
+
+// sampleNet starts watching the sockets of pid and its descendants. It
+// returns nil if enabled is false.
+func sampleNet(enabled bool, pid int) *netSampler {
+	if !enabled {
+		return nil
+	}
+
+	s := &netSampler{done: make(chan struct{}), stopped: make(chan struct{}), tcp: map[uint64]socketSample{}, dns: map[uint64]int{}}
+
+	go func() {
+		defer close(s.stopped)
+
+		ticker := time.NewTicker(netTraceInterval)
+		defer ticker.Stop()
+
+		for {
+			if sockets, ok := sampleSockets(pid); ok {
+				s.add(sockets)
+			}
+
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return s
+}
+
+func (s *netSampler) add(sockets []socketSample) {
+	s.samples++
+
+	for _, sock := range sockets {
+		switch {
+		case sock.udp && sock.remote.Port() == dnsPort:
+			s.dns[sock.cookie]++
+		case !sock.udp:
+			if prev, ok := s.tcp[sock.cookie]; ok && prev.rtt > 0 && (sock.rtt == 0 || prev.rtt < sock.rtt) {
+				sock.rtt = prev.rtt
+			}
+
+			s.tcp[sock.cookie] = sock
+		}
+	}
+}
+
+// stop ends the sampling and stores what was seen in m.
+func (s *netSampler) stop(m *Metrics) {
+	if s == nil {
+		return
+	}
+
+	close(s.done)
+	<-s.stopped
+
+	m.NetTrace = s.trace()
+}
+
+func (s *netSampler) trace() *NetTrace {
+	t := &NetTrace{Samples: s.samples, SampleInterval: netTraceInterval, Connections: len(s.tcp), DNSLookups: len(s.dns)}
+
+	for _, seen := range s.dns {
+		t.DNSTime += time.Duration(seen) * netTraceInterval
+	}
+
+	hosts := map[netip.Addr]*RemoteHost{}
+
+	for _, sock := range s.tcp {
+		addr := sock.remote.Addr().Unmap()
+
+		h, ok := hosts[addr]
+		if !ok {
+			h = &RemoteHost{Address: addr.String()}
+			hosts[addr] = h
+		}
+
+		h.Connections++
+		h.BytesSent += sock.sent
+		h.BytesReceived += sock.received
+
+		if sock.rtt > 0 && (h.MinRTT == 0 || sock.rtt < h.MinRTT) {
+			h.MinRTT = sock.rtt
+		}
+
+		t.BytesSent += sock.sent
+		t.BytesReceived += sock.received
+	}
+
+	for _, h := range hosts {
+		t.Hosts = append(t.Hosts, *h)
+	}
+
+	slices.SortFunc(t.Hosts, func(a, b RemoteHost) int {
+		return cmp.Or(cmp.Compare(b.BytesSent+b.BytesReceived, a.BytesSent+a.BytesReceived), strings.Compare(a.Address, b.Address))
+	})
+
+	return t
+}
+
+// netNote describes the network activity over several lines, or returns ""
+// without --net-trace. It says that the counts are sampled lower bounds.
+func netNote(m Metrics) string {
+	t := m.NetTrace
+	if t == nil {
+		return ""
+	}
+
+	var note strings.Builder
+
+	fmt.Fprintf(&note, "net: at least %d TCP connections to %d hosts, sent %s, received %s; %d DNS lookups",
+		t.Connections, len(t.Hosts), humanBytes(t.BytesSent), humanBytes(t.BytesReceived), t.DNSLookups)
+
+	if t.DNSLookups > 0 {
+		fmt.Fprintf(&note, " (about %s)", humanDuration(t.DNSTime))
+	}
+
+	fmt.Fprintf(&note, "; sampled every %s, so counts are lower bounds", humanDuration(t.SampleInterval))
+
+	for _, h := range t.Hosts[:min(netTraceHosts, len(t.Hosts))] {
+		fmt.Fprintf(&note, "\n  %10s sent  %10s received  %3d conns  rtt %-8s  %s",
+			humanBytes(h.BytesSent), humanBytes(h.BytesReceived), h.Connections, humanDuration(h.MinRTT), h.Address)
+	}
+
+	return note.String()
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// Sizes of struct nlmsghdr, inet_diag_req_v2, and inet_diag_msg.
+	nlmsgHeaderLen   = 16
+	inetDiagReqLen   = 56
+	inetDiagMsgLen   = 72
+	inetDiagInfo     = 2  // INET_DIAG_INFO, the attribute holding struct tcp_info
+	tcpListen        = 10 // TCP_LISTEN
+	sockDiagBufferSz = 64 * 1024
+)
+
+func netTraceSupported() error {
+	return nil
+}
+
+// sampleSockets lists the TCP and UDP sockets that pid and its descendants
+// have open. Sockets are found through the processes' descriptors and
+// described by the kernel's socket diagnostics.
+func sampleSockets(pid int) ([]socketSample, bool) {
+	tree, ok := processTree(pid)
+	if !ok {
+		return nil, false
+	}
+
+	inodes := map[uint32]bool{}
+
+	for _, p := range tree {
+		dir := "/proc/" + strconv.Itoa(p) + "/fd/"
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			target, err := os.Readlink(dir + entry.Name())
+			if err != nil {
+				continue
+			}
+
+			if inode, ok := socketInode(target); ok {
+				inodes[inode] = true
+			}
+		}
+	}
+
+	if len(inodes) == 0 {
+		return nil, true
+	}
+
+	var sockets []socketSample
+
+	for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
+		for _, protocol := range []uint8{unix.IPPROTO_TCP, unix.IPPROTO_UDP} {
+			found, err := sockDiag(family, protocol, inodes)
+			if err != nil {
+				return nil, false
+			}
+
+			sockets = append(sockets, found...)
+		}
+	}
+
+	return sockets, true
+}
+
+// socketInode reads the inode from a "socket:[INODE]" descriptor link.
+func socketInode(target string) (uint32, bool) {
+	s, ok := strings.CutPrefix(target, "socket:[")
+	if !ok {
+		return 0, false
+	}
+
+	inode, err := strconv.ParseUint(strings.TrimSuffix(s, "]"), 10, 32)
+
+	return uint32(inode), err == nil
+}
+
+// sockDiag dumps the sockets of a family and protocol with NETLINK_SOCK_DIAG
+// and keeps the connected ones whose inode is in inodes.
+func sockDiag(family, protocol uint8, inodes map[uint32]bool) ([]socketSample, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, unix.NETLINK_SOCK_DIAG)
+	if err != nil {
+		return nil, fmt.Errorf("opening sock_diag: %w", err)
+	}
+	defer unix.Close(fd)
+
+	req := make([]byte, nlmsgHeaderLen+inetDiagReqLen)
+	binary.NativeEndian.PutUint32(req[0:], uint32(len(req)))
+	binary.NativeEndian.PutUint16(req[4:], unix.SOCK_DIAG_BY_FAMILY)
+	binary.NativeEndian.PutUint16(req[6:], unix.NLM_F_REQUEST|unix.NLM_F_DUMP)
+	req[nlmsgHeaderLen] = family
+	req[nlmsgHeaderLen+1] = protocol
+	req[nlmsgHeaderLen+2] = 1 << (inetDiagInfo - 1)
+	binary.NativeEndian.PutUint32(req[nlmsgHeaderLen+4:], ^uint32(0)) // every state
+
+	if err := unix.Sendto(fd, req, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("querying sock_diag: %w", err)
+	}
+
+	var sockets []socketSample
+
+	buf := make([]byte, sockDiagBufferSz)
+
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return nil, fmt.Errorf("reading sock_diag: %w", err)
+		}
+
+		done, err := parseSockDiag(buf[:n], protocol, inodes, &sockets)
+		if err != nil || done {
+			return sockets, err
+		}
+	}
+}
+
+// parseSockDiag parses one batch of sock_diag replies into sockets. It
+// reports whether the dump is complete.
+func parseSockDiag(data []byte, protocol uint8, inodes map[uint32]bool, sockets *[]socketSample) (bool, error) {
+	for len(data) >= nlmsgHeaderLen {
+		size := int(binary.NativeEndian.Uint32(data[0:]))
+		kind := binary.NativeEndian.Uint16(data[4:])
+
+		if size < nlmsgHeaderLen || size > len(data) {
+			return false, fmt.Errorf("reading sock_diag: %w", unix.EBADMSG)
+		}
+
+		body := data[nlmsgHeaderLen:size]
+		data = data[min(nlmAlign(size), len(data)):]
+
+		switch kind {
+		case unix.NLMSG_DONE:
+			return true, nil
+		case unix.NLMSG_ERROR:
+			if len(body) >= 4 {
+				if errno := int32(binary.NativeEndian.Uint32(body)); errno != 0 { //nolint:gosec // Reinterprets the C int.
+					return false, fmt.Errorf("reading sock_diag: %w", unix.Errno(-errno))
+				}
+			}
+
+			return true, nil
+		}
+
+		if sock, ok := parseInetDiagMsg(body, protocol, inodes); ok {
+			*sockets = append(*sockets, sock)
+		}
+	}
+
+	return false, nil
+}
+
+func nlmAlign(n int) int {
+	return (n + unix.NLMSG_ALIGNTO - 1) &^ (unix.NLMSG_ALIGNTO - 1)
+}
+
+// parseInetDiagMsg reads a struct inet_diag_msg and, for TCP, the tcp_info
+// attribute after it.
+func parseInetDiagMsg(msg []byte, protocol uint8, inodes map[uint32]bool) (socketSample, bool) {
+	if len(msg) < inetDiagMsgLen {
+		return socketSample{}, false
+	}
+
+	family, state := msg[0], msg[1]
+	inode := binary.NativeEndian.Uint32(msg[68:])
+	port := binary.BigEndian.Uint16(msg[6:])
+
+	if !inodes[inode] || port == 0 || protocol == unix.IPPROTO_TCP && state == tcpListen {
+		return socketSample{}, false
+	}
+
+	var addr netip.Addr
+	if family == unix.AF_INET {
+		addr = netip.AddrFrom4([4]byte(msg[24:28]))
+	} else {
+		addr = netip.AddrFrom16([16]byte(msg[24:40]))
+	}
+
+	sock := socketSample{
+		cookie: binary.NativeEndian.Uint64(msg[44:]),
+		udp:    protocol == unix.IPPROTO_UDP,
+		remote: netip.AddrPortFrom(addr, port),
+	}
+
+	for attrs := msg[inetDiagMsgLen:]; len(attrs) >= unix.SizeofRtAttr; {
+		size := int(binary.NativeEndian.Uint16(attrs[0:]))
+		kind := binary.NativeEndian.Uint16(attrs[2:])
+
+		if size < unix.SizeofRtAttr || size > len(attrs) {
+			break
+		}
+
+		if kind == inetDiagInfo {
+			sock.sent, sock.received, sock.rtt = parseTCPInfo(attrs[unix.SizeofRtAttr:size])
+		}
+
+		attrs = attrs[min((size+unix.RTA_ALIGNTO-1)&^(unix.RTA_ALIGNTO-1), len(attrs)):]
+	}
+
+	return sock, true
+}
+
+// parseTCPInfo returns the bytes acknowledged and received and the
+// smoothed round-trip time from a struct tcp_info, which older kernels
+// send shorter.
+func parseTCPInfo(data []byte) (int64, int64, time.Duration) {
+	var info unix.TCPInfo
+
+	full := make([]byte, unsafe.Sizeof(info))
+	copy(full, data)
+
+	acked := binary.NativeEndian.Uint64(full[unsafe.Offsetof(info.Bytes_acked):])
+	received := binary.NativeEndian.Uint64(full[unsafe.Offsetof(info.Bytes_received):])
+	rtt := binary.NativeEndian.Uint32(full[unsafe.Offsetof(info.Rtt):])
+
+	//nolint:gosec // Byte counts fit in int64.
+	return int64(acked), int64(received), time.Duration(rtt) * time.Microsecond
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestSocketInode(t *testing.T) {
+	t.Parallel()
+
+	if inode, ok := socketInode("socket:[123456]"); !ok || inode != 123456 {
+		t.Errorf("socketInode() = %d, %v; want 123456", inode, ok)
+	}
+
+	for _, target := range []string{"pipe:[1]", "/dev/null", "socket:[x]"} {
+		if _, ok := socketInode(target); ok {
+			t.Errorf("socketInode(%q) found an inode", target)
+		}
+	}
+}
+
+func TestParseInetDiagMsg(t *testing.T) {
+	t.Parallel()
+
+	msg := make([]byte, inetDiagMsgLen)
+	msg[0], msg[1] = unix.AF_INET, 1 // TCP_ESTABLISHED
+	binary.BigEndian.PutUint16(msg[6:], 443)
+	copy(msg[24:], []byte{203, 0, 113, 7})
+	binary.NativeEndian.PutUint64(msg[44:], 99)
+	binary.NativeEndian.PutUint32(msg[68:], 4242)
+
+	sock, ok := parseInetDiagMsg(msg, unix.IPPROTO_TCP, map[uint32]bool{4242: true})
+	if !ok || sock.cookie != 99 || sock.udp || sock.remote.String() != "203.0.113.7:443" {
+		t.Errorf("parseInetDiagMsg() = %+v, %v", sock, ok)
+	}
+
+	if _, ok := parseInetDiagMsg(msg, unix.IPPROTO_TCP, map[uint32]bool{1: true}); ok {
+		t.Error("parseInetDiagMsg() kept a socket of another process")
+	}
+
+	msg[1] = tcpListen
+	if _, ok := parseInetDiagMsg(msg, unix.IPPROTO_TCP, map[uint32]bool{4242: true}); ok {
+		t.Error("parseInetDiagMsg() kept a listening socket")
+	}
+}
+
+func TestSampleSockets(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer listener.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sockets, ok := sampleSockets(os.Getpid())
+	if !ok {
+		t.Skip("sock_diag not available")
+	}
+
+	for _, sock := range sockets {
+		if !sock.udp && sock.remote.String() == listener.Addr().String() {
+			return
+		}
+	}
+
+	t.Errorf("sampleSockets() = %+v, want the connection to %s", sockets, listener.Addr())
+}
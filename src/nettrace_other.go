@@ -0,0 +1,16 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func netTraceSupported() error {
+	return fmt.Errorf("%w on %s", errNetTraceUnsupported, runtime.GOOS)
+}
+
+func sampleSockets(int) ([]socketSample, bool) {
+	return nil, false
+}
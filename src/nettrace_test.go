@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestNetSamplerTrace(t *testing.T) {
+	t.Parallel()
+
+	s := &netSampler{tcp: map[uint64]socketSample{}, dns: map[uint64]int{}}
+
+	web := netip.MustParseAddrPort("203.0.113.7:443")
+	db := netip.MustParseAddrPort("[::ffff:198.51.100.2]:5432")
+	dns := netip.MustParseAddrPort("127.0.0.53:53")
+
+	s.add([]socketSample{
+		{cookie: 1, remote: web, sent: 100, received: 1000, rtt: 20 * time.Millisecond},
+		{cookie: 2, remote: db, sent: 10, received: 10, rtt: time.Millisecond},
+		{cookie: 3, udp: true, remote: dns},
+		{cookie: 4, udp: true, remote: netip.MustParseAddrPort("10.0.0.1:9000")},
+	})
+	s.add([]socketSample{
+		{cookie: 1, remote: web, sent: 200, received: 5000, rtt: 30 * time.Millisecond},
+		{cookie: 5, remote: web, sent: 50, received: 50, rtt: 15 * time.Millisecond},
+		{cookie: 3, udp: true, remote: dns},
+	})
+
+	got := s.trace()
+
+	if got.Samples != 2 || got.SampleInterval != netTraceInterval || got.Connections != 3 || got.BytesSent != 260 || got.BytesReceived != 5060 {
+		t.Errorf("trace() = %+v", got)
+	}
+
+	if got.DNSLookups != 1 || got.DNSTime != 2*netTraceInterval {
+		t.Errorf("DNS = %d lookups in %v, want 1 in %v", got.DNSLookups, got.DNSTime, 2*netTraceInterval)
+	}
+
+	want := []RemoteHost{
+		{Address: "203.0.113.7", Connections: 2, BytesSent: 250, BytesReceived: 5050, MinRTT: 15 * time.Millisecond},
+		{Address: "198.51.100.2", Connections: 1, BytesSent: 10, BytesReceived: 10, MinRTT: time.Millisecond},
+	}
+
+	if len(got.Hosts) != len(want) || got.Hosts[0] != want[0] || got.Hosts[1] != want[1] {
+		t.Errorf("Hosts = %+v, want %+v", got.Hosts, want)
+	}
+}
+
+func TestNetNote(t *testing.T) {
+	t.Parallel()
+
+	if got := netNote(Metrics{}); got != "" {
+		t.Errorf("netNote() = %q, want \"\"", got)
+	}
+
+	m := Metrics{NetTrace: &NetTrace{
+		SampleInterval: netTraceInterval, Connections: 2, BytesSent: 250, BytesReceived: 5120, DNSLookups: 1, DNSTime: 40 * time.Millisecond,
+		Hosts: []RemoteHost{{Address: "203.0.113.7", Connections: 2, BytesSent: 250, BytesReceived: 5120, MinRTT: 15 * time.Millisecond}},
+	}}
+
+	const want = "net: at least 2 TCP connections to 1 hosts, sent 250 B, received 5.0 KiB; 1 DNS lookups (about 40.00ms); sampled every 20.00ms, so counts are lower bounds\n" +
+		"       250 B sent     5.0 KiB received    2 conns  rtt 15.00ms   203.0.113.7"
+	if got := netNote(m); got != want {
+		t.Errorf("netNote() = %q, want %q", got, want)
+	}
+}
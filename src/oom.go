@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// heavySwap is how much swapping during a run is worth a warning.
+const heavySwap = 16 << 20
+
+// memoryCounters are the kernel counters compared before and after a run
+// to tell whether memory ran out.
+type memoryCounters struct {
+	oomKills int64 // in ztime's cgroup
+	swapIn   int64 // pages, system-wide
+	swapOut  int64
+}
+
+// parseCounters returns the values of the given keys in a file of
+// "key value" lines such as memory.events, memory.oom_control, or
+// /proc/vmstat. Missing keys are left out.
+func parseCounters(data []byte, keys ...string) map[string]int64 {
+	counters := make(map[string]int64, len(keys))
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+
+		for _, key := range keys {
+			if fields[0] == key {
+				if n, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+					counters[key] = n
+				}
+			}
+		}
+	}
+
+	return counters
+}
+
+// memoryNotes states plainly whether the run was killed for lack of memory
+// or swapped heavily, since either explains a slow or failed run.
+func memoryNotes(m Metrics) []string {
+	var notes []string
+
+	switch {
+	case m.OOMKilled:
+		notes = append(notes, "the command was killed by the kernel's out-of-memory killer")
+	case m.OOMKills > 0:
+		notes = append(notes, fmt.Sprintf("warning: the out-of-memory killer killed %d processes in ztime's cgroup during the run", m.OOMKills))
+	}
+
+	if m.SwapIn+m.SwapOut >= heavySwap {
+		notes = append(notes, fmt.Sprintf("warning: the system swapped heavily during the run (%s in, %s out)", humanBytes(m.SwapIn), humanBytes(m.SwapOut)))
+	}
+
+	return notes
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// memoryWatch compares the OOM kills of ztime's cgroup and the system's
+// swap activity before and after a run.
+type memoryWatch struct {
+	eventsPath string // "" if the cgroup's memory events cannot be read
+	before     memoryCounters
+	swapOK     bool
+}
+
+// watchMemory records the counters before the run.
+func watchMemory() *memoryWatch {
+	w := &memoryWatch{}
+
+	if data, err := os.ReadFile("/proc/self/cgroup"); err == nil {
+		for _, path := range cgroupPaths(data, "memory", "memory.oom_control", "memory.events") {
+			if oomKills, ok := readOOMKills(path); ok {
+				w.eventsPath = path
+				w.before.oomKills = oomKills
+
+				break
+			}
+		}
+	}
+
+	w.before.swapIn, w.before.swapOut, w.swapOK = readSwap()
+
+	return w
+}
+
+func (w *memoryWatch) finish(m *Metrics, _ time.Time) {
+	if w.eventsPath != "" {
+		if oomKills, ok := readOOMKills(w.eventsPath); ok {
+			m.OOMKills = oomKills - w.before.oomKills
+		}
+	}
+
+	// A process the OOM killer picks gets a SIGKILL.
+	m.OOMKilled = m.OOMKills > 0 && m.TermSignal == signalName(syscall.SIGKILL)
+
+	if swapIn, swapOut, ok := readSwap(); ok && w.swapOK {
+		pageSize := int64(os.Getpagesize())
+		m.SwapIn = max(swapIn-w.before.swapIn, 0) * pageSize
+		m.SwapOut = max(swapOut-w.before.swapOut, 0) * pageSize
+	}
+}
+
+func readOOMKills(path string) (int64, bool) {
+	//nolint:gosec // Intended behavior: the path comes from /proc/self/cgroup.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+
+	n, ok := parseCounters(data, "oom_kill")["oom_kill"]
+
+	return n, ok
+}
+
+// readSwap returns the pages swapped in and out since boot.
+func readSwap() (int64, int64, bool) {
+	data, err := os.ReadFile("/proc/vmstat")
+	if err != nil {
+		return 0, 0, false
+	}
+
+	counters := parseCounters(data, "pswpin", "pswpout")
+
+	return counters["pswpin"], counters["pswpout"], true
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package main
+
+import "time"
+
+// memoryWatch does nothing outside Linux, where the kernel does not expose
+// OOM kills or swap activity in a form ztime reads.
+type memoryWatch struct{}
+
+func watchMemory() *memoryWatch {
+	return nil
+}
+
+func (*memoryWatch) finish(*Metrics, time.Time) {}
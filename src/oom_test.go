@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCounters(t *testing.T) {
+	t.Parallel()
+
+	events := []byte("low 0\nhigh 12\nmax 3\noom 2\noom_kill 1\noom_group_kill 0\n")
+	if got, want := parseCounters(events, "oom_kill", "missing"), map[string]int64{"oom_kill": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCounters(memory.events) = %v, want %v", got, want)
+	}
+
+	vmstat := []byte("nr_free_pages 12345\npswpin 7\npswpout 19\n")
+	if got, want := parseCounters(vmstat, "pswpin", "pswpout"), map[string]int64{"pswpin": 7, "pswpout": 19}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCounters(vmstat) = %v, want %v", got, want)
+	}
+}
+
+func TestMemoryNotes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		m    Metrics
+		want []string
+	}{
+		{"healthy", Metrics{SwapIn: 4096}, nil},
+		{"killed", Metrics{OOMKilled: true, OOMKills: 1}, []string{"the command was killed by the kernel's out-of-memory killer"}},
+		{
+			"sibling killed and swapping",
+			Metrics{OOMKills: 2, SwapIn: 8 << 20, SwapOut: 24 << 20},
+			[]string{
+				"warning: the out-of-memory killer killed 2 processes in ztime's cgroup during the run",
+				"warning: the system swapped heavily during the run (8.0 MiB in, 24.0 MiB out)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := memoryNotes(tt.m); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("memoryNotes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
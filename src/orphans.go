@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// helpers holds the processes ztime starts for itself while commands run,
+// such as profilers, diagnostic tools, and sudo collectors. They are
+// ztime's children like the orphans it adopts, so collectOrphans leaves
+// them out.
+var helpers struct {
+	sync.Mutex
+
+	pids map[int]bool
+}
+
+// startHelper starts cmd as one of ztime's helpers. It must be waited for
+// with waitHelper rather than cmd.Wait.
+func startHelper(cmd *exec.Cmd) error {
+	helpers.Lock()
+	defer helpers.Unlock()
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	if helpers.pids == nil {
+		helpers.pids = make(map[int]bool)
+	}
+
+	helpers.pids[cmd.Process.Pid] = true
+
+	return nil
+}
+
+// waitHelper waits for a helper started with startHelper.
+func waitHelper(cmd *exec.Cmd) error {
+	err := cmd.Wait()
+
+	helpers.Lock()
+	delete(helpers.pids, cmd.Process.Pid)
+	helpers.Unlock()
+
+	return err
+}
+
+// runHelper runs cmd as a helper and waits for it, like cmd.Run.
+func runHelper(cmd *exec.Cmd) error {
+	if err := startHelper(cmd); err != nil {
+		return err
+	}
+
+	return waitHelper(cmd)
+}
+
+// helperOutput runs cmd as a helper and returns its standard output, and
+// its standard error too if combined is set.
+func helperOutput(cmd *exec.Cmd, combined bool) ([]byte, error) {
+	var out bytes.Buffer
+
+	cmd.Stdout = &out
+	if combined {
+		cmd.Stderr = &out
+	}
+
+	err := runHelper(cmd)
+
+	return out.Bytes(), err
+}
+
+// Orphan is a process the command left behind after it exited.
+type Orphan struct {
+	PID    int    `json:"pid" unit:"none"`
+	Name   string `json:"name"`
+	Zombie bool   `json:"zombie,omitempty"` // had exited but was not waited for; reaped by ztime
+	Killed bool   `json:"killed,omitempty"` // killed by --kill-orphans
+
+	CPUTime time.Duration `json:"cpu_time,omitempty"` // user plus system, with its reaped descendants, once ztime reaped it
+}
+
+// collectOrphans reports processes left behind by the latest run, after the
+// cleanup hook had a chance to stop them. Zombies are reaped, and with
+// --kill-orphans live ones are killed. Exporters and helpers are ztime's
+// own children and are skipped, as are processes reported for an earlier
+// run.
+func (c *runCmd) collectOrphans() []Orphan {
+	if !c.orphansWatched {
+		return nil
+	}
+
+	// Helpers cannot start while the children are listed, or a new one
+	// could be taken for an orphan.
+	helpers.Lock()
+	defer helpers.Unlock()
+
+	exclude := make([]int, 0, len(c.exporters)+len(helpers.pids))
+	for _, e := range c.exporters {
+		exclude = append(exclude, e.cmd.Process.Pid)
+	}
+
+	for pid := range helpers.pids {
+		exclude = append(exclude, pid)
+	}
+
+	if c.seenOrphans == nil {
+		c.seenOrphans = make(map[int]bool)
+	}
+
+	var orphans []Orphan
+
+	for _, o := range settleOrphans(exclude, c.KillOrphans) {
+		if !c.seenOrphans[o.PID] {
+			c.seenOrphans[o.PID] = true
+			orphans = append(orphans, o)
+		}
+	}
+
+	return orphans
+}
+
+// orphanNote describes the processes still running after the command
+// exited, or returns "" if there were none.
+func orphanNote(orphans []Orphan) string {
+	var names []string
+
+	killed := false
+
+	for _, o := range orphans {
+		if !o.Zombie {
+			names = append(names, fmt.Sprintf("%s (%d)", o.Name, o.PID))
+			killed = killed || o.Killed
+		}
+	}
+
+	if len(names) == 0 {
+		return ""
+	}
+
+	note := fmt.Sprintf("%d process(es) outlived the command: %s", len(names), strings.Join(names, ", "))
+	if killed {
+		return note + "; killed"
+	}
+
+	return note + " (see --kill-orphans)"
+}
@@ -0,0 +1,129 @@
+//go:build freebsd && (amd64 || arm64 || riscv64)
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// procctl(2) commands and flags from <sys/procctl.h>, which x/sys/unix
+// does not define. procctl takes a 64-bit id_t, passed as one argument on
+// 64-bit platforms only.
+const (
+	pPID             = 0 // P_PID
+	procReapAcquire  = 2
+	procReapStatus   = 4
+	procReapGetPIDs  = 5
+	reaperInfoValid  = 0x01
+	reaperInfoChild  = 0x02
+	reaperInfoZombie = 0x08
+)
+
+type reaperStatus struct {
+	Flags       uint32
+	Children    uint32
+	Descendants uint32
+	Reaper      int32
+	PID         int32
+	_           [15]uint32
+}
+
+type reaperPIDInfo struct {
+	PID     int32
+	Subtree int32
+	Flags   uint32
+	_       [15]uint32
+}
+
+type reaperPIDs struct {
+	Count uint32
+	_     [15]uint32
+	PIDs  *reaperPIDInfo
+}
+
+// watchOrphans makes ztime a reaper, so processes orphaned by the command
+// are reparented to ztime instead of init and can be found later.
+func watchOrphans() bool {
+	return procctl(procReapAcquire, nil) == nil
+}
+
+// settleOrphans lists ztime's children other than exclude, reaps the
+// zombies among them, and kills the rest if kill is set. Reaping them
+// adds what they used, with their own reaped descendants, to the CPU time
+// each reports.
+func settleOrphans(exclude []int, kill bool) []Orphan {
+	var status reaperStatus
+	if procctl(procReapStatus, unsafe.Pointer(&status)) != nil || status.Descendants == 0 {
+		return nil
+	}
+
+	infos := make([]reaperPIDInfo, status.Descendants)
+	pids := reaperPIDs{Count: uint32(len(infos)), PIDs: &infos[0]}
+
+	if procctl(procReapGetPIDs, unsafe.Pointer(&pids)) != nil {
+		return nil
+	}
+
+	var orphans []Orphan
+
+	for _, info := range infos {
+		pid := int(info.PID)
+		if info.Flags&(reaperInfoValid|reaperInfoChild) != reaperInfoValid|reaperInfoChild || slices.Contains(exclude, pid) {
+			continue
+		}
+
+		o := Orphan{PID: pid, Name: processName(pid), Zombie: info.Flags&reaperInfoZombie != 0}
+
+		var (
+			ws    unix.WaitStatus
+			usage unix.Rusage
+		)
+
+		switch {
+		case o.Zombie:
+			if reaped, _ := unix.Wait4(pid, &ws, unix.WNOHANG, &usage); reaped == pid {
+				o.CPUTime = rusageCPU(usage)
+			}
+		case kill:
+			if unix.Kill(pid, unix.SIGKILL) == nil {
+				_, _ = unix.Wait4(pid, &ws, 0, &usage)
+				o.Killed, o.CPUTime = true, rusageCPU(usage)
+			}
+		}
+
+		orphans = append(orphans, o)
+	}
+
+	return orphans
+}
+
+func procctl(cmd int, data unsafe.Pointer) error {
+	_, _, errno := unix.Syscall6(unix.SYS_PROCCTL, pPID, uintptr(os.Getpid()), uintptr(cmd), uintptr(data), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// processName returns the program name of pid from its arguments, or ""
+// for a zombie, which has none left.
+func processName(pid int) string {
+	args, err := unix.SysctlRaw("kern.proc.args", pid)
+	if err != nil {
+		return ""
+	}
+
+	argv0, _, _ := bytes.Cut(args, []byte{0})
+	if len(argv0) == 0 {
+		return ""
+	}
+
+	return filepath.Base(string(argv0))
+}
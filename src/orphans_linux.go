@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"slices"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchOrphans makes ztime a child subreaper, so processes orphaned by the
+// command are reparented to ztime instead of init and can be found later.
+func watchOrphans() bool {
+	return unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0) == nil
+}
+
+// settleOrphans lists ztime's children other than exclude, reaps the
+// zombies among them, and kills the rest if kill is set.
+func settleOrphans(exclude []int, kill bool) []Orphan {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	self := os.Getpid()
+
+	var orphans []Orphan
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || slices.Contains(exclude, pid) {
+			continue
+		}
+
+		name, state, ppid, ok := readStat(pid)
+		if !ok || ppid != self {
+			continue
+		}
+
+		o := Orphan{PID: pid, Name: name, Zombie: state == 'Z'}
+
+		var (
+			status unix.WaitStatus
+			usage  unix.Rusage
+		)
+
+		switch {
+		case o.Zombie:
+			if reaped, _ := unix.Wait4(pid, &status, unix.WNOHANG, &usage); reaped == pid {
+				o.CPUTime = rusageCPU(usage)
+			}
+		case kill:
+			if unix.Kill(pid, unix.SIGKILL) == nil {
+				_, _ = unix.Wait4(pid, &status, 0, &usage)
+				o.Killed, o.CPUTime = true, rusageCPU(usage)
+			}
+		}
+
+		orphans = append(orphans, o)
+	}
+
+	return orphans
+}
+
+// readStat parses the command name, state, and parent PID from
+// /proc/<pid>/stat. The name is in parentheses and may itself contain
+// spaces or parentheses, so the fields after it are found from the last ')'.
+func readStat(pid int) (string, byte, int, bool) {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return "", 0, 0, false
+	}
+
+	open := bytes.IndexByte(data, '(')
+	end := bytes.LastIndexByte(data, ')')
+
+	if open < 0 || end < open {
+		return "", 0, 0, false
+	}
+
+	fields := bytes.Fields(data[end+1:])
+	if len(fields) < 2 || len(fields[0]) != 1 {
+		return "", 0, 0, false
+	}
+
+	ppid, err := strconv.Atoi(string(fields[1]))
+	if err != nil {
+		return "", 0, 0, false
+	}
+
+	return string(data[open+1 : end]), fields[0][0], ppid, true
+}
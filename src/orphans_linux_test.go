@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestReadStat(t *testing.T) {
+	t.Parallel()
+
+	name, state, ppid, ok := readStat(os.Getpid())
+	if !ok {
+		t.Fatal("readStat() of the test process failed")
+	}
+
+	if name == "" || state == 0 || ppid != os.Getppid() {
+		t.Errorf("readStat() = %q, %q, %d, want a name, a state, and parent %d", name, state, ppid, os.Getppid())
+	}
+
+	if _, _, _, ok := readStat(-1); ok {
+		t.Error("readStat(-1) succeeded")
+	}
+}
+
+func TestCollectOrphansSkipsHelpers(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep not available")
+	}
+
+	cmd := exec.CommandContext(context.Background(), sleep, "10")
+	if err := startHelper(cmd); err != nil {
+		t.Fatalf("startHelper() error = %v", err)
+	}
+
+	c := &runCmd{orphansWatched: true}
+	orphans := c.collectOrphans()
+
+	_ = cmd.Process.Kill()
+	_ = waitHelper(cmd)
+
+	for _, o := range orphans {
+		if o.PID == cmd.Process.Pid {
+			t.Errorf("collectOrphans() = %+v, includes helper %d", orphans, o.PID)
+		}
+	}
+
+	helpers.Lock()
+	defer helpers.Unlock()
+
+	if helpers.pids[cmd.Process.Pid] {
+		t.Errorf("helper %d still tracked after waitHelper()", cmd.Process.Pid)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !(freebsd && (amd64 || arm64 || riscv64))
+
+package main
+
+// watchOrphans reports that orphaned processes cannot be tracked: only
+// Linux and FreeBSD let ztime adopt them.
+func watchOrphans() bool {
+	return false
+}
+
+func settleOrphans([]int, bool) []Orphan {
+	return nil
+}
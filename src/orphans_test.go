@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestOrphanNote(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		orphans  []Orphan
+		expected string
+	}{
+		{name: "None", expected: ""},
+		{name: "Only Zombies", orphans: []Orphan{{PID: 7, Name: "true", Zombie: true}}, expected: ""},
+		{
+			name:     "Running",
+			orphans:  []Orphan{{PID: 7, Name: "sleep"}, {PID: 9, Name: "true", Zombie: true}, {PID: 8, Name: "redis-server"}},
+			expected: "2 process(es) outlived the command: sleep (7), redis-server (8) (see --kill-orphans)",
+		},
+		{
+			name:     "Killed",
+			orphans:  []Orphan{{PID: 7, Name: "sleep", Killed: true}},
+			expected: "1 process(es) outlived the command: sleep (7); killed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := orphanNote(tt.orphans); got != tt.expected {
+				t.Errorf("orphanNote() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Actions for --output-rate-action.
+const (
+	rateActionWarn     = "warn"
+	rateActionThrottle = "throttle"
+	rateActionTruncate = "truncate"
+)
+
+// outputGuard watches the combined stdout/stderr rate of the child and, if
+// asked, throttles or truncates output beyond the limit. Installing it puts
+// a pipe between the child and the terminal.
+type outputGuard struct {
+	limit  int64 // bytes per second
+	action string
+
+	mu          sync.Mutex
+	windowStart time.Time
+	windowBytes int64
+	next        time.Time // earliest time the next write may pass when throttling
+	peak        int64
+	exceeded    bool
+	dropped     int64
+	throttled   time.Duration
+}
+
+type guardedWriter struct {
+	guard *outputGuard
+	dst   io.Writer
+}
+
+func guardOutput(cmd *exec.Cmd, limit int64, action string) *outputGuard {
+	g := &outputGuard{limit: limit, action: action}
+	cmd.Stdout = guardedWriter{guard: g, dst: os.Stdout}
+	cmd.Stderr = guardedWriter{guard: g, dst: os.Stderr}
+
+	return g
+}
+
+// Write passes p through as far as the guard allows. Truncated output is
+// reported as written so the child does not see an error.
+func (w guardedWriter) Write(p []byte) (int, error) {
+	allowed, delay := w.guard.admit(len(p), time.Now())
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	if allowed > 0 {
+		if _, err := w.dst.Write(p[:allowed]); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// admit accounts for n bytes written at now and returns how many may pass
+// and how long to wait before writing them.
+func (g *outputGuard) admit(n int, now time.Time) (int, time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if now.Sub(g.windowStart) >= time.Second {
+		g.windowStart = now
+		g.windowBytes = 0
+	}
+
+	before := g.windowBytes
+	g.windowBytes += int64(n)
+	g.peak = max(g.peak, g.windowBytes)
+
+	if g.windowBytes > g.limit {
+		g.exceeded = true
+	}
+
+	switch g.action {
+	case rateActionTruncate:
+		allowed := int(min(max(g.limit-before, 0), int64(n)))
+		g.dropped += int64(n - allowed)
+
+		return allowed, 0
+	case rateActionThrottle:
+		if g.next.Before(now) {
+			g.next = now
+		}
+
+		delay := g.next.Sub(now)
+		g.next = g.next.Add(time.Duration(int64(n) * int64(time.Second) / g.limit))
+		g.throttled += delay
+
+		return n, delay
+	default:
+		return n, 0
+	}
+}
+
+func (g *outputGuard) finish(m *Metrics, _ time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	m.OutputRatePeak = g.peak
+	m.OutputRateExceeded = g.exceeded
+	m.OutputDropped = g.dropped
+	m.OutputThrottled = g.throttled
+}
+
+// outputRateNote describes how the output rate limit was hit, or returns ""
+// if it was not.
+func outputRateNote(m Metrics, limit int64) string {
+	if !m.OutputRateExceeded {
+		return ""
+	}
+
+	note := fmt.Sprintf("output exceeded %s/s (peak %s/s)", humanBytes(limit), humanBytes(m.OutputRatePeak))
+
+	switch {
+	case m.OutputDropped > 0:
+		note += fmt.Sprintf(", %s dropped", humanBytes(m.OutputDropped))
+	case m.OutputThrottled > 0:
+		note += fmt.Sprintf(", throttled for %s", humanDuration(m.OutputThrottled))
+	}
+
+	return note
+}
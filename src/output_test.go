@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutputGuardAdmit(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+
+	tests := []struct {
+		name        string
+		action      string
+		writes      []int
+		wantAllowed []int
+		wantDelays  []time.Duration
+		wantDropped int64
+		exceeded    bool
+	}{
+		{
+			name:        "under limit",
+			action:      rateActionWarn,
+			writes:      []int{40, 40},
+			wantAllowed: []int{40, 40},
+			wantDelays:  []time.Duration{0, 0},
+		},
+		{
+			name:        "warn passes everything",
+			action:      rateActionWarn,
+			writes:      []int{80, 80},
+			wantAllowed: []int{80, 80},
+			wantDelays:  []time.Duration{0, 0},
+			exceeded:    true,
+		},
+		{
+			name:        "truncate drops the excess",
+			action:      rateActionTruncate,
+			writes:      []int{80, 80, 10},
+			wantAllowed: []int{80, 20, 0},
+			wantDelays:  []time.Duration{0, 0, 0},
+			wantDropped: 70,
+			exceeded:    true,
+		},
+		{
+			name:        "throttle paces writes",
+			action:      rateActionThrottle,
+			writes:      []int{50, 50, 50},
+			wantAllowed: []int{50, 50, 50},
+			wantDelays:  []time.Duration{0, 500 * time.Millisecond, time.Second},
+			exceeded:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			g := &outputGuard{limit: 100, action: tt.action}
+
+			for i, n := range tt.writes {
+				allowed, delay := g.admit(n, start)
+				if allowed != tt.wantAllowed[i] || delay != tt.wantDelays[i] {
+					t.Errorf("write %d: admit(%d) = %d, %v, want %d, %v", i, n, allowed, delay, tt.wantAllowed[i], tt.wantDelays[i])
+				}
+			}
+
+			var m Metrics
+
+			g.finish(&m, start)
+
+			if m.OutputDropped != tt.wantDropped {
+				t.Errorf("OutputDropped = %d, want %d", m.OutputDropped, tt.wantDropped)
+			}
+
+			if m.OutputRateExceeded != tt.exceeded {
+				t.Errorf("OutputRateExceeded = %v, want %v", m.OutputRateExceeded, tt.exceeded)
+			}
+		})
+	}
+}
+
+func TestOutputGuardWindowResets(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	g := &outputGuard{limit: 100, action: rateActionTruncate}
+
+	g.admit(100, start)
+
+	if allowed, _ := g.admit(100, start.Add(time.Second)); allowed != 100 {
+		t.Errorf("admit() in a new window allowed %d bytes, want 100", allowed)
+	}
+}
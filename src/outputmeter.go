@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// outputMeter counts what the child writes to stdout and stderr and records
+// when it first wrote. Installing it puts a pipe between the child and the
+// terminal, so the child no longer sees a terminal on its output.
+type outputMeter struct {
+	mu      sync.Mutex
+	first   time.Time
+	bytes   int64
+	lines   int64
+	partial [2]bool // whether stdout and stderr end in an unterminated line
+}
+
+type meteredWriter struct {
+	meter  *outputMeter
+	stream int // 0 for stdout, 1 for stderr
+	dst    io.Writer
+}
+
+// meterOutput wraps the stdout and stderr the instruments before it settled
+// on, or ztime's own.
+func meterOutput(cmd *exec.Cmd) *outputMeter {
+	meter := &outputMeter{}
+
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
+	}
+
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+
+	cmd.Stdout = meteredWriter{meter: meter, stream: 0, dst: cmd.Stdout}
+	cmd.Stderr = meteredWriter{meter: meter, stream: 1, dst: cmd.Stderr}
+
+	return meter
+}
+
+func (w meteredWriter) Write(p []byte) (int, error) {
+	w.meter.count(w.stream, p, time.Now())
+
+	return w.dst.Write(p)
+}
+
+func (o *outputMeter) count(stream int, p []byte, now time.Time) {
+	if len(p) == 0 {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.first.IsZero() {
+		o.first = now
+	}
+
+	o.bytes += int64(len(p))
+	o.lines += int64(bytes.Count(p, []byte{'\n'}))
+	o.partial[stream] = p[len(p)-1] != '\n'
+}
+
+// finish stores the counters in m, counting a final line without a newline
+// as a line. Throughput is over the whole run, not just the time after the
+// first output.
+func (o *outputMeter) finish(m *Metrics, start time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	m.OutputBytes = o.bytes
+	m.OutputLines = o.lines
+
+	for _, partial := range o.partial {
+		if partial {
+			m.OutputLines++
+		}
+	}
+
+	if !o.first.IsZero() {
+		m.FirstOutputMS = float64(max(o.first.Sub(start), 0)) / float64(time.Millisecond)
+	}
+
+	if seconds := m.ElapsedTime.Seconds(); seconds > 0 {
+		m.OutputBytesPerSec = float64(m.OutputBytes) / seconds
+		m.OutputLinesPerSec = float64(m.OutputLines) / seconds
+	}
+}
+
+// firstOutputNote describes how long the command took to start writing.
+func firstOutputNote(m Metrics) string {
+	if m.FirstOutputMS == 0 {
+		return "first output: none"
+	}
+
+	return fmt.Sprintf("first output after %.3fs", m.FirstOutputMS/1000)
+}
+
+// outputStatsNote describes how much the command wrote and how fast.
+func outputStatsNote(m Metrics) string {
+	return fmt.Sprintf("output %d lines, %s (%.1f lines/s, %s/s)",
+		m.OutputLines, humanBytes(m.OutputBytes), m.OutputLinesPerSec, humanBytes(int64(m.OutputBytesPerSec)))
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestOutputMeterCount(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	meter := &outputMeter{}
+
+	meter.count(0, []byte("one\ntw"), start.Add(50*time.Millisecond))
+	meter.count(1, []byte("err\n"), start.Add(60*time.Millisecond))
+	meter.count(0, []byte("o\nthree"), start.Add(70*time.Millisecond))
+	meter.count(1, nil, start.Add(80*time.Millisecond))
+
+	m := Metrics{ElapsedTime: 2 * time.Second}
+	meter.finish(&m, start)
+
+	if m.OutputBytes != 17 || m.OutputLines != 4 || m.FirstOutputMS != 50 {
+		t.Errorf("counted %d bytes, %d lines, first at %vms; want 17, 4, 50ms", m.OutputBytes, m.OutputLines, m.FirstOutputMS)
+	}
+
+	if m.OutputBytesPerSec != 8.5 || m.OutputLinesPerSec != 2 {
+		t.Errorf("rates = %v bytes/s, %v lines/s; want 8.5, 2", m.OutputBytesPerSec, m.OutputLinesPerSec)
+	}
+}
+
+func TestOutputMeter(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	tests := []struct {
+		name    string
+		script  string
+		wantMin float64
+		want    string
+	}{
+		{"delayed stderr", "sleep 0.1; echo late >&2", 100, "late\n"},
+		{"silent", "true", 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var stderr bytes.Buffer
+
+			cmd := exec.CommandContext(context.Background(), "sh", "-c", tt.script)
+			cmd.Stderr = &stderr
+
+			first := meterOutput(cmd)
+			start := time.Now()
+
+			if err := cmd.Run(); err != nil {
+				t.Fatal(err)
+			}
+
+			var m Metrics
+			first.finish(&m, start)
+
+			if tt.wantMin == 0 && m.FirstOutputMS != 0 || m.FirstOutputMS < tt.wantMin || m.FirstOutputMS > tt.wantMin+5000 {
+				t.Errorf("FirstOutputMS = %v, want about %v", m.FirstOutputMS, tt.wantMin)
+			}
+
+			if stderr.String() != tt.want {
+				t.Errorf("stderr = %q, want %q passed through", stderr.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatOutput(t *testing.T) {
+	t.Parallel()
+
+	m := Metrics{FirstOutputMS: 184.5, OutputBytes: 3 << 20, OutputLines: 1200, OutputBytesPerSec: 1 << 20, OutputLinesPerSec: 400.25}
+
+	tests := []struct {
+		fmt   string
+		m     Metrics
+		human bool
+		want  string
+	}{
+		{"%f", m, false, "0.18s"},
+		{"%.3f", m, false, "0.184s"},
+		{"%*f", Metrics{FirstOutputMS: 61500}, false, "1:01.50"},
+		{"%f", Metrics{}, false, "-"},
+		{"%L %l", m, false, "1200 400.2"},
+		{"%B %b", m, false, "3145728 1048576"},
+		{"%B %b", m, true, "3.0 MiB 1.0 MiB/s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fmt, func(t *testing.T) {
+			t.Parallel()
+
+			if got := format(tt.fmt, tt.m, formatOptions{human: tt.human}); got != tt.want {
+				t.Errorf("format(%q) = %q, want %q", tt.fmt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsesSpecifier(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		tmpl string
+		want bool
+	}{
+		{"%E first %f", true},
+		{"%L lines", false},
+		{"%-8.3f", true},
+		{"100%% full", false},
+		{"%E %J", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := usesSpecifier(tt.tmpl, "f", nil); got != tt.want {
+			t.Errorf("usesSpecifier(%q, \"f\") = %v, want %v", tt.tmpl, got, tt.want)
+		}
+	}
+}
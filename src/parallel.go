@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ParallelResult is the outcome of running several commands at once with
+// --parallel.
+type ParallelResult struct {
+	SchemaVersion int `json:"schema_version,omitempty" unit:"none"`
+
+	Jobs     int           `json:"jobs" unit:"count"` // most commands running at a time
+	WallTime time.Duration `json:"wall_time"`         // from the first start to the last exit, excluding hooks
+	Results  []Metrics     `json:"results"`           // in argument order
+
+	Annotations
+}
+
+// parallel runs the --parallel commands, reports them together, and
+// returns the error of the first command, in argument order, that failed.
+func (c *runCmd) parallel() error {
+	result, err := c.runParallel()
+	orphans := c.collectOrphans()
+
+	c.export("parallel", result)
+
+	if !c.Quiet {
+		c.reportParallel(result)
+
+		if note := orphanNote(orphans); note != "" && !c.structured() {
+			fmt.Fprintf(os.Stderr, "ztime: %s\n", note)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, m := range result.Results {
+		if err := c.checkThresholds(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runParallel runs every argument as a shell command, --jobs at a time,
+// between one --prepare and one --cleanup. The commands get an empty stdin
+// unless --stdin names a file. The error is that of the first command, in
+// argument order, that failed, or else of the cleanup hook.
+func (c *runCmd) runParallel() (ParallelResult, error) {
+	jobs := len(c.Command)
+	if c.Jobs > 0 {
+		jobs = min(c.Jobs, jobs)
+	}
+
+	opts := c.runOptions(runOptions{totalRuns: len(c.Command)})
+	if opts.stdinPath == "" {
+		opts.stdinPath = os.DevNull
+	}
+
+	if err := c.beforeRun(opts); err != nil {
+		return ParallelResult{Jobs: jobs}, err
+	}
+
+	run := c.runner()
+	results := make([]Metrics, len(c.Command))
+	errs := make([]error, len(c.Command))
+	slots := make(chan struct{}, jobs)
+
+	var wg sync.WaitGroup
+
+	start := time.Now()
+
+	for i, command := range c.Command {
+		wg.Go(func() {
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			opts := opts
+			opts.iteration = i + 1
+
+			m, err := run(shellCommand(context.Background(), command).Args, opts)
+			m.Command = command
+			m.Annotations = c.annotations()
+			opts.events.emit(event{Type: eventRunFinished, Iteration: opts.iteration, Result: m})
+			c.sendStatsD(m)
+
+			results[i], errs[i] = m, err
+		})
+	}
+
+	wg.Wait()
+
+	result := ParallelResult{Jobs: jobs, WallTime: time.Since(start), Results: results, Annotations: c.annotations()}
+
+	hookErr := runHook("cleanup", c.Cleanup, opts)
+
+	for _, err := range errs {
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, hookErr
+}
+
+// reportParallel prints the result of --parallel in the selected output
+// format. Templates receive the ParallelResult.
+func (c *runCmd) reportParallel(r ParallelResult) {
+	switch {
+	case c.JSONL:
+		c.events.emit(event{Type: eventParallelFinished, Result: r})
+	case c.JSON:
+		c.printJSON(r)
+	case c.tmpl != nil:
+		printTemplate(c.tmpl, r)
+	default:
+		printParallel(r)
+	}
+}
+
+// printParallel prints a row of metrics per command and a line comparing
+// the wall time with the sum of the commands' elapsed times.
+func printParallel(r ParallelResult) {
+	bold := lipgloss.NewStyle().Bold(true)
+	faint := lipgloss.NewStyle().Faint(true)
+
+	rows := [][]string{{"command", "elapsed", "user", "system", "cpu", "max rss", "exit"}}
+
+	var total time.Duration
+
+	for _, m := range r.Results {
+		total += m.ElapsedTime
+		rows = append(rows, []string{
+			m.Command,
+			fmt.Sprintf("%.3fs", m.ElapsedTime.Seconds()),
+			fmt.Sprintf("%.2fs", m.UserTime.Seconds()),
+			fmt.Sprintf("%.2fs", m.SystemTime.Seconds()),
+			fmt.Sprintf("%d%%", m.CPUPercent),
+			humanBytes(m.MaxRSS * 1024),
+			strconv.Itoa(m.ExitCode),
+		})
+	}
+
+	var widths [6]int
+
+	for _, row := range rows {
+		for i := range widths {
+			widths[i] = max(widths[i], utf8.RuneCountInString(row[i]))
+		}
+	}
+
+	for i, row := range rows {
+		line := fmt.Sprintf("%-*s  %*s  %*s  %*s  %*s  %*s  ", widths[0], row[0], widths[1], row[1], widths[2], row[2], widths[3], row[3], widths[4], row[4], widths[5], row[5])
+
+		switch {
+		case i == 0:
+			fmt.Fprintln(os.Stderr, faint.Render(line+row[6]))
+		case row[6] != "0":
+			fmt.Fprintln(os.Stderr, line+palette.bad.Render(row[6]))
+		default:
+			fmt.Fprintln(os.Stderr, line+row[6])
+		}
+	}
+
+	summary := fmt.Sprintf("%d commands, %d at a time: %.3fs wall, %.3fs total", len(r.Results), r.Jobs, r.WallTime.Seconds(), total.Seconds())
+	if r.WallTime > 0 {
+		summary += fmt.Sprintf(" (%.2fx concurrency)", float64(total)/float64(r.WallTime))
+	}
+
+	fmt.Fprintln(os.Stderr, bold.Render(summary))
+}
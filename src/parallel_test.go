@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestRunParallel(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	tests := []struct {
+		name     string
+		jobs     int
+		wantJobs int
+		minWall  time.Duration
+		maxWall  time.Duration
+	}{
+		{"all at once", 0, 3, 0, 550 * time.Millisecond},
+		{"one at a time", 1, 1, 600 * time.Millisecond, time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := runCmd{Command: []string{"sleep 0.2", "sleep 0.2; exit 3", "sleep 0.2; exit 4"}, Jobs: tt.jobs}
+
+			r, err := c.runParallel()
+
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+				t.Errorf("runParallel() error = %v, want the first failure, exit status 3", err)
+			}
+
+			if r.Jobs != tt.wantJobs || len(r.Results) != 3 {
+				t.Fatalf("runParallel() = %d jobs, %d results; want %d, 3", r.Jobs, len(r.Results), tt.wantJobs)
+			}
+
+			for i, want := range []int{0, 3, 4} {
+				if r.Results[i].ExitCode != want || r.Results[i].Command != c.Command[i] {
+					t.Errorf("Results[%d] = %q exit %d, want %q exit %d", i, r.Results[i].Command, r.Results[i].ExitCode, c.Command[i], want)
+				}
+			}
+
+			if r.WallTime < tt.minWall || r.WallTime > tt.maxWall {
+				t.Errorf("WallTime = %v, want between %v and %v", r.WallTime, tt.minWall, tt.maxWall)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// perThreadInterval is how often --per-thread reads the CPU time of the
+// command's threads.
+const perThreadInterval = 20 * time.Millisecond
+
+var errPerThreadUnsupported = errors.New("--per-thread is not supported")
+
+// ThreadCPU is how the CPU time of the command and its children was split
+// among their threads, sampled with --per-thread.
+type ThreadCPU struct {
+	Samples int           `json:"samples" unit:"count"`
+	Threads int           `json:"threads" unit:"count"` // distinct threads seen
+	CPUs    int           `json:"cpus" unit:"count"`    // distinct CPUs any of them was seen on
+	CPUTime time.Duration `json:"cpu_time"`             // of all the threads seen
+	Busiest []ThreadTime  `json:"busiest"`              // most CPU time first
+}
+
+// ThreadTime is the CPU time of one thread.
+type ThreadTime struct {
+	PID    int           `json:"pid" unit:"none"`
+	TID    int           `json:"tid" unit:"none"`
+	Name   string        `json:"name"`
+	User   time.Duration `json:"user"`
+	System time.Duration `json:"system"`
+	CPUs   int           `json:"cpus" unit:"count"` // distinct CPUs it was seen on
+}
+
+// threadSample is one thread as seen in one sample.
+type threadSample struct {
+	pid, tid int
+	name     string
+	user     time.Duration
+	system   time.Duration
+	cpu      int // the CPU it last ran on
+}
+
+// threadTracker keeps the latest CPU times of every thread seen and the
+// CPUs each was seen on.
+type threadTracker struct {
+	samples int
+	threads map[int]*ThreadTime
+	cpus    map[int]map[int]bool // by TID
+}
+
+func newThreadTracker() *threadTracker {
+	return &threadTracker{threads: map[int]*ThreadTime{}, cpus: map[int]map[int]bool{}}
+}
+
+func (t *threadTracker) add(threads []threadSample) {
+	t.samples++
+
+	for _, s := range threads {
+		th, ok := t.threads[s.tid]
+		if !ok {
+			th = &ThreadTime{PID: s.pid, TID: s.tid}
+			t.threads[s.tid] = th
+			t.cpus[s.tid] = map[int]bool{}
+		}
+
+		th.Name, th.User, th.System = s.name, s.user, s.system
+		t.cpus[s.tid][s.cpu] = true
+	}
+}
+
+// result returns the split with the top busiest threads.
+func (t *threadTracker) result(top int) *ThreadCPU {
+	c := &ThreadCPU{Samples: t.samples, Threads: len(t.threads)}
+
+	cpus := map[int]bool{}
+
+	for tid, th := range t.threads {
+		th.CPUs = len(t.cpus[tid])
+		for cpu := range t.cpus[tid] {
+			cpus[cpu] = true
+		}
+
+		c.CPUTime += th.User + th.System
+		c.Busiest = append(c.Busiest, *th)
+	}
+
+	c.CPUs = len(cpus)
+
+	slices.SortFunc(c.Busiest, func(a, b ThreadTime) int {
+		return cmp.Or(cmp.Compare(b.User+b.System, a.User+a.System), cmp.Compare(a.TID, b.TID))
+	})
+	c.Busiest = c.Busiest[:min(top, len(c.Busiest))]
+
+	return c
+}
+
+// perThreadNote lists the busiest threads over several lines, or returns ""
+// without --per-thread.
+func perThreadNote(c *ThreadCPU) string {
+	if c == nil {
+		return ""
+	}
+
+	var note strings.Builder
+
+	fmt.Fprintf(&note, "threads: %d threads on %d CPUs used %s of CPU time", c.Threads, c.CPUs, humanDuration(c.CPUTime))
+
+	for _, th := range c.Busiest {
+		share := 0.0
+		if c.CPUTime > 0 {
+			share = float64(th.User+th.System) / float64(c.CPUTime) * 100
+		}
+
+		fmt.Fprintf(&note, "\n  %10s %3.0f%%  %10s user %10s sys  %2d CPUs  %s (pid %d, tid %d)",
+			humanDuration(th.User+th.System), share, humanDuration(th.User), humanDuration(th.System), th.CPUs, th.Name, th.PID, th.TID)
+	}
+
+	return note.String()
+}
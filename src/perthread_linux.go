@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"time"
+)
+
+// userHZ is the unit of the CPU times in /proc, fixed at 100 ticks a
+// second by the kernel's ABI.
+const userHZ = 100
+
+func perThreadSupported() error {
+	return nil
+}
+
+// threadSampler reads the CPU time of every thread of a process tree until
+// stopped.
+type threadSampler struct {
+	done    chan struct{}
+	stopped chan struct{}
+	top     int
+	tracker *threadTracker
+}
+
+// sampleThreads starts sampling the threads of pid and its descendants. It
+// returns nil if top is 0.
+func sampleThreads(top, pid int) *threadSampler {
+	if top <= 0 {
+		return nil
+	}
+
+	s := &threadSampler{done: make(chan struct{}), stopped: make(chan struct{}), top: top, tracker: newThreadTracker()}
+
+	go func() {
+		defer close(s.stopped)
+
+		ticker := time.NewTicker(perThreadInterval)
+		defer ticker.Stop()
+
+		for {
+			if threads, ok := readThreads(pid); ok {
+				s.tracker.add(threads)
+			}
+
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return s
+}
+
+// readThreads reads the stat file of every thread of pid and its
+// descendants.
+func readThreads(pid int) ([]threadSample, bool) {
+	tree, ok := processTree(pid)
+	if !ok {
+		return nil, false
+	}
+
+	var threads []threadSample
+
+	for _, p := range tree {
+		dir := "/proc/" + strconv.Itoa(p) + "/task/"
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			tid, err := strconv.Atoi(entry.Name())
+			if err != nil {
+				continue
+			}
+
+			data, err := os.ReadFile(dir + entry.Name() + "/stat")
+			if err != nil {
+				continue
+			}
+
+			if th, ok := parseThreadStat(data); ok {
+				th.pid, th.tid = p, tid
+				threads = append(threads, th)
+			}
+		}
+	}
+
+	return threads, true
+}
+
+// parseThreadStat reads the name, user and system time, and last CPU from a
+// thread's stat file. The name is in parentheses and may contain spaces, so
+// the other fields are counted from the last closing one.
+func parseThreadStat(data []byte) (threadSample, bool) {
+	open := bytes.IndexByte(data, '(')
+	end := bytes.LastIndexByte(data, ')')
+
+	if open < 0 || end < open {
+		return threadSample{}, false
+	}
+
+	// Fields from the state, the third, on: utime is the 14th, stime the
+	// 15th, and processor the 39th.
+	fields := bytes.Fields(data[end+1:])
+	if len(fields) < 37 {
+		return threadSample{}, false
+	}
+
+	user, err1 := strconv.ParseInt(string(fields[11]), 10, 64)
+	system, err2 := strconv.ParseInt(string(fields[12]), 10, 64)
+	cpu, err3 := strconv.Atoi(string(fields[36]))
+
+	if err1 != nil || err2 != nil || err3 != nil {
+		return threadSample{}, false
+	}
+
+	return threadSample{
+		name:   string(data[open+1 : end]),
+		user:   time.Duration(user) * time.Second / userHZ,
+		system: time.Duration(system) * time.Second / userHZ,
+		cpu:    cpu,
+	}, true
+}
+
+// stop ends the sampling and stores the busiest threads in m.
+func (s *threadSampler) stop(m *Metrics) {
+	if s == nil {
+		return
+	}
+
+	close(s.done)
+	<-s.stopped
+
+	m.ThreadCPU = s.tracker.result(s.top)
+}
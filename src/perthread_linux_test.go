@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseThreadStat(t *testing.T) {
+	t.Parallel()
+
+	stat := "4242 (my (odd) name) R 1 4242 4242 0 -1 4194304 120 0 0 0 250 17 0 0 20 0 4 0 12345 " +
+		"1000000 200 18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 0 17 3 0 0 0 0 0\n"
+
+	got, ok := parseThreadStat([]byte(stat))
+
+	want := threadSample{name: "my (odd) name", user: 2500 * time.Millisecond, system: 170 * time.Millisecond, cpu: 3}
+	if !ok || got != want {
+		t.Errorf("parseThreadStat() = %+v, %v, want %+v", got, ok, want)
+	}
+
+	if _, ok := parseThreadStat([]byte("4242 (short) R 1")); ok {
+		t.Error("parseThreadStat() of a truncated stat succeeded")
+	}
+}
+
+func TestSampleThreads(t *testing.T) {
+	t.Parallel()
+
+	if sampleThreads(0, os.Getpid()) != nil {
+		t.Error("sampleThreads(0) != nil")
+	}
+
+	s := sampleThreads(1, os.Getpid())
+	time.Sleep(3 * perThreadInterval)
+
+	var m Metrics
+
+	s.stop(&m)
+
+	if m.ThreadCPU == nil || m.ThreadCPU.Threads < 1 || len(m.ThreadCPU.Busiest) != 1 {
+		t.Errorf("ThreadCPU = %+v, want the test's threads", m.ThreadCPU)
+	}
+}
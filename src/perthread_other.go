@@ -0,0 +1,20 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func perThreadSupported() error {
+	return fmt.Errorf("%w on %s", errPerThreadUnsupported, runtime.GOOS)
+}
+
+type threadSampler struct{}
+
+func sampleThreads(int, int) *threadSampler {
+	return nil
+}
+
+func (*threadSampler) stop(*Metrics) {}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThreadTracker(t *testing.T) {
+	t.Parallel()
+
+	tr := newThreadTracker()
+	tr.add([]threadSample{
+		{pid: 10, tid: 10, name: "main", user: 100 * time.Millisecond, cpu: 0},
+		{pid: 10, tid: 11, name: "worker", user: 50 * time.Millisecond, cpu: 1},
+	})
+	tr.add([]threadSample{
+		{pid: 10, tid: 10, name: "main", user: 900 * time.Millisecond, system: 100 * time.Millisecond, cpu: 2},
+		{pid: 12, tid: 12, name: "helper", user: 10 * time.Millisecond, cpu: 0},
+	})
+
+	got := tr.result(2)
+
+	if got.Samples != 2 || got.Threads != 3 || got.CPUs != 3 || got.CPUTime != 1060*time.Millisecond {
+		t.Errorf("result() = %+v", got)
+	}
+
+	want := []ThreadTime{
+		{PID: 10, TID: 10, Name: "main", User: 900 * time.Millisecond, System: 100 * time.Millisecond, CPUs: 2},
+		{PID: 10, TID: 11, Name: "worker", User: 50 * time.Millisecond, CPUs: 1},
+	}
+
+	if len(got.Busiest) != len(want) {
+		t.Fatalf("Busiest = %+v, want %+v", got.Busiest, want)
+	}
+
+	for i := range want {
+		if got.Busiest[i] != want[i] {
+			t.Errorf("Busiest[%d] = %+v, want %+v", i, got.Busiest[i], want[i])
+		}
+	}
+}
+
+func TestPerThreadNote(t *testing.T) {
+	t.Parallel()
+
+	if got := perThreadNote(nil); got != "" {
+		t.Errorf("perThreadNote(nil) = %q, want empty", got)
+	}
+
+	c := &ThreadCPU{Threads: 4, CPUs: 8, CPUTime: time.Second, Busiest: []ThreadTime{
+		{PID: 10, TID: 10, Name: "main", User: 900 * time.Millisecond, CPUs: 8},
+	}}
+
+	lines := strings.Split(perThreadNote(c), "\n")
+	if len(lines) != 2 || lines[0] != "threads: 4 threads on 8 CPUs used 1.00s of CPU time" ||
+		!strings.Contains(lines[1], " 90%") || !strings.HasSuffix(lines[1], "main (pid 10, tid 10)") {
+		t.Errorf("perThreadNote() = %q", lines)
+	}
+}
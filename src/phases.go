@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// phaseMarkPrefix starts a line that marks the beginning of a phase.
+	phaseMarkPrefix = "ZTIME-MARK:"
+	// phaseDrain is how long ztime keeps reading marks after the command
+	// exits, in case a process it left behind still holds the pipe.
+	phaseDrain = 100 * time.Millisecond
+)
+
+var errPhasesUnsupported = errors.New("--phases is not supported on this platform")
+
+// Phase is a part of the run marked by the command with --phases.
+type Phase struct {
+	Name     string        `json:"name"`
+	Start    time.Duration `json:"start"` // since run start
+	Duration time.Duration `json:"duration"`
+}
+
+// phaseMark is a phase start as read from the pipe.
+type phaseMark struct {
+	name string
+	at   time.Time
+}
+
+// phaseReader gives the command a pipe for phase marks, announced in
+// ZTIME_MARK_FD, and records when each mark arrives. A mark is a line
+// "ZTIME-MARK:NAME"; it ends the previous phase and starts the next. The
+// last phase ends when the command exits.
+type phaseReader struct {
+	reader *os.File
+	writer *os.File
+	done   chan struct{}
+
+	mu    sync.Mutex
+	marks []phaseMark
+}
+
+func watchPhases(cmd *exec.Cmd) (*phaseReader, error) {
+	if runtime.GOOS == "windows" {
+		return nil, errPhasesUnsupported
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("creating phase pipe: %w", err)
+	}
+
+	// Descriptors 0-2 are stdio; extra files follow in order.
+	cmd.ExtraFiles = append(cmd.ExtraFiles, w)
+	cmd.Env = append(cmd.Env, "ZTIME_MARK_FD="+strconv.Itoa(2+len(cmd.ExtraFiles)))
+
+	p := &phaseReader{reader: r, writer: w, done: make(chan struct{})}
+
+	go func() {
+		defer close(p.done)
+
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			if name, ok := strings.CutPrefix(scanner.Text(), phaseMarkPrefix); ok {
+				p.mu.Lock()
+				p.marks = append(p.marks, phaseMark{name: strings.TrimSpace(name), at: time.Now()})
+				p.mu.Unlock()
+			}
+		}
+	}()
+
+	return p, nil
+}
+
+// finish reads the marks still in the pipe and turns them into phases.
+func (p *phaseReader) finish(m *Metrics, start time.Time) {
+	_ = p.writer.Close()
+
+	if p.reader.SetReadDeadline(time.Now().Add(phaseDrain)) != nil {
+		_ = p.reader.Close()
+	}
+
+	<-p.done
+
+	_ = p.reader.Close()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	m.Phases = phasesFromMarks(p.marks, start, start.Add(m.ElapsedTime))
+}
+
+// phasesFromMarks turns the marks of a run into consecutive phases, the
+// last one ending at end.
+func phasesFromMarks(marks []phaseMark, start, end time.Time) []Phase {
+	phases := make([]Phase, 0, len(marks))
+
+	for i, mark := range marks {
+		until := end
+		if i+1 < len(marks) {
+			until = marks[i+1].at
+		}
+
+		phases = append(phases, Phase{Name: mark.name, Start: mark.at.Sub(start), Duration: max(until.Sub(mark.at), 0)})
+	}
+
+	return phases
+}
+
+// phasesNote lists the phases the command marked with their share of the
+// run, or returns "" if it marked none.
+func phasesNote(m Metrics) string {
+	if len(m.Phases) == 0 {
+		return ""
+	}
+
+	parts := make([]string, 0, len(m.Phases)+1)
+
+	if first := m.Phases[0].Start; first > 0 {
+		parts = append(parts, phasePart("unmarked", first, m.ElapsedTime))
+	}
+
+	for _, p := range m.Phases {
+		parts = append(parts, phasePart(p.Name, p.Duration, m.ElapsedTime))
+	}
+
+	return "phases: " + strings.Join(parts, ", ")
+}
+
+func phasePart(name string, d, total time.Duration) string {
+	if total <= 0 {
+		return fmt.Sprintf("%s %.3fs", name, d.Seconds())
+	}
+
+	return fmt.Sprintf("%s %.3fs (%.0f%%)", name, d.Seconds(), float64(d)/float64(total)*100)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os/exec"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestPhasesFromMarks(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	got := phasesFromMarks([]phaseMark{{"compile", at(100)}, {"link", at(700)}}, start, at(1000))
+	want := []Phase{
+		{Name: "compile", Start: 100 * time.Millisecond, Duration: 600 * time.Millisecond},
+		{Name: "link", Start: 700 * time.Millisecond, Duration: 300 * time.Millisecond},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("phasesFromMarks() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPhasesNote(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		m    Metrics
+		want string
+	}{
+		{"no phases", Metrics{ElapsedTime: time.Second}, ""},
+		{
+			"marked from the start",
+			Metrics{ElapsedTime: time.Second, Phases: []Phase{{Name: "a", Duration: 250 * time.Millisecond}, {Name: "b", Start: 250 * time.Millisecond, Duration: 750 * time.Millisecond}}},
+			"phases: a 0.250s (25%), b 0.750s (75%)",
+		},
+		{
+			"unmarked start",
+			Metrics{ElapsedTime: time.Second, Phases: []Phase{{Name: "a", Start: 100 * time.Millisecond, Duration: 900 * time.Millisecond}}},
+			"phases: unmarked 0.100s (10%), a 0.900s (90%)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := phasesNote(tt.m); got != tt.want {
+				t.Errorf("phasesNote() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunCommandPhases(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("--phases is not supported on Windows")
+	}
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	script := `echo ZTIME-MARK:setup >&$ZTIME_MARK_FD; echo not a mark >&$ZTIME_MARK_FD; sleep 0.2; echo ZTIME-MARK:work >&$ZTIME_MARK_FD`
+
+	m, err := runCommand([]string{"sh", "-c", script}, runOptions{phases: true})
+	if err != nil {
+		t.Fatalf("runCommand() error = %v", err)
+	}
+
+	if len(m.Phases) != 2 || m.Phases[0].Name != "setup" || m.Phases[1].Name != "work" {
+		t.Fatalf("Phases = %+v, want setup then work", m.Phases)
+	}
+
+	if m.Phases[0].Duration < 100*time.Millisecond { // marks are timed as read, so allow for a late reader
+		t.Errorf("setup took %v, want most of the 200ms sleep", m.Phases[0].Duration)
+	}
+}
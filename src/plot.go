@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Limits of the --plot charts, in terminal cells.
+const (
+	plotWidth    = 60 // sparkline; longer benchmarks are averaged down
+	plotBarWidth = 30 // longest histogram bar
+	plotMaxBins  = 10
+	sparkLevels  = "▁▂▃▄▅▆▇█"
+)
+
+// printPlot draws a benchmark's elapsed times as a sparkline in run order,
+// which shows drift and warm-up, and as a histogram, which shows the spread.
+func printPlot(r BenchResult) {
+	elapsed := elapsedTimes(r.Results)
+	if len(elapsed) < 2 {
+		return
+	}
+
+	faint := lipgloss.NewStyle().Faint(true)
+
+	fmt.Fprintf(os.Stderr, "  runs     %s\n", palette.plot.Render(sparkline(elapsed, plotWidth)))
+
+	bins, lower, width := histogram(elapsed, plotMaxBins)
+	peak := 0
+
+	for _, n := range bins {
+		peak = max(peak, n)
+	}
+
+	for i, n := range bins {
+		bar := strings.Repeat("█", int(math.Ceil(float64(n)*plotBarWidth/float64(peak))))
+		label := humanDuration(lower + time.Duration(i)*width)
+
+		fmt.Fprintf(os.Stderr, "  %s  %s %s\n", faint.Render(fmt.Sprintf("%7s", label)), palette.plot.Render(bar), faint.Render(fmt.Sprint(n)))
+	}
+}
+
+// sparkline renders samples as one block character each, scaled between
+// their minimum and maximum. More than width samples are averaged in
+// consecutive groups to fit.
+func sparkline(samples []time.Duration, width int) string {
+	if len(samples) > width {
+		grouped := make([]time.Duration, width)
+
+		for i := range grouped {
+			chunk := samples[i*len(samples)/width : (i+1)*len(samples)/width]
+			grouped[i] = summarize(chunk).Mean
+		}
+
+		samples = grouped
+	}
+
+	lowest, highest := slices.Min(samples), slices.Max(samples)
+	levels := []rune(sparkLevels)
+
+	var b strings.Builder
+
+	for _, s := range samples {
+		level := len(levels) / 2
+		if highest > lowest {
+			level = int(float64(s-lowest) / float64(highest-lowest) * float64(len(levels)-1))
+		}
+
+		b.WriteRune(levels[level])
+	}
+
+	return b.String()
+}
+
+// histogram counts samples in up to maxBins equal-width bins from the
+// smallest sample, about the square root of their number, and returns the
+// counts with the lower bound and width of the bins.
+func histogram(samples []time.Duration, maxBins int) ([]int, time.Duration, time.Duration) {
+	lowest, highest := slices.Min(samples), slices.Max(samples)
+
+	n := min(maxBins, int(math.Ceil(math.Sqrt(float64(len(samples))))))
+	if highest == lowest {
+		n = 1
+	}
+
+	width := max((highest-lowest)/time.Duration(n), 1)
+	bins := make([]int, n)
+
+	for _, s := range samples {
+		bins[min(int((s-lowest)/width), n-1)]++
+	}
+
+	return bins, lowest, width
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSparkline(t *testing.T) {
+	t.Parallel()
+
+	ms := func(values ...int) []time.Duration {
+		samples := make([]time.Duration, 0, len(values))
+		for _, v := range values {
+			samples = append(samples, time.Duration(v)*time.Millisecond)
+		}
+
+		return samples
+	}
+
+	tests := []struct {
+		name     string
+		samples  []time.Duration
+		width    int
+		expected string
+	}{
+		{name: "Scaled", samples: ms(10, 17, 24), width: 60, expected: "▁▄█"},
+		{name: "Constant", samples: ms(5, 5, 5), width: 60, expected: "▅▅▅"},
+		{name: "Averaged To Width", samples: ms(10, 10, 20, 20), width: 2, expected: "▁█"},
+	}
+
+	for _, tt := range tests {
+		if got := sparkline(tt.samples, tt.width); got != tt.expected {
+			t.Errorf("%s: sparkline() = %q, want %q", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	t.Parallel()
+
+	samples := []time.Duration{10, 11, 12, 19, 20, 30, 31, 39, 40}
+
+	bins, lower, width := histogram(samples, 10)
+	if want := []int{4, 1, 4}; !slices.Equal(bins, want) || lower != 10 || width != 10 {
+		t.Errorf("histogram() = %v, %v, %v; want %v, 10, 10", bins, lower, width, want)
+	}
+
+	bins, _, _ = histogram(samples, 2)
+	if want := []int{5, 4}; !slices.Equal(bins, want) {
+		t.Errorf("histogram() with 2 bins = %v, want %v", bins, want)
+	}
+
+	bins, _, _ = histogram([]time.Duration{5, 5, 5}, 10)
+	if want := []int{3}; !slices.Equal(bins, want) {
+		t.Errorf("histogram() of equal samples = %v, want %v", bins, want)
+	}
+}
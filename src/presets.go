@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/alecthomas/kong"
+)
+
+var errUnknownPreset = errors.New("no such preset")
+
+// presetTemplate reports a preset's benchmark on one line; %s names what
+// was run.
+const presetTemplate = "{{bold .Command}}: {{duration .Elapsed.Mean}} ± {{duration .Elapsed.StdDev}} " +
+	"(min {{duration .Elapsed.Min}}, max {{duration .Elapsed.Max}}) over {{.Runs}} %s\n"
+
+// presetTemplates are the presets' one-line summaries. They are not flag
+// defaults: a benchmark reported as plain text uses one only when no
+// --template is given, so that --json and the like still work.
+//
+//nolint:gochecknoglobals // The table is fixed and read-only.
+var presetTemplates = map[string]string{
+	"cargo": fmt.Sprintf(presetTemplate, "clean builds"),
+	"go":    fmt.Sprintf(presetTemplate, "cold runs"),
+	"npm":   fmt.Sprintf(presetTemplate, "installs from an empty cache"),
+}
+
+// presets are the bundles of flag defaults that --preset applies, keyed by
+// flag name like a config file's tables. They benchmark a build tool from
+// a cold cache, with the tool's own caching, progress output, and network
+// checks that add noise turned off.
+//
+//nolint:gochecknoglobals // The table is fixed and read-only.
+var presets = map[string]map[string]any{
+	"cargo": {
+		"runs":           3,
+		"cooldown":       "1s",
+		"jsonl-interval": "250ms",
+		"prepare":        "cargo clean",
+		"env": map[string]any{
+			"CARGO_INCREMENTAL":        "0",
+			"CARGO_TERM_PROGRESS_WHEN": "never",
+		},
+	},
+	"go": {
+		"runs":           5,
+		"cooldown":       "1s",
+		"jsonl-interval": "250ms",
+		"prepare":        "go clean -cache",
+		"env": map[string]any{
+			"GOFLAGS":     "-count=1",
+			"GOTOOLCHAIN": "local",
+		},
+	},
+	"npm": {
+		"runs":           5,
+		"cooldown":       "1s",
+		"jsonl-interval": "250ms",
+		"prepare":        "npm cache clean --force",
+		"env": map[string]any{
+			"NPM_CONFIG_AUDIT":           "false",
+			"NPM_CONFIG_FUND":            "false",
+			"NPM_CONFIG_PROGRESS":        "false",
+			"NPM_CONFIG_UPDATE_NOTIFIER": "false",
+		},
+	},
+}
+
+// presetSummary returns the preset template to report the benchmark with,
+// or "" if another output was asked for or the run is not a benchmark.
+func (c *runCmd) presetSummary() string {
+	if c.structured() || !c.benchmarking() {
+		return ""
+	}
+
+	return presetTemplates[c.preset]
+}
+
+// templateSet reports whether --template was given, even if empty, on the
+// command line or in a config file.
+func templateSet(kctx *kong.Context) bool {
+	for _, flag := range kctx.Flags() {
+		if flag.Name == "template" && flag.Set {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkPreset reports an error if name is set but is not a preset.
+func checkPreset(name string) error {
+	if name == "" || presets[name] != nil {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s (want %s)", errUnknownPreset, name, strings.Join(slices.Sorted(maps.Keys(presets)), ", "))
+}
+
+// presetResolver supplies the defaults of the selected preset. It comes
+// before the config files' resolvers, so their values and the command
+// line's take precedence.
+type presetResolver struct {
+	files *configFiles
+}
+
+func (presetResolver) Validate(*kong.Application) error {
+	return nil
+}
+
+func (r presetResolver) Resolve(ctx *kong.Context, _ *kong.Path, flag *kong.Flag) (any, error) {
+	return presets[r.selected(ctx)][flag.Name], nil
+}
+
+// selected returns the value of --preset from the command line or, failing
+// that, from the last config file that sets it.
+func (r presetResolver) selected(ctx *kong.Context) string {
+	for _, flag := range ctx.Flags() {
+		if flag.Name == "preset" {
+			if name, _ := ctx.FlagValue(flag).(string); name != "" {
+				return name
+			}
+		}
+	}
+
+	for _, c := range slices.Backward(r.files.loaded) {
+		if name, ok := c.values["preset"].(string); ok {
+			return name
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kong"
+)
+
+func TestPresets(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("cooldown = \"5s\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		args         []string
+		wantRuns     int
+		wantPrepare  string
+		wantEnv      map[string]string
+		wantCooldown time.Duration
+	}{
+		{name: "no preset", args: []string{"run", "true"}, wantCooldown: 5 * time.Second},
+		{
+			name:         "go",
+			args:         []string{"--preset", "go", "run", "go", "build"},
+			wantRuns:     5,
+			wantPrepare:  "go clean -cache",
+			wantEnv:      map[string]string{"GOFLAGS": "-count=1", "GOTOOLCHAIN": "local"},
+			wantCooldown: 5 * time.Second, // the config file wins
+		},
+		{
+			name:         "flags over preset",
+			args:         []string{"--preset", "cargo", "run", "--runs", "10", "--env", "RUSTFLAGS=-Ctarget-cpu=native", "cargo", "build"},
+			wantRuns:     10,
+			wantPrepare:  "cargo clean",
+			wantEnv:      map[string]string{"RUSTFLAGS": "-Ctarget-cpu=native"},
+			wantCooldown: 5 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var cli commandLine
+
+			configs := &configFiles{}
+
+			parser, err := kong.New(&cli, kong.Resolvers(presetResolver{files: configs}), kong.Configuration(configs.load, path))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := parser.Parse(tt.args); err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			run := cli.Run
+			if run.Runs != tt.wantRuns || run.Prepare != tt.wantPrepare || run.Cooldown != tt.wantCooldown || len(run.Env) != len(tt.wantEnv) {
+				t.Errorf("runs %d, prepare %q, cooldown %v, env %v; want %d, %q, %v, %v",
+					run.Runs, run.Prepare, run.Cooldown, run.Env, tt.wantRuns, tt.wantPrepare, tt.wantCooldown, tt.wantEnv)
+			}
+
+			for key, value := range tt.wantEnv {
+				if run.Env[key] != value {
+					t.Errorf("env %s = %q, want %q", key, run.Env[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestPresetsParse(t *testing.T) {
+	t.Parallel()
+
+	// Every preset must name real flags with values they accept.
+	for name := range presets {
+		var cli commandLine
+
+		configs := &configFiles{}
+
+		parser, err := kong.New(&cli, kong.Resolvers(presetResolver{files: configs}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := parser.Parse([]string{"--preset", name, "run", "true"}); err != nil {
+			t.Errorf("preset %s: Parse() error = %v", name, err)
+		}
+
+		// Output modes that exclude --template must still be accepted.
+		if _, err := parser.Parse([]string{"--preset", name, "run", "--json", "true"}); err != nil {
+			t.Errorf("preset %s with --json: Parse() error = %v", name, err)
+		}
+
+		if _, err := loadTemplate(presetTemplates[name]); err != nil {
+			t.Errorf("preset %s: template error = %v", name, err)
+		}
+	}
+}
+
+func TestPresetSummary(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		cmd      runCmd
+		expected string
+	}{
+		{name: "Benchmark", cmd: runCmd{preset: "go", Runs: 5}, expected: presetTemplates["go"]},
+		{name: "No Preset", cmd: runCmd{Runs: 5}, expected: ""},
+		{name: "Single Run", cmd: runCmd{preset: "go", Runs: 1}, expected: ""},
+		{name: "JSON", cmd: runCmd{preset: "go", Runs: 5, JSON: true}, expected: ""},
+		{name: "JSON Lines", cmd: runCmd{preset: "go", Runs: 5, JSONL: true}, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.cmd.presetSummary(); got != tt.expected {
+				t.Errorf("presetSummary() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCheckPreset(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"", "cargo", "go", "npm"} {
+		if err := checkPreset(name); err != nil {
+			t.Errorf("checkPreset(%q) error = %v", name, err)
+		}
+	}
+
+	if err := checkPreset("maven"); !errors.Is(err, errUnknownPreset) {
+		t.Errorf("checkPreset(maven) error = %v, want %v", err, errUnknownPreset)
+	}
+}
+
+func TestRunOptionsEnv(t *testing.T) {
+	t.Parallel()
+
+	c := &runCmd{Env: map[string]string{"B": "2", "A": "1=one"}}
+
+	if got, want := c.runOptions(runOptions{}).env, []string{"A=1=one", "B=2"}; !slices.Equal(got, want) {
+		t.Errorf("runOptions().env = %q, want %q", got, want)
+	}
+}
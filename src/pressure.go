@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// pressureInterval is how often --pressure reads the pressure files.
+	pressureInterval = 100 * time.Millisecond
+	// pressureWarn is the share of the run stalled on a resource above
+	// which the note warns that the timing is not representative.
+	pressureWarn = 10.0
+)
+
+var errPressureUnsupported = errors.New("--pressure is not supported")
+
+// Pressure is the pressure stall information of the machine, or of
+// ztime's cgroup when it has its own, during a run with --pressure.
+type Pressure struct {
+	Source  string        `json:"source"` // directory of the pressure files read
+	Samples int           `json:"samples" unit:"count"`
+	CPU     PressureStall `json:"cpu"`
+	Memory  PressureStall `json:"memory"`
+	IO      PressureStall `json:"io"`
+}
+
+// PressureStall is the share of the run in which tasks stalled waiting for
+// one resource, over the whole run and at its worst between two samples.
+type PressureStall struct {
+	SomeAvg float64 `json:"some_avg" unit:"percent"` // at least one task stalled
+	SomeMax float64 `json:"some_max" unit:"percent"`
+	FullAvg float64 `json:"full_avg" unit:"percent"` // every non-idle task stalled at once
+	FullMax float64 `json:"full_max" unit:"percent"`
+}
+
+// psiTotals is the stall time a pressure file reports so far.
+type psiTotals struct {
+	some time.Duration
+	full time.Duration
+}
+
+// parsePSI reads the total stall times of a pressure file, whose lines are
+// "some avg10=0.00 avg60=0.00 avg300=0.00 total=MICROSECONDS" and the same
+// for "full", which CPU pressure lacks on kernels before 5.13.
+func parsePSI(data []byte) (psiTotals, error) {
+	var (
+		t     psiTotals
+		found bool
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		for _, field := range fields[1:] {
+			value, ok := strings.CutPrefix(field, "total=")
+			if !ok {
+				continue
+			}
+
+			us, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return psiTotals{}, fmt.Errorf("parsing pressure %s total: %w", fields[0], err)
+			}
+
+			switch fields[0] {
+			case "some":
+				t.some, found = time.Duration(us)*time.Microsecond, true
+			case "full":
+				t.full = time.Duration(us) * time.Microsecond
+			}
+		}
+	}
+
+	if !found {
+		return psiTotals{}, fmt.Errorf("parsing pressure %q: no totals", bytes.TrimSpace(data))
+	}
+
+	return t, nil
+}
+
+// stallTracker turns successive readings of one pressure file into a
+// PressureStall.
+type stallTracker struct {
+	first, last      psiTotals
+	firstAt, lastAt  time.Time
+	someMax, fullMax float64
+	seen             bool
+}
+
+// add records a reading taken at at.
+func (s *stallTracker) add(t psiTotals, at time.Time) {
+	if !s.seen {
+		s.first, s.firstAt, s.seen = t, at, true
+	} else if wall := at.Sub(s.lastAt); wall > 0 {
+		s.someMax = max(s.someMax, stallPercent(t.some-s.last.some, wall))
+		s.fullMax = max(s.fullMax, stallPercent(t.full-s.last.full, wall))
+	}
+
+	s.last, s.lastAt = t, at
+}
+
+func (s *stallTracker) stall() PressureStall {
+	wall := s.lastAt.Sub(s.firstAt)
+	if wall <= 0 {
+		return PressureStall{}
+	}
+
+	return PressureStall{
+		SomeAvg: stallPercent(s.last.some-s.first.some, wall),
+		SomeMax: s.someMax,
+		FullAvg: stallPercent(s.last.full-s.first.full, wall),
+		FullMax: s.fullMax,
+	}
+}
+
+// stallPercent is the share of wall that stalled was, capped at 100%
+// since the readings are not taken at exactly the same time.
+func stallPercent(stalled, wall time.Duration) float64 {
+	return min(max(float64(stalled)/float64(wall)*100, 0), 100)
+}
+
+// pressureNote says how much of the run the machine stalled on each
+// resource, or returns "" without --pressure.
+func pressureNote(m Metrics) string {
+	p := m.Pressure
+	if p == nil {
+		return ""
+	}
+
+	note := fmt.Sprintf("pressure: stalled on cpu %.1f%% of the run (peak %.1f%%), memory %.1f%% (peak %.1f%%), io %.1f%% (peak %.1f%%), from %s",
+		p.CPU.SomeAvg, p.CPU.SomeMax, p.Memory.SomeAvg, p.Memory.SomeMax, p.IO.SomeAvg, p.IO.SomeMax, p.Source)
+
+	if max(p.CPU.SomeAvg, p.Memory.SomeAvg, p.IO.SomeAvg) >= pressureWarn {
+		note += "; the machine was contended, so the timing may not be representative"
+	}
+
+	return note
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// pressureResources are the pressure files read, in the order of the
+// fields of Pressure.
+var pressureResources = [3]string{"cpu", "memory", "io"}
+
+func pressureSupported() error {
+	if _, err := os.ReadFile("/proc/pressure/cpu"); err != nil {
+		return fmt.Errorf("%w: %w", errPressureUnsupported, err)
+	}
+
+	return nil
+}
+
+// pressureSource returns the directory of ztime's cgroup v2 if it has
+// pressure files, which inside a container describe the container rather
+// than the host, and /proc/pressure otherwise.
+func pressureSource() string {
+	if data, err := os.ReadFile("/proc/self/cgroup"); err == nil {
+		for _, path := range cgroupPaths(data, "cpu", "", "cpu.pressure") {
+			// The root cgroup's pressure is the machine's.
+			dir := filepath.Dir(path)
+			if filepath.Base(path) != "cpu.pressure" || dir == "/sys/fs/cgroup" || dir == "/sys/fs/cgroup/unified" {
+				continue
+			}
+
+			if _, err := os.Stat(path); err == nil {
+				return dir
+			}
+		}
+	}
+
+	return "/proc/pressure"
+}
+
+// pressureFile is where source keeps the pressure of resource: cgroups
+// name the files after the resource with a ".pressure" suffix.
+func pressureFile(source, resource string) string {
+	if source == "/proc/pressure" {
+		return filepath.Join(source, resource)
+	}
+
+	return filepath.Join(source, resource+".pressure")
+}
+
+// pressureSampler reads the pressure files until stopped.
+type pressureSampler struct {
+	done     chan struct{}
+	stopped  chan struct{}
+	source   string
+	samples  int
+	trackers [3]stallTracker
+}
+
+// samplePressure starts reading the pressure files. It returns nil if
+// enabled is false.
+func samplePressure(enabled bool) *pressureSampler {
+	if !enabled {
+		return nil
+	}
+
+	s := &pressureSampler{done: make(chan struct{}), stopped: make(chan struct{}), source: pressureSource()}
+	s.sample()
+
+	go func() {
+		defer close(s.stopped)
+
+		ticker := time.NewTicker(pressureInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				s.sample()
+			}
+		}
+	}()
+
+	return s
+}
+
+func (s *pressureSampler) sample() {
+	s.samples++
+
+	for i, resource := range pressureResources {
+		//nolint:gosec // Intended behavior: the source is /proc/pressure or from /proc/self/cgroup.
+		data, err := os.ReadFile(pressureFile(s.source, resource))
+		if err != nil {
+			continue
+		}
+
+		if totals, err := parsePSI(data); err == nil {
+			s.trackers[i].add(totals, time.Now())
+		}
+	}
+}
+
+// stop takes a last reading, ends the sampling, and stores the stalls in m.
+func (s *pressureSampler) stop(m *Metrics) {
+	if s == nil {
+		return
+	}
+
+	close(s.done)
+	<-s.stopped
+
+	s.sample()
+
+	m.Pressure = &Pressure{
+		Source:  s.source,
+		Samples: s.samples,
+		CPU:     s.trackers[0].stall(),
+		Memory:  s.trackers[1].stall(),
+		IO:      s.trackers[2].stall(),
+	}
+}
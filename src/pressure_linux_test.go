@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPressureFile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		source, resource, want string
+	}{
+		{"/proc/pressure", "cpu", "/proc/pressure/cpu"},
+		{"/sys/fs/cgroup/user.slice", "io", "/sys/fs/cgroup/user.slice/io.pressure"},
+	}
+
+	for _, tt := range tests {
+		if got := pressureFile(tt.source, tt.resource); got != tt.want {
+			t.Errorf("pressureFile(%q, %q) = %q, want %q", tt.source, tt.resource, got, tt.want)
+		}
+	}
+}
+
+func TestSamplePressure(t *testing.T) {
+	t.Parallel()
+
+	if err := pressureSupported(); err != nil {
+		t.Skipf("no pressure stall information: %v", err)
+	}
+
+	if samplePressure(false) != nil {
+		t.Error("samplePressure(false) != nil")
+	}
+
+	s := samplePressure(true)
+	time.Sleep(2 * pressureInterval)
+
+	var m Metrics
+
+	s.stop(&m)
+
+	if m.Pressure == nil || m.Pressure.Samples < 2 || m.Pressure.Source == "" {
+		t.Errorf("Pressure = %+v, want samples", m.Pressure)
+	}
+}
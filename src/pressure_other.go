@@ -0,0 +1,20 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func pressureSupported() error {
+	return fmt.Errorf("%w on %s", errPressureUnsupported, runtime.GOOS)
+}
+
+type pressureSampler struct{}
+
+func samplePressure(bool) *pressureSampler {
+	return nil
+}
+
+func (*pressureSampler) stop(*Metrics) {}
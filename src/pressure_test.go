@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePSI(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		data    string
+		want    psiTotals
+		wantErr bool
+	}{
+		{
+			"Some and full",
+			"some avg10=2.24 avg60=1.61 avg300=1.07 total=166247731\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=7519\n",
+			psiTotals{some: 166247731 * time.Microsecond, full: 7519 * time.Microsecond},
+			false,
+		},
+		{"Some only", "some avg10=0.00 avg60=0.00 avg300=0.00 total=42\n", psiTotals{some: 42 * time.Microsecond}, false},
+		{"Empty", "", psiTotals{}, true},
+		{"Bad total", "some avg10=0.00 total=x\n", psiTotals{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parsePSI([]byte(tt.data))
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parsePSI() = %+v, %v, want %+v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStallTracker(t *testing.T) {
+	t.Parallel()
+
+	var s stallTracker
+
+	if got := s.stall(); got != (PressureStall{}) {
+		t.Errorf("stall() without readings = %+v, want zero", got)
+	}
+
+	at := time.Unix(1000, 0)
+	s.add(psiTotals{some: time.Second, full: time.Second}, at)
+	s.add(psiTotals{some: time.Second + 50*time.Millisecond, full: time.Second}, at.Add(100*time.Millisecond))
+	s.add(psiTotals{some: time.Second + 60*time.Millisecond, full: time.Second + 10*time.Millisecond}, at.Add(200*time.Millisecond))
+	s.add(psiTotals{some: time.Second + 400*time.Millisecond, full: time.Second + 10*time.Millisecond}, at.Add(300*time.Millisecond))
+
+	got := s.stall()
+	want := PressureStall{SomeAvg: 100, SomeMax: 100, FullAvg: 10.0 / 3, FullMax: 10}
+
+	for _, c := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"SomeAvg", got.SomeAvg, want.SomeAvg}, // 400ms in 300ms, capped
+		{"SomeMax", got.SomeMax, want.SomeMax},
+		{"FullAvg", got.FullAvg, want.FullAvg},
+		{"FullMax", got.FullMax, want.FullMax},
+	} {
+		if diff := c.got - c.want; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestPressureNote(t *testing.T) {
+	t.Parallel()
+
+	if got := pressureNote(Metrics{}); got != "" {
+		t.Errorf("pressureNote() without --pressure = %q, want empty", got)
+	}
+
+	quiet := Metrics{Pressure: &Pressure{Source: "/proc/pressure", CPU: PressureStall{SomeAvg: 1.25, SomeMax: 8}}}
+
+	want := "pressure: stalled on cpu 1.2% of the run (peak 8.0%), memory 0.0% (peak 0.0%), io 0.0% (peak 0.0%), from /proc/pressure"
+	if got := pressureNote(quiet); got != want {
+		t.Errorf("pressureNote() = %q, want %q", got, want)
+	}
+
+	busy := Metrics{Pressure: &Pressure{Source: "/proc/pressure", IO: PressureStall{SomeAvg: pressureWarn}}}
+	if got := pressureNote(busy); !strings.HasSuffix(got, "may not be representative") {
+		t.Errorf("pressureNote() under pressure = %q, want a warning", got)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Actions the privileged helper can perform.
+const (
+	privilegedDropCaches   = "drop-caches"
+	privilegedPerfRecord   = "perf-record"
+	privilegedWatchFiles   = "watch-files"
+	privilegedCreateCgroup = "create-cgroup"
+)
+
+var (
+	errPrivilegedDenied      = errors.New("not allowed")
+	errPrivilegedUnsupported = errors.New("the privileged helper is not supported")
+)
+
+// privilegedCmd is the helper that --sudo-collectors runs through sudo. Each
+// action does one fixed thing, at most for one process, so a sudoers rule
+// can allow exactly these command lines and nothing else:
+//
+//   - drop-caches drops the page cache and exits.
+//   - perf-record records the user's process PID with "perf record",
+//     streaming the data to stdout until ztime closes stdin.
+//   - watch-files watches file accesses with fanotify and, once ztime sends
+//     the command's PID on stdin, reports those of that process tree. Only
+//     the user's own processes are reported.
+//   - create-cgroup delegates a cgroup to the user and moves the calling
+//     ztime, PID, into it, so ztime can create --limit-mem cgroups there.
+type privilegedCmd struct {
+	Action string `arg:"" enum:"drop-caches,perf-record,watch-files,create-cgroup" help:"Privileged action to perform (${enum})."`
+	PID    int    `arg:"" optional:"" help:"Process the action is for: the command with perf-record, ztime itself with create-cgroup."`
+}
+
+// Run performs the action.
+func (c *privilegedCmd) Run() error {
+	switch c.Action {
+	case privilegedDropCaches:
+		return dropCaches()
+	case privilegedPerfRecord:
+		if err := checkSudoCaller(c.PID, false); err != nil {
+			return err
+		}
+
+		return recordPerf(c.PID)
+	case privilegedWatchFiles:
+		return serveFileWatch()
+	case privilegedCreateCgroup:
+		if err := checkSudoCaller(c.PID, true); err != nil {
+			return err
+		}
+
+		return delegateCgroup(c.PID)
+	default:
+		return nil
+	}
+}
+
+// privileged performs action in this process, or through the sudo helper
+// with --sudo-collectors.
+func (c *runCmd) privileged(action string) error {
+	if !c.SudoCollectors {
+		return (&privilegedCmd{Action: action}).Run()
+	}
+
+	return runPrivileged(action)
+}
+
+// sudoHelper returns the command that performs action through sudo. The
+// long-running actions last until their stdin is closed.
+func sudoHelper(action string, args ...string) (*exec.Cmd, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("locating ztime for sudo: %w", err)
+	}
+
+	//nolint:gosec // The helper is this binary and the action comes from a fixed set.
+	return exec.CommandContext(context.Background(), "sudo", append([]string{"--", self, "privileged", action}, args...)...), nil
+}
+
+// runPrivileged performs a one-off action through the sudo helper.
+func runPrivileged(action string, args ...string) error {
+	cmd, err := sudoHelper(action, args...)
+	if err != nil {
+		return err
+	}
+
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+
+	if err := runHelper(cmd); err != nil {
+		return sudoError(cmd, err)
+	}
+
+	return nil
+}
+
+// sudoError describes the failure of a sudo helper.
+func sudoError(cmd *exec.Cmd, err error) error {
+	return fmt.Errorf("sudo %s: %v", strings.Join(cmd.Args[2:], " "), err) //nolint:errorlint // Keep sudo's exit status from being read as the command's.
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// checkSudoCaller makes sure the user who ran sudo may have the helper act
+// on pid: the process must be theirs and, with self, one of the helper's own
+// ancestors, that is, the ztime that started it. Outside sudo the caller is
+// root already and anything goes.
+func checkSudoCaller(pid int, self bool) error {
+	user, ok := os.LookupEnv("SUDO_UID")
+	if !ok {
+		return nil
+	}
+
+	if pid <= 1 {
+		return fmt.Errorf("%w: no process given", errPrivilegedDenied)
+	}
+
+	if uid, ok := processUID(pid); !ok || strconv.Itoa(uid) != user {
+		return fmt.Errorf("%w: process %d does not belong to user %s", errPrivilegedDenied, pid, user)
+	}
+
+	if self && !slices.Contains(processAncestry(os.Getpid()), pid) {
+		return fmt.Errorf("%w: process %d did not start the helper", errPrivilegedDenied, pid)
+	}
+
+	return nil
+}
+
+// sudoUser returns the user and group IDs of the user who ran sudo, or
+// false outside sudo.
+func sudoUser() (int, int, bool) {
+	uid, err := strconv.Atoi(os.Getenv("SUDO_UID"))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	gid, err := strconv.Atoi(os.Getenv("SUDO_GID"))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return uid, gid, true
+}
+
+// processUID returns the real user ID of pid.
+func processUID(pid int) (int, bool) {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/status")
+	if err != nil {
+		return 0, false
+	}
+
+	return parseStatusUID(data)
+}
+
+// parseStatusUID returns the real user ID from the "Uid:" line of
+// /proc/<pid>/status, which lists the real, effective, saved, and file
+// system IDs.
+func parseStatusUID(data []byte) (int, bool) {
+	for line := range strings.Lines(string(data)) {
+		if rest, ok := strings.CutPrefix(line, "Uid:"); ok {
+			fields := strings.Fields(rest)
+			if len(fields) == 0 {
+				return 0, false
+			}
+
+			uid, err := strconv.Atoi(fields[0])
+
+			return uid, err == nil
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestParseStatusUID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		status string
+		uid    int
+		ok     bool
+	}{
+		{"user", "Name:\tmake\nUid:\t1000\t1000\t1000\t1000\nGid:\t1000\t1000\t1000\t1000\n", 1000, true},
+		{"setuid", "Uid:\t1000\t0\t0\t0\n", 1000, true},
+		{"no uid", "Name:\tmake\n", 0, false},
+		{"empty uid", "Uid:\n", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if uid, ok := parseStatusUID([]byte(tt.status)); uid != tt.uid || ok != tt.ok {
+				t.Errorf("parseStatusUID() = %d, %v; want %d, %v", uid, ok, tt.uid, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCheckSudoCaller(t *testing.T) {
+	user := strconv.Itoa(os.Getuid())
+	self := os.Getpid()
+
+	// A child of the test is the user's but did not start it.
+	child := exec.CommandContext(t.Context(), "sleep", "10")
+	if err := child.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = child.Process.Kill()
+		_ = child.Wait()
+	})
+
+	tests := []struct {
+		name    string
+		sudoUID string
+		pid     int
+		self    bool
+		denied  bool
+	}{
+		{"own process", user, self, false, false},
+		{"own ancestor", user, self, true, false},
+		{"no process", user, 0, false, true},
+		{"other user", user + "1", self, false, true},
+		{"not an ancestor", user, child.Process.Pid, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SUDO_UID", tt.sudoUID)
+
+			if err := checkSudoCaller(tt.pid, tt.self); errors.Is(err, errPrivilegedDenied) != tt.denied {
+				t.Errorf("checkSudoCaller() error = %v, want denied %v", err, tt.denied)
+			}
+		})
+	}
+}
+
+func TestStartSudoPerf(t *testing.T) {
+	// The fake helper streams "data" once ztime closes its stdin.
+	log := fakeSudoScript(t, "cat > /dev/null\necho data")
+	path := filepath.Join(t.TempDir(), "perf.data")
+
+	record, stdin, err := startSudoPerf(42, path)
+	if err != nil {
+		t.Fatalf("startSudoPerf() error = %v", err)
+	}
+
+	_ = stdin.Close()
+
+	if err := waitHelper(record); err != nil {
+		t.Fatalf("waiting for the helper: %v", err)
+	}
+
+	if data, err := os.ReadFile(path); err != nil || string(data) != "data\n" {
+		t.Errorf("perf data = %q, %v; want the helper's output", data, err)
+	}
+
+	calls, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exe, _ := os.Executable()
+	if want := "sudo -- " + exe + " privileged perf-record 42\n"; string(calls) != want {
+		t.Errorf("sudo ran with %q, want %q", calls, want)
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Only --drop-caches runs through the helper outside Linux; the collectors
+// of the other actions are Linux-only.
+
+func checkSudoCaller(int, bool) error { return nil }
+
+func recordPerf(int) error { return privilegedUnsupported() }
+
+func serveFileWatch() error { return privilegedUnsupported() }
+
+func delegateCgroup(int) error { return privilegedUnsupported() }
+
+func privilegedUnsupported() error {
+	return fmt.Errorf("%w on %s", errPrivilegedUnsupported, runtime.GOOS)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestPrivilegedSudo(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake sudo is a shell script")
+	}
+
+	log := fakeSudo(t, 0)
+
+	if err := (&runCmd{SudoCollectors: true}).privileged(privilegedDropCaches); err != nil {
+		t.Fatalf("privileged() error = %v", err)
+	}
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	self, _ := os.Executable()
+	if want := "sudo -- " + self + " privileged drop-caches\n"; string(data) != want {
+		t.Errorf("sudo ran with %q, want %q", data, want)
+	}
+}
+
+func TestPrivilegedSudoFails(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake sudo is a shell script")
+	}
+
+	fakeSudo(t, 1)
+
+	err := (&runCmd{SudoCollectors: true}).privileged(privilegedDropCaches)
+	if err == nil || !strings.HasPrefix(err.Error(), "sudo ") {
+		t.Errorf("privileged() error = %v, want sudo's failure", err)
+	}
+}
@@ -0,0 +1,122 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// processGroup runs the command in a process group of its own with
+// --kill-children, so that signals and the final cleanup reach everything
+// it started.
+type processGroup struct {
+	cmd   *exec.Cmd
+	grace time.Duration
+	debug *debugLog
+}
+
+// newProcessGroup makes cmd start a new process group. A --pty session is
+// one already.
+func newProcessGroup(cmd *exec.Cmd, grace time.Duration, debug *debugLog) (*processGroup, error) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	if !cmd.SysProcAttr.Setsid {
+		cmd.SysProcAttr.Setpgid = true
+	}
+
+	return &processGroup{cmd: cmd, grace: grace, debug: debug}, nil
+}
+
+// finish terminates what is left of the group once the command has exited:
+// SIGTERM, and SIGKILL for whatever is still there after the grace period.
+func (g *processGroup) finish(m *Metrics, _ time.Time) {
+	if g.cmd.ProcessState == nil {
+		return
+	}
+
+	pgid := g.cmd.Process.Pid
+	if !groupAlive(pgid) {
+		return
+	}
+
+	g.debug.printf("terminating what is left of process group %d", pgid)
+
+	// Stopped processes only act on SIGTERM once continued.
+	_ = unix.Kill(-pgid, unix.SIGTERM)
+	_ = unix.Kill(-pgid, unix.SIGCONT)
+
+	deadline := time.Now().Add(g.grace)
+	for groupAlive(pgid) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if groupAlive(pgid) {
+		g.debug.printf("killing process group %d after %s", pgid, g.grace)
+
+		_ = unix.Kill(-pgid, unix.SIGKILL)
+		for i := 0; i < 100 && groupAlive(pgid); i++ { //nolint:intrange // Polls until the group is gone.
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	m.ChildrenKilled = true
+}
+
+// killTimedOut ends a command that ran past --timeout as timeout(1) does:
+// SIGTERM, and SIGKILL if it has not exited after --kill-grace, sent to
+// its whole group with --kill-children.
+func killTimedOut(cmd *exec.Cmd, _ *jobObject, opts runOptions, exited <-chan struct{}) {
+	send := func(sig syscall.Signal) {
+		if opts.killChildren {
+			_ = signalGroup(cmd.Process, sig)
+		} else {
+			_ = cmd.Process.Signal(sig)
+		}
+	}
+
+	opts.debug.printf("sending SIGTERM after --timeout")
+
+	// Stopped processes only act on SIGTERM once continued.
+	send(unix.SIGTERM)
+	send(unix.SIGCONT)
+
+	select {
+	case <-exited:
+	case <-time.After(opts.killGrace):
+		opts.debug.printf("sending SIGKILL after %s", opts.killGrace)
+		send(unix.SIGKILL)
+	}
+}
+
+// groupAlive reports whether the process group pgid has members, after
+// reaping those that are ztime's own children, which they become when
+// orphaned while ztime is a subreaper.
+func groupAlive(pgid int) bool {
+	var status unix.WaitStatus
+
+	for {
+		pid, err := unix.Wait4(-pgid, &status, unix.WNOHANG, nil)
+		if err != nil || pid <= 0 {
+			break
+		}
+	}
+
+	return unix.Kill(-pgid, 0) == nil
+}
+
+// signalGroup sends sig to the process group led by p.
+func signalGroup(p *os.Process, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return p.Signal(sig)
+	}
+
+	return unix.Kill(-p.Pid, s)
+}
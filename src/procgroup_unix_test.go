@@ -0,0 +1,92 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestRunCommandKillChildren(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	tests := []struct {
+		name   string
+		script string
+		want   bool
+	}{
+		{"nothing left", "true", false},
+		{"background child", "sleep 30 & exit 0", true},
+		{"child ignoring SIGTERM", "trap '' TERM; sleep 30 & exit 0", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			start := time.Now()
+
+			m, err := runCommand([]string{"sh", "-c", tt.script}, runOptions{killChildren: true, killGrace: 100 * time.Millisecond})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if m.ChildrenKilled != tt.want {
+				t.Errorf("ChildrenKilled = %v, want %v", m.ChildrenKilled, tt.want)
+			}
+
+			if took := time.Since(start); took > 10*time.Second {
+				t.Errorf("run took %v; the group was not cleaned up", took)
+			}
+		})
+	}
+}
+
+func TestRunCommandTimeout(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	tests := []struct {
+		name   string
+		script string
+		signal string
+	}{
+		{"exits on SIGTERM", "sleep 30", "SIGTERM"},
+		{"ignores SIGTERM", "trap '' TERM; sleep 30 & wait", "SIGKILL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := runOptions{timeout: 100 * time.Millisecond, killChildren: true, killGrace: 100 * time.Millisecond}
+
+			m, err := runCommand([]string{"sh", "-c", tt.script}, opts)
+			if !errors.Is(err, errTimedOut) {
+				t.Fatalf("runCommand() error = %v, want %v", err, errTimedOut)
+			}
+
+			if !m.TimedOut || m.TermSignal != tt.signal {
+				t.Errorf("timed out %v by %q, want %s", m.TimedOut, m.TermSignal, tt.signal)
+			}
+
+			if m.ElapsedTime > 10*time.Second {
+				t.Errorf("run took %v; the timeout did not end it", m.ElapsedTime)
+			}
+		})
+	}
+
+	m, err := runCommand([]string{"true"}, runOptions{timeout: time.Minute})
+	if err != nil || m.TimedOut {
+		t.Errorf("fast run: timed out %v, error %v", m.TimedOut, err)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"time"
+)
+
+// processGroup does nothing: with --kill-children, the command's Job
+// Object terminates what is left of it.
+type processGroup struct{}
+
+func newProcessGroup(*exec.Cmd, time.Duration, *debugLog) (*processGroup, error) {
+	return &processGroup{}, nil
+}
+
+func (*processGroup) finish(*Metrics, time.Time) {}
+
+// signalGroup sends sig to p alone: Windows has no process groups to signal.
+func signalGroup(p *os.Process, sig os.Signal) error {
+	return p.Signal(sig)
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// profileFrequency is how many times a second --profile-cpu samples the
+// command's stacks. 99 rather than 100 avoids sampling in lockstep with
+// timers.
+const profileFrequency = 99
+
+var errProfileUnsupported = errors.New("--profile-cpu is not supported")
+
+// CPUProfile describes the stack samples written with --profile-cpu.
+type CPUProfile struct {
+	File     string `json:"file"`
+	Profiler string `json:"profiler"`             // perf or sample
+	Samples  int    `json:"samples" unit:"count"` // stack samples taken
+	Stacks   int    `json:"stacks" unit:"count"`  // distinct stacks among them
+}
+
+// cpuProfiler samples a running command with an external profiler.
+type cpuProfiler struct {
+	path    string // folded stacks go here
+	tool    string
+	collect func() ([]byte, error) // stops the profiler and returns its output
+}
+
+// profileCPU starts sampling pid for --profile-cpu, through the sudo helper
+// with sudo. It returns nil if path is empty or the profiler could not be
+// started, which is reported but does not fail the run.
+func profileCPU(path string, pid int, sudo bool) *cpuProfiler {
+	if path == "" {
+		return nil
+	}
+
+	tool, collect, err := startProfiler(pid, sudo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ztime: profiling: %v\n", err)
+
+		return nil
+	}
+
+	return &cpuProfiler{path: path, tool: tool, collect: collect}
+}
+
+// stop ends the sampling, folds the stacks, writes them to the --profile-cpu
+// file, and describes them in m.
+func (p *cpuProfiler) stop(m *Metrics) {
+	if p == nil {
+		return
+	}
+
+	raw, err := p.collect()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ztime: profiling: %v\n", err)
+
+		return
+	}
+
+	var stacks map[string]int
+	if p.tool == "perf" {
+		stacks = foldPerfScript(bytes.NewReader(raw))
+	} else {
+		stacks = foldSampleReport(bytes.NewReader(raw))
+	}
+
+	if err := writeFolded(p.path, stacks); err != nil {
+		fmt.Fprintf(os.Stderr, "ztime: profiling: %v\n", err)
+
+		return
+	}
+
+	profile := &CPUProfile{File: p.path, Profiler: p.tool, Stacks: len(stacks)}
+	for _, n := range stacks {
+		profile.Samples += n
+	}
+
+	m.CPUProfile = profile
+}
+
+// writeFolded writes one "frame;frame;... count" line per stack, outermost
+// frame first, as flamegraph.pl and speedscope read them.
+func writeFolded(path string, stacks map[string]int) error {
+	var buf bytes.Buffer
+
+	for _, stack := range slices.Sorted(maps.Keys(stacks)) {
+		fmt.Fprintf(&buf, "%s %d\n", stack, stacks[stack])
+	}
+
+	//nolint:gosec // Intended behavior: the profile is for the user to read.
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// foldPerfScript counts the stacks in "perf script" output. Each sample is
+// a header line starting with the command name and PID, followed by one
+// tab-indented line per frame, innermost first, and a blank line. The
+// command name becomes the root frame.
+func foldPerfScript(r io.Reader) map[string]int {
+	stacks := map[string]int{}
+
+	var frames []string
+
+	flush := func() {
+		if len(frames) > 1 {
+			slices.Reverse(frames[1:])
+			stacks[strings.Join(frames, ";")]++
+		}
+
+		frames = frames[:0]
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+		case line[0] != '\t':
+			flush()
+
+			// The command name may contain spaces; the PID follows it.
+			fields := strings.Fields(line)
+			for i := 1; i < len(fields); i++ {
+				if isPIDField(fields[i]) {
+					frames = append(frames, strings.Join(fields[:i], " "))
+
+					break
+				}
+			}
+		case len(frames) > 0:
+			frames = append(frames, perfFrame(strings.TrimSpace(line)))
+		}
+	}
+
+	flush()
+
+	return stacks
+}
+
+// isPIDField reports whether s is a "PID" or "PID/TID" field.
+func isPIDField(s string) bool {
+	pid, _, _ := strings.Cut(s, "/")
+	_, err := strconv.Atoi(pid)
+
+	return err == nil
+}
+
+// perfFrame names a "ADDRESS SYMBOL+OFFSET (DSO)" frame line by its symbol,
+// or by its DSO when perf could not resolve the symbol.
+func perfFrame(line string) string {
+	_, rest, _ := strings.Cut(line, " ")
+	rest = strings.TrimSpace(rest)
+
+	dso := ""
+	if i := strings.LastIndex(rest, " ("); i >= 0 {
+		dso = strings.TrimSuffix(rest[i+2:], ")")
+		rest = rest[:i]
+	}
+
+	if i := strings.LastIndex(rest, "+0x"); i > 0 {
+		rest = rest[:i]
+	}
+
+	if rest == "" || rest == "[unknown]" {
+		if dso != "" && dso != "[unknown]" {
+			return "[" + filepath.Base(dso) + "]"
+		}
+
+		return "[unknown]"
+	}
+
+	return rest
+}
+
+// sampleFrame is a node of the call tree in a "sample" report.
+type sampleFrame struct {
+	indent   int
+	name     string
+	count    int
+	children int // samples in the frames below this one
+}
+
+// foldSampleReport turns the call tree in the "Call graph:" section of a
+// macOS "sample" report into stacks. Each line is a frame indented below
+// its caller and prefixed with the samples taken in it or below it, so a
+// frame's own samples are its count minus its children's.
+func foldSampleReport(r io.Reader) map[string]int {
+	stacks := map[string]int{}
+
+	var path []sampleFrame
+
+	pop := func(indent int) {
+		for len(path) > 0 && path[len(path)-1].indent >= indent {
+			top := path[len(path)-1]
+			if self := top.count - top.children; self > 0 {
+				names := make([]string, len(path))
+				for i, f := range path {
+					names[i] = f.name
+				}
+
+				stacks[strings.Join(names, ";")] += self
+			}
+
+			path = path[:len(path)-1]
+		}
+	}
+
+	inGraph := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if !inGraph {
+			inGraph = strings.HasPrefix(line, "Call graph:")
+
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+
+		frame, ok := parseSampleLine(line)
+		if !ok {
+			continue
+		}
+
+		pop(frame.indent)
+
+		if len(path) > 0 {
+			path[len(path)-1].children += frame.count
+		}
+
+		path = append(path, frame)
+	}
+
+	pop(0)
+
+	return stacks
+}
+
+// parseSampleLine reads a call graph line such as
+// "    +   2384 main  (in tool) + 40  [0x1000034a8]".
+func parseSampleLine(line string) (sampleFrame, bool) {
+	indent := strings.IndexFunc(line, func(r rune) bool { return !strings.ContainsRune(" +!:|", r) })
+	if indent < 0 {
+		return sampleFrame{}, false
+	}
+
+	countText, rest, _ := strings.Cut(line[indent:], " ")
+
+	count, err := strconv.Atoi(countText)
+	if err != nil {
+		return sampleFrame{}, false
+	}
+
+	name, _, _ := strings.Cut(strings.TrimSpace(rest), "  ")
+
+	return sampleFrame{indent: indent, name: name, count: count}, true
+}
+
+// profileNote says where the profile went, or returns "" without one.
+func profileNote(m Metrics) string {
+	if m.CPUProfile == nil {
+		return ""
+	}
+
+	p := m.CPUProfile
+
+	return fmt.Sprintf("cpu profile: %d samples in %d stacks from %s written to %s", p.Samples, p.Stacks, p.Profiler, p.File)
+}
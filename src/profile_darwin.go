@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	// sampleIntervalMS is the sampling interval of "sample", close to
+	// profileFrequency.
+	sampleIntervalMS = "10"
+	// sampleExitWait is how long "sample" gets to notice that the command
+	// exited and write its report before it is interrupted.
+	sampleExitWait = 5 * time.Second
+)
+
+func profileSupported() error {
+	if _, err := exec.LookPath("sample"); err != nil {
+		return fmt.Errorf("%w: sample is not installed", errProfileUnsupported)
+	}
+
+	return nil
+}
+
+// startProfiler samples pid with "sample" until it exits, and then returns
+// the report.
+func startProfiler(pid int, _ bool) (string, func() ([]byte, error), error) {
+	dir, err := os.MkdirTemp("", "ztime-profile-")
+	if err != nil {
+		return "", nil, err
+	}
+
+	report := filepath.Join(dir, "sample.txt")
+
+	// sample needs a duration; -mayDie makes it stop when the process does.
+	//nolint:gosec // Intended behavior: the PID is the command's.
+	cmd := exec.CommandContext(context.Background(), "sample", strconv.Itoa(pid), "86400", sampleIntervalMS,
+		"-mayDie", "-file", report)
+
+	if err := startHelper(cmd); err != nil {
+		_ = os.RemoveAll(dir)
+
+		return "", nil, err
+	}
+
+	collect := func() ([]byte, error) {
+		defer os.RemoveAll(dir)
+
+		done := make(chan error, 1)
+		go func() { done <- waitHelper(cmd) }()
+
+		select {
+		case <-done:
+		case <-time.After(sampleExitWait):
+			_ = cmd.Process.Signal(os.Interrupt)
+			<-done
+		}
+
+		data, err := os.ReadFile(report)
+		if err != nil {
+			return nil, fmt.Errorf("sample: %w", err)
+		}
+
+		return data, nil
+	}
+
+	return "sample", collect, nil
+}
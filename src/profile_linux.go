@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+func profileSupported() error {
+	if _, err := exec.LookPath("perf"); err != nil {
+		return fmt.Errorf("%w: perf is not installed", errProfileUnsupported)
+	}
+
+	return nil
+}
+
+// startProfiler records pid and the processes it starts with "perf record"
+// until collected, and then returns the samples as "perf script" prints
+// them. With sudo, perf records through the helper's perf-record action;
+// only "perf record" needs the privileges.
+func startProfiler(pid int, sudo bool) (string, func() ([]byte, error), error) {
+	dir, err := os.MkdirTemp("", "ztime-profile-")
+	if err != nil {
+		return "", nil, err
+	}
+
+	data := filepath.Join(dir, "perf.data")
+
+	var (
+		record *exec.Cmd
+		stdin  io.WriteCloser
+		stderr bytes.Buffer
+	)
+
+	if sudo {
+		record, stdin, err = startSudoPerf(pid, data)
+	} else {
+		//nolint:gosec // Intended behavior: the PID is the command's.
+		record = exec.CommandContext(context.Background(), "perf", "record", "--quiet", "-g",
+			"-F", strconv.Itoa(profileFrequency), "-p", strconv.Itoa(pid), "-o", data)
+		record.Stderr = &stderr
+		err = startHelper(record)
+	}
+
+	if err != nil {
+		_ = os.RemoveAll(dir)
+
+		return "", nil, err
+	}
+
+	collect := func() ([]byte, error) {
+		defer os.RemoveAll(dir)
+
+		if sudo {
+			_ = stdin.Close()
+
+			if err := waitHelper(record); err != nil {
+				return nil, sudoError(record, err)
+			}
+		} else {
+			// perf record writes out what it has when interrupted.
+			_ = record.Process.Signal(syscall.SIGINT)
+
+			if err := waitHelper(record); err != nil && !strings.Contains(err.Error(), "interrupt") {
+				return nil, fmt.Errorf("perf record: %w: %s", err, strings.TrimSpace(stderr.String()))
+			}
+		}
+
+		//nolint:gosec // Intended behavior: the file is the one perf just wrote.
+		out, err := helperOutput(exec.CommandContext(context.Background(), "perf", "script", "-i", data), false)
+		if err != nil {
+			return nil, fmt.Errorf("perf script: %w", err)
+		}
+
+		return out, nil
+	}
+
+	return "perf", collect, nil
+}
+
+// startSudoPerf starts the helper's perf-record action on pid, writing its
+// data to path. It records until the returned stdin is closed.
+func startSudoPerf(pid int, path string) (*exec.Cmd, io.WriteCloser, error) {
+	record, err := sudoHelper(privilegedPerfRecord, strconv.Itoa(pid))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out, err := os.Create(path) //nolint:gosec // Intended behavior: the path is ztime's own temporary file.
+	if err != nil {
+		return nil, nil, err
+	}
+	defer out.Close()
+
+	record.Stdout = out
+	record.Stderr = os.Stderr
+
+	stdin, err := record.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := startHelper(record); err != nil {
+		return nil, nil, sudoError(record, err)
+	}
+
+	return record, stdin, nil
+}
+
+// recordPerf is the perf-record action of the privileged helper. It runs
+// "perf record" on pid with its data on stdout until stdin is closed.
+func recordPerf(pid int) error {
+	//nolint:gosec // Intended behavior: the PID was checked to be the user's.
+	record := exec.CommandContext(context.Background(), "perf", "record", "--quiet", "-g",
+		"-F", strconv.Itoa(profileFrequency), "-p", strconv.Itoa(pid), "-o", "-")
+	record.Stdout = os.Stdout
+	record.Stderr = os.Stderr
+
+	if err := record.Start(); err != nil {
+		return fmt.Errorf("perf record: %w", err)
+	}
+
+	_, _ = io.Copy(io.Discard, os.Stdin)
+
+	// perf record writes out what it has when interrupted.
+	_ = record.Process.Signal(syscall.SIGINT)
+
+	if err := record.Wait(); err != nil && !strings.Contains(err.Error(), "interrupt") {
+		return fmt.Errorf("perf record: %w", err)
+	}
+
+	return nil
+}
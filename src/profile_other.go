@@ -0,0 +1,16 @@
+//go:build !linux && !darwin
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func profileSupported() error {
+	return fmt.Errorf("%w on %s", errProfileUnsupported, runtime.GOOS)
+}
+
+func startProfiler(int, bool) (string, func() ([]byte, error), error) {
+	return "", nil, profileSupported()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFoldPerfScript(t *testing.T) {
+	t.Parallel()
+
+	script := "make 4242 [001] 81.250000:   10101010 cpu-clock:pppH: \n" +
+		"\t    55d4c0a1b2c3 compile+0x13 (/usr/bin/make)\n" +
+		"\t    55d4c0a1b000 main+0x2a (/usr/bin/make)\n" +
+		"\t    7f00aa001234 __libc_start_main+0x80 (/usr/lib/libc.so.6)\n" +
+		"\n" +
+		"make 4242 [003] 81.260000:   10101010 cpu-clock:pppH: \n" +
+		"\t    55d4c0a1b2c3 compile+0x13 (/usr/bin/make)\n" +
+		"\t    55d4c0a1b000 main+0x2a (/usr/bin/make)\n" +
+		"\t    7f00aa001234 __libc_start_main+0x80 (/usr/lib/libc.so.6)\n" +
+		"\n" +
+		"     Web Content 4250/4251 [000] 81.270000:   10101010 cpu-clock:pppH: \n" +
+		"\t    7f00aa00ffff [unknown] (/usr/lib/libxul.so)\n" +
+		"\t               0 [unknown] ([unknown])\n" +
+		"\n" +
+		"make 4242 [001] 81.280000:   10101010 cpu-clock:pppH: \n"
+
+	want := map[string]int{
+		"make;__libc_start_main;main;compile": 2,
+		"Web Content;[unknown];[libxul.so]":   1,
+	}
+
+	if got := foldPerfScript(strings.NewReader(script)); !reflect.DeepEqual(got, want) {
+		t.Errorf("foldPerfScript() = %v, want %v", got, want)
+	}
+}
+
+func TestFoldSampleReport(t *testing.T) {
+	t.Parallel()
+
+	report := `Analysis of sampling tool (pid 4242) every 10 milliseconds
+Process:         tool [4242]
+
+Call graph:
+    100 Thread_1   DispatchQueue_1: com.apple.main-thread  (serial)
+    + 100 start  (in dyld) + 1903  [0x1874e0274]
+    +   100 main  (in tool) + 40  [0x1000034a8]
+    +     70 compile  (in tool) + 20  [0x100003400]
+    +     ! 60 parse  (in tool) + 8  [0x100003300]
+    +     20 link  (in tool) + 12  [0x100003500]
+    10 Thread_2
+    + 10 worker  (in tool) + 4  [0x100003600]
+
+Total number in stack (recursive counted multiple times):
+    100 main  (in tool) + 40  [0x1000034a8]
+`
+
+	want := map[string]int{
+		"Thread_1;start;main":               10,
+		"Thread_1;start;main;compile":       10,
+		"Thread_1;start;main;compile;parse": 60,
+		"Thread_1;start;main;link":          20,
+		"Thread_2;worker":                   10,
+	}
+
+	if got := foldSampleReport(strings.NewReader(report)); !reflect.DeepEqual(got, want) {
+		t.Errorf("foldSampleReport() = %v, want %v", got, want)
+	}
+}
+
+func TestWriteFolded(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "cpu.folded")
+	if err := writeFolded(path, map[string]int{"make;main;link": 3, "make;main;compile": 7}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "make;main;compile 7\nmake;main;link 3\n"; string(data) != want {
+		t.Errorf("file = %q, want %q", data, want)
+	}
+}
+
+func TestProfileNote(t *testing.T) {
+	t.Parallel()
+
+	if got := profileNote(Metrics{}); got != "" {
+		t.Errorf("profileNote() = %q, want \"\"", got)
+	}
+
+	m := Metrics{CPUProfile: &CPUProfile{File: "cpu.folded", Profiler: "perf", Samples: 812, Stacks: 96}}
+	if got, want := profileNote(m), "cpu profile: 812 samples in 96 stacks from perf written to cpu.folded"; got != want {
+		t.Errorf("profileNote() = %q, want %q", got, want)
+	}
+}
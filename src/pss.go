@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pssInterval is how often --pss measures the process tree.
+const pssInterval = 100 * time.Millisecond
+
+var errPSSUnsupported = errors.New("--pss is not supported")
+
+// memoryShare is the memory of a set of processes in kilobytes. PSS counts
+// each shared page divided by the number of processes mapping it, and USS
+// only the pages private to one process, so unlike RSS they can be summed
+// over a process tree without counting shared libraries many times.
+type memoryShare struct {
+	pss   int64
+	uss   int64
+	procs int
+}
+
+// pssSampler tracks the peak PSS and USS of a process and its descendants
+// until stopped.
+type pssSampler struct {
+	done    chan struct{}
+	stopped chan struct{}
+	peak    memoryShare
+	samples int
+}
+
+// samplePSS starts measuring the tree under pid. It returns nil if enabled
+// is false.
+func samplePSS(enabled bool, pid int) *pssSampler {
+	if !enabled {
+		return nil
+	}
+
+	s := &pssSampler{done: make(chan struct{}), stopped: make(chan struct{})}
+
+	go func() {
+		defer close(s.stopped)
+
+		ticker := time.NewTicker(pssInterval)
+		defer ticker.Stop()
+
+		for {
+			if share, ok := sampleTreeMemory(pid); ok {
+				s.samples++
+				s.peak.pss = max(s.peak.pss, share.pss)
+				s.peak.uss = max(s.peak.uss, share.uss)
+				s.peak.procs = max(s.peak.procs, share.procs)
+			}
+
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return s
+}
+
+// stop ends the sampling and stores the peaks in m.
+func (s *pssSampler) stop(m *Metrics) {
+	if s == nil {
+		return
+	}
+
+	close(s.done)
+	<-s.stopped
+
+	m.PeakPSS = s.peak.pss
+	m.PeakUSS = s.peak.uss
+	m.PeakProcs = s.peak.procs
+	m.PSSSamples = s.samples
+}
+
+// parseSmapsRollup returns the PSS and USS in kilobytes from the contents of
+// /proc/<pid>/smaps_rollup.
+func parseSmapsRollup(data []byte) (int64, int64, bool) {
+	var (
+		pss, uss int64
+		found    bool
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+
+		kb, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(value), " kB"), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		switch key {
+		case "Pss":
+			pss, found = kb, true
+		case "Private_Clean", "Private_Dirty", "Private_Hugetlb":
+			uss += kb
+		}
+	}
+
+	return pss, uss, found
+}
+
+// descendants returns root and every process below it, given the parent of
+// each process.
+func descendants(root int, parents map[int]int) []int {
+	children := make(map[int][]int, len(parents))
+	for pid, ppid := range parents {
+		children[ppid] = append(children[ppid], pid)
+	}
+
+	tree := []int{root}
+
+	//nolint:intrange // The tree grows while it is walked.
+	for i := 0; i < len(tree); i++ {
+		tree = append(tree, children[tree[i]]...)
+	}
+
+	return tree
+}
+
+// pssNote describes the peak PSS and USS of the run, or returns "" without
+// --pss.
+func pssNote(m Metrics) string {
+	if m.PSSSamples == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("memory: peak PSS %s, USS %s across up to %d processes (max RSS %s)",
+		humanBytes(m.PeakPSS*1024), humanBytes(m.PeakUSS*1024), m.PeakProcs, humanBytes(m.MaxRSS*1024))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"strconv"
+)
+
+func pssSupported() error {
+	return nil
+}
+
+// processTree returns pid and its descendants as /proc lists them now.
+func processTree(pid int) ([]int, bool) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, false
+	}
+
+	parents := make(map[int]int, len(entries))
+
+	for _, entry := range entries {
+		child, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		if _, _, ppid, ok := readStat(child); ok {
+			parents[child] = ppid
+		}
+	}
+
+	return descendants(pid, parents), true
+}
+
+// sampleTreeMemory sums the PSS and USS of pid and its descendants.
+func sampleTreeMemory(pid int) (memoryShare, bool) {
+	tree, ok := processTree(pid)
+	if !ok {
+		return memoryShare{}, false
+	}
+
+	var share memoryShare
+
+	for _, p := range tree {
+		data, err := os.ReadFile("/proc/" + strconv.Itoa(p) + "/smaps_rollup")
+		if err != nil {
+			continue
+		}
+
+		if pss, uss, ok := parseSmapsRollup(data); ok {
+			share.pss += pss
+			share.uss += uss
+			share.procs++
+		}
+	}
+
+	return share, share.procs > 0
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func pssSupported() error {
+	return fmt.Errorf("%w on %s", errPSSUnsupported, runtime.GOOS)
+}
+
+func sampleTreeMemory(int) (memoryShare, bool) {
+	return memoryShare{}, false
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestParseSmapsRollup(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`55c24bb62000-7ffc087bf000 ---p 00000000 00:00 0                          [rollup]
+Rss:                1448 kB
+Pss:                 488 kB
+Pss_Dirty:           104 kB
+Shared_Clean:       1300 kB
+Private_Clean:        44 kB
+Private_Dirty:       104 kB
+Private_Hugetlb:       2 kB
+`)
+
+	pss, uss, ok := parseSmapsRollup(data)
+	if !ok || pss != 488 || uss != 150 {
+		t.Errorf("parseSmapsRollup() = %d, %d, %v; want 488, 150, true", pss, uss, ok)
+	}
+
+	if _, _, ok := parseSmapsRollup([]byte("Rss: 12 kB\n")); ok {
+		t.Error("parseSmapsRollup() without Pss succeeded")
+	}
+}
+
+func TestDescendants(t *testing.T) {
+	t.Parallel()
+
+	parents := map[int]int{10: 1, 11: 10, 12: 10, 13: 11, 20: 1, 21: 20}
+
+	got := descendants(10, parents)
+	slices.Sort(got)
+
+	if want := []int{10, 11, 12, 13}; !reflect.DeepEqual(got, want) {
+		t.Errorf("descendants(10) = %v, want %v", got, want)
+	}
+}
+
+func TestPSSNote(t *testing.T) {
+	t.Parallel()
+
+	if note := pssNote(Metrics{}); note != "" {
+		t.Errorf("pssNote() without samples = %q, want none", note)
+	}
+
+	m := Metrics{MaxRSS: 60 << 10, PeakPSS: 90 << 10, PeakUSS: 85 << 10, PeakProcs: 3, PSSSamples: 4}
+	if got, want := pssNote(m), "memory: peak PSS 90.0 MiB, USS 85.0 MiB across up to 3 processes (max RSS 60.0 MiB)"; got != want {
+		t.Errorf("pssNote() = %q, want %q", got, want)
+	}
+}
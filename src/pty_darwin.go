@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ioctlGetTermios = unix.TIOCGETA
+	ioctlSetTermios = unix.TIOCSETA
+)
+
+// openPTY allocates a pseudo-terminal pair through /dev/ptmx, like
+// posix_openpt(3), grantpt(3), and unlockpt(3).
+func openPTY() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening /dev/ptmx: %w", err)
+	}
+
+	var name string
+
+	err = controlFile(master, func(fd int) error {
+		if err := unix.IoctlSetInt(fd, unix.TIOCPTYGRANT, 0); err != nil {
+			return fmt.Errorf("granting pseudo-terminal: %w", err)
+		}
+
+		if err := unix.IoctlSetInt(fd, unix.TIOCPTYUNLK, 0); err != nil {
+			return fmt.Errorf("unlocking pseudo-terminal: %w", err)
+		}
+
+		buf := make([]byte, 128)
+
+		//nolint:gosec // TIOCPTYGNAME writes at most 128 bytes into buf.
+		if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(unix.TIOCPTYGNAME), uintptr(unsafe.Pointer(&buf[0]))); errno != 0 {
+			return fmt.Errorf("getting pseudo-terminal name: %w", errno)
+		}
+
+		name = unix.ByteSliceToString(buf)
+
+		return nil
+	})
+	if err != nil {
+		_ = master.Close()
+
+		return nil, nil, err
+	}
+
+	slave, err := os.OpenFile(name, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		_ = master.Close()
+
+		return nil, nil, fmt.Errorf("opening pseudo-terminal: %w", err)
+	}
+
+	return master, slave, nil
+}
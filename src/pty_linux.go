@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ioctlGetTermios = unix.TCGETS
+	ioctlSetTermios = unix.TCSETS
+)
+
+// openPTY allocates a pseudo-terminal pair through /dev/ptmx.
+func openPTY() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening /dev/ptmx: %w", err)
+	}
+
+	var n uint32
+
+	err = controlFile(master, func(fd int) error {
+		if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+			return fmt.Errorf("unlocking pseudo-terminal: %w", err)
+		}
+
+		n, err = unix.IoctlGetUint32(fd, unix.TIOCGPTN)
+		if err != nil {
+			return fmt.Errorf("getting pseudo-terminal number: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		_ = master.Close()
+
+		return nil, nil, err
+	}
+
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		_ = master.Close()
+
+		return nil, nil, fmt.Errorf("opening pseudo-terminal: %w", err)
+	}
+
+	return master, slave, nil
+}
@@ -0,0 +1,21 @@
+//go:build !linux && !darwin
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+var errPTYUnsupported = errors.New("--pty is not supported")
+
+type ptySession struct{}
+
+func attachPTY(*exec.Cmd) (*ptySession, error) {
+	return nil, fmt.Errorf("%w on %s", errPTYUnsupported, runtime.GOOS)
+}
+
+func (*ptySession) finish(*Metrics, time.Time) {}
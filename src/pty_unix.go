@@ -0,0 +1,204 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// ptyDrainTimeout bounds how long ztime keeps copying terminal output after
+// the command exits, in case a background process still holds the terminal.
+const ptyDrainTimeout = time.Second
+
+// ptySession runs the command on a pseudo-terminal. ztime relays its stdin
+// to the terminal and the terminal's output to its stdout, keeps the
+// terminal's size in step with its own, and puts its own terminal in raw
+// mode so that keys such as Ctrl-C reach the command unchanged.
+type ptySession struct {
+	master  *os.File
+	slave   *os.File
+	restore *unix.Termios // ztime's terminal settings; nil if stdin is not a terminal
+	winch   chan os.Signal
+	output  chan struct{} // closed once all output has been copied
+	input   chan struct{} // closed once ztime stops relaying its stdin
+	stop    *os.File      // closed to stop relaying stdin
+}
+
+func attachPTY(cmd *exec.Cmd) (*ptySession, error) {
+	master, slave, err := openPTY()
+	if err != nil {
+		return nil, fmt.Errorf("allocating a pseudo-terminal: %w", err)
+	}
+
+	stopRead, stop, err := os.Pipe()
+	if err != nil {
+		_ = master.Close()
+		_ = slave.Close()
+
+		return nil, fmt.Errorf("allocating a pseudo-terminal: %w", err)
+	}
+
+	p := &ptySession{
+		master: master,
+		slave:  slave,
+		winch:  make(chan os.Signal, 1),
+		output: make(chan struct{}),
+		input:  make(chan struct{}),
+		stop:   stop,
+	}
+
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	// Keep what was set already, such as the --limit-mem cgroup.
+	cmd.SysProcAttr.Setsid, cmd.SysProcAttr.Setctty, cmd.SysProcAttr.Ctty = true, true, 0
+
+	// Give the command the same terminal settings as ztime's, then switch
+	// ztime's terminal to raw mode until the run is over.
+	if termios, err := unix.IoctlGetTermios(int(os.Stdin.Fd()), ioctlGetTermios); err == nil {
+		_ = controlFile(slave, func(fd int) error { return unix.IoctlSetTermios(fd, ioctlSetTermios, termios) })
+
+		raw := makeRaw(*termios)
+		if unix.IoctlSetTermios(int(os.Stdin.Fd()), ioctlSetTermios, &raw) == nil {
+			p.restore = termios
+		}
+	}
+
+	p.resize()
+	signal.Notify(p.winch, unix.SIGWINCH)
+
+	go func() {
+		for range p.winch {
+			p.resize()
+		}
+	}()
+
+	go func() {
+		_, _ = io.Copy(os.Stdout, master)
+
+		close(p.output)
+	}()
+
+	go func() {
+		relayInput(int(os.Stdin.Fd()), master, stopRead)
+
+		_ = stopRead.Close()
+
+		close(p.input)
+	}()
+
+	return p, nil
+}
+
+// relayInput copies what can be read from the descriptor in to out, until
+// in ends or stop is closed at its other end. It polls rather than blocking
+// in read(2), so that once a run is over it lets go of ztime's terminal
+// instead of taking the next keystroke meant for a later run or the shell.
+func relayInput(in int, out io.Writer, stop *os.File) {
+	fds := []unix.PollFd{
+		{Fd: int32(in), Events: unix.POLLIN},        //nolint:gosec // Descriptors fit in 32 bits.
+		{Fd: int32(stop.Fd()), Events: unix.POLLIN}, //nolint:gosec // Descriptors fit in 32 bits.
+	}
+
+	buf := make([]byte, 32*1024)
+
+	for {
+		if _, err := unix.Poll(fds, -1); err != nil {
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+
+			return
+		}
+
+		if fds[1].Revents != 0 || fds[0].Revents&(unix.POLLIN|unix.POLLHUP) == 0 {
+			return
+		}
+
+		n, err := unix.Read(in, buf)
+		if n <= 0 || err != nil {
+			return
+		}
+
+		if _, err := out.Write(buf[:n]); err != nil {
+			return
+		}
+	}
+}
+
+// resize copies the size of ztime's terminal to the command's.
+func (p *ptySession) resize() {
+	for _, f := range []*os.File{os.Stdin, os.Stdout, os.Stderr} {
+		if ws, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ); err == nil {
+			_ = controlFile(p.master, func(fd int) error { return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, ws) })
+
+			return
+		}
+	}
+}
+
+// finish stops relaying stdin, waits for the remaining output, and
+// restores ztime's terminal.
+func (p *ptySession) finish(_ *Metrics, _ time.Time) {
+	signal.Stop(p.winch)
+	close(p.winch)
+
+	_ = p.stop.Close()
+	<-p.input
+
+	// With ztime's copy of the terminal closed, reading the master fails
+	// once the command and its children have let go of it too.
+	_ = p.slave.Close()
+
+	select {
+	case <-p.output:
+	case <-time.After(ptyDrainTimeout):
+	}
+
+	_ = p.master.Close()
+
+	if p.restore != nil {
+		_ = unix.IoctlSetTermios(int(os.Stdin.Fd()), ioctlSetTermios, p.restore)
+	}
+}
+
+// makeRaw returns t with input and output processing, echo, and signal
+// keys turned off, like cfmakeraw(3).
+func makeRaw(t unix.Termios) unix.Termios {
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CSIZE | unix.PARENB
+	t.Cflag |= unix.CS8
+	t.Cc[unix.VMIN] = 1
+	t.Cc[unix.VTIME] = 0
+
+	return t
+}
+
+// controlFile runs fn on the descriptor of f without switching f to
+// blocking mode, so that closing f still interrupts a pending read.
+func controlFile(f *os.File, fn func(fd int) error) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("accessing %s: %w", f.Name(), err)
+	}
+
+	var fnErr error
+	if err := conn.Control(func(fd uintptr) { fnErr = fn(int(fd)) }); err != nil {
+		return fmt.Errorf("accessing %s: %w", f.Name(), err)
+	}
+
+	return fnErr
+}
@@ -0,0 +1,68 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestRunCommandPTY(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	if _, err := runCommand([]string{"sh", "-c", "test -t 0 && test -t 1 && test -t 2"}, runOptions{pty: true}); err != nil {
+		t.Errorf("command did not see a terminal: %v", err)
+	}
+}
+
+func TestRelayInputStops(t *testing.T) {
+	t.Parallel()
+
+	in, feed, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer in.Close()
+	defer feed.Close()
+
+	stopRead, stop, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer stopRead.Close()
+
+	out, relayed := io.Pipe()
+	done := make(chan struct{})
+
+	go func() {
+		relayInput(int(in.Fd()), relayed, stopRead)
+		close(done)
+	}()
+
+	if _, err := feed.WriteString("ls\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 3)
+	if _, err := io.ReadFull(out, buf); err != nil || string(buf) != "ls\n" {
+		t.Fatalf("relayed %q, %v; want %q", buf, err, "ls\n")
+	}
+
+	// The input is still open, as a terminal would be.
+	_ = stop.Close()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("relayInput() kept reading after stop was closed")
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import "time"
+
+// microPrecision is the number of decimals of times with --precision-ns.
+const microPrecision = 6
+
+// rawClock times a run with the raw monotonic clock for --precision-ns.
+// Without it, runs are timed with the differences of time.Now readings,
+// which use the monotonic clock but follow NTP's rate corrections.
+type rawClock struct {
+	start   time.Duration
+	elapsed time.Duration
+}
+
+// startRawClock reads the raw clock when enabled and available; the result
+// is nil otherwise, and its methods do nothing.
+func startRawClock(enabled bool) *rawClock {
+	if !enabled {
+		return nil
+	}
+
+	start, ok := readRawClock()
+	if !ok {
+		return nil
+	}
+
+	return &rawClock{start: start}
+}
+
+// stop reads the clock again when the command has exited.
+func (c *rawClock) stop() {
+	if c == nil {
+		return
+	}
+
+	if end, ok := readRawClock(); ok {
+		c.elapsed = end - c.start
+	}
+}
+
+// apply replaces the elapsed time of m with the raw clock's, along with the
+// figures derived from it.
+func (c *rawClock) apply(m *Metrics) {
+	if c == nil || c.elapsed == 0 {
+		return
+	}
+
+	m.ElapsedTime = c.elapsed
+	m.CPUPercent = calculateCPUPercent(m.UserTime, m.SystemTime, m.ElapsedTime)
+	m.Unaccounted = max(m.ElapsedTime-m.UserTime-m.SystemTime, 0)
+}
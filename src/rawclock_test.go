@@ -0,0 +1,38 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRawClock(t *testing.T) {
+	t.Parallel()
+
+	if startRawClock(false) != nil {
+		t.Error("startRawClock(false) != nil")
+	}
+
+	clock := startRawClock(true)
+	if clock == nil {
+		if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
+			t.Fatal("startRawClock(true) = nil")
+		}
+
+		t.Skip("no raw monotonic clock on", runtime.GOOS)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	clock.stop()
+
+	m := Metrics{ElapsedTime: time.Hour, UserTime: 2 * time.Millisecond}
+	clock.apply(&m)
+
+	if m.ElapsedTime < 10*time.Millisecond || m.ElapsedTime > time.Second {
+		t.Errorf("ElapsedTime = %v, want about 10ms", m.ElapsedTime)
+	}
+
+	if m.Unaccounted != m.ElapsedTime-m.UserTime || m.CPUPercent == 0 {
+		t.Errorf("Unaccounted = %v, CPUPercent = %d; want them recomputed", m.Unaccounted, m.CPUPercent)
+	}
+}
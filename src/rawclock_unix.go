@@ -0,0 +1,20 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// readRawClock reads CLOCK_MONOTONIC_RAW, which NTP neither steps nor
+// slews, unlike the monotonic clock behind time.Now.
+func readRawClock() (time.Duration, bool) {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC_RAW, &ts); err != nil {
+		return 0, false
+	}
+
+	return time.Duration(ts.Nano()), true
+}
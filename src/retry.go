@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// runAttempts runs the command until it succeeds or --retries is used up.
+// Failed attempts are reported as they happen (except in JSON mode, which
+// reports once at the end). The returned metrics describe the last attempt
+// and, if there were several, list all of them.
+func (c *runCmd) runAttempts(opts runOptions) (Metrics, error) {
+	var attempts []Metrics
+
+	for attempt := 1; ; attempt++ {
+		m, err := c.measure(c.Command, opts)
+		attempts = append(attempts, m)
+
+		if attempt > c.Retries || !shouldRetry(m, err) {
+			if len(attempts) > 1 {
+				m.Attempts = attempts
+				m.TotalElapsed = m.EndTime.Sub(attempts[0].StartTime)
+			}
+
+			return m, err
+		}
+
+		if !c.Quiet && !c.structured() {
+			c.report(m)
+			fmt.Fprintf(os.Stderr, "ztime: attempt %d of %d exited with status %d, retrying in %v\n",
+				attempt, c.Retries+1, m.ExitCode, c.RetryDelay)
+		}
+
+		time.Sleep(c.RetryDelay)
+	}
+}
+
+// shouldRetry reports whether a failed run is worth repeating. Commands that
+// could not be started, were killed by a signal, or were interrupted through
+// ztime are not retried.
+func shouldRetry(m Metrics, err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+
+	return !m.Killed && !interrupted(m.SignalLog)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestShouldRetry(t *testing.T) {
+	t.Parallel()
+
+	exitErr := &exec.ExitError{}
+
+	tests := []struct {
+		name     string
+		m        Metrics
+		err      error
+		expected bool
+	}{
+		{
+			name:     "Success",
+			expected: false,
+		},
+		{
+			name:     "Non-Zero Exit",
+			m:        Metrics{ExitCode: 1},
+			err:      exitErr,
+			expected: true,
+		},
+		{
+			name:     "Killed",
+			m:        Metrics{ExitCode: -1, Killed: true, TermSignal: "SIGKILL"},
+			err:      exitErr,
+			expected: false,
+		},
+		{
+			name:     "Interrupted Through ztime",
+			m:        Metrics{ExitCode: 130, SignalLog: []SignalEvent{{Signal: "SIGINT", Forwarded: true}}},
+			err:      exitErr,
+			expected: false,
+		},
+		{
+			name:     "Start Failure",
+			m:        Metrics{ExitCode: -1},
+			err:      errors.New("executable file not found"),
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := shouldRetry(tt.m, tt.err); got != tt.expected {
+				t.Errorf("shouldRetry() = %t, want %t", got, tt.expected)
+			}
+		})
+	}
+}
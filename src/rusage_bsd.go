@@ -0,0 +1,38 @@
+//go:build dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// defaultStatHz is the statistics clock rate to assume if the kernel does
+// not report it: FreeBSD's and OpenBSD's usual rate.
+const defaultStatHz = 128
+
+// normalizeUsage converts the memory integrals to kilobytes. The BSDs add
+// the current shared, data, and stack sizes to them on every tick of the
+// statistics clock, so they are in kilobyte-ticks; dividing by the ticks of
+// CPU time gives the average size, as their time -l prints it. ru_maxrss
+// is already in kilobytes.
+func normalizeUsage(m *Metrics) {
+	scaleIntegrals(m, statHz())
+}
+
+// scaleIntegrals divides the memory integrals of m by the ticks of a
+// statistics clock running at hz during its CPU time.
+func scaleIntegrals(m *Metrics, hz int64) {
+	ticks := max(int64((m.UserTime+m.SystemTime).Seconds()*float64(hz)), 1)
+
+	m.SharedRSS /= ticks
+	m.UnsharedData /= ticks
+	m.UnsharedStk /= ticks
+}
+
+// statHz returns the rate of the statistics clock, which samples the
+// memory integrals.
+func statHz() int64 {
+	if info, err := unix.SysctlClockinfo("kern.clockrate"); err == nil && info.Stathz > 0 {
+		return int64(info.Stathz)
+	}
+
+	return defaultStatHz
+}
@@ -0,0 +1,32 @@
+//go:build dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScaleIntegrals(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		cpu      time.Duration
+		integral int64
+		expected int64
+	}{
+		{"two seconds", 2 * time.Second, 256 * 4096, 4096},
+		{"under a tick", time.Millisecond, 4096, 4096},
+		{"no CPU time", 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		m := Metrics{UserTime: tt.cpu / 2, SystemTime: tt.cpu / 2, SharedRSS: tt.integral, UnsharedData: tt.integral, UnsharedStk: tt.integral}
+		scaleIntegrals(&m, 128)
+
+		if m.SharedRSS != tt.expected || m.UnsharedData != tt.expected || m.UnsharedStk != tt.expected {
+			t.Errorf("%s: scaleIntegrals() = %d, %d, %d KB, want %d", tt.name, m.SharedRSS, m.UnsharedData, m.UnsharedStk, tt.expected)
+		}
+	}
+}
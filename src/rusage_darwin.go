@@ -0,0 +1,7 @@
+package main
+
+// normalizeUsage converts ru_maxrss, which macOS reports in bytes, to
+// kilobytes. The kernel does not keep the memory integrals.
+func normalizeUsage(m *Metrics) {
+	m.MaxRSS /= 1024
+}
@@ -5,23 +5,35 @@ package main
 import (
 	"os"
 	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
 )
 
+// populateUsage copies the rusage of the exited command, whose fields are
+// 32 bits wide on 32-bit platforms.
 func populateUsage(m *Metrics, state *os.ProcessState) {
 	if usage, ok := state.SysUsage().(*syscall.Rusage); ok {
-		m.MaxRSS = usage.Maxrss
-		m.SharedRSS = usage.Ixrss
-		m.UnsharedData = usage.Idrss
-		m.UnsharedStk = usage.Isrss
-		m.PageFaults = usage.Majflt
-		m.PageReclaims = usage.Minflt
-		m.Swaps = usage.Nswap
-		m.BlockInput = usage.Inblock
-		m.BlockOutput = usage.Oublock
-		m.MsgsSent = usage.Msgsnd
-		m.MsgsRecv = usage.Msgrcv
-		m.Signals = usage.Nsignals
-		m.VCtxSwitches = usage.Nvcsw
-		m.ICtxSwitches = usage.Nivcsw
+		m.MaxRSS = int64(usage.Maxrss)
+		m.SharedRSS = int64(usage.Ixrss)
+		m.UnsharedData = int64(usage.Idrss)
+		m.UnsharedStk = int64(usage.Isrss)
+		m.PageFaults = int64(usage.Majflt)
+		m.PageReclaims = int64(usage.Minflt)
+		m.Swaps = int64(usage.Nswap)
+		m.BlockInput = int64(usage.Inblock)
+		m.BlockOutput = int64(usage.Oublock)
+		m.MsgsSent = int64(usage.Msgsnd)
+		m.MsgsRecv = int64(usage.Msgrcv)
+		m.Signals = int64(usage.Nsignals)
+		m.VCtxSwitches = int64(usage.Nvcsw)
+		m.ICtxSwitches = int64(usage.Nivcsw)
+
+		normalizeUsage(m)
 	}
 }
+
+// rusageCPU returns the user plus system time in usage.
+func rusageCPU(usage unix.Rusage) time.Duration {
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// sampleProcess returns the current resident set size, thread count, and
+// open file descriptor count of pid.
+func sampleProcess(pid int) (processSample, bool) {
+	dir := "/proc/" + strconv.Itoa(pid)
+
+	f, err := os.Open(dir + "/status")
+	if err != nil {
+		return processSample{}, false
+	}
+	defer f.Close()
+
+	var s processSample
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "VmRSS":
+			s.rss, _ = strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(value), " kB"), 10, 64)
+		case "Threads":
+			s.threads, _ = strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		}
+	}
+
+	// Listing the descriptors of a process owned by another user fails;
+	// the other figures are still good.
+	if fds, err := os.ReadDir(dir + "/fd"); err == nil {
+		s.fds = int64(len(fds))
+	}
+
+	s.voluntary, s.involuntary = contextSwitches(dir)
+
+	return s, s.threads > 0
+}
+
+// contextSwitches sums the voluntary and involuntary context switches of
+// the live threads of the process at dir, since its status file only
+// counts the main thread's.
+func contextSwitches(dir string) (int64, int64) {
+	tasks, err := os.ReadDir(dir + "/task")
+	if err != nil {
+		return 0, 0
+	}
+
+	var voluntary, involuntary int64
+
+	for _, task := range tasks {
+		data, err := os.ReadFile(dir + "/task/" + task.Name() + "/status")
+		if err != nil {
+			continue
+		}
+
+		v, i := parseContextSwitches(data)
+		voluntary += v
+		involuntary += i
+	}
+
+	return voluntary, involuntary
+}
+
+// parseContextSwitches reads the voluntary_ctxt_switches and
+// nonvoluntary_ctxt_switches lines of a status file.
+func parseContextSwitches(data []byte) (int64, int64) {
+	var voluntary, involuntary int64
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "voluntary_ctxt_switches":
+			voluntary, _ = strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		case "nonvoluntary_ctxt_switches":
+			involuntary, _ = strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		}
+	}
+
+	return voluntary, involuntary
+}
+
+// sampleState returns the state letter of pid from /proc/<pid>/stat, such
+// as R, S, or D.
+func sampleState(pid int) (byte, bool) {
+	_, state, _, ok := readStat(pid)
+
+	return state, ok
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSampleProcess(t *testing.T) {
+	t.Parallel()
+
+	s, ok := sampleProcess(os.Getpid())
+	if !ok || s.rss <= 0 || s.threads < 1 || s.fds < 3 {
+		t.Errorf("sampleProcess(self) = %+v, %v; want RSS, threads, and at least stdio", s, ok)
+	}
+
+	if _, ok := sampleProcess(-1); ok {
+		t.Error("sampleProcess(-1) succeeded")
+	}
+}
+
+func TestParseContextSwitches(t *testing.T) {
+	t.Parallel()
+
+	status := "Name:\tsh\nThreads:\t1\nvoluntary_ctxt_switches:\t812\nnonvoluntary_ctxt_switches:\t7\n"
+
+	if v, i := parseContextSwitches([]byte(status)); v != 812 || i != 7 {
+		t.Errorf("parseContextSwitches() = %d, %d, want 812, 7", v, i)
+	}
+
+	if v, i := contextSwitches("/proc/self"); v+i == 0 {
+		t.Error("contextSwitches(/proc/self) found no switches")
+	}
+}
+
+func TestEventStreamSamplePeak(t *testing.T) {
+	t.Parallel()
+
+	s := newEventStream(io.Discard, time.Millisecond)
+
+	stop := s.sample(os.Getpid(), time.Now(), 1, nil)
+	time.Sleep(20 * time.Millisecond)
+
+	if peak, _ := stop(); peak.threads < 1 || peak.fds < 3 {
+		t.Errorf("sample() peak = %+v, want threads and descriptors", peak)
+	}
+}
+
+func TestSampleTreeMemory(t *testing.T) {
+	t.Parallel()
+
+	share, ok := sampleTreeMemory(os.Getpid())
+	if !ok || share.pss <= 0 || share.uss <= 0 || share.procs < 1 {
+		t.Errorf("sampleTreeMemory(self) = %+v, %v; want the test process's memory", share, ok)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package main
+
+// sampleProcess is only implemented on Linux, where /proc exposes the
+// resident set size, threads, and descriptors of a running process.
+func sampleProcess(int) (processSample, bool) {
+	return processSample{}, false
+}
+
+// sampleState is only implemented on Linux, where /proc exposes the state
+// of a running process.
+func sampleState(int) (byte, bool) {
+	return 0, false
+}
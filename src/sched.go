@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var errSchedStatsUnsupported = errors.New("--sched-stats is not supported")
+
+// SchedStats is what the Linux scheduler recorded about the command's main
+// thread, read with --sched-stats after it exited and before it was reaped.
+type SchedStats struct {
+	RunTime      time.Duration `json:"run_time"`                // on a CPU
+	RunQueueWait time.Duration `json:"run_queue_wait"`          // runnable but waiting for a CPU
+	Timeslices   int64         `json:"timeslices" unit:"count"` // times it was put on a CPU
+	Migrations   int64         `json:"migrations" unit:"count"` // moves between CPUs
+	Error        string        `json:"error,omitempty"`         // why the stats could not be read
+}
+
+// schedNote describes how long the command waited for a CPU, or returns ""
+// without --sched-stats.
+func schedNote(m Metrics) string {
+	s := m.SchedStats
+
+	switch {
+	case s == nil:
+		return ""
+	case s.Error != "":
+		return "no scheduler stats: " + s.Error
+	}
+
+	note := fmt.Sprintf("sched: waited %.3fs for a CPU", s.RunQueueWait.Seconds())
+	if m.ElapsedTime > 0 {
+		note += fmt.Sprintf(" (%.0f%% of elapsed)", float64(s.RunQueueWait)/float64(m.ElapsedTime)*100)
+	}
+
+	return note + fmt.Sprintf(", %d timeslices, %d migrations", s.Timeslices, s.Migrations)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func schedStatsSupported() error {
+	return nil
+}
+
+// waitSchedStats blocks until pid exits and reads its scheduler statistics
+// while it is a zombie. It leaves the process for the caller to reap.
+func waitSchedStats(pid int) *SchedStats {
+	if err := waitZombie(pid); err != nil {
+		return &SchedStats{Error: err.Error()}
+	}
+
+	dir := "/proc/" + strconv.Itoa(pid)
+
+	schedstat, err := os.ReadFile(dir + "/schedstat")
+	if err != nil {
+		return &SchedStats{Error: err.Error()}
+	}
+
+	s, err := parseSchedstat(schedstat)
+	if err != nil {
+		return &SchedStats{Error: err.Error()}
+	}
+
+	// /proc/<pid>/sched exists only with CONFIG_SCHED_DEBUG.
+	if sched, err := os.ReadFile(dir + "/sched"); err == nil {
+		s.Migrations = parseSchedMigrations(sched)
+	}
+
+	return &s
+}
+
+// waitZombie blocks until pid exits without reaping it, so that what /proc
+// holds about it can still be read.
+func waitZombie(pid int) error {
+	var info unix.Siginfo
+
+	for {
+		err := unix.Waitid(unix.P_PID, pid, &info, unix.WEXITED|unix.WNOWAIT, nil)
+		if err == nil {
+			return nil
+		}
+
+		if !errors.Is(err, unix.EINTR) {
+			return fmt.Errorf("waiting for the command: %w", err)
+		}
+	}
+}
+
+// parseSchedMigrations returns se.nr_migrations from /proc/<pid>/sched.
+func parseSchedMigrations(data []byte) int64 {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if ok && strings.TrimSpace(key) == "se.nr_migrations" {
+			n, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+
+			return n
+		}
+	}
+
+	return 0
+}
+
+// parseSchedstat parses /proc/<pid>/schedstat: nanoseconds on a CPU,
+// nanoseconds waiting on a run queue, and timeslices.
+func parseSchedstat(data []byte) (SchedStats, error) {
+	var run, wait, slices int64
+
+	if _, err := fmt.Sscan(string(data), &run, &wait, &slices); err != nil {
+		return SchedStats{}, fmt.Errorf("parsing schedstat %q: %w", bytes.TrimSpace(data), err)
+	}
+
+	return SchedStats{RunTime: time.Duration(run), RunQueueWait: time.Duration(wait), Timeslices: slices}, nil
+}
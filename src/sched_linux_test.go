@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestParseSchedstat(t *testing.T) {
+	t.Parallel()
+
+	s, err := parseSchedstat([]byte("1500000 250000 42\n"))
+	if err != nil || s.RunTime != 1500*time.Microsecond || s.RunQueueWait != 250*time.Microsecond || s.Timeslices != 42 {
+		t.Errorf("parseSchedstat() = %+v, %v; want 1.5ms running, 250µs waiting, 42 timeslices", s, err)
+	}
+
+	if _, err := parseSchedstat([]byte("garbage")); err == nil {
+		t.Error("parseSchedstat() accepted garbage")
+	}
+
+	sched := []byte("sleep (1234, #threads: 1)\n---\nse.exec_start      :     1.5\nse.nr_migrations   :      9\nnr_switches        :     12\n")
+	if got := parseSchedMigrations(sched); got != 9 {
+		t.Errorf("parseSchedMigrations() = %d, want 9", got)
+	}
+}
+
+func TestWaitSchedStats(t *testing.T) {
+	t.Parallel()
+
+	cmd := exec.CommandContext(context.Background(), "true")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start true: %v", err)
+	}
+
+	s := waitSchedStats(cmd.Process.Pid)
+
+	// The process must still be there for Wait to reap.
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("Wait() after waitSchedStats() error = %v", err)
+	}
+
+	if s == nil || s.Error != "" || s.Timeslices == 0 {
+		t.Errorf("waitSchedStats() = %+v, want the stats of the exited process", s)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func schedStatsSupported() error {
+	return fmt.Errorf("%w on %s", errSchedStatsUnsupported, runtime.GOOS)
+}
+
+func waitSchedStats(int) *SchedStats {
+	return nil
+}
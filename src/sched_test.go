@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedNote(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		m    Metrics
+		want string
+	}{
+		{"not requested", Metrics{}, ""},
+		{"unreadable", Metrics{SchedStats: &SchedStats{Error: "permission denied"}}, "no scheduler stats: permission denied"},
+		{
+			"contended",
+			Metrics{ElapsedTime: 4 * time.Second, SchedStats: &SchedStats{RunQueueWait: time.Second, Timeslices: 812, Migrations: 37}},
+			"sched: waited 1.000s for a CPU (25% of elapsed), 812 timeslices, 37 migrations",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := schedNote(tt.m); got != tt.want {
+				t.Errorf("schedNote() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strings"
+	"time"
+)
+
+const (
+	// schedLatencyInterval is how often --sched-latency reads the threads'
+	// scheduler statistics.
+	schedLatencyInterval = 10 * time.Millisecond
+	// schedLatencyBuckets is the number of histogram buckets: under 1µs,
+	// then one per power of two microseconds up to about 16s.
+	schedLatencyBuckets = 26
+)
+
+var errSchedLatencyUnsupported = errors.New("--sched-latency is not supported")
+
+// SchedLatency is a histogram of how long the threads of the command and
+// its children waited runnable for a CPU before each timeslice, from
+// --sched-latency. The scheduler only keeps totals, so each sample
+// attributes the average wait of a thread's new timeslices to all of them.
+type SchedLatency struct {
+	Samples   int             `json:"samples" unit:"count"`
+	Waits     int64           `json:"waits" unit:"count"` // timeslices seen
+	TotalWait time.Duration   `json:"total_wait"`
+	Buckets   []LatencyBucket `json:"buckets"` // from the lowest to the highest non-empty one
+}
+
+// LatencyBucket counts the waits of at least Min and under Max.
+type LatencyBucket struct {
+	Min   time.Duration `json:"min"`
+	Max   time.Duration `json:"max"`
+	Count int64         `json:"count" unit:"count"`
+}
+
+// latencyHistogram counts waits in power-of-two microsecond buckets.
+type latencyHistogram [schedLatencyBuckets]int64
+
+// add counts n waits of d each.
+func (h *latencyHistogram) add(d time.Duration, n int64) {
+	h[latencyBucket(d)] += n
+}
+
+// latencyBucket returns the bucket of d: 0 under 1µs, and k for
+// [2^(k-1), 2^k) microseconds.
+func latencyBucket(d time.Duration) int {
+	us := d.Microseconds()
+	if us <= 0 {
+		return 0
+	}
+
+	return min(bits.Len64(uint64(us)), schedLatencyBuckets-1)
+}
+
+// bucketBounds returns the range of bucket k.
+func bucketBounds(k int) (time.Duration, time.Duration) {
+	if k == 0 {
+		return 0, time.Microsecond
+	}
+
+	return time.Duration(1<<(k-1)) * time.Microsecond, time.Duration(1<<k) * time.Microsecond
+}
+
+// buckets returns the buckets from the lowest to the highest non-empty one.
+func (h *latencyHistogram) buckets() []LatencyBucket {
+	first, last := -1, -1
+
+	for k, n := range h {
+		if n > 0 {
+			if first < 0 {
+				first = k
+			}
+
+			last = k
+		}
+	}
+
+	if first < 0 {
+		return nil
+	}
+
+	out := make([]LatencyBucket, 0, last-first+1)
+
+	for k := first; k <= last; k++ {
+		lo, hi := bucketBounds(k)
+		out = append(out, LatencyBucket{Min: lo, Max: hi, Count: h[k]})
+	}
+
+	return out
+}
+
+// latencyPercentile returns the upper bound of the bucket holding the p-th
+// fraction of the waits.
+func latencyPercentile(buckets []LatencyBucket, p float64) time.Duration {
+	var total int64
+	for _, b := range buckets {
+		total += b.Count
+	}
+
+	target := int64(math.Ceil(p * float64(total)))
+
+	var seen int64
+
+	for _, b := range buckets {
+		seen += b.Count
+		if seen >= target {
+			return b.Max
+		}
+	}
+
+	return 0
+}
+
+// schedLatencyNote draws the histogram, or returns "" without
+// --sched-latency.
+func schedLatencyNote(m Metrics) string {
+	s := m.SchedLatency
+	if s == nil {
+		return ""
+	}
+
+	if s.Waits == 0 {
+		return "sched latency: no timeslices seen"
+	}
+
+	var note strings.Builder
+
+	fmt.Fprintf(&note, "sched latency: %d waits for a CPU, %s in all; median under %s, 99%% under %s",
+		s.Waits, humanDuration(s.TotalWait), humanDuration(latencyPercentile(s.Buckets, 0.5)), humanDuration(latencyPercentile(s.Buckets, 0.99)))
+
+	var peak int64
+	for _, b := range s.Buckets {
+		peak = max(peak, b.Count)
+	}
+
+	for _, b := range s.Buckets {
+		bar := strings.Repeat("█", int(math.Ceil(float64(b.Count)*plotBarWidth/float64(peak))))
+		fmt.Fprintf(&note, "\n  < %-8s %s %d", humanDuration(b.Max), bar, b.Count)
+	}
+
+	return note.String()
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+func schedLatencySupported() error {
+	return nil
+}
+
+// threadSched is the run queue wait and timeslices of a thread so far.
+type threadSched struct {
+	wait   time.Duration
+	slices int64
+}
+
+// schedLatencySampler reads the scheduler statistics of every thread of a
+// process tree until stopped.
+type schedLatencySampler struct {
+	done    chan struct{}
+	stopped chan struct{}
+	threads map[int]threadSched
+	hist    latencyHistogram
+	latency SchedLatency
+}
+
+// sampleSchedLatency starts sampling the threads of pid and its
+// descendants. It returns nil if enabled is false.
+func sampleSchedLatency(enabled bool, pid int) *schedLatencySampler {
+	if !enabled {
+		return nil
+	}
+
+	s := &schedLatencySampler{done: make(chan struct{}), stopped: make(chan struct{}), threads: map[int]threadSched{}}
+
+	go func() {
+		defer close(s.stopped)
+
+		ticker := time.NewTicker(schedLatencyInterval)
+		defer ticker.Stop()
+
+		for {
+			s.sample(pid)
+
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return s
+}
+
+// sample adds the timeslices each thread ran since the last sample, each
+// with the thread's average wait over them.
+func (s *schedLatencySampler) sample(pid int) {
+	tree, ok := processTree(pid)
+	if !ok {
+		return
+	}
+
+	s.latency.Samples++
+
+	for _, p := range tree {
+		dir := "/proc/" + strconv.Itoa(p) + "/task/"
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			tid, err := strconv.Atoi(entry.Name())
+			if err != nil {
+				continue
+			}
+
+			data, err := os.ReadFile(dir + entry.Name() + "/schedstat")
+			if err != nil {
+				continue
+			}
+
+			stats, err := parseSchedstat(data)
+			if err != nil {
+				continue
+			}
+
+			s.record(tid, threadSched{wait: stats.RunQueueWait, slices: stats.Timeslices})
+		}
+	}
+}
+
+// record adds what thread tid did since it was last seen.
+func (s *schedLatencySampler) record(tid int, now threadSched) {
+	prev := s.threads[tid]
+	s.threads[tid] = now
+
+	slices := now.slices - prev.slices
+	if slices <= 0 {
+		return
+	}
+
+	wait := max(now.wait-prev.wait, 0)
+
+	s.hist.add(wait/time.Duration(slices), slices)
+	s.latency.Waits += slices
+	s.latency.TotalWait += wait
+}
+
+// stop ends the sampling and stores the histogram in m.
+func (s *schedLatencySampler) stop(m *Metrics) {
+	if s == nil {
+		return
+	}
+
+	close(s.done)
+	<-s.stopped
+
+	latency := s.latency
+	latency.Buckets = s.hist.buckets()
+	m.SchedLatency = &latency
+}
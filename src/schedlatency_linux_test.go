@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSchedLatencySamplerRecord(t *testing.T) {
+	t.Parallel()
+
+	s := &schedLatencySampler{threads: map[int]threadSched{}}
+
+	s.record(1, threadSched{wait: 40 * time.Microsecond, slices: 4})
+	s.record(1, threadSched{wait: 40 * time.Microsecond, slices: 4})   // did not run
+	s.record(1, threadSched{wait: 1040 * time.Microsecond, slices: 6}) // two slices, 500µs each
+	s.record(2, threadSched{wait: 0, slices: 1})
+
+	var m Metrics
+
+	s.done, s.stopped = make(chan struct{}), make(chan struct{})
+	close(s.stopped)
+	s.stop(&m)
+
+	got := m.SchedLatency
+	if got.Waits != 7 || got.TotalWait != 1040*time.Microsecond {
+		t.Errorf("SchedLatency = %+v", got)
+	}
+
+	for _, want := range []struct {
+		wait  time.Duration
+		count int64
+	}{{0, 1}, {10 * time.Microsecond, 4}, {500 * time.Microsecond, 2}} {
+		if n := s.hist[latencyBucket(want.wait)]; n != want.count {
+			t.Errorf("bucket of %v has %d waits, want %d", want.wait, n, want.count)
+		}
+	}
+}
+
+func TestSampleSchedLatency(t *testing.T) {
+	t.Parallel()
+
+	if sampleSchedLatency(false, os.Getpid()) != nil {
+		t.Error("sampleSchedLatency(false) != nil")
+	}
+
+	s := sampleSchedLatency(true, os.Getpid())
+	time.Sleep(3 * schedLatencyInterval)
+
+	var m Metrics
+
+	s.stop(&m)
+
+	if m.SchedLatency == nil || m.SchedLatency.Samples == 0 {
+		t.Errorf("SchedLatency = %+v, want samples", m.SchedLatency)
+	}
+}
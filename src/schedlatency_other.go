@@ -0,0 +1,20 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func schedLatencySupported() error {
+	return fmt.Errorf("%w on %s", errSchedLatencyUnsupported, runtime.GOOS)
+}
+
+type schedLatencySampler struct{}
+
+func sampleSchedLatency(bool, int) *schedLatencySampler {
+	return nil
+}
+
+func (*schedLatencySampler) stop(*Metrics) {}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatencyBucket(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		wait time.Duration
+		want int
+	}{
+		{"Zero", 0, 0},
+		{"Under a microsecond", 900 * time.Nanosecond, 0},
+		{"One microsecond", time.Microsecond, 1},
+		{"Three microseconds", 3 * time.Microsecond, 2},
+		{"Power of two", 64 * time.Microsecond, 7},
+		{"Past the last bucket", time.Minute, schedLatencyBuckets - 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := latencyBucket(tt.wait); got != tt.want {
+				t.Errorf("latencyBucket(%v) = %d, want %d", tt.wait, got, tt.want)
+			}
+
+			if lo, hi := bucketBounds(tt.want); tt.want < schedLatencyBuckets-1 && (tt.wait < lo || tt.wait >= hi) {
+				t.Errorf("%v outside bucket %d [%v, %v)", tt.wait, tt.want, lo, hi)
+			}
+		})
+	}
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	t.Parallel()
+
+	var h latencyHistogram
+	if got := h.buckets(); got != nil {
+		t.Errorf("empty buckets() = %v, want nil", got)
+	}
+
+	h.add(3*time.Microsecond, 90)
+	h.add(100*time.Microsecond, 9)
+	h.add(5*time.Millisecond, 1)
+
+	got := h.buckets()
+	if len(got) != 12 || got[0].Min != 2*time.Microsecond || got[len(got)-1].Max != 8192*time.Microsecond {
+		t.Fatalf("buckets() = %v", got)
+	}
+
+	counts := make([]int64, 0, len(got))
+	for _, b := range got {
+		counts = append(counts, b.Count)
+	}
+
+	if want := []int64{90, 0, 0, 0, 0, 9, 0, 0, 0, 0, 0, 1}; !slices.Equal(counts, want) {
+		t.Errorf("counts = %v, want %v", counts, want)
+	}
+
+	for _, tt := range []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0.5, 4 * time.Microsecond},
+		{0.9, 4 * time.Microsecond},
+		{0.99, 128 * time.Microsecond},
+		{1, 8192 * time.Microsecond},
+	} {
+		if got := latencyPercentile(got, tt.p); got != tt.want {
+			t.Errorf("latencyPercentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestSchedLatencyNote(t *testing.T) {
+	t.Parallel()
+
+	if got := schedLatencyNote(Metrics{}); got != "" {
+		t.Errorf("schedLatencyNote() without --sched-latency = %q, want empty", got)
+	}
+
+	m := Metrics{SchedLatency: &SchedLatency{
+		Samples:   10,
+		Waits:     30,
+		TotalWait: 2 * time.Millisecond,
+		Buckets: []LatencyBucket{
+			{Min: 8 * time.Microsecond, Max: 16 * time.Microsecond, Count: 20},
+			{Min: 16 * time.Microsecond, Max: 32 * time.Microsecond, Count: 10},
+		},
+	}}
+
+	lines := strings.Split(schedLatencyNote(m), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "sched latency: 30 waits for a CPU") {
+		t.Fatalf("schedLatencyNote() = %q", lines)
+	}
+
+	if n := strings.Count(lines[1], "█"); n != plotBarWidth {
+		t.Errorf("tallest bar is %d wide, want %d", n, plotBarWidth)
+	}
+
+	if n := strings.Count(lines[2], "█"); n != plotBarWidth/2 {
+		t.Errorf("half bar is %d wide, want %d", n, plotBarWidth/2)
+	}
+}
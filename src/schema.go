@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// schemaVersion is the version of ztime's JSON output. It changes only when
+// a field is removed, renamed, or changes type or unit; new fields can be
+// added within a version, so consumers should ignore fields they do not
+// know.
+const schemaVersion = 1
+
+// versioned sets the schema version of a top-level result about to be
+// written as JSON. Results nested in other results are left unversioned.
+func versioned(v any) any {
+	switch r := v.(type) {
+	case Metrics:
+		r.SchemaVersion = schemaVersion
+
+		return r
+	case BenchResult:
+		r.SchemaVersion = schemaVersion
+
+		return r
+	case Comparison:
+		r.SchemaVersion = schemaVersion
+
+		return r
+	case CheckResult:
+		r.SchemaVersion = schemaVersion
+
+		return r
+	case FleetResult:
+		r.SchemaVersion = schemaVersion
+
+		return r
+	case ParallelResult:
+		r.SchemaVersion = schemaVersion
+
+		return r
+	case SuiteResult:
+		r.SchemaVersion = schemaVersion
+
+		return r
+	default:
+		return v
+	}
+}
+
+// outputSchema returns the JSON Schema of ztime's JSON output: a single
+// run, a benchmark, a comparison, a --parallel or --suite result, a
+// check, a fleet result, or a --jsonl
+// event. Numbers carry their unit in a "unit" keyword; durations are
+// integer nanoseconds. Counts that sampling can only undercount carry
+// "bound": "lower".
+func outputSchema() map[string]any {
+	defs := make(map[string]any)
+
+	var results []any
+	for _, v := range []any{Metrics{}, BenchResult{}, Comparison{}, ParallelResult{}, SuiteResult{}, CheckResult{}, FleetResult{}, event{}} {
+		results = append(results, typeSchema(reflect.TypeOf(v), defs))
+	}
+
+	return map[string]any{
+		"$schema":        "https://json-schema.org/draft/2020-12/schema",
+		"title":          "ztime JSON output",
+		"description":    "Results printed by --json, --jsonl, ztime check --json, ztime fleet --json, and ztime merge. Top-level results carry schema_version.",
+		"schema_version": schemaVersion,
+		"anyOf":          results,
+		"$defs":          defs,
+	}
+}
+
+// typeSchema returns the schema of values of type t, adding the schemas of
+// named struct types to defs and referring to them.
+func typeSchema(t reflect.Type, defs map[string]any) map[string]any {
+	switch t {
+	case reflect.TypeFor[time.Duration]():
+		return map[string]any{"type": "integer", "unit": "nanoseconds"}
+	case reflect.TypeFor[time.Time]():
+		return map[string]any{"type": "string", "format": "date-time"}
+	case reflect.TypeFor[json.RawMessage]():
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem(), defs)
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		s := map[string]any{"type": "array", "items": typeSchema(t.Elem(), defs)}
+		if t.Kind() == reflect.Array {
+			s["minItems"], s["maxItems"] = t.Len(), t.Len()
+		}
+
+		return s
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem(), defs)}
+	case reflect.Struct:
+		name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+
+		if _, ok := defs[name]; !ok {
+			defs[name] = nil // placeholder for recursive types
+
+			s := map[string]any{"type": "object", "properties": map[string]any{}}
+			addFields(s, t, defs)
+			defs[name] = s
+		}
+
+		return map[string]any{"$ref": "#/$defs/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+// addFields adds the JSON fields of struct type t, including those of
+// embedded structs, to the object schema s. Fields without omitempty are
+// required.
+func addFields(s map[string]any, t reflect.Type, defs map[string]any) {
+	properties, _ := s["properties"].(map[string]any)
+	required, _ := s["required"].([]string)
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+
+		if field.Anonymous && tag == "" {
+			addFields(s, field.Type, defs)
+			required, _ = s["required"].([]string)
+
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := typeSchema(field.Type, defs)
+		if unit := field.Tag.Get("unit"); unit != "" {
+			schema["unit"] = unit
+		}
+
+		if bound := field.Tag.Get("bound"); bound != "" {
+			schema["bound"] = bound
+		}
+
+		properties[name] = schema
+
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	if len(required) > 0 {
+		s["required"] = required
+	}
+}
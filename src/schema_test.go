@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestOutputSchemaUnits(t *testing.T) {
+	t.Parallel()
+
+	defs, _ := outputSchema()["$defs"].(map[string]any)
+	if len(defs) == 0 {
+		t.Fatal("outputSchema() has no $defs")
+	}
+
+	for name, def := range defs {
+		properties, _ := def.(map[string]any)["properties"].(map[string]any)
+
+		for field, p := range properties {
+			schema, _ := p.(map[string]any)
+			if items, ok := schema["items"].(map[string]any); ok && schema["unit"] == nil {
+				schema = items
+			}
+
+			if typ := schema["type"]; (typ == "integer" || typ == "number") && schema["unit"] == nil {
+				t.Errorf("%s.%s is a number without a unit tag", name, field)
+			}
+		}
+	}
+}
+
+func TestOutputSchemaBounds(t *testing.T) {
+	t.Parallel()
+
+	defs, _ := outputSchema()["$defs"].(map[string]any)
+	properties, _ := defs["NetTrace"].(map[string]any)["properties"].(map[string]any)
+
+	if bytes, _ := properties["bytes_sent"].(map[string]any); bytes["bound"] != "lower" {
+		t.Errorf("NetTrace.bytes_sent = %v, want a lower bound", bytes)
+	}
+
+	if samples, _ := properties["samples"].(map[string]any); samples["bound"] != nil {
+		t.Errorf("NetTrace.samples = %v, want no bound", samples)
+	}
+}
+
+func TestVersioned(t *testing.T) {
+	t.Parallel()
+
+	r, ok := versioned(BenchResult{Results: []Metrics{{}}}).(BenchResult)
+	if !ok || r.SchemaVersion != schemaVersion {
+		t.Fatalf("versioned() = %+v, want schema version %d", r, schemaVersion)
+	}
+
+	if r.Results[0].SchemaVersion != 0 {
+		t.Errorf("versioned() set the schema version of a nested result")
+	}
+
+	if got := versioned("text"); got != "text" {
+		t.Errorf("versioned() = %v, want other values unchanged", got)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	errShellNotFound = errors.New("reference shell not found")
+	errDivergence    = errors.New("output diverged from the reference shell")
+)
+
+// selftestCmd runs the compatibility corpus against a reference shell.
+type selftestCmd struct {
+	Against   string        `default:"zsh" enum:"zsh" help:"Reference implementation to compare against (${enum})."`
+	Shell     string        `default:"zsh" help:"Path or name of the reference shell binary."`
+	Tolerance time.Duration `default:"50ms" help:"Allowed absolute difference between timing values."`
+	Verbose   bool          `short:"v" help:"Show the output of matching cases too."`
+}
+
+// compatCase is a command and TIMEFMT pair timed by both ztime and the
+// reference shell. Commands are split on spaces and must not be shell
+// builtins, since zsh does not time those.
+type compatCase struct {
+	command string
+	timefmt string
+}
+
+// compatResult is the outcome of a single compatCase.
+type compatResult struct {
+	compatCase
+
+	ztime     string
+	reference string
+	problem   string // empty when the outputs agree
+}
+
+func compatCorpus() []compatCase {
+	return []compatCase{
+		{command: "sleep 0.2", timefmt: "%J  %U user %S system %P cpu %*E total"},
+		{command: "sleep 0.2", timefmt: "%E"},
+		{command: "sleep 0.2", timefmt: "%*E"},
+		{command: "sleep 0.2", timefmt: "%U %S"},
+		{command: "sleep 0.2", timefmt: "100%% of %J"},
+		{command: "cat /dev/null", timefmt: "%J took %E"},
+		{command: "cat /dev/null", timefmt: "[%Z] %"},
+	}
+}
+
+// Run executes the corpus and reports each divergence.
+func (c *selftestCmd) Run() error {
+	shell, err := exec.LookPath(c.Shell)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errShellNotFound, c.Shell)
+	}
+
+	corpus := compatCorpus()
+	failed := 0
+
+	for _, tc := range corpus {
+		result := runCompatCase(shell, tc, c.Tolerance)
+		if result.problem != "" {
+			failed++
+		}
+
+		printCompatResult(result, c.Verbose)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d cases", errDivergence, failed, len(corpus))
+	}
+
+	fmt.Fprintf(os.Stderr, "all %d cases match %s\n", len(corpus), c.Against)
+
+	return nil
+}
+
+func runCompatCase(shell string, tc compatCase, tolerance time.Duration) compatResult {
+	result := compatResult{compatCase: tc}
+
+	reference, err := zshTime(shell, tc)
+	if err != nil {
+		result.problem = err.Error()
+
+		return result
+	}
+
+	result.reference = reference
+
+	metrics, err := runCommand(strings.Fields(tc.command), runOptions{})
+	if err != nil {
+		result.problem = err.Error()
+
+		return result
+	}
+
+	result.ztime = format(tc.timefmt, metrics, formatOptions{})
+	result.problem = compareOutputs(result.ztime, reference, tolerance.Seconds())
+
+	return result
+}
+
+// zshTime times tc.command with zsh's time reserved word and returns the
+// report it printed.
+func zshTime(shell string, tc compatCase) (string, error) {
+	script := "TIMEFMT=" + shellQuote(tc.timefmt) + "; time " + tc.command
+
+	var stderr bytes.Buffer
+
+	//nolint:gosec // The shell is chosen by the user and the script comes from the built-in corpus.
+	cmd := exec.CommandContext(context.Background(), shell, "-f", "-c", script)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("running %s: %w", shell, err)
+	}
+
+	return strings.TrimSuffix(stderr.String(), "\n"), nil
+}
+
+// compareOutputs reports how got differs from want. The text around numbers
+// must match exactly; the numbers themselves may drift, since the two runs
+// are measured separately. Decimals are timings and may differ by tolerance
+// seconds; integers are counts or percentages and may differ by 10% (at
+// least 2).
+func compareOutputs(got, want string, tolerance float64) string {
+	number := regexp.MustCompile(`\d+(\.\d+)?`)
+
+	if number.ReplaceAllString(got, "#") != number.ReplaceAllString(want, "#") {
+		return "format differs"
+	}
+
+	gotNums := number.FindAllString(got, -1)
+	wantNums := number.FindAllString(want, -1)
+
+	for i := range gotNums {
+		g, _ := strconv.ParseFloat(gotNums[i], 64)
+		w, _ := strconv.ParseFloat(wantNums[i], 64)
+
+		allowed := tolerance
+		if !strings.Contains(gotNums[i], ".") && !strings.Contains(wantNums[i], ".") {
+			allowed = math.Max(2, 0.1*math.Max(g, w))
+		}
+
+		if math.Abs(g-w) > allowed {
+			return fmt.Sprintf("value %s differs from %s", gotNums[i], wantNums[i])
+		}
+	}
+
+	return ""
+}
+
+func printCompatResult(r compatResult, verbose bool) {
+	faint := lipgloss.NewStyle().Faint(true)
+
+	if r.problem == "" {
+		fmt.Fprintf(os.Stderr, "%s %s %s\n", palette.good.Render("ok  "), r.command, faint.Render(strconv.Quote(r.timefmt)))
+	} else {
+		fmt.Fprintf(os.Stderr, "%s %s %s: %s\n", palette.bad.Render("FAIL"), r.command, faint.Render(strconv.Quote(r.timefmt)), r.problem)
+	}
+
+	if r.problem != "" || verbose {
+		fmt.Fprintf(os.Stderr, "       ztime: %q\n", r.ztime)
+		fmt.Fprintf(os.Stderr, "   reference: %q\n", r.reference)
+	}
+}
+
+// shellQuote quotes s for POSIX-style shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestCompareOutputs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		got      string
+		want     string
+		expected string
+	}{
+		{
+			name: "Identical",
+			got:  "sleep 1 took 1.00s",
+			want: "sleep 1 took 1.00s",
+		},
+		{
+			name: "Timing Within Tolerance",
+			got:  "0.21s user",
+			want: "0.24s user",
+		},
+		{
+			name:     "Timing Outside Tolerance",
+			got:      "0.21s user",
+			want:     "0.40s user",
+			expected: "value 0.21 differs from 0.40",
+		},
+		{
+			name: "Counts Within Tolerance",
+			got:  "1% cpu 1000 faults",
+			want: "0% cpu 1080 faults",
+		},
+		{
+			name:     "Format Differs",
+			got:      "0:00.20",
+			want:     "0.201",
+			expected: "format differs",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := compareOutputs(tt.got, tt.want, 0.05)
+			if got != tt.expected {
+				t.Errorf("compareOutputs() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	t.Parallel()
+
+	if got, want := shellQuote("it's %J"), `'it'\''s %J'`; got != want {
+		t.Errorf("shellQuote() = %s, want %s", got, want)
+	}
+}
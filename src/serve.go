@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// serveShutdownTimeout bounds how long ztime serve waits for runs in
+// progress when it is stopped.
+const serveShutdownTimeout = 30 * time.Second
+
+// serveCmd runs commands on request over HTTP and keeps their metrics.
+type serveCmd struct {
+	Listen   string        `default:"localhost:8080" help:"Address to listen on. Anyone who can reach it with the token can run commands as this user." placeholder:"ADDR"`
+	Token    string        `env:"ZTIME_TOKEN" help:"Require this bearer token on every request. Without it, a random token is generated and printed." placeholder:"TOKEN"`
+	Interval time.Duration `default:"1s" help:"Time between sample events streamed while a command runs; 0 disables them."`
+}
+
+// runRequest is the body of POST /runs.
+type runRequest struct {
+	Argv []string `json:"argv"`
+}
+
+// server is the state of ztime serve: the per-command statistics behind
+// /metrics.
+type server struct {
+	cmd *serveCmd
+
+	mu    sync.Mutex
+	stats map[string]*commandStats
+}
+
+// commandStats accumulates the runs of one command line for /metrics.
+type commandStats struct {
+	runs     int
+	failures int
+	elapsed  float64 // total, in seconds
+	last     float64 // latest elapsed time, in seconds
+}
+
+// Run serves the API until interrupted, then waits for runs in progress.
+func (c *serveCmd) Run() error {
+	if c.Token == "" {
+		c.Token = rand.Text()
+		fmt.Fprintf(os.Stderr, "ztime: no --token given; requests must send Authorization: Bearer %s\n", c.Token)
+	}
+
+	s := &server{cmd: c, stats: make(map[string]*commandStats)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /runs", s.handleRun)
+	mux.HandleFunc("GET /runs", s.handleHistory)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+
+	srv := &http.Server{Addr: c.Listen, Handler: s.authorize(mux), ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	fmt.Fprintf(os.Stderr, "ztime: serving on %s\n", c.Listen)
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+	defer cancel()
+
+	return srv.Shutdown(shutdown)
+}
+
+// authorize rejects requests without the --token bearer token, and those
+// sent by a web page from another origin, so that a page the user visits
+// cannot run commands through the service.
+func (s *server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !sameOrigin(r) {
+			http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+
+			return
+		}
+
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if s.cmd.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.cmd.Token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sameOrigin reports whether r has no Origin header, as from curl or a CI
+// job, or one naming the host it was sent to.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && strings.EqualFold(u.Host, r.Host)
+}
+
+// handleRun runs the command in the request body once, streaming its
+// --jsonl events as they happen, and records the run in the history. The
+// command gets an empty stdin; its output goes to the server's.
+func (s *server) handleRun(w http.ResponseWriter, r *http.Request) {
+	if kind, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || kind != "application/json" {
+		http.Error(w, "expected Content-Type: application/json", http.StatusUnsupportedMediaType)
+
+		return
+	}
+
+	var req runRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Argv) == 0 {
+		http.Error(w, `expected {"argv": ["command", "arg", ...]}`, http.StatusBadRequest)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	c := &runCmd{Command: req.Argv, StdinNull: true}
+	c.events = newEventStream(flushWriter{w}, s.cmd.Interval)
+
+	m, err := c.measure(req.Argv, c.runOptions(runOptions{totalRuns: 1}))
+	s.record(m)
+
+	if err != nil && m.PID == 0 {
+		c.events.emit(event{Type: eventError, Error: err.Error()})
+
+		return
+	}
+
+	if path, err := historyPath(req.Argv); err == nil {
+		if err := appendHistory(path, m); err != nil {
+			fmt.Fprintf(os.Stderr, "ztime: recording run: %v\n", err)
+		}
+	}
+}
+
+// handleHistory returns the recorded runs of the command line given by the
+// repeated argv query parameter, oldest first.
+func (s *server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	argv := r.URL.Query()["argv"]
+	if len(argv) == 0 {
+		http.Error(w, "expected ?argv=command&argv=arg...", http.StatusBadRequest)
+
+		return
+	}
+
+	path, err := historyPath(argv)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	runs, err := loadHistory(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	results := make([]any, 0, len(runs))
+	for _, m := range runs {
+		results = append(results, versioned(m))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(results)
+}
+
+// handleMetrics writes the per-command statistics in the Prometheus text
+// format.
+func (s *server) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writePrometheus(w, s.stats)
+}
+
+// record adds a finished run to the statistics.
+func (s *server) record(m Metrics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.stats[m.Command]
+	if !ok {
+		st = &commandStats{}
+		s.stats[m.Command] = st
+	}
+
+	st.runs++
+	st.elapsed += m.ElapsedTime.Seconds()
+	st.last = m.ElapsedTime.Seconds()
+
+	if m.ExitCode != 0 {
+		st.failures++
+	}
+}
+
+// writePrometheus writes stats in the Prometheus text exposition format,
+// one series per command line.
+func writePrometheus(w io.Writer, stats map[string]*commandStats) {
+	metrics := []struct {
+		name, kind, help string
+		value            func(*commandStats) float64
+	}{
+		{"ztime_runs_total", "counter", "Runs of the command.", func(s *commandStats) float64 { return float64(s.runs) }},
+		{"ztime_run_failures_total", "counter", "Runs that exited non-zero or could not start.", func(s *commandStats) float64 { return float64(s.failures) }},
+		{"ztime_run_elapsed_seconds_total", "counter", "Total elapsed time of the runs.", func(s *commandStats) float64 { return s.elapsed }},
+		{"ztime_run_last_elapsed_seconds", "gauge", "Elapsed time of the latest run.", func(s *commandStats) float64 { return s.last }},
+	}
+
+	commands := slices.Sorted(maps.Keys(stats))
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+	for _, metric := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", metric.name, metric.help, metric.name, metric.kind)
+
+		for _, command := range commands {
+			fmt.Fprintf(w, "%s{command=\"%s\"} %g\n", metric.name, escape.Replace(command), metric.value(stats[command]))
+		}
+	}
+}
+
+// flushWriter flushes every write to the client, so that streamed events
+// arrive as they happen.
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err == nil {
+		err = http.NewResponseController(f.w).Flush()
+	}
+
+	if errors.Is(err, http.ErrNotSupported) {
+		err = nil
+	}
+
+	return n, err
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeAuthorize(t *testing.T) {
+	t.Parallel()
+
+	s := &server{cmd: &serveCmd{Token: "secret"}}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })
+
+	tests := []struct {
+		name   string
+		header string
+		origin string
+		status int
+	}{
+		{name: "Missing", status: http.StatusUnauthorized},
+		{name: "Wrong", header: "Bearer guess", status: http.StatusUnauthorized},
+		{name: "Correct", header: "Bearer secret", status: http.StatusNoContent},
+		{name: "Same Origin", header: "Bearer secret", origin: "http://example.com", status: http.StatusNoContent},
+		{name: "Cross Origin", header: "Bearer secret", origin: "https://evil.test", status: http.StatusForbidden},
+		{name: "Null Origin", header: "Bearer secret", origin: "null", status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+
+		rec := httptest.NewRecorder()
+		s.authorize(ok).ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.status)
+		}
+	}
+}
+
+func TestServeRunBadRequest(t *testing.T) {
+	t.Parallel()
+
+	s := &server{cmd: &serveCmd{}, stats: make(map[string]*commandStats)}
+
+	for _, body := range []string{"", "{}", `{"argv": []}`, "not json"} {
+		req := httptest.NewRequest(http.MethodPost, "/runs", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		rec := httptest.NewRecorder()
+		s.handleRun(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("POST /runs %q: status = %d, want %d", body, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestServeRunContentType(t *testing.T) {
+	t.Parallel()
+
+	s := &server{cmd: &serveCmd{}, stats: make(map[string]*commandStats)}
+
+	for _, kind := range []string{"", "text/plain", "application/x-www-form-urlencoded", "multipart/form-data; boundary=x"} {
+		req := httptest.NewRequest(http.MethodPost, "/runs", strings.NewReader(`{"argv": ["true"]}`))
+		if kind != "" {
+			req.Header.Set("Content-Type", kind)
+		}
+
+		rec := httptest.NewRecorder()
+		s.handleRun(rec, req)
+
+		if rec.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("POST /runs as %q: status = %d, want %d", kind, rec.Code, http.StatusUnsupportedMediaType)
+		}
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	t.Parallel()
+
+	s := &server{stats: make(map[string]*commandStats)}
+	s.record(Metrics{Command: "make", ElapsedTime: 2e9})
+	s.record(Metrics{Command: "make", ElapsedTime: 1e9, ExitCode: 2})
+	s.record(Metrics{Command: `say "hi"`, ElapsedTime: 5e8})
+
+	var b strings.Builder
+	writePrometheus(&b, s.stats)
+
+	for _, want := range []string{
+		"# TYPE ztime_runs_total counter\n",
+		`ztime_runs_total{command="make"} 2` + "\n",
+		`ztime_run_failures_total{command="make"} 1` + "\n",
+		`ztime_run_elapsed_seconds_total{command="make"} 3` + "\n",
+		`ztime_run_last_elapsed_seconds{command="make"} 1` + "\n",
+		`ztime_runs_total{command="say \"hi\""} 1` + "\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("writePrometheus() output lacks %q:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"os/exec"
+)
+
+// shellCommand returns a command that runs script with the system shell.
+func shellCommand(ctx context.Context, script string) *exec.Cmd {
+	//nolint:gosec // Intended behavior: the user supplies the script.
+	return exec.CommandContext(ctx, "cmd", "/C", script)
+}
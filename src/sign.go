@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	errNoPEM          = errors.New("no PEM block found")
+	errNotEd25519     = errors.New("key is not an Ed25519 key")
+	errBadSignature   = errors.New("signature does not match the result")
+	errKeyMismatch    = errors.New("result was signed by a different key")
+	errBadAlgorithm   = errors.New("unsupported signature algorithm")
+	errMalformedField = errors.New("malformed signature field")
+)
+
+const signatureAlgorithm = "ed25519"
+
+// SignedResult wraps a JSON result with an Ed25519 signature over its
+// canonical form, so edits to the result can be detected.
+type SignedResult struct {
+	Result    json.RawMessage `json:"result"`
+	Signature Signature       `json:"signature"`
+}
+
+// Signature is a detached signature over a canonicalized JSON result.
+type Signature struct {
+	Algorithm string `json:"algorithm"`  // always "ed25519"
+	PublicKey string `json:"public_key"` // base64
+	Value     string `json:"value"`      // base64
+}
+
+// verifyCmd checks a result written with --sign or --attest.
+type verifyCmd struct {
+	File string `arg:"" type:"existingfile" help:"Signed JSON result or attestation to verify."`
+	Key  string `type:"existingfile" help:"PEM public key the result must be signed with."`
+}
+
+// Run verifies the signature and, with --key, the signer.
+func (c *verifyCmd) Run() error {
+	data, err := os.ReadFile(c.File)
+	if err != nil {
+		return fmt.Errorf("reading result: %w", err)
+	}
+
+	var want ed25519.PublicKey
+
+	if c.Key != "" {
+		if want, err = loadPublicKey(c.Key); err != nil {
+			return err
+		}
+	}
+
+	if bytes.Contains(data, []byte(`"payloadType"`)) {
+		return c.verifyAttestation(data, want)
+	}
+
+	signer, err := verifyResult(data, want)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: valid signature by %s\n", c.File, base64.StdEncoding.EncodeToString(signer))
+
+	return nil
+}
+
+// verifyAttestation checks an attestation and reports what it states.
+func (c *verifyCmd) verifyAttestation(data []byte, want ed25519.PublicKey) error {
+	signer, statement, err := verifyAttestation(data, want)
+	if err != nil {
+		return err
+	}
+
+	p := statement.Predicate
+	commit := statement.Subject[0].Digest["gitCommit"]
+
+	if p.Git != nil && p.Git.Dirty {
+		commit += " (dirty)"
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: valid attestation by %s\n", c.File, base64.StdEncoding.EncodeToString(signer))
+	fmt.Fprintf(os.Stderr, "  commit  %s of %s\n", commit, statement.Subject[0].Name)
+	fmt.Fprintf(os.Stderr, "  machine %s, ztime %s\n", p.Builder.ID, p.Builder.Version)
+
+	return nil
+}
+
+// signResult marshals v and wraps it in a SignedResult.
+func signResult(v any, key ed25519.PrivateKey) ([]byte, error) {
+	result, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("encoding result: %w", err)
+	}
+
+	canonical, err := canonicalJSON(result)
+	if err != nil {
+		return nil, err
+	}
+
+	signed := SignedResult{
+		Result: canonical,
+		Signature: Signature{
+			Algorithm: signatureAlgorithm,
+			PublicKey: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)), //nolint:forcetypeassert // Always an ed25519.PublicKey.
+			Value:     base64.StdEncoding.EncodeToString(ed25519.Sign(key, canonical)),
+		},
+	}
+
+	data, err := json.MarshalIndent(signed, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encoding signed result: %w", err)
+	}
+
+	return data, nil
+}
+
+// verifyResult checks a SignedResult and returns the key that signed it. If
+// want is non-nil, the result must have been signed by that key.
+func verifyResult(data []byte, want ed25519.PublicKey) (ed25519.PublicKey, error) {
+	var signed SignedResult
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, fmt.Errorf("parsing signed result: %w", err)
+	}
+
+	if signed.Signature.Algorithm != signatureAlgorithm {
+		return nil, fmt.Errorf("%w: %q", errBadAlgorithm, signed.Signature.Algorithm)
+	}
+
+	pub, err := base64.StdEncoding.DecodeString(signed.Signature.PublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: public_key", errMalformedField)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signed.Signature.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: value", errMalformedField)
+	}
+
+	if want != nil && !want.Equal(ed25519.PublicKey(pub)) {
+		return nil, errKeyMismatch
+	}
+
+	canonical, err := canonicalJSON(signed.Result)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ed25519.Verify(pub, canonical, sig) {
+		return nil, errBadSignature
+	}
+
+	return pub, nil
+}
+
+// canonicalJSON re-encodes data with sorted object keys, no insignificant
+// whitespace, and numbers kept exactly as written.
+func canonicalJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("canonicalizing JSON: %w", err)
+	}
+
+	var out bytes.Buffer
+
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(v); err != nil {
+		return nil, fmt.Errorf("canonicalizing JSON: %w", err)
+	}
+
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
+
+// loadSigningKey reads a PKCS #8 PEM Ed25519 private key, as written by
+// "openssl genpkey -algorithm ed25519".
+func loadSigningKey(path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", path, errNotEd25519)
+	}
+
+	return priv, nil
+}
+
+// loadPublicKey reads a PKIX PEM Ed25519 public key, as written by
+// "openssl pkey -pubout".
+func loadPublicKey(path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", path, errNotEd25519)
+	}
+
+	return pub, nil
+}
+
+func readPEM(path string) ([]byte, error) {
+	//nolint:gosec // Intended behavior: the user names the key file.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: %w", path, errNoPEM)
+	}
+
+	return block.Bytes, nil
+}
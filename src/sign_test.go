@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+)
+
+func TestSignAndVerifyResult(t *testing.T) {
+	t.Parallel()
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	data, err := signResult(Metrics{Command: "make", ExitCode: 0}, priv)
+	if err != nil {
+		t.Fatalf("signResult() error = %v", err)
+	}
+
+	signer, err := verifyResult(data, pub)
+	if err != nil {
+		t.Fatalf("verifyResult() error = %v", err)
+	}
+
+	if !signer.Equal(pub) {
+		t.Errorf("verifyResult() signer = %x, want %x", signer, pub)
+	}
+
+	if _, err := verifyResult(data, otherPub); !errors.Is(err, errKeyMismatch) {
+		t.Errorf("verifyResult() with other key error = %v, want %v", err, errKeyMismatch)
+	}
+
+	tampered := bytes.Replace(data, []byte(`"command": "make"`), []byte(`"command": "true"`), 1)
+	if bytes.Equal(tampered, data) {
+		t.Fatal("tampering did not change the result")
+	}
+
+	if _, err := verifyResult(tampered, nil); !errors.Is(err, errBadSignature) {
+		t.Errorf("verifyResult() on tampered result error = %v, want %v", err, errBadSignature)
+	}
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	t.Parallel()
+
+	got, err := canonicalJSON([]byte(`{ "b": 1.50, "a": {"d": "<x>", "c": [2, 1]} }`))
+	if err != nil {
+		t.Fatalf("canonicalJSON() error = %v", err)
+	}
+
+	expected := `{"a":{"c":[2,1],"d":"<x>"},"b":1.50}`
+	if string(got) != expected {
+		t.Errorf("canonicalJSON() = %s, want %s", got, expected)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"time"
+)
+
+// SignalEvent records a signal ztime received while the command ran.
+type SignalEvent struct {
+	Signal    string        `json:"signal"`
+	Offset    time.Duration `json:"offset"` // since run start
+	Forwarded bool          `json:"forwarded"`
+	Error     string        `json:"error,omitempty"`
+}
+
+type receivedSignal struct {
+	sig       os.Signal
+	at        time.Time
+	forwarded bool
+	err       error
+}
+
+// signalForwarder relays signals delivered to ztime to the child process and
+// keeps an audit trail of everything it saw.
+type signalForwarder struct {
+	sigChan  chan os.Signal
+	done     chan struct{}
+	received []receivedSignal
+}
+
+// forwardSignals relays signals to cmd once it has started, or to its
+// whole process group with --kill-children.
+func forwardSignals(cmd *exec.Cmd, opts runOptions) *signalForwarder {
+	return relaySignals(opts.signals, opts.debug, func(sig os.Signal) (bool, error) {
+		if cmd.Process == nil {
+			return false, nil
+		}
+
+		var err error
+		if opts.killChildren {
+			err = signalGroup(cmd.Process, sig)
+		} else {
+			err = cmd.Process.Signal(sig)
+		}
+
+		return err == nil, err
+	})
+}
+
+// relaySignals passes the given signals delivered to ztime to send, which
+// reports whether it forwarded the signal; nil means signalList(). Signals
+// not in the list keep their usual effect on ztime. A suspend signal
+// suspends ztime too once it is passed on, as it would have if ztime did not
+// catch it, so the shell sees the job as stopped. Window size changes are
+// passed on without being recorded, as resizing sends many.
+func relaySignals(signals []os.Signal, debug *debugLog, send func(os.Signal) (bool, error)) *signalForwarder {
+	f := &signalForwarder{
+		sigChan: make(chan os.Signal, 1),
+		done:    make(chan struct{}),
+	}
+
+	if signals == nil {
+		signals = signalList()
+	}
+
+	if len(signals) > 0 {
+		signal.Notify(f.sigChan, signals...)
+	}
+
+	go func() {
+		defer close(f.done)
+
+		for sig := range f.sigChan {
+			event := receivedSignal{sig: sig, at: time.Now()}
+			event.forwarded, event.err = send(sig)
+
+			switch {
+			case event.err != nil:
+				debug.printf("received %s, forwarding failed: %v", signalName(sig), event.err)
+			case event.forwarded:
+				debug.printf("received %s, forwarded to the command", signalName(sig))
+			default:
+				debug.printf("received %s before the command started", signalName(sig))
+			}
+
+			if isSuspend(sig) {
+				debug.printf("suspending ztime")
+				suspendSelf()
+			}
+
+			if !isResize(sig) {
+				f.received = append(f.received, event)
+			}
+		}
+	}()
+
+	return f
+}
+
+// interrupted reports whether a run was interrupted through ztime: whether
+// it received a signal other than those of job control and window resizing,
+// which leave the command running.
+func interrupted(log []SignalEvent) bool {
+	return slices.ContainsFunc(log, func(e SignalEvent) bool {
+		switch e.Signal {
+		case "SIGTSTP", "SIGCONT", "SIGWINCH":
+			return false
+		default:
+			return true
+		}
+	})
+}
+
+// parseSignals looks up the signals of --forward-signals by name, such as
+// "INT" or "SIGTERM".
+func parseSignals(names []string) ([]os.Signal, error) {
+	signals := make([]os.Signal, 0, len(names))
+
+	for _, name := range names {
+		sig, ok := signalByName(name)
+		if !ok {
+			return nil, fmt.Errorf("--forward-signals: %w: %s", errUnknownSignal, name)
+		}
+
+		signals = append(signals, sig)
+	}
+
+	return signals, nil
+}
+
+// stop ends forwarding and returns the audit trail relative to start.
+func (f *signalForwarder) stop(start time.Time) []SignalEvent {
+	signal.Stop(f.sigChan)
+	close(f.sigChan)
+	<-f.done
+
+	events := make([]SignalEvent, 0, len(f.received))
+	for _, r := range f.received {
+		event := SignalEvent{
+			Signal:    signalName(r.sig),
+			Offset:    r.at.Sub(start),
+			Forwarded: r.forwarded,
+		}
+		if r.err != nil {
+			event.Error = r.err.Error()
+		}
+
+		events = append(events, event)
+	}
+
+	return events
+}
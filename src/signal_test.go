@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestSignalForwarderAuditTrail(t *testing.T) {
+	t.Parallel()
+
+	// The command is never started, so signals are recorded but not forwarded.
+	cmd := exec.CommandContext(t.Context(), "true")
+	start := time.Now()
+
+	f := forwardSignals(cmd, runOptions{})
+	f.sigChan <- os.Interrupt
+
+	events := f.stop(start)
+	if len(events) != 1 {
+		t.Fatalf("stop() returned %d events, want 1", len(events))
+	}
+
+	event := events[0]
+	if event.Signal != signalName(os.Interrupt) {
+		t.Errorf("Signal = %q, want %q", event.Signal, signalName(os.Interrupt))
+	}
+
+	if event.Forwarded {
+		t.Error("Forwarded = true for a command that never started")
+	}
+
+	if event.Offset < 0 {
+		t.Errorf("Offset = %v, want >= 0", event.Offset)
+	}
+}
+
+func TestInterrupted(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		log  []SignalEvent
+		want bool
+	}{
+		{"none", nil, false},
+		{"interrupt", []SignalEvent{{Signal: "SIGINT"}}, true},
+		{"suspended and resumed", []SignalEvent{{Signal: "SIGTSTP"}, {Signal: "SIGCONT"}}, false},
+		{"suspended then terminated", []SignalEvent{{Signal: "SIGTSTP"}, {Signal: "SIGTERM"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := interrupted(tt.log); got != tt.want {
+				t.Errorf("interrupted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
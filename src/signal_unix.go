@@ -4,9 +4,16 @@ package main
 
 import (
 	"os"
+	"os/exec"
+	"strings"
 	"syscall"
+
+	"golang.org/x/sys/unix"
 )
 
+// signalList returns the signals forwarded by default: every catchable
+// signal a user or the terminal sends to a foreground job. SIGCHLD, SIGPIPE,
+// and SIGURG are about ztime itself, and the Go runtime uses SIGURG.
 func signalList() []os.Signal {
 	return []os.Signal{
 		syscall.SIGHUP,
@@ -15,5 +22,50 @@ func signalList() []os.Signal {
 		syscall.SIGTERM,
 		syscall.SIGUSR1,
 		syscall.SIGUSR2,
+		syscall.SIGALRM,
+		syscall.SIGWINCH,
+		syscall.SIGTSTP,
+		syscall.SIGCONT,
+	}
+}
+
+// isSuspend reports whether sig is the terminal's suspend signal (Ctrl-Z).
+func isSuspend(sig os.Signal) bool {
+	return sig == syscall.SIGTSTP
+}
+
+// isResize reports whether sig reports a change of the terminal's size.
+func isResize(sig os.Signal) bool {
+	return sig == syscall.SIGWINCH
+}
+
+// suspendSelf stops ztime until it is continued. SIGSTOP cannot be caught,
+// so this works while ztime is catching SIGTSTP.
+func suspendSelf() {
+	_ = unix.Kill(os.Getpid(), unix.SIGSTOP)
+}
+
+// signalName returns the conventional name of sig, such as "SIGINT".
+func signalName(sig os.Signal) string {
+	if s, ok := sig.(syscall.Signal); ok {
+		if name := unix.SignalName(s); name != "" {
+			return name
+		}
 	}
+
+	return sig.String()
+}
+
+// detachSignals starts cmd in its own process group, so that signals from
+// the terminal reach only ztime, which relays them itself.
+func detachSignals(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalByName returns the signal with the given name, such as "QUIT" or
+// "SIGQUIT".
+func signalByName(name string) (os.Signal, bool) {
+	sig := unix.SignalNum("SIG" + strings.TrimPrefix(strings.ToUpper(name), "SIG"))
+
+	return sig, sig != 0
 }
@@ -0,0 +1,57 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseSignals(t *testing.T) {
+	t.Parallel()
+
+	got, err := parseSignals([]string{"INT", "sigterm", "SIGWINCH"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGWINCH}
+	if len(got) != len(want) {
+		t.Fatalf("parseSignals() = %v, want %v", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("parseSignals()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	if _, err := parseSignals([]string{"NOPE"}); err == nil {
+		t.Error("parseSignals() accepted an unknown signal")
+	}
+}
+
+func TestRelaySignalsResize(t *testing.T) {
+	t.Parallel()
+
+	var sent []os.Signal
+
+	f := relaySignals([]os.Signal{syscall.SIGWINCH, syscall.SIGUSR1}, nil, func(sig os.Signal) (bool, error) {
+		sent = append(sent, sig)
+
+		return true, nil
+	})
+	f.sigChan <- syscall.SIGWINCH
+	f.sigChan <- syscall.SIGUSR1
+
+	events := f.stop(time.Now())
+	if len(sent) != 2 {
+		t.Errorf("forwarded %v, want SIGWINCH and SIGUSR1", sent)
+	}
+
+	if len(events) != 1 || events[0].Signal != "SIGUSR1" {
+		t.Errorf("stop() = %v, want only SIGUSR1 recorded", events)
+	}
+}
@@ -2,8 +2,38 @@
 
 package main
 
-import "os"
+import (
+	"os"
+	"os/exec"
+)
 
 func signalList() []os.Signal {
 	return []os.Signal{os.Interrupt}
 }
+
+// isSuspend always reports false: Windows has no suspend signal.
+func isSuspend(os.Signal) bool {
+	return false
+}
+
+// isResize always reports false: console resizes are not signals.
+func isResize(os.Signal) bool {
+	return false
+}
+
+func suspendSelf() {}
+
+// signalName returns the name of sig. Windows has no SIG* naming convention.
+func signalName(sig os.Signal) string {
+	return sig.String()
+}
+
+// detachSignals does nothing: console interrupts reach every process
+// attached to the console.
+func detachSignals(*exec.Cmd) {}
+
+// signalByName always fails: Windows cannot deliver named signals to
+// another process.
+func signalByName(string) (os.Signal, bool) {
+	return nil, false
+}
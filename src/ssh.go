@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// sshPIDPrefix marks the line on which the remote shell reports the PID of
+// the ztime it is about to exec, so that signals can be relayed to it.
+const sshPIDPrefix = "ZTIME_PID="
+
+// RemoteRun describes how a --ssh run reached its host. The run's own
+// times are those the remote ztime measured.
+type RemoteRun struct {
+	Host      string        `json:"host"`
+	RoundTrip time.Duration `json:"round_trip"` // from starting ssh until it exited
+	Overhead  time.Duration `json:"overhead"`   // round trip minus the remote elapsed time: connecting, starting ztime, and relaying
+}
+
+// runSSH runs args on the --ssh host under the ztime installed there and
+// returns the metrics it reports, with the local round trip. Signals ztime
+// receives are relayed to the remote ztime with kill, which passes them on
+// to the command; ssh runs in its own process group so that it does not
+// receive them too and drop the connection.
+func (c *runCmd) runSSH(args []string, opts runOptions) (Metrics, error) {
+	command := strings.Join(args, " ")
+
+	words := c.sshArgs(args)
+
+	//nolint:gosec // Intended behavior: the host and remote command come from the user.
+	cmd := exec.CommandContext(context.Background(), words[0], words[1:]...)
+	cmd.Stdout = os.Stdout
+	detachSignals(cmd)
+
+	// A terminal cannot be read from another process group, and the remote
+	// command has none anyway.
+	if !isTerminal(os.Stdin) {
+		cmd.Stdin = os.Stdin
+	}
+
+	var instruments []instrument
+
+	if opts.stdinPath != "" {
+		in, err := openStdin(cmd, opts.stdinPath)
+		if err != nil {
+			return Metrics{Command: command, ExitCode: -1}, err
+		}
+
+		instruments = append(instruments, in)
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return Metrics{Command: command, ExitCode: -1}, err
+	}
+
+	var pid atomic.Int64
+
+	// The remote command has no terminal to resize.
+	forwarder := relaySignals(opts.signals, opts.debug, func(sig os.Signal) (bool, error) {
+		if pid.Load() == 0 || isResize(sig) {
+			return false, nil
+		}
+
+		err := c.killRemote(int(pid.Load()), sig)
+
+		return err == nil, err
+	})
+
+	opts.events.emit(event{Type: eventRunStarted, Iteration: max(opts.iteration, 1), Command: command, Argv: args})
+
+	start := time.Now()
+
+	var (
+		m  Metrics
+		ok bool
+	)
+
+	err = cmd.Start()
+	if err == nil {
+		m, ok = scanRemoteRun(stderr, &pid, os.Stderr)
+		err = cmd.Wait()
+	}
+
+	end := time.Now()
+
+	signalLog := forwarder.stop(start)
+
+	if !ok {
+		m = Metrics{Command: command, ExitCode: -1, StartTime: start, EndTime: end, Argv: args}
+		if cmd.ProcessState != nil {
+			err = fmt.Errorf("%s: %w", c.SSH, errNoRemoteResult)
+		}
+	}
+
+	if len(signalLog) > 0 {
+		m.SignalLog = signalLog
+	}
+
+	if ok {
+		m.Remote = &RemoteRun{Host: c.SSH, RoundTrip: end.Sub(start), Overhead: max(end.Sub(start)-m.ElapsedTime, 0)}
+	}
+
+	for _, inst := range instruments {
+		inst.finish(&m, start)
+	}
+
+	return m, err
+}
+
+// sshArgs is the local command line that runs args on the --ssh host.
+func (c *runCmd) sshArgs(args []string) []string {
+	return []string{c.SSHClient, "-o", "BatchMode=yes", "--", c.SSH, sshCommand(c.SSHZtime, args)}
+}
+
+// sshCommand is the shell command line that runs args under ztime on the
+// remote host, reporting results as --jsonl events on stderr. ztime is not
+// quoted, so it may use "~" or variables.
+func sshCommand(ztime string, args []string) string {
+	words := []string{"echo", sshPIDPrefix + "$$", ">&2;", "exec", ztime, "run", "--jsonl", "--jsonl-interval", "0"}
+	for _, arg := range args {
+		words = append(words, shellQuote(arg))
+	}
+
+	return strings.Join(words, " ")
+}
+
+// scanRemoteRun copies the remote stderr to w, taking out the PID line,
+// which it stores in pid, and the remote ztime's events, of which it
+// returns the run_finished result.
+func scanRemoteRun(r io.Reader, pid *atomic.Int64, w io.Writer) (Metrics, bool) {
+	var (
+		m  Metrics
+		ok bool
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16<<20)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		if value, found := bytes.CutPrefix(line, []byte(sshPIDPrefix)); found && pid.Load() == 0 {
+			if n, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+				pid.Store(n)
+
+				continue
+			}
+		}
+
+		if bytes.HasPrefix(line, []byte(`{"type":`)) {
+			var e struct {
+				Type   string          `json:"type"`
+				Result json.RawMessage `json:"result"`
+			}
+
+			if json.Unmarshal(line, &e) == nil {
+				if e.Type == eventRunFinished {
+					ok = json.Unmarshal(e.Result, &m) == nil
+				}
+
+				continue
+			}
+		}
+
+		_, _ = w.Write(append(line, '\n'))
+	}
+
+	return m, ok
+}
+
+// killRemote sends sig to the process pid on the --ssh host.
+func (c *runCmd) killRemote(pid int, sig os.Signal) error {
+	name := strings.TrimPrefix(signalName(sig), "SIG")
+
+	//nolint:gosec // Intended behavior: the host comes from the user.
+	cmd := exec.CommandContext(context.Background(), c.SSHClient, "-o", "BatchMode=yes", "--", c.SSH, "kill", "-s", name, strconv.Itoa(pid))
+
+	if out, err := helperOutput(cmd, true); err != nil {
+		return fmt.Errorf("%w%s", err, lastLine(string(out)))
+	}
+
+	return nil
+}
+
+// isTerminal reports whether f is a terminal, or another character device
+// such as /dev/null.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// remoteNote says where the command ran and what ssh added to its time, or
+// returns "" for a local run.
+func remoteNote(m Metrics) string {
+	if m.Remote == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("ran on %s; ssh round trip %.3fs, %.3fs of it overhead", m.Remote.Host, m.Remote.RoundTrip.Seconds(), m.Remote.Overhead.Seconds())
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSSHCommand(t *testing.T) {
+	t.Parallel()
+
+	got := sshCommand("~/bin/ztime", []string{"grep", "-r", "it's"})
+	want := `echo ZTIME_PID=$$ >&2; exec ~/bin/ztime run --jsonl --jsonl-interval 0 'grep' '-r' 'it'\''s'`
+
+	if got != want {
+		t.Errorf("sshCommand() = %s, want %s", got, want)
+	}
+}
+
+func TestScanRemoteRun(t *testing.T) {
+	t.Parallel()
+
+	input := strings.Join([]string{
+		"ZTIME_PID=4242",
+		`{"type":"run_started","iteration":1}`,
+		"warning: from the command",
+		"ZTIME_PID=1",
+		`{"type":"run_finished","iteration":1,"result":{"command":"make","elapsed_time":2000000000}}`,
+		`{"type": not an event`,
+	}, "\n")
+
+	var (
+		pid    atomic.Int64
+		stderr strings.Builder
+	)
+
+	m, ok := scanRemoteRun(strings.NewReader(input), &pid, &stderr)
+	if !ok || m.Command != "make" || m.ElapsedTime != 2*time.Second {
+		t.Errorf("scanRemoteRun() = %+v, %v; want the run_finished result", m, ok)
+	}
+
+	if pid.Load() != 4242 {
+		t.Errorf("pid = %d, want 4242", pid.Load())
+	}
+
+	if want := "warning: from the command\nZTIME_PID=1\n{\"type\": not an event\n"; stderr.String() != want {
+		t.Errorf("stderr = %q, want %q", stderr.String(), want)
+	}
+}
+
+func TestRunSSH(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	// The fake SSH client reports a one-second run that exited 3, and fails
+	// for the host "down".
+	ssh := filepath.Join(t.TempDir(), "ssh")
+	script := `#!/bin/sh
+while [ "$1" != "--" ]; do shift; done
+[ "$2" = down ] && { echo "connection refused" >&2; exit 255; }
+echo ZTIME_PID=$$ >&2
+echo '{"type":"run_finished","iteration":1,"result":{"command":"make","elapsed_time":1000000000,"exit_code":3}}' >&2
+exit 3
+`
+
+	if err := os.WriteFile(ssh, []byte(script), 0o700); err != nil { //nolint:gosec // The fake client must be executable.
+		t.Fatal(err)
+	}
+
+	c := runCmd{SSH: "up", SSHClient: ssh, SSHZtime: "ztime"}
+
+	m, err := c.runSSH([]string{"make"}, runOptions{stdinPath: os.DevNull})
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("runSSH() error = %v, want the remote exit status 3", err)
+	}
+
+	if m.ExitCode != 3 || m.ElapsedTime != time.Second || m.Remote == nil || m.Remote.Host != "up" {
+		t.Fatalf("runSSH() = %+v, want the remote result", m)
+	}
+
+	if m.Remote.Overhead != max(m.Remote.RoundTrip-time.Second, 0) {
+		t.Errorf("Overhead = %v, want the round trip %v less the remote elapsed time", m.Remote.Overhead, m.Remote.RoundTrip)
+	}
+
+	c.SSH = "down"
+
+	if m, err := c.runSSH([]string{"make"}, runOptions{stdinPath: os.DevNull}); !errors.Is(err, errNoRemoteResult) || m.Remote != nil {
+		t.Errorf("runSSH(down) = %+v, %v; want %v", m, err, errNoRemoteResult)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// DurationStats summarizes a set of duration samples.
+type DurationStats struct {
+	Mean   time.Duration `json:"mean"`
+	StdDev time.Duration `json:"stddev"`          // sample standard deviation
+	CV     float64       `json:"cv" unit:"ratio"` // coefficient of variation, StdDev/Mean
+	Min    time.Duration `json:"min"`
+	Max    time.Duration `json:"max"`
+	P50    time.Duration `json:"p50"`
+	P90    time.Duration `json:"p90"`
+	P95    time.Duration `json:"p95"`
+	P99    time.Duration `json:"p99"`
+}
+
+func summarize(samples []time.Duration) DurationStats {
+	if len(samples) == 0 {
+		return DurationStats{}
+	}
+
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+
+	var sum float64
+	for _, s := range sorted {
+		sum += float64(s)
+	}
+
+	mean := sum / float64(len(sorted))
+
+	var squares float64
+	for _, s := range sorted {
+		squares += (float64(s) - mean) * (float64(s) - mean)
+	}
+
+	var stddev float64
+	if len(sorted) > 1 {
+		stddev = math.Sqrt(squares / float64(len(sorted)-1))
+	}
+
+	var cv float64
+	if mean > 0 {
+		cv = stddev / mean
+	}
+
+	return DurationStats{
+		Mean:   time.Duration(mean),
+		StdDev: time.Duration(stddev),
+		CV:     cv,
+		Min:    sorted[0],
+		Max:    sorted[len(sorted)-1],
+		P50:    percentile(sorted, 50),
+		P90:    percentile(sorted, 90),
+		P95:    percentile(sorted, 95),
+		P99:    percentile(sorted, 99),
+	}
+}
+
+// outlierThreshold is the modified z-score above which a sample counts as
+// an outlier, as recommended by Iglewicz and Hoaglin.
+const outlierThreshold = 3.5
+
+// outliers returns the indices of samples whose modified z-score, based on
+// the median absolute deviation, exceeds outlierThreshold. Unlike a z-score
+// built on the mean, it is not dragged along by the outliers themselves.
+// When more than half the samples are equal the MAD is zero, and the mean
+// absolute deviation is used instead.
+func outliers(samples []time.Duration) []int {
+	if len(samples) < 3 {
+		return nil
+	}
+
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+	median := percentile(sorted, 50)
+
+	deviations := make([]time.Duration, len(samples))
+	for i, s := range samples {
+		deviations[i] = (s - median).Abs()
+	}
+
+	var sum float64
+	for _, d := range deviations {
+		sum += float64(d)
+	}
+
+	slices.Sort(deviations)
+
+	scale := float64(percentile(deviations, 50)) / 0.6745
+	if scale == 0 {
+		scale = 1.253314 * sum / float64(len(deviations))
+	}
+
+	if scale == 0 {
+		return nil
+	}
+
+	var indices []int
+
+	for i, s := range samples {
+		if score := float64(s-median) / scale; math.Abs(score) > outlierThreshold {
+			indices = append(indices, i)
+		}
+	}
+
+	return indices
+}
+
+// percentile returns the p-th percentile of sorted samples, interpolating
+// linearly between the closest ranks.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	frac := rank - float64(lower)
+
+	return sorted[lower] + time.Duration(math.Round(frac*float64(sorted[upper]-sorted[lower])))
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	t.Parallel()
+
+	samples := []time.Duration{
+		5 * time.Second,
+		1 * time.Second,
+		3 * time.Second,
+		2 * time.Second,
+		4 * time.Second,
+	}
+
+	got := summarize(samples)
+	expected := DurationStats{
+		Mean:   3 * time.Second,
+		StdDev: 1581138830, // sqrt(2.5)s
+		Min:    1 * time.Second,
+		Max:    5 * time.Second,
+		P50:    3 * time.Second,
+		P90:    4600 * time.Millisecond,
+		P95:    4800 * time.Millisecond,
+		P99:    4960 * time.Millisecond,
+	}
+
+	if math.Abs(got.CV-0.527046) > 1e-6 {
+		t.Errorf("summarize().CV = %f, want 0.527046", got.CV)
+	}
+
+	got.CV = 0
+	if got != expected {
+		t.Errorf("summarize() = %+v, want %+v", got, expected)
+	}
+
+	if samples[0] != 5*time.Second {
+		t.Error("summarize() reordered its input")
+	}
+}
+
+func TestSummarizeEdgeCases(t *testing.T) {
+	t.Parallel()
+
+	if got := summarize(nil); got != (DurationStats{}) {
+		t.Errorf("summarize(nil) = %+v, want zero value", got)
+	}
+
+	one := summarize([]time.Duration{time.Second})
+	if one.Mean != time.Second || one.StdDev != 0 || one.P99 != time.Second {
+		t.Errorf("summarize(single) = %+v", one)
+	}
+}
+
+// runs builds benchmark results with the given elapsed times.
+func runs(elapsed ...time.Duration) []Metrics {
+	results := make([]Metrics, 0, len(elapsed))
+	for _, e := range elapsed {
+		results = append(results, Metrics{ElapsedTime: e})
+	}
+
+	return results
+}
+
+func TestStopReason(t *testing.T) {
+	t.Parallel()
+
+	steady := runs(time.Second, time.Second, time.Second)
+	noisy := runs(time.Second, 3*time.Second, time.Second)
+
+	tests := []struct {
+		name     string
+		cmd      runCmd
+		results  []Metrics
+		spent    time.Duration
+		expected string
+	}{
+		{name: "Fixed Count Within", cmd: runCmd{Runs: 3}, results: runs(time.Second), expected: ""},
+		{name: "Fixed Count Done", cmd: runCmd{Runs: 3}, results: steady, expected: "runs"},
+		{name: "Window Open", cmd: runCmd{Duration: time.Hour}, results: steady, spent: time.Minute, expected: ""},
+		{name: "Window Closed", cmd: runCmd{Duration: time.Second}, results: steady, spent: 2 * time.Second, expected: "duration"},
+		{name: "Window Always Runs Once", cmd: runCmd{Duration: time.Second}, spent: 2 * time.Second, expected: ""},
+		{name: "Count Caps Window", cmd: runCmd{Runs: 3, Duration: time.Hour}, results: steady, spent: time.Minute, expected: "runs"},
+		{name: "Stable", cmd: runCmd{MaxRuns: 10, TargetCV: 0.05}, results: steady, expected: "stable"},
+		{name: "Below Min Runs", cmd: runCmd{MinRuns: 5, TargetCV: 0.05}, results: steady, expected: ""},
+		{name: "Unstable", cmd: runCmd{MaxRuns: 10, TargetCV: 0.05}, results: noisy, expected: ""},
+		{name: "Max Runs", cmd: runCmd{MaxRuns: 3, TargetCV: 0.05}, results: noisy, expected: "max-runs"},
+		{name: "Min Runs Above Default Max", cmd: runCmd{MinRuns: 150, TargetCV: 0.05}, results: slices.Repeat(steady, 40), expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.cmd.stopReason(tt.results, tt.spent); got != tt.expected {
+				t.Errorf("stopReason() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOutliers(t *testing.T) {
+	t.Parallel()
+
+	ms := func(values ...int) []time.Duration {
+		samples := make([]time.Duration, len(values))
+		for i, v := range values {
+			samples[i] = time.Duration(v) * time.Millisecond
+		}
+
+		return samples
+	}
+
+	tests := []struct {
+		name     string
+		samples  []time.Duration
+		expected []int
+	}{
+		{name: "None", samples: ms(100, 102, 98, 101, 99), expected: nil},
+		{name: "Slow Run", samples: ms(100, 102, 98, 400, 101, 99), expected: []int{3}},
+		{name: "Both Sides", samples: ms(10, 100, 102, 98, 101, 99, 300), expected: []int{0, 6}},
+		{name: "Identical", samples: ms(100, 100, 100, 100), expected: nil},
+		{name: "Mostly Identical", samples: ms(100, 100, 100, 100, 500), expected: []int{4}},
+		{name: "Too Few", samples: ms(100, 900), expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := outliers(tt.samples); !slices.Equal(got, tt.expected) {
+				t.Errorf("outliers() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewBenchResultOutliers(t *testing.T) {
+	t.Parallel()
+
+	results := runs(time.Second, time.Second, time.Second, 5*time.Second, time.Second)
+
+	kept := newBenchResult(results, 9*time.Second, false)
+	if !slices.Equal(kept.Outliers, []int{4}) || kept.OutliersDropped || kept.Elapsed.Max != 5*time.Second {
+		t.Errorf("newBenchResult(keep) = outliers %v, dropped %v, max %v", kept.Outliers, kept.OutliersDropped, kept.Elapsed.Max)
+	}
+
+	dropped := newBenchResult(results, 9*time.Second, true)
+	if !dropped.OutliersDropped || dropped.Elapsed.Max != time.Second || dropped.Runs != 5 || len(dropped.Results) != 5 {
+		t.Errorf("newBenchResult(drop) = dropped %v, max %v, runs %d", dropped.OutliersDropped, dropped.Elapsed.Max, dropped.Runs)
+	}
+}
+
+func TestInvalidatedNote(t *testing.T) {
+	t.Parallel()
+
+	jumped := Metrics{ElapsedTime: time.Hour, ClockJump: time.Hour}
+
+	tests := []struct {
+		name     string
+		result   BenchResult
+		expected string
+	}{
+		{name: "None", result: BenchResult{Results: runs(time.Second)}, expected: ""},
+		{
+			name:     "Repeated",
+			result:   BenchResult{Invalidated: []Metrics{jumped}, Results: runs(time.Second)},
+			expected: "repeated 1 run(s) interrupted by a system suspend or clock change",
+		},
+		{
+			name:     "Kept After Repeats",
+			result:   BenchResult{Invalidated: []Metrics{jumped, jumped, jumped}, Results: []Metrics{jumped}},
+			expected: "repeated 3 run(s) interrupted by a system suspend or clock change, then kept 1 more; their elapsed times include the jump",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := invalidatedNote(tt.result); got != tt.expected {
+				t.Errorf("invalidatedNote() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// statsdPacket formats the metrics of a run as StatsD lines: timers for the
+// elapsed, user, and system times in milliseconds and a gauge for max_rss
+// in bytes. Tags are appended in the DogStatsD "|#key:value" form.
+func statsdPacket(prefix string, m Metrics, tags map[string]string) []byte {
+	var suffix string
+
+	if len(tags) > 0 {
+		pairs := make([]string, 0, len(tags))
+		for key, value := range tags {
+			pairs = append(pairs, key+":"+value)
+		}
+
+		slices.Sort(pairs)
+		suffix = "|#" + strings.Join(pairs, ",")
+	}
+
+	ms := func(d time.Duration) string {
+		return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64)
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%selapsed:%s|ms%s\n", prefix, ms(m.ElapsedTime), suffix)
+	fmt.Fprintf(&b, "%suser:%s|ms%s\n", prefix, ms(m.UserTime), suffix)
+	fmt.Fprintf(&b, "%ssystem:%s|ms%s\n", prefix, ms(m.SystemTime), suffix)
+	fmt.Fprintf(&b, "%smax_rss:%d|g%s\n", prefix, m.MaxRSS*1024, suffix)
+
+	return []byte(strings.TrimSuffix(b.String(), "\n"))
+}
+
+// sendStatsD sends the metrics of a run to --statsd in one UDP packet. As
+// with StatsD clients generally, a lost packet goes unnoticed, and other
+// errors are reported without failing the run.
+func (c *runCmd) sendStatsD(m Metrics) {
+	if c.statsd == nil || m.PID == 0 {
+		return
+	}
+
+	if _, err := c.statsd.Write(statsdPacket(c.StatsDPrefix, m, c.Tag)); err != nil {
+		fmt.Fprintf(os.Stderr, "ztime: statsd: %v\n", err)
+	}
+}
+
+// dialStatsD opens the UDP socket for --statsd.
+func dialStatsD(addr string) (net.Conn, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("--statsd: %w", err)
+	}
+
+	return conn, nil
+}
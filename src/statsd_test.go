@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatsDPacket(t *testing.T) {
+	t.Parallel()
+
+	m := Metrics{ElapsedTime: 1500 * time.Microsecond, UserTime: time.Second, MaxRSS: 2}
+
+	tests := []struct {
+		name     string
+		prefix   string
+		tags     map[string]string
+		expected string
+	}{
+		{
+			name:     "No Tags",
+			prefix:   "ztime.",
+			expected: "ztime.elapsed:1.5|ms\nztime.user:1000|ms\nztime.system:0|ms\nztime.max_rss:2048|g",
+		},
+		{
+			name:     "Sorted Tags",
+			prefix:   "ci.build.",
+			tags:     map[string]string{"os": "linux", "branch": "main"},
+			expected: "ci.build.elapsed:1.5|ms|#branch:main,os:linux\nci.build.user:1000|ms|#branch:main,os:linux\nci.build.system:0|ms|#branch:main,os:linux\nci.build.max_rss:2048|g|#branch:main,os:linux",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := string(statsdPacket(tt.prefix, m, tt.tags)); got != tt.expected {
+			t.Errorf("%s: statsdPacket() = %q, want %q", tt.name, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"time"
+)
+
+// stdinFile is the file given to the child as stdin by --stdin or
+// --stdin-null. It is opened afresh for every run, so each run of a
+// benchmark reads the same input from the start.
+type stdinFile struct {
+	file *os.File
+}
+
+func openStdin(cmd *exec.Cmd, path string) (*stdinFile, error) {
+	//nolint:gosec // Intended behavior: the user names the input file.
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening stdin: %w", err)
+	}
+
+	cmd.Stdin = f
+
+	return &stdinFile{file: f}, nil
+}
+
+func (s *stdinFile) finish(m *Metrics, _ time.Time) {
+	_ = s.file.Close()
+
+	m.StdinSource = s.file.Name()
+}
+
+// stdinMeter feeds ztime's stdin to the child through a pipe, recording how
+// many bytes the child received and when the first one arrived.
+type stdinMeter struct {
+	reader    *os.File
+	bytes     atomic.Int64
+	firstByte atomic.Pointer[time.Time]
+}
+
+func meterStdin(cmd *exec.Cmd, src io.Reader) (*stdinMeter, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("creating stdin pipe: %w", err)
+	}
+
+	meter := &stdinMeter{reader: r}
+	cmd.Stdin = r
+
+	go meter.pump(src, w)
+
+	return meter, nil
+}
+
+func (s *stdinMeter) pump(src io.Reader, dst io.WriteCloser) {
+	defer func() { _ = dst.Close() }()
+
+	buf := make([]byte, 32*1024)
+
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			now := time.Now()
+			s.firstByte.CompareAndSwap(nil, &now)
+
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return
+			}
+
+			s.bytes.Add(int64(n))
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// finish closes ztime's copy of the child's stdin and stores the counters in
+// m. The pump may still be blocked reading stdin; it exits on its next write.
+// Input that was ready before the run started counts as arriving at once.
+func (s *stdinMeter) finish(m *Metrics, start time.Time) {
+	_ = s.reader.Close()
+
+	m.StdinBytes = s.bytes.Load()
+	if first := s.firstByte.Load(); first != nil {
+		m.StdinFirstByte = max(first.Sub(start), 0)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type nopWriteCloser struct {
+	bytes.Buffer
+}
+
+func (*nopWriteCloser) Close() error { return nil }
+
+func TestStdinMeterPump(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	meter := &stdinMeter{}
+
+	var dst nopWriteCloser
+
+	meter.pump(strings.NewReader("hello\nworld\n"), &dst)
+
+	if got := dst.String(); got != "hello\nworld\n" {
+		t.Errorf("pump() delivered %q, want %q", got, "hello\nworld\n")
+	}
+
+	if got := meter.bytes.Load(); got != 12 {
+		t.Errorf("bytes = %d, want 12", got)
+	}
+
+	first := meter.firstByte.Load()
+	if first == nil || first.Before(start) {
+		t.Errorf("firstByte = %v, want a time after %v", first, start)
+	}
+}
+
+func TestAttachInstrumentsStdinFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "input")
+	if err := os.WriteFile(path, []byte("replay\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.CommandContext(context.Background(), "cat")
+
+	instruments, err := attachInstruments(cmd, runOptions{stdinPath: path})
+	if err != nil {
+		t.Fatalf("attachInstruments() error = %v", err)
+	}
+
+	if f, ok := cmd.Stdin.(*os.File); !ok || f.Name() != path {
+		t.Errorf("cmd.Stdin = %v, want %s", cmd.Stdin, path)
+	}
+
+	var m Metrics
+	for _, inst := range instruments {
+		inst.finish(&m, time.Now())
+	}
+
+	if m.StdinSource != path {
+		t.Errorf("StdinSource = %q, want %q", m.StdinSource, path)
+	}
+
+	if _, err := attachInstruments(cmd, runOptions{stdinPath: filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Error("attachInstruments() with a missing file succeeded")
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// stopPollInterval is how often --exclude-stopped checks whether the
+// command was stopped or continued.
+const stopPollInterval = 10 * time.Millisecond
+
+var errExcludeStoppedUnsupported = errors.New("--exclude-stopped is not supported")
+
+// jobEvent is a change of the command's job control state.
+type jobEvent int
+
+const (
+	noJobEvent jobEvent = iota
+	jobStopped
+	jobContinued
+)
+
+// stopWatch adds up the time the command spends stopped by SIGTSTP,
+// SIGSTOP, SIGTTIN, or SIGTTOU until it is continued.
+type stopWatch struct {
+	done    chan struct{}
+	stopped chan struct{}
+
+	stops int
+	total time.Duration
+	since time.Time // start of the current stop; zero while running
+}
+
+// watchStops starts polling pid for stops and continues. It returns nil if
+// enabled is false.
+func watchStops(enabled bool, pid int) *stopWatch {
+	if !enabled {
+		return nil
+	}
+
+	w := &stopWatch{done: make(chan struct{}), stopped: make(chan struct{})}
+
+	go func() {
+		defer close(w.stopped)
+
+		ticker := time.NewTicker(stopPollInterval)
+		defer ticker.Stop()
+
+		polled := time.Now()
+
+		for {
+			now := time.Now()
+
+			// A stop is dated from the previous poll: on Ctrl-Z ztime is
+			// suspended along with the command and sees the stop only once
+			// both are continued.
+			for e := childJobEvent(pid); e != noJobEvent; e = childJobEvent(pid) {
+				at := now
+				if e == jobStopped {
+					at = polled
+				}
+
+				w.record(e, at)
+			}
+
+			polled = now
+
+			select {
+			case <-w.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return w
+}
+
+func (w *stopWatch) record(e jobEvent, now time.Time) {
+	switch {
+	case e == jobStopped && w.since.IsZero():
+		w.stops++
+		w.since = now
+	case e == jobContinued && !w.since.IsZero():
+		w.total += now.Sub(w.since)
+		w.since = time.Time{}
+	}
+}
+
+// stop ends the polling and takes the stopped time out of the elapsed time
+// in m, keeping the wall-clock time in WallTime. A stop still going on when
+// the command exited, as when it was killed while stopped, lasts until the
+// end of the run.
+func (w *stopWatch) stop(m *Metrics) {
+	if w == nil {
+		return
+	}
+
+	close(w.done)
+	<-w.stopped
+
+	if !w.since.IsZero() {
+		w.total += max(m.EndTime.Sub(w.since), 0)
+	}
+
+	m.WallTime = m.ElapsedTime
+	m.Stops = w.stops
+	m.StoppedTime = min(w.total, m.ElapsedTime)
+	m.ElapsedTime -= m.StoppedTime
+	m.CPUPercent = calculateCPUPercent(m.UserTime, m.SystemTime, m.ElapsedTime)
+	m.Unaccounted = max(m.ElapsedTime-m.UserTime-m.SystemTime, 0)
+}
+
+// stoppedNote describes how long the command was stopped, or returns "" if
+// it never was.
+func stoppedNote(m Metrics) string {
+	if m.Stops == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("stopped %d time(s) for %.3fs, left out of the elapsed time; wall clock %.3fs",
+		m.Stops, m.StoppedTime.Seconds(), m.WallTime.Seconds())
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// Values of si_code for SIGCHLD.
+const (
+	cldStopped   = 5
+	cldContinued = 6
+)
+
+func excludeStoppedSupported() error {
+	return nil
+}
+
+// childJobEvent returns the next stop or continue of pid without waiting.
+// Without WEXITED, the exit stays for cmd.Wait to reap.
+func childJobEvent(pid int) jobEvent {
+	var info unix.Siginfo
+
+	err := unix.Waitid(unix.P_PID, pid, &info, unix.WSTOPPED|unix.WCONTINUED|unix.WNOHANG, nil)
+	if err != nil || info.Signo != int32(unix.SIGCHLD) {
+		return noJobEvent
+	}
+
+	switch info.Code {
+	case cldStopped:
+		return jobStopped
+	case cldContinued:
+		return jobContinued
+	default:
+		return noJobEvent
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestRunCommandExcludeStopped(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	// A background subshell continues the shell after it stops itself.
+	m, err := runCommand([]string{"sh", "-c", "(sleep 0.3; kill -CONT $$) & kill -STOP $$; wait"}, runOptions{excludeStopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Stops != 1 {
+		t.Fatalf("Stops = %d, want 1", m.Stops)
+	}
+
+	if m.StoppedTime < 200*time.Millisecond {
+		t.Errorf("StoppedTime = %v, want at least 200ms", m.StoppedTime)
+	}
+
+	if m.WallTime != m.ElapsedTime+m.StoppedTime {
+		t.Errorf("WallTime = %v, want ElapsedTime %v + StoppedTime %v", m.WallTime, m.ElapsedTime, m.StoppedTime)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func excludeStoppedSupported() error {
+	return fmt.Errorf("%w on %s", errExcludeStoppedUnsupported, runtime.GOOS)
+}
+
+func childJobEvent(int) jobEvent {
+	return noJobEvent
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStopWatch(t *testing.T) {
+	t.Parallel()
+
+	base := time.Unix(1_700_000_000, 0)
+	at := func(ms int) time.Time { return base.Add(time.Duration(ms) * time.Millisecond) }
+
+	w := &stopWatch{done: make(chan struct{}), stopped: make(chan struct{})}
+	close(w.stopped)
+
+	w.record(jobStopped, at(100))
+	w.record(jobStopped, at(150)) // already stopped
+	w.record(jobContinued, at(400))
+	w.record(jobContinued, at(450)) // already running
+	w.record(jobStopped, at(800))   // killed while stopped
+
+	m := Metrics{
+		StartTime:   base,
+		EndTime:     at(1000),
+		ElapsedTime: time.Second,
+		UserTime:    100 * time.Millisecond,
+		SystemTime:  100 * time.Millisecond,
+	}
+	w.stop(&m)
+
+	if m.Stops != 2 {
+		t.Errorf("Stops = %d, want 2", m.Stops)
+	}
+
+	if m.StoppedTime != 500*time.Millisecond {
+		t.Errorf("StoppedTime = %v, want 500ms", m.StoppedTime)
+	}
+
+	if m.WallTime != time.Second || m.ElapsedTime != 500*time.Millisecond {
+		t.Errorf("WallTime, ElapsedTime = %v, %v, want 1s, 500ms", m.WallTime, m.ElapsedTime)
+	}
+
+	if m.CPUPercent != 40 || m.Unaccounted != 300*time.Millisecond {
+		t.Errorf("CPUPercent, Unaccounted = %d, %v, want 40, 300ms", m.CPUPercent, m.Unaccounted)
+	}
+}
+
+func TestStoppedNote(t *testing.T) {
+	t.Parallel()
+
+	if note := stoppedNote(Metrics{}); note != "" {
+		t.Errorf("stoppedNote() = %q for a run that never stopped", note)
+	}
+
+	m := Metrics{Stops: 1, StoppedTime: 2 * time.Second, WallTime: 3 * time.Second}
+	if got, want := stoppedNote(m), "stopped 1 time(s) for 2.000s, left out of the elapsed time; wall clock 3.000s"; got != want {
+		t.Errorf("stoppedNote() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// defaultSuiteRuns is the number of runs of a suite benchmark that sets no
+// run count of its own.
+const defaultSuiteRuns = 10
+
+var errSuite = errors.New("invalid suite")
+
+// SuiteResult is the outcome of running a benchmark suite with --suite.
+type SuiteResult struct {
+	SchemaVersion int `json:"schema_version,omitempty" unit:"none"`
+
+	Suite      string        `json:"suite"`      // the suite file
+	Benchmarks []BenchResult `json:"benchmarks"` // in suite order, up to the first that failed
+}
+
+// suiteBenchmark is one named command of a benchmark suite file.
+type suiteBenchmark struct {
+	name    string
+	command string // shell command
+	prepare string
+	cleanup string
+	runs    int
+}
+
+// loadSuite reads a suite file: a config-format file with a table per
+// benchmark, run in file order. Top-level runs, prepare, and cleanup keys
+// are defaults for every benchmark:
+//
+//	runs = 10
+//
+//	[build]
+//	command = "make -j8"
+//	prepare = "make clean"
+func loadSuite(path string) ([]suiteBenchmark, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tables, err := parseConfig(f)
+	if err != nil {
+		return nil, err
+	}
+
+	defaults := suiteBenchmark{runs: defaultSuiteRuns}
+	if err := defaults.set(tables[0].values, false); err != nil {
+		return nil, err
+	}
+
+	suite := make([]suiteBenchmark, 0, len(tables)-1)
+
+	for _, t := range tables[1:] {
+		b := defaults
+		b.name = t.name
+
+		if err := b.set(t.values, true); err != nil {
+			return nil, fmt.Errorf("[%s]: %w", t.name, err)
+		}
+
+		if b.command == "" {
+			return nil, fmt.Errorf("%w: [%s] has no command", errSuite, t.name)
+		}
+
+		suite = append(suite, b)
+	}
+
+	if len(suite) == 0 {
+		return nil, fmt.Errorf("%w: %s defines no benchmarks", errSuite, path)
+	}
+
+	return suite, nil
+}
+
+// set applies the keys of a suite table to b. Only benchmark tables may
+// set a command.
+func (b *suiteBenchmark) set(values map[string]any, benchmark bool) error {
+	for key, value := range values {
+		var ok bool
+
+		switch key {
+		case "command":
+			b.command, ok = value.(string)
+			ok = ok && benchmark
+		case "prepare":
+			b.prepare, ok = value.(string)
+		case "cleanup":
+			b.cleanup, ok = value.(string)
+		case "runs":
+			var n int64
+
+			n, ok = value.(int64)
+			b.runs = int(n)
+			ok = ok && n > 0
+		}
+
+		if !ok {
+			return fmt.Errorf("%w: unexpected %s = %v", errSuite, key, value)
+		}
+	}
+
+	return nil
+}
+
+// suite runs the benchmarks of the --suite file one after the other,
+// reports each as it finishes and then all of them together, and returns
+// the error of the run that stopped the suite, or else the first exceeded
+// threshold.
+func (c *runCmd) suite() error {
+	if len(c.Command) > 0 {
+		return fmt.Errorf("%w: --suite takes no command", errSuite)
+	}
+
+	benchmarks, err := loadSuite(c.Suite)
+	if err != nil {
+		return err
+	}
+
+	result := SuiteResult{Suite: c.Suite}
+
+	var exceeded error
+
+	for _, b := range benchmarks {
+		r, runErr := c.runSuiteBenchmark(b)
+		result.Benchmarks = append(result.Benchmarks, r)
+
+		if !c.Quiet && !c.JSON && c.tmpl == nil {
+			c.reportBench(r)
+			c.printBenchNotes(r, runErr)
+		}
+
+		if runErr != nil {
+			err = runErr
+
+			break
+		}
+
+		if exceeded == nil {
+			exceeded = c.checkThresholds(r.Results...)
+		}
+	}
+
+	c.export("suite", result)
+
+	if !c.Quiet {
+		c.reportSuite(result)
+	}
+
+	return cmp.Or(err, exceeded)
+}
+
+// runSuiteBenchmark benchmarks one command of the suite with its own run
+// count and hooks. Hooks it does not set fall back to --prepare and
+// --cleanup.
+func (c *runCmd) runSuiteBenchmark(b suiteBenchmark) (BenchResult, error) {
+	bc := *c
+	bc.Command = shellCommand(context.Background(), b.command).Args
+	bc.Runs = b.runs
+	bc.Prepare = cmp.Or(b.prepare, c.Prepare)
+	bc.Cleanup = cmp.Or(b.cleanup, c.Cleanup)
+
+	results, err := bc.runBenches(benchState{name: b.name, command: bc.Command})
+	c.lastRunEnd = bc.lastRunEnd
+
+	return results[0], err
+}
+
+// reportSuite prints a suite result in the selected output format.
+// Templates receive the SuiteResult.
+func (c *runCmd) reportSuite(r SuiteResult) {
+	switch {
+	case c.JSONL:
+		c.events.emit(event{Type: eventSuiteFinished, Result: r})
+	case c.JSON:
+		c.printJSON(r)
+	case c.tmpl != nil:
+		printTemplate(c.tmpl, r)
+	default:
+		printSuite(r)
+	}
+}
+
+// printSuite prints a line per benchmark of the suite with its mean
+// elapsed time and how it ended.
+func printSuite(r SuiteResult) {
+	bold := lipgloss.NewStyle().Bold(true)
+	faint := lipgloss.NewStyle().Faint(true)
+
+	width := 0
+	for _, b := range r.Benchmarks {
+		width = max(width, utf8.RuneCountInString(b.Command))
+	}
+
+	var total time.Duration
+
+	fmt.Fprintf(os.Stderr, "%s  %s\n", bold.Render("suite"), faint.Render(r.Suite))
+
+	for _, b := range r.Benchmarks {
+		total += b.WallTime
+
+		line := fmt.Sprintf("  %-*s  mean %.3fs ± %.3fs  %s", width, b.Command, b.Elapsed.Mean.Seconds(), b.Elapsed.StdDev.Seconds(), faint.Render(fmt.Sprintf("%d runs", b.Runs)))
+		if b.StopReason != "runs" {
+			line += "  " + palette.bad.Render(b.StopReason)
+		}
+
+		fmt.Fprintln(os.Stderr, line)
+	}
+
+	fmt.Fprintf(os.Stderr, "  %d benchmarks in %.3fs\n", len(r.Benchmarks), total.Seconds())
+}
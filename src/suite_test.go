@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadSuite(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []suiteBenchmark
+		wantErr error
+	}{
+		{
+			name:  "defaults",
+			input: "runs = 5\ncleanup = \"rm -rf out\"\n\n[build]\ncommand = \"make\"\nprepare = \"make clean\"\n\n[test]\ncommand = \"make test\"\nruns = 2\n",
+			want: []suiteBenchmark{
+				{name: "build", command: "make", prepare: "make clean", cleanup: "rm -rf out", runs: 5},
+				{name: "test", command: "make test", cleanup: "rm -rf out", runs: 2},
+			},
+		},
+		{
+			name:  "default runs",
+			input: "[build]\ncommand = \"make\"\n",
+			want:  []suiteBenchmark{{name: "build", command: "make", runs: defaultSuiteRuns}},
+		},
+		{name: "no benchmarks", input: "runs = 5\n", wantErr: errSuite},
+		{name: "no command", input: "[build]\nruns = 5\n", wantErr: errSuite},
+		{name: "top-level command", input: "command = \"make\"\n[build]\ncommand = \"make\"\n", wantErr: errSuite},
+		{name: "unknown key", input: "[build]\ncommand = \"make\"\nwarmup = 3\n", wantErr: errSuite},
+		{name: "bad runs", input: "[build]\ncommand = \"make\"\nruns = 0\n", wantErr: errSuite},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "suite.toml")
+			if err := os.WriteFile(path, []byte(tt.input), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := loadSuite(path)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("loadSuite() error = %v, want %v", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("loadSuite() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadSuite() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunSuiteBenchmark(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	// Each hook appends to the log, so the log shows which hooks ran around
+	// which runs.
+	log := filepath.Join(t.TempDir(), "log")
+	c := runCmd{Prepare: "echo default-prepare >> " + log, Cleanup: "echo default-cleanup >> " + log}
+
+	r, err := c.runSuiteBenchmark(suiteBenchmark{name: "build", command: "echo run >> " + log, prepare: "echo prepare >> " + log, runs: 2})
+	if err != nil {
+		t.Fatalf("runSuiteBenchmark() error = %v", err)
+	}
+
+	if r.Command != "build" || r.Runs != 2 || r.StopReason != "runs" {
+		t.Errorf("runSuiteBenchmark() = %q, %d runs, stopped by %q; want build, 2, runs", r.Command, r.Runs, r.StopReason)
+	}
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "prepare\nrun\ndefault-cleanup\nprepare\nrun\ndefault-cleanup\n"; string(data) != want {
+		t.Errorf("log = %q, want %q", data, want)
+	}
+}
+
+func TestSuiteStopsAtFailure(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "ran")
+	path := filepath.Join(dir, "suite.toml")
+	suite := "runs = 1\n[ok]\ncommand = \"true\"\n[broken]\ncommand = \"exit 3\"\n[after]\ncommand = \"touch " + marker + "\"\n"
+
+	if err := os.WriteFile(path, []byte(suite), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := runCmd{Suite: path, Quiet: true}
+
+	var exitErr *exec.ExitError
+	if err := c.suite(); !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("suite() error = %v, want exit status 3", err)
+	}
+
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("the benchmark after the failing one ran")
+	}
+}
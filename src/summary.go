@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+)
+
+// summaryRow is one labeled line of the long summary.
+type summaryRow struct {
+	label string
+	value string
+}
+
+// printSummary prints m with timeFmt or, without one, as the default
+// summary: one line like zsh's, or one line per metric with --long or when
+// the line would be wider than the terminal. --all adds resource usage to
+// either.
+func printSummary(m Metrics, timeFmt string, opts formatOptions) {
+	if timeFmt != "" {
+		fmt.Fprintln(os.Stderr, format(timeFmt, m, opts))
+
+		return
+	}
+
+	fmt.Fprint(os.Stderr, defaultSummary(m, opts))
+}
+
+// defaultSummary returns the one-line summary, or the long one if it is
+// asked for or the line would not fit.
+func defaultSummary(m Metrics, opts formatOptions) string {
+	line := summaryLine(m, opts)
+	if opts.long || (opts.width > 0 && lipgloss.Width(line) > opts.width) {
+		return summaryLong(m, opts)
+	}
+
+	return line + "\n"
+}
+
+// summaryLine is the default one-line summary, followed with --all by the
+// resource usage that was not zero.
+func summaryLine(m Metrics, opts formatOptions) string {
+	line := fmt.Sprintf("%s  %s user %s system %s cpu %s total",
+		palette.command.Render(m.Command),
+		palette.user.Render(opts.seconds(m.UserTime, 2)),
+		palette.system.Render(opts.seconds(m.SystemTime, 2)),
+		palette.cpu.Render(fmt.Sprintf("%d%%", m.CPUPercent)),
+		palette.total.Render(opts.seconds(m.ElapsedTime, 3)),
+	)
+
+	if opts.all {
+		for _, row := range usageRows(m) {
+			line += "  " + row.value + " " + row.label
+		}
+	}
+
+	return line
+}
+
+// summaryRows returns the metrics of the long summary with their units
+// chosen for their size, or in seconds to the microsecond with
+// --precision-ns.
+func summaryRows(m Metrics, opts formatOptions) []summaryRow {
+	duration := humanDuration
+	if opts.micro {
+		duration = func(d time.Duration) string { return opts.seconds(d, microPrecision) }
+	}
+
+	rows := []summaryRow{
+		{"elapsed", palette.total.Render(duration(m.ElapsedTime))},
+		{"user", palette.user.Render(duration(m.UserTime))},
+		{"system", palette.system.Render(duration(m.SystemTime))},
+		{"cpu", palette.cpu.Render(fmt.Sprintf("%d%%", m.CPUPercent))},
+	}
+
+	if opts.all {
+		rows = append(rows, usageRows(m)...)
+	}
+
+	return rows
+}
+
+// usageRows returns the memory, page fault, context switch, and block I/O
+// figures of --all, leaving out those that are zero.
+func usageRows(m Metrics) []summaryRow {
+	var rows []summaryRow
+
+	if m.MaxRSS > 0 {
+		rows = append(rows, summaryRow{"max rss", humanBytes(m.MaxRSS * 1024)})
+	}
+
+	if m.PageFaults > 0 || m.PageReclaims > 0 {
+		rows = append(rows, summaryRow{"page faults", fmt.Sprintf("%d major, %d minor", m.PageFaults, m.PageReclaims)})
+	}
+
+	if m.VCtxSwitches > 0 || m.ICtxSwitches > 0 {
+		rows = append(rows, summaryRow{"switches", fmt.Sprintf("%d voluntary, %d involuntary", m.VCtxSwitches, m.ICtxSwitches)})
+	}
+
+	if m.BlockInput > 0 || m.BlockOutput > 0 {
+		rows = append(rows, summaryRow{"block i/o", fmt.Sprintf("%d in, %d out", m.BlockInput, m.BlockOutput)})
+	}
+
+	return rows
+}
+
+// summaryLong is the summary with the command on its own line and every
+// metric below it, labels aligned.
+func summaryLong(m Metrics, opts formatOptions) string {
+	rows := summaryRows(m, opts)
+
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row.label))
+	}
+
+	var b strings.Builder
+
+	b.WriteString(palette.command.Render(m.Command) + "\n")
+
+	for _, row := range rows {
+		fmt.Fprintf(&b, "  %-*s  %s\n", width, row.label, row.value)
+	}
+
+	return b.String()
+}
+
+// terminalWidth returns the width of the terminal on stderr, or 0 if
+// stderr is not a terminal.
+func terminalWidth() int {
+	width, _, err := term.GetSize(os.Stderr.Fd())
+	if err != nil {
+		return 0
+	}
+
+	return width
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDefaultSummary(t *testing.T) {
+	t.Parallel()
+
+	m := Metrics{
+		Command:     "make -j8",
+		UserTime:    2100 * time.Millisecond,
+		SystemTime:  310 * time.Millisecond,
+		ElapsedTime: 3250 * time.Millisecond,
+		CPUPercent:  74,
+	}
+
+	line := "make -j8  2.10s user 0.31s system 74% cpu 3.250s total\n"
+	long := "make -j8\n  elapsed  3.25s\n  user     2.10s\n  system   310.00ms\n  cpu      74%\n"
+
+	tests := []struct {
+		name string
+		opts formatOptions
+		want string
+	}{
+		{"unknown width", formatOptions{}, line},
+		{"fits", formatOptions{width: 80}, line},
+		{"narrow terminal", formatOptions{width: 40}, long},
+		{"long", formatOptions{long: true, width: 200}, long},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := defaultSummary(m, tt.opts); got != tt.want {
+				t.Errorf("defaultSummary() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultSummaryAll(t *testing.T) {
+	t.Parallel()
+
+	busy := Metrics{
+		Command:      "make",
+		ElapsedTime:  time.Second,
+		MaxRSS:       2048,
+		PageReclaims: 120,
+		BlockOutput:  16,
+	}
+
+	tests := []struct {
+		name string
+		m    Metrics
+		opts formatOptions
+		want string
+	}{
+		{
+			"line",
+			busy,
+			formatOptions{all: true},
+			"make  0.00s user 0.00s system 0% cpu 1.000s total  2.0 MiB max rss  0 major, 120 minor page faults  0 in, 16 out block i/o\n",
+		},
+		{
+			"long",
+			busy,
+			formatOptions{all: true, long: true},
+			"make\n  elapsed      1.00s\n  user         0µs\n  system       0µs\n  cpu          0%\n" +
+				"  max rss      2.0 MiB\n  page faults  0 major, 120 minor\n  block i/o    0 in, 16 out\n",
+		},
+		{"nothing to add", Metrics{Command: "true"}, formatOptions{all: true}, "true  0.00s user 0.00s system 0% cpu 0.000s total\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := defaultSummary(tt.m, tt.opts); got != tt.want {
+				t.Errorf("defaultSummary() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import "time"
+
+// ContextSwitches is the rate at which the threads of the command's process
+// were switched out, per --jsonl sample interval. Voluntary switches happen
+// when a thread blocks, such as on a lock or I/O; involuntary ones when the
+// scheduler preempts it. A spike in voluntary switches often marks a phase
+// of lock contention.
+type ContextSwitches struct {
+	Interval          time.Duration `json:"interval"`
+	Voluntary         []float64     `json:"voluntary" unit:"switches/second"` // one per sample
+	Involuntary       []float64     `json:"involuntary" unit:"switches/second"`
+	PeakVoluntary     float64       `json:"peak_voluntary" unit:"switches/second"`
+	PeakVoluntaryAt   time.Duration `json:"peak_voluntary_at"` // end of the peak interval, since the run started
+	PeakInvoluntary   float64       `json:"peak_involuntary" unit:"switches/second"`
+	PeakInvoluntaryAt time.Duration `json:"peak_involuntary_at"`
+}
+
+// switchRates turns successive samples into context switch rates.
+type switchRates struct {
+	last   processSample
+	lastAt time.Duration
+	series ContextSwitches
+}
+
+// add records a sample taken at elapsed and returns the voluntary and
+// involuntary switches per second since the previous one. Counts of
+// threads that exited in between are lost, so a drop counts as no switches.
+func (r *switchRates) add(s processSample, elapsed time.Duration) (float64, float64) {
+	wall := (elapsed - r.lastAt).Seconds()
+	if wall <= 0 {
+		return 0, 0
+	}
+
+	voluntary := float64(max(s.voluntary-r.last.voluntary, 0)) / wall
+	involuntary := float64(max(s.involuntary-r.last.involuntary, 0)) / wall
+	r.last, r.lastAt = s, elapsed
+
+	c := &r.series
+	c.Voluntary = append(c.Voluntary, voluntary)
+	c.Involuntary = append(c.Involuntary, involuntary)
+
+	if voluntary > c.PeakVoluntary {
+		c.PeakVoluntary, c.PeakVoluntaryAt = voluntary, elapsed
+	}
+
+	if involuntary > c.PeakInvoluntary {
+		c.PeakInvoluntary, c.PeakInvoluntaryAt = involuntary, elapsed
+	}
+
+	return voluntary, involuntary
+}
+
+// result returns the series, or nil if there were no samples, as on
+// platforms where processes are not sampled.
+func (r *switchRates) result(interval time.Duration) *ContextSwitches {
+	if len(r.series.Voluntary) == 0 {
+		return nil
+	}
+
+	c := r.series
+	c.Interval = interval
+
+	return &c
+}
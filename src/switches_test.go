@@ -0,0 +1,49 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSwitchRates(t *testing.T) {
+	t.Parallel()
+
+	var r switchRates
+
+	if got := r.result(time.Second); got != nil {
+		t.Errorf("result() without samples = %+v, want nil", got)
+	}
+
+	samples := []struct {
+		voluntary, involuntary int64
+		at                     time.Duration
+	}{
+		{100, 10, time.Second},
+		{1100, 12, 2 * time.Second},
+		{900, 40, 3 * time.Second}, // a thread exited
+		{1000, 40, 3 * time.Second},
+	}
+
+	for _, s := range samples {
+		r.add(processSample{voluntary: s.voluntary, involuntary: s.involuntary}, s.at)
+	}
+
+	got := r.result(time.Second)
+	if got == nil {
+		t.Fatal("result() = nil")
+	}
+
+	if want := []float64{100, 1000, 0}; !slices.Equal(got.Voluntary, want) {
+		t.Errorf("Voluntary = %v, want %v", got.Voluntary, want)
+	}
+
+	if want := []float64{10, 2, 28}; !slices.Equal(got.Involuntary, want) {
+		t.Errorf("Involuntary = %v, want %v", got.Involuntary, want)
+	}
+
+	if got.Interval != time.Second || got.PeakVoluntary != 1000 || got.PeakVoluntaryAt != 2*time.Second ||
+		got.PeakInvoluntary != 28 || got.PeakInvoluntaryAt != 3*time.Second {
+		t.Errorf("result() = %+v", got)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"runtime"
+)
+
+// SystemInfo describes the machine a result was measured on and the state
+// it was in, since numbers from different machines or a busy one are not
+// comparable.
+type SystemInfo struct {
+	Hostname    string      `json:"hostname"`
+	OS          string      `json:"os"`
+	Arch        string      `json:"arch"`
+	Kernel      string      `json:"kernel,omitempty"` // kernel release, e.g. "6.8.0-45-generic"
+	CPUModel    string      `json:"cpu_model,omitempty"`
+	Cores       int         `json:"cores" unit:"count"`                      // logical CPUs
+	MemoryTotal int64       `json:"memory_total,omitempty" unit:"kilobytes"` // in KB
+	LoadAverage *[3]float64 `json:"load_average,omitempty" unit:"load"`      // 1, 5, and 15 minutes
+	Governor    string      `json:"governor,omitempty"`                      // CPU frequency governor (Linux)
+	PowerSource string      `json:"power_source,omitempty"`                  // "ac" or "battery", on machines that have one
+}
+
+// readSystemInfo takes a snapshot of the machine. Details the platform
+// does not expose are left empty.
+func readSystemInfo() *SystemInfo {
+	info := &SystemInfo{OS: runtime.GOOS, Arch: runtime.GOARCH, Cores: runtime.NumCPU()}
+	info.Hostname, _ = os.Hostname()
+
+	readPlatformInfo(info)
+
+	return info
+}
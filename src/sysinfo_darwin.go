@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+func readPlatformInfo(info *SystemInfo) {
+	info.Kernel, _ = unix.Sysctl("kern.osrelease")
+	info.CPUModel, _ = unix.Sysctl("machdep.cpu.brand_string")
+
+	if mem, err := unix.SysctlUint64("hw.memsize"); err == nil {
+		info.MemoryTotal = int64(mem / 1024) //nolint:gosec // Total RAM fits in an int64.
+	}
+
+	info.LoadAverage = loadAverage()
+	info.PowerSource = powerSource()
+}
+
+// loadAverage decodes vm.loadavg, a struct loadavg: three fixed-point
+// uint32 averages followed by their scale as a long.
+func loadAverage() *[3]float64 {
+	raw, err := unix.SysctlRaw("vm.loadavg")
+	if err != nil || len(raw) < 24 {
+		return nil
+	}
+
+	scale := float64(binary.LittleEndian.Uint64(raw[16:]))
+	if scale == 0 {
+		return nil
+	}
+
+	var loads [3]float64
+	for i := range loads {
+		loads[i] = float64(binary.LittleEndian.Uint32(raw[4*i:])) / scale
+	}
+
+	return &loads
+}
+
+// powerSource asks pmset whether the Mac is on AC or battery power.
+func powerSource() string {
+	out, err := exec.CommandContext(context.Background(), "pmset", "-g", "ps").Output()
+
+	switch {
+	case err != nil:
+		return ""
+	case strings.Contains(string(out), "'AC Power'"):
+		return "ac"
+	case strings.Contains(string(out), "'Battery Power'"):
+		return "battery"
+	default:
+		return ""
+	}
+}
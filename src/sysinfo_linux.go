@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// loadScale is the fixed-point scale of sysinfo(2) load averages.
+const loadScale = 1 << 16
+
+func readPlatformInfo(info *SystemInfo) {
+	var uts unix.Utsname
+	if unix.Uname(&uts) == nil {
+		info.Kernel = unix.ByteSliceToString(uts.Release[:])
+	}
+
+	var si unix.Sysinfo_t
+	if unix.Sysinfo(&si) == nil {
+		info.MemoryTotal = int64(uint64(si.Totalram) * uint64(si.Unit) / 1024) //nolint:gosec // Total RAM fits in an int64.
+	}
+
+	info.LoadAverage = loadAverage()
+
+	info.CPUModel = cpuModel()
+
+	if governor, err := os.ReadFile("/sys/devices/system/cpu/cpu0/cpufreq/scaling_governor"); err == nil {
+		info.Governor = strings.TrimSpace(string(governor))
+	}
+
+	info.PowerSource = powerSource()
+}
+
+// loadAverage returns the 1, 5, and 15-minute load averages.
+func loadAverage() *[3]float64 {
+	var si unix.Sysinfo_t
+	if unix.Sysinfo(&si) != nil {
+		return nil
+	}
+
+	return &[3]float64{
+		float64(si.Loads[0]) / loadScale,
+		float64(si.Loads[1]) / loadScale,
+		float64(si.Loads[2]) / loadScale,
+	}
+}
+
+// cpuModel returns the first model name in /proc/cpuinfo. ARM kernels
+// may not report one.
+func cpuModel() string {
+	f, err := os.Open("/proc/cpuinfo")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if ok && strings.TrimSpace(key) == "model name" {
+			return strings.TrimSpace(value)
+		}
+	}
+
+	return ""
+}
+
+// powerSource reports "ac" if a mains supply is online and "battery" if
+// the machine has only batteries to run on, or "" if it reports neither.
+func powerSource() string {
+	supplies, _ := filepath.Glob("/sys/class/power_supply/*")
+	source := ""
+
+	for _, supply := range supplies {
+		kind, _ := os.ReadFile(filepath.Join(supply, "type"))
+		online, _ := os.ReadFile(filepath.Join(supply, "online"))
+
+		switch strings.TrimSpace(string(kind)) {
+		case "Mains":
+			if strings.TrimSpace(string(online)) == "1" {
+				return "ac"
+			}
+
+			source = "battery"
+		case "Battery":
+			source = "battery"
+		}
+	}
+
+	return source
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestReadPlatformInfoLinux(t *testing.T) {
+	t.Parallel()
+
+	var info SystemInfo
+	readPlatformInfo(&info)
+
+	if info.Kernel == "" {
+		t.Error("Kernel is empty")
+	}
+
+	if info.MemoryTotal <= 0 {
+		t.Errorf("MemoryTotal = %d, want the installed RAM", info.MemoryTotal)
+	}
+
+	if info.LoadAverage == nil {
+		t.Fatal("LoadAverage is nil")
+	}
+
+	for i, load := range info.LoadAverage {
+		if load < 0 {
+			t.Errorf("LoadAverage[%d] = %v, want non-negative", i, load)
+		}
+	}
+}
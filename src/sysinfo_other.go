@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package main
+
+// readPlatformInfo adds nothing beyond the portable details on platforms
+// without a collector.
+func readPlatformInfo(*SystemInfo) {}
+
+// loadAverage is not available without a collector.
+func loadAverage() *[3]float64 {
+	return nil
+}
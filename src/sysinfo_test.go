@@ -0,0 +1,24 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestReadSystemInfo(t *testing.T) {
+	t.Parallel()
+
+	info := readSystemInfo()
+
+	if info.OS != runtime.GOOS || info.Arch != runtime.GOARCH {
+		t.Errorf("OS/Arch = %s/%s, want %s/%s", info.OS, info.Arch, runtime.GOOS, runtime.GOARCH)
+	}
+
+	if info.Cores < 1 {
+		t.Errorf("Cores = %d, want at least 1", info.Cores)
+	}
+
+	if info.PowerSource != "" && info.PowerSource != "ac" && info.PowerSource != "battery" {
+		t.Errorf("PowerSource = %q, want ac, battery, or empty", info.PowerSource)
+	}
+}
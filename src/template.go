@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// loadTemplate parses the --template argument. An argument naming an existing
+// file is read from disk; anything else is treated as the template text.
+func loadTemplate(src string) (*template.Template, error) {
+	text := src
+
+	if info, err := os.Stat(src); err == nil && info.Mode().IsRegular() {
+		//nolint:gosec // Intended behavior: the user names the template file.
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return nil, fmt.Errorf("reading template: %w", err)
+		}
+
+		text = string(data)
+	}
+
+	tmpl, err := template.New("ztime").Funcs(templateFuncs()).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// renderTemplate executes tmpl with data, a Metrics or a BenchResult.
+func renderTemplate(tmpl *template.Template, data any) (string, error) {
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("rendering template: %w", err)
+	}
+
+	return out.String(), nil
+}
+
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"duration": humanDuration,
+		"seconds":  func(d time.Duration) float64 { return d.Seconds() },
+		"bytes":    humanBytes,
+		"kb":       func(kb int64) string { return humanBytes(kb * 1024) },
+		"color": func(color, text string) string {
+			return lipgloss.NewStyle().Foreground(lipgloss.Color(color)).Render(text)
+		},
+		"bold":  func(text string) string { return lipgloss.NewStyle().Bold(true).Render(text) },
+		"faint": func(text string) string { return lipgloss.NewStyle().Faint(true).Render(text) },
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	t.Parallel()
+
+	metrics := Metrics{
+		Command:     "sleep 1",
+		UserTime:    1500 * time.Microsecond,
+		ElapsedTime: 1250 * time.Millisecond,
+		CPUPercent:  12,
+		MaxRSS:      2048,
+	}
+
+	tests := []struct {
+		name     string
+		tmpl     string
+		expected string
+	}{
+		{
+			name:     "Fields",
+			tmpl:     "{{.Command}} {{.CPUPercent}}%",
+			expected: "sleep 1 12%",
+		},
+		{
+			name:     "Duration Helpers",
+			tmpl:     "{{duration .ElapsedTime}} {{duration .UserTime}} {{printf \"%.3f\" (seconds .ElapsedTime)}}",
+			expected: "1.25s 1.50ms 1.250",
+		},
+		{
+			name:     "Byte Helpers",
+			tmpl:     "{{kb .MaxRSS}} {{bytes 512}}",
+			expected: "2.0 MiB 512 B",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpl, err := loadTemplate(tt.tmpl)
+			if err != nil {
+				t.Fatalf("loadTemplate() error = %v", err)
+			}
+
+			got, err := renderTemplate(tmpl, metrics)
+			if err != nil {
+				t.Fatalf("renderTemplate() error = %v", err)
+			}
+
+			if got != tt.expected {
+				t.Errorf("renderTemplate() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadTemplateInvalid(t *testing.T) {
+	t.Parallel()
+
+	if _, err := loadTemplate("{{.Command"); err == nil {
+		t.Error("loadTemplate() expected error for unterminated action")
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// testReportSlowest is how many of the slowest tests a test report lists.
+const testReportSlowest = 5
+
+// TestReport summarizes the JUnit XML report the command wrote, read with
+// --test-report after it exited.
+type TestReport struct {
+	File     string        `json:"file"`
+	Suites   int           `json:"suites" unit:"count"`
+	Tests    int           `json:"tests" unit:"count"`
+	Failures int           `json:"failures" unit:"count"`
+	Errors   int           `json:"errors" unit:"count"`
+	Skipped  int           `json:"skipped" unit:"count"`
+	TestTime time.Duration `json:"test_time"`         // sum of the tests' own times
+	Slowest  []TestCase    `json:"slowest,omitempty"` // slowest first, leaving out skipped ones
+	Failed   []TestCase    `json:"failed,omitempty"`  // failures and errors, in report order
+	Error    string        `json:"error,omitempty"`   // why the report could not be read
+}
+
+// TestCase is one test of a report.
+type TestCase struct {
+	Name  string        `json:"name"`
+	Class string        `json:"class,omitempty"` // classname, the package or module
+	Time  time.Duration `json:"time"`
+}
+
+// junitSuite is a <testsuites> or <testsuite> element. Both may nest
+// suites, and reports have either at their root.
+type junitSuite struct {
+	XMLName xml.Name
+	Suites  []junitSuite `xml:"testsuite"`
+	Cases   []junitCase  `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name    string    `xml:"name,attr"`
+	Class   string    `xml:"classname,attr"`
+	Time    string    `xml:"time,attr"` // seconds
+	Failure *struct{} `xml:"failure"`
+	Error   *struct{} `xml:"error"`
+	Skipped *struct{} `xml:"skipped"`
+}
+
+// testReportWatch reads the report once the command has exited.
+type testReportWatch struct {
+	path string
+}
+
+func (w testReportWatch) finish(m *Metrics, start time.Time) {
+	m.TestReport = readTestReport(w.path, start)
+}
+
+// readTestReport reads the report at path, which must have been written
+// since start so that a report left from an earlier run is not mistaken
+// for this one's.
+func readTestReport(path string, start time.Time) *TestReport {
+	info, err := os.Stat(path)
+	if err != nil {
+		return &TestReport{File: path, Error: err.Error()}
+	}
+
+	if info.ModTime().Before(start.Truncate(time.Second)) {
+		return &TestReport{File: path, Error: "the report was not written during the run"}
+	}
+
+	//nolint:gosec // Intended behavior: the user names the report.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return &TestReport{File: path, Error: err.Error()}
+	}
+
+	r, err := parseJUnit(data)
+	if err != nil {
+		return &TestReport{File: path, Error: err.Error()}
+	}
+
+	r.File = path
+
+	return r
+}
+
+// parseJUnit counts the tests of a JUnit XML report. The counts come from
+// the test cases rather than the suites' attributes, which some tools
+// leave out.
+func parseJUnit(data []byte) (*TestReport, error) {
+	var root junitSuite
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("parsing JUnit report: %w", err)
+	}
+
+	r := &TestReport{}
+	if root.XMLName.Local == "testsuite" {
+		r.Suites++
+	}
+
+	var cases []TestCase
+
+	var walk func(s junitSuite)
+	walk = func(s junitSuite) {
+		for _, c := range s.Cases {
+			seconds, _ := strconv.ParseFloat(strings.TrimSpace(c.Time), 64)
+			tc := TestCase{Name: c.Name, Class: c.Class, Time: time.Duration(seconds * float64(time.Second))}
+
+			r.Tests++
+			r.TestTime += tc.Time
+
+			if c.Skipped == nil {
+				cases = append(cases, tc)
+			}
+
+			switch {
+			case c.Failure != nil:
+				r.Failures++
+				r.Failed = append(r.Failed, tc)
+			case c.Error != nil:
+				r.Errors++
+				r.Failed = append(r.Failed, tc)
+			case c.Skipped != nil:
+				r.Skipped++
+			}
+		}
+
+		r.Suites += len(s.Suites)
+		for _, child := range s.Suites {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	slices.SortStableFunc(cases, func(a, b TestCase) int { return cmp.Compare(b.Time, a.Time) })
+	r.Slowest = cases[:min(testReportSlowest, len(cases))]
+
+	return r, nil
+}
+
+// testReportNote gives the test counts and the slowest tests over several
+// lines, or returns "" without --test-report.
+func testReportNote(m Metrics) string {
+	r := m.TestReport
+
+	switch {
+	case r == nil:
+		return ""
+	case r.Error != "":
+		return fmt.Sprintf("no test report from %s: %s", r.File, r.Error)
+	}
+
+	var note strings.Builder
+
+	fmt.Fprintf(&note, "tests: %d passed, %d failed, %d errors, %d skipped in %d suites; %s of test time",
+		r.Tests-r.Failures-r.Errors-r.Skipped, r.Failures, r.Errors, r.Skipped, r.Suites, humanDuration(r.TestTime))
+
+	if m.ElapsedTime > 0 {
+		note.WriteString(" in " + humanDuration(m.ElapsedTime) + " elapsed")
+	}
+
+	for _, c := range r.Slowest {
+		fmt.Fprintf(&note, "\n  %10s  %s", humanDuration(c.Time), testName(c))
+	}
+
+	for _, c := range r.Failed {
+		note.WriteString("\n  failed: " + testName(c))
+	}
+
+	return note.String()
+}
+
+func testName(c TestCase) string {
+	if c.Class == "" {
+		return c.Name
+	}
+
+	return c.Class + "." + c.Name
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const junitReport = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="pkg/a" tests="4">
+    <testcase classname="pkg/a" name="TestFast" time="0.010"></testcase>
+    <testcase classname="pkg/a" name="TestSlow" time="2.500"><failure message="boom">x</failure></testcase>
+    <testcase classname="pkg/a" name="TestSkip" time="0"><skipped/></testcase>
+    <testcase classname="pkg/a" name="TestPanic" time="0.5"><error/></testcase>
+  </testsuite>
+  <testsuite name="pkg/b">
+    <testsuite name="pkg/b/nested"><testcase name="test_mid" time="1.25"/></testsuite>
+  </testsuite>
+</testsuites>`
+
+func TestParseJUnit(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		data    string
+		want    TestReport
+		slowest []string
+		wantErr bool
+	}{
+		{
+			"Suites",
+			junitReport,
+			TestReport{Suites: 3, Tests: 5, Failures: 1, Errors: 1, Skipped: 1, TestTime: 4260 * time.Millisecond},
+			[]string{"TestSlow", "test_mid", "TestPanic", "TestFast"},
+			false,
+		},
+		{
+			"Single suite",
+			`<testsuite name="tests"><testcase classname="test_x" name="test_one" time="0.25"/></testsuite>`,
+			TestReport{Suites: 1, Tests: 1, TestTime: 250 * time.Millisecond},
+			[]string{"test_one"},
+			false,
+		},
+		{"Not XML", "PASS\nok  pkg 0.1s", TestReport{}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseJUnit([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseJUnit() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if got.Suites != tt.want.Suites || got.Tests != tt.want.Tests || got.Failures != tt.want.Failures ||
+				got.Errors != tt.want.Errors || got.Skipped != tt.want.Skipped || got.TestTime != tt.want.TestTime {
+				t.Errorf("parseJUnit() = %+v, want %+v", got, tt.want)
+			}
+
+			var slowest []string
+			for _, c := range got.Slowest {
+				slowest = append(slowest, c.Name)
+			}
+
+			if strings.Join(slowest, ",") != strings.Join(tt.slowest, ",") {
+				t.Errorf("Slowest = %v, want %v", slowest, tt.slowest)
+			}
+		})
+	}
+}
+
+func TestReadTestReport(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "junit.xml")
+
+	if got := readTestReport(path, time.Now()); got.Error == "" {
+		t.Errorf("readTestReport() of a missing file = %+v, want an error", got)
+	}
+
+	if err := os.WriteFile(path, []byte(junitReport), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readTestReport(path, time.Now().Add(-time.Minute)); got.Error != "" || got.File != path || got.Tests != 5 {
+		t.Errorf("readTestReport() = %+v, want the report", got)
+	}
+
+	if got := readTestReport(path, time.Now().Add(time.Minute)); !strings.Contains(got.Error, "not written during the run") {
+		t.Errorf("readTestReport() of an old report = %+v, want it rejected", got)
+	}
+}
+
+func TestTestReportNote(t *testing.T) {
+	t.Parallel()
+
+	if got := testReportNote(Metrics{}); got != "" {
+		t.Errorf("testReportNote() without --test-report = %q, want empty", got)
+	}
+
+	m := Metrics{ElapsedTime: 3 * time.Second, TestReport: &TestReport{
+		Suites: 2, Tests: 4, Failures: 1, Skipped: 1, TestTime: 2 * time.Second,
+		Slowest: []TestCase{{Name: "TestSlow", Class: "pkg/a", Time: 1500 * time.Millisecond}},
+		Failed:  []TestCase{{Name: "TestSlow", Class: "pkg/a", Time: 1500 * time.Millisecond}},
+	}}
+
+	want := "tests: 2 passed, 1 failed, 0 errors, 1 skipped in 2 suites; 2.00s of test time in 3.00s elapsed\n" +
+		"       1.50s  pkg/a.TestSlow\n" +
+		"  failed: pkg/a.TestSlow"
+	if got := testReportNote(m); got != want {
+		t.Errorf("testReportNote() = %q, want %q", got, want)
+	}
+
+	unread := Metrics{TestReport: &TestReport{File: "junit.xml", Error: "no such file"}}
+	if got := testReportNote(unread); got != "no test report from junit.xml: no such file" {
+		t.Errorf("testReportNote() = %q", got)
+	}
+}
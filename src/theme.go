@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+var (
+	errThemeRole  = errors.New("unknown role")
+	errThemeStyle = errors.New("invalid style")
+)
+
+// theme holds the styles of ztime's human-readable output.
+type theme struct {
+	user    lipgloss.Style // user CPU time
+	system  lipgloss.Style // system CPU time
+	cpu     lipgloss.Style // CPU percentage
+	total   lipgloss.Style // elapsed time
+	command lipgloss.Style // the command line
+	plot    lipgloss.Style // sparklines and histograms
+	good    lipgloss.Style // passes, improvements, and throughput
+	warn    lipgloss.Style
+	bad     lipgloss.Style // failures and regressions
+}
+
+// palette is the theme in use. It is plain until setupColors runs.
+//
+//nolint:gochecknoglobals // Set once before any output, like lipgloss's default renderer.
+var palette theme
+
+// defaultTheme returns the style of every --theme role.
+func defaultTheme() map[string]string {
+	return map[string]string{
+		"user":    "33",
+		"system":  "33",
+		"cpu":     "42",
+		"total":   "bold",
+		"command": "faint",
+		"plot":    "33",
+		"good":    "42",
+		"warn":    "214",
+		"bad":     "196",
+	}
+}
+
+// setupColors sends styled output to stderr's renderer, decides whether it
+// is colored from --color and the environment, and applies the --theme
+// overrides.
+func setupColors(mode string, overrides map[string]string) error {
+	r := lipgloss.NewRenderer(os.Stderr)
+	if profile, ok := colorProfile(mode, os.Getenv); ok {
+		r.SetColorProfile(profile)
+	}
+
+	lipgloss.SetDefaultRenderer(r)
+
+	t, err := newTheme(overrides)
+	if err != nil {
+		return err
+	}
+
+	palette = t
+
+	return nil
+}
+
+// colorProfile returns the color profile that mode and the environment
+// force, or false to detect it from the terminal. NO_COLOR turns colors off
+// and a CLICOLOR_FORCE other than 0 turns them on, unless --color says
+// otherwise.
+func colorProfile(mode string, getenv func(string) string) (termenv.Profile, bool) {
+	forced := termenv.ANSI256
+	if colorterm := getenv("COLORTERM"); colorterm == "truecolor" || colorterm == "24bit" {
+		forced = termenv.TrueColor
+	}
+
+	switch {
+	case mode == "never":
+		return termenv.Ascii, true
+	case mode == "always":
+		return forced, true
+	case getenv("NO_COLOR") != "":
+		return termenv.Ascii, true
+	case getenv("CLICOLOR_FORCE") != "" && getenv("CLICOLOR_FORCE") != "0":
+		return forced, true
+	default:
+		return termenv.Ascii, false
+	}
+}
+
+// newTheme returns the default theme with the given roles restyled.
+func newTheme(overrides map[string]string) (theme, error) {
+	specs := defaultTheme()
+
+	for role, spec := range overrides {
+		if _, ok := specs[role]; !ok {
+			return theme{}, fmt.Errorf("--theme: %w: %s", errThemeRole, role)
+		}
+
+		specs[role] = spec
+	}
+
+	styles := make(map[string]lipgloss.Style, len(specs))
+
+	for role, spec := range specs {
+		style, err := parseStyle(spec)
+		if err != nil {
+			return theme{}, fmt.Errorf("--theme %s: %w", role, err)
+		}
+
+		styles[role] = style
+	}
+
+	return theme{
+		user:    styles["user"],
+		system:  styles["system"],
+		cpu:     styles["cpu"],
+		total:   styles["total"],
+		command: styles["command"],
+		plot:    styles["plot"],
+		good:    styles["good"],
+		warn:    styles["warn"],
+		bad:     styles["bad"],
+	}, nil
+}
+
+// parseStyle reads a style such as "bold 33" or "#5f87ff underline": a
+// foreground color, as an ANSI color number or #RGB/#RRGGBB, and any of
+// bold, faint, italic, and underline, or "none" for plain text.
+func parseStyle(spec string) (lipgloss.Style, error) {
+	style := lipgloss.NewStyle()
+
+	for _, word := range strings.Fields(spec) {
+		switch word {
+		case "none":
+		case "bold":
+			style = style.Bold(true)
+		case "faint":
+			style = style.Faint(true)
+		case "italic":
+			style = style.Italic(true)
+		case "underline":
+			style = style.Underline(true)
+		default:
+			if !validColor(word) {
+				return style, fmt.Errorf("%w: %q", errThemeStyle, word)
+			}
+
+			style = style.Foreground(lipgloss.Color(word))
+		}
+	}
+
+	return style, nil
+}
+
+// validColor reports whether color is an ANSI color number or a hex color.
+func validColor(color string) bool {
+	if hex, ok := strings.CutPrefix(color, "#"); ok {
+		_, err := strconv.ParseUint(hex, 16, 32)
+
+		return err == nil && (len(hex) == 3 || len(hex) == 6)
+	}
+
+	n, err := strconv.Atoi(color)
+
+	return err == nil && n >= 0 && n <= 255
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/muesli/termenv"
+)
+
+func TestColorProfile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		mode      string
+		env       map[string]string
+		want      termenv.Profile
+		wantForce bool
+	}{
+		{"auto", "auto", nil, termenv.Ascii, false},
+		{"never", "never", map[string]string{"CLICOLOR_FORCE": "1"}, termenv.Ascii, true},
+		{"always", "always", map[string]string{"NO_COLOR": "1"}, termenv.ANSI256, true},
+		{"always truecolor", "always", map[string]string{"COLORTERM": "truecolor"}, termenv.TrueColor, true},
+		{"NO_COLOR", "auto", map[string]string{"NO_COLOR": "1", "CLICOLOR_FORCE": "1"}, termenv.Ascii, true},
+		{"CLICOLOR_FORCE", "auto", map[string]string{"CLICOLOR_FORCE": "1"}, termenv.ANSI256, true},
+		{"CLICOLOR_FORCE=0", "auto", map[string]string{"CLICOLOR_FORCE": "0"}, termenv.Ascii, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, forced := colorProfile(tt.mode, func(key string) string { return tt.env[key] })
+			if forced != tt.wantForce || (forced && got != tt.want) {
+				t.Errorf("colorProfile() = %v, %v, want %v, %v", got, forced, tt.want, tt.wantForce)
+			}
+		})
+	}
+}
+
+func TestNewTheme(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		overrides map[string]string
+		wantErr   error
+	}{
+		{"defaults", nil, nil},
+		{"ansi and attributes", map[string]string{"user": "21 bold", "total": "underline"}, nil},
+		{"hex", map[string]string{"bad": "#f00", "good": "#00ff00"}, nil},
+		{"plain", map[string]string{"command": "none"}, nil},
+		{"unknown role", map[string]string{"elapsed": "21"}, errThemeRole},
+		{"color name", map[string]string{"user": "red"}, errThemeStyle},
+		{"out of range", map[string]string{"user": "256"}, errThemeStyle},
+		{"bad hex", map[string]string{"user": "#12345"}, errThemeStyle},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := newTheme(tt.overrides); !errors.Is(err, tt.wantErr) {
+				t.Errorf("newTheme() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseStyle(t *testing.T) {
+	t.Parallel()
+
+	style, err := parseStyle("bold #5f87ff underline")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !style.GetBold() || !style.GetUnderline() || style.GetFaint() {
+		t.Errorf("parseStyle() attributes: bold %v, underline %v, faint %v", style.GetBold(), style.GetUnderline(), style.GetFaint())
+	}
+}
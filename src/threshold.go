@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errInvalidThreshold  = errors.New("invalid threshold")
+	errThresholdExceeded = errors.New("performance budget exceeded")
+)
+
+// Kinds of threshold metric, which decide how limits are parsed and values
+// printed.
+const (
+	metricDuration = iota
+	metricSize
+	metricCount
+)
+
+// thresholdMetric is a metric that --fail-if can test.
+type thresholdMetric struct {
+	kind  int
+	value func(Metrics) float64 // seconds, bytes, or a count
+}
+
+// thresholdMetrics returns the metrics --fail-if accepts, by name.
+func thresholdMetrics() map[string]thresholdMetric {
+	seconds := func(f func(Metrics) time.Duration) func(Metrics) float64 {
+		return func(m Metrics) float64 { return f(m).Seconds() }
+	}
+	count := func(f func(Metrics) int64) func(Metrics) float64 {
+		return func(m Metrics) float64 { return float64(f(m)) }
+	}
+
+	return map[string]thresholdMetric{
+		"elapsed":        {metricDuration, seconds(func(m Metrics) time.Duration { return m.ElapsedTime })},
+		"user":           {metricDuration, seconds(func(m Metrics) time.Duration { return m.UserTime })},
+		"system":         {metricDuration, seconds(func(m Metrics) time.Duration { return m.SystemTime })},
+		"cpu":            {metricCount, func(m Metrics) float64 { return float64(m.CPUPercent) }},
+		"max_rss":        {metricSize, func(m Metrics) float64 { return float64(m.MaxRSS) * 1024 }},
+		"page_faults":    {metricCount, count(func(m Metrics) int64 { return m.PageFaults })},
+		"page_reclaims":  {metricCount, count(func(m Metrics) int64 { return m.PageReclaims })},
+		"swaps":          {metricCount, count(func(m Metrics) int64 { return m.Swaps })},
+		"block_input":    {metricCount, count(func(m Metrics) int64 { return m.BlockInput })},
+		"block_output":   {metricCount, count(func(m Metrics) int64 { return m.BlockOutput })},
+		"v_ctx_switches": {metricCount, count(func(m Metrics) int64 { return m.VCtxSwitches })},
+		"i_ctx_switches": {metricCount, count(func(m Metrics) int64 { return m.ICtxSwitches })},
+	}
+}
+
+// threshold is a performance budget such as "elapsed > 30s": the run
+// fails it when the comparison holds.
+type threshold struct {
+	metric string
+	op     string // >, >=, <, or <=
+	limit  float64
+	text   string // as written, for messages
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for kong, parsing
+// "METRIC OP LIMIT". Durations take Go duration limits ("30s"), max_rss
+// takes sizes ("2G"), and cpu a percentage ("90" or "90%").
+func (t *threshold) UnmarshalText(text []byte) error {
+	s := string(text)
+
+	i := strings.IndexAny(s, "<>")
+	if i < 0 {
+		return fmt.Errorf("%w %q: expected METRIC OP LIMIT, such as 'elapsed > 30s'", errInvalidThreshold, s)
+	}
+
+	op := s[i : i+1]
+	if strings.HasPrefix(s[i+1:], "=") {
+		op += "="
+	}
+
+	name := strings.TrimSpace(s[:i])
+	limit := strings.TrimSpace(s[i+len(op):])
+
+	metric, ok := thresholdMetrics()[name]
+	if !ok {
+		names := make([]string, 0, len(thresholdMetrics()))
+		for name := range thresholdMetrics() {
+			names = append(names, name)
+		}
+
+		slices.Sort(names)
+
+		return fmt.Errorf("%w %q: unknown metric %q (want one of %s)", errInvalidThreshold, s, name, strings.Join(names, ", "))
+	}
+
+	value, err := parseLimit(metric.kind, limit)
+	if err != nil {
+		return fmt.Errorf("%w %q: %w", errInvalidThreshold, s, err)
+	}
+
+	*t = threshold{metric: name, op: op, limit: value, text: fmt.Sprintf("%s %s %s", name, op, limit)}
+
+	return nil
+}
+
+// parseLimit parses a threshold limit for a metric of the given kind.
+func parseLimit(kind int, s string) (float64, error) {
+	switch kind {
+	case metricDuration:
+		d, err := time.ParseDuration(s)
+
+		return d.Seconds(), err
+	case metricSize:
+		n, err := parseSize(s)
+
+		return float64(n), err
+	default:
+		return strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	}
+}
+
+// exceeded returns the value of the threshold's metric, averaged over runs,
+// and whether it fails the threshold.
+func (t threshold) exceeded(runs []Metrics) (float64, bool) {
+	value := thresholdMetrics()[t.metric].value
+
+	var sum float64
+	for _, m := range runs {
+		sum += value(m)
+	}
+
+	mean := sum / float64(len(runs))
+
+	switch t.op {
+	case ">":
+		return mean, mean > t.limit
+	case ">=":
+		return mean, mean >= t.limit
+	case "<":
+		return mean, mean < t.limit
+	default:
+		return mean, mean <= t.limit
+	}
+}
+
+// formatMetric renders a value of the named metric in its unit.
+func formatMetric(name string, value float64) string {
+	switch thresholdMetrics()[name].kind {
+	case metricDuration:
+		return humanDuration(time.Duration(value * float64(time.Second)))
+	case metricSize:
+		return humanBytes(int64(value))
+	default:
+		return strconv.FormatFloat(value, 'g', 4, 64)
+	}
+}
+
+// checkThresholds tests the --budget limits and --fail-if thresholds
+// against the mean of runs and returns errThresholdExceeded, listing the
+// failed thresholds with the measured values, if any failed.
+func (c *runCmd) checkThresholds(runs ...Metrics) error {
+	if len(runs) == 0 {
+		return nil
+	}
+
+	failed := c.checkBudget(runs)
+
+	for _, t := range c.FailIf {
+		if value, exceeded := t.exceeded(runs); exceeded {
+			failed = append(failed, fmt.Sprintf("%s (was %s)", t.text, formatMetric(t.metric, value)))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %s", errThresholdExceeded, strings.Join(failed, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestThresholdUnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in       string
+		expected threshold
+		wantErr  bool
+	}{
+		{in: "elapsed > 30s", expected: threshold{metric: "elapsed", op: ">", limit: 30, text: "elapsed > 30s"}},
+		{in: "max_rss>=2G", expected: threshold{metric: "max_rss", op: ">=", limit: 2 << 30, text: "max_rss >= 2G"}},
+		{in: "cpu < 90%", expected: threshold{metric: "cpu", op: "<", limit: 90, text: "cpu < 90%"}},
+		{in: "page_faults <= 0", expected: threshold{metric: "page_faults", op: "<=", limit: 0, text: "page_faults <= 0"}},
+		{in: "elapsed = 30s", wantErr: true},
+		{in: "elapsed > 30", wantErr: true},
+		{in: "max_rss > lots", wantErr: true},
+		{in: "latency > 1s", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var got threshold
+
+		err := got.UnmarshalText([]byte(tt.in))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("UnmarshalText(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+
+			continue
+		}
+
+		if !tt.wantErr && got != tt.expected {
+			t.Errorf("UnmarshalText(%q) = %+v, want %+v", tt.in, got, tt.expected)
+		}
+	}
+}
+
+func TestCheckThresholds(t *testing.T) {
+	t.Parallel()
+
+	runs := []Metrics{
+		{ElapsedTime: 1 * time.Second, MaxRSS: 1024},
+		{ElapsedTime: 3 * time.Second, MaxRSS: 3072},
+	}
+
+	tests := []struct {
+		name      string
+		condition string
+		exceeded  bool
+	}{
+		{name: "Mean Above Limit", condition: "elapsed > 1500ms", exceeded: true},
+		{name: "Mean Below Limit", condition: "elapsed > 2500ms"},
+		{name: "Mean At Inclusive Limit", condition: "elapsed >= 2s", exceeded: true},
+		{name: "Size In Bytes", condition: "max_rss > 2M"},
+		{name: "Lower Bound", condition: "max_rss < 4M", exceeded: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var th threshold
+			if err := th.UnmarshalText([]byte(tt.condition)); err != nil {
+				t.Fatal(err)
+			}
+
+			c := runCmd{FailIf: []threshold{th}}
+
+			if err := c.checkThresholds(runs...); errors.Is(err, errThresholdExceeded) != tt.exceeded {
+				t.Errorf("checkThresholds() error = %v, want exceeded %v", err, tt.exceeded)
+			}
+		})
+	}
+}
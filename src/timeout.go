@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+var errTimedOut = errors.New("command timed out")
+
+// watchTimeout calls kill if the command is still running after d. kill
+// gets a channel that is closed once the command has exited, for waiting
+// out a grace period. The returned stop must be called once the command has
+// exited; it waits for kill to return and reports whether it was called.
+func watchTimeout(d time.Duration, kill func(exited <-chan struct{})) (stop func() bool) {
+	if d <= 0 {
+		return func() bool { return false }
+	}
+
+	exited, killed := make(chan struct{}), make(chan struct{})
+	timer := time.AfterFunc(d, func() {
+		defer close(killed)
+
+		kill(exited)
+	})
+
+	return func() bool {
+		stopped := timer.Stop()
+		close(exited)
+
+		if stopped {
+			return false
+		}
+
+		<-killed
+
+		return true
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatchTimeout(t *testing.T) {
+	t.Parallel()
+
+	if stop := watchTimeout(0, func(<-chan struct{}) { t.Error("kill called without a timeout") }); stop() {
+		t.Error("stop() = true without a timeout")
+	}
+
+	if stop := watchTimeout(time.Minute, func(<-chan struct{}) { t.Error("kill called before the timeout") }); stop() {
+		t.Error("stop() = true before the timeout")
+	}
+
+	// kill waits for the command to exit, as a grace period would.
+	killed := make(chan struct{})
+	stop := watchTimeout(time.Millisecond, func(exited <-chan struct{}) {
+		close(killed)
+		<-exited
+	})
+
+	<-killed
+
+	if !stop() {
+		t.Error("stop() = false after the timeout")
+	}
+}
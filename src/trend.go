@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+)
+
+const (
+	trendHeight = 12 // rows of the chart
+	trendGutter = 10 // cells left of the chart for the axis labels
+)
+
+var (
+	errInvalidTime = errors.New("invalid time")
+	errNoTrendRuns = errors.New("no recorded runs match")
+)
+
+// trendCmd charts the elapsed times of recorded runs over time.
+type trendCmd struct {
+	Since timeBound `help:"Only chart runs started at or after WHEN: a date such as 2026-01-31, an RFC 3339 time, or an age such as 2w, 10d, or 36h." placeholder:"WHEN"`
+	Until timeBound `help:"Only chart runs started before WHEN, in the same forms as --since." placeholder:"WHEN"`
+	CSV   bool      `name:"csv" help:"Write the matching runs to stdout as CSV instead of charting them."`
+
+	Pattern string `arg:"" help:"Regular expression matched against the recorded command lines."`
+}
+
+// timeBound is a --since or --until argument.
+type timeBound struct {
+	time.Time
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for kong, taking a
+// local date, a local date and time, an RFC 3339 time, or an age counted
+// back from now in weeks (2w), days (10d), or a Go duration (36h).
+func (b *timeBound) UnmarshalText(text []byte) error {
+	s := string(text)
+
+	for _, layout := range []string{time.DateOnly, "2006-01-02T15:04", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			b.Time = t
+
+			return nil
+		}
+	}
+
+	unit := time.Duration(0)
+
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+
+	var (
+		ago time.Duration
+		err error
+	)
+
+	if unit > 0 {
+		var n float64
+
+		n, err = strconv.ParseFloat(s[:len(s)-1], 64)
+		ago = time.Duration(n * float64(unit))
+	} else {
+		ago, err = time.ParseDuration(s)
+	}
+
+	if err != nil || ago < 0 {
+		return fmt.Errorf("%w %q: want a date such as 2026-01-31 or an age such as 2w", errInvalidTime, s)
+	}
+
+	b.Time = time.Now().Add(-ago)
+
+	return nil
+}
+
+// Run charts the matching runs, or writes them as CSV.
+func (c *trendCmd) Run() error {
+	pattern, err := regexp.Compile(c.Pattern)
+	if err != nil {
+		return fmt.Errorf("parsing pattern: %w", err)
+	}
+
+	dir, err := historyDir()
+	if err != nil {
+		return err
+	}
+
+	history, err := loadAllHistory(dir)
+	if err != nil {
+		return err
+	}
+
+	runs := c.matching(history, pattern)
+	if len(runs) == 0 {
+		return fmt.Errorf("%w %q in %s", errNoTrendRuns, c.Pattern, dir)
+	}
+
+	if c.CSV {
+		return writeTrendCSV(os.Stdout, runs)
+	}
+
+	width := 80
+	if w, _, err := term.GetSize(os.Stdout.Fd()); err == nil && w > 0 {
+		width = w
+	}
+
+	fmt.Print(trendReport(runs, width-trendGutter-1, trendHeight))
+
+	return nil
+}
+
+// matching returns the runs of history whose command matches pattern and
+// that started between --since and --until, oldest first.
+func (c *trendCmd) matching(history []Metrics, pattern *regexp.Regexp) []Metrics {
+	var runs []Metrics
+
+	for _, m := range history {
+		switch {
+		case !pattern.MatchString(m.Command):
+		case !c.Since.IsZero() && m.StartTime.Before(c.Since.Time):
+		case !c.Until.IsZero() && !m.StartTime.Before(c.Until.Time):
+		default:
+			runs = append(runs, m)
+		}
+	}
+
+	slices.SortStableFunc(runs, func(a, b Metrics) int { return a.StartTime.Compare(b.StartTime) })
+
+	return runs
+}
+
+// writeTrendCSV writes one row per run: its start time, command line,
+// elapsed seconds, and exit code.
+func writeTrendCSV(w io.Writer, runs []Metrics) error {
+	out := csv.NewWriter(w)
+
+	if err := out.Write([]string{"start_time", "command", "elapsed_seconds", "exit_code"}); err != nil {
+		return err
+	}
+
+	for _, m := range runs {
+		err := out.Write([]string{
+			m.StartTime.Format(time.RFC3339),
+			m.Command,
+			strconv.FormatFloat(m.ElapsedTime.Seconds(), 'f', microPrecision, 64),
+			strconv.Itoa(m.ExitCode),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+
+	return out.Error()
+}
+
+// trendReport is the chart of runs, oldest first, with a summary line
+// comparing the latest third of them with the earliest.
+func trendReport(runs []Metrics, width, height int) string {
+	var b strings.Builder
+
+	commands := map[string]bool{}
+	for _, m := range runs {
+		commands[m.Command] = true
+	}
+
+	title := runs[0].Command
+	if len(commands) > 1 {
+		title = fmt.Sprintf("%d commands", len(commands))
+	}
+
+	fmt.Fprintf(&b, "%s  %d runs from %s to %s\n\n", palette.command.Render(title), len(runs),
+		runs[0].StartTime.Local().Format(time.DateOnly), runs[len(runs)-1].StartTime.Local().Format(time.DateOnly))
+
+	b.WriteString(trendChart(runs, width, height))
+
+	elapsed := elapsedTimes(runs)
+	stats := summarize(elapsed)
+
+	fmt.Fprintf(&b, "\nmean %s ± %s, min %s, max %s", humanDuration(stats.Mean), humanDuration(stats.StdDev),
+		humanDuration(stats.Min), humanDuration(stats.Max))
+
+	if third := len(elapsed) / 3; third > 0 {
+		first, last := summarize(elapsed[:third]).P50, summarize(elapsed[len(elapsed)-third:]).P50
+		fmt.Fprintf(&b, "; median %s → %s (%s) from the first to the last third of the runs",
+			humanDuration(first), humanDuration(last), percentChange(float64(first), float64(last)))
+	}
+
+	b.WriteString("\n")
+
+	return b.String()
+}
+
+// trendChart plots elapsed time against start time. Each column covers an
+// equal stretch of time and shows the mean of the runs in it as a dot,
+// scaled between the fastest and slowest columns; columns without runs
+// stay empty.
+func trendChart(runs []Metrics, width, height int) string {
+	width, height = max(width, 2), max(height, 2)
+
+	first, last := runs[0].StartTime, runs[len(runs)-1].StartTime
+	span := last.Sub(first)
+
+	sums := make([]time.Duration, width)
+	counts := make([]int, width)
+
+	for _, m := range runs {
+		col := 0
+		if span > 0 {
+			col = int(float64(m.StartTime.Sub(first)) / float64(span) * float64(width-1))
+		}
+
+		sums[col] += m.ElapsedTime
+		counts[col]++
+	}
+
+	var means []time.Duration
+
+	for col, n := range counts {
+		if n > 0 {
+			sums[col] /= time.Duration(n)
+			means = append(means, sums[col])
+		}
+	}
+
+	lowest, highest := slices.Min(means), slices.Max(means)
+
+	grid := make([][]rune, height)
+	for row := range grid {
+		grid[row] = []rune(strings.Repeat(" ", width))
+	}
+
+	for col, n := range counts {
+		if n == 0 {
+			continue
+		}
+
+		row := (height - 1) / 2
+		if highest > lowest {
+			row = int(float64(highest-sums[col]) / float64(highest-lowest) * float64(height-1))
+		}
+
+		grid[row][col] = '●'
+	}
+
+	faint := lipgloss.NewStyle().Faint(true)
+
+	var b strings.Builder
+
+	for row, cells := range grid {
+		label := ""
+
+		switch row {
+		case 0:
+			label = humanDuration(highest)
+		case (height - 1) / 2:
+			label = humanDuration(highest - (highest-lowest)*time.Duration(row)/time.Duration(height-1))
+		case height - 1:
+			label = humanDuration(lowest)
+		}
+
+		fmt.Fprintf(&b, "%s %s%s\n", faint.Render(fmt.Sprintf("%*s", trendGutter-2, label)), faint.Render("│"), palette.plot.Render(strings.TrimRight(string(cells), " ")))
+	}
+
+	from, to := first.Local().Format(time.DateOnly), last.Local().Format(time.DateOnly)
+	axis := strings.Repeat(" ", trendGutter) + from
+
+	if span > 0 {
+		axis += strings.Repeat(" ", max(width-len(from)-len(to), 1)) + to
+	}
+
+	fmt.Fprintf(&b, "%s %s\n%s\n", strings.Repeat(" ", trendGutter-2), faint.Render("└"+strings.Repeat("─", width)), faint.Render(axis))
+
+	return b.String()
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeBoundUnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	tests := []struct {
+		in       string
+		expected time.Time
+		wantErr  bool
+	}{
+		{in: "2026-01-31", expected: time.Date(2026, 1, 31, 0, 0, 0, 0, time.Local)},
+		{in: "2026-01-31T08:30", expected: time.Date(2026, 1, 31, 8, 30, 0, 0, time.Local)},
+		{in: "2026-01-31T08:30:00Z", expected: time.Date(2026, 1, 31, 8, 30, 0, 0, time.UTC)},
+		{in: "2w", expected: now.Add(-14 * 24 * time.Hour)},
+		{in: "1.5d", expected: now.Add(-36 * time.Hour)},
+		{in: "36h", expected: now.Add(-36 * time.Hour)},
+		{in: "yesterday", wantErr: true},
+		{in: "-2d", wantErr: true},
+		{in: "d", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var got timeBound
+
+		err := got.UnmarshalText([]byte(tt.in))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("UnmarshalText(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+
+			continue
+		}
+
+		if err != nil {
+			if !errors.Is(err, errInvalidTime) {
+				t.Errorf("UnmarshalText(%q) error = %v, want %v", tt.in, err, errInvalidTime)
+			}
+
+			continue
+		}
+
+		if diff := got.Sub(tt.expected).Abs(); diff > time.Minute {
+			t.Errorf("UnmarshalText(%q) = %v, want %v", tt.in, got.Time, tt.expected)
+		}
+	}
+}
+
+func trendRuns() []Metrics {
+	start := time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	return []Metrics{
+		{Command: "make test", ElapsedTime: 4 * time.Second, StartTime: start.Add(48 * time.Hour)},
+		{Command: "go build", ElapsedTime: time.Second, StartTime: start.Add(24 * time.Hour)},
+		{Command: "make test", ElapsedTime: 2 * time.Second, StartTime: start},
+		{Command: "make test", ElapsedTime: 3 * time.Second, StartTime: start.Add(24 * time.Hour)},
+	}
+}
+
+func TestTrendMatching(t *testing.T) {
+	t.Parallel()
+
+	since := time.Date(2026, 9, 2, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 9, 3, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		cmd      trendCmd
+		pattern  string
+		expected []time.Duration
+	}{
+		{name: "pattern", pattern: "^make", expected: []time.Duration{2 * time.Second, 3 * time.Second, 4 * time.Second}},
+		{name: "since", cmd: trendCmd{Since: timeBound{since}}, pattern: "make", expected: []time.Duration{3 * time.Second, 4 * time.Second}},
+		{name: "until is exclusive", cmd: trendCmd{Until: timeBound{until}}, pattern: "make", expected: []time.Duration{2 * time.Second, 3 * time.Second}},
+		{name: "no match", pattern: "cargo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := elapsedTimes(tt.cmd.matching(trendRuns(), regexp.MustCompile(tt.pattern)))
+			if len(got) != len(tt.expected) {
+				t.Fatalf("matching() = %v, want %v", got, tt.expected)
+			}
+
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("matching() = %v, want %v", got, tt.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteTrendCSV(t *testing.T) {
+	t.Parallel()
+
+	runs := []Metrics{{
+		Command:     `sh -c "echo a, b"`,
+		ElapsedTime: 1500 * time.Millisecond,
+		ExitCode:    2,
+		StartTime:   time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC),
+	}}
+
+	var out bytes.Buffer
+	if err := writeTrendCSV(&out, runs); err != nil {
+		t.Fatalf("writeTrendCSV() error = %v", err)
+	}
+
+	expected := "start_time,command,elapsed_seconds,exit_code\n" +
+		`2026-09-01T12:00:00Z,"sh -c ""echo a, b""",1.500000,2` + "\n"
+
+	if out.String() != expected {
+		t.Errorf("writeTrendCSV() =\n%s\nwant\n%s", out.String(), expected)
+	}
+}
+
+func TestTrendChart(t *testing.T) {
+	t.Parallel()
+
+	var cmd trendCmd
+
+	runs := cmd.matching(trendRuns(), regexp.MustCompile("make"))
+	lines := strings.Split(strings.TrimRight(trendChart(runs, 5, 3), "\n"), "\n")
+
+	// Three runs a day apart fall in the first, middle, and last columns,
+	// each slower than the one before.
+	expected := []string{
+		"   4.00s │    ●",
+		"   3.00s │  ●",
+		"   2.00s │●",
+		"         └─────",
+	}
+
+	for i, want := range expected {
+		if i >= len(lines) || lines[i] != want {
+			t.Errorf("chart line %d = %q, want %q\n%s", i, lines[min(i, len(lines)-1)], want, strings.Join(lines, "\n"))
+		}
+	}
+
+	report := trendReport(runs, 5, 3)
+	if !strings.Contains(report, "median 2.00s → 4.00s (+100.0%)") {
+		t.Errorf("trendReport() lacks the change between thirds:\n%s", report)
+	}
+}
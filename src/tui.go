@@ -0,0 +1,492 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+)
+
+var (
+	errTUITerminal = errors.New("ztime tui needs a terminal on stdin and stdout")
+	errNoHistory   = errors.New("no recorded runs")
+)
+
+// tuiCmd browses the runs recorded by ztime check and ztime serve.
+type tuiCmd struct {
+	Filter string `help:"Start with the runs matching this filter: words the command must contain and KEY=VALUE tags it must have." placeholder:"FILTER"`
+}
+
+// tuiView is a screen of the browser.
+type tuiView int
+
+const (
+	tuiList tuiView = iota
+	tuiDetail
+	tuiCompare
+	tuiTrend
+)
+
+// tuiModel is the state of the browser, run as a bubbletea model. update
+// changes it in response to a key, and render draws it.
+type tuiModel struct {
+	runs    []Metrics // every recorded run, newest first
+	shown   []int     // the runs matching the filter
+	filter  string
+	editing bool // typing a filter
+	cursor  int  // in shown
+	marked  int  // run marked for comparison, or -1
+	view    tuiView
+	width   int
+	height  int
+	quit    bool
+}
+
+func newTUIModel(runs []Metrics, filter string) *tuiModel {
+	t := &tuiModel{runs: runs, filter: filter, marked: -1, width: 80, height: 24}
+	t.applyFilter()
+
+	return t
+}
+
+// Run loads the history and shows the list of runs until q is pressed.
+func (c *tuiCmd) Run() error {
+	if !term.IsTerminal(os.Stdin.Fd()) || !term.IsTerminal(os.Stdout.Fd()) {
+		return errTUITerminal
+	}
+
+	dir, err := historyDir()
+	if err != nil {
+		return err
+	}
+
+	runs, err := loadAllHistory(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(runs) == 0 {
+		return fmt.Errorf("%w in %s; ztime check and ztime serve record them", errNoHistory, dir)
+	}
+
+	// The alternate screen leaves the shell's scrollback as it was.
+	if _, err := tea.NewProgram(newTUIModel(runs, c.Filter), tea.WithAltScreen()).Run(); err != nil {
+		return fmt.Errorf("running the browser: %w", err)
+	}
+
+	return nil
+}
+
+// Init starts the browser, which waits for the terminal's size and the
+// first key.
+func (t *tuiModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles a key, or a change of the terminal's size, which
+// bubbletea reports at the start and on every resize, redrawing the
+// screen to fit.
+func (t *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		t.width, t.height = msg.Width, msg.Height
+	case tea.KeyMsg:
+		t.update(msg.String())
+	}
+
+	if t.quit {
+		return t, tea.Quit
+	}
+
+	return t, nil
+}
+
+// View draws the current screen.
+func (t *tuiModel) View() string {
+	return t.render()
+}
+
+// loadAllHistory reads every history file in dir, newest run first.
+func loadAllHistory(dir string) ([]Metrics, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []Metrics
+
+	for _, path := range paths {
+		history, err := loadHistory(path)
+		if err != nil {
+			return nil, err
+		}
+
+		runs = append(runs, history...)
+	}
+
+	slices.SortStableFunc(runs, func(a, b Metrics) int { return b.StartTime.Compare(a.StartTime) })
+
+	return runs, nil
+}
+
+// update handles one key, named as bubbletea names it: "up", "down",
+// "pgup", "pgdown", "enter", "esc", "backspace", "ctrl+c", or the
+// character typed.
+func (t *tuiModel) update(key string) {
+	if key == "ctrl+c" {
+		t.quit = true
+
+		return
+	}
+
+	if t.editing {
+		t.editFilter(key)
+
+		return
+	}
+
+	if t.view != tuiList {
+		if key == "esc" || key == "backspace" || key == "q" || key == "enter" {
+			t.view = tuiList
+		}
+
+		return
+	}
+
+	page := max(t.listRows(), 1)
+
+	switch key {
+	case "q", "esc":
+		t.quit = true
+	case "up", "k":
+		t.cursor = max(t.cursor-1, 0)
+	case "down", "j":
+		t.cursor = min(t.cursor+1, max(len(t.shown)-1, 0))
+	case "pgup":
+		t.cursor = max(t.cursor-page, 0)
+	case "pgdown":
+		t.cursor = min(t.cursor+page, max(len(t.shown)-1, 0))
+	case "/":
+		t.editing = true
+	case "enter":
+		if len(t.shown) > 0 {
+			t.view = tuiDetail
+		}
+	case " ", "m":
+		if len(t.shown) > 0 {
+			if t.marked == t.shown[t.cursor] {
+				t.marked = -1
+			} else {
+				t.marked = t.shown[t.cursor]
+			}
+		}
+	case "c":
+		if t.marked >= 0 && len(t.shown) > 0 && t.marked != t.shown[t.cursor] {
+			t.view = tuiCompare
+		}
+	case "t":
+		if len(t.shown) > 0 {
+			t.view = tuiTrend
+		}
+	}
+}
+
+// editFilter handles a key typed into the filter, which applies as it
+// changes.
+func (t *tuiModel) editFilter(key string) {
+	switch key {
+	case "enter":
+		t.editing = false
+	case "esc":
+		t.editing = false
+		t.filter = ""
+	case "backspace":
+		if r := []rune(t.filter); len(r) > 0 {
+			t.filter = string(r[:len(r)-1])
+		}
+	default:
+		if len([]rune(key)) != 1 {
+			return
+		}
+
+		t.filter += key
+	}
+
+	t.applyFilter()
+}
+
+// applyFilter selects the runs matching the filter, keeping the cursor on
+// the same run when it still matches.
+func (t *tuiModel) applyFilter() {
+	current := -1
+	if t.cursor < len(t.shown) {
+		current = t.shown[t.cursor]
+	}
+
+	t.shown, t.cursor = t.shown[:0], 0
+
+	for i, m := range t.runs {
+		if !matchesFilter(m, t.filter) {
+			continue
+		}
+
+		if i == current {
+			t.cursor = len(t.shown)
+		}
+
+		t.shown = append(t.shown, i)
+	}
+}
+
+// matchesFilter reports whether the command of m contains every word of
+// filter and m has every KEY=VALUE tag in it. Words match without regard
+// to case.
+func matchesFilter(m Metrics, filter string) bool {
+	command := strings.ToLower(m.Command)
+
+	for _, word := range strings.Fields(filter) {
+		if key, value, ok := strings.Cut(word, "="); ok {
+			if tag, found := m.Tags[key]; !found || tag != value {
+				return false
+			}
+
+			continue
+		}
+
+		if !strings.Contains(command, strings.ToLower(word)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// listRows is how many runs fit on the list screen, below the header and
+// above the help line.
+func (t *tuiModel) listRows() int {
+	return t.height - 3
+}
+
+// render draws the current screen.
+func (t *tuiModel) render() string {
+	switch t.view {
+	case tuiDetail:
+		return t.renderDetail(t.runs[t.shown[t.cursor]])
+	case tuiCompare:
+		return t.renderCompare(t.runs[t.marked], t.runs[t.shown[t.cursor]])
+	case tuiTrend:
+		return t.renderTrend(t.runs[t.shown[t.cursor]].Command)
+	default:
+		return t.renderList()
+	}
+}
+
+var (
+	tuiTitle    = lipgloss.NewStyle().Bold(true)
+	tuiHelp     = lipgloss.NewStyle().Faint(true)
+	tuiSelected = lipgloss.NewStyle().Reverse(true)
+)
+
+func (t *tuiModel) renderList() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s  %d of %d runs\n\n", tuiTitle.Render("ztime history"), len(t.shown), len(t.runs))
+
+	rows := max(t.listRows(), 1)
+	first := min(max(t.cursor-rows/2, 0), max(len(t.shown)-rows, 0))
+
+	for i := first; i < min(first+rows, len(t.shown)); i++ {
+		index := t.shown[i]
+
+		mark := "  "
+		if index == t.marked {
+			mark = "* "
+		}
+
+		line := truncate(mark+runLine(t.runs[index]), t.width)
+		if i == t.cursor {
+			line = tuiSelected.Render(line)
+		}
+
+		b.WriteString(line + "\n")
+	}
+
+	for range rows - (min(first+rows, len(t.shown)) - first) {
+		b.WriteString("\n")
+	}
+
+	if t.editing {
+		b.WriteString("filter: " + t.filter + "█")
+	} else {
+		help := "↑/↓ move  enter details  space mark  c compare with mark  t trend  / filter  q quit"
+		if t.filter != "" {
+			help = "filter: " + t.filter + "  " + help
+		}
+
+		b.WriteString(tuiHelp.Render(truncate(help, t.width)))
+	}
+
+	return b.String()
+}
+
+// runLine describes a run in one line of the list.
+func runLine(m Metrics) string {
+	line := fmt.Sprintf("%s  %9s  ", m.StartTime.Local().Format("2006-01-02 15:04"), humanDuration(m.ElapsedTime))
+
+	if m.ExitCode != 0 {
+		line += fmt.Sprintf("exit %-3d ", m.ExitCode)
+	} else {
+		line += "         "
+	}
+
+	line += m.Command
+
+	for _, key := range slices.Sorted(maps.Keys(m.Tags)) {
+		line += "  " + key + "=" + m.Tags[key]
+	}
+
+	return line
+}
+
+// truncate shortens s to width cells, or leaves it alone if width is not
+// positive.
+func truncate(s string, width int) string {
+	if width <= 0 || lipgloss.Width(s) <= width {
+		return s
+	}
+
+	r := []rune(s)
+	for len(r) > 0 && lipgloss.Width(string(r)) > width-1 {
+		r = r[:len(r)-1]
+	}
+
+	return string(r) + "…"
+}
+
+func (t *tuiModel) renderDetail(m Metrics) string {
+	var b strings.Builder
+
+	b.WriteString(summaryLong(m, formatOptions{all: true}))
+
+	fmt.Fprintf(&b, "  %-11s  %s\n", "started", m.StartTime.Local().Format(time.DateTime))
+	fmt.Fprintf(&b, "  %-11s  %d\n", "exit", m.ExitCode)
+
+	for _, key := range slices.Sorted(maps.Keys(m.Tags)) {
+		fmt.Fprintf(&b, "  %-11s  %s=%s\n", "tag", key, m.Tags[key])
+	}
+
+	if m.Note != "" {
+		fmt.Fprintf(&b, "  %-11s  %s\n", "note", m.Note)
+	}
+
+	if m.Git != nil {
+		fmt.Fprintf(&b, "  %-11s  %s\n", "git", m.Git.Commit)
+	}
+
+	b.WriteString("\n" + tuiHelp.Render("esc back  q quit"))
+
+	return b.String()
+}
+
+// renderCompare puts the metrics of the marked run and the one under the
+// cursor side by side, with the change from the first to the second.
+func (t *tuiModel) renderCompare(a, b Metrics) string {
+	var out strings.Builder
+
+	fmt.Fprintf(&out, "%s\n\n", tuiTitle.Render("compare"))
+	fmt.Fprintf(&out, "  %-8s  %s\n  %-8s  %s\n\n", "marked", runLine(a), "selected", runLine(b))
+
+	row := func(label, first, second, change string) {
+		fmt.Fprintf(&out, "  %-12s %12s %12s  %s\n", label, first, second, change)
+	}
+
+	durations := []struct {
+		label string
+		get   func(Metrics) time.Duration
+	}{
+		{"elapsed", func(m Metrics) time.Duration { return m.ElapsedTime }},
+		{"user", func(m Metrics) time.Duration { return m.UserTime }},
+		{"system", func(m Metrics) time.Duration { return m.SystemTime }},
+	}
+
+	for _, d := range durations {
+		row(d.label, humanDuration(d.get(a)), humanDuration(d.get(b)), percentChange(float64(d.get(a)), float64(d.get(b))))
+	}
+
+	row("cpu", fmt.Sprintf("%d%%", a.CPUPercent), fmt.Sprintf("%d%%", b.CPUPercent), percentChange(float64(a.CPUPercent), float64(b.CPUPercent)))
+	row("max rss", humanBytes(a.MaxRSS*1024), humanBytes(b.MaxRSS*1024), percentChange(float64(a.MaxRSS), float64(b.MaxRSS)))
+	row("exit", fmt.Sprint(a.ExitCode), fmt.Sprint(b.ExitCode), "")
+
+	out.WriteString("\n" + tuiHelp.Render("esc back  q quit"))
+
+	return out.String()
+}
+
+// percentChange formats the change from a to b, colored as worse when it
+// grew, or returns "" when a is zero.
+func percentChange(a, b float64) string {
+	if a == 0 {
+		return ""
+	}
+
+	change := (b - a) / a * 100
+	text := fmt.Sprintf("%+.1f%%", change)
+
+	switch {
+	case math.Abs(change) < 0.05:
+		return text
+	case change > 0:
+		return palette.bad.Render(text)
+	default:
+		return palette.good.Render(text)
+	}
+}
+
+// renderTrend charts the elapsed times of every recorded run of command,
+// oldest first.
+func (t *tuiModel) renderTrend(command string) string {
+	var runs []Metrics
+
+	for _, m := range t.runs {
+		if m.Command == command {
+			runs = append(runs, m)
+		}
+	}
+
+	slices.SortStableFunc(runs, func(a, b Metrics) int { return cmp.Compare(a.StartTime.UnixNano(), b.StartTime.UnixNano()) })
+
+	elapsed := elapsedTimes(runs)
+	stats := summarize(elapsed)
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s  %s\n\n", tuiTitle.Render("trend"), palette.command.Render(command))
+	fmt.Fprintf(&b, "  runs: %d, from %s to %s\n", len(runs), runs[0].StartTime.Local().Format(time.DateOnly), runs[len(runs)-1].StartTime.Local().Format(time.DateOnly))
+	fmt.Fprintf(&b, "  mean %s ± %s, min %s, max %s\n\n", humanDuration(stats.Mean), humanDuration(stats.StdDev), humanDuration(stats.Min), humanDuration(stats.Max))
+
+	if len(elapsed) > 1 {
+		fmt.Fprintf(&b, "  runs     %s\n\n", palette.plot.Render(sparkline(elapsed, max(min(t.width-12, len(elapsed)), 1))))
+
+		bins, lower, width := histogram(elapsed, plotMaxBins)
+		peak := slices.Max(bins)
+
+		for i, n := range bins {
+			bar := strings.Repeat("█", int(math.Ceil(float64(n)*plotBarWidth/float64(peak))))
+			fmt.Fprintf(&b, "  %7s  %s %d\n", humanDuration(lower+time.Duration(i)*width), palette.plot.Render(bar), n)
+		}
+	}
+
+	b.WriteString("\n" + tuiHelp.Render("esc back  q quit"))
+
+	return b.String()
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestTUIUpdate(t *testing.T) {
+	t.Parallel()
+
+	m := newTUIModel(tuiRuns(), "")
+
+	if _, cmd := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40}); cmd != nil || m.width != 120 || m.height != 40 {
+		t.Errorf("after a resize, size = %dx%d, want 120x40", m.width, m.height)
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+
+	if m.cursor != 1 {
+		t.Errorf("cursor = %d after down, want 1", m.cursor)
+	}
+
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")}); cmd == nil || !m.quit {
+		t.Error("q on the list did not quit")
+	}
+}
+
+func TestMatchesFilter(t *testing.T) {
+	t.Parallel()
+
+	m := Metrics{Command: "cargo build --release", Annotations: Annotations{Tags: map[string]string{"host": "ci"}}}
+
+	tests := []struct {
+		filter   string
+		expected bool
+	}{
+		{"", true},
+		{"cargo", true},
+		{"BUILD release", true},
+		{"test", false},
+		{"host=ci", true},
+		{"cargo host=local", false},
+		{"branch=main", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchesFilter(m, tt.filter); got != tt.expected {
+			t.Errorf("matchesFilter(%q) = %v, want %v", tt.filter, got, tt.expected)
+		}
+	}
+}
+
+func tuiRuns() []Metrics {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	return []Metrics{
+		{Command: "make test", ElapsedTime: 3 * time.Second, StartTime: start.Add(3 * time.Hour)},
+		{Command: "go build", ElapsedTime: 2 * time.Second, StartTime: start.Add(2 * time.Hour)},
+		{Command: "make test", ElapsedTime: 4 * time.Second, StartTime: start.Add(time.Hour)},
+		{Command: "go build", ElapsedTime: time.Second, StartTime: start},
+	}
+}
+
+func TestTUIFilter(t *testing.T) {
+	t.Parallel()
+
+	m := newTUIModel(tuiRuns(), "")
+	m.update("down")
+
+	for _, key := range []string{"/", "m", "a", "k", "e", "enter"} {
+		m.update(key)
+	}
+
+	if m.editing || m.filter != "make" || !slices.Equal(m.shown, []int{0, 2}) {
+		t.Fatalf("after filtering: editing %v, filter %q, shown %v", m.editing, m.filter, m.shown)
+	}
+
+	// Clearing the filter keeps the cursor on the same run.
+	m.update("down")
+	m.update("/")
+	m.update("esc")
+
+	if m.filter != "" || len(m.shown) != 4 || m.shown[m.cursor] != 2 {
+		t.Errorf("after clearing: filter %q, shown %v, cursor %d", m.filter, m.shown, m.cursor)
+	}
+}
+
+func TestTUIViews(t *testing.T) {
+	t.Parallel()
+
+	m := newTUIModel(tuiRuns(), "")
+
+	// Compare needs a marked run other than the selected one.
+	m.update("c")
+
+	if m.view != tuiList {
+		t.Fatalf("compare without a mark opened view %v", m.view)
+	}
+
+	m.update(" ")
+	m.update("down")
+	m.update("down")
+	m.update("c")
+
+	if m.view != tuiCompare {
+		t.Fatalf("view = %v, want compare", m.view)
+	}
+
+	if screen := m.render(); !strings.Contains(screen, "+33.3%") {
+		t.Errorf("compare screen lacks the elapsed change:\n%s", screen)
+	}
+
+	m.update("esc")
+	m.update("t")
+
+	if screen := m.render(); !strings.Contains(screen, "runs: 2") || !strings.Contains(screen, "mean 3.50s") {
+		t.Errorf("trend screen:\n%s", screen)
+	}
+
+	m.update("esc")
+	m.update("enter")
+
+	if screen := m.render(); m.view != tuiDetail || !strings.Contains(screen, "make test") {
+		t.Errorf("detail screen:\n%s", screen)
+	}
+
+	m.update("q")
+	m.update("q")
+
+	if !m.quit {
+		t.Error("q on the list did not quit")
+	}
+}
+
+func TestLoadAllHistory(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	runs := tuiRuns()
+
+	for i, m := range runs {
+		if err := appendHistory(filepath.Join(dir, []string{"a", "b"}[i%2]+".jsonl"), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := loadAllHistory(dir)
+	if err != nil {
+		t.Fatalf("loadAllHistory() error = %v", err)
+	}
+
+	if len(got) != len(runs) {
+		t.Fatalf("loadAllHistory() returned %d runs, want %d", len(got), len(runs))
+	}
+
+	for i := range got {
+		if !got[i].StartTime.Equal(runs[i].StartTime) {
+			t.Errorf("run %d started %v, want %v (newest first)", i, got[i].StartTime, runs[i].StartTime)
+		}
+	}
+}
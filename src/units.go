@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errInvalidSize = errors.New("invalid size")
+
+// humanDuration renders d with a unit suited to its magnitude, e.g. "850µs",
+// "12.40ms", "3.25s", or "2m5.3s".
+func humanDuration(d time.Duration) string {
+	switch {
+	case d < time.Millisecond:
+		return fmt.Sprintf("%dµs", d.Microseconds())
+	case d < time.Second:
+		return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+	case d < time.Minute:
+		return fmt.Sprintf("%.2fs", d.Seconds())
+	default:
+		return d.Round(100 * time.Millisecond).String()
+	}
+}
+
+// humanBytes renders n bytes using binary units, e.g. "512 B" or "1.2 GiB".
+func humanBytes(n int64) string {
+	const unit = 1024
+
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// parseSize parses a byte count such as "512", "1.5M", "2GiB", or "100KB".
+// K, M, G, and T (with or without "iB") are binary multiples; "KB", "MB",
+// "GB", and "TB" are decimal.
+func parseSize(s string) (int64, error) {
+	trimmed := strings.TrimSpace(s)
+	end := strings.LastIndexAny(trimmed, "0123456789.") + 1
+
+	value, err := strconv.ParseFloat(trimmed[:end], 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("%w: %q", errInvalidSize, s)
+	}
+
+	multiplier, ok := sizeUnits()[strings.ToUpper(strings.TrimSpace(trimmed[end:]))]
+	if !ok {
+		return 0, fmt.Errorf("%w: unknown unit in %q", errInvalidSize, s)
+	}
+
+	return int64(value * float64(multiplier)), nil
+}
+
+func sizeUnits() map[string]int64 {
+	units := map[string]int64{"": 1, "B": 1}
+
+	for i, prefix := range []string{"K", "M", "G", "T"} {
+		binary := int64(1) << (10 * (i + 1))
+		decimal := int64(1)
+
+		for range i + 1 {
+			decimal *= 1000
+		}
+
+		units[prefix] = binary
+		units[prefix+"IB"] = binary
+		units[prefix+"B"] = decimal
+	}
+
+	return units
+}
+
+// byteSize is a byte count flag value such as "512M" or "2GiB".
+type byteSize int64
+
+// UnmarshalText implements encoding.TextUnmarshaler for kong.
+func (s *byteSize) UnmarshalText(text []byte) error {
+	n, err := parseSize(string(text))
+	if err != nil {
+		return err
+	}
+
+	*s = byteSize(n)
+
+	return nil
+}
+
+// byteRate is a bytes-per-second flag value such as "1MiB/s" or "500K".
+type byteRate int64
+
+// UnmarshalText implements encoding.TextUnmarshaler for kong.
+func (r *byteRate) UnmarshalText(text []byte) error {
+	n, err := parseSize(strings.TrimSuffix(string(text), "/s"))
+	if err != nil {
+		return err
+	}
+
+	*r = byteRate(n)
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHumanDuration(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in       time.Duration
+		expected string
+	}{
+		{850 * time.Microsecond, "850µs"},
+		{12400 * time.Microsecond, "12.40ms"},
+		{3250 * time.Millisecond, "3.25s"},
+		{125300 * time.Millisecond, "2m5.3s"},
+	}
+
+	for _, tt := range tests {
+		if got := humanDuration(tt.in); got != tt.expected {
+			t.Errorf("humanDuration(%v) = %q, want %q", tt.in, got, tt.expected)
+		}
+	}
+}
+
+func TestHumanBytes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in       int64
+		expected string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536 * 1024, "1.5 MiB"},
+		{1288490189, "1.2 GiB"},
+	}
+
+	for _, tt := range tests {
+		if got := humanBytes(tt.in); got != tt.expected {
+			t.Errorf("humanBytes(%d) = %q, want %q", tt.in, got, tt.expected)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in       string
+		expected int64
+	}{
+		{"512", 512},
+		{"512B", 512},
+		{"1.5K", 1536},
+		{"2MiB", 2 << 20},
+		{"2G", 2 << 30},
+		{"100KB", 100000},
+		{"1 mb", 1000000},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSize(tt.in)
+		if err != nil || got != tt.expected {
+			t.Errorf("parseSize(%q) = %d, %v; want %d", tt.in, got, err, tt.expected)
+		}
+	}
+
+	for _, in := range []string{"", "abc", "-1K", "10XB"} {
+		if _, err := parseSize(in); err == nil {
+			t.Errorf("parseSize(%q) expected error", in)
+		}
+	}
+}
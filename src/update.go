@@ -0,0 +1,431 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	_ "embed"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errNoReleaseAsset = errors.New("release has no archive for this platform")
+	errChecksum       = errors.New("checksum mismatch")
+	errNoChecksum     = errors.New("archive is not listed in checksums.txt")
+	errNoBinary       = errors.New("archive has no ztime binary")
+	errSignature      = errors.New("checksums.txt is not signed by the ztime release key")
+	errReleaseStatus  = errors.New("release lookup failed")
+)
+
+// releasesAPI is where releases are looked up.
+const releasesAPI = "https://api.github.com/repos/howmanysmall/ztime/releases"
+
+// updateTimeout bounds a release lookup or download.
+const updateTimeout = 5 * time.Minute
+
+// releaseKey is the public half of the key that signs each release's
+// checksums.txt with "cosign sign-blob", as set up in .goreleaser.yaml. It
+// is a PEM-encoded ECDSA P-256 key.
+//
+//go:embed release.pub
+var releaseKey []byte
+
+// versionCmd prints the version, and with --check whether a newer release
+// exists.
+type versionCmd struct {
+	Check bool `help:"Look up the latest release on GitHub and report whether it is newer."`
+
+	API string `name:"api" hidden:"" help:"Releases API to query instead of GitHub's."`
+}
+
+// selfUpdateCmd replaces the running binary with a release.
+type selfUpdateCmd struct {
+	Version string `help:"Install this release tag instead of the latest, such as v1.4.0." placeholder:"TAG"`
+	Force   bool   `help:"Install the release even if it is not newer than this build."`
+
+	API string `name:"api" hidden:"" help:"Releases API to query instead of GitHub's."`
+}
+
+// release is the part of a GitHub release that ztime uses.
+type release struct {
+	Tag    string `json:"tag_name"`
+	Assets []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// Run prints the version.
+func (c *versionCmd) Run() error {
+	fmt.Printf("ztime %s (%s/%s)\n", serverVersion, runtime.GOOS, runtime.GOARCH)
+
+	if !c.Check {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), updateTimeout)
+	defer cancel()
+
+	r, err := fetchRelease(ctx, cmp.Or(c.API, releasesAPI), "")
+	if err != nil {
+		return err
+	}
+
+	if newerVersion(r.Tag, serverVersion) {
+		fmt.Printf("ztime %s is available; run \"ztime self-update\" to install it\n", r.Tag)
+	} else {
+		fmt.Println("ztime is up to date")
+	}
+
+	return nil
+}
+
+// Run downloads the release archive for this platform and checksums.txt
+// with its signature, checks the signature against the release key and the
+// archive's SHA-256 against checksums.txt, and swaps the binary in.
+func (c *selfUpdateCmd) Run() error {
+	ctx, cancel := context.WithTimeout(context.Background(), updateTimeout)
+	defer cancel()
+
+	r, err := fetchRelease(ctx, cmp.Or(c.API, releasesAPI), c.Version)
+	if err != nil {
+		return err
+	}
+
+	if !c.Force && c.Version == "" && !newerVersion(r.Tag, serverVersion) {
+		fmt.Fprintf(os.Stderr, "ztime: %s is the latest release; nothing to do\n", serverVersion)
+
+		return nil
+	}
+
+	name := releaseArchive(runtime.GOOS, runtime.GOARCH)
+
+	archiveURL, checksumsURL, signatureURL := r.asset(name), r.asset("checksums.txt"), r.asset("checksums.txt.sig")
+	if archiveURL == "" || checksumsURL == "" || signatureURL == "" {
+		return fmt.Errorf("%w: %s in %s", errNoReleaseAsset, name, r.Tag)
+	}
+
+	archive, err := download(ctx, archiveURL)
+	if err != nil {
+		return err
+	}
+
+	checksums, err := download(ctx, checksumsURL)
+	if err != nil {
+		return err
+	}
+
+	signature, err := download(ctx, signatureURL)
+	if err != nil {
+		return err
+	}
+
+	if err := verifySignature(checksums, signature, releaseKey); err != nil {
+		return err
+	}
+
+	if err := verifyChecksum(name, archive, checksums); err != nil {
+		return err
+	}
+
+	binary, err := extractBinary(name, archive)
+	if err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+
+	if err := replaceExecutable(exe, binary); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "ztime: updated %s from %s to %s\n", exe, serverVersion, r.Tag)
+
+	return nil
+}
+
+// asset returns the download URL of the named asset, or "".
+func (r release) asset(name string) string {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL
+		}
+	}
+
+	return ""
+}
+
+// fetchRelease looks up a release by tag, or the latest one.
+func fetchRelease(ctx context.Context, api, tag string) (release, error) {
+	url := api + "/latest"
+	if tag != "" {
+		url = api + "/tags/" + tag
+	}
+
+	data, err := download(ctx, url)
+	if err != nil {
+		return release{}, err
+	}
+
+	var r release
+	if err := json.Unmarshal(data, &r); err != nil {
+		return release{}, fmt.Errorf("reading %s: %w", url, err)
+	}
+
+	return r, nil
+}
+
+func download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s: %s", errReleaseStatus, url, resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// releaseArchive is the name of the release archive for a platform, as
+// .goreleaser.yaml names them.
+func releaseArchive(goos, goarch string) string {
+	arch := goarch
+	if arch == "amd64" {
+		arch = "x86_64"
+	}
+
+	ext := ".tar.gz"
+	if goos == "windows" {
+		ext = ".zip"
+	}
+
+	return "ztime_" + goos + "_" + arch + ext
+}
+
+// verifySignature checks that signature, as "cosign sign-blob" writes it
+// (a base64 ASN.1 ECDSA signature of the SHA-256 of the data), is key's
+// signature of checksums.
+func verifySignature(checksums, signature, key []byte) error {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return fmt.Errorf("%w: the release key is not PEM", errSignature)
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errSignature, err)
+	}
+
+	pub, ok := parsed.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("%w: the release key is not ECDSA", errSignature)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("%w: %w", errSignature, err)
+	}
+
+	digest := sha256.Sum256(checksums)
+	if !ecdsa.VerifyASN1(pub, digest[:], sig) {
+		return errSignature
+	}
+
+	return nil
+}
+
+// verifyChecksum checks archive against its line in checksums.txt, in
+// sha256sum's "HASH  NAME" format.
+func verifyChecksum(name string, archive, checksums []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[1] != name {
+			continue
+		}
+
+		sum := sha256.Sum256(archive)
+		if hex.EncodeToString(sum[:]) != strings.ToLower(fields[0]) {
+			return fmt.Errorf("%w: %s", errChecksum, name)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", errNoChecksum, name)
+}
+
+// extractBinary returns the ztime executable from a release archive.
+func extractBinary(name string, archive []byte) ([]byte, error) {
+	if strings.HasSuffix(name, ".zip") {
+		zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range zr.File {
+			if filepath.Base(f.Name) == "ztime.exe" {
+				rc, err := f.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer rc.Close()
+
+				return io.ReadAll(rc)
+			}
+		}
+
+		return nil, errNoBinary
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+
+	tr := tar.NewReader(gz)
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, errNoBinary
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if hdr.Typeflag == tar.TypeReg && filepath.Base(hdr.Name) == "ztime" {
+			return io.ReadAll(tr)
+		}
+	}
+}
+
+// replaceExecutable writes binary next to exe and renames it over exe, so
+// that a failure leaves the old binary in place. Windows cannot replace a
+// running executable, so the old one is moved aside to exe.old first.
+func replaceExecutable(exe string, binary []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".ztime-update-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	//nolint:gosec // Intended behavior: the binary must be executable.
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return err
+	}
+
+	if runtime.GOOS != "windows" {
+		return os.Rename(tmp.Name(), exe)
+	}
+
+	old := exe + ".old"
+	_ = os.Remove(old)
+
+	if err := os.Rename(exe, old); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		_ = os.Rename(old, exe)
+
+		return err
+	}
+
+	return nil
+}
+
+// newerVersion reports whether tag is a later release than current.
+// Development builds are older than any release.
+func newerVersion(tag, current string) bool {
+	latest, ok := parseVersion(tag)
+	if !ok {
+		return false
+	}
+
+	have, ok := parseVersion(current)
+	if !ok {
+		return true
+	}
+
+	for i := range latest {
+		if latest[i] != have[i] {
+			return latest[i] > have[i]
+		}
+	}
+
+	return false
+}
+
+// parseVersion reads "v1.2.3" or "1.2.3", ignoring any pre-release or
+// build suffix.
+func parseVersion(s string) ([3]int, bool) {
+	var v [3]int
+
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) != len(v) {
+		return v, false
+	}
+
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, false
+		}
+
+		v[i] = n
+	}
+
+	return v, true
+}
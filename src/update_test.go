@@ -0,0 +1,250 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewerVersion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		tag, current string
+		want         bool
+	}{
+		{"v1.2.0", "1.1.9", true},
+		{"v1.10.0", "1.9.0", true},
+		{"v1.2.0", "1.2.0", false},
+		{"v1.2.0", "1.3.0", false},
+		{"v2.0.0-rc1", "1.9.9", true},
+		{"v1.0.0", "dev", true},
+		{"nightly", "1.0.0", false},
+	}
+
+	for _, tt := range tests {
+		if got := newerVersion(tt.tag, tt.current); got != tt.want {
+			t.Errorf("newerVersion(%q, %q) = %v, want %v", tt.tag, tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestReleaseArchive(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct{ goos, goarch, want string }{
+		{"linux", "amd64", "ztime_linux_x86_64.tar.gz"},
+		{"darwin", "arm64", "ztime_darwin_arm64.tar.gz"},
+		{"windows", "amd64", "ztime_windows_x86_64.zip"},
+	}
+
+	for _, tt := range tests {
+		if got := releaseArchive(tt.goos, tt.goarch); got != tt.want {
+			t.Errorf("releaseArchive(%q, %q) = %q, want %q", tt.goos, tt.goarch, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	t.Parallel()
+
+	archive := []byte("archive")
+	sum := sha256.Sum256(archive)
+	checksums := []byte(hex.EncodeToString(sum[:]) + "  ztime_linux_x86_64.tar.gz\n" + "00  checksums.txt\n")
+
+	if err := verifyChecksum("ztime_linux_x86_64.tar.gz", archive, checksums); err != nil {
+		t.Errorf("verifyChecksum() error = %v", err)
+	}
+
+	if err := verifyChecksum("ztime_linux_x86_64.tar.gz", []byte("tampered"), checksums); !errors.Is(err, errChecksum) {
+		t.Errorf("verifyChecksum(tampered) error = %v, want %v", err, errChecksum)
+	}
+
+	if err := verifyChecksum("ztime_darwin_arm64.tar.gz", archive, checksums); !errors.Is(err, errNoChecksum) {
+		t.Errorf("verifyChecksum(unlisted) error = %v, want %v", err, errNoChecksum)
+	}
+}
+
+// signingKey returns a new release key, PEM-encoded as in release.pub.
+func signingKey(t *testing.T) (*ecdsa.PrivateKey, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestVerifySignature(t *testing.T) {
+	t.Parallel()
+
+	key, pub := signingKey(t)
+	_, otherPub := signingKey(t)
+
+	checksums := []byte("abc123  ztime_linux_x86_64.tar.gz\n")
+	digest := sha256.Sum256(checksums)
+
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature := []byte(base64.StdEncoding.EncodeToString(sig) + "\n")
+
+	tests := []struct {
+		name      string
+		checksums []byte
+		signature []byte
+		key       []byte
+		wantErr   error
+	}{
+		{"signed", checksums, signature, pub, nil},
+		{"tampered", []byte("def456  ztime_linux_x86_64.tar.gz\n"), signature, pub, errSignature},
+		{"other key", checksums, signature, otherPub, errSignature},
+		{"not base64", checksums, []byte("%%%"), pub, errSignature},
+		{"no key", checksums, signature, nil, errSignature},
+	}
+
+	for _, tt := range tests {
+		if err := verifySignature(tt.checksums, tt.signature, tt.key); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: verifySignature() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestReleaseKey(t *testing.T) {
+	t.Parallel()
+
+	// Any signature will do: the key must parse for verification to fail
+	// on the signature rather than on the key.
+	err := verifySignature(nil, []byte("AA=="), releaseKey)
+	if !errors.Is(err, errSignature) || err.Error() != errSignature.Error() {
+		t.Errorf("verifySignature() with the release key error = %v, want only a bad signature", err)
+	}
+}
+
+func TestExtractBinary(t *testing.T) {
+	t.Parallel()
+
+	var tgz bytes.Buffer
+
+	gz := gzip.NewWriter(&tgz)
+	tw := tar.NewWriter(gz)
+
+	for name, body := range map[string]string{"README.md": "docs", "ztime": "binary"} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := extractBinary("ztime_linux_x86_64.tar.gz", tgz.Bytes()); err != nil || string(got) != "binary" {
+		t.Errorf("extractBinary(tar.gz) = %q, %v; want \"binary\"", got, err)
+	}
+
+	var zipped bytes.Buffer
+
+	zw := zip.NewWriter(&zipped)
+
+	w, err := zw.Create("ztime.exe")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Write([]byte("exe")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := extractBinary("ztime_windows_x86_64.zip", zipped.Bytes()); err != nil || string(got) != "exe" {
+		t.Errorf("extractBinary(zip) = %q, %v; want \"exe\"", got, err)
+	}
+}
+
+func TestFetchRelease(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/releases/latest":
+			fmt.Fprint(w, `{"tag_name":"v1.5.0","assets":[{"name":"checksums.txt","browser_download_url":"http://x/checksums.txt"}]}`)
+		case "/releases/tags/v1.4.0":
+			fmt.Fprint(w, `{"tag_name":"v1.4.0"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	r, err := fetchRelease(t.Context(), server.URL+"/releases", "")
+	if err != nil || r.Tag != "v1.5.0" || r.asset("checksums.txt") != "http://x/checksums.txt" || r.asset("nope") != "" {
+		t.Errorf("fetchRelease(latest) = %+v, %v", r, err)
+	}
+
+	if r, err := fetchRelease(t.Context(), server.URL+"/releases", "v1.4.0"); err != nil || r.Tag != "v1.4.0" {
+		t.Errorf("fetchRelease(v1.4.0) = %+v, %v", r, err)
+	}
+
+	if _, err := fetchRelease(t.Context(), server.URL+"/releases", "v0.0.1"); !errors.Is(err, errReleaseStatus) {
+		t.Errorf("fetchRelease(missing) error = %v, want %v", err, errReleaseStatus)
+	}
+}
+
+func TestReplaceExecutable(t *testing.T) {
+	t.Parallel()
+
+	exe := filepath.Join(t.TempDir(), "ztime")
+	if err := os.WriteFile(exe, []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := replaceExecutable(exe, []byte("new")); err != nil {
+		t.Fatalf("replaceExecutable() error = %v", err)
+	}
+
+	if data, err := os.ReadFile(exe); err != nil || string(data) != "new" {
+		t.Errorf("binary = %q, %v; want \"new\"", data, err)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(exe))
+	if err != nil || len(entries) > 2 {
+		t.Errorf("directory has %d entries, want no leftover temporary file", len(entries))
+	}
+}
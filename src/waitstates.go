@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// waitStateInterval is how often --wait-states reads the process state.
+const waitStateInterval = 10 * time.Millisecond
+
+// WaitStates splits the unaccounted time of a run by the states the
+// command's process was seen in with --wait-states (Linux).
+type WaitStates struct {
+	Samples  int `json:"samples" unit:"count"`
+	Running  int `json:"running" unit:"count"`   // samples in state R, on or waiting for a CPU
+	Sleeping int `json:"sleeping" unit:"count"`  // samples in state S, waiting for an event
+	DiskWait int `json:"disk_wait" unit:"count"` // samples in state D, uninterruptible, usually I/O
+	Other    int `json:"other" unit:"count"`     // stopped, traced, or zombie
+
+	// Estimates: the unaccounted time shared out between the sleeping and
+	// disk wait samples.
+	SleepTime    time.Duration `json:"sleep_time"`
+	DiskWaitTime time.Duration `json:"disk_wait_time"`
+}
+
+// waitSampler reads the state of a running process until stopped.
+type waitSampler struct {
+	done    chan struct{}
+	stopped chan struct{}
+	states  WaitStates
+}
+
+// sampleWaitStates starts sampling the state of pid. It returns nil if
+// enabled is false.
+func sampleWaitStates(enabled bool, pid int) *waitSampler {
+	if !enabled {
+		return nil
+	}
+
+	s := &waitSampler{done: make(chan struct{}), stopped: make(chan struct{})}
+
+	go func() {
+		defer close(s.stopped)
+
+		ticker := time.NewTicker(waitStateInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				if state, ok := sampleState(pid); ok {
+					s.states.count(state)
+				}
+			}
+		}
+	}()
+
+	return s
+}
+
+// stop ends the sampling and splits unaccounted by the samples, or returns
+// nil if the sampler was not started.
+func (s *waitSampler) stop(unaccounted time.Duration) *WaitStates {
+	if s == nil {
+		return nil
+	}
+
+	close(s.done)
+	<-s.stopped
+
+	states := s.states
+	if waiting := states.Sleeping + states.DiskWait; waiting > 0 {
+		states.DiskWaitTime = unaccounted * time.Duration(states.DiskWait) / time.Duration(waiting)
+		states.SleepTime = unaccounted - states.DiskWaitTime
+	}
+
+	return &states
+}
+
+func (w *WaitStates) count(state byte) {
+	w.Samples++
+
+	switch state {
+	case 'R':
+		w.Running++
+	case 'S', 'I':
+		w.Sleeping++
+	case 'D':
+		w.DiskWait++
+	default:
+		w.Other++
+	}
+}
+
+// unaccountedNote describes how the unaccounted time of a run splits into
+// sleeping and disk wait, or returns "" without --wait-states.
+func unaccountedNote(m Metrics) string {
+	w := m.WaitStates
+	if w == nil {
+		return ""
+	}
+
+	if w.Samples == 0 {
+		return fmt.Sprintf("unaccounted %.3fs, no process state samples", m.Unaccounted.Seconds())
+	}
+
+	return fmt.Sprintf("unaccounted %.3fs: ~%.3fs sleeping, ~%.3fs disk wait (%d samples, %.0f%% running)",
+		m.Unaccounted.Seconds(), w.SleepTime.Seconds(), w.DiskWaitTime.Seconds(), w.Samples, float64(w.Running)/float64(w.Samples)*100)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaitSamplerStop(t *testing.T) {
+	t.Parallel()
+
+	s := &waitSampler{done: make(chan struct{}), stopped: make(chan struct{})}
+	close(s.stopped)
+
+	for _, state := range []byte("RSSSDZI") {
+		s.states.count(state)
+	}
+
+	got := s.stop(1500 * time.Millisecond)
+	want := WaitStates{Samples: 7, Running: 1, Sleeping: 4, DiskWait: 1, Other: 1, SleepTime: 1200 * time.Millisecond, DiskWaitTime: 300 * time.Millisecond}
+
+	if *got != want {
+		t.Errorf("stop() = %+v, want %+v", *got, want)
+	}
+
+	if (*waitSampler)(nil).stop(time.Second) != nil {
+		t.Error("stop() on a nil sampler returned states")
+	}
+}
+
+func TestUnaccountedNote(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		m    Metrics
+		want string
+	}{
+		{"not sampled", Metrics{Unaccounted: time.Second}, ""},
+		{"no samples", Metrics{Unaccounted: time.Second, WaitStates: &WaitStates{}}, "unaccounted 1.000s, no process state samples"},
+		{
+			"split",
+			Metrics{Unaccounted: 2 * time.Second, WaitStates: &WaitStates{Samples: 40, Running: 10, Sleeping: 20, DiskWait: 10, SleepTime: 1333 * time.Millisecond, DiskWaitTime: 667 * time.Millisecond}},
+			"unaccounted 2.000s: ~1.333s sleeping, ~0.667s disk wait (40 samples, 25% running)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := unaccountedNote(tt.m); got != tt.want {
+				t.Errorf("unaccountedNote() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatUnaccounted(t *testing.T) {
+	t.Parallel()
+
+	m := Metrics{Unaccounted: 1250 * time.Millisecond}
+
+	if got := format("%i %*.1i", m, formatOptions{}); got != "1.25s 0:01.2" {
+		t.Errorf("format() = %q, want %q", got, "1.25s 0:01.2")
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// watchWindow is the number of recent runs averaged by --watch.
+const watchWindow = 10
+
+// watch runs the command every --watch interval, or whenever a file under
+// the --on-change paths changes, until interrupted. Each run's elapsed time
+// is printed next to the average of the recent runs so that a command
+// getting slower stands out.
+func (c *runCmd) watch() error {
+	next := c.waitInterval
+
+	if len(c.OnChange) > 0 {
+		changes, err := watchChanges(c.OnChange, c.OnChangeExclude)
+		if err != nil {
+			return err
+		}
+		defer changes.close()
+
+		// Changes made while the command ran, including its own writes,
+		// are dropped: the next run waits for a change after this one.
+		next = func(ctx context.Context) bool {
+			changes.discard(ctx)
+
+			return changes.wait(ctx)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The exporters get every run's result and are shut down once the
+	// watch ends.
+	defer c.closeExporters()
+
+	var recent []time.Duration
+
+	for n := 1; ; n++ {
+		m, err := c.measure(c.Command, c.runOptions(runOptions{iteration: n}))
+
+		// A failing command is reported and watched on; a failing hook or a
+		// command that cannot start ends the watch without a result.
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			return err
+		}
+
+		c.sendResult("run", m)
+
+		recent = append(recent, m.ElapsedTime)
+		if len(recent) > watchWindow {
+			recent = recent[1:]
+		}
+
+		if !c.Quiet {
+			if c.structured() || c.tmpl != nil {
+				c.report(m)
+			} else {
+				printWatchLine(n, m, summarize(recent))
+			}
+		}
+
+		if interrupted(m.SignalLog) || !next(ctx) {
+			return nil
+		}
+	}
+}
+
+// waitInterval pauses for the --watch interval, like watch(1): the time
+// between the end of one run and the start of the next. It returns false
+// if ctx is cancelled first.
+func (c *runCmd) waitInterval(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(c.Watch):
+		return true
+	}
+}
+
+// printWatchLine prints one --watch or --on-change run: its number, end time, and elapsed
+// time, the mean and range of the recent runs, and how it failed if it
+// did.
+func printWatchLine(n int, m Metrics, recent DurationStats) {
+	bold := lipgloss.NewStyle().Bold(true)
+	faint := lipgloss.NewStyle().Faint(true)
+
+	secs := func(d time.Duration) string { return fmt.Sprintf("%.3fs", d.Seconds()) }
+
+	line := fmt.Sprintf("%s  %s  %s  avg %s  min %s  max %s",
+		faint.Render(fmt.Sprintf("#%-4d", n)),
+		faint.Render(m.EndTime.Local().Format(time.TimeOnly)),
+		bold.Render(secs(m.ElapsedTime)),
+		secs(recent.Mean), secs(recent.Min), secs(recent.Max),
+	)
+
+	if n > watchWindow {
+		line += faint.Render(fmt.Sprintf("  (last %d)", watchWindow))
+	}
+
+	switch {
+	case m.Killed:
+		line += "  " + palette.bad.Render(m.TermSignal)
+	case m.ExitCode != 0:
+		line += "  " + palette.bad.Render(fmt.Sprintf("exit %d", m.ExitCode))
+	}
+
+	fmt.Fprintln(os.Stderr, line)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchStopsOnHookFailure(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	c := runCmd{Watch: time.Millisecond, Prepare: "exit 3", Quiet: true, Command: []string{"true"}}
+
+	if err := c.watch(); !errors.Is(err, errHookFailed) {
+		t.Errorf("watch() error = %v, want %v", err, errHookFailed)
+	}
+}
+
+func TestWatchExportsEveryRun(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	dir := t.TempDir()
+	out, count := filepath.Join(dir, "messages"), filepath.Join(dir, "count")
+
+	e, err := startExporter(`read hello; echo '{"type":"ready","protocol":1}'; cat > ` + shellQuote(out))
+	if err != nil {
+		t.Fatalf("startExporter() error = %v", err)
+	}
+
+	// The prepare hook fails on the third run, ending the watch after two.
+	prepare := "n=$(cat " + shellQuote(count) + " 2>/dev/null || echo 0); n=$((n + 1)); echo $n > " + shellQuote(count) + "; [ $n -le 2 ]"
+	c := runCmd{Watch: time.Millisecond, Prepare: prepare, Quiet: true, Command: []string{"true"}, exporters: []*exporter{e}}
+
+	if err := c.watch(); !errors.Is(err, errHookFailed) {
+		t.Fatalf("watch() error = %v, want %v", err, errHookFailed)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("reading exporter output: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("exporter received %d results, want 2: %q", len(lines), lines)
+	}
+
+	for i, line := range lines {
+		var msg exporterMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil || msg.Type != "result" || msg.Kind != "run" {
+			t.Errorf("message %d = %s, want a run result", i+1, line)
+		}
+	}
+}
+
+func TestOnChangeIgnoresOwnWrites(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	watched, state := t.TempDir(), t.TempDir()
+	runs, count := filepath.Join(state, "runs"), filepath.Join(state, "count")
+
+	// The command writes under the watched path on every run, and the
+	// prepare hook fails on the third run, ending the watch after two.
+	prepare := "n=$(cat " + shellQuote(count) + " 2>/dev/null || echo 0); n=$((n + 1)); echo $n > " + shellQuote(count) + "; [ $n -le 2 ]"
+	c := runCmd{
+		OnChange: []string{watched},
+		Prepare:  prepare,
+		Quiet:    true,
+		Command:  []string{"sh", "-c", "echo run >> " + shellQuote(runs) + "; date > " + shellQuote(filepath.Join(watched, "output"))},
+	}
+
+	done := make(chan error, 1)
+
+	go func() { done <- c.watch() }()
+
+	lines := func() int {
+		data, _ := os.ReadFile(runs)
+
+		return strings.Count(string(data), "\n")
+	}
+
+	time.Sleep(time.Second)
+
+	if n := lines(); n != 1 {
+		t.Fatalf("command ran %d times before anything else changed, want once", n)
+	}
+
+	// A change from outside starts the next run, whose own write does not.
+	if err := os.WriteFile(filepath.Join(watched, "input"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Second)
+
+	if n := lines(); n != 2 {
+		t.Fatalf("command ran %d times after one change, want twice", n)
+	}
+
+	if err := os.WriteFile(filepath.Join(watched, "input"), []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, errHookFailed) {
+			t.Errorf("watch() error = %v, want %v", err, errHookFailed)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("watch() did not run again after the second change")
+	}
+}
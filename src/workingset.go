@@ -0,0 +1,94 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// WorkingSet summarizes the files the command and its children opened.
+type WorkingSet struct {
+	Files      int          `json:"files" unit:"count"`
+	TotalBytes int64        `json:"total_bytes" unit:"bytes"` // sum of the file sizes
+	Read       int          `json:"read" unit:"count"`        // files read from
+	Written    int          `json:"written" unit:"count"`     // files written to
+	Top        []FileAccess `json:"top"`                      // largest files first
+	Incomplete bool         `json:"incomplete,omitempty"`
+}
+
+// FileAccess is one file in a WorkingSet.
+type FileAccess struct {
+	Path    string `json:"path"`
+	Bytes   int64  `json:"bytes" unit:"bytes"` // file size at the last access
+	Read    bool   `json:"read"`
+	Written bool   `json:"written"`
+}
+
+// merge combines two accesses to the same file.
+func (a FileAccess) merge(b FileAccess) FileAccess {
+	a.Bytes = b.Bytes
+	a.Read = a.Read || b.Read
+	a.Written = a.Written || b.Written
+
+	return a
+}
+
+func newWorkingSet(files map[string]FileAccess, top int, incomplete bool) *WorkingSet {
+	ws := &WorkingSet{Files: len(files), Incomplete: incomplete}
+
+	all := make([]FileAccess, 0, len(files))
+
+	for _, f := range files {
+		ws.TotalBytes += f.Bytes
+
+		if f.Read {
+			ws.Read++
+		}
+
+		if f.Written {
+			ws.Written++
+		}
+
+		all = append(all, f)
+	}
+
+	slices.SortFunc(all, func(a, b FileAccess) int {
+		return cmp.Or(cmp.Compare(b.Bytes, a.Bytes), strings.Compare(a.Path, b.Path))
+	})
+
+	ws.Top = all[:min(top, len(all))]
+
+	return ws
+}
+
+// workingSetNote describes the working set over several lines, or returns
+// "" if none was recorded.
+func workingSetNote(ws *WorkingSet) string {
+	if ws == nil {
+		return ""
+	}
+
+	var note strings.Builder
+
+	fmt.Fprintf(&note, "opened %d files (%s; %d read, %d written)", ws.Files, humanBytes(ws.TotalBytes), ws.Read, ws.Written)
+
+	if ws.Incomplete {
+		note.WriteString(", some accesses were missed")
+	}
+
+	for _, f := range ws.Top {
+		mode := []byte("--")
+		if f.Read {
+			mode[0] = 'r'
+		}
+
+		if f.Written {
+			mode[1] = 'w'
+		}
+
+		fmt.Fprintf(&note, "\n  %10s  %s  %s", humanBytes(f.Bytes), mode, f.Path)
+	}
+
+	return note.String()
+}
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// fanotifyMetadataLen is the size of struct fanotify_event_metadata.
+	fanotifyMetadataLen = 24
+
+	// fileWatchDrain is how long ztime keeps reading queued events after
+	// the command exits.
+	fileWatchDrain = 100 * time.Millisecond
+
+	fileWatchMask = unix.FAN_OPEN | unix.FAN_ACCESS | unix.FAN_MODIFY | unix.FAN_CLOSE_WRITE
+)
+
+// fileWatcher records file accesses on every mount with fanotify, which
+// requires CAP_SYS_ADMIN. Events come from every process on the system;
+// they are attributed to the command by walking each process's parents.
+// It builds the --working-set summary if top is positive and the
+// --fs-trace one if traceTop is.
+type fileWatcher struct {
+	cmd      *exec.Cmd
+	file     *os.File
+	top      int
+	traceTop int
+	owner    int // in the sudo helper, the only user whose processes are attributed; 0, root, sees all
+	done     chan struct{}
+	fds      map[int32]map[string]map[int]int64 // last position of each descriptor a process had on a file; used by the reader only
+
+	mu         sync.Mutex
+	byPID      map[int32]map[string]FileAccess
+	traces     map[int32]map[string]*pathTrace
+	ancestors  map[int32][]int
+	incomplete bool
+}
+
+// pathTrace is what one process did to one file. Bytes are estimated from
+// how far the process's descriptors on the file had moved between the
+// events ztime read, which misses positioned I/O and memory-mapped access.
+type pathTrace struct {
+	activity PathActivity
+}
+
+// fileReport is what a file watch saw the command and its descendants do,
+// as the watch-files helper sends it back to ztime.
+type fileReport struct {
+	Files      map[string]FileAccess   `json:"files"`
+	Paths      map[string]PathActivity `json:"paths"`
+	Incomplete bool                    `json:"incomplete"`
+}
+
+// watchFiles starts watching file accesses for cmd, in the sudo helper with
+// sudo.
+func watchFiles(cmd *exec.Cmd, top, traceTop int, sudo bool) (instrument, error) {
+	if sudo {
+		return watchFilesWithSudo(cmd, top, traceTop)
+	}
+
+	w, err := startFileWatch(top, traceTop)
+	if err != nil {
+		return nil, err
+	}
+
+	w.cmd = cmd
+
+	return w, nil
+}
+
+func startFileWatch(top, traceTop int) (*fileWatcher, error) {
+	fd, err := unix.FanotifyInit(unix.FAN_CLASS_NOTIF|unix.FAN_CLOEXEC|unix.FAN_NONBLOCK, unix.O_RDONLY|unix.O_LARGEFILE|unix.O_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("starting file watch (requires root or --sudo-collectors): %w", err)
+	}
+
+	marked := 0
+
+	for _, mount := range mountPoints() {
+		if unix.FanotifyMark(fd, unix.FAN_MARK_ADD|unix.FAN_MARK_MOUNT, fileWatchMask, unix.AT_FDCWD, mount) == nil {
+			marked++
+		}
+	}
+
+	if marked == 0 {
+		_ = unix.Close(fd)
+
+		return nil, fmt.Errorf("starting file watch: %w", unix.ENOENT)
+	}
+
+	w := &fileWatcher{
+		file:      os.NewFile(uintptr(fd), "fanotify"),
+		top:       top,
+		traceTop:  traceTop,
+		done:      make(chan struct{}),
+		fds:       make(map[int32]map[string]map[int]int64),
+		byPID:     make(map[int32]map[string]FileAccess),
+		traces:    make(map[int32]map[string]*pathTrace),
+		ancestors: make(map[int32][]int),
+	}
+
+	go w.read()
+
+	return w, nil
+}
+
+// mountPoints lists the mount points of ztime's mount namespace. Pseudo
+// file systems that fanotify refuses are skipped when marking.
+func mountPoints() []string {
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return []string{"/"}
+	}
+	defer f.Close()
+
+	var mounts []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 1 {
+			mounts = append(mounts, unescapeMount(fields[1]))
+		}
+	}
+
+	return mounts
+}
+
+// unescapeMount decodes the octal escapes (such as \040 for a space) used in
+// /proc/self/mounts.
+func unescapeMount(s string) string {
+	var out strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				out.WriteByte(byte(n))
+
+				i += 3
+
+				continue
+			}
+		}
+
+		out.WriteByte(s[i])
+	}
+
+	return out.String()
+}
+
+func (w *fileWatcher) read() {
+	defer close(w.done)
+
+	buf := make([]byte, 64*1024)
+
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		w.handle(buf[:n])
+	}
+}
+
+// handle records a batch of fanotify events.
+func (w *fileWatcher) handle(buf []byte) {
+	self := int32(os.Getpid()) //nolint:gosec // PIDs fit in 32 bits.
+
+	for len(buf) >= fanotifyMetadataLen {
+		size := binary.NativeEndian.Uint32(buf[0:])
+		mask := binary.NativeEndian.Uint64(buf[8:])
+		fd := int32(binary.NativeEndian.Uint32(buf[16:]))  //nolint:gosec // Reinterprets the C int.
+		pid := int32(binary.NativeEndian.Uint32(buf[20:])) //nolint:gosec // Reinterprets the C int.
+
+		if size < fanotifyMetadataLen || int(size) > len(buf) {
+			return
+		}
+
+		buf = buf[size:]
+
+		if mask&unix.FAN_Q_OVERFLOW != 0 {
+			w.mu.Lock()
+			w.incomplete = true
+			w.mu.Unlock()
+		}
+
+		if fd < 0 {
+			continue
+		}
+
+		access, ok := describeFile(int(fd), mask)
+		_ = unix.Close(int(fd))
+
+		if !ok || pid == self {
+			continue
+		}
+
+		var moved int64
+		if w.traceTop > 0 && (access.Read || access.Written) {
+			moved = w.moved(pid, access.Path)
+		}
+
+		w.record(pid, access, mask, moved)
+	}
+}
+
+func describeFile(fd int, mask uint64) (FileAccess, bool) {
+	path, err := os.Readlink("/proc/self/fd/" + strconv.Itoa(fd))
+	if err != nil {
+		return FileAccess{}, false
+	}
+
+	var st unix.Stat_t
+	if unix.Fstat(fd, &st) != nil || st.Mode&unix.S_IFMT != unix.S_IFREG {
+		return FileAccess{}, false
+	}
+
+	return FileAccess{
+		Path:    path,
+		Bytes:   st.Size,
+		Read:    mask&unix.FAN_ACCESS != 0,
+		Written: mask&(unix.FAN_MODIFY|unix.FAN_CLOSE_WRITE) != 0,
+	}, true
+}
+
+// record stores an access by pid, whose descriptors on the file had moved
+// by moved bytes since its previous event. The bytes count as written if
+// the event reports a write, and as read otherwise. The parents of pid are
+// looked up on its first event, while the process is most likely still
+// running.
+func (w *fileWatcher) record(pid int32, access FileAccess, mask uint64, moved int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	files, ok := w.byPID[pid]
+	if !ok {
+		files = make(map[string]FileAccess)
+		w.byPID[pid] = files
+		w.traces[pid] = make(map[string]*pathTrace)
+
+		// Without ancestors, another user's process is never the command's.
+		if uid, ok := processUID(int(pid)); w.owner == 0 || ok && uid == w.owner {
+			w.ancestors[pid] = processAncestry(int(pid))
+		}
+	}
+
+	if prev, ok := files[access.Path]; ok {
+		access = prev.merge(access)
+	}
+
+	files[access.Path] = access
+
+	trace, ok := w.traces[pid][access.Path]
+	if !ok {
+		trace = &pathTrace{activity: PathActivity{Path: access.Path}}
+		w.traces[pid][access.Path] = trace
+	}
+
+	// A merged event can carry an open and the accesses after it.
+	if mask&unix.FAN_OPEN != 0 {
+		trace.activity.Opens++
+	}
+
+	if mask&unix.FAN_ACCESS != 0 {
+		trace.activity.Reads++
+	}
+
+	if mask&unix.FAN_MODIFY != 0 {
+		trace.activity.Writes++
+		trace.activity.WriteBytes += moved
+	} else if mask&unix.FAN_ACCESS != 0 {
+		trace.activity.ReadBytes += moved
+	}
+}
+
+// moved returns how far pid's descriptors on path have moved since its
+// previous event on the file. A descriptor that is new, or whose position
+// went back because it was reused or rewound, counts from the start of the
+// file. The descriptors found last time are checked first, as a process
+// usually works through one file at a time, and the process's descriptors
+// are only listed again when none of them still refers to path.
+func (w *fileWatcher) moved(pid int32, path string) int64 {
+	dir := "/proc/" + strconv.Itoa(int(pid))
+
+	if w.fds[pid] == nil {
+		w.fds[pid] = make(map[string]map[int]int64)
+	}
+
+	last := w.fds[pid][path]
+	current := make(map[int]int64)
+
+	for fd := range last {
+		if pos, ok := descriptorOffset(dir, strconv.Itoa(fd), path); ok {
+			current[fd] = pos
+		}
+	}
+
+	if len(current) == 0 {
+		entries, err := os.ReadDir(dir + "/fd")
+		if err != nil {
+			return 0
+		}
+
+		for _, entry := range entries {
+			fd, err := strconv.Atoi(entry.Name())
+			if err != nil {
+				continue
+			}
+
+			if pos, ok := descriptorOffset(dir, entry.Name(), path); ok {
+				current[fd] = pos
+			}
+		}
+	}
+
+	w.fds[pid][path] = current
+
+	return positionDelta(last, current)
+}
+
+// positionDelta sums how far each descriptor in current moved from its
+// position in last.
+func positionDelta(last, current map[int]int64) int64 {
+	var total int64
+
+	for fd, pos := range current {
+		if prev, ok := last[fd]; ok && pos >= prev {
+			total += pos - prev
+		} else {
+			total += pos
+		}
+	}
+
+	return total
+}
+
+// descriptorOffset returns the position of descriptor fd in the process
+// directory dir if it refers to path.
+func descriptorOffset(dir, fd, path string) (int64, bool) {
+	if target, err := os.Readlink(dir + "/fd/" + fd); err != nil || target != path {
+		return 0, false
+	}
+
+	data, err := os.ReadFile(dir + "/fdinfo/" + fd)
+	if err != nil {
+		return 0, false
+	}
+
+	return parseFdinfoPos(data)
+}
+
+// parseFdinfoPos returns the "pos:" field of /proc/<pid>/fdinfo/<fd>.
+func parseFdinfoPos(data []byte) (int64, bool) {
+	for line := range strings.Lines(string(data)) {
+		if value, ok := strings.CutPrefix(line, "pos:"); ok {
+			pos, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+
+			return pos, err == nil
+		}
+	}
+
+	return 0, false
+}
+
+// processAncestry returns pid followed by its parent, grandparent, and so on.
+func processAncestry(pid int) []int {
+	chain := []int{pid}
+
+	for len(chain) < 64 {
+		_, _, ppid, ok := readStat(chain[len(chain)-1])
+		if !ok || ppid <= 1 {
+			break
+		}
+
+		chain = append(chain, ppid)
+	}
+
+	return chain
+}
+
+// finish drains the remaining events and keeps those from the command and
+// its descendants.
+func (w *fileWatcher) finish(m *Metrics, _ time.Time) {
+	w.stop()
+
+	if w.cmd.ProcessState == nil {
+		return
+	}
+
+	w.report(w.cmd.ProcessState.Pid()).apply(m, w.top, w.traceTop)
+}
+
+// stop drains the remaining events and closes the watch.
+func (w *fileWatcher) stop() {
+	if w.file.SetReadDeadline(time.Now().Add(fileWatchDrain)) != nil {
+		_ = w.file.Close()
+	}
+
+	<-w.done
+
+	_ = w.file.Close()
+}
+
+// report gathers the accesses of child and its descendants.
+func (w *fileWatcher) report(child int) fileReport {
+	r := fileReport{Files: make(map[string]FileAccess), Paths: make(map[string]PathActivity)}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for pid, accesses := range w.byPID {
+		if !slices.Contains(w.ancestors[pid], child) {
+			continue
+		}
+
+		for path, access := range accesses {
+			if prev, ok := r.Files[path]; ok {
+				access = prev.merge(access)
+			}
+
+			r.Files[path] = access
+		}
+
+		for path, trace := range w.traces[pid] {
+			r.Paths[path] = r.Paths[path].add(trace.activity)
+		}
+	}
+
+	for path, a := range r.Paths {
+		a.Path = path
+		r.Paths[path] = a
+	}
+
+	r.Incomplete = w.incomplete
+
+	return r
+}
+
+// apply builds the --working-set summary if top is positive and the
+// --fs-trace one if traceTop is.
+func (r fileReport) apply(m *Metrics, top, traceTop int) {
+	if top > 0 {
+		m.WorkingSet = newWorkingSet(r.Files, top, r.Incomplete)
+	}
+
+	if traceTop > 0 {
+		m.FSTrace = newFSTrace(r.Paths, traceTop, r.Incomplete)
+	}
+}
+
+// sudoFileWatcher is a file watch run by the helper's watch-files action.
+type sudoFileWatcher struct {
+	cmd      *exec.Cmd
+	helper   *exec.Cmd
+	stdin    io.WriteCloser
+	stdout   *bufio.Reader
+	top      int
+	traceTop int
+}
+
+// watchFilesWithSudo starts the helper's watch-files action and waits for
+// the watch to be on, so that the command cannot open files before it is.
+func watchFilesWithSudo(cmd *exec.Cmd, top, traceTop int) (instrument, error) {
+	helper, err := sudoHelper(privilegedWatchFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	helper.Stderr = os.Stderr
+
+	stdin, err := helper.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := helper.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := startHelper(helper); err != nil {
+		return nil, sudoError(helper, err)
+	}
+
+	w := &sudoFileWatcher{cmd: cmd, helper: helper, stdin: stdin, stdout: bufio.NewReader(stdout), top: top, traceTop: traceTop}
+
+	if line, _ := w.stdout.ReadString('\n'); line != "ready\n" {
+		_ = stdin.Close()
+
+		if err := waitHelper(helper); err != nil {
+			return nil, fmt.Errorf("starting file watch: %w", sudoError(helper, err))
+		}
+
+		return nil, fmt.Errorf("starting file watch: %s did not start the watch", strings.Join(helper.Args, " "))
+	}
+
+	return w, nil
+}
+
+// finish sends the helper the command's PID and reads back its report.
+// Without one, the helper stops without reporting anything.
+func (w *sudoFileWatcher) finish(m *Metrics, _ time.Time) {
+	if w.cmd.ProcessState != nil {
+		fmt.Fprintln(w.stdin, w.cmd.ProcessState.Pid())
+	}
+
+	_ = w.stdin.Close()
+
+	var r fileReport
+	decodeErr := json.NewDecoder(w.stdout).Decode(&r)
+
+	if err := waitHelper(w.helper); err != nil {
+		fmt.Fprintf(os.Stderr, "ztime: file watch: %v\n", sudoError(w.helper, err))
+
+		return
+	}
+
+	if w.cmd.ProcessState == nil {
+		return
+	}
+
+	if decodeErr != nil {
+		fmt.Fprintf(os.Stderr, "ztime: file watch: reading the helper's report: %v\n", decodeErr)
+
+		return
+	}
+
+	r.apply(m, w.top, w.traceTop)
+}
+
+// serveFileWatch is the watch-files action of the privileged helper. It
+// prints "ready" once the watch is on, reads the command's PID from stdin
+// once the run is over, and writes the fileReport of that process tree as
+// JSON. Under sudo only the processes of the user who ran it are reported.
+func serveFileWatch() error {
+	w, err := startFileWatch(1, 1)
+	if err != nil {
+		return err
+	}
+
+	if uid, _, ok := sudoUser(); ok {
+		w.owner = uid
+	}
+
+	fmt.Println("ready")
+
+	var pid int
+
+	_, err = fmt.Fscan(os.Stdin, &pid)
+
+	w.stop()
+
+	if err != nil {
+		// ztime had no command to report on.
+		return nil //nolint:nilerr // Stopping without a PID is how ztime cancels the watch.
+	}
+
+	return json.NewEncoder(os.Stdout).Encode(w.report(pid))
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestParseFdinfoPos(t *testing.T) {
+	t.Parallel()
+
+	if pos, ok := parseFdinfoPos([]byte("pos:\t65536\nflags:\t0100000\nmnt_id:\t25\nino:\t1234\n")); !ok || pos != 65536 {
+		t.Errorf("parseFdinfoPos() = %d, %v; want 65536", pos, ok)
+	}
+
+	if _, ok := parseFdinfoPos([]byte("flags:\t0100000\n")); ok {
+		t.Error("parseFdinfoPos() found a position in fdinfo without one")
+	}
+}
+
+func TestFileWatcherTrace(t *testing.T) {
+	t.Parallel()
+
+	w := &fileWatcher{
+		byPID:     map[int32]map[string]FileAccess{},
+		traces:    map[int32]map[string]*pathTrace{},
+		ancestors: map[int32][]int{},
+	}
+
+	read := FileAccess{Path: "/f", Read: true}
+
+	// Two opens of /f, reading 300 and then 50 bytes.
+	w.record(1, read, unix.FAN_OPEN|unix.FAN_ACCESS, 100)
+	w.record(1, read, unix.FAN_ACCESS, 200)
+	w.record(1, read, unix.FAN_OPEN, 0)
+	w.record(1, read, unix.FAN_ACCESS, 50)
+	w.record(1, FileAccess{Path: "/f", Written: true}, unix.FAN_MODIFY, 20)
+
+	trace := w.traces[1]["/f"]
+
+	want := PathActivity{Path: "/f", Opens: 2, Reads: 3, Writes: 1, ReadBytes: 350, WriteBytes: 20}
+	if trace.activity != want {
+		t.Errorf("activity = %+v, want %+v", trace.activity, want)
+	}
+}
+
+func TestPositionDelta(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		last, current map[int]int64
+		want          int64
+	}{
+		{"first event", nil, map[int]int64{3: 4096}, 4096},
+		{"moved on", map[int]int64{3: 4096}, map[int]int64{3: 6144}, 2048},
+		{"not moved", map[int]int64{3: 4096}, map[int]int64{3: 4096}, 0},
+		{"rewound", map[int]int64{3: 4096}, map[int]int64{3: 512}, 512},
+		{"two descriptors", map[int]int64{3: 100, 4: 0}, map[int]int64{3: 150, 4: 30, 5: 10}, 90},
+		{"closed", map[int]int64{3: 100}, map[int]int64{}, 0},
+	}
+
+	for _, tt := range tests {
+		if got := positionDelta(tt.last, tt.current); got != tt.want {
+			t.Errorf("%s: positionDelta() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWatchFilesWithSudo(t *testing.T) {
+	// The fake helper reports one file read by whatever PID ztime sends.
+	dir := t.TempDir()
+	pid := filepath.Join(dir, "pid")
+	fakeSudoScript(t, `echo ready
+read pid; echo "$pid" > `+shellQuote(pid)+`
+echo '{"files":{"/src/main.go":{"path":"/src/main.go","bytes":512,"read":true}},"paths":{"/src/main.go":{"path":"/src/main.go","opens":1,"reads":1,"read_bytes":512}}}'`)
+
+	cmd := exec.CommandContext(context.Background(), "true")
+
+	w, err := watchFiles(cmd, 5, 5, true)
+	if err != nil {
+		t.Fatalf("watchFiles() error = %v", err)
+	}
+
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var m Metrics
+	w.finish(&m, time.Now())
+
+	if got, err := os.ReadFile(pid); err != nil || strings.TrimSpace(string(got)) != strconv.Itoa(cmd.ProcessState.Pid()) {
+		t.Errorf("helper got PID %q, %v; want %d", got, err, cmd.ProcessState.Pid())
+	}
+
+	if m.WorkingSet == nil || m.FSTrace == nil {
+		t.Fatalf("WorkingSet = %+v, FSTrace = %+v; want both from the helper's report", m.WorkingSet, m.FSTrace)
+	}
+}
+
+func TestWatchFilesWithSudoFails(t *testing.T) {
+	fakeSudo(t, 1)
+
+	if _, err := watchFiles(exec.CommandContext(context.Background(), "true"), 5, 0, true); err == nil {
+		t.Error("watchFiles() succeeded without the helper's watch")
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+var errWorkingSetUnsupported = errors.New("--working-set and --fs-trace are not supported")
+
+func watchFiles(*exec.Cmd, int, int, bool) (instrument, error) {
+	return nil, fmt.Errorf("%w on %s", errWorkingSetUnsupported, runtime.GOOS)
+}
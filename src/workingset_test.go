@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewWorkingSet(t *testing.T) {
+	t.Parallel()
+
+	files := map[string]FileAccess{
+		"/lib/libc.so":  {Path: "/lib/libc.so", Bytes: 2048, Read: true},
+		"/tmp/out":      {Path: "/tmp/out", Bytes: 4096, Written: true},
+		"/etc/hosts":    {Path: "/etc/hosts", Bytes: 128, Read: true},
+		"/etc/hostname": {Path: "/etc/hostname", Bytes: 128, Read: true, Written: true},
+	}
+
+	ws := newWorkingSet(files, 3, false)
+
+	if ws.Files != 4 || ws.TotalBytes != 6400 || ws.Read != 3 || ws.Written != 2 {
+		t.Errorf("newWorkingSet() = %+v", ws)
+	}
+
+	var top []string
+	for _, f := range ws.Top {
+		top = append(top, f.Path)
+	}
+
+	if got, want := strings.Join(top, " "), "/tmp/out /lib/libc.so /etc/hostname"; got != want {
+		t.Errorf("Top = %s, want %s", got, want)
+	}
+}
+
+func TestFileAccessMerge(t *testing.T) {
+	t.Parallel()
+
+	got := FileAccess{Path: "/f", Bytes: 1, Read: true}.merge(FileAccess{Path: "/f", Bytes: 9, Written: true})
+	if got != (FileAccess{Path: "/f", Bytes: 9, Read: true, Written: true}) {
+		t.Errorf("merge() = %+v", got)
+	}
+}
+
+func TestWorkingSetNote(t *testing.T) {
+	t.Parallel()
+
+	if got := workingSetNote(nil); got != "" {
+		t.Errorf("workingSetNote(nil) = %q, want empty", got)
+	}
+
+	ws := &WorkingSet{Files: 1, TotalBytes: 2048, Read: 1, Incomplete: true, Top: []FileAccess{{Path: "/f", Bytes: 2048, Read: true}}}
+
+	const want = "opened 1 files (2.0 KiB; 1 read, 0 written), some accesses were missed\n     2.0 KiB  r-  /f"
+	if got := workingSetNote(ws); got != want {
+		t.Errorf("workingSetNote() = %q, want %q", got, want)
+	}
+}